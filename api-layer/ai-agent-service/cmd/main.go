@@ -189,7 +189,10 @@ func main() {
 	buildClient := clients.NewBuildServiceClient(pkg.GetEnv("BUILD_SERVICE_URL", "http://localhost:5050"))
 	log.Printf("✅ Build service client initialized")
 
-	monitoringClient := clients.NewMonitoringServiceClient(pkg.GetEnv("MONITORING_SERVICE_URL", "http://localhost:5110"))
+	monitoringClient := clients.NewMonitoringServiceClient(
+		pkg.GetEnv("MONITORING_SERVICE_URL", "http://localhost:5110"),
+		pkg.GetEnv("MONITORING_SERVICE_TOKEN", ""),
+	)
 	log.Printf("✅ Monitoring service client initialized")
 
 	platformDB := clients.NewPlatformDB(db.DB)
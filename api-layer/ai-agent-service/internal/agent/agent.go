@@ -111,6 +111,10 @@ func (a *Agent) EnrichContext(ctx context.Context, userMessage, projectID string
 		if section != "" {
 			parts = append(parts, section)
 		}
+
+		if section := a.fetchAppMetricsContext(ctx, projectID); section != "" {
+			parts = append(parts, section)
+		}
 	}
 
 	if len(parts) == 0 {
@@ -374,6 +378,49 @@ func (a *Agent) fetchAlertsContext(ctx context.Context, projectID string) string
 	return sb.String()
 }
 
+// fetchAppMetricsContext summarizes the metrics scraped from the project's own
+// /metrics endpoint. Nothing is added when the project did not opt in.
+func (a *Agent) fetchAppMetricsContext(ctx context.Context, projectID string) string {
+	if a.monitoringClient == nil {
+		return ""
+	}
+
+	appMetrics, err := a.monitoringClient.GetAppMetrics(ctx, projectID)
+	if err != nil {
+		return fmt.Sprintf("_Could not fetch application metrics: %v_", err)
+	}
+	if !appMetrics.Config.Enabled {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("### Application Metrics (scraped from `%s` every %ds)\n",
+		appMetrics.Config.Path, appMetrics.Config.IntervalSeconds))
+
+	for _, d := range appMetrics.Deployments {
+		sb.WriteString(fmt.Sprintf("\n**%s** `%s`", d.Environment, d.DeploymentID))
+		switch {
+		case d.Scrape.LastError != "":
+			sb.WriteString(fmt.Sprintf(" — last scrape failed: %s", d.Scrape.LastError))
+		case d.Scrape.LastSuccessAt == nil:
+			sb.WriteString(" — not scraped yet")
+		}
+		if d.Scrape.DroppedSeries > 0 {
+			sb.WriteString(fmt.Sprintf(" — %d series dropped (limit %d)", d.Scrape.DroppedSeries, d.Scrape.SeriesLimit))
+		}
+		sb.WriteString("\n")
+
+		for _, m := range d.Metrics {
+			if m.RatePerSec != nil {
+				sb.WriteString(fmt.Sprintf("- `%s` (%s): %.4g/s\n", m.Name, m.Type, *m.RatePerSec))
+			} else {
+				sb.WriteString(fmt.Sprintf("- `%s` (%s): %.4g\n", m.Name, m.Type, m.Latest))
+			}
+		}
+	}
+	return sb.String()
+}
+
 func statusEmoji(status string) string {
 	switch strings.ToLower(status) {
 	case "completed", "success", "active":
@@ -452,6 +499,10 @@ func (a *Agent) ExecuteTool(ctx context.Context, name string, params map[string]
 		return a.toolGetDeploymentLogs(ctx, params, projectID)
 	case "get_alerts":
 		return a.toolGetAlerts(ctx, params, projectID)
+	case "get_incident_postmortem":
+		return a.toolGetIncidentPostmortem(ctx, params)
+	case "save_incident_postmortem":
+		return a.toolSaveIncidentPostmortem(ctx, params)
 	default:
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
@@ -598,6 +649,40 @@ func (a *Agent) toolGetAlerts(ctx context.Context, params map[string]interface{}
 	return string(jsonResult), nil
 }
 
+func (a *Agent) toolGetIncidentPostmortem(ctx context.Context, params map[string]interface{}) (string, error) {
+	incidentID, ok := params["incidentId"].(string)
+	if !ok || incidentID == "" {
+		return "", fmt.Errorf("incidentId is required")
+	}
+	if a.monitoringClient == nil {
+		return "", fmt.Errorf("monitoring client not configured")
+	}
+	draft, err := a.monitoringClient.GetIncidentPostmortem(ctx, incidentID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get postmortem draft: %w", err)
+	}
+	jsonResult, _ := json.MarshalIndent(draft, "", "  ")
+	return string(jsonResult), nil
+}
+
+func (a *Agent) toolSaveIncidentPostmortem(ctx context.Context, params map[string]interface{}) (string, error) {
+	incidentID, ok := params["incidentId"].(string)
+	if !ok || incidentID == "" {
+		return "", fmt.Errorf("incidentId is required")
+	}
+	sections, ok := params["sections"].(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("sections is required")
+	}
+	if a.monitoringClient == nil {
+		return "", fmt.Errorf("monitoring client not configured")
+	}
+	if err := a.monitoringClient.SaveIncidentPostmortem(ctx, incidentID, sections); err != nil {
+		return "", fmt.Errorf("failed to save postmortem: %w", err)
+	}
+	return "Postmortem saved", nil
+}
+
 // AvailableTools returns the list of tools available to the AI
 func (a *Agent) AvailableTools() []Tool {
 	return []Tool{
@@ -703,5 +788,35 @@ func (a *Agent) AvailableTools() []Tool {
 				},
 			},
 		},
+		{
+			Name:        "get_incident_postmortem",
+			Description: "Get the postmortem draft of an incident: timeline, grouped alerts, deployments in the window, MTTA/MTTR and the sections to fill in",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"incidentId": map[string]interface{}{
+						"type": "string",
+					},
+				},
+				"required": []string{"incidentId"},
+			},
+		},
+		{
+			Name:        "save_incident_postmortem",
+			Description: "Save the filled-in postmortem sections for an incident",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"incidentId": map[string]interface{}{
+						"type": "string",
+					},
+					"sections": map[string]interface{}{
+						"type":        "object",
+						"description": "summary, impact, root_cause, contributing_factors (string[]), resolution, action_items ({description, owner, priority}[]), lessons_learned",
+					},
+				},
+				"required": []string{"incidentId", "sections"},
+			},
+		},
 	}
 }
@@ -1,6 +1,7 @@
 package clients
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 
 type MonitoringServiceClient struct {
 	baseURL    string
+	token      string // Service token the monitoring service accepts (SERVICE_API_TOKENS)
 	httpClient *http.Client
 }
 
@@ -43,15 +45,51 @@ type Alert struct {
 	ResolvedAt *time.Time `json:"resolvedAt"`
 }
 
-func NewMonitoringServiceClient(baseURL string) *MonitoringServiceClient {
+// AppMetrics is what the monitoring service scraped from a project's own /metrics
+// endpoint, condensed per active deployment
+type AppMetrics struct {
+	Config struct {
+		Enabled         bool   `json:"enabled"`
+		Path            string `json:"path"`
+		IntervalSeconds int    `json:"interval_seconds"`
+	} `json:"config"`
+	Deployments []struct {
+		DeploymentID string `json:"deployment_id"`
+		Environment  string `json:"environment"`
+		Scrape       struct {
+			LastSuccessAt *time.Time `json:"last_success_at"`
+			LastError     string     `json:"last_error"`
+			SeriesCount   int        `json:"series_count"`
+			DroppedSeries int        `json:"dropped_series"`
+			SeriesLimit   int        `json:"series_limit"`
+		} `json:"scrape"`
+		Metrics []struct {
+			Name        string   `json:"name"`
+			Type        string   `json:"type"`
+			SeriesCount int      `json:"series_count"`
+			Latest      float64  `json:"latest"`
+			RatePerSec  *float64 `json:"rate_per_sec"`
+		} `json:"metrics"`
+	} `json:"deployments"`
+}
+
+func NewMonitoringServiceClient(baseURL, token string) *MonitoringServiceClient {
 	return &MonitoringServiceClient{
 		baseURL: baseURL,
+		token:   token,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
 }
 
+func (c *MonitoringServiceClient) do(req *http.Request) (*http.Response, error) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.httpClient.Do(req)
+}
+
 func (c *MonitoringServiceClient) GetDeploymentMetrics(ctx context.Context, deploymentID string, duration time.Duration) ([]DeploymentMetrics, error) {
 	url := fmt.Sprintf("%s/api/deployments/%s/metrics?duration=%s", c.baseURL, deploymentID, duration.String())
 
@@ -60,7 +98,7 @@ func (c *MonitoringServiceClient) GetDeploymentMetrics(ctx context.Context, depl
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -88,7 +126,7 @@ func (c *MonitoringServiceClient) GetAlerts(ctx context.Context, projectID strin
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -116,7 +154,7 @@ func (c *MonitoringServiceClient) GetAnomalies(ctx context.Context, deploymentID
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -135,3 +173,86 @@ func (c *MonitoringServiceClient) GetAnomalies(ctx context.Context, deploymentID
 
 	return anomalies.Anomalies, nil
 }
+
+// GetAppMetrics returns the application metrics summary of a project
+func (c *MonitoringServiceClient) GetAppMetrics(ctx context.Context, projectID string) (*AppMetrics, error) {
+	url := fmt.Sprintf("%s/api/projects/%s/app-metrics", c.baseURL, projectID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("monitoring service error: status %d", resp.StatusCode)
+	}
+
+	var metrics AppMetrics
+	if err := json.NewDecoder(resp.Body).Decode(&metrics); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &metrics, nil
+}
+
+// GetIncidentPostmortem returns the postmortem draft of an incident: the generated facts
+// (timeline, alerts, deployments, MTTA/MTTR) and the sections left to fill in
+func (c *MonitoringServiceClient) GetIncidentPostmortem(ctx context.Context, incidentID string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/api/incidents/%s/postmortem", c.baseURL, incidentID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("monitoring service error: status %d", resp.StatusCode)
+	}
+
+	var draft map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&draft); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return draft, nil
+}
+
+// SaveIncidentPostmortem stores the filled-in postmortem sections
+func (c *MonitoringServiceClient) SaveIncidentPostmortem(ctx context.Context, incidentID string, sections map[string]interface{}) error {
+	url := fmt.Sprintf("%s/api/incidents/%s/postmortem", c.baseURL, incidentID)
+
+	body, err := json.Marshal(map[string]interface{}{"sections": sections})
+	if err != nil {
+		return fmt.Errorf("failed to encode postmortem: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("monitoring service error: status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -145,6 +145,8 @@ func main() {
 
 		log.Println("🛑 Shutting down gracefully...")
 		w.Close()
+		// Delivers or spools the platform log events still buffered
+		logger.Close()
 		db.Close()
 		os.Exit(0)
 	}()
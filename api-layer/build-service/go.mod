@@ -3,6 +3,8 @@ module build-service
 go 1.24.1
 
 require (
+	github.com/AALXX/Obtura/shared/healthcheck v1.0.0
+	github.com/AALXX/Obtura/shared/platformlog v1.0.0
 	github.com/docker/docker v27.3.1+incompatible
 	github.com/docker/go-units v0.5.0
 	github.com/gin-gonic/gin v1.11.0
@@ -99,3 +101,6 @@ require (
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	gotest.tools/v3 v3.5.2 // indirect
 )
+
+replace github.com/AALXX/Obtura/shared/healthcheck => ../../shared/healthcheck
+replace github.com/AALXX/Obtura/shared/platformlog => ../../shared/platformlog
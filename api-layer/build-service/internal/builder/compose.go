@@ -5,6 +5,8 @@ import (
 	"log"
 	"regexp"
 	"strings"
+
+	"github.com/AALXX/Obtura/shared/healthcheck"
 )
 
 func NormalizeServiceName(path string) string {
@@ -195,57 +197,27 @@ func getEnvironmentVariables(framework *Framework, serviceName string) map[strin
 	return env
 }
 
-// getHealthCheck returns a health check configuration for the framework
+// getHealthCheck returns a health check configuration for the framework,
+// built from the same spec the deploy-service applies to the running container
 func getHealthCheck(framework *Framework) string {
-	var check strings.Builder
-
-	switch {
-	case framework.Name == "FastAPI" || framework.Name == "Django" || framework.Name == "Flask":
-		check.WriteString("      test: [\"CMD\", \"curl\", \"-f\", \"http://localhost:8000/health\"]\n")
-		check.WriteString("      interval: 30s\n")
-		check.WriteString("      timeout: 10s\n")
-		check.WriteString("      retries: 3\n")
-		check.WriteString("      start_period: 40s\n")
-
-	case framework.Name == "Express.js" || framework.Name == "NestJS" || framework.Name == "Fastify" ||
-		framework.Name == "Koa" || framework.Name == "Hono" || framework.Name == "Bun (Express)" ||
-		framework.Name == "Bun (Hono)":
-		check.WriteString(fmt.Sprintf("      test: [\"CMD\", \"wget\", \"--no-verbose\", \"--tries=1\", \"--spider\", \"http://localhost:%d/health\"]\n", framework.Port))
-		check.WriteString("      interval: 30s\n")
-		check.WriteString("      timeout: 10s\n")
-		check.WriteString("      retries: 3\n")
-
-	case strings.HasPrefix(framework.Name, "Go"):
-		check.WriteString(fmt.Sprintf("      test: [\"CMD\", \"wget\", \"--no-verbose\", \"--tries=1\", \"--spider\", \"http://localhost:%d/health\"]\n", framework.Port))
-		check.WriteString("      interval: 30s\n")
-		check.WriteString("      timeout: 10s\n")
-		check.WriteString("      retries: 3\n")
-
-	case framework.Name == "Spring Boot":
-		check.WriteString("      test: [\"CMD\", \"curl\", \"-f\", \"http://localhost:8080/actuator/health\"]\n")
-		check.WriteString("      interval: 30s\n")
-		check.WriteString("      timeout: 10s\n")
-		check.WriteString("      retries: 3\n")
-
-	case strings.HasPrefix(framework.Name, ".NET") || strings.HasPrefix(framework.Name, "ASP.NET"):
-		check.WriteString(fmt.Sprintf("      test: [\"CMD\", \"curl\", \"-f\", \"http://localhost:%d/health\"]\n", framework.Port))
-		check.WriteString("      interval: 30s\n")
-		check.WriteString("      timeout: 10s\n")
-		check.WriteString("      retries: 3\n")
-
-	case framework.Name == "Phoenix":
-		check.WriteString("      test: [\"CMD\", \"curl\", \"-f\", \"http://localhost:4000/health\"]\n")
-		check.WriteString("      interval: 30s\n")
-		check.WriteString("      timeout: 10s\n")
-		check.WriteString("      retries: 3\n")
+	spec := framework.HealthCheckSpec
+	if spec == nil {
+		spec = &HealthCheckSpec{Path: healthcheck.DefaultPath}
+	}
 
-	case framework.Name == "Ruby on Rails":
-		check.WriteString("      test: [\"CMD\", \"curl\", \"-f\", \"http://localhost:3000/health\"]\n")
-		check.WriteString("      interval: 30s\n")
-		check.WriteString("      timeout: 10s\n")
-		check.WriteString("      retries: 3\n")
+	target := fmt.Sprintf("http://localhost:%d%s", framework.Port, healthcheck.NormalizePath(spec.Path))
+	test := fmt.Sprintf("wget -q --spider %s || curl -fsS -o /dev/null %s || exit 1", target, target)
+	if spec.ExpectedStatus > 0 {
+		test = fmt.Sprintf("curl -s -o /dev/null -w '%%{http_code}' %s | grep -q '^%d$' || exit 1", target, spec.ExpectedStatus)
 	}
 
+	var check strings.Builder
+	check.WriteString(fmt.Sprintf("      test: [\"CMD-SHELL\", %q]\n", test))
+	check.WriteString(fmt.Sprintf("      interval: %ds\n", int(spec.Interval().Seconds())))
+	check.WriteString(fmt.Sprintf("      timeout: %ds\n", int(spec.Timeout().Seconds())))
+	check.WriteString(fmt.Sprintf("      retries: %d\n", spec.RetryCount()))
+	check.WriteString(fmt.Sprintf("      start_period: %ds\n", int(spec.StartPeriod().Seconds())))
+
 	return check.String()
 }
 
@@ -22,6 +22,8 @@ type Framework struct {
 	IsStatic    bool              `json:"isStatic"`
 	EnvVars     map[string]string `json:"envVars,omitempty"`
 	HealthCheck string            `json:"healthCheck,omitempty"`
+
+	HealthCheckSpec *HealthCheckSpec `json:"healthCheckSpec,omitempty"`
 }
 
 type DatabaseDependency struct {
@@ -201,6 +203,7 @@ func detectFrameworkInDir(dirPath, relativePath string) *Framework {
 	}
 
 	framework.Path = relativePath
+	framework.HealthCheckSpec = resolveHealthCheckSpec(dirPath, framework)
 	return framework
 }
 
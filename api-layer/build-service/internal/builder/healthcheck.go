@@ -0,0 +1,91 @@
+package builder
+
+import (
+	"build-service/pkg"
+	"encoding/json"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/AALXX/Obtura/shared/healthcheck"
+)
+
+// RepoConfigFile is the optional per-service config file read from the repository
+const RepoConfigFile = "obtura.json"
+
+// HealthCheckSpec is stored in the build metadata and consumed by the deploy-service,
+// which applies it to the Docker HEALTHCHECK, Traefik and the monitoring checks
+type HealthCheckSpec = healthcheck.Spec
+
+// frameworkHealthPaths covers frameworks whose conventional health endpoint is not "/health"
+var frameworkHealthPaths = map[string]string{
+	"Spring Boot":   "/actuator/health",
+	"Ruby on Rails": "/up",
+}
+
+// resolveHealthCheckSpec prefers obtura.json in the service directory, then the detected path
+func resolveHealthCheckSpec(dirPath string, framework *Framework) *HealthCheckSpec {
+	if spec := loadRepoHealthCheck(dirPath); spec != nil {
+		log.Printf("Using health check %s from %s", spec.Path, RepoConfigFile)
+		return spec
+	}
+
+	path := framework.HealthCheck
+	if p, ok := frameworkHealthPaths[framework.Name]; ok && path == "" {
+		path = p
+	}
+	if path == "" {
+		if framework.IsStatic {
+			path = "/"
+		} else {
+			return nil
+		}
+	}
+
+	return &HealthCheckSpec{Path: path, Source: "framework_detection"}
+}
+
+// loadRepoHealthCheck reads {"healthCheck": "/path"} or {"healthCheck": {...}} from obtura.json
+func loadRepoHealthCheck(dirPath string) *HealthCheckSpec {
+	configPath := filepath.Join(dirPath, RepoConfigFile)
+	if !pkg.FileExists(configPath) {
+		return nil
+	}
+
+	data, err := pkg.ReadFile(configPath)
+	if err != nil {
+		return nil
+	}
+
+	var config struct {
+		HealthCheck json.RawMessage `json:"healthCheck"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil || len(config.HealthCheck) == 0 {
+		if err != nil {
+			log.Printf("⚠️ Ignoring invalid %s: %v", configPath, err)
+		}
+		return nil
+	}
+
+	var spec HealthCheckSpec
+	var path string
+	if err := json.Unmarshal(config.HealthCheck, &path); err == nil {
+		spec.Path = path
+	} else if err := json.Unmarshal(config.HealthCheck, &spec); err != nil {
+		log.Printf("⚠️ Ignoring invalid healthCheck in %s: %v", configPath, err)
+		return nil
+	}
+
+	configured := strings.TrimSpace(spec.Path)
+	if configured == "" {
+		return nil
+	}
+	// The path is written into the compose file's CMD-SHELL test, so it gets the same
+	// rules the deploy-service applies to the Docker HEALTHCHECK
+	spec.Path = healthcheck.NormalizePath(configured)
+	if spec.Path == healthcheck.DefaultPath && strings.TrimLeft(configured, "/") != "" {
+		log.Printf("⚠️ Ignoring unsafe healthCheck path %q in %s, using %s", configured, configPath, spec.Path)
+	}
+	spec.Source = "repo_config"
+	return &spec
+}
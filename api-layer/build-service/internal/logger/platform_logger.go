@@ -4,7 +4,7 @@ import (
 	"context"
 	"os"
 
-	"build-service/pkg/platformlog"
+	"github.com/AALXX/Obtura/shared/platformlog"
 )
 
 var platformLogger *platformlog.Client
@@ -18,8 +18,12 @@ func InitPlatformLogger() {
 
 	// Use console transport as fallback and HTTP transport for remote logging
 	consoleTransport := platformlog.NewConsoleTransport()
-	httpTransport := platformlog.NewHTTPTransport(monitoringURL, "")
-	platformLogger = platformlog.NewClient("build-service", consoleTransport, httpTransport)
+	httpTransport := platformlog.NewHTTPTransport(monitoringURL, os.Getenv("MONITORING_SERVICE_TOKEN"))
+	// Batches the monitoring service does not accept are spooled and retried, and
+	// survive a restart when PLATFORM_LOG_SPOOL_DIR is on a volume
+	platformLogger = platformlog.NewClientWithOptions("build-service", platformlog.Options{
+		SpoolDir: os.Getenv("PLATFORM_LOG_SPOOL_DIR"),
+	}, consoleTransport, httpTransport)
 }
 
 // GetPlatformLogger returns the platform logger instance
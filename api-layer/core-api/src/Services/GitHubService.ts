@@ -7,6 +7,7 @@ import redis from '../config/redis';
 import { type Request, type Response } from 'express';
 import { getCompanyIdFromSessionToken } from '../lib/utils';
 import logging from '../config/logging';
+import rabbitmq from '../config/rabbitmql';
 
 const GITHUB_APP_ID = process.env.GITHUB_APP_ID!;
 const GITHUB_APP_CLIENT_ID = process.env.GITHUB_APP_CLIENT_ID!;
@@ -15,6 +16,10 @@ const GITHUB_APP_WEBHOOK_SECRET = process.env.GITHUB_APP_WEBHOOK_SECRET!;
 
 const GITHUB_APP_PRIVATE_KEY = fs.readFileSync(process.env.GITHUB_APP_PRIVATE_KEY_PATH || '', 'utf-8');
 
+// Reduces p.git_repo_url (https://github.com/owner/repo.git, git@github.com:owner/repo...) to
+// the lowercase "owner/repo" full name webhooks are matched on
+const PROJECT_REPO_FULL_NAME_SQL = `lower(regexp_replace(p.git_repo_url, '^(https?://|ssh://)?([^@/]+@)?github\\.com[:/]|(\\.git)?/*$', '', 'g'))`;
+
 const GetInstallationURL = async (req: Request, res: Response) => {
     try {
         const { accessToken } = req.params;
@@ -449,8 +454,8 @@ const handlePushEvent = async (payload: any) => {
         `SELECT p.* FROM projects p
      JOIN github_installations gi ON gi.user_id = p.user_id
      WHERE gi.installation_id = $1
-     AND p.git_repo_url LIKE $2`,
-        [installation.id, `%${repository.full_name}%`],
+     AND ${PROJECT_REPO_FULL_NAME_SQL} = lower($2)`,
+        [installation.id, repository.full_name],
     );
 
     for (const project of rows) {
@@ -465,17 +470,178 @@ const handlePushEvent = async (payload: any) => {
     }
 };
 
-const handlePullRequestEvent = (payload: any) => {
-    const { action, pull_request, installation } = payload;
+const handlePullRequestEvent = async (payload: any) => {
+    const { action, pull_request, repository, installation } = payload;
+
+    const { rows: projects } = await pool.query(
+        `SELECT p.id, p.slug FROM projects p
+     LEFT JOIN project_preview_settings pps ON pps.project_id = p.id
+     WHERE p.github_installation_id = $1
+     AND ${PROJECT_REPO_FULL_NAME_SQL} = lower($2)
+     AND COALESCE(pps.enabled, true) = true`,
+        [installation.id, repository.full_name],
+    );
+
+    const subdomain = `pr-${pull_request.number}`;
 
-    if (action === 'opened' || action === 'synchronize') {
+    if (action === 'opened' || action === 'reopened' || action === 'synchronize') {
         console.log(`PR ${action}: ${pull_request.html_url}`);
 
-        // TODO: Create preview environment
+        // A fork's head is code from outside the repository; building it would run it
+        // with the project's environment, so only branches of the repository get previews
+        if (!isSameRepositoryPullRequest(pull_request, repository)) {
+            console.log(`Skipping preview for fork PR ${pull_request.html_url}`);
+            return;
+        }
+
+        for (const project of projects) {
+            await createPreviewDeployment(project, pull_request, subdomain);
+        }
     } else if (action === 'closed') {
         console.log(`PR closed: ${pull_request.html_url}`);
 
-        // TODO: Destroy preview environment
+        for (const project of projects) {
+            await destroyPreviewEnvironment(project, pull_request, subdomain);
+        }
+    }
+};
+
+// The head repository is null when the fork has been deleted
+const isSameRepositoryPullRequest = (pullRequest: any, repository: any): boolean => {
+    const headRepo = pullRequest.head?.repo?.full_name;
+    return typeof headRepo === 'string' && headRepo.toLowerCase() === String(repository.full_name).toLowerCase();
+};
+
+// Builds the PR head and deploys it as a preview; the deploy-service assigns the domain,
+// clones the staging environment and enforces the plan's preview quota
+const createPreviewDeployment = async (project: any, pullRequest: any, subdomain: string) => {
+    const client = await pool.connect();
+
+    try {
+        await client.query('BEGIN');
+
+        await client.query(
+            `INSERT INTO preview_environments (
+                project_id, pull_request_number, pull_request_url, pull_request_title, head_branch, head_commit, subdomain, status
+            ) VALUES ($1, $2, $3, $4, $5, $6, $7, 'pending')
+            ON CONFLICT (project_id, subdomain) DO UPDATE SET
+                pull_request_number = EXCLUDED.pull_request_number,
+                pull_request_url = EXCLUDED.pull_request_url,
+                pull_request_title = EXCLUDED.pull_request_title,
+                head_branch = EXCLUDED.head_branch,
+                head_commit = EXCLUDED.head_commit,
+                status = 'pending',
+                closed_at = NULL,
+                terminated_at = NULL,
+                termination_reason = NULL,
+                updated_at = NOW()`,
+            [project.id, pullRequest.number, pullRequest.html_url, pullRequest.title, pullRequest.head.ref, pullRequest.head.sha, subdomain],
+        );
+
+        const buildResult = await client.query('INSERT INTO builds (project_id, commit_hash, branch, status) VALUES ($1, $2, $3, $4) RETURNING id', [project.id, pullRequest.head.sha, pullRequest.head.ref, 'PENDING']);
+        const buildId = buildResult.rows[0].id;
+
+        const deploymentInsert = await client.query(
+            `INSERT INTO deployments (
+                project_id,
+                build_id,
+                environment,
+                branch,
+                commit_hash,
+                commit_message,
+                commit_author,
+                deployment_strategy,
+                subdomain,
+                deployment_trigger,
+                approval_required,
+                status,
+                is_ephemeral,
+                deployment_started_at
+            ) VALUES ($1, $2, 'preview', $3, $4, $5, $6, 'blue_green', $7, 'pull_request', false, 'pending', true, NOW())
+            RETURNING id`,
+            [project.id, buildId, pullRequest.head.ref, pullRequest.head.sha, pullRequest.title, pullRequest.user?.login || 'Unknown', subdomain],
+        );
+        const deploymentId = deploymentInsert.rows[0].id;
+
+        await client.query(
+            `INSERT INTO deployment_events (
+                deployment_id,
+                event_type,
+                event_message,
+                severity
+            ) VALUES ($1, $2, $3, $4)`,
+            [deploymentId, 'started', `Preview deployment triggered by pull request #${pullRequest.number}`, 'info'],
+        );
+
+        await client.query('COMMIT');
+
+        await rabbitmq.connect();
+        const channel = await rabbitmq.getChannel();
+
+        await channel.publish(
+            'obtura.builds',
+            'build.triggered',
+            Buffer.from(
+                JSON.stringify({
+                    buildId: buildId,
+                    deploymentId: deploymentId,
+                    projectId: project.id,
+                    commitHash: pullRequest.head.sha,
+                    branch: pullRequest.head.ref,
+                    environment: 'preview',
+                    approvalRequired: false,
+                    deploy: true,
+                }),
+            ),
+            { persistent: true, timestamp: Date.now() },
+        );
+
+        logging.info('PREVIEW-DEPLOY', `Preview ${subdomain} queued for project ${project.id} (build ${buildId})`);
+    } catch (error: any) {
+        await client.query('ROLLBACK');
+        logging.error('PREVIEW-DEPLOY', `Failed to create preview ${subdomain} for project ${project.id}: ${error.message}`);
+    } finally {
+        client.release();
+    }
+};
+
+// Tears the preview down through the deploy-service cleanup queue
+const destroyPreviewEnvironment = async (project: any, pullRequest: any, subdomain: string) => {
+    try {
+        const { rowCount } = await pool.query(
+            `UPDATE preview_environments
+             SET closed_at = NOW(), status = 'terminating', termination_reason = 'pr_closed', updated_at = NOW()
+             WHERE project_id = $1 AND subdomain = $2 AND status <> 'terminated'`,
+            [project.id, subdomain],
+        );
+
+        if (!rowCount) {
+            return;
+        }
+
+        await rabbitmq.connect();
+        const channel = await rabbitmq.getChannel();
+
+        await channel.publish(
+            'obtura.deploys',
+            'project.cleanup',
+            Buffer.from(
+                JSON.stringify({
+                    projectId: project.id,
+                    containers: [],
+                    environment: 'preview',
+                    subdomain: subdomain,
+                    pullRequestNumber: pullRequest.number,
+                    reason: 'pr_closed',
+                    timestamp: Date.now(),
+                }),
+            ),
+            { persistent: true, timestamp: Date.now() },
+        );
+
+        logging.info('PREVIEW-DESTROY', `Published cleanup for preview ${subdomain} of project ${project.id}`);
+    } catch (error: any) {
+        logging.error('PREVIEW-DESTROY', `Failed to destroy preview ${subdomain} for project ${project.id}: ${error.message}`);
     }
 };
 
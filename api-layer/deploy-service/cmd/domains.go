@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"deploy-service/internal/deployment"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerDomainRoutes exposes custom domain management and the HTTP verification endpoint
+func registerDomainRoutes(r *gin.Engine, orchestrator *deployment.DeploymentOrchestrator) {
+	r.GET(deployment.DomainChallengePathPrefix+":token", func(c *gin.Context) {
+		token := c.Param("token")
+		if !orchestrator.LookupChallengeToken(c.Request.Context(), c.Request.Host, token) {
+			c.String(http.StatusNotFound, "not found")
+			return
+		}
+		c.String(http.StatusOK, token)
+	})
+
+	domains := r.Group("/api/projects/:projectId/domains")
+
+	domains.GET("", func(c *gin.Context) {
+		list, err := orchestrator.ListProjectDomains(c.Request.Context(), c.Param("projectId"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch domains"})
+			return
+		}
+		c.JSON(200, gin.H{"domains": list})
+	})
+
+	domains.POST("", func(c *gin.Context) {
+		var req struct {
+			Domain             string `json:"domain" binding:"required"`
+			Environment        string `json:"environment"`
+			VerificationMethod string `json:"verificationMethod"`
+			Wildcard           bool   `json:"wildcard"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		domain, err := orchestrator.AddProjectDomain(c.Request.Context(), c.Param("projectId"),
+			req.Environment, req.Domain, req.VerificationMethod, req.Wildcard)
+		if errors.Is(err, deployment.ErrDomainUnavailable) || errors.Is(err, deployment.ErrDomainQuotaExceeded) {
+			respondDomainError(c, err, "Failed to add domain")
+			return
+		}
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(201, gin.H{
+			"domain":       domain,
+			"verification": domain.VerificationInstructions(),
+		})
+	})
+
+	domains.POST("/:domainId/verify", func(c *gin.Context) {
+		domain, err := orchestrator.VerifyProjectDomain(c.Request.Context(), c.Param("projectId"), c.Param("domainId"))
+		if err != nil {
+			respondDomainError(c, err, "Failed to verify domain")
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"domain":       domain,
+			"verified":     domain.VerificationStatus == deployment.DomainStatusVerified,
+			"verification": domain.VerificationInstructions(),
+		})
+	})
+
+	domains.DELETE("/:domainId", func(c *gin.Context) {
+		err := orchestrator.RemoveProjectDomain(c.Request.Context(), c.Param("projectId"), c.Param("domainId"))
+		if errors.Is(err, deployment.ErrDomainNotFound) {
+			c.JSON(404, gin.H{"error": "Domain not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to remove domain"})
+			return
+		}
+		c.JSON(200, gin.H{"success": true})
+	})
+}
+
+func respondDomainError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, deployment.ErrDomainNotFound):
+		c.JSON(404, gin.H{"error": "Domain not found"})
+	case errors.Is(err, deployment.ErrDomainUnavailable):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, deployment.ErrDomainQuotaExceeded):
+		c.JSON(429, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"deploy-service/internal/deployment"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerJobRoutes exposes one-off and cron jobs, their runs and run logs
+func registerJobRoutes(r *gin.Engine, orchestrator *deployment.DeploymentOrchestrator) {
+	jobs := r.Group("/api/projects/:projectId/jobs")
+
+	jobs.GET("", func(c *gin.Context) {
+		list, err := orchestrator.ListProjectJobs(c.Request.Context(), c.Param("projectId"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch jobs"})
+			return
+		}
+		c.JSON(200, gin.H{"jobs": list})
+	})
+
+	jobs.POST("", func(c *gin.Context) {
+		job := deployment.ProjectJob{Enabled: true}
+		if err := c.ShouldBindJSON(&job); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		if err := job.Validate(); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := orchestrator.CreateProjectJob(c.Request.Context(), c.Param("projectId"), &job); err != nil {
+			respondJobError(c, err, "Failed to create job")
+			return
+		}
+		c.JSON(201, gin.H{"job": job})
+	})
+
+	jobs.GET("/:jobId", func(c *gin.Context) {
+		job, err := orchestrator.GetProjectJob(c.Request.Context(), c.Param("projectId"), c.Param("jobId"))
+		if err != nil {
+			respondJobError(c, err, "Failed to fetch job")
+			return
+		}
+		c.JSON(200, gin.H{"job": job})
+	})
+
+	jobs.PUT("/:jobId", func(c *gin.Context) {
+		job := deployment.ProjectJob{Enabled: true}
+		if err := c.ShouldBindJSON(&job); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		if err := job.Validate(); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := orchestrator.UpdateProjectJob(c.Request.Context(), c.Param("projectId"), c.Param("jobId"), &job); err != nil {
+			respondJobError(c, err, "Failed to update job")
+			return
+		}
+		c.JSON(200, gin.H{"job": job})
+	})
+
+	jobs.DELETE("/:jobId", func(c *gin.Context) {
+		if err := orchestrator.DeleteProjectJob(c.Request.Context(), c.Param("projectId"), c.Param("jobId")); err != nil {
+			respondJobError(c, err, "Failed to delete job")
+			return
+		}
+		c.JSON(200, gin.H{"success": true})
+	})
+
+	jobs.POST("/:jobId/runs", func(c *gin.Context) {
+		run, err := orchestrator.RunProjectJob(c.Request.Context(), c.Param("projectId"), c.Param("jobId"), deployment.JobTriggerManual, nil)
+		if err != nil {
+			respondJobError(c, err, "Failed to start job run")
+			return
+		}
+		c.JSON(202, gin.H{"run": run})
+	})
+
+	jobs.GET("/:jobId/runs", func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if limit <= 0 || limit > 500 {
+			limit = 50
+		}
+
+		runs, err := orchestrator.ListJobRuns(c.Request.Context(), c.Param("projectId"), c.Param("jobId"), limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch job runs"})
+			return
+		}
+		c.JSON(200, gin.H{"runs": runs})
+	})
+
+	jobs.GET("/:jobId/runs/:runId", func(c *gin.Context) {
+		run, err := orchestrator.GetJobRun(c.Request.Context(), c.Param("projectId"), c.Param("jobId"), c.Param("runId"))
+		if err != nil {
+			respondJobError(c, err, "Failed to fetch job run")
+			return
+		}
+		c.JSON(200, gin.H{"run": run})
+	})
+
+	jobs.POST("/:jobId/runs/:runId/cancel", func(c *gin.Context) {
+		run, err := orchestrator.CancelJobRun(c.Request.Context(), c.Param("projectId"), c.Param("jobId"), c.Param("runId"))
+		if err != nil {
+			respondJobError(c, err, "Failed to cancel job run")
+			return
+		}
+		c.JSON(200, gin.H{"run": run})
+	})
+}
+
+func respondJobError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, deployment.ErrJobNotFound):
+		c.JSON(404, gin.H{"error": "Job not found"})
+	case errors.Is(err, deployment.ErrJobRunNotFound):
+		c.JSON(404, gin.H{"error": "Job run not found"})
+	case errors.Is(err, deployment.ErrJobQuotaExceeded):
+		c.JSON(429, gin.H{"error": err.Error()})
+	case errors.Is(err, deployment.ErrJobDisabled), errors.Is(err, deployment.ErrJobNameTaken):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": fallback})
+	}
+}
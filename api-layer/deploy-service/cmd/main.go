@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	deployment_logger "deploy-service/internal/logger"
 	"deploy-service/internal/security"
@@ -81,7 +82,7 @@ func main() {
 	// CORS middleware for SSE
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if c.Request.Method == "OPTIONS" {
@@ -182,6 +183,21 @@ func main() {
 	}
 	defer w.Close()
 
+	orchestrator := w.Orchestrator()
+	registerDomainRoutes(r, orchestrator)
+	registerMiddlewareRoutes(r, orchestrator)
+	registerPreviewRoutes(r, orchestrator)
+	registerJobRoutes(r, orchestrator)
+	if err := orchestrator.EnsureDomainChallengeRoute(); err != nil {
+		log.Printf("⚠️ Failed to write domain verification route: %v", err)
+	}
+
+	monitorCtx, stopMonitors := context.WithCancel(context.Background())
+	defer stopMonitors()
+	go orchestrator.StartCertificateMonitor(monitorCtx, 10*time.Minute)
+	go w.StartPreviewReaper(monitorCtx, 5*time.Minute)
+	go orchestrator.StartJobScheduler(monitorCtx, 30*time.Second)
+
 	go func() {
 		log.Println("🚀 Starting RabbitMQ deployment worker...")
 		if err := w.Start(); err != nil {
@@ -198,6 +214,8 @@ func main() {
 
 		log.Println("🛑 Shutting down gracefully...")
 		w.Close()
+		// Delivers or spools the platform log events still buffered
+		deployment_logger.Close()
 		db.Close()
 		os.Exit(0)
 	}()
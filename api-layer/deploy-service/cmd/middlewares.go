@@ -0,0 +1,44 @@
+package main
+
+import (
+	"deploy-service/internal/deployment"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerMiddlewareRoutes exposes the per environment Traefik middleware configuration
+func registerMiddlewareRoutes(r *gin.Engine, orchestrator *deployment.DeploymentOrchestrator) {
+	group := r.Group("/api/projects/:projectId/environments/:environment/middlewares")
+
+	group.GET("", func(c *gin.Context) {
+		config, err := orchestrator.GetRouteMiddlewares(c.Request.Context(), c.Param("projectId"), c.Param("environment"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch middleware configuration"})
+			return
+		}
+		c.JSON(200, gin.H{"middlewares": config})
+	})
+
+	group.PUT("", func(c *gin.Context) {
+		var config deployment.RouteMiddlewares
+		if err := c.ShouldBindJSON(&config); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		if err := config.Validate(); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := orchestrator.SaveRouteMiddlewares(c.Request.Context(), c.Param("projectId"), c.Param("environment"), &config); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to save middleware configuration"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"middlewares": config,
+			"message":     "Middleware configuration saved; it applies on the next deployment",
+		})
+	})
+}
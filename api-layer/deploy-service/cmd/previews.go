@@ -0,0 +1,61 @@
+package main
+
+import (
+	"deploy-service/internal/deployment"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerPreviewRoutes exposes pull request preview environments and their settings
+func registerPreviewRoutes(r *gin.Engine, orchestrator *deployment.DeploymentOrchestrator) {
+	group := r.Group("/api/projects/:projectId/previews")
+
+	group.GET("", func(c *gin.Context) {
+		previews, err := orchestrator.ListPreviewEnvironments(c.Request.Context(), c.Param("projectId"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch preview environments"})
+			return
+		}
+		c.JSON(200, gin.H{"previews": previews})
+	})
+
+	group.DELETE("/:subdomain", func(c *gin.Context) {
+		if err := orchestrator.TeardownPreviewEnvironment(c.Request.Context(), c.Param("projectId"), c.Param("subdomain"), deployment.PreviewTerminationManual); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to tear down preview environment"})
+			return
+		}
+		c.JSON(200, gin.H{"success": true})
+	})
+
+	group.GET("/settings", func(c *gin.Context) {
+		settings, err := orchestrator.GetPreviewSettings(c.Request.Context(), c.Param("projectId"))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch preview settings"})
+			return
+		}
+		c.JSON(200, gin.H{"settings": settings})
+	})
+
+	group.PUT("/settings", func(c *gin.Context) {
+		var settings deployment.PreviewSettings
+		if err := c.ShouldBindJSON(&settings); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		if err := settings.Validate(); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := orchestrator.SavePreviewSettings(c.Request.Context(), c.Param("projectId"), &settings); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to save preview settings"})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"settings": settings,
+			"message":  "Preview settings saved; they apply on the next preview deployment",
+		})
+	})
+}
@@ -0,0 +1,714 @@
+package deployment
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"deploy-service/pkg"
+
+	"github.com/lib/pq"
+)
+
+const (
+	DomainVerificationDNS  = "dns_txt"
+	DomainVerificationHTTP = "http"
+
+	DomainStatusPending  = "pending"
+	DomainStatusVerified = "verified"
+	DomainStatusFailed   = "failed"
+
+	CertificateStatusNone     = "none"
+	CertificateStatusPending  = "pending"
+	CertificateStatusIssued   = "issued"
+	CertificateStatusExpiring = "expiring"
+	CertificateStatusFailed   = "failed"
+
+	// DNS TXT records are looked up at <prefix>.<domain>
+	domainChallengeRecordPrefix = "_obtura-challenge"
+	// HTTP tokens are served by deploy-service under this path on the domain
+	DomainChallengePathPrefix = "/.well-known/obtura-challenge/"
+
+	certificateExpiryWarning = 14 * 24 * time.Hour
+	certificateCheckTimeout  = 10 * time.Second
+)
+
+var (
+	ErrDomainNotFound      = errors.New("domain not found")
+	ErrDomainUnavailable   = errors.New("domain unavailable")
+	ErrDomainQuotaExceeded = errors.New("custom domain quota exceeded")
+
+	domainNamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+)
+
+// ProjectDomain is a custom domain attached to a project environment
+type ProjectDomain struct {
+	ID                 string     `json:"id"`
+	ProjectID          string     `json:"project_id"`
+	Environment        string     `json:"environment"`
+	Domain             string     `json:"domain"`
+	IsWildcard         bool       `json:"is_wildcard"`
+	VerificationMethod string     `json:"verification_method"`
+	VerificationToken  string     `json:"verification_token"`
+	VerificationStatus string     `json:"verification_status"`
+	VerificationError  string     `json:"verification_error,omitempty"`
+	VerifiedAt         *time.Time `json:"verified_at,omitempty"`
+	// Unverified domains are deleted after this, freeing the name for other projects
+	VerificationExpiresAt *time.Time `json:"verification_expires_at,omitempty"`
+	CertificateStatus     string     `json:"certificate_status"`
+	CertificateResolver   string     `json:"certificate_resolver,omitempty"`
+	CertificateIssuer     string     `json:"certificate_issuer,omitempty"`
+	CertificateExpiresAt  *time.Time `json:"certificate_expires_at,omitempty"`
+	CertificateError      string     `json:"certificate_error,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+}
+
+// VerificationInstructions describes what the user has to publish to prove ownership
+func (d *ProjectDomain) VerificationInstructions() map[string]string {
+	if d.VerificationMethod == DomainVerificationHTTP {
+		return map[string]string{
+			"method": DomainVerificationHTTP,
+			"url":    fmt.Sprintf("http://%s%s%s", d.Domain, DomainChallengePathPrefix, d.VerificationToken),
+			"body":   d.VerificationToken,
+			"note":   "Point the domain at the platform; the token is served automatically once traffic reaches it",
+		}
+	}
+	return map[string]string{
+		"method": DomainVerificationDNS,
+		"record": fmt.Sprintf("%s.%s", domainChallengeRecordPrefix, d.Domain),
+		"type":   "TXT",
+		"value":  domainTXTValue(d.VerificationToken),
+	}
+}
+
+// TLSConfig holds the Traefik entrypoints and resolvers used for deployment routers
+type TLSConfig struct {
+	Enabled          bool
+	HTTPEntryPoint   string
+	HTTPSEntryPoint  string
+	CertResolver     string
+	WildcardResolver string
+}
+
+// GetTLSConfig reads the TLS routing settings from the environment
+func GetTLSConfig() TLSConfig {
+	return TLSConfig{
+		Enabled:         pkg.GetEnv("TRAEFIK_TLS_ENABLED", "true") == "true",
+		HTTPEntryPoint:  pkg.GetEnv("TRAEFIK_HTTP_ENTRYPOINT", "web"),
+		HTTPSEntryPoint: pkg.GetEnv("TRAEFIK_HTTPS_ENTRYPOINT", "websecure"),
+		CertResolver:    pkg.GetEnv("TRAEFIK_CERT_RESOLVER", "letsencrypt"),
+		// Wildcard certificates need a DNS-01 resolver; without one wildcard domains are refused
+		WildcardResolver: pkg.GetEnv("TRAEFIK_WILDCARD_CERT_RESOLVER", ""),
+	}
+}
+
+// platformDomains are the base domains the platform serves deployments and previews
+// under; they cannot be claimed as custom domains
+func platformDomains() []string {
+	var domains []string
+	for _, d := range []string{pkg.GetEnv("PLATFORM_DOMAIN", ""), pkg.GetEnv("PREVIEW_BASE_DOMAIN", "s3rbvn.org")} {
+		if name, _, err := NormalizeDomain(d); err == nil {
+			domains = append(domains, name)
+		}
+	}
+	return domains
+}
+
+// domainsOverlap reports whether a is b or a subdomain of it
+func domainsOverlap(a, b string) bool {
+	return a == b || strings.HasSuffix(a, "."+b)
+}
+
+// NormalizeDomain lowercases a domain, strips a trailing dot and a leading "*." and validates it
+func NormalizeDomain(domain string) (string, bool, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	wildcard := false
+	if strings.HasPrefix(domain, "*.") {
+		wildcard = true
+		domain = strings.TrimPrefix(domain, "*.")
+	}
+
+	if len(domain) > 253 || !domainNamePattern.MatchString(domain) {
+		return "", false, fmt.Errorf("invalid domain name: %q", domain)
+	}
+
+	return domain, wildcard, nil
+}
+
+// AddProjectDomain registers a custom domain and returns it with a fresh verification token
+func (o *DeploymentOrchestrator) AddProjectDomain(ctx context.Context, projectID, environment, domain, method string, wildcard bool) (*ProjectDomain, error) {
+	name, isWildcard, err := NormalizeDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	isWildcard = isWildcard || wildcard
+
+	if environment == "" {
+		environment = "production"
+	}
+	if method == "" {
+		method = DomainVerificationDNS
+	}
+	if method != DomainVerificationDNS && method != DomainVerificationHTTP {
+		return nil, fmt.Errorf("unsupported verification method: %s", method)
+	}
+	if isWildcard && method != DomainVerificationDNS {
+		return nil, fmt.Errorf("wildcard domains must be verified with a DNS TXT record")
+	}
+	if tlsConfig := GetTLSConfig(); isWildcard && tlsConfig.Enabled && tlsConfig.WildcardResolver == "" {
+		return nil, fmt.Errorf("wildcard domains are not supported: no DNS certificate resolver is configured")
+	}
+
+	for _, base := range platformDomains() {
+		if domainsOverlap(name, base) || domainsOverlap(base, name) {
+			return nil, fmt.Errorf("%w: %s is reserved by the platform", ErrDomainUnavailable, name)
+		}
+	}
+	if err := o.checkDomainAvailable(ctx, projectID, name, isWildcard); err != nil {
+		return nil, err
+	}
+	if err := o.checkCustomDomainQuota(ctx, projectID); err != nil {
+		return nil, err
+	}
+
+	token, err := generateVerificationToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate verification token: %w", err)
+	}
+
+	d := &ProjectDomain{
+		ProjectID:          projectID,
+		Environment:        environment,
+		Domain:             name,
+		IsWildcard:         isWildcard,
+		VerificationMethod: method,
+		VerificationToken:  token,
+		VerificationStatus: DomainStatusPending,
+		CertificateStatus:  CertificateStatusNone,
+	}
+
+	query := `
+		INSERT INTO project_domains (project_id, environment, domain, is_wildcard, verification_method, verification_token)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	if err := o.db.QueryRowContext(ctx, query, projectID, environment, name, isWildcard, method, token).Scan(&d.ID, &d.CreatedAt); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, fmt.Errorf("%w: %s is already added to this project", ErrDomainUnavailable, name)
+		}
+		return nil, fmt.Errorf("failed to create domain: %w", err)
+	}
+
+	log.Printf("🌐 Registered domain %s for project %s (%s, %s verification)", name, projectID, environment, method)
+	return d, nil
+}
+
+// checkDomainAvailable refuses a domain another project has verified, including one
+// covered by or covering another project's verified wildcard. Unverified claims by other
+// projects do not block it; whoever verifies first gets the domain.
+func (o *DeploymentOrchestrator) checkDomainAvailable(ctx context.Context, projectID, name string, wildcard bool) error {
+	var owner string
+	err := o.db.QueryRowContext(ctx, `
+		SELECT project_id FROM project_domains
+		WHERE project_id <> $1 AND verification_status = 'verified'
+		  AND (domain = $2
+		       OR (is_wildcard AND $2 LIKE '%.' || domain)
+		       OR ($3 AND domain LIKE '%.' || $2))
+		LIMIT 1`, projectID, name, wildcard).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check domain availability: %w", err)
+	}
+	return fmt.Errorf("%w: %s is in use by another project", ErrDomainUnavailable, name)
+}
+
+// checkCustomDomainQuota counts the company's custom domains against the plan limit
+func (o *DeploymentOrchestrator) checkCustomDomainQuota(ctx context.Context, projectID string) error {
+	companyID, err := o.getCompanyIDForProject(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	quota, err := o.quotaService.GetDeploymentQuotaForCompany(ctx, companyID)
+	if err != nil {
+		return fmt.Errorf("failed to get deployment quota: %w", err)
+	}
+
+	var count int
+	err = o.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM project_domains d
+		JOIN projects p ON p.id = d.project_id
+		WHERE p.company_id = $1`, companyID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to count custom domains: %w", err)
+	}
+
+	if ok, reason := quota.IsWithinCustomDomainQuota(count); !ok {
+		return fmt.Errorf("%w: %s", ErrDomainQuotaExceeded, reason)
+	}
+	return nil
+}
+
+// ListProjectDomains returns all custom domains of a project
+func (o *DeploymentOrchestrator) ListProjectDomains(ctx context.Context, projectID string) ([]*ProjectDomain, error) {
+	rows, err := o.db.QueryContext(ctx, projectDomainSelect+` WHERE project_id = $1 ORDER BY created_at ASC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []*ProjectDomain
+	for rows.Next() {
+		d, err := scanProjectDomain(rows)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
+
+// GetProjectDomain loads a single domain of a project
+func (o *DeploymentOrchestrator) GetProjectDomain(ctx context.Context, projectID, domainID string) (*ProjectDomain, error) {
+	row := o.db.QueryRowContext(ctx, projectDomainSelect+` WHERE project_id = $1 AND id = $2`, projectID, domainID)
+	d, err := scanProjectDomain(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrDomainNotFound
+	}
+	return d, err
+}
+
+// RemoveProjectDomain deletes a domain; routes drop it on the next deployment
+func (o *DeploymentOrchestrator) RemoveProjectDomain(ctx context.Context, projectID, domainID string) error {
+	result, err := o.db.ExecContext(ctx, `DELETE FROM project_domains WHERE project_id = $1 AND id = $2`, projectID, domainID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrDomainNotFound
+	}
+	return nil
+}
+
+// LookupChallengeToken reports whether an unexpired HTTP verification token is pending
+// for the host the challenge request was made to. Binding it to the host means a token
+// only proves ownership when the claimed domain itself routes to the platform.
+func (o *DeploymentOrchestrator) LookupChallengeToken(ctx context.Context, host, token string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	var exists bool
+	err := o.db.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM project_domains
+			WHERE verification_token = $1 AND verification_method = 'http' AND domain = $2
+			  AND verification_status <> 'verified' AND verification_expires_at > NOW()
+		)`, token, host).Scan(&exists)
+	return err == nil && exists
+}
+
+// VerifyProjectDomain checks the DNS TXT record or HTTP token and stores the result
+func (o *DeploymentOrchestrator) VerifyProjectDomain(ctx context.Context, projectID, domainID string) (*ProjectDomain, error) {
+	d, err := o.GetProjectDomain(ctx, projectID, domainID)
+	if err != nil {
+		return nil, err
+	}
+	if d.VerificationStatus != DomainStatusVerified {
+		if d.VerificationExpiresAt != nil && time.Now().After(*d.VerificationExpiresAt) {
+			return nil, fmt.Errorf("%w: verification of %s expired, add the domain again", ErrDomainUnavailable, d.Domain)
+		}
+		if err := o.checkDomainAvailable(ctx, projectID, d.Domain, d.IsWildcard); err != nil {
+			return nil, err
+		}
+	}
+
+	var verifyErr error
+	switch d.VerificationMethod {
+	case DomainVerificationHTTP:
+		verifyErr = verifyHTTPToken(ctx, d.Domain, d.VerificationToken)
+	default:
+		verifyErr = verifyDNSRecord(ctx, d.Domain, d.VerificationToken)
+	}
+
+	if verifyErr != nil {
+		d.VerificationError = verifyErr.Error()
+		// A previously verified domain only loses its status through removal
+		if d.VerificationStatus != DomainStatusVerified {
+			d.VerificationStatus = DomainStatusFailed
+		}
+		_, err = o.db.ExecContext(ctx, `
+			UPDATE project_domains
+			SET verification_status = $1, verification_error = $2,
+				verification_attempts = verification_attempts + 1,
+				last_verification_at = NOW(), updated_at = NOW()
+			WHERE id = $3`, d.VerificationStatus, d.VerificationError, d.ID)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("⚠️ Verification failed for domain %s: %v", d.Domain, verifyErr)
+		return d, nil
+	}
+
+	now := time.Now()
+	d.VerificationStatus = DomainStatusVerified
+	d.VerificationError = ""
+	d.VerifiedAt = &now
+	if d.CertificateStatus == CertificateStatusNone || d.CertificateStatus == CertificateStatusFailed {
+		d.CertificateStatus = CertificateStatusPending
+	}
+
+	_, err = o.db.ExecContext(ctx, `
+		UPDATE project_domains
+		SET verification_status = 'verified', verification_error = NULL, verification_expires_at = NULL,
+			verification_attempts = verification_attempts + 1,
+			last_verification_at = NOW(), verified_at = COALESCE(verified_at, NOW()),
+			certificate_status = $1, updated_at = NOW()
+		WHERE id = $2`, d.CertificateStatus, d.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, fmt.Errorf("%w: %s was verified by another project", ErrDomainUnavailable, d.Domain)
+		}
+		return nil, err
+	}
+	d.VerificationExpiresAt = nil
+
+	log.Printf("✅ Domain %s verified for project %s", d.Domain, d.ProjectID)
+	return d, nil
+}
+
+// getRoutableDomains returns the verified custom domains for a project environment
+func (o *DeploymentOrchestrator) getRoutableDomains(ctx context.Context, projectID, environment string) ([]*ProjectDomain, error) {
+	rows, err := o.db.QueryContext(ctx, projectDomainSelect+`
+		WHERE project_id = $1 AND environment = $2 AND verification_status = 'verified'
+		ORDER BY domain ASC`, projectID, environment)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []*ProjectDomain
+	for rows.Next() {
+		d, err := scanProjectDomain(rows)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
+
+// markCertificatesRequested flags domains whose routers now ask Traefik for a certificate
+func (o *DeploymentOrchestrator) markCertificatesRequested(ctx context.Context, domains []*ProjectDomain, tlsConfig TLSConfig) {
+	for _, d := range domains {
+		resolver := tlsConfig.CertResolver
+		if d.IsWildcard {
+			resolver = tlsConfig.WildcardResolver
+		}
+		_, err := o.db.ExecContext(ctx, `
+			UPDATE project_domains
+			SET certificate_resolver = $1,
+				certificate_status = CASE WHEN certificate_status IN ('none', 'failed') THEN 'pending' ELSE certificate_status END,
+				updated_at = NOW()
+			WHERE id = $2`, resolver, d.ID)
+		if err != nil {
+			log.Printf("[warn] failed to update certificate status for %s: %v", d.Domain, err)
+		}
+	}
+}
+
+// RefreshCertificateStatuses inspects the certificate Traefik serves for each verified domain
+func (o *DeploymentOrchestrator) RefreshCertificateStatuses(ctx context.Context) error {
+	rows, err := o.db.QueryContext(ctx, projectDomainSelect+`
+		WHERE verification_status = 'verified'
+		  AND certificate_status <> 'none'
+		ORDER BY certificate_checked_at ASC NULLS FIRST
+		LIMIT 100`)
+	if err != nil {
+		return err
+	}
+
+	var domains []*ProjectDomain
+	for rows.Next() {
+		d, err := scanProjectDomain(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		domains = append(domains, d)
+	}
+	rows.Close()
+
+	roots := certificateRootPool()
+	for _, d := range domains {
+		o.refreshCertificateStatus(ctx, d, roots)
+	}
+	return nil
+}
+
+// DeleteExpiredDomainClaims removes domains that were never verified before their claim expired
+func (o *DeploymentOrchestrator) DeleteExpiredDomainClaims(ctx context.Context) error {
+	result, err := o.db.ExecContext(ctx, `
+		DELETE FROM project_domains
+		WHERE verification_status <> 'verified' AND verification_expires_at < NOW()`)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("🧹 Deleted %d expired domain claims", n)
+	}
+	return nil
+}
+
+// StartCertificateMonitor periodically refreshes certificate statuses and deletes
+// expired domain claims until ctx is cancelled
+func (o *DeploymentOrchestrator) StartCertificateMonitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := o.RefreshCertificateStatuses(ctx); err != nil {
+				log.Printf("[warn] certificate status refresh failed: %v", err)
+			}
+			if err := o.DeleteExpiredDomainClaims(ctx); err != nil {
+				log.Printf("[warn] expired domain cleanup failed: %v", err)
+			}
+		}
+	}
+}
+
+func (o *DeploymentOrchestrator) refreshCertificateStatus(ctx context.Context, d *ProjectDomain, roots *x509.CertPool) {
+	host := d.Domain
+	if d.IsWildcard {
+		// Any name under the wildcard presents the same certificate
+		host = "obtura-probe." + d.Domain
+	}
+
+	cert, err := fetchServedCertificate(ctx, host, roots)
+	if err != nil {
+		// Until the ACME order completes Traefik serves its default certificate
+		status := CertificateStatusPending
+		if d.CertificateStatus == CertificateStatusIssued || d.CertificateStatus == CertificateStatusExpiring {
+			status = CertificateStatusFailed
+		}
+		o.db.ExecContext(ctx, `
+			UPDATE project_domains
+			SET certificate_status = $1, certificate_error = $2,
+				certificate_checked_at = NOW(), updated_at = NOW()
+			WHERE id = $3`, status, err.Error(), d.ID)
+		return
+	}
+
+	status := CertificateStatusIssued
+	if time.Until(cert.NotAfter) < certificateExpiryWarning {
+		status = CertificateStatusExpiring
+	}
+
+	_, err = o.db.ExecContext(ctx, `
+		UPDATE project_domains
+		SET certificate_status = $1, certificate_issuer = $2,
+			certificate_not_before = $3, certificate_expires_at = $4,
+			certificate_error = NULL, certificate_checked_at = NOW(), updated_at = NOW()
+		WHERE id = $5`, status, cert.Issuer.CommonName, cert.NotBefore, cert.NotAfter, d.ID)
+	if err != nil {
+		log.Printf("[warn] failed to store certificate status for %s: %v", d.Domain, err)
+		return
+	}
+
+	if status != d.CertificateStatus {
+		log.Printf("🔒 Certificate for %s is now %s (expires %s)", d.Domain, status, cert.NotAfter.Format(time.RFC3339))
+	}
+}
+
+// fetchServedCertificate performs a verified TLS handshake and returns the leaf certificate
+func fetchServedCertificate(ctx context.Context, host string, roots *x509.CertPool) (*x509.Certificate, error) {
+	addr := pkg.GetEnv("TRAEFIK_TLS_ADDRESS", "")
+	if addr == "" {
+		addr = net.JoinHostPort(host, "443")
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: certificateCheckTimeout},
+		Config: &tls.Config{
+			ServerName: host,
+			RootCAs:    roots,
+		},
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, certificateCheckTimeout)
+	defer cancel()
+
+	conn, err := dialer.DialContext(dialCtx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate presented for %s", host)
+	}
+	return certs[0], nil
+}
+
+// certificateRootPool returns the system roots plus any extra ACME CA roots (e.g. Pebble)
+func certificateRootPool() *x509.CertPool {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if path := os.Getenv("ACME_CA_ROOTS"); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[warn] failed to read ACME_CA_ROOTS %s: %v", path, err)
+		} else if !pool.AppendCertsFromPEM(pem) {
+			log.Printf("[warn] no certificates found in ACME_CA_ROOTS %s", path)
+		}
+	}
+
+	return pool
+}
+
+func verifyDNSRecord(ctx context.Context, domain, token string) error {
+	record := fmt.Sprintf("%s.%s", domainChallengeRecordPrefix, domain)
+	expected := domainTXTValue(token)
+
+	resolver := net.DefaultResolver
+	if server := os.Getenv("DOMAIN_VERIFICATION_DNS_SERVER"); server != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{Timeout: 5 * time.Second}
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	values, err := resolver.LookupTXT(lookupCtx, record)
+	if err != nil {
+		return fmt.Errorf("TXT lookup for %s failed: %w", record, err)
+	}
+
+	for _, v := range values {
+		if strings.TrimSpace(v) == expected {
+			return nil
+		}
+	}
+	return fmt.Errorf("TXT record %s does not contain %q", record, expected)
+}
+
+func verifyHTTPToken(ctx context.Context, domain, token string) error {
+	url := fmt.Sprintf("http://%s%s%s", domain, DomainChallengePathPrefix, token)
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			// Ownership is proven by DNS pointing at us; the domain has no
+			// certificate yet if the web entrypoint redirects to HTTPS
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(body)) != token {
+		return fmt.Errorf("%s returned an unexpected token", url)
+	}
+	return nil
+}
+
+func domainTXTValue(token string) string {
+	return "obtura-verification=" + token
+}
+
+func generateVerificationToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+const projectDomainSelect = `
+	SELECT id, project_id, environment, domain, is_wildcard,
+		verification_method, verification_token, verification_status,
+		COALESCE(verification_error, ''), verified_at, verification_expires_at,
+		certificate_status, COALESCE(certificate_resolver, ''),
+		COALESCE(certificate_issuer, ''), certificate_expires_at,
+		COALESCE(certificate_error, ''), created_at
+	FROM project_domains`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProjectDomain(row rowScanner) (*ProjectDomain, error) {
+	var d ProjectDomain
+	var verifiedAt, verificationExpiresAt, expiresAt sql.NullTime
+
+	err := row.Scan(
+		&d.ID, &d.ProjectID, &d.Environment, &d.Domain, &d.IsWildcard,
+		&d.VerificationMethod, &d.VerificationToken, &d.VerificationStatus,
+		&d.VerificationError, &verifiedAt, &verificationExpiresAt,
+		&d.CertificateStatus, &d.CertificateResolver,
+		&d.CertificateIssuer, &expiresAt,
+		&d.CertificateError, &d.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if verifiedAt.Valid {
+		d.VerifiedAt = &verifiedAt.Time
+	}
+	if verificationExpiresAt.Valid {
+		d.VerificationExpiresAt = &verificationExpiresAt.Time
+	}
+	if expiresAt.Valid {
+		d.CertificateExpiresAt = &expiresAt.Time
+	}
+	return &d, nil
+}
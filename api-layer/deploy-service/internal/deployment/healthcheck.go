@@ -0,0 +1,132 @@
+package deployment
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/AALXX/Obtura/shared/healthcheck"
+)
+
+// HealthCheckSpec is the single health check definition for a service. It drives the
+// Docker HEALTHCHECK, the Traefik load balancer check, the deploy-time wait and the
+// monitoring HealthChecker (via the health_check_* columns on deployments).
+type HealthCheckSpec = healthcheck.Spec
+
+// ResolveHealthCheckSpec picks the health check for a deployment. Priority:
+// job config, project settings, repo config / framework detection from the build, default "/".
+func (o *DeploymentOrchestrator) ResolveHealthCheckSpec(ctx context.Context, job DeploymentJob) HealthCheckSpec {
+	var spec HealthCheckSpec
+
+	if job.Config != nil {
+		if raw, ok := job.Config["health_check"]; ok {
+			if b, err := json.Marshal(raw); err == nil && json.Unmarshal(b, &spec) == nil && spec.Path != "" {
+				spec.Source = "deployment_config"
+			}
+		}
+	}
+
+	if spec.Path == "" {
+		var settingsPath sql.NullString
+		err := o.db.QueryRowContext(ctx, `
+			SELECT health_check_url FROM project_settings
+			WHERE project_id = $1 AND perform_health_checks = true`, job.ProjectID).Scan(&settingsPath)
+		if err == nil && settingsPath.Valid && strings.TrimSpace(settingsPath.String) != "" {
+			spec.Path = settingsPath.String
+			spec.Source = "project_settings"
+		}
+	}
+
+	if buildSpec, ok := o.healthCheckFromBuildMetadata(ctx, job.BuildID); ok {
+		if spec.Path == "" {
+			spec = buildSpec
+		} else {
+			// Keep the user's path but take thresholds from the repo config
+			spec.ExpectedStatus = firstNonZero(spec.ExpectedStatus, buildSpec.ExpectedStatus)
+			spec.IntervalSeconds = firstNonZero(spec.IntervalSeconds, buildSpec.IntervalSeconds)
+			spec.TimeoutSeconds = firstNonZero(spec.TimeoutSeconds, buildSpec.TimeoutSeconds)
+			spec.StartPeriodSeconds = firstNonZero(spec.StartPeriodSeconds, buildSpec.StartPeriodSeconds)
+			spec.Retries = firstNonZero(spec.Retries, buildSpec.Retries)
+		}
+	}
+
+	if spec.Source == "" {
+		spec.Source = "default"
+	}
+	spec.Normalize()
+
+	log.Printf("[health] using %s (status %s, every %ds, timeout %ds, start %ds, retries %d) from %s",
+		spec.Path, expectedStatusLabel(spec.ExpectedStatus), spec.IntervalSeconds, spec.TimeoutSeconds,
+		spec.StartPeriodSeconds, spec.Retries, spec.Source)
+
+	return spec
+}
+
+// healthCheckFromBuildMetadata reads the spec the build-service stored per framework
+func (o *DeploymentOrchestrator) healthCheckFromBuildMetadata(ctx context.Context, buildID string) (HealthCheckSpec, bool) {
+	var metadataJSON sql.NullString
+	if err := o.db.QueryRowContext(ctx, `SELECT metadata FROM builds WHERE id = $1`, buildID).Scan(&metadataJSON); err != nil || !metadataJSON.Valid {
+		return HealthCheckSpec{}, false
+	}
+
+	var metadata struct {
+		Frameworks []struct {
+			HealthCheck     string           `json:"healthCheck"`
+			HealthCheckSpec *HealthCheckSpec `json:"healthCheckSpec"`
+		} `json:"frameworks"`
+	}
+	if err := json.Unmarshal([]byte(metadataJSON.String), &metadata); err != nil {
+		return HealthCheckSpec{}, false
+	}
+
+	for _, fw := range metadata.Frameworks {
+		if fw.HealthCheckSpec != nil && fw.HealthCheckSpec.Path != "" {
+			spec := *fw.HealthCheckSpec
+			if spec.Source == "" {
+				spec.Source = "build_metadata"
+			}
+			return spec, true
+		}
+		if fw.HealthCheck != "" {
+			return HealthCheckSpec{Path: fw.HealthCheck, Source: "framework_detection"}, true
+		}
+	}
+	return HealthCheckSpec{}, false
+}
+
+// storeHealthCheckSpec persists the resolved spec so the monitoring service checks the same endpoint
+func (o *DeploymentOrchestrator) storeHealthCheckSpec(ctx context.Context, deploymentID string, spec HealthCheckSpec) {
+	_, err := o.db.ExecContext(ctx, `
+		UPDATE deployments
+		SET health_check_path = $1,
+			health_check_expected_status = $2,
+			health_check_interval_seconds = $3,
+			health_check_timeout_seconds = $4,
+			health_check_start_period_seconds = $5,
+			health_check_retries = $6
+		WHERE id = $7`,
+		spec.Path, spec.ExpectedStatus, spec.IntervalSeconds, spec.TimeoutSeconds,
+		spec.StartPeriodSeconds, spec.Retries, deploymentID)
+	if err != nil {
+		log.Printf("[warn] failed to store health check spec for deployment %s: %v", deploymentID, err)
+	}
+}
+
+func expectedStatusLabel(status int) string {
+	if status == 0 {
+		return "2xx/3xx"
+	}
+	return fmt.Sprintf("%d", status)
+}
+
+func firstNonZero(values ...int) int {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
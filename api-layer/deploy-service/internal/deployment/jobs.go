@@ -0,0 +1,969 @@
+package deployment
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"deploy-service/internal/security"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+const (
+	JobTypeJob  = "job"
+	JobTypeCron = "cron"
+
+	JobConcurrencyAllow   = "allow"
+	JobConcurrencyForbid  = "forbid"
+	JobConcurrencyReplace = "replace"
+
+	JobTriggerManual   = "manual"
+	JobTriggerSchedule = "schedule"
+
+	JobRunStatusPending   = "pending"
+	JobRunStatusRunning   = "running"
+	JobRunStatusSucceeded = "succeeded"
+	JobRunStatusFailed    = "failed"
+	JobRunStatusTimedOut  = "timed_out"
+	JobRunStatusCancelled = "cancelled"
+	JobRunStatusSkipped   = "skipped"
+
+	defaultJobTimeout = 30 * time.Minute
+	maxJobTimeout     = 24 * time.Hour
+	maxJobRunLogBytes = 256 * 1024
+)
+
+var (
+	ErrJobNotFound      = errors.New("job not found")
+	ErrJobRunNotFound   = errors.New("job run not found")
+	ErrJobQuotaExceeded = errors.New("job quota exceeded")
+	ErrJobDisabled      = errors.New("job is disabled")
+	ErrJobNameTaken     = errors.New("a job with this name already exists in the environment")
+
+	jobNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+)
+
+// ProjectJob is a one-off or scheduled command run from an environment's deployed image
+type ProjectJob struct {
+	ID                string     `json:"id"`
+	ProjectID         string     `json:"projectId"`
+	Environment       string     `json:"environment"`
+	Name              string     `json:"name"`
+	Type              string     `json:"type"`
+	Command           []string   `json:"command"`
+	Schedule          string     `json:"schedule,omitempty"`
+	Timezone          string     `json:"timezone,omitempty"`
+	ConcurrencyPolicy string     `json:"concurrencyPolicy"`
+	TimeoutSeconds    int        `json:"timeoutSeconds"`
+	Enabled           bool       `json:"enabled"`
+	NextRunAt         *time.Time `json:"nextRunAt,omitempty"`
+	LastRunAt         *time.Time `json:"lastRunAt,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	UpdatedAt         time.Time  `json:"updatedAt"`
+}
+
+// JobRun is one execution of a ProjectJob
+type JobRun struct {
+	ID            string     `json:"id"`
+	JobID         string     `json:"jobId"`
+	ProjectID     string     `json:"projectId"`
+	DeploymentID  string     `json:"deploymentId,omitempty"`
+	Trigger       string     `json:"trigger"`
+	Status        string     `json:"status"`
+	Image         string     `json:"image,omitempty"`
+	Command       []string   `json:"command,omitempty"`
+	ExitCode      *int       `json:"exitCode,omitempty"`
+	ErrorMessage  string     `json:"errorMessage,omitempty"`
+	Logs          string     `json:"logs,omitempty"`
+	LogsTruncated bool       `json:"logsTruncated,omitempty"`
+	ScheduledFor  *time.Time `json:"scheduledFor,omitempty"`
+	StartedAt     *time.Time `json:"startedAt,omitempty"`
+	FinishedAt    *time.Time `json:"finishedAt,omitempty"`
+	DurationMs    *int64     `json:"durationMs,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+
+	containerID string
+}
+
+// Validate fills in defaults and checks the job definition
+func (j *ProjectJob) Validate() error {
+	if !jobNamePattern.MatchString(j.Name) {
+		return fmt.Errorf("name must be lowercase letters, digits and dashes (max 63 characters)")
+	}
+
+	if j.Environment == "" {
+		j.Environment = "production"
+	}
+	if j.Environment != "production" && j.Environment != "staging" {
+		return fmt.Errorf("environment must be production or staging")
+	}
+
+	if j.Type == "" {
+		j.Type = JobTypeJob
+	}
+	switch j.Type {
+	case JobTypeJob:
+		j.Schedule, j.Timezone = "", ""
+	case JobTypeCron:
+		if j.Timezone == "" {
+			j.Timezone = "UTC"
+		}
+		schedule, err := ParseCronSchedule(j.Schedule, j.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid schedule: %w", err)
+		}
+		if schedule.Next(time.Now()).IsZero() {
+			return fmt.Errorf("invalid schedule: %q never runs", j.Schedule)
+		}
+	default:
+		return fmt.Errorf("type must be job or cron")
+	}
+
+	if j.ConcurrencyPolicy == "" {
+		j.ConcurrencyPolicy = JobConcurrencyForbid
+	}
+	switch j.ConcurrencyPolicy {
+	case JobConcurrencyAllow, JobConcurrencyForbid, JobConcurrencyReplace:
+	default:
+		return fmt.Errorf("concurrencyPolicy must be allow, forbid or replace")
+	}
+
+	if j.TimeoutSeconds == 0 {
+		j.TimeoutSeconds = int(defaultJobTimeout.Seconds())
+	}
+	if j.TimeoutSeconds < 1 || j.TimeoutSeconds > int(maxJobTimeout.Seconds()) {
+		return fmt.Errorf("timeoutSeconds must be between 1 and %d", int(maxJobTimeout.Seconds()))
+	}
+
+	if len(j.Command) > 64 {
+		return fmt.Errorf("command may have at most 64 arguments")
+	}
+	for _, arg := range j.Command {
+		if arg == "" {
+			return fmt.Errorf("command arguments must not be empty")
+		}
+	}
+	if j.Command == nil {
+		j.Command = []string{}
+	}
+	return nil
+}
+
+// nextRun returns the next activation of a cron job after t, or nil for one-off and
+// disabled jobs
+func (j *ProjectJob) nextRun(t time.Time) *time.Time {
+	if j.Type != JobTypeCron || !j.Enabled {
+		return nil
+	}
+	schedule, err := ParseCronSchedule(j.Schedule, j.Timezone)
+	if err != nil {
+		return nil
+	}
+	next := schedule.Next(t)
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+func (j *ProjectJob) Timeout() time.Duration {
+	return time.Duration(j.TimeoutSeconds) * time.Second
+}
+
+const projectJobColumns = `id, project_id, environment, name, job_type, command, COALESCE(schedule, ''),
+	COALESCE(timezone, ''), concurrency_policy, timeout_seconds, enabled, next_run_at, last_run_at,
+	created_at, updated_at`
+
+func scanProjectJob(scan func(dest ...interface{}) error) (*ProjectJob, error) {
+	var j ProjectJob
+	var command []byte
+	var nextRunAt, lastRunAt sql.NullTime
+	err := scan(&j.ID, &j.ProjectID, &j.Environment, &j.Name, &j.Type, &command, &j.Schedule,
+		&j.Timezone, &j.ConcurrencyPolicy, &j.TimeoutSeconds, &j.Enabled, &nextRunAt, &lastRunAt,
+		&j.CreatedAt, &j.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(command, &j.Command); err != nil {
+		return nil, fmt.Errorf("invalid command for job %s: %w", j.ID, err)
+	}
+	if nextRunAt.Valid {
+		j.NextRunAt = &nextRunAt.Time
+	}
+	if lastRunAt.Valid {
+		j.LastRunAt = &lastRunAt.Time
+	}
+	return &j, nil
+}
+
+func (o *DeploymentOrchestrator) ListProjectJobs(ctx context.Context, projectID string) ([]*ProjectJob, error) {
+	rows, err := o.db.QueryContext(ctx, `
+		SELECT `+projectJobColumns+` FROM project_jobs
+		WHERE project_id = $1
+		ORDER BY environment, name`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*ProjectJob{}
+	for rows.Next() {
+		j, err := scanProjectJob(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+func (o *DeploymentOrchestrator) GetProjectJob(ctx context.Context, projectID, jobID string) (*ProjectJob, error) {
+	j, err := scanProjectJob(o.db.QueryRowContext(ctx, `
+		SELECT `+projectJobColumns+` FROM project_jobs
+		WHERE id = $1 AND project_id = $2`, jobID, projectID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrJobNotFound
+	}
+	return j, err
+}
+
+func (o *DeploymentOrchestrator) CreateProjectJob(ctx context.Context, projectID string, job *ProjectJob) error {
+	job.ProjectID = projectID
+	if err := job.Validate(); err != nil {
+		return err
+	}
+	if err := o.checkScheduledJobQuota(ctx, job); err != nil {
+		return err
+	}
+
+	command, err := json.Marshal(job.Command)
+	if err != nil {
+		return err
+	}
+	job.NextRunAt = job.nextRun(time.Now())
+
+	err = o.db.QueryRowContext(ctx, `
+		INSERT INTO project_jobs
+		(project_id, environment, name, job_type, command, schedule, timezone,
+		 concurrency_policy, timeout_seconds, enabled, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10, $11)
+		RETURNING id, created_at, updated_at`,
+		projectID, job.Environment, job.Name, job.Type, string(command), job.Schedule, job.Timezone,
+		job.ConcurrencyPolicy, job.TimeoutSeconds, job.Enabled, job.NextRunAt,
+	).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil && strings.Contains(err.Error(), "duplicate key") {
+		return ErrJobNameTaken
+	}
+	return err
+}
+
+// UpdateProjectJob replaces the job definition. The schedule is recomputed from now.
+func (o *DeploymentOrchestrator) UpdateProjectJob(ctx context.Context, projectID, jobID string, job *ProjectJob) error {
+	if _, err := o.GetProjectJob(ctx, projectID, jobID); err != nil {
+		return err
+	}
+
+	job.ID, job.ProjectID = jobID, projectID
+	if err := job.Validate(); err != nil {
+		return err
+	}
+	if err := o.checkScheduledJobQuota(ctx, job); err != nil {
+		return err
+	}
+
+	command, err := json.Marshal(job.Command)
+	if err != nil {
+		return err
+	}
+	job.NextRunAt = job.nextRun(time.Now())
+
+	err = o.db.QueryRowContext(ctx, `
+		UPDATE project_jobs
+		SET environment = $1, name = $2, job_type = $3, command = $4, schedule = NULLIF($5, ''),
+		    timezone = NULLIF($6, ''), concurrency_policy = $7, timeout_seconds = $8, enabled = $9,
+		    next_run_at = $10, updated_at = NOW()
+		WHERE id = $11 AND project_id = $12
+		RETURNING created_at, updated_at`,
+		job.Environment, job.Name, job.Type, string(command), job.Schedule, job.Timezone,
+		job.ConcurrencyPolicy, job.TimeoutSeconds, job.Enabled, job.NextRunAt, jobID, projectID,
+	).Scan(&job.CreatedAt, &job.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrJobNotFound
+	}
+	if err != nil && strings.Contains(err.Error(), "duplicate key") {
+		return ErrJobNameTaken
+	}
+	return err
+}
+
+// DeleteProjectJob stops any active runs and removes the job with its history
+func (o *DeploymentOrchestrator) DeleteProjectJob(ctx context.Context, projectID, jobID string) error {
+	if _, err := o.GetProjectJob(ctx, projectID, jobID); err != nil {
+		return err
+	}
+
+	o.cancelActiveJobRuns(ctx, jobID, "job deleted")
+
+	_, err := o.db.ExecContext(ctx, `DELETE FROM project_jobs WHERE id = $1 AND project_id = $2`, jobID, projectID)
+	return err
+}
+
+// checkScheduledJobQuota counts enabled cron jobs in the project against the plan limit
+func (o *DeploymentOrchestrator) checkScheduledJobQuota(ctx context.Context, job *ProjectJob) error {
+	if job.Type != JobTypeCron || !job.Enabled {
+		return nil
+	}
+
+	quota, err := o.quotaService.GetDeploymentQuotaForProject(ctx, job.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to get deployment quota: %w", err)
+	}
+
+	var count int
+	err = o.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM project_jobs
+		WHERE project_id = $1 AND job_type = 'cron' AND enabled = true AND ($2 = '' OR id::text <> $2)`,
+		job.ProjectID, job.ID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to count scheduled jobs: %w", err)
+	}
+
+	if ok, reason := quota.IsWithinScheduledJobQuota(security.JobUsage{CurrentScheduledJobs: count}); !ok {
+		return fmt.Errorf("%w: %s", ErrJobQuotaExceeded, reason)
+	}
+	return nil
+}
+
+// RunProjectJob starts a run of the job in a sandboxed container created from the image
+// of the environment's active deployment. The concurrency policy is applied first: with
+// "forbid" a run that overlaps an active one is recorded as skipped, with "replace" the
+// active runs are cancelled. The job row stays locked from that check until the new run
+// is recorded, so concurrent manual and scheduled runs are claimed one at a time. The
+// run is watched in the background until it exits or times out.
+func (o *DeploymentOrchestrator) RunProjectJob(ctx context.Context, projectID, jobID, trigger string, scheduledFor *time.Time) (*JobRun, error) {
+	job, err := o.GetProjectJob(ctx, projectID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if !job.Enabled {
+		return nil, ErrJobDisabled
+	}
+
+	run := &JobRun{
+		JobID:        job.ID,
+		ProjectID:    job.ProjectID,
+		Trigger:      trigger,
+		Command:      job.Command,
+		ScheduledFor: scheduledFor,
+	}
+
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// NO KEY UPDATE serializes claims without blocking the foreign key checks of
+	// skipped runs recorded outside the transaction
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM project_jobs WHERE id = $1 FOR NO KEY UPDATE`, job.ID); err != nil {
+		return nil, fmt.Errorf("failed to lock job: %w", err)
+	}
+
+	active, err := countActiveJobRuns(ctx, tx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if active > 0 {
+		switch job.ConcurrencyPolicy {
+		case JobConcurrencyForbid:
+			log.Printf("[jobs] skipping %s/%s: previous run still active", job.ProjectID, job.Name)
+			tx.Rollback()
+			return run, o.insertFinishedJobRun(ctx, run, JobRunStatusSkipped, "previous run is still active")
+		case JobConcurrencyReplace:
+			log.Printf("[jobs] replacing %d active run(s) of %s/%s", active, job.ProjectID, job.Name)
+			o.cancelActiveJobRuns(ctx, job.ID, "replaced by a newer run")
+		}
+	}
+
+	if err := o.checkJobRunQuota(ctx, job.ProjectID); err != nil {
+		if trigger == JobTriggerSchedule {
+			return run, o.insertFinishedJobRun(ctx, run, JobRunStatusSkipped, err.Error())
+		}
+		return nil, err
+	}
+
+	source, err := o.getJobSourceContainer(ctx, job)
+	if err != nil {
+		return run, o.insertFinishedJobRun(ctx, run, JobRunStatusFailed, err.Error())
+	}
+	run.DeploymentID = source.deploymentID
+	run.Image = source.image
+
+	command, _ := json.Marshal(run.Command)
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO project_job_runs
+		(job_id, project_id, deployment_id, trigger, status, image, command, scheduled_for)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`,
+		run.JobID, run.ProjectID, run.DeploymentID, run.Trigger, JobRunStatusPending, run.Image,
+		string(command), run.ScheduledFor,
+	).Scan(&run.ID, &run.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record job run: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE project_jobs SET last_run_at = NOW() WHERE id = $1`, job.ID); err != nil {
+		return nil, fmt.Errorf("failed to update last_run_at: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to record job run: %w", err)
+	}
+	run.Status = JobRunStatusPending
+
+	if err := o.startJobContainer(ctx, job, run, source); err != nil {
+		log.Printf("[jobs] run %s of %s/%s failed to start: %v", run.ID, job.ProjectID, job.Name, err)
+		o.finishJobRun(context.Background(), run.ID, JobRunStatusFailed, nil, err.Error(), "", false)
+		run.Status = JobRunStatusFailed
+		run.ErrorMessage = err.Error()
+		return run, nil
+	}
+
+	go o.watchJobRun(run.ID, run.containerID, job.Timeout())
+
+	log.Printf("[jobs] started run %s of %s/%s (%s)", run.ID, job.ProjectID, job.Name, trigger)
+	return run, nil
+}
+
+// checkJobRunQuota counts the company's active job containers against the plan limit
+func (o *DeploymentOrchestrator) checkJobRunQuota(ctx context.Context, projectID string) error {
+	companyID, err := o.getCompanyIDForProject(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	quota, err := o.quotaService.GetDeploymentQuotaForCompany(ctx, companyID)
+	if err != nil {
+		return fmt.Errorf("failed to get deployment quota: %w", err)
+	}
+
+	var count int
+	err = o.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM project_job_runs r
+		JOIN projects p ON p.id = r.project_id
+		WHERE p.company_id = $1 AND r.status IN ('pending', 'running')`, companyID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to count active job runs: %w", err)
+	}
+
+	if ok, reason := quota.IsWithinJobRunQuota(security.JobUsage{CurrentConcurrentJobRuns: count}); !ok {
+		return fmt.Errorf("%w: %s", ErrJobQuotaExceeded, reason)
+	}
+	return nil
+}
+
+type jobSource struct {
+	deploymentID string
+	containerID  string
+	image        string
+	env          []string
+	workingDir   string
+}
+
+// getJobSourceContainer finds the active container of the job's environment. Its image
+// and environment are what the job runs with, so jobs always see the deployed release.
+func (o *DeploymentOrchestrator) getJobSourceContainer(ctx context.Context, job *ProjectJob) (*jobSource, error) {
+	source := &jobSource{}
+	err := o.db.QueryRowContext(ctx, `
+		SELECT d.id, dc.container_id
+		FROM deployment_containers dc
+		JOIN deployments d ON d.id = dc.deployment_id
+		WHERE d.project_id = $1 AND d.environment = $2 AND d.status = $3
+		  AND dc.is_active = true AND dc.status IN ('running', 'healthy')
+		ORDER BY dc.started_at DESC
+		LIMIT 1`, job.ProjectID, job.Environment, DeploymentStatusActive,
+	).Scan(&source.deploymentID, &source.containerID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no active %s deployment to run the job from", job.Environment)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inspect, err := o.dockerClient.ContainerInspect(ctx, source.containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect deployment container: %w", err)
+	}
+	source.image = inspect.Config.Image
+	source.env = inspect.Config.Env
+	source.workingDir = inspect.Config.WorkingDir
+	return source, nil
+}
+
+func (o *DeploymentOrchestrator) startJobContainer(ctx context.Context, job *ProjectJob, run *JobRun, source *jobSource) error {
+	planTier, err := o.getProjectPlanTier(ctx, job.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to get project plan tier: %w", err)
+	}
+	sandboxConfig := security.GetDefaultDeploymentConfig(planTier, job.Environment)
+
+	containerName := fmt.Sprintf("%s-job-%s-%s", job.ProjectID, job.Name, run.ID[:8])
+
+	containerConfig := &container.Config{
+		Image:      source.image,
+		Env:        source.env,
+		WorkingDir: source.workingDir,
+		Labels: map[string]string{
+			"obtura.service":       "job",
+			"obtura.deployment_id": source.deploymentID,
+			"obtura.project_id":    job.ProjectID,
+			"obtura.environment":   job.Environment,
+			"obtura.job_id":        job.ID,
+			"obtura.job_run_id":    run.ID,
+			"obtura.sandbox":       "enabled",
+			"obtura.created_at":    time.Now().UTC().Format(time.RFC3339),
+		},
+		Healthcheck: &container.HealthConfig{Test: []string{"NONE"}},
+	}
+	if len(job.Command) > 0 {
+		containerConfig.Cmd = job.Command
+	}
+
+	hostConfig := sandboxHostConfig(sandboxConfig)
+	hostConfig.Mounts = o.buildSecureMounts(DeploymentJob{ProjectID: job.ProjectID, Environment: job.Environment}, sandboxConfig)
+	hostConfig.RestartPolicy = container.RestartPolicy{Name: "no"}
+
+	networkConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			"obtura_dev": {},
+		},
+	}
+
+	resp, err := o.dockerClient.ContainerCreate(ctx, containerConfig, hostConfig, networkConfig, nil, containerName)
+	if err != nil {
+		return fmt.Errorf("failed to create job container: %w", err)
+	}
+	run.containerID = resp.ID
+
+	_, err = o.db.ExecContext(ctx, `
+		UPDATE project_job_runs SET container_id = $1, container_name = $2 WHERE id = $3`,
+		resp.ID, containerName, run.ID)
+	if err != nil {
+		log.Printf("[warn] failed to store container for job run %s: %v", run.ID, err)
+	}
+
+	if err := o.dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		o.removeJobContainer(resp.ID)
+		return fmt.Errorf("failed to start job container: %w", err)
+	}
+
+	now := time.Now()
+	run.Status = JobRunStatusRunning
+	run.StartedAt = &now
+	_, err = o.db.ExecContext(ctx, `
+		UPDATE project_job_runs SET status = $1, started_at = $2
+		WHERE id = $3 AND status = $4`,
+		JobRunStatusRunning, now, run.ID, JobRunStatusPending)
+	return err
+}
+
+// watchJobRun waits for the job container to exit, enforcing the timeout, then stores
+// the exit code and log tail and removes the container
+func (o *DeploymentOrchestrator) watchJobRun(runID, containerID string, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	status := JobRunStatusFailed
+	var exitCode *int
+	errorMessage := ""
+
+	statusCh, errCh := o.dockerClient.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	select {
+	case result := <-statusCh:
+		code := int(result.StatusCode)
+		exitCode = &code
+		if code == 0 {
+			status = JobRunStatusSucceeded
+		} else {
+			errorMessage = fmt.Sprintf("exited with code %d", code)
+		}
+	case err := <-errCh:
+		if ctx.Err() == context.DeadlineExceeded {
+			status = JobRunStatusTimedOut
+			errorMessage = fmt.Sprintf("timed out after %s", timeout)
+			stopTimeout := 10
+			if err := o.dockerClient.ContainerStop(context.Background(), containerID, container.StopOptions{Timeout: &stopTimeout}); err != nil {
+				log.Printf("[warn] failed to stop timed out job container %s: %v", containerID[:12], err)
+			}
+		} else {
+			errorMessage = fmt.Sprintf("failed waiting for container: %v", err)
+		}
+	}
+
+	logs, truncated, err := o.jobContainerLogs(context.Background(), containerID)
+	if err != nil {
+		log.Printf("[warn] failed to collect logs for job run %s: %v", runID, err)
+	}
+
+	o.finishJobRun(context.Background(), runID, status, exitCode, errorMessage, logs, truncated)
+	o.removeJobContainer(containerID)
+
+	log.Printf("[jobs] run %s finished: %s", runID, status)
+}
+
+// finishJobRun records the outcome of a run. A run that was already cancelled keeps its
+// status but still gets its logs.
+func (o *DeploymentOrchestrator) finishJobRun(ctx context.Context, runID, status string, exitCode *int, errorMessage, logs string, truncated bool) {
+	_, err := o.db.ExecContext(ctx, `
+		UPDATE project_job_runs
+		SET status = CASE WHEN status IN ('pending', 'running') THEN $1 ELSE status END,
+		    exit_code = $2,
+		    error_message = COALESCE(error_message, NULLIF($3, '')),
+		    logs = NULLIF($4, ''),
+		    logs_truncated = $5,
+		    finished_at = COALESCE(finished_at, NOW()),
+		    duration_ms = (EXTRACT(EPOCH FROM (COALESCE(finished_at, NOW()) - COALESCE(started_at, created_at))) * 1000)::BIGINT
+		WHERE id = $6`,
+		status, exitCode, errorMessage, logs, truncated, runID)
+	if err != nil {
+		log.Printf("[warn] failed to finish job run %s: %v", runID, err)
+	}
+}
+
+// insertFinishedJobRun records a run that never got a container (skipped or failed early)
+func (o *DeploymentOrchestrator) insertFinishedJobRun(ctx context.Context, run *JobRun, status, message string) error {
+	command, _ := json.Marshal(run.Command)
+	run.Status = status
+	run.ErrorMessage = message
+	return o.db.QueryRowContext(ctx, `
+		INSERT INTO project_job_runs
+		(job_id, project_id, trigger, status, command, error_message, scheduled_for, finished_at, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), 0)
+		RETURNING id, created_at`,
+		run.JobID, run.ProjectID, run.Trigger, status, string(command), message, run.ScheduledFor,
+	).Scan(&run.ID, &run.CreatedAt)
+}
+
+func countActiveJobRuns(ctx context.Context, tx *sql.Tx, jobID string) (int, error) {
+	var count int
+	err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM project_job_runs
+		WHERE job_id = $1 AND status IN ('pending', 'running')`, jobID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count active job runs: %w", err)
+	}
+	return count, nil
+}
+
+// cancelActiveJobRuns marks the job's active runs cancelled and stops their containers.
+// The watcher of each run then stores the logs and removes the container.
+func (o *DeploymentOrchestrator) cancelActiveJobRuns(ctx context.Context, jobID, reason string) {
+	rows, err := o.db.QueryContext(ctx, `
+		UPDATE project_job_runs
+		SET status = $1, error_message = $2, finished_at = NOW()
+		WHERE job_id = $3 AND status IN ('pending', 'running')
+		RETURNING COALESCE(container_id, '')`,
+		JobRunStatusCancelled, reason, jobID)
+	if err != nil {
+		log.Printf("[warn] failed to cancel runs of job %s: %v", jobID, err)
+		return
+	}
+
+	var containerIDs []string
+	for rows.Next() {
+		var containerID string
+		if err := rows.Scan(&containerID); err == nil && containerID != "" {
+			containerIDs = append(containerIDs, containerID)
+		}
+	}
+	rows.Close()
+
+	for _, containerID := range containerIDs {
+		o.stopJobContainer(ctx, containerID)
+	}
+}
+
+// CancelJobRun stops an active run
+func (o *DeploymentOrchestrator) CancelJobRun(ctx context.Context, projectID, jobID, runID string) (*JobRun, error) {
+	var containerID string
+	err := o.db.QueryRowContext(ctx, `
+		UPDATE project_job_runs
+		SET status = $1, error_message = 'cancelled by user', finished_at = NOW()
+		WHERE id = $2 AND job_id = $3 AND project_id = $4 AND status IN ('pending', 'running')
+		RETURNING COALESCE(container_id, '')`,
+		JobRunStatusCancelled, runID, jobID, projectID).Scan(&containerID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if containerID != "" {
+		o.stopJobContainer(ctx, containerID)
+	}
+
+	return o.GetJobRun(ctx, projectID, jobID, runID)
+}
+
+func (o *DeploymentOrchestrator) stopJobContainer(ctx context.Context, containerID string) {
+	stopTimeout := 10
+	err := o.dockerClient.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &stopTimeout})
+	if err != nil && !errdefs.IsNotFound(err) {
+		log.Printf("[warn] failed to stop job container %s: %v", containerID[:12], err)
+	}
+}
+
+func (o *DeploymentOrchestrator) removeJobContainer(containerID string) {
+	err := o.dockerClient.ContainerRemove(context.Background(), containerID, container.RemoveOptions{Force: true})
+	if err != nil && !errdefs.IsNotFound(err) {
+		log.Printf("[warn] failed to remove job container %s: %v", containerID[:12], err)
+	}
+}
+
+// jobContainerLogs returns the tail of the container's output, capped at maxJobRunLogBytes
+func (o *DeploymentOrchestrator) jobContainerLogs(ctx context.Context, containerID string) (string, bool, error) {
+	reader, err := o.dockerClient.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	defer reader.Close()
+
+	buf := &tailBuffer{max: maxJobRunLogBytes}
+	if _, err := stdcopy.StdCopy(buf, buf, reader); err != nil {
+		return "", false, err
+	}
+	return buf.String(), buf.truncated, nil
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	data      []byte
+	max       int
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if over := len(b.data) - b.max; over > 0 {
+		b.data = append(b.data[:0], b.data[over:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	s := string(b.data)
+	if b.truncated {
+		// Drop the partial first line
+		if idx := strings.IndexByte(s, '\n'); idx >= 0 {
+			s = s[idx+1:]
+		}
+	}
+	return s
+}
+
+const jobRunColumns = `id, job_id, project_id, COALESCE(deployment_id::text, ''), trigger, status,
+	COALESCE(image, ''), command, exit_code, COALESCE(error_message, ''), COALESCE(logs, ''),
+	COALESCE(logs_truncated, false), scheduled_for, started_at, finished_at, duration_ms, created_at,
+	COALESCE(container_id, '')`
+
+func scanJobRun(scan func(dest ...interface{}) error) (*JobRun, error) {
+	var r JobRun
+	var command []byte
+	var exitCode sql.NullInt64
+	var durationMs sql.NullInt64
+	var scheduledFor, startedAt, finishedAt sql.NullTime
+	err := scan(&r.ID, &r.JobID, &r.ProjectID, &r.DeploymentID, &r.Trigger, &r.Status,
+		&r.Image, &command, &exitCode, &r.ErrorMessage, &r.Logs,
+		&r.LogsTruncated, &scheduledFor, &startedAt, &finishedAt, &durationMs, &r.CreatedAt,
+		&r.containerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(command) > 0 {
+		_ = json.Unmarshal(command, &r.Command)
+	}
+	if exitCode.Valid {
+		code := int(exitCode.Int64)
+		r.ExitCode = &code
+	}
+	if durationMs.Valid {
+		r.DurationMs = &durationMs.Int64
+	}
+	if scheduledFor.Valid {
+		r.ScheduledFor = &scheduledFor.Time
+	}
+	if startedAt.Valid {
+		r.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		r.FinishedAt = &finishedAt.Time
+	}
+	return &r, nil
+}
+
+// ListJobRuns returns the job's most recent runs without their logs
+func (o *DeploymentOrchestrator) ListJobRuns(ctx context.Context, projectID, jobID string, limit int) ([]*JobRun, error) {
+	rows, err := o.db.QueryContext(ctx, `
+		SELECT `+jobRunColumns+` FROM project_job_runs
+		WHERE job_id = $1 AND project_id = $2
+		ORDER BY created_at DESC
+		LIMIT $3`, jobID, projectID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []*JobRun{}
+	for rows.Next() {
+		r, err := scanJobRun(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		r.Logs = ""
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// GetJobRun returns a run with its logs, read live from the container while it runs
+func (o *DeploymentOrchestrator) GetJobRun(ctx context.Context, projectID, jobID, runID string) (*JobRun, error) {
+	run, err := scanJobRun(o.db.QueryRowContext(ctx, `
+		SELECT `+jobRunColumns+` FROM project_job_runs
+		WHERE id = $1 AND job_id = $2 AND project_id = $3`, runID, jobID, projectID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrJobRunNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if run.Status == JobRunStatusRunning && run.containerID != "" {
+		if logs, truncated, err := o.jobContainerLogs(ctx, run.containerID); err == nil {
+			run.Logs, run.LogsTruncated = logs, truncated
+		}
+	}
+	return run, nil
+}
+
+// StartJobScheduler runs due cron jobs until ctx is cancelled. Each due job is claimed by
+// moving next_run_at forward with a conditional update, so several deploy-service
+// replicas never start the same activation twice. Missed activations are not replayed;
+// a job that was due while the service was down runs once.
+func (o *DeploymentOrchestrator) StartJobScheduler(ctx context.Context, interval time.Duration) {
+	o.reconcileJobRuns(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		o.runDueJobs(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (o *DeploymentOrchestrator) runDueJobs(ctx context.Context) {
+	rows, err := o.db.QueryContext(ctx, `
+		SELECT `+projectJobColumns+` FROM project_jobs
+		WHERE job_type = 'cron' AND enabled = true AND next_run_at <= NOW()
+		ORDER BY next_run_at`)
+	if err != nil {
+		log.Printf("[jobs] failed to query due jobs: %v", err)
+		return
+	}
+
+	var due []*ProjectJob
+	for rows.Next() {
+		j, err := scanProjectJob(rows.Scan)
+		if err != nil {
+			log.Printf("[jobs] failed to scan due job: %v", err)
+			continue
+		}
+		due = append(due, j)
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, job := range due {
+		scheduledFor := *job.NextRunAt
+		next := job.nextRun(now)
+
+		res, err := o.db.ExecContext(ctx, `
+			UPDATE project_jobs SET next_run_at = $1
+			WHERE id = $2 AND next_run_at = $3`, next, job.ID, scheduledFor)
+		if err != nil {
+			log.Printf("[jobs] failed to claim %s: %v", job.ID, err)
+			continue
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue // Claimed by another replica
+		}
+
+		if _, err := o.RunProjectJob(ctx, job.ProjectID, job.ID, JobTriggerSchedule, &scheduledFor); err != nil {
+			log.Printf("[jobs] scheduled run of %s/%s failed: %v", job.ProjectID, job.Name, err)
+		}
+	}
+}
+
+// reconcileJobRuns resumes watching runs that were active when the service stopped and
+// fails the ones whose container is gone
+func (o *DeploymentOrchestrator) reconcileJobRuns(ctx context.Context) {
+	rows, err := o.db.QueryContext(ctx, `
+		SELECT r.id, COALESCE(r.container_id, ''), COALESCE(r.started_at, r.created_at), j.timeout_seconds
+		FROM project_job_runs r
+		JOIN project_jobs j ON j.id = r.job_id
+		WHERE r.status IN ('pending', 'running')`)
+	if err != nil {
+		log.Printf("[jobs] failed to load active runs: %v", err)
+		return
+	}
+
+	type activeRun struct {
+		id, containerID string
+		startedAt       time.Time
+		timeout         time.Duration
+	}
+	var active []activeRun
+	for rows.Next() {
+		var r activeRun
+		var timeoutSeconds int
+		if err := rows.Scan(&r.id, &r.containerID, &r.startedAt, &timeoutSeconds); err != nil {
+			continue
+		}
+		r.timeout = time.Duration(timeoutSeconds) * time.Second
+		active = append(active, r)
+	}
+	rows.Close()
+
+	for _, r := range active {
+		if r.containerID == "" {
+			o.finishJobRun(ctx, r.id, JobRunStatusFailed, nil, "deploy-service restarted before the container was created", "", false)
+			continue
+		}
+		if _, err := o.dockerClient.ContainerInspect(ctx, r.containerID); err != nil {
+			o.finishJobRun(ctx, r.id, JobRunStatusFailed, nil, "job container no longer exists", "", false)
+			continue
+		}
+
+		remaining := r.timeout - time.Since(r.startedAt)
+		if remaining < time.Second {
+			remaining = time.Second
+		}
+		log.Printf("[jobs] resuming watch of run %s", r.id)
+		go o.watchJobRun(r.id, r.containerID, remaining)
+	}
+}
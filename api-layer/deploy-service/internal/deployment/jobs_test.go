@@ -0,0 +1,24 @@
+package deployment
+
+import "testing"
+
+func TestProjectJobValidateSchedule(t *testing.T) {
+	for _, tc := range []struct {
+		schedule string
+		valid    bool
+	}{
+		{"*/5 * * * *", true},
+		{"0 0 29 2 *", true}, // Leap days only
+		{"0 0 31 2 *", false},
+		{"0 0 30 2 *", false},
+		{"0 0 31 4,6,9,11 *", false},
+		{"not a schedule", false},
+	} {
+		t.Run(tc.schedule, func(t *testing.T) {
+			job := ProjectJob{Name: "cleanup", Type: JobTypeCron, Schedule: tc.schedule}
+			if err := job.Validate(); (err == nil) != tc.valid {
+				t.Fatalf("Validate() = %v, want valid %v", err, tc.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package deployment
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	headerNamePattern     = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+	htpasswdUserPattern   = regexp.MustCompile(`^[^:\s]+:(\$2[aby]\$\d{2}\$.{53}|\$apr1\$[./0-9A-Za-z]{1,8}\$[./0-9A-Za-z]{22}|\{SHA\}[A-Za-z0-9+/]{27}=)$`)
+	allowedCORSMethods    = map[string]bool{"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true}
+	maxRateLimitAverage   = int64(100000)
+	maxBasicAuthUsers     = 50
+	maxIPAllowListEntries = 200
+)
+
+// RouteMiddlewares is the per project environment middleware configuration rendered into the Traefik router
+type RouteMiddlewares struct {
+	RateLimit     *RateLimitMiddleware     `json:"rateLimit,omitempty"`
+	BasicAuth     *BasicAuthMiddleware     `json:"basicAuth,omitempty"`
+	IPAllowList   *IPAllowListMiddleware   `json:"ipAllowList,omitempty"`
+	Headers       *HeadersMiddleware       `json:"headers,omitempty"`
+	Compress      bool                     `json:"compress,omitempty"`
+	RedirectRegex *RedirectRegexMiddleware `json:"redirectRegex,omitempty"`
+}
+
+type RateLimitMiddleware struct {
+	Average       int64 `json:"average"`
+	Burst         int64 `json:"burst,omitempty"`
+	PeriodSeconds int   `json:"periodSeconds,omitempty"`
+}
+
+// BasicAuthMiddleware takes htpasswd entries; plain text passwords are rejected
+type BasicAuthMiddleware struct {
+	Users        []string `json:"users"`
+	Realm        string   `json:"realm,omitempty"`
+	RemoveHeader bool     `json:"removeHeader,omitempty"`
+}
+
+type IPAllowListMiddleware struct {
+	SourceRange []string `json:"sourceRange"`
+	// Depth of X-Forwarded-For to trust when sitting behind another proxy (e.g. the tunnel)
+	IPStrategyDepth int `json:"ipStrategyDepth,omitempty"`
+}
+
+type HeadersMiddleware struct {
+	CustomRequestHeaders  map[string]string `json:"customRequestHeaders,omitempty"`
+	CustomResponseHeaders map[string]string `json:"customResponseHeaders,omitempty"`
+
+	// Security headers
+	STSSeconds            int64  `json:"stsSeconds,omitempty"`
+	STSIncludeSubdomains  bool   `json:"stsIncludeSubdomains,omitempty"`
+	FrameDeny             bool   `json:"frameDeny,omitempty"`
+	ContentTypeNosniff    bool   `json:"contentTypeNosniff,omitempty"`
+	BrowserXSSFilter      bool   `json:"browserXssFilter,omitempty"`
+	ReferrerPolicy        string `json:"referrerPolicy,omitempty"`
+	ContentSecurityPolicy string `json:"contentSecurityPolicy,omitempty"`
+
+	// CORS
+	AccessControlAllowOriginList  []string `json:"accessControlAllowOriginList,omitempty"`
+	AccessControlAllowMethods     []string `json:"accessControlAllowMethods,omitempty"`
+	AccessControlAllowHeaders     []string `json:"accessControlAllowHeaders,omitempty"`
+	AccessControlExposeHeaders    []string `json:"accessControlExposeHeaders,omitempty"`
+	AccessControlAllowCredentials bool     `json:"accessControlAllowCredentials,omitempty"`
+	AccessControlMaxAge           int64    `json:"accessControlMaxAge,omitempty"`
+}
+
+type RedirectRegexMiddleware struct {
+	Regex       string `json:"regex"`
+	Replacement string `json:"replacement"`
+	Permanent   bool   `json:"permanent,omitempty"`
+}
+
+// IsEmpty reports whether no middleware is configured
+func (m *RouteMiddlewares) IsEmpty() bool {
+	return m == nil || (m.RateLimit == nil && m.BasicAuth == nil && m.IPAllowList == nil &&
+		m.Headers == nil && !m.Compress && m.RedirectRegex == nil)
+}
+
+// Validate rejects configurations Traefik would refuse or that would weaken the route
+func (m *RouteMiddlewares) Validate() error {
+	if m == nil {
+		return nil
+	}
+	var errs []error
+
+	if rl := m.RateLimit; rl != nil {
+		if rl.Average <= 0 || rl.Average > maxRateLimitAverage {
+			errs = append(errs, fmt.Errorf("rateLimit.average must be between 1 and %d", maxRateLimitAverage))
+		}
+		if rl.Burst < 0 {
+			errs = append(errs, fmt.Errorf("rateLimit.burst must not be negative"))
+		}
+		if rl.PeriodSeconds < 0 || rl.PeriodSeconds > 3600 {
+			errs = append(errs, fmt.Errorf("rateLimit.periodSeconds must be between 0 and 3600"))
+		}
+	}
+
+	if ba := m.BasicAuth; ba != nil {
+		if len(ba.Users) == 0 || len(ba.Users) > maxBasicAuthUsers {
+			errs = append(errs, fmt.Errorf("basicAuth.users must contain between 1 and %d entries", maxBasicAuthUsers))
+		}
+		for i, u := range ba.Users {
+			if !htpasswdUserPattern.MatchString(u) {
+				errs = append(errs, fmt.Errorf("basicAuth.users[%d] must be a 'user:hash' entry using bcrypt, apr1 or SHA", i))
+			}
+		}
+		if strings.ContainsAny(ba.Realm, "\"\n") {
+			errs = append(errs, fmt.Errorf("basicAuth.realm contains invalid characters"))
+		}
+	}
+
+	if al := m.IPAllowList; al != nil {
+		if len(al.SourceRange) == 0 || len(al.SourceRange) > maxIPAllowListEntries {
+			errs = append(errs, fmt.Errorf("ipAllowList.sourceRange must contain between 1 and %d entries", maxIPAllowListEntries))
+		}
+		for i, r := range al.SourceRange {
+			if _, _, err := net.ParseCIDR(r); err != nil && net.ParseIP(r) == nil {
+				errs = append(errs, fmt.Errorf("ipAllowList.sourceRange[%d] is not an IP or CIDR: %q", i, r))
+			}
+		}
+		if al.IPStrategyDepth < 0 || al.IPStrategyDepth > 10 {
+			errs = append(errs, fmt.Errorf("ipAllowList.ipStrategyDepth must be between 0 and 10"))
+		}
+	}
+
+	if h := m.Headers; h != nil {
+		errs = append(errs, validateHeaderMap("headers.customRequestHeaders", h.CustomRequestHeaders)...)
+		errs = append(errs, validateHeaderMap("headers.customResponseHeaders", h.CustomResponseHeaders)...)
+		if h.STSSeconds < 0 || h.AccessControlMaxAge < 0 {
+			errs = append(errs, fmt.Errorf("headers durations must not be negative"))
+		}
+		for _, v := range []string{h.ReferrerPolicy, h.ContentSecurityPolicy} {
+			if strings.ContainsAny(v, "\r\n") {
+				errs = append(errs, fmt.Errorf("headers values must not contain line breaks"))
+			}
+		}
+		for i, origin := range h.AccessControlAllowOriginList {
+			if origin == "*" {
+				if h.AccessControlAllowCredentials {
+					errs = append(errs, fmt.Errorf("headers: wildcard origin cannot be combined with allow credentials"))
+				}
+				continue
+			}
+			if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+				errs = append(errs, fmt.Errorf("headers.accessControlAllowOriginList[%d] must be an origin like https://example.com", i))
+			}
+		}
+		for _, method := range h.AccessControlAllowMethods {
+			if !allowedCORSMethods[strings.ToUpper(method)] {
+				errs = append(errs, fmt.Errorf("headers: unsupported CORS method %q", method))
+			}
+		}
+		for _, name := range append(append([]string{}, h.AccessControlAllowHeaders...), h.AccessControlExposeHeaders...) {
+			if name != "*" && !headerNamePattern.MatchString(name) {
+				errs = append(errs, fmt.Errorf("headers: invalid CORS header name %q", name))
+			}
+		}
+	}
+
+	if rr := m.RedirectRegex; rr != nil {
+		if rr.Regex == "" || rr.Replacement == "" {
+			errs = append(errs, fmt.Errorf("redirectRegex requires regex and replacement"))
+		} else if _, err := regexp.Compile(rr.Regex); err != nil {
+			errs = append(errs, fmt.Errorf("redirectRegex.regex is invalid: %w", err))
+		}
+		if strings.ContainsAny(rr.Replacement, "\r\n") {
+			errs = append(errs, fmt.Errorf("redirectRegex.replacement must not contain line breaks"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateHeaderMap(field string, headers map[string]string) []error {
+	var errs []error
+	for name, value := range headers {
+		if !headerNamePattern.MatchString(name) {
+			errs = append(errs, fmt.Errorf("%s: invalid header name %q", field, name))
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			errs = append(errs, fmt.Errorf("%s: value of %q must not contain line breaks", field, name))
+		}
+	}
+	return errs
+}
+
+// GetRouteMiddlewares loads the middleware configuration for a project environment.
+// Projects without an explicit configuration fall back to the rate limiting and
+// compression toggles in project_settings.
+func (o *DeploymentOrchestrator) GetRouteMiddlewares(ctx context.Context, projectID, environment string) (*RouteMiddlewares, error) {
+	var raw []byte
+	err := o.db.QueryRowContext(ctx, `
+		SELECT config FROM project_route_middlewares
+		WHERE project_id = $1 AND environment = $2`, projectID, environment).Scan(&raw)
+	if err == nil {
+		var m RouteMiddlewares
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, fmt.Errorf("failed to parse middleware config: %w", err)
+		}
+		return &m, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var rateEnabled, compress sql.NullBool
+	var maxRequests, window, burst sql.NullInt64
+	err = o.db.QueryRowContext(ctx, `
+		SELECT rate_limiting_enabled, rate_limiting_max_requests, rate_limiting_window_seconds,
+			rate_limiting_burst_limit, compress_assets
+		FROM project_settings WHERE project_id = $1`, projectID).
+		Scan(&rateEnabled, &maxRequests, &window, &burst, &compress)
+	if err == sql.ErrNoRows {
+		return &RouteMiddlewares{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := &RouteMiddlewares{Compress: compress.Bool}
+	if rateEnabled.Bool && maxRequests.Int64 > 0 {
+		// project_settings is not checked when saved; clamp into what Validate accepts
+		m.RateLimit = &RateLimitMiddleware{
+			Average:       clampInt64(maxRequests.Int64, 1, maxRateLimitAverage),
+			Burst:         clampInt64(burst.Int64, 0, math.MaxInt64),
+			PeriodSeconds: int(clampInt64(window.Int64, 0, 3600)),
+		}
+	}
+	if err := m.Validate(); err != nil {
+		log.Printf("[warn] ignoring rate limit settings of project %s: %v", projectID, err)
+		m.RateLimit = nil
+	}
+	return m, nil
+}
+
+func clampInt64(v, lo, hi int64) int64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// SaveRouteMiddlewares validates and stores the middleware configuration; it applies on the next deployment
+func (o *DeploymentOrchestrator) SaveRouteMiddlewares(ctx context.Context, projectID, environment string, m *RouteMiddlewares) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.db.ExecContext(ctx, `
+		INSERT INTO project_route_middlewares (project_id, environment, config)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (project_id, environment)
+		DO UPDATE SET config = EXCLUDED.config, updated_at = NOW()`, projectID, environment, raw)
+	return err
+}
+
+// render writes the middleware definitions and returns their names in chain order
+func (m *RouteMiddlewares) render(b *strings.Builder, prefix string) []string {
+	if m.IsEmpty() {
+		return nil
+	}
+	var names []string
+
+	// Reject unwanted clients before spending rate limit budget or auth work on them
+	if al := m.IPAllowList; al != nil {
+		name := prefix + "-ipallowlist"
+		names = append(names, name)
+		fmt.Fprintf(b, "    %s:\n      ipAllowList:\n        sourceRange:\n", name)
+		writeYAMLList(b, 10, al.SourceRange)
+		if al.IPStrategyDepth > 0 {
+			fmt.Fprintf(b, "        ipStrategy:\n          depth: %d\n", al.IPStrategyDepth)
+		}
+	}
+
+	if rl := m.RateLimit; rl != nil {
+		name := prefix + "-ratelimit"
+		names = append(names, name)
+		fmt.Fprintf(b, "    %s:\n      rateLimit:\n        average: %d\n", name, rl.Average)
+		if rl.Burst > 0 {
+			fmt.Fprintf(b, "        burst: %d\n", rl.Burst)
+		}
+		if rl.PeriodSeconds > 0 {
+			fmt.Fprintf(b, "        period: %ds\n", rl.PeriodSeconds)
+		}
+	}
+
+	if ba := m.BasicAuth; ba != nil {
+		name := prefix + "-basicauth"
+		names = append(names, name)
+		fmt.Fprintf(b, "    %s:\n      basicAuth:\n        users:\n", name)
+		writeYAMLList(b, 10, ba.Users)
+		if ba.Realm != "" {
+			fmt.Fprintf(b, "        realm: %s\n", strconv.Quote(ba.Realm))
+		}
+		if ba.RemoveHeader {
+			b.WriteString("        removeHeader: true\n")
+		}
+	}
+
+	if rr := m.RedirectRegex; rr != nil {
+		name := prefix + "-redirectregex"
+		names = append(names, name)
+		fmt.Fprintf(b, "    %s:\n      redirectRegex:\n        regex: %s\n        replacement: %s\n        permanent: %t\n",
+			name, strconv.Quote(rr.Regex), strconv.Quote(rr.Replacement), rr.Permanent)
+	}
+
+	if h := m.Headers; h != nil {
+		name := prefix + "-headers"
+		names = append(names, name)
+		fmt.Fprintf(b, "    %s:\n      headers:\n", name)
+		writeYAMLMap(b, 8, "customRequestHeaders", h.CustomRequestHeaders)
+		writeYAMLMap(b, 8, "customResponseHeaders", h.CustomResponseHeaders)
+		if h.STSSeconds > 0 {
+			fmt.Fprintf(b, "        stsSeconds: %d\n        stsIncludeSubdomains: %t\n", h.STSSeconds, h.STSIncludeSubdomains)
+		}
+		if h.FrameDeny {
+			b.WriteString("        frameDeny: true\n")
+		}
+		if h.ContentTypeNosniff {
+			b.WriteString("        contentTypeNosniff: true\n")
+		}
+		if h.BrowserXSSFilter {
+			b.WriteString("        browserXssFilter: true\n")
+		}
+		if h.ReferrerPolicy != "" {
+			fmt.Fprintf(b, "        referrerPolicy: %s\n", strconv.Quote(h.ReferrerPolicy))
+		}
+		if h.ContentSecurityPolicy != "" {
+			fmt.Fprintf(b, "        contentSecurityPolicy: %s\n", strconv.Quote(h.ContentSecurityPolicy))
+		}
+		if len(h.AccessControlAllowOriginList) > 0 {
+			b.WriteString("        accessControlAllowOriginList:\n")
+			writeYAMLList(b, 10, h.AccessControlAllowOriginList)
+			if len(h.AccessControlAllowMethods) > 0 {
+				b.WriteString("        accessControlAllowMethods:\n")
+				writeYAMLList(b, 10, h.AccessControlAllowMethods)
+			}
+			if len(h.AccessControlAllowHeaders) > 0 {
+				b.WriteString("        accessControlAllowHeaders:\n")
+				writeYAMLList(b, 10, h.AccessControlAllowHeaders)
+			}
+			if len(h.AccessControlExposeHeaders) > 0 {
+				b.WriteString("        accessControlExposeHeaders:\n")
+				writeYAMLList(b, 10, h.AccessControlExposeHeaders)
+			}
+			if h.AccessControlAllowCredentials {
+				b.WriteString("        accessControlAllowCredentials: true\n")
+			}
+			if h.AccessControlMaxAge > 0 {
+				fmt.Fprintf(b, "        accessControlMaxAge: %d\n", h.AccessControlMaxAge)
+			}
+			b.WriteString("        addVaryHeader: true\n")
+		}
+	}
+
+	if m.Compress {
+		name := prefix + "-compress"
+		names = append(names, name)
+		fmt.Fprintf(b, "    %s:\n      compress: {}\n", name)
+	}
+
+	return names
+}
+
+func writeYAMLList(b *strings.Builder, indent int, values []string) {
+	pad := strings.Repeat(" ", indent)
+	for _, v := range values {
+		fmt.Fprintf(b, "%s- %s\n", pad, strconv.Quote(v))
+	}
+}
+
+func writeYAMLMap(b *strings.Builder, indent int, key string, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+	pad := strings.Repeat(" ", indent)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(b, "%s%s:\n", pad, key)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s  %s: %s\n", pad, strconv.Quote(k), strconv.Quote(values[k]))
+	}
+}
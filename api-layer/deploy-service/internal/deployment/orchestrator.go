@@ -14,8 +14,8 @@ import (
 	"deploy-service/internal/detection"
 	deployment_logger "deploy-service/internal/logger"
 	"deploy-service/internal/security"
-	"deploy-service/pkg/platformlog"
 
+	"github.com/AALXX/Obtura/shared/platformlog"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/mount"
@@ -62,6 +62,8 @@ type DeploymentJob struct {
 	Domain              string                 `json:"domain"`
 	Subdomain           string                 `json:"subdomain"`
 	Config              map[string]interface{} `json:"config"`
+	EnvVars             map[string]string      `json:"env_vars,omitempty"`
+	HealthCheck         HealthCheckSpec        `json:"health_check"`
 	CreatedAt           time.Time              `json:"created_at"`
 }
 
@@ -132,6 +134,15 @@ func (o *DeploymentOrchestrator) Deploy(ctx context.Context, job DeploymentJob)
 	if job.ReplicaCount == 0 {
 		job.ReplicaCount = 1
 	}
+	if job.Environment == "preview" {
+		job.Subdomain = sanitizePreviewLabel(job.Subdomain)
+		if len(job.Subdomain) > 40 {
+			job.Subdomain = strings.TrimRight(job.Subdomain[:40], "-")
+		}
+		if job.Subdomain == "" {
+			return o.handleFailure(job, "preview_setup", errors.New("preview deployments require a subdomain"))
+		}
+	}
 
 	companyID, err := o.getCompanyIDForProject(ctx, job.ProjectID)
 	if err != nil {
@@ -161,6 +172,16 @@ func (o *DeploymentOrchestrator) Deploy(ctx context.Context, job DeploymentJob)
 
 	o.broker.PublishLog(job.DeploymentID, "success", "✅ Deployment validated successfully")
 
+	if err := o.preparePreviewEnvironment(ctx, &job); err != nil {
+		return o.handleFailure(job, "preview_setup", err)
+	}
+
+	job.HealthCheck = o.ResolveHealthCheckSpec(ctx, job)
+	o.storeHealthCheckSpec(ctx, job.DeploymentID, job.HealthCheck)
+	o.broker.PublishLog(job.DeploymentID, "info",
+		fmt.Sprintf("🩺 Health check: GET %s expecting %s (from %s)",
+			job.HealthCheck.Path, expectedStatusLabel(job.HealthCheck.ExpectedStatus), job.HealthCheck.Source))
+
 	if err := o.initializeStrategyState(ctx, job); err != nil {
 		return o.handleFailure(job, "strategy_initialization", err)
 	}
@@ -196,6 +217,8 @@ func (o *DeploymentOrchestrator) Deploy(ctx context.Context, job DeploymentJob)
 		return deployErr
 	}
 
+	o.markPreviewStatus(job, PreviewStatusActive)
+
 	o.broker.PublishComplete(job.DeploymentID, "active",
 		"🎉 Deployment completed successfully!", "", "")
 
@@ -227,15 +250,17 @@ func (o *DeploymentOrchestrator) checkDeploymentQuotaWithCompany(ctx context.Con
 		return fmt.Errorf("failed to check environment count: %w", err)
 	}
 
-	previewCount, err := o.getCurrentPreviewEnvironmentCount(ctx, job.ProjectID)
-	if err != nil {
-		return fmt.Errorf("failed to check preview environment count: %w", err)
-	}
-
 	usage := security.DeploymentUsage{
 		CurrentEnvironmentsCount:     environmentCount,
-		CurrentPreviewEnvironments:   previewCount,
 		CurrentServicesPerDeployment: 1,
+		IsPreview:                    job.Environment == "preview",
+	}
+
+	if usage.IsPreview {
+		usage.CurrentPreviewEnvironments, err = o.getCurrentPreviewEnvironmentCount(ctx, job.ProjectID, job.previewScope())
+		if err != nil {
+			return fmt.Errorf("failed to check preview environment count: %w", err)
+		}
 	}
 
 	if ok, reason := quota.IsWithinDeploymentQuota(usage); !ok {
@@ -267,11 +292,12 @@ func (o *DeploymentOrchestrator) BlueGreenDeploy(ctx context.Context, job Deploy
 			JOIN deployments d ON d.id = dc.deployment_id
 			WHERE d.project_id = $1 
 			  AND d.environment = $2
+			  AND ($3 = '' OR d.subdomain = $3)
 			  AND dc.status IN ('running', 'healthy', 'starting')
 			ORDER BY dc.created_at DESC
 			LIMIT 1
 		`
-		err := o.db.QueryRowContext(ctx, query, job.ProjectID, job.Environment).Scan(&existingGroup)
+		err := o.db.QueryRowContext(ctx, query, job.ProjectID, job.Environment, job.previewScope()).Scan(&existingGroup)
 		if err == nil && existingGroup.Valid {
 			activeGroup = existingGroup.String
 			log.Printf("[blue-green] found existing deployment group from containers: %s", activeGroup)
@@ -295,7 +321,7 @@ func (o *DeploymentOrchestrator) BlueGreenDeploy(ctx context.Context, job Deploy
 			fmt.Sprintf("🆕 No active deployment found | Deploying to: %s group", newGroup))
 	}
 
-	existingContainers, err := o.getContainersByProjectAndGroup(ctx, job.ProjectID, job.Environment, newGroup)
+	existingContainers, err := o.getContainersByProjectAndGroup(ctx, job, newGroup)
 	if err != nil {
 		log.Printf("[warn] failed to check for existing containers in %s group: %v", newGroup, err)
 	} else if len(existingContainers) > 0 {
@@ -360,7 +386,7 @@ func (o *DeploymentOrchestrator) BlueGreenDeploy(ctx context.Context, job Deploy
 		o.broker.PublishLog(job.DeploymentID, "info",
 			fmt.Sprintf("🔍 Checking health of container %d/%d: %s", i+1, len(newContainers), container.Name))
 
-		if !o.waitForDockerHealthCheck(ctx, container.ID, healthCheckTimeout) {
+		if !o.waitForDockerHealthCheck(ctx, container.ID, job.HealthCheck, healthCheckTimeout) {
 			allHealthy = false
 			o.broker.PublishLog(job.DeploymentID, "error",
 				fmt.Sprintf("❌ Health check failed for container %s", container.Name))
@@ -419,7 +445,7 @@ func (o *DeploymentOrchestrator) BlueGreenDeploy(ctx context.Context, job Deploy
 
 		time.Sleep(gracePeriod)
 
-		oldContainers, err := o.getContainersByProjectAndGroup(ctx, job.ProjectID, job.Environment, activeGroup)
+		oldContainers, err := o.getContainersByProjectAndGroup(ctx, job, activeGroup)
 		if err != nil {
 			log.Printf("[warn] failed to get old containers for cleanup: %v", err)
 		} else {
@@ -449,7 +475,8 @@ func (o *DeploymentOrchestrator) BlueGreenDeploy(ctx context.Context, job Deploy
 	return nil
 }
 
-func (o *DeploymentOrchestrator) getContainersByProjectAndGroup(ctx context.Context, projectID, environment, group string) ([]*ContainerInfo, error) {
+// getContainersByProjectAndGroup returns the running containers of a group in the job's slot
+func (o *DeploymentOrchestrator) getContainersByProjectAndGroup(ctx context.Context, job DeploymentJob, group string) ([]*ContainerInfo, error) {
 	query := `
         SELECT dc.container_id, dc.container_name, dc.status, dc.image, dc.port, dc.health_status,
                dc.deployment_group, dc.is_active, dc.is_primary, dc.replica_index
@@ -458,11 +485,12 @@ func (o *DeploymentOrchestrator) getContainersByProjectAndGroup(ctx context.Cont
         WHERE d.project_id = $1 
           AND d.environment = $2
           AND dc.deployment_group = $3
+          AND ($4 = '' OR d.subdomain = $4)
           AND dc.status IN ('running', 'healthy', 'starting')
         ORDER BY dc.created_at DESC
     `
 
-	rows, err := o.db.QueryContext(ctx, query, projectID, environment, group)
+	rows, err := o.db.QueryContext(ctx, query, job.ProjectID, job.Environment, group, job.previewScope())
 	if err != nil {
 		return nil, err
 	}
@@ -528,7 +556,7 @@ func (o *DeploymentOrchestrator) RollingUpdate(ctx context.Context, job Deployme
 
 		healthy := true
 		for _, container := range batchContainers {
-			if !o.waitForDockerHealthCheck(ctx, container.ID, 60*time.Second) {
+			if !o.waitForDockerHealthCheck(ctx, container.ID, job.HealthCheck, 60*time.Second) {
 				healthy = false
 				break
 			}
@@ -586,7 +614,7 @@ func (o *DeploymentOrchestrator) CanaryDeploy(ctx context.Context, job Deploymen
 	o.updateStrategyPhase(ctx, job.DeploymentID, "health_checking", nil)
 	deployment_logger.DeployStep(ctx, job.DeploymentID, "health_checking", "Health checking canary container")
 
-	healthy := o.waitForDockerHealthCheck(ctx, canaryContainer.ID, 60*time.Second)
+	healthy := o.waitForDockerHealthCheck(ctx, canaryContainer.ID, job.HealthCheck, 60*time.Second)
 	if !healthy {
 		o.RemoveTraefikConfig(canaryContainer.Name)
 		o.RemoveContainerWithDocker(ctx, canaryContainer.ID)
@@ -639,11 +667,14 @@ func (o *DeploymentOrchestrator) CanaryDeploy(ctx context.Context, job Deploymen
 
 func (o *DeploymentOrchestrator) deployContainer(ctx context.Context, job DeploymentJob, group string, replicaIndex int, isActive bool, skipHealthCheck ...bool) (*ContainerInfo, error) {
 	shouldSkipHealthCheck := len(skipHealthCheck) > 0 && skipHealthCheck[0]
+	if job.HealthCheck.Path == "" {
+		job.HealthCheck = o.ResolveHealthCheckSpec(ctx, job)
+	}
 	tempContainerID := fmt.Sprintf("container_%s_%s_%d_%d", job.DeploymentID, group, replicaIndex, time.Now().Unix())
 
 	deployContainer := &ContainerInfo{
 		ID:              tempContainerID,
-		Name:            fmt.Sprintf("%s-%s-%s-%d", job.ProjectID, job.Environment, group, replicaIndex),
+		Name:            fmt.Sprintf("%s-%s-%s-%d", job.ProjectID, job.slot(), group, replicaIndex),
 		Status:          "starting",
 		Image:           job.ImageTag,
 		Port:            0,
@@ -719,7 +750,7 @@ func (o *DeploymentOrchestrator) deployContainer(ctx context.Context, job Deploy
 
 	// Only do health check here if not skipped (caller will do it)
 	if !shouldSkipHealthCheck {
-		healthy := o.waitForDockerHealthCheck(ctx, createResp.ID, healthCheckTimeout)
+		healthy := o.waitForDockerHealthCheck(ctx, createResp.ID, job.HealthCheck, healthCheckTimeout)
 		if !healthy {
 			o.updateContainerStatus(ctx, createResp.ID, "unhealthy", "failed")
 			o.RemoveTraefikConfig(deployContainer.Name)
@@ -742,7 +773,10 @@ func (o *DeploymentOrchestrator) deployContainer(ctx context.Context, job Deploy
 	return deployContainer, nil
 }
 
-func (o *DeploymentOrchestrator) waitForDockerHealthCheck(ctx context.Context, containerID string, timeout time.Duration) bool {
+func (o *DeploymentOrchestrator) waitForDockerHealthCheck(ctx context.Context, containerID string, spec HealthCheckSpec, timeout time.Duration) bool {
+	if minTimeout := spec.UnhealthyGrace() + spec.Interval(); timeout < minTimeout {
+		timeout = minTimeout
+	}
 	deadline := time.Now().Add(timeout)
 	log.Printf("[health] waiting for container %s...", containerID[:12])
 
@@ -779,7 +813,7 @@ func (o *DeploymentOrchestrator) waitForDockerHealthCheck(ctx context.Context, c
 				healthStatus := inspect.State.Health.Status
 				log.Printf("[health] container %s status: %s", containerID[:12], healthStatus)
 
-				o.recordHealthCheck(ctx, containerID, healthStatus == "healthy")
+				o.recordHealthCheck(ctx, containerID, spec.Path, healthStatus == "healthy")
 
 				if healthStatus == "healthy" {
 					log.Printf("[health] container %s is healthy", containerID[:12])
@@ -792,8 +826,9 @@ func (o *DeploymentOrchestrator) waitForDockerHealthCheck(ctx context.Context, c
 				} else {
 					if healthStatus == "unhealthy" {
 						timeSinceStart := time.Since(startedAt)
-						// Reduced grace period to match faster deployments
-						if timeSinceStart > 15*time.Second {
+						// Docker only reports unhealthy after the configured retries, but an
+						// app still booting inside its start period may flap
+						if timeSinceStart > spec.UnhealthyGrace() {
 							log.Printf("[health] container %s is unhealthy after %v",
 								containerID[:12], timeSinceStart)
 							return false
@@ -851,19 +886,6 @@ func (o *DeploymentOrchestrator) createSecureDeploymentContainer(
 	appPort := o.DetectAppPort(ctx, job)
 	log.Printf("[container] port %d internal, mapped to host port %d", appPort, hostPort)
 
-	healthCheckPath := config.HealthCheckURL
-	if healthCheckPath == "" {
-		healthCheckPath = "/health"
-	}
-
-	minPidsLimit := int64(512)
-	adjustedPidsLimit := config.PidsLimit
-	if adjustedPidsLimit < minPidsLimit {
-		log.Printf("[warn] PidsLimit %d is too low for Node.js, increasing to %d",
-			adjustedPidsLimit, minPidsLimit)
-		adjustedPidsLimit = minPidsLimit
-	}
-
 	containerConfig := &container.Config{
 		Image: job.ImageTag,
 		// Note: User is intentionally not set here to allow the image's
@@ -885,21 +907,55 @@ func (o *DeploymentOrchestrator) createSecureDeploymentContainer(
 			"obtura.created_at":       time.Now().UTC().Format(time.RFC3339),
 			"obtura.subdomain":        job.Subdomain,
 		},
+		Env: job.containerEnv(),
 		Healthcheck: &container.HealthConfig{
-			Test: []string{
-				"CMD-SHELL",
-				fmt.Sprintf("wget --no-verbose --tries=1 --spider http://127.0.0.1:%d%s 2>/dev/null || wget --no-verbose --tries=1 --spider http://127.0.0.1:%d/ || exit 1",
-					appPort, healthCheckPath, appPort),
-			},
-			Interval:    10 * time.Second,
-			Timeout:     5 * time.Second,
-			Retries:     3,
-			StartPeriod: 40 * time.Second,
+			Test:        []string{"CMD-SHELL", job.HealthCheck.ShellCommand(appPort)},
+			Interval:    job.HealthCheck.Interval(),
+			Timeout:     job.HealthCheck.Timeout(),
+			Retries:     job.HealthCheck.RetryCount(),
+			StartPeriod: job.HealthCheck.StartPeriod(),
 		},
 		WorkingDir: "/app",
 	}
 
-	hostConfig := &container.HostConfig{
+	hostConfig := sandboxHostConfig(config)
+	hostConfig.PortBindings = nat.PortMap{
+		nat.Port(fmt.Sprintf("%d/tcp", appPort)): []nat.PortBinding{
+			{
+				HostIP:   "0.0.0.0",
+				HostPort: fmt.Sprintf("%d", hostPort),
+			},
+		},
+	}
+	hostConfig.Mounts = o.buildSecureMounts(job, config)
+	hostConfig.RestartPolicy = container.RestartPolicy{Name: "unless-stopped"}
+
+	networkConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			"obtura_dev": {
+				Aliases: []string{
+					deployContainer.Name,
+					fmt.Sprintf("%s-%s", job.ProjectID, job.slot()),
+				},
+			},
+		},
+	}
+
+	return containerConfig, hostConfig, networkConfig
+}
+
+// sandboxHostConfig applies the plan's sandbox limits and hardening. It is shared by
+// web containers and job runs; callers add ports, mounts and the restart policy.
+func sandboxHostConfig(config security.DeploymentSandboxConfig) *container.HostConfig {
+	minPidsLimit := int64(512)
+	adjustedPidsLimit := config.PidsLimit
+	if adjustedPidsLimit < minPidsLimit {
+		log.Printf("[warn] PidsLimit %d is too low for Node.js, increasing to %d",
+			adjustedPidsLimit, minPidsLimit)
+		adjustedPidsLimit = minPidsLimit
+	}
+
+	return &container.HostConfig{
 		Resources: container.Resources{
 			CPUQuota:    config.CPUQuota,
 			CPUPeriod:   100000,
@@ -913,14 +969,6 @@ func (o *DeploymentOrchestrator) createSecureDeploymentContainer(
 				{Name: "core", Soft: 0, Hard: 0},
 			},
 		},
-		PortBindings: nat.PortMap{
-			nat.Port(fmt.Sprintf("%d/tcp", appPort)): []nat.PortBinding{
-				{
-					HostIP:   "0.0.0.0",
-					HostPort: fmt.Sprintf("%d", hostPort),
-				},
-			},
-		},
 		SecurityOpt: []string{
 			"no-new-privileges:true",
 			"seccomp=unconfined",
@@ -945,7 +993,6 @@ func (o *DeploymentOrchestrator) createSecureDeploymentContainer(
 			"/var/run":         "rw,noexec,nosuid,size=100m",
 			"/var/cache/nginx": "rw,exec,size=500m",
 		},
-		Mounts: o.buildSecureMounts(job, config),
 		LogConfig: container.LogConfig{
 			Type: "json-file",
 			Config: map[string]string{
@@ -954,25 +1001,11 @@ func (o *DeploymentOrchestrator) createSecureDeploymentContainer(
 				"compress": "true",
 			},
 		},
-		RestartPolicy: container.RestartPolicy{Name: "unless-stopped"},
-		OomScoreAdj:   500,
-		IpcMode:       "private",
-		UsernsMode:    "host",
-		AutoRemove:    false,
+		OomScoreAdj: 500,
+		IpcMode:     "private",
+		UsernsMode:  "host",
+		AutoRemove:  false,
 	}
-
-	networkConfig := &network.NetworkingConfig{
-		EndpointsConfig: map[string]*network.EndpointSettings{
-			"obtura_dev": {
-				Aliases: []string{
-					deployContainer.Name,
-					fmt.Sprintf("%s-%s", job.ProjectID, job.Environment),
-				},
-			},
-		},
-	}
-
-	return containerConfig, hostConfig, networkConfig
 }
 
 func (o *DeploymentOrchestrator) buildSecureMounts(job DeploymentJob, config security.DeploymentSandboxConfig) []mount.Mount {
@@ -1091,7 +1124,7 @@ func (o *DeploymentOrchestrator) updateContainerGroup(ctx context.Context, conta
 	return err
 }
 
-func (o *DeploymentOrchestrator) recordHealthCheck(ctx context.Context, containerID string, passed bool) {
+func (o *DeploymentOrchestrator) recordHealthCheck(ctx context.Context, containerID, endpoint string, passed bool) {
 	status := "passed"
 	if !passed {
 		status = "failed"
@@ -1105,12 +1138,12 @@ func (o *DeploymentOrchestrator) recordHealthCheck(ctx context.Context, containe
             deployment_id,
             'http',
             $2,
-            '/health',
+            $3,
             100
         FROM deployment_containers
         WHERE container_id = $1
     `
-	o.db.ExecContext(ctx, query, containerID, status)
+	o.db.ExecContext(ctx, query, containerID, status, endpoint)
 
 	if passed {
 		o.db.ExecContext(ctx, `
@@ -1470,15 +1503,18 @@ func (o *DeploymentOrchestrator) getCurrentEnvironmentCount(ctx context.Context,
 	return count, err
 }
 
-func (o *DeploymentOrchestrator) getCurrentPreviewEnvironmentCount(ctx context.Context, projectID string) (int, error) {
+// getCurrentPreviewEnvironmentCount counts live previews. Redeploying a preview replaces it,
+// so its own subdomain is not counted against the quota.
+func (o *DeploymentOrchestrator) getCurrentPreviewEnvironmentCount(ctx context.Context, projectID, excludeSubdomain string) (int, error) {
 	query := `
-        SELECT COUNT(*)
+        SELECT COUNT(DISTINCT COALESCE(subdomain, id::text))
         FROM deployments
         WHERE project_id = $1 AND status = 'active' AND environment = 'preview'
+          AND COALESCE(subdomain, '') <> $2
     `
 
 	var count int
-	err := o.db.QueryRowContext(ctx, query, projectID).Scan(&count)
+	err := o.db.QueryRowContext(ctx, query, projectID, excludeSubdomain).Scan(&count)
 	return count, err
 }
 
@@ -0,0 +1,516 @@
+package deployment
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"deploy-service/pkg"
+)
+
+const (
+	PreviewStatusPending     = "pending"
+	PreviewStatusDeploying   = "deploying"
+	PreviewStatusActive      = "active"
+	PreviewStatusFailed      = "failed"
+	PreviewStatusTerminating = "terminating"
+	PreviewStatusTerminated  = "terminated"
+
+	PreviewTerminationPRClosed   = "pr_closed"
+	PreviewTerminationTTLExpired = "ttl_expired"
+	PreviewTerminationManual     = "manual"
+
+	defaultPreviewTTL = 7 * 24 * time.Hour
+)
+
+var (
+	ErrPreviewsDisabled = errors.New("preview environments are disabled for this project")
+	ErrPreviewClosed    = errors.New("preview environment was closed")
+
+	previewSubdomainInvalid = regexp.MustCompile(`[^a-z0-9-]+`)
+	envKeyPattern           = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// PreviewEnvironment is one pull request (or manually previewed branch) preview
+type PreviewEnvironment struct {
+	ID                string     `json:"id"`
+	ProjectID         string     `json:"projectId"`
+	PullRequestNumber *int       `json:"pullRequestNumber,omitempty"`
+	HeadBranch        string     `json:"headBranch"`
+	Subdomain         string     `json:"subdomain"`
+	Domain            string     `json:"domain,omitempty"`
+	DeploymentID      string     `json:"deploymentId,omitempty"`
+	Status            string     `json:"status"`
+	ExpiresAt         *time.Time `json:"expiresAt,omitempty"`
+}
+
+// PreviewSettings are the per-project preview options
+type PreviewSettings struct {
+	Enabled      bool               `json:"enabled"`
+	TTLHours     int                `json:"ttlHours"`
+	EnvOverrides map[string]*string `json:"envOverrides"` // nil value removes the variable
+}
+
+func (s *PreviewSettings) TTL() time.Duration {
+	if s.TTLHours > 0 {
+		return time.Duration(s.TTLHours) * time.Hour
+	}
+	return defaultPreviewTTL
+}
+
+func (s *PreviewSettings) Validate() error {
+	if s.TTLHours < 0 || s.TTLHours > 24*90 {
+		return fmt.Errorf("ttlHours must be between 1 and %d", 24*90)
+	}
+	for k := range s.EnvOverrides {
+		if !envKeyPattern.MatchString(k) {
+			return fmt.Errorf("invalid environment variable name: %q", k)
+		}
+	}
+	return nil
+}
+
+// PreviewSubdomain returns the subdomain used for a pull request preview
+func PreviewSubdomain(pullRequestNumber int) string {
+	return fmt.Sprintf("pr-%d", pullRequestNumber)
+}
+
+// previewScope is the subdomain that separates one preview from another. Production and
+// staging have a single slot per project, so it is empty for them.
+func (j DeploymentJob) previewScope() string {
+	if j.Environment == "preview" {
+		return j.Subdomain
+	}
+	return ""
+}
+
+// slot names the container set a deployment replaces, e.g. "staging" or "preview-pr-42"
+func (j DeploymentJob) slot() string {
+	if scope := j.previewScope(); scope != "" {
+		return j.Environment + "-" + scope
+	}
+	return j.Environment
+}
+
+// preparePreviewEnvironment assigns the preview its unique domain, clones the staging
+// environment variables with the project overrides and records the preview for teardown.
+// It is a no-op for other environments.
+func (o *DeploymentOrchestrator) preparePreviewEnvironment(ctx context.Context, job *DeploymentJob) error {
+	if job.Environment != "preview" {
+		return nil
+	}
+
+	settings, err := o.GetPreviewSettings(ctx, job.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to load preview settings: %w", err)
+	}
+	if !settings.Enabled {
+		return ErrPreviewsDisabled
+	}
+
+	var status sql.NullString
+	var closedAt sql.NullTime
+	err = o.db.QueryRowContext(ctx, `
+		SELECT status, closed_at FROM preview_environments
+		WHERE project_id = $1 AND subdomain = $2`, job.ProjectID, job.Subdomain).Scan(&status, &closedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to load preview environment: %w", err)
+	}
+	if closedAt.Valid {
+		// A push that raced the PR close must not bring the preview back
+		return ErrPreviewClosed
+	}
+
+	var slug string
+	if err := o.db.QueryRowContext(ctx, `SELECT slug FROM projects WHERE id = $1`, job.ProjectID).Scan(&slug); err != nil {
+		return fmt.Errorf("failed to load project slug: %w", err)
+	}
+	job.Domain = previewDomain(job.Subdomain, slug, job.ProjectID)
+
+	env, err := o.clonePreviewEnv(ctx, job.ProjectID, settings.EnvOverrides)
+	if err != nil {
+		return fmt.Errorf("failed to clone staging environment: %w", err)
+	}
+	projectVars := len(env)
+	preview := map[string]string{
+		"OBTURA_ENVIRONMENT": "preview",
+		"OBTURA_PREVIEW_URL": "https://" + job.Domain,
+	}
+	for k, v := range preview {
+		env[k] = v
+	}
+	job.EnvVars = env
+
+	// Only what the preview adds is recorded: the project variables stay encrypted
+	// in project_env_configs
+	for k, v := range settings.EnvOverrides {
+		if v != nil {
+			preview[k] = *v
+		}
+	}
+	envJSON, err := json.Marshal(preview)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(settings.TTL())
+
+	if _, err := o.db.ExecContext(ctx, `
+		UPDATE deployments
+		SET domain = $1, subdomain = $2, env_vars = $3, is_ephemeral = true,
+			preview_expires_at = $4, updated_at = NOW()
+		WHERE id = $5`,
+		job.Domain, job.Subdomain, string(envJSON), expiresAt, job.DeploymentID); err != nil {
+		return fmt.Errorf("failed to update preview deployment: %w", err)
+	}
+
+	_, err = o.db.ExecContext(ctx, `
+		INSERT INTO preview_environments
+			(project_id, subdomain, domain, head_branch, head_commit, deployment_id, status, expires_at)
+		SELECT d.project_id, $2, $3, d.branch, d.commit_hash, d.id, $4, $5
+		FROM deployments d WHERE d.id = $1
+		ON CONFLICT (project_id, subdomain) DO UPDATE
+		SET domain = EXCLUDED.domain,
+			head_branch = EXCLUDED.head_branch,
+			head_commit = EXCLUDED.head_commit,
+			deployment_id = EXCLUDED.deployment_id,
+			status = EXCLUDED.status,
+			expires_at = EXCLUDED.expires_at,
+			terminated_at = NULL,
+			termination_reason = NULL,
+			updated_at = NOW()`,
+		job.DeploymentID, job.Subdomain, job.Domain, PreviewStatusDeploying, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to record preview environment: %w", err)
+	}
+
+	o.broker.PublishLog(job.DeploymentID, "info",
+		fmt.Sprintf("🔎 Preview %s at https://%s (%d variables from staging, expires %s)",
+			job.Subdomain, job.Domain, projectVars, expiresAt.Format(time.RFC1123)))
+	return nil
+}
+
+// previewDomain builds a host that is unique across projects: slugs are only unique per team
+func previewDomain(subdomain, slug, projectID string) string {
+	shortID := strings.ReplaceAll(projectID, "-", "")
+	if len(shortID) > 6 {
+		shortID = shortID[:6]
+	}
+
+	label := sanitizePreviewLabel(fmt.Sprintf("%s-%s", subdomain, slug))
+	if len(label) > 63-len(shortID)-1 {
+		label = strings.TrimRight(label[:63-len(shortID)-1], "-")
+	}
+
+	return fmt.Sprintf("%s-%s.%s", label, shortID, pkg.GetEnv("PREVIEW_BASE_DOMAIN", "s3rbvn.org"))
+}
+
+func sanitizePreviewLabel(label string) string {
+	label = previewSubdomainInvalid.ReplaceAllString(strings.ToLower(label), "-")
+	return strings.Trim(label, "-")
+}
+
+// clonePreviewEnv loads the project's environment configs, the same ones the build
+// writes as .env files for staging, and applies the preview overrides on top
+func (o *DeploymentOrchestrator) clonePreviewEnv(ctx context.Context, projectID string, overrides map[string]*string) (map[string]string, error) {
+	rows, err := o.db.QueryContext(ctx, `
+		SELECT service_name, env_content FROM project_env_configs
+		WHERE project_id = $1
+		ORDER BY service_name`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var configs []envConfig
+	for rows.Next() {
+		var c envConfig
+		if err := rows.Scan(&c.ServiceName, &c.EncryptedContent); err != nil {
+			return nil, err
+		}
+		configs = append(configs, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buildPreviewEnv(configs, overrides)
+}
+
+// envConfig is one row of project_env_configs: an encrypted .env file for a service
+type envConfig struct {
+	ServiceName      string
+	EncryptedContent string
+}
+
+// buildPreviewEnv merges the decrypted configs, the "shared" one first so service
+// specific values win, then applies the overrides; a nil override removes a variable
+func buildPreviewEnv(configs []envConfig, overrides map[string]*string) (map[string]string, error) {
+	sort.SliceStable(configs, func(i, j int) bool {
+		return configs[i].ServiceName == "shared" && configs[j].ServiceName != "shared"
+	})
+
+	env := make(map[string]string)
+	for _, c := range configs {
+		content, err := pkg.DecryptEnvContent(c.EncryptedContent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt env for service %s: %w", c.ServiceName, err)
+		}
+		for k, v := range parseEnvContent(content) {
+			env[k] = v
+		}
+	}
+
+	for k, v := range overrides {
+		if v == nil {
+			delete(env, k)
+			continue
+		}
+		env[k] = *v
+	}
+
+	return env, nil
+}
+
+// parseEnvContent reads KEY=VALUE lines of a .env file, skipping blank lines and
+// comments and unquoting quoted values
+func parseEnvContent(content string) map[string]string {
+	env := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[key] = value
+	}
+	return env
+}
+
+// GetPreviewSettings returns the project's preview settings, or the defaults
+func (o *DeploymentOrchestrator) GetPreviewSettings(ctx context.Context, projectID string) (*PreviewSettings, error) {
+	settings := &PreviewSettings{Enabled: true, TTLHours: int(defaultPreviewTTL.Hours())}
+
+	var ttlHours sql.NullInt64
+	var overridesJSON sql.NullString
+	err := o.db.QueryRowContext(ctx, `
+		SELECT enabled, ttl_hours, env_overrides FROM project_preview_settings
+		WHERE project_id = $1`, projectID).Scan(&settings.Enabled, &ttlHours, &overridesJSON)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ttlHours.Valid && ttlHours.Int64 > 0 {
+		settings.TTLHours = int(ttlHours.Int64)
+	}
+	if overridesJSON.Valid && overridesJSON.String != "" {
+		if err := json.Unmarshal([]byte(overridesJSON.String), &settings.EnvOverrides); err != nil {
+			return nil, fmt.Errorf("invalid preview env_overrides: %w", err)
+		}
+	}
+	return settings, nil
+}
+
+func (o *DeploymentOrchestrator) SavePreviewSettings(ctx context.Context, projectID string, settings *PreviewSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	if settings.TTLHours == 0 {
+		settings.TTLHours = int(defaultPreviewTTL.Hours())
+	}
+	if settings.EnvOverrides == nil {
+		settings.EnvOverrides = map[string]*string{}
+	}
+
+	overrides, err := json.Marshal(settings.EnvOverrides)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.db.ExecContext(ctx, `
+		INSERT INTO project_preview_settings (project_id, enabled, ttl_hours, env_overrides)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (project_id)
+		DO UPDATE SET enabled = EXCLUDED.enabled, ttl_hours = EXCLUDED.ttl_hours,
+			env_overrides = EXCLUDED.env_overrides, updated_at = NOW()`,
+		projectID, settings.Enabled, settings.TTLHours, string(overrides))
+	return err
+}
+
+// ListPreviewEnvironments returns the project's previews, live ones first
+func (o *DeploymentOrchestrator) ListPreviewEnvironments(ctx context.Context, projectID string) ([]*PreviewEnvironment, error) {
+	return o.queryPreviewEnvironments(ctx, `
+		WHERE project_id = $1
+		ORDER BY (status = 'terminated'), updated_at DESC`, projectID)
+}
+
+// markPreviewStatus updates the preview record after a deploy succeeds or fails
+func (o *DeploymentOrchestrator) markPreviewStatus(job DeploymentJob, status string) {
+	if job.previewScope() == "" {
+		return
+	}
+
+	_, err := o.db.Exec(`
+		UPDATE preview_environments
+		SET status = $1, updated_at = NOW()
+		WHERE project_id = $2 AND subdomain = $3 AND deployment_id = $4
+		  AND status NOT IN ($5, $6)`,
+		status, job.ProjectID, job.Subdomain, job.DeploymentID, PreviewStatusTerminating, PreviewStatusTerminated)
+	if err != nil {
+		log.Printf("[warn] failed to mark preview %s as %s: %v", job.Subdomain, status, err)
+	}
+}
+
+// ExpiredPreviewEnvironments lists previews past their TTL that still have to be torn down
+func (o *DeploymentOrchestrator) ExpiredPreviewEnvironments(ctx context.Context) ([]*PreviewEnvironment, error) {
+	return o.queryPreviewEnvironments(ctx, `
+		WHERE expires_at < NOW() AND status NOT IN ($1, $2)
+		ORDER BY expires_at`, PreviewStatusTerminating, PreviewStatusTerminated)
+}
+
+func (o *DeploymentOrchestrator) queryPreviewEnvironments(ctx context.Context, where string, args ...interface{}) ([]*PreviewEnvironment, error) {
+	rows, err := o.db.QueryContext(ctx, `
+		SELECT id, project_id, pull_request_number, head_branch, subdomain, COALESCE(domain, ''),
+			COALESCE(deployment_id::text, ''), status, expires_at
+		FROM preview_environments `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var previews []*PreviewEnvironment
+	for rows.Next() {
+		p := &PreviewEnvironment{}
+		var prNumber sql.NullInt64
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&p.ID, &p.ProjectID, &prNumber, &p.HeadBranch, &p.Subdomain, &p.Domain,
+			&p.DeploymentID, &p.Status, &expiresAt); err != nil {
+			return nil, err
+		}
+		if prNumber.Valid {
+			n := int(prNumber.Int64)
+			p.PullRequestNumber = &n
+		}
+		if expiresAt.Valid {
+			p.ExpiresAt = &expiresAt.Time
+		}
+		previews = append(previews, p)
+	}
+	return previews, rows.Err()
+}
+
+// MarkPreviewTerminating claims a preview for teardown so the reaper does not publish it twice
+func (o *DeploymentOrchestrator) MarkPreviewTerminating(ctx context.Context, projectID, subdomain, reason string) error {
+	_, err := o.db.ExecContext(ctx, `
+		UPDATE preview_environments
+		SET status = $1, termination_reason = $2, updated_at = NOW()
+		WHERE project_id = $3 AND subdomain = $4 AND status <> $5`,
+		PreviewStatusTerminating, reason, projectID, subdomain, PreviewStatusTerminated)
+	return err
+}
+
+// TeardownPreviewEnvironment removes every container and route of one preview and marks
+// its deployments terminated. It is safe to run more than once.
+func (o *DeploymentOrchestrator) TeardownPreviewEnvironment(ctx context.Context, projectID, subdomain, reason string) error {
+	if subdomain == "" {
+		return fmt.Errorf("preview subdomain is required")
+	}
+
+	rows, err := o.db.QueryContext(ctx, `
+		SELECT dc.container_id, dc.container_name
+		FROM deployment_containers dc
+		JOIN deployments d ON d.id = dc.deployment_id
+		WHERE d.project_id = $1 AND d.environment = 'preview' AND d.subdomain = $2
+		  AND dc.status IN ('running', 'healthy', 'starting')`, projectID, subdomain)
+	if err != nil {
+		return fmt.Errorf("failed to load preview containers: %w", err)
+	}
+
+	type previewContainer struct{ id, name string }
+	var containers []previewContainer
+	for rows.Next() {
+		var c previewContainer
+		if err := rows.Scan(&c.id, &c.name); err != nil {
+			rows.Close()
+			return err
+		}
+		containers = append(containers, c)
+	}
+	rows.Close()
+
+	for _, c := range containers {
+		o.RemoveTraefikConfig(c.name)
+		o.RemoveContainerWithDocker(ctx, c.id)
+	}
+
+	if _, err := o.db.ExecContext(ctx, `
+		UPDATE deployment_containers
+		SET is_active = false, is_primary = false, status = 'stopped', stopped_at = NOW(), updated_at = NOW()
+		WHERE deployment_id IN (
+			SELECT id FROM deployments
+			WHERE project_id = $1 AND environment = 'preview' AND subdomain = $2
+		) AND status <> 'stopped'`, projectID, subdomain); err != nil {
+		return fmt.Errorf("failed to stop preview containers: %w", err)
+	}
+
+	if _, err := o.db.ExecContext(ctx, `
+		UPDATE deployments
+		SET status = $1, traffic_percentage = 0, terminated_at = NOW(), updated_at = NOW()
+		WHERE project_id = $2 AND environment = 'preview' AND subdomain = $3
+		  AND status IN ('pending', 'deploying', 'active')`,
+		DeploymentStatusTerminated, projectID, subdomain); err != nil {
+		return fmt.Errorf("failed to terminate preview deployments: %w", err)
+	}
+
+	if _, err := o.db.ExecContext(ctx, `
+		UPDATE preview_environments
+		SET status = $1,
+			termination_reason = COALESCE(termination_reason, $2),
+			terminated_at = NOW(),
+			updated_at = NOW()
+		WHERE project_id = $3 AND subdomain = $4`,
+		PreviewStatusTerminated, reason, projectID, subdomain); err != nil {
+		return fmt.Errorf("failed to mark preview terminated: %w", err)
+	}
+
+	log.Printf("🧹 Preview %s of project %s torn down (%s, %d containers)", subdomain, projectID, reason, len(containers))
+	return nil
+}
+
+// containerEnv renders EnvVars in a stable order for the container config
+func (j DeploymentJob) containerEnv() []string {
+	if len(j.EnvVars) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(j.EnvVars))
+	for k := range j.EnvVars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		env = append(env, k+"="+j.EnvVars[k])
+	}
+	return env
+}
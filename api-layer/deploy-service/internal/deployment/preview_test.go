@@ -0,0 +1,80 @@
+package deployment
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// encryptEnvContent encrypts like core-api does when it stores project_env_configs
+func encryptEnvContent(t *testing.T, secret, content string) string {
+	t.Helper()
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	padding := aes.BlockSize - len(content)%aes.BlockSize
+	plaintext := append([]byte(content), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+
+	return hex.EncodeToString(iv) + ":" + hex.EncodeToString(ciphertext)
+}
+
+func TestBuildPreviewEnvClonesStagingVariables(t *testing.T) {
+	const secret = "test-encryption-key"
+	t.Setenv("ENV_ENCRYPTION_KEY", secret)
+
+	configs := []envConfig{
+		{ServiceName: "web", EncryptedContent: encryptEnvContent(t, secret, "API_URL=https://staging.example.com\nLOG_LEVEL=debug\n")},
+		{ServiceName: "shared", EncryptedContent: encryptEnvContent(t, secret,
+			"# Shared by every service\nDATABASE_URL=\"postgres://staging\"\nexport LOG_LEVEL=info\nSENTRY_DSN='dsn'\n\nnot a variable\n")},
+	}
+	previewURL := "https://preview.example.com"
+	overrides := map[string]*string{
+		"API_URL":    &previewURL,
+		"SENTRY_DSN": nil,
+	}
+
+	env, err := buildPreviewEnv(configs, overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"DATABASE_URL": "postgres://staging",
+		"LOG_LEVEL":    "debug", // The service config wins over shared
+		"API_URL":      previewURL,
+	}
+	if len(env) != len(want) {
+		t.Fatalf("preview env %v, want %v", env, want)
+	}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("%s = %q, want %q", k, env[k], v)
+		}
+	}
+}
+
+func TestBuildPreviewEnvRejectsUndecryptableConfig(t *testing.T) {
+	t.Setenv("ENV_ENCRYPTION_KEY", "test-encryption-key")
+
+	configs := []envConfig{
+		{ServiceName: "shared", EncryptedContent: "DATABASE_URL=postgres://staging"},
+	}
+	if _, err := buildPreviewEnv(configs, nil); err == nil {
+		t.Fatal("deployed a preview without its staging variables")
+	}
+}
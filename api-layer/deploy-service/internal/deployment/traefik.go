@@ -1,59 +1,232 @@
 package deployment
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"deploy-service/pkg"
+)
+
+const (
+	traefikConfigDir = "/etc/traefik/dynamic"
+
+	domainChallengeRouter = "obtura-domain-challenge"
 )
 
 func (o *DeploymentOrchestrator) CreateTraefikConfig(job DeploymentJob, container *ContainerInfo) error {
-	configDir := "/etc/traefik/dynamic"
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+	if err := os.MkdirAll(traefikConfigDir, 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	rule := fmt.Sprintf("Host(`%s`)", job.Domain)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	configContent := fmt.Sprintf(`http:
-  routers:
-    %s:
-      rule: "%s"
-      service: "%s"
-      entryPoints:
-        - web
-      priority: 200
+	customDomains, err := o.getRoutableDomains(ctx, job.ProjectID, job.Environment)
+	if err != nil {
+		log.Printf("[warn] failed to load custom domains for project %s: %v", job.ProjectID, err)
+	}
+
+	middlewares, err := o.GetRouteMiddlewares(ctx, job.ProjectID, job.Environment)
+	if err != nil {
+		return fmt.Errorf("failed to load route middlewares: %w", err)
+	}
+	if err := middlewares.Validate(); err != nil {
+		return fmt.Errorf("invalid route middlewares: %w", err)
+	}
+
+	tlsConfig := GetTLSConfig()
+	configContent := renderTraefikConfig(job, container, customDomains, middlewares, tlsConfig)
+
+	configPath := filepath.Join(traefikConfigDir, fmt.Sprintf("%s.yml", container.Name))
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		return fmt.Errorf("failed to write Traefik config: %w", err)
+	}
+
+	if tlsConfig.Enabled && len(customDomains) > 0 {
+		o.markCertificatesRequested(ctx, customDomains, tlsConfig)
+	}
+
+	log.Printf("✅ Created Traefik config: %s -> %s (+%d custom domains, port %d)",
+		container.Name, job.Domain, len(customDomains), container.Port)
+
+	return nil
+}
+
+// renderTraefikConfig builds the dynamic configuration for one container. With TLS
+// enabled, the web router only redirects to HTTPS and websecure routers serve traffic:
+// one for exact hosts on the default resolver and one per wildcard domain on the DNS resolver.
+// The route middleware chain is attached to whichever routers serve traffic.
+func renderTraefikConfig(job DeploymentJob, container *ContainerInfo, customDomains []*ProjectDomain, middlewares *RouteMiddlewares, tlsConfig TLSConfig) string {
+	var hosts []string
+	if job.Domain != "" {
+		hosts = append(hosts, job.Domain)
+	}
+	var wildcards []*ProjectDomain
+	for _, d := range customDomains {
+		if d.IsWildcard {
+			if tlsConfig.Enabled && tlsConfig.WildcardResolver == "" {
+				log.Printf("[warn] skipping wildcard domain %s: no DNS certificate resolver configured", d.Domain)
+				continue
+			}
+			wildcards = append(wildcards, d)
+			continue
+		}
+		hosts = append(hosts, d.Domain)
+	}
+
+	if len(hosts) == 0 && len(wildcards) == 0 {
+		hosts = append(hosts, job.Domain)
+	}
+
+	var rules []string
+	if len(hosts) > 0 {
+		rules = append(rules, hostRule(hosts))
+	}
+	for _, d := range wildcards {
+		rules = append(rules, wildcardRule(d.Domain))
+	}
+	allHosts := strings.Join(rules, " || ")
+
+	name := container.Name
+	redirectMiddleware := name + "-redirect-https"
+
+	var mw strings.Builder
+	chain := middlewares.render(&mw, name)
+
+	var b strings.Builder
+	b.WriteString("http:\n  routers:\n")
+
+	writeRouter(&b, name, allHosts, name, tlsConfig.HTTPEntryPoint, 200)
+	if tlsConfig.Enabled {
+		writeRouterMiddlewares(&b, []string{redirectMiddleware})
 
+		if len(hosts) > 0 {
+			writeRouter(&b, name+"-secure", hostRule(hosts), name, tlsConfig.HTTPSEntryPoint, 200)
+			writeRouterMiddlewares(&b, chain)
+			fmt.Fprintf(&b, "      tls:\n        certResolver: %s\n", tlsConfig.CertResolver)
+		}
+		for i, d := range wildcards {
+			writeRouter(&b, fmt.Sprintf("%s-wildcard-%d", name, i), wildcardRule(d.Domain), name, tlsConfig.HTTPSEntryPoint, 190)
+			writeRouterMiddlewares(&b, chain)
+			fmt.Fprintf(&b, "      tls:\n        certResolver: %s\n        domains:\n", tlsConfig.WildcardResolver)
+			fmt.Fprintf(&b, "          - main: %s\n            sans:\n              - %s\n",
+				strconv.Quote(d.Domain), strconv.Quote("*."+d.Domain))
+		}
+
+		fmt.Fprintf(&mw, `    %s:
+      redirectScheme:
+        scheme: https
+        permanent: true
+`, redirectMiddleware)
+	} else {
+		writeRouterMiddlewares(&b, chain)
+	}
+
+	if mw.Len() > 0 {
+		b.WriteString("\n  middlewares:\n")
+		b.WriteString(mw.String())
+	}
+
+	fmt.Fprintf(&b, `
   services:
     %s:
       loadBalancer:
         servers:
           - url: "http://docker:%d"
         healthCheck:
-          path: /
-          interval: 10s
-          timeout: 3s
+          path: %s
+          interval: %s
+          timeout: %s
 `,
-		container.Name,
-		rule,
-		container.Name,
-		container.Name,
+		name,
 		container.Port,
+		strconv.Quote(job.HealthCheck.Path),
+		job.HealthCheck.Interval(),
+		job.HealthCheck.Timeout(),
 	)
+	if job.HealthCheck.ExpectedStatus > 0 {
+		fmt.Fprintf(&b, "          status: %d\n", job.HealthCheck.ExpectedStatus)
+	}
 
-	configPath := filepath.Join(configDir, fmt.Sprintf("%s.yml", container.Name))
-	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
-		return fmt.Errorf("failed to write Traefik config: %w", err)
+	return b.String()
+}
+
+func writeRouter(b *strings.Builder, name, rule, service, entryPoint string, priority int) {
+	fmt.Fprintf(b, `    %s:
+      rule: %s
+      service: "%s"
+      entryPoints:
+        - %s
+      priority: %d
+`, name, strconv.Quote(rule), service, entryPoint, priority)
+}
+
+func writeRouterMiddlewares(b *strings.Builder, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	b.WriteString("      middlewares:\n")
+	for _, n := range names {
+		fmt.Fprintf(b, "        - %s\n", n)
 	}
+}
 
-	log.Printf("✅ Created Traefik config: %s -> %s (port %d)",
-		container.Name, job.Domain, container.Port)
+func hostRule(hosts []string) string {
+	quoted := make([]string, len(hosts))
+	for i, h := range hosts {
+		quoted[i] = fmt.Sprintf("`%s`", h)
+	}
+	return fmt.Sprintf("Host(%s)", strings.Join(quoted, ", "))
+}
+
+func wildcardRule(domain string) string {
+	return fmt.Sprintf("Host(`%s`) || HostRegexp(`^[a-z0-9-]+\\.%s$`)", domain, regexp.QuoteMeta(domain))
+}
+
+// EnsureDomainChallengeRoute routes HTTP verification tokens for any host to deploy-service
+func (o *DeploymentOrchestrator) EnsureDomainChallengeRoute() error {
+	if err := os.MkdirAll(traefikConfigDir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	tlsConfig := GetTLSConfig()
+	serviceURL := pkg.GetEnv("DEPLOY_SERVICE_INTERNAL_URL", "http://deploy-service:5070")
+	rule := fmt.Sprintf("PathPrefix(`%s`)", DomainChallengePathPrefix)
+
+	var b strings.Builder
+	b.WriteString("http:\n  routers:\n")
+	writeRouter(&b, domainChallengeRouter, rule, domainChallengeRouter, tlsConfig.HTTPEntryPoint, 1000)
+	if tlsConfig.Enabled {
+		// Entrypoint-level redirects send the check to HTTPS before a certificate exists
+		writeRouter(&b, domainChallengeRouter+"-secure", rule, domainChallengeRouter, tlsConfig.HTTPSEntryPoint, 1000)
+		b.WriteString("      tls: {}\n")
+	}
+	fmt.Fprintf(&b, `
+  services:
+    %s:
+      loadBalancer:
+        servers:
+          - url: "%s"
+`, domainChallengeRouter, serviceURL)
+
+	configPath := filepath.Join(traefikConfigDir, domainChallengeRouter+".yml")
+	if err := os.WriteFile(configPath, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write Traefik config: %w", err)
+	}
 
+	log.Printf("✅ Domain verification route ready -> %s", serviceURL)
 	return nil
 }
 
 func (o *DeploymentOrchestrator) RemoveTraefikConfig(containerName string) error {
-	configPath := filepath.Join("/etc/traefik/dynamic", fmt.Sprintf("%s.yml", containerName))
+	configPath := filepath.Join(traefikConfigDir, fmt.Sprintf("%s.yml", containerName))
 
 	if err := os.Remove(configPath); err != nil && !os.IsNotExist(err) {
 		log.Printf("⚠️ Failed to remove Traefik config for %s: %v", containerName, err)
@@ -330,6 +330,8 @@ func (o *DeploymentOrchestrator) handleFailure(job DeploymentJob, phase string,
 	o.broker.PublishLog(job.DeploymentID, "error",
 		fmt.Sprintf("Deployment failed in phase %s: %v", phase, err))
 
+	o.markPreviewStatus(job, PreviewStatusFailed)
+
 	o.db.Exec(`
         UPDATE deployment_strategy_state
         SET current_phase = 'failed',
@@ -377,9 +379,10 @@ func (o *DeploymentOrchestrator) deactivateOldDeployments(ctx context.Context, j
 		    updated_at = NOW()
 		WHERE project_id = $2 
 		  AND environment = $3
+		  AND ($5 = '' OR subdomain = $5)
 		  AND id != $4
 		  AND status = 'active'
-	`, DeploymentStatusTerminated, job.ProjectID, job.Environment, newDeploymentID)
+	`, DeploymentStatusTerminated, job.ProjectID, job.Environment, newDeploymentID, job.previewScope())
 	if err != nil {
 		return fmt.Errorf("failed to update old deployment status: %w", err)
 	}
@@ -392,10 +395,11 @@ func (o *DeploymentOrchestrator) deactivateOldDeployments(ctx context.Context, j
 			SELECT id FROM deployments 
 			WHERE project_id = $1 
 			  AND environment = $2
+			  AND ($4 = '' OR subdomain = $4)
 			  AND id != $3
 		)
 		AND is_active = true
-	`, job.ProjectID, job.Environment, newDeploymentID)
+	`, job.ProjectID, job.Environment, newDeploymentID, job.previewScope())
 	if err != nil {
 		return fmt.Errorf("failed to deactivate old traffic routing: %w", err)
 	}
@@ -412,10 +416,11 @@ func (o *DeploymentOrchestrator) deactivateOldDeployments(ctx context.Context, j
 			SELECT id FROM deployments 
 			WHERE project_id = $1 
 			  AND environment = $2
+			  AND ($4 = '' OR subdomain = $4)
 			  AND id != $3
 		)
 		AND is_active = true
-	`, job.ProjectID, job.Environment, newDeploymentID)
+	`, job.ProjectID, job.Environment, newDeploymentID, job.previewScope())
 	if err != nil {
 		return fmt.Errorf("failed to deactivate old containers: %w", err)
 	}
@@ -46,6 +46,13 @@ type DeploymentQuota struct {
 	MaxPreviewEnvironments    int // Max preview environments
 	RollbackRetentionCount    int // How many rollbacks to keep
 
+	// Job limits
+	MaxConcurrentJobRuns int // Max job containers running at once per company
+	MaxScheduledJobs     int // Max cron jobs per project
+
+	// Domain limits
+	MaxCustomDomains int // Max custom domains per company
+
 	// Additional limits
 	MaxServicesPerDeployment int // Max services that can be deployed together
 }
@@ -275,6 +282,7 @@ type DeploymentUsage struct {
 	CurrentEnvironmentsCount     int
 	CurrentPreviewEnvironments   int
 	CurrentServicesPerDeployment int
+	IsPreview                    bool // Preview limits only apply to preview deployments
 }
 
 func (q DeploymentQuota) IsWithinDeploymentQuota(usage DeploymentUsage) (bool, string) {
@@ -287,7 +295,7 @@ func (q DeploymentQuota) IsWithinDeploymentQuota(usage DeploymentUsage) (bool, s
 	if usage.CurrentEnvironmentsCount >= q.MaxEnvironmentsPerProject {
 		return false, "Environment limit exceeded"
 	}
-	if usage.CurrentPreviewEnvironments >= q.MaxPreviewEnvironments {
+	if usage.IsPreview && usage.CurrentPreviewEnvironments >= q.MaxPreviewEnvironments {
 		return false, "Preview environment limit exceeded"
 	}
 	if usage.CurrentServicesPerDeployment > q.MaxServicesPerDeployment {
@@ -296,6 +304,33 @@ func (q DeploymentQuota) IsWithinDeploymentQuota(usage DeploymentUsage) (bool, s
 	return true, ""
 }
 
+// Job usage and quota checking
+type JobUsage struct {
+	CurrentConcurrentJobRuns int
+	CurrentScheduledJobs     int
+}
+
+func (q DeploymentQuota) IsWithinJobRunQuota(usage JobUsage) (bool, string) {
+	if usage.CurrentConcurrentJobRuns >= q.MaxConcurrentJobRuns {
+		return false, "Concurrent job run limit exceeded"
+	}
+	return true, ""
+}
+
+func (q DeploymentQuota) IsWithinScheduledJobQuota(usage JobUsage) (bool, string) {
+	if usage.CurrentScheduledJobs >= q.MaxScheduledJobs {
+		return false, "Scheduled job limit exceeded"
+	}
+	return true, ""
+}
+
+func (q DeploymentQuota) IsWithinCustomDomainQuota(currentDomains int) (bool, string) {
+	if currentDomains >= q.MaxCustomDomains {
+		return false, "Custom domain limit exceeded"
+	}
+	return true, ""
+}
+
 func (qs *QuotaService) GetDeploymentQuotaForProject(ctx context.Context, projectID string) (DeploymentQuota, error) {
 	query := `
 		SELECT
@@ -306,7 +341,10 @@ func (qs *QuotaService) GetDeploymentQuotaForProject(ctx context.Context, projec
 			sp.storage_gb,
 			sp.max_environments_per_project,
 			sp.max_preview_environments,
-			sp.rollback_retention_count
+			sp.rollback_retention_count,
+			sp.max_concurrent_job_runs,
+			sp.max_scheduled_jobs_per_project,
+			sp.max_custom_domains
 		FROM projects p
 		JOIN companies c ON c.id = p.company_id
 		JOIN subscriptions s ON s.company_id = c.id
@@ -316,7 +354,7 @@ func (qs *QuotaService) GetDeploymentQuotaForProject(ctx context.Context, projec
 	`
 
 	var quota DeploymentQuota
-	var maxDeploymentsPerMonth, maxPreviewEnvs sql.NullInt32
+	var maxDeploymentsPerMonth, maxPreviewEnvs, maxJobRuns, maxScheduledJobs, maxCustomDomains sql.NullInt32
 	var cpuCores sql.NullFloat64
 
 	err := qs.db.QueryRowContext(ctx, query, projectID).Scan(
@@ -328,6 +366,9 @@ func (qs *QuotaService) GetDeploymentQuotaForProject(ctx context.Context, projec
 		&quota.MaxEnvironmentsPerProject,
 		&maxPreviewEnvs,
 		&quota.RollbackRetentionCount,
+		&maxJobRuns,
+		&maxScheduledJobs,
+		&maxCustomDomains,
 	)
 
 	if err != nil {
@@ -347,6 +388,24 @@ func (qs *QuotaService) GetDeploymentQuotaForProject(ctx context.Context, projec
 		quota.MaxPreviewEnvironments = 999999 // Unlimited
 	}
 
+	if maxJobRuns.Valid {
+		quota.MaxConcurrentJobRuns = int(maxJobRuns.Int32)
+	} else {
+		quota.MaxConcurrentJobRuns = 999999 // Unlimited
+	}
+
+	if maxScheduledJobs.Valid {
+		quota.MaxScheduledJobs = int(maxScheduledJobs.Int32)
+	} else {
+		quota.MaxScheduledJobs = 999999 // Unlimited
+	}
+
+	if maxCustomDomains.Valid {
+		quota.MaxCustomDomains = int(maxCustomDomains.Int32)
+	} else {
+		quota.MaxCustomDomains = 999999 // Unlimited
+	}
+
 	if cpuCores.Valid {
 		quota.CPUCoresPerDeployment = cpuCores.Float64
 	} else {
@@ -370,7 +429,10 @@ func (qs *QuotaService) GetDeploymentQuotaForCompany(ctx context.Context, compan
 			sp.storage_gb,
 			sp.max_environments_per_project,
 			sp.max_preview_environments,
-			sp.rollback_retention_count
+			sp.rollback_retention_count,
+			sp.max_concurrent_job_runs,
+			sp.max_scheduled_jobs_per_project,
+			sp.max_custom_domains
 		FROM companies c
 		JOIN subscriptions s ON s.company_id = c.id
 		JOIN subscription_plans sp ON sp.id = s.plan_id
@@ -379,7 +441,7 @@ func (qs *QuotaService) GetDeploymentQuotaForCompany(ctx context.Context, compan
 	`
 
 	var quota DeploymentQuota
-	var maxDeploymentsPerMonth, maxPreviewEnvs sql.NullInt32
+	var maxDeploymentsPerMonth, maxPreviewEnvs, maxJobRuns, maxScheduledJobs, maxCustomDomains sql.NullInt32
 	var cpuCores sql.NullFloat64
 
 	err := qs.db.QueryRowContext(ctx, query, companyID).Scan(
@@ -391,6 +453,9 @@ func (qs *QuotaService) GetDeploymentQuotaForCompany(ctx context.Context, compan
 		&quota.MaxEnvironmentsPerProject,
 		&maxPreviewEnvs,
 		&quota.RollbackRetentionCount,
+		&maxJobRuns,
+		&maxScheduledJobs,
+		&maxCustomDomains,
 	)
 
 	if err != nil {
@@ -410,6 +475,24 @@ func (qs *QuotaService) GetDeploymentQuotaForCompany(ctx context.Context, compan
 		quota.MaxPreviewEnvironments = 999999
 	}
 
+	if maxJobRuns.Valid {
+		quota.MaxConcurrentJobRuns = int(maxJobRuns.Int32)
+	} else {
+		quota.MaxConcurrentJobRuns = 999999 // Unlimited
+	}
+
+	if maxScheduledJobs.Valid {
+		quota.MaxScheduledJobs = int(maxScheduledJobs.Int32)
+	} else {
+		quota.MaxScheduledJobs = 999999 // Unlimited
+	}
+
+	if maxCustomDomains.Valid {
+		quota.MaxCustomDomains = int(maxCustomDomains.Int32)
+	} else {
+		quota.MaxCustomDomains = 999999 // Unlimited
+	}
+
 	if cpuCores.Valid {
 		quota.CPUCoresPerDeployment = cpuCores.Float64
 	} else {
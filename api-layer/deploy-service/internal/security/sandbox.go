@@ -30,7 +30,6 @@ type DeploymentSandboxConfig struct {
 
 	// Runtime Options
 	Environment    string
-	HealthCheckURL string
 	StartupTimeout time.Duration
 
 	// Traefik Integration
@@ -47,7 +46,6 @@ func GetDefaultDeploymentConfig(planTier string, environment string) DeploymentS
 		NetworkMode:    "obtura_dev",
 		NetworkName:    "obtura_dev",
 		Environment:    environment,
-		HealthCheckURL: "/health",
 		StartupTimeout: 120 * time.Second,
 		DNSServers:     []string{"1.1.1.1", "1.0.0.1"},
 		ExposeToHost:   false,
@@ -532,12 +532,21 @@ func (w *Worker) updateDeploymentError(deploymentID, errorMsg string) {
 }
 
 type CleanupMessage struct {
-	ProjectID  string `json:"projectId"`
-	Containers []struct {
-		ContainerID   string `json:"containerId"`
-		ContainerName string `json:"containerName"`
-	} `json:"containers"`
-	Timestamp int64 `json:"timestamp"`
+	ProjectID  string             `json:"projectId"`
+	Containers []CleanupContainer `json:"containers"`
+	Timestamp  int64              `json:"timestamp"`
+
+	// Set when a single preview environment is torn down (PR closed or TTL expired)
+	// instead of a whole project; its containers are looked up by subdomain.
+	Environment       string `json:"environment,omitempty"`
+	Subdomain         string `json:"subdomain,omitempty"`
+	PullRequestNumber int    `json:"pullRequestNumber,omitempty"`
+	Reason            string `json:"reason,omitempty"`
+}
+
+type CleanupContainer struct {
+	ContainerID   string `json:"containerId"`
+	ContainerName string `json:"containerName"`
 }
 
 func (w *Worker) handleCleanupMessage(msg amqp091.Delivery) error {
@@ -552,10 +561,24 @@ func (w *Worker) handleCleanupMessage(msg amqp091.Delivery) error {
 		return fmt.Errorf("projectId is required")
 	}
 
-	log.Printf("🧹 Processing cleanup for ProjectID: %s with %d containers", cleanupMsg.ProjectID, len(cleanupMsg.Containers))
-
 	ctx := context.Background()
 
+	if cleanupMsg.Environment == "preview" {
+		subdomain := cleanupMsg.Subdomain
+		if subdomain == "" && cleanupMsg.PullRequestNumber > 0 {
+			subdomain = deployment.PreviewSubdomain(cleanupMsg.PullRequestNumber)
+		}
+		reason := cleanupMsg.Reason
+		if reason == "" {
+			reason = deployment.PreviewTerminationManual
+		}
+
+		log.Printf("🧹 Tearing down preview %s for ProjectID: %s (%s)", subdomain, cleanupMsg.ProjectID, reason)
+		return w.orchestrator.TeardownPreviewEnvironment(ctx, cleanupMsg.ProjectID, subdomain, reason)
+	}
+
+	log.Printf("🧹 Processing cleanup for ProjectID: %s with %d containers", cleanupMsg.ProjectID, len(cleanupMsg.Containers))
+
 	// Cleanup each container
 	for _, c := range cleanupMsg.Containers {
 		if c.ContainerID == "" {
@@ -578,6 +601,75 @@ func (w *Worker) handleCleanupMessage(msg amqp091.Delivery) error {
 	return nil
 }
 
+// StartPreviewReaper publishes a preview cleanup for every preview past its TTL, so expiry
+// goes through the same project.cleanup queue as PR-close teardown
+func (w *Worker) StartPreviewReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.reapExpiredPreviews(ctx); err != nil {
+				log.Printf("⚠️ Preview reaper failed: %v", err)
+			}
+		}
+	}
+}
+
+func (w *Worker) reapExpiredPreviews(ctx context.Context) error {
+	previews, err := w.orchestrator.ExpiredPreviewEnvironments(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list expired previews: %w", err)
+	}
+	if len(previews) == 0 {
+		return nil
+	}
+
+	// Publishing on the consumer channel from another goroutine is not safe
+	channel, err := w.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open publish channel: %w", err)
+	}
+	defer channel.Close()
+
+	for _, p := range previews {
+		msg := CleanupMessage{
+			ProjectID:   p.ProjectID,
+			Environment: "preview",
+			Subdomain:   p.Subdomain,
+			Reason:      deployment.PreviewTerminationTTLExpired,
+			Timestamp:   time.Now().UnixMilli(),
+		}
+		if p.PullRequestNumber != nil {
+			msg.PullRequestNumber = *p.PullRequestNumber
+		}
+
+		body, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+
+		if err := channel.PublishWithContext(ctx, "obtura.deploys", "project.cleanup", false, false, amqp091.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp091.Persistent,
+			Body:         body,
+			Timestamp:    time.Now(),
+		}); err != nil {
+			return fmt.Errorf("failed to publish cleanup for preview %s: %w", p.Subdomain, err)
+		}
+
+		if err := w.orchestrator.MarkPreviewTerminating(ctx, p.ProjectID, p.Subdomain, deployment.PreviewTerminationTTLExpired); err != nil {
+			log.Printf("⚠️ Failed to mark preview %s as terminating: %v", p.Subdomain, err)
+		}
+		log.Printf("⏰ Preview %s of project %s expired, cleanup queued", p.Subdomain, p.ProjectID)
+	}
+
+	return nil
+}
+
 func (w *Worker) Close() error {
 	if w.deploymentChannel != nil {
 		w.deploymentChannel.Close()
@@ -587,3 +679,8 @@ func (w *Worker) Close() error {
 	}
 	return nil
 }
+
+// Orchestrator exposes the deployment orchestrator for the HTTP API
+func (w *Worker) Orchestrator() *deployment.DeploymentOrchestrator {
+	return w.orchestrator
+}
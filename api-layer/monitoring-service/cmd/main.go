@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -15,6 +16,8 @@ import (
 	"monitoring-service/pkg/config"
 	"monitoring-service/pkg/db"
 	"monitoring-service/pkg/logger"
+
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -97,6 +100,21 @@ func main() {
 		}
 	}()
 
+	var otlpServer *grpc.Server
+	if cfg.OTLPGRPCPort != "" {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%s", cfg.OTLPGRPCPort))
+		if err != nil {
+			logger.Fatal("Failed to listen for OTLP/gRPC", logger.Err(err))
+		}
+		otlpServer = api.NewOTLPGRPCServer(orchestrator)
+		go func() {
+			logger.Info("Starting OTLP/gRPC receiver", logger.String("port", cfg.OTLPGRPCPort))
+			if err := otlpServer.Serve(listener); err != nil {
+				logger.Fatal("Failed to start OTLP/gRPC receiver", logger.Err(err))
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -109,6 +127,12 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Error("Server forced to shutdown", logger.Err(err))
 	}
+	if otlpServer != nil {
+		otlpServer.GracefulStop()
+	}
+	if err := apiServer.Close(); err != nil {
+		logger.Error("Failed to close WebSocket streams", logger.Err(err))
+	}
 
 	logger.Info("Monitoring service stopped")
 }
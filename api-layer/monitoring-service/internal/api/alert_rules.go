@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"monitoring-service/internal/monitoring"
+	"monitoring-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) respondAlertRuleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, monitoring.ErrAlertRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+	case errors.Is(err, monitoring.ErrAlertRuleNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error(message, logger.String("project_id", c.Param("projectId")), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func (s *Server) validateRuleID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isValidID(c.Param("ruleId")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID format"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func (s *Server) handleGetAlertRules(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rules, err := s.orchestrator.GetAlertManager().ListRules(ctx, c.Param("projectId"))
+	if err != nil {
+		s.respondAlertRuleError(c, err, "Failed to retrieve alert rules")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+func (s *Server) handleGetAlertRule(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rule, err := s.orchestrator.GetAlertManager().GetRule(ctx, c.Param("projectId"), c.Param("ruleId"))
+	if err != nil {
+		s.respondAlertRuleError(c, err, "Failed to retrieve alert rule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rule": rule})
+}
+
+func (s *Server) handleCreateAlertRule(c *gin.Context) {
+	rule := monitoring.AlertRule{Enabled: true, Channels: []string{"slack"}}
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := rule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetAlertManager().CreateRule(ctx, c.Param("projectId"), &rule); err != nil {
+		s.respondAlertRuleError(c, err, "Failed to create alert rule")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"rule": rule})
+}
+
+func (s *Server) handleUpdateAlertRule(c *gin.Context) {
+	rule := monitoring.AlertRule{Enabled: true, Channels: []string{"slack"}}
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := rule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetAlertManager().UpdateRule(ctx, c.Param("projectId"), c.Param("ruleId"), &rule); err != nil {
+		s.respondAlertRuleError(c, err, "Failed to update alert rule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rule": rule})
+}
+
+func (s *Server) handleDeleteAlertRule(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetAlertManager().DeleteRule(ctx, c.Param("projectId"), c.Param("ruleId")); err != nil {
+		s.respondAlertRuleError(c, err, "Failed to delete alert rule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rule deleted"})
+}
+
+// Evaluates an expression on the project's deployments right now, to try a rule out
+// before saving it
+func (s *Server) handleEvaluateAlertExpression(c *gin.Context) {
+	var req struct {
+		Expression  string `json:"expression"`
+		Environment string `json:"environment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if _, err := monitoring.ParseAlertExpr(req.Expression); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expression: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	results, err := s.orchestrator.GetAlertManager().EvaluateExpression(ctx, c.Param("projectId"), req.Environment, req.Expression)
+	if err != nil {
+		s.respondAlertRuleError(c, err, "Failed to evaluate expression")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"expression": req.Expression, "results": results})
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"monitoring-service/internal/metrics"
+	"monitoring-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Metrics per deployment in the project summary
+const appMetricsSummaryLimit = 30
+
+// Application metrics settings of a project, with what the latest scrapes returned
+func (s *Server) handleGetAppMetrics(c *gin.Context) {
+	projectID := c.Param("projectId")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	scraper := s.orchestrator.GetAppScraper()
+	config, err := scraper.GetConfig(ctx, projectID)
+	if err != nil {
+		logger.Error("Failed to get app metrics config", zap.String("project_id", projectID), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app metrics"})
+		return
+	}
+
+	deployments, err := scraper.ProjectSummary(ctx, projectID, appMetricsSummaryLimit)
+	if err != nil {
+		logger.Error("Failed to summarize app metrics", zap.String("project_id", projectID), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app metrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"project_id":  projectID,
+		"config":      config,
+		"deployments": deployments,
+	})
+}
+
+func (s *Server) handleUpdateAppMetricsConfig(c *gin.Context) {
+	projectID := c.Param("projectId")
+
+	config := metrics.AppMetricsConfig{
+		Path:            metrics.DefaultAppMetricsPath,
+		IntervalSeconds: metrics.DefaultAppMetricsInterval,
+	}
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := config.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetAppScraper().UpdateConfig(ctx, projectID, &config); err != nil {
+		logger.Error("Failed to update app metrics config", zap.String("project_id", projectID), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update app metrics config"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"config": config})
+}
+
+// Metric names scraped from a deployment
+func (s *Server) handleGetDeploymentAppMetrics(c *gin.Context) {
+	deploymentID := c.Param("deploymentId")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	scraper := s.orchestrator.GetAppScraper()
+	status, err := scraper.GetScrapeStatus(ctx, deploymentID)
+	if err != nil {
+		logger.Error("Failed to get app metrics scrape status", zap.String("deployment_id", deploymentID), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app metrics"})
+		return
+	}
+
+	list, err := scraper.ListMetrics(ctx, deploymentID)
+	if err != nil {
+		logger.Error("Failed to list app metrics", zap.String("deployment_id", deploymentID), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve app metrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deployment_id": deploymentID,
+		"scrape":        status,
+		"metrics":       list,
+	})
+}
+
+// Series of one metric. Labels are matched with label.<name>=<value> query params.
+func (s *Server) handleQueryDeploymentAppMetric(c *gin.Context) {
+	deploymentID := c.Param("deploymentId")
+
+	name := c.Query("metric")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "metric is required"})
+		return
+	}
+
+	end := time.Now()
+	start := end.Add(-1 * time.Hour)
+	if v := c.Query("start"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time format. Use RFC3339 format."})
+			return
+		}
+		start = t
+	}
+	if v := c.Query("end"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time format. Use RFC3339 format."})
+			return
+		}
+		end = t
+	}
+	if !start.Before(end) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start must be before end"})
+		return
+	}
+
+	match := map[string]string{}
+	for key, values := range c.Request.URL.Query() {
+		if label, ok := strings.CutPrefix(key, "label."); ok && label != "" && len(values) > 0 {
+			match[label] = values[0]
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	series, err := s.orchestrator.GetAppScraper().QueryMetric(ctx, deploymentID, name, match, start, end)
+	if err != nil {
+		logger.Error("Failed to query app metric", zap.String("deployment_id", deploymentID), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query app metric"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deployment_id": deploymentID,
+		"metric":        name,
+		"start":         start,
+		"end":           end,
+		"series":        series,
+	})
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"monitoring-service/internal/auth"
+	"monitoring-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sets the caller's session as an HttpOnly cookie. EventSource and WebSocket clients
+// cannot send an Authorization header, so the dashboard calls this before opening a
+// stream.
+func (s *Server) handleCreateSessionCookie(c *gin.Context) {
+	if principal(c).Kind != auth.KindUser {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only user sessions can be stored in a cookie"})
+		return
+	}
+
+	secure := s.config.Environment == "production"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(s.config.SessionCookieName, s.requestToken(c), 0, "/", "", secure, true)
+	c.JSON(http.StatusOK, gin.H{"message": "Session cookie set"})
+}
+
+func (s *Server) handleDeleteSessionCookie(c *gin.Context) {
+	secure := s.config.Environment == "production"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(s.config.SessionCookieName, "", -1, "/", "", secure, true)
+	c.JSON(http.StatusOK, gin.H{"message": "Session cookie cleared"})
+}
+
+func (s *Server) respondAPITokenError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, auth.ErrAPITokenNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "API token not found"})
+	case errors.Is(err, auth.ErrAPITokenNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		logger.Error(message, logger.String("project_id", c.Param("projectId")), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// API tokens
+func (s *Server) handleGetAPITokens(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	tokens, err := s.auth.ListAPITokens(ctx, c.Param("projectId"))
+	if err != nil {
+		s.respondAPITokenError(c, err, "Failed to retrieve API tokens")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+func (s *Server) handleCreateAPIToken(c *gin.Context) {
+	// Tokens are issued by people; a token cannot mint further tokens
+	if principal(c).Kind != auth.KindUser {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API tokens can only be created by users"})
+		return
+	}
+
+	var token auth.APIToken
+	if err := c.ShouldBindJSON(&token); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := token.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	token.ProjectID = c.Param("projectId")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.auth.CreateAPIToken(ctx, principal(c), &token); err != nil {
+		s.respondAPITokenError(c, err, "Failed to create API token")
+		return
+	}
+
+	// The token is only returned here
+	c.JSON(http.StatusCreated, gin.H{"token": token})
+}
+
+func (s *Server) handleRevokeAPIToken(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.auth.RevokeAPIToken(ctx, c.Param("projectId"), c.Param("tokenId")); err != nil {
+		s.respondAPITokenError(c, err, "Failed to revoke API token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API token revoked"})
+}
@@ -3,15 +3,19 @@ package api
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"monitoring-service/internal/auth"
 	"monitoring-service/internal/metrics"
 	"monitoring-service/internal/monitoring"
 	"monitoring-service/pkg/config"
+	"monitoring-service/pkg/db"
 	"monitoring-service/pkg/logger"
 
 	"github.com/gin-gonic/gin"
@@ -19,18 +23,13 @@ import (
 	"go.uber.org/zap"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-}
-
 type Server struct {
 	config       *config.Config
 	orchestrator *monitoring.Orchestrator
+	auth         *auth.Authenticator
 	router       *gin.Engine
+	upgrader     websocket.Upgrader
+	hub          *hub
 }
 
 func NewServer(cfg *config.Config, orch *monitoring.Orchestrator) *Server {
@@ -42,7 +41,18 @@ func NewServer(cfg *config.Config, orch *monitoring.Orchestrator) *Server {
 	s := &Server{
 		config:       cfg,
 		orchestrator: orch,
+		auth:         auth.NewAuthenticator(orch.GetDB(), cfg.ServiceAPITokens),
 		router:       gin.New(),
+		hub:          newHub(orch.GetRedis()),
+	}
+	s.upgrader = websocket.Upgrader{
+		// WebSockets are not covered by CORS; refuse pages from other origins riding
+		// on the session cookie
+		CheckOrigin: func(r *http.Request) bool {
+			return s.originAllowed(r.Header.Get("Origin"))
+		},
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
 	}
 
 	s.setupMiddleware()
@@ -50,26 +60,15 @@ func NewServer(cfg *config.Config, orch *monitoring.Orchestrator) *Server {
 	return s
 }
 
-// getUserIdFromSessionToken retrieves the user ID from a session token
-func (s *Server) getUserIdFromSessionToken(ctx context.Context, sessionToken string) (string, error) {
-	query := `SELECT user_id FROM sessions WHERE access_token = $1`
-
-	var userID string
-	err := s.orchestrator.GetDB().QueryRowContext(ctx, query, sessionToken).Scan(&userID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", fmt.Errorf("invalid session token")
-		}
-		return "", err
-	}
-
-	return userID, nil
-}
-
 func (s *Server) Router() *gin.Engine {
 	return s.router
 }
 
+// Close disconnects the WebSocket clients, which http.Server.Shutdown does not track
+func (s *Server) Close() error {
+	return s.hub.Close()
+}
+
 func (s *Server) setupMiddleware() {
 	// Recovery middleware recovers from any panics
 	s.router.Use(gin.Recovery())
@@ -87,33 +86,119 @@ func (s *Server) setupMiddleware() {
 func (s *Server) setupRoutes() {
 	s.router.GET("/health", s.handleHealth)
 
+	// Prometheus scrape endpoint, scoped to a project by its scrape token
+	s.router.GET("/metrics", s.handlePrometheusMetrics)
+
+	// OTLP/HTTP receivers, scoped to a project by its ingest token
+	s.router.POST("/otlp/v1/traces", s.handleOTLPTraces)
+	s.router.POST("/otlp/v1/logs", s.handleOTLPLogs)
+	s.router.POST("/otlp/v1/metrics", s.handleOTLPMetrics)
+
+	// Permissions on the project a route is about
+	canRead := s.requireProjectPermission(permDeploymentRead)
+	canReadLogs := s.requireProjectPermission(permLogsRead)
+	canOperate := s.requireProjectPermission(permDeploymentUpdate)
+	canConfigure := s.requireProjectPermission(permProjectUpdate)
+
 	// API group
-	api := s.router.Group("/api")
+	api := s.router.Group("/api", s.authenticate())
 	{
+		// Exchanges the bearer session for a cookie, for EventSource and WebSocket clients
+		api.POST("/auth/session", s.handleCreateSessionCookie)
+		api.DELETE("/auth/session", s.handleDeleteSessionCookie)
+
 		// Project-level monitoring routes
 		projects := api.Group("/projects")
 		{
-			projects.GET("/:projectId/metrics", s.validateProjectID(), s.handleGetProjectMetrics)
-			projects.GET("/:projectId/metrics/sse", s.validateProjectID(), s.handleProjectMetricsSSE)
-			projects.GET("/:projectId/alerts", s.validateProjectID(), s.handleGetProjectAlerts)
+			projects.GET("/:projectId/metrics", s.validateProjectID(), canRead, s.handleGetProjectMetrics)
+			projects.GET("/:projectId/metrics/sse", s.validateProjectID(), canRead, s.handleProjectMetricsSSE)
+			projects.GET("/:projectId/alerts", s.validateProjectID(), canRead, s.handleGetProjectAlerts)
+
+			projects.GET("/:projectId/alert-rules", s.validateProjectID(), canRead, s.handleGetAlertRules)
+			projects.POST("/:projectId/alert-rules", s.validateProjectID(), canConfigure, s.handleCreateAlertRule)
+			projects.POST("/:projectId/alert-rules/evaluate", s.validateProjectID(), canRead, s.handleEvaluateAlertExpression)
+			projects.GET("/:projectId/alert-rules/:ruleId", s.validateProjectID(), s.validateRuleID(), canRead, s.handleGetAlertRule)
+			projects.PUT("/:projectId/alert-rules/:ruleId", s.validateProjectID(), s.validateRuleID(), canConfigure, s.handleUpdateAlertRule)
+			projects.DELETE("/:projectId/alert-rules/:ruleId", s.validateProjectID(), s.validateRuleID(), canConfigure, s.handleDeleteAlertRule)
+
+			projects.GET("/:projectId/logs/tail", s.validateProjectID(), canReadLogs, s.handleProjectLogTail)
+
+			projects.GET("/:projectId/issues", s.validateProjectID(), canReadLogs, s.handleGetIssues)
+			projects.GET("/:projectId/issues/:issueId", s.validateProjectID(), s.validateIssueID(), canReadLogs, s.handleGetIssue)
+			projects.PUT("/:projectId/issues/:issueId/status", s.validateProjectID(), s.validateIssueID(), canOperate, s.handleUpdateIssueStatus)
+
+			projects.GET("/:projectId/silences", s.validateProjectID(), canRead, s.handleGetSilences)
+			projects.POST("/:projectId/silences", s.validateProjectID(), canOperate, s.handleCreateSilence)
+			projects.DELETE("/:projectId/silences/:silenceId", s.validateProjectID(), s.validateSilenceID(), canOperate, s.handleExpireSilence)
+			projects.GET("/:projectId/maintenance-windows", s.validateProjectID(), canRead, s.handleGetMaintenanceWindows)
+			projects.POST("/:projectId/maintenance-windows", s.validateProjectID(), canConfigure, s.handleCreateMaintenanceWindow)
+			projects.PUT("/:projectId/maintenance-windows/:windowId", s.validateProjectID(), s.validateWindowID(), canConfigure, s.handleUpdateMaintenanceWindow)
+			projects.DELETE("/:projectId/maintenance-windows/:windowId", s.validateProjectID(), s.validateWindowID(), canConfigure, s.handleDeleteMaintenanceWindow)
+
+			projects.GET("/:projectId/probes", s.validateProjectID(), canRead, s.handleGetProbes)
+			projects.POST("/:projectId/probes", s.validateProjectID(), canConfigure, s.handleCreateProbe)
+			projects.PUT("/:projectId/probes/:probeId", s.validateProjectID(), s.validateProbeID(), canConfigure, s.handleUpdateProbe)
+			projects.DELETE("/:projectId/probes/:probeId", s.validateProjectID(), s.validateProbeID(), canConfigure, s.handleDeleteProbe)
+			projects.GET("/:projectId/probes/:probeId/results", s.validateProjectID(), s.validateProbeID(), canRead, s.handleGetProbeResults)
+
+			projects.GET("/:projectId/slos", s.validateProjectID(), canRead, s.handleGetSLOs)
+			projects.POST("/:projectId/slos", s.validateProjectID(), canConfigure, s.handleCreateSLO)
+			projects.GET("/:projectId/slos/:sloId", s.validateProjectID(), s.validateSLOID(), canRead, s.handleGetSLO)
+			projects.PUT("/:projectId/slos/:sloId", s.validateProjectID(), s.validateSLOID(), canConfigure, s.handleUpdateSLO)
+			projects.DELETE("/:projectId/slos/:sloId", s.validateProjectID(), s.validateSLOID(), canConfigure, s.handleDeleteSLO)
+
+			projects.GET("/:projectId/metrics-tokens", s.validateProjectID(), canConfigure, s.handleGetScrapeTokens)
+			projects.POST("/:projectId/metrics-tokens", s.validateProjectID(), canConfigure, s.handleCreateScrapeToken)
+			projects.DELETE("/:projectId/metrics-tokens/:tokenId", s.validateProjectID(), s.validateTokenID(), canConfigure, s.handleRevokeScrapeToken)
+
+			projects.GET("/:projectId/api-tokens", s.validateProjectID(), canConfigure, s.handleGetAPITokens)
+			projects.POST("/:projectId/api-tokens", s.validateProjectID(), canConfigure, s.handleCreateAPIToken)
+			projects.DELETE("/:projectId/api-tokens/:tokenId", s.validateProjectID(), s.validateTokenID(), canConfigure, s.handleRevokeAPIToken)
+
+			projects.GET("/:projectId/remote-write", s.validateProjectID(), canConfigure, s.handleGetRemoteWriteTargets)
+			projects.POST("/:projectId/remote-write", s.validateProjectID(), canConfigure, s.handleCreateRemoteWriteTarget)
+			projects.PUT("/:projectId/remote-write/:targetId", s.validateProjectID(), s.validateTargetID(), canConfigure, s.handleUpdateRemoteWriteTarget)
+			projects.DELETE("/:projectId/remote-write/:targetId", s.validateProjectID(), s.validateTargetID(), canConfigure, s.handleDeleteRemoteWriteTarget)
+
+			projects.GET("/:projectId/log-drains", s.validateProjectID(), canConfigure, s.handleGetLogDrains)
+			projects.POST("/:projectId/log-drains", s.validateProjectID(), canConfigure, s.handleCreateLogDrain)
+			projects.PUT("/:projectId/log-drains/:drainId", s.validateProjectID(), s.validateDrainID(), canConfigure, s.handleUpdateLogDrain)
+			projects.DELETE("/:projectId/log-drains/:drainId", s.validateProjectID(), s.validateDrainID(), canConfigure, s.handleDeleteLogDrain)
+			projects.POST("/:projectId/log-drains/:drainId/test", s.validateProjectID(), s.validateDrainID(), canConfigure, s.handleTestLogDrain)
+
+			projects.GET("/:projectId/otlp-tokens", s.validateProjectID(), canConfigure, s.handleGetOTLPTokens)
+			projects.POST("/:projectId/otlp-tokens", s.validateProjectID(), canConfigure, s.handleCreateOTLPToken)
+			projects.DELETE("/:projectId/otlp-tokens/:tokenId", s.validateProjectID(), s.validateTokenID(), canConfigure, s.handleRevokeOTLPToken)
+
+			projects.GET("/:projectId/traces", s.validateProjectID(), canReadLogs, s.handleGetTraces)
+			projects.GET("/:projectId/traces/:traceId", s.validateProjectID(), canReadLogs, s.handleGetTrace)
+
+			projects.GET("/:projectId/app-metrics", s.validateProjectID(), canRead, s.handleGetAppMetrics)
+			projects.PUT("/:projectId/app-metrics", s.validateProjectID(), canConfigure, s.handleUpdateAppMetricsConfig)
 		}
 
 		metrics := api.Group("/metrics")
 		{
-			metrics.GET("/:deploymentId", s.validateDeploymentID(), s.handleGetMetrics)
-			metrics.GET("/:deploymentId/current", s.validateDeploymentID(), s.handleGetCurrentMetrics)
-			metrics.GET("/:deploymentId/history", s.validateDeploymentID(), s.handleGetMetricsHistory)
+			metrics.GET("/:deploymentId", s.validateDeploymentID(), canRead, s.handleGetMetrics)
+			metrics.GET("/:deploymentId/current", s.validateDeploymentID(), canRead, s.handleGetCurrentMetrics)
+			metrics.GET("/:deploymentId/history", s.validateDeploymentID(), canRead, s.handleGetMetricsHistory)
+			metrics.GET("/:deploymentId/app", s.validateDeploymentID(), canRead, s.handleGetDeploymentAppMetrics)
+			metrics.GET("/:deploymentId/app/query", s.validateDeploymentID(), canRead, s.handleQueryDeploymentAppMetric)
+			metrics.GET("/:deploymentId/requests", s.validateDeploymentID(), canRead, s.handleGetSampledRequests)
+			metrics.GET("/:deploymentId/requests/:requestId/trace", s.validateDeploymentID(), canReadLogs, s.handleGetRequestTrace)
 		}
 
 		logs := api.Group("/logs")
 		{
-			logs.GET("/:deploymentId", s.validateDeploymentID(), s.handleGetLogs)
-			logs.GET("/:deploymentId/stream", s.validateDeploymentID(), s.handleStreamLogs)
+			logs.GET("/:deploymentId", s.validateDeploymentID(), canReadLogs, s.handleGetLogs)
+			logs.GET("/:deploymentId/stream", s.validateDeploymentID(), canReadLogs, s.handleWebSocketLogs)
 		}
 
+		// Platform log resources are authorized in the handlers, a query's resource can
+		// come from its body
 		platformLogs := api.Group("/platform-logs")
 		{
-			platformLogs.POST("/ingest", s.handleIngestLogs)
+			platformLogs.POST("/ingest", s.requireService(), s.handleIngestLogs)
 			platformLogs.GET("/query", s.handleQueryLogs)
 			platformLogs.POST("/query", s.handleQueryLogs)
 			platformLogs.GET("/stream/:resourceType/:resourceId", s.handleStreamPlatformLogs)
@@ -122,30 +207,59 @@ func (s *Server) setupRoutes() {
 
 		alerts := api.Group("/alerts")
 		{
-			alerts.GET("", s.handleGetAlerts)
-			alerts.GET("/:alertId", s.validateAlertID(), s.handleGetAlert)
-			alerts.POST("/:alertId/acknowledge/:sessionToken", s.validateAlertID(), s.handleAcknowledgeAlert)
-			alerts.POST("/:alertId/resolve/:sessionToken", s.validateAlertID(), s.handleResolveAlert)
-			alerts.DELETE("/:alertId", s.validateAlertID(), s.handleDeleteAlert)
+			alerts.GET("", canRead, s.handleGetAlerts)
+			alerts.GET("/:alertId", s.validateAlertID(), canRead, s.handleGetAlert)
+			alerts.POST("/:alertId/acknowledge", s.validateAlertID(), canOperate, s.handleAcknowledgeAlert)
+			alerts.POST("/:alertId/resolve", s.validateAlertID(), canOperate, s.handleResolveAlert)
+			alerts.DELETE("/:alertId", s.validateAlertID(), canOperate, s.handleDeleteAlert)
+			alerts.GET("/:alertId/escalation", s.validateAlertID(), canRead, s.handleGetAlertEscalation)
+		}
+
+		teams := api.Group("/teams")
+		{
+			canReadTeam := s.requireTeamPermission(permTeamRead)
+			canUpdateTeam := s.requireTeamPermission(permTeamUpdate)
+
+			teams.GET("/:teamId/oncall", s.validateTeamID(), canReadTeam, s.handleGetOnCall)
+			teams.GET("/:teamId/schedules", s.validateTeamID(), canReadTeam, s.handleGetSchedules)
+			teams.POST("/:teamId/schedules", s.validateTeamID(), canUpdateTeam, s.handleCreateSchedule)
+			teams.GET("/:teamId/schedules/:scheduleId", s.validateTeamID(), s.validateScheduleID(), canReadTeam, s.handleGetSchedule)
+			teams.PUT("/:teamId/schedules/:scheduleId", s.validateTeamID(), s.validateScheduleID(), canUpdateTeam, s.handleUpdateSchedule)
+			teams.DELETE("/:teamId/schedules/:scheduleId", s.validateTeamID(), s.validateScheduleID(), canUpdateTeam, s.handleDeleteSchedule)
+			teams.POST("/:teamId/schedules/:scheduleId/overrides", s.validateTeamID(), s.validateScheduleID(), canUpdateTeam, s.handleCreateOverride)
+			teams.DELETE("/:teamId/schedules/:scheduleId/overrides/:overrideId", s.validateTeamID(), s.validateScheduleID(), canUpdateTeam, s.handleDeleteOverride)
+
+			teams.GET("/:teamId/escalation-policies", s.validateTeamID(), canReadTeam, s.handleGetEscalationPolicies)
+			teams.POST("/:teamId/escalation-policies", s.validateTeamID(), canUpdateTeam, s.handleCreateEscalationPolicy)
+			teams.PUT("/:teamId/escalation-policies/:policyId", s.validateTeamID(), s.validatePolicyID(), canUpdateTeam, s.handleUpdateEscalationPolicy)
+			teams.DELETE("/:teamId/escalation-policies/:policyId", s.validateTeamID(), s.validatePolicyID(), canUpdateTeam, s.handleDeleteEscalationPolicy)
 		}
 
 		deployments := api.Group("/deployments")
 		{
-			deployments.GET("/:deploymentId/health", s.validateDeploymentID(), s.handleGetDeploymentHealth)
-			deployments.GET("/:deploymentId/uptime", s.validateDeploymentID(), s.handleGetDeploymentUptime)
+			deployments.GET("/:deploymentId/health", s.validateDeploymentID(), canRead, s.handleGetDeploymentHealth)
+			deployments.GET("/:deploymentId/uptime", s.validateDeploymentID(), canRead, s.handleGetDeploymentUptime)
 		}
 
 		incidents := api.Group("/incidents")
 		{
-			incidents.GET("", s.handleGetIncidents)
-			incidents.GET("/:incidentId", s.validateIncidentID(), s.handleGetIncident)
+			incidents.GET("", canRead, s.handleGetIncidents)
+			incidents.GET("/stats", canRead, s.handleGetIncidentStats)
+			incidents.GET("/:incidentId", s.validateIncidentID(), canRead, s.handleGetIncident)
+			incidents.POST("/:incidentId/acknowledge", s.validateIncidentID(), canOperate, s.handleTransitionIncident(monitoring.IncidentStatusAcknowledged))
+			incidents.POST("/:incidentId/mitigate", s.validateIncidentID(), canOperate, s.handleTransitionIncident(monitoring.IncidentStatusMitigated))
+			incidents.POST("/:incidentId/resolve", s.validateIncidentID(), canOperate, s.handleTransitionIncident(monitoring.IncidentStatusResolved))
+			incidents.POST("/:incidentId/notes", s.validateIncidentID(), canOperate, s.handleAddIncidentNote)
+			incidents.GET("/:incidentId/postmortem", s.validateIncidentID(), canRead, s.handleGetPostmortem)
+			incidents.PUT("/:incidentId/postmortem", s.validateIncidentID(), canOperate, s.handleSavePostmortem)
 		}
 	}
 
-	ws := s.router.Group("/ws")
+	// Browsers cannot set headers on WebSockets; they authenticate with the session cookie
+	ws := s.router.Group("/ws", s.authenticate())
 	{
-		ws.GET("/metrics/:deploymentId", s.validateDeploymentID(), s.handleWebSocketMetrics)
-		ws.GET("/logs/:deploymentId", s.validateDeploymentID(), s.handleWebSocketLogs)
+		ws.GET("/metrics/:deploymentId", s.validateDeploymentID(), canRead, s.handleWebSocketMetrics)
+		ws.GET("/logs/:deploymentId", s.validateDeploymentID(), canReadLogs, s.handleWebSocketLogs)
 	}
 }
 
@@ -328,6 +442,14 @@ func (s *Server) handleGetMetricsHistory(c *gin.Context) {
 		return
 	}
 
+	resolution := metrics.Resolution(c.Query("resolution"))
+	switch resolution {
+	case "", metrics.ResolutionRaw, metrics.ResolutionMinute, metrics.ResolutionHour, metrics.ResolutionDay:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid resolution. Use raw, 1m, 1h or 1d."})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
@@ -335,15 +457,41 @@ func (s *Server) handleGetMetricsHistory(c *gin.Context) {
 	var args []interface{}
 
 	if startTime != "" && endTime != "" {
-		query = `
-			SELECT timestamp, cpu_usage, memory_usage, network_rx, network_tx, status
-			FROM deployments_metrics
-			WHERE deployment_id = $1 AND timestamp >= $2 AND timestamp <= $3
-			ORDER BY timestamp DESC
-			LIMIT 1000
-		`
+		// Pick the finest tier that still covers the range unless one was requested
+		if resolution == "" {
+			start, _ := time.Parse(time.RFC3339, startTime)
+			end, _ := time.Parse(time.RFC3339, endTime)
+			resolution = s.orchestrator.GetMetricsRollup().ResolutionFor(ctx, deploymentID, start, end)
+		}
+
+		if resolution == metrics.ResolutionRaw {
+			query = `
+				SELECT timestamp, cpu_usage, memory_usage, network_rx, network_tx, status
+				FROM deployments_metrics
+				WHERE deployment_id = $1 AND timestamp >= $2 AND timestamp <= $3
+				ORDER BY timestamp DESC
+				LIMIT 1000
+			`
+		} else {
+			query = `
+				SELECT bucket_start, sample_count, cpu_avg, cpu_min, cpu_max, cpu_p95,
+				       memory_avg, memory_min, memory_max, memory_p95, network_rx, network_tx
+				FROM container_metrics_rollups
+				WHERE deployment_id = $1 AND bucket_start >= date_trunc($4, $2::timestamp) AND bucket_start <= $3
+				  AND resolution = $5
+				ORDER BY bucket_start DESC
+				LIMIT 5000
+			`
+		}
 		args = []interface{}{deploymentID, startTime, endTime}
+		if resolution != metrics.ResolutionRaw {
+			unit := map[metrics.Resolution]string{
+				metrics.ResolutionMinute: "minute", metrics.ResolutionHour: "hour", metrics.ResolutionDay: "day",
+			}[resolution]
+			args = append(args, unit, string(resolution))
+		}
 	} else {
+		resolution = metrics.ResolutionRaw
 		query = `
 			SELECT timestamp, cpu_usage, memory_usage, network_rx, network_tx, status
 			FROM deployments_metrics
@@ -364,8 +512,38 @@ func (s *Server) handleGetMetricsHistory(c *gin.Context) {
 	}
 	defer rows.Close()
 
-	var metrics []gin.H
+	var metricsHistory []gin.H
 	for rows.Next() {
+		if resolution != metrics.ResolutionRaw {
+			var bucket time.Time
+			var samples int64
+			var cpuAvg, cpuMin, cpuMax, cpuP95 sql.NullFloat64
+			var memAvg, memMin, memMax, memP95, netRx, netTx sql.NullInt64
+
+			if err := rows.Scan(&bucket, &samples, &cpuAvg, &cpuMin, &cpuMax, &cpuP95,
+				&memAvg, &memMin, &memMax, &memP95, &netRx, &netTx); err != nil {
+				logger.Error("Failed to scan metric row", logger.Err(err))
+				continue
+			}
+
+			// cpu_usage and memory_usage carry the bucket averages so charts read both shapes
+			metricsHistory = append(metricsHistory, gin.H{
+				"timestamp":    bucket,
+				"cpu_usage":    cpuAvg.Float64,
+				"cpu_min":      cpuMin.Float64,
+				"cpu_max":      cpuMax.Float64,
+				"cpu_p95":      cpuP95.Float64,
+				"memory_usage": memAvg.Int64,
+				"memory_min":   memMin.Int64,
+				"memory_max":   memMax.Int64,
+				"memory_p95":   memP95.Int64,
+				"network_rx":   netRx.Int64,
+				"network_tx":   netTx.Int64,
+				"sample_count": samples,
+			})
+			continue
+		}
+
 		var timestamp time.Time
 		var cpu float64
 		var mem, netRx, netTx int64
@@ -376,7 +554,7 @@ func (s *Server) handleGetMetricsHistory(c *gin.Context) {
 			continue
 		}
 
-		metrics = append(metrics, gin.H{
+		metricsHistory = append(metricsHistory, gin.H{
 			"timestamp":    timestamp,
 			"cpu_usage":    cpu,
 			"memory_usage": mem,
@@ -390,27 +568,55 @@ func (s *Server) handleGetMetricsHistory(c *gin.Context) {
 		"deployment_id": deploymentID,
 		"start":         startTime,
 		"end":           endTime,
-		"metrics":       metrics,
-		"count":         len(metrics),
+		"resolution":    resolution,
+		"metrics":       metricsHistory,
+		"count":         len(metricsHistory),
 	})
 }
 
-// Get logs
+// Get logs, optionally filtered with a log query (see monitoring.ParseLogQuery)
 func (s *Server) handleGetLogs(c *gin.Context) {
 	deploymentID := c.Param("deploymentId")
+	startTime := c.Query("start")
+	endTime := c.Query("end")
 
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
-	defer cancel()
+	if !isValidTimeFormat(startTime) || !isValidTimeFormat(endTime) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time format. Use RFC3339 format."})
+		return
+	}
 
-	query := `
-		SELECT timestamp, level, message, source
-		FROM logs_archive
-		WHERE deployment_id = $1
-		ORDER BY timestamp DESC
-		LIMIT 1000
-	`
+	end := time.Now()
+	if endTime != "" {
+		end, _ = time.Parse(time.RFC3339, endTime)
+	}
+	start := end.Add(-24 * time.Hour)
+	if startTime != "" {
+		start, _ = time.Parse(time.RFC3339, startTime)
+	}
+	if start.After(end) || end.Sub(start) > db.LogRetentionMinIO {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start must be before end and at most 90 days apart"})
+		return
+	}
 
-	rows, err := s.orchestrator.GetDB().QueryContext(ctx, query, deploymentID)
+	limit := 1000
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	var query *monitoring.LogQuery
+	if q := c.Query("q"); q != "" {
+		var err error
+		if query, err = monitoring.ParseLogQuery(q, monitoring.ContainerLogColumns); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Archived days are read from MinIO, which takes longer than the database
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	entries, err := s.orchestrator.GetLogAggregator().SearchLogs(ctx, deploymentID, query, start, end, limit)
 	if err != nil {
 		logger.Error("Failed to query logs",
 			zap.String("deployment_id", deploymentID),
@@ -418,77 +624,46 @@ func (s *Server) handleGetLogs(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve logs"})
 		return
 	}
-	defer rows.Close()
-
-	var logs []gin.H
-	for rows.Next() {
-		var timestamp time.Time
-		var level, message, source string
-
-		if err := rows.Scan(&timestamp, &level, &message, &source); err != nil {
-			logger.Error("Failed to scan log row", logger.Err(err))
-			continue
-		}
 
+	logs := []gin.H{}
+	for _, entry := range entries {
 		logs = append(logs, gin.H{
-			"timestamp": timestamp,
-			"level":     level,
-			"message":   message,
-			"source":    source,
+			"timestamp":    entry.Timestamp,
+			"level":        entry.Level,
+			"message":      entry.Message,
+			"source":       entry.Source,
+			"stream":       entry.Stream,
+			"container_id": entry.ContainerID,
+			"metadata":     json.RawMessage(entry.Metadata),
 		})
 	}
 
 	c.JSON(http.StatusOK, logs)
 }
 
-// Stream logs via WebSocket
-func (s *Server) handleStreamLogs(c *gin.Context) {
-	deploymentID := c.Param("deploymentId")
-
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
-	if err != nil {
-		logger.Error("Failed to upgrade WebSocket connection", logger.Err(err))
-		return
-	}
-	defer conn.Close()
-
-	logger.Info("WebSocket log stream started", zap.String("deployment_id", deploymentID))
-
-	// Stream logs in real-time
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Send periodic ping to keep connection alive
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			if err := conn.WriteMessage(websocket.PingMessage, []byte{}); err != nil {
-				logger.Error("WebSocket ping failed", logger.Err(err))
-				return
-			}
-		}
-	}
-}
+const alertStatusColumn = `
+	CASE WHEN a.resolved THEN 'resolved'
+	     WHEN a.suppressed_by IS NOT NULL THEN 'silenced'
+	     WHEN a.acknowledged THEN 'acknowledged'
+	     ELSE 'active' END`
 
 // Get alerts
 func (s *Server) handleGetAlerts(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
+	// Scoped to the projectId the permission check ran against; internal services may
+	// list every project's alerts
 	query := `
-		SELECT id, deployment_id, severity, title, description, status, triggered_at
-		FROM alerts
-		WHERE status = 'active'
-		ORDER BY triggered_at DESC
+		SELECT a.id, a.deployment_id, a.severity, a.alert_type, a.alert_message, ` + alertStatusColumn + `, a.created_at
+		FROM deployment_alerts a
+		JOIN deployments d ON d.id = a.deployment_id
+		WHERE a.resolved = false AND ($1 = '' OR d.project_id::text = $1)
+		ORDER BY a.created_at DESC
 		LIMIT 100
 	`
 
-	rows, err := s.orchestrator.GetDB().QueryContext(ctx, query)
+	rows, err := s.orchestrator.GetDB().QueryContext(ctx, query, c.Query("projectId"))
 	if err != nil {
 		logger.Error("Failed to query alerts", logger.Err(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve alerts"})
@@ -528,9 +703,9 @@ func (s *Server) handleGetAlert(c *gin.Context) {
 	defer cancel()
 
 	query := `
-		SELECT id, deployment_id, severity, title, description, status, triggered_at
-		FROM alerts
-		WHERE id = $1
+		SELECT a.id, a.deployment_id, a.severity, a.alert_type, a.alert_message, ` + alertStatusColumn + `, a.created_at
+		FROM deployment_alerts a
+		WHERE a.id = $1
 	`
 
 	var alert gin.H
@@ -565,22 +740,14 @@ func (s *Server) handleGetAlert(c *gin.Context) {
 
 func (s *Server) handleAcknowledgeAlert(c *gin.Context) {
 	alertID := c.Param("alertId")
-	sessionToken := c.Param("sessionToken")
+	userID := principal(c).UserID
 
 	logger.Info("Acknowledging alert", zap.String("alert_id", alertID))
 
-	// Get user ID from session token
-	userID, err := s.getUserIdFromSessionToken(c.Request.Context(), sessionToken)
-	if err != nil {
-		logger.Error("Failed to get user ID from session token", zap.Error(err))
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session token"})
-		return
-	}
-
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	query := `UPDATE deployment_alerts SET acknowledged = true, acknowledged_at = NOW(), acknowledged_by_user_id = $2 WHERE id = $1`
+	query := `UPDATE deployment_alerts SET acknowledged = true, acknowledged_at = NOW(), acknowledged_by_user_id = NULLIF($2, '')::uuid WHERE id = $1`
 	result, err := s.orchestrator.GetDB().ExecContext(ctx, query, alertID, userID)
 	if err != nil {
 		logger.Error("Failed to acknowledge alert", zap.String("alert_id", alertID), logger.Err(err))
@@ -594,28 +761,25 @@ func (s *Server) handleAcknowledgeAlert(c *gin.Context) {
 		return
 	}
 
+	// Nobody else needs to be paged for it
+	if err := s.orchestrator.GetOnCallManager().StopEscalation(ctx, alertID, "acknowledged"); err != nil {
+		logger.Error("Failed to stop alert escalation", zap.String("alert_id", alertID), logger.Err(err))
+	}
+
 	logger.Info("Alert acknowledged", zap.String("alert_id", alertID), zap.String("user_id", userID))
 	c.JSON(http.StatusOK, gin.H{"message": "Alert acknowledged"})
 }
 
 func (s *Server) handleResolveAlert(c *gin.Context) {
 	alertID := c.Param("alertId")
-	sessionToken := c.Param("sessionToken")
+	userID := principal(c).UserID
 
 	logger.Info("Resolving alert", zap.String("alert_id", alertID))
 
-	// Get user ID from session token
-	userID, err := s.getUserIdFromSessionToken(c.Request.Context(), sessionToken)
-	if err != nil {
-		logger.Error("Failed to get user ID from session token", zap.Error(err))
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session token"})
-		return
-	}
-
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	query := `UPDATE deployment_alerts SET resolved = true, resolved_at = NOW(), resolved_by_user_id = $2 WHERE id = $1`
+	query := `UPDATE deployment_alerts SET resolved = true, resolved_at = NOW(), resolved_by_user_id = NULLIF($2, '')::uuid WHERE id = $1`
 	result, err := s.orchestrator.GetDB().ExecContext(ctx, query, alertID, userID)
 	if err != nil {
 		logger.Error("Failed to resolve alert", zap.String("alert_id", alertID), logger.Err(err))
@@ -629,29 +793,20 @@ func (s *Server) handleResolveAlert(c *gin.Context) {
 		return
 	}
 
+	// Nobody else needs to be paged for it
+	if err := s.orchestrator.GetOnCallManager().StopEscalation(ctx, alertID, "resolved"); err != nil {
+		logger.Error("Failed to stop alert escalation", zap.String("alert_id", alertID), logger.Err(err))
+	}
+
 	logger.Info("Alert resolved", zap.String("alert_id", alertID), zap.String("user_id", userID))
 	c.JSON(http.StatusOK, gin.H{"message": "Alert resolved"})
 }
 
 func (s *Server) handleDeleteAlert(c *gin.Context) {
 	alertID := c.Param("alertId")
-	accessToken := c.Query("accessToken")
-
-	if accessToken == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Access token required"})
-		return
-	}
 
 	logger.Info("Deleting alert", zap.String("alert_id", alertID))
 
-	// Validate session token
-	_, err := s.getUserIdFromSessionToken(c.Request.Context(), accessToken)
-	if err != nil {
-		logger.Error("Failed to validate session token", zap.Error(err))
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session token"})
-		return
-	}
-
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
@@ -760,165 +915,14 @@ func (s *Server) handleGetDeploymentUptime(c *gin.Context) {
 	})
 }
 
-// Get incidents
-func (s *Server) handleGetIncidents(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
-	defer cancel()
-
-	query := `
-		SELECT id, deployment_id, title, description, severity, status, started_at
-		FROM incidents
-		ORDER BY started_at DESC
-		LIMIT 100
-	`
-
-	rows, err := s.orchestrator.GetDB().QueryContext(ctx, query)
-	if err != nil {
-		logger.Error("Failed to query incidents", logger.Err(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve incidents"})
-		return
-	}
-	defer rows.Close()
-
-	var incidents []gin.H
-	for rows.Next() {
-		var id, deploymentID, title, description, severity, status string
-		var startedAt time.Time
-
-		if err := rows.Scan(&id, &deploymentID, &title, &description, &severity, &status, &startedAt); err != nil {
-			logger.Error("Failed to scan incident row", logger.Err(err))
-			continue
-		}
-
-		incidents = append(incidents, gin.H{
-			"id":            id,
-			"deployment_id": deploymentID,
-			"title":         title,
-			"description":   description,
-			"severity":      severity,
-			"status":        status,
-			"started_at":    startedAt,
-		})
-	}
-
-	c.JSON(http.StatusOK, incidents)
-}
-
-// Get single incident
-func (s *Server) handleGetIncident(c *gin.Context) {
-	incidentID := c.Param("incidentId")
-
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
-	defer cancel()
-
-	query := `
-		SELECT id, deployment_id, title, description, severity, status, started_at
-		FROM incidents
-		WHERE id = $1
-	`
-
-	var incident gin.H
-	var id, deploymentID, title, description, severity, status string
-	var startedAt time.Time
-
-	err := s.orchestrator.GetDB().QueryRowContext(ctx, query, incidentID).Scan(
-		&id, &deploymentID, &title, &description, &severity, &status, &startedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
-		return
-	} else if err != nil {
-		logger.Error("Failed to query incident", zap.String("incident_id", incidentID), logger.Err(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve incident"})
-		return
-	}
-
-	incident = gin.H{
-		"id":            id,
-		"deployment_id": deploymentID,
-		"title":         title,
-		"description":   description,
-		"severity":      severity,
-		"status":        status,
-		"started_at":    startedAt,
-	}
-
-	c.JSON(http.StatusOK, incident)
-}
-
 // WebSocket for real-time metrics
 func (s *Server) handleWebSocketMetrics(c *gin.Context) {
-	deploymentID := c.Param("deploymentId")
-
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
-	if err != nil {
-		logger.Error("Failed to upgrade WebSocket connection for metrics", logger.Err(err))
-		return
-	}
-	defer conn.Close()
-
-	logger.Info("WebSocket metrics stream started", zap.String("deployment_id", deploymentID))
-
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			key := "metrics:" + deploymentID + ":latest"
-			metrics, err := s.orchestrator.GetRedis().HGetAll(ctx, key).Result()
-			if err != nil {
-				logger.Error("Failed to get metrics from Redis",
-					zap.String("deployment_id", deploymentID),
-					logger.Err(err))
-				continue
-			}
-
-			if err := conn.WriteJSON(metrics); err != nil {
-				logger.Error("Failed to write metrics to WebSocket", logger.Err(err))
-				return
-			}
-		}
-	}
+	s.serveStream(c, streamMetrics)
 }
 
 // WebSocket for real-time logs
 func (s *Server) handleWebSocketLogs(c *gin.Context) {
-	deploymentID := c.Param("deploymentId")
-
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
-	if err != nil {
-		logger.Error("Failed to upgrade WebSocket connection for logs", logger.Err(err))
-		return
-	}
-	defer conn.Close()
-
-	logger.Info("WebSocket logs stream started", zap.String("deployment_id", deploymentID))
-
-	// Keep connection alive with ping/pong
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			if err := conn.WriteMessage(websocket.PingMessage, []byte{}); err != nil {
-				logger.Error("WebSocket ping failed", logger.Err(err))
-				return
-			}
-		}
-	}
+	s.serveStream(c, streamLogs)
 }
 
 // Middleware
@@ -947,9 +951,14 @@ func (s *Server) loggingMiddleware() gin.HandlerFunc {
 
 func (s *Server) corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		// Credentials (the session cookie) are only accepted from the allowed origins
+		if origin := c.GetHeader("Origin"); origin != "" && s.originAllowed(origin) {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		c.Writer.Header().Add("Vary", "Origin")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
 		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Type")
 		c.Writer.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate, proxy-revalidate")
 		c.Writer.Header().Set("Connection", "keep-alive")
@@ -1037,6 +1046,7 @@ type ProjectAlert struct {
 	Message      string    `json:"message"`
 	Timestamp    time.Time `json:"timestamp"`
 	Status       string    `json:"status"`
+	Occurrences  int       `json:"occurrences"` // Times the condition was seen while the alert stayed open
 }
 
 func (s *Server) handleGetProjectMetrics(c *gin.Context) {
@@ -1321,13 +1331,35 @@ func (s *Server) getDeploymentTimeSeriesData(ctx context.Context, deploymentID s
 		  AND timestamp >= NOW() - $2::interval
 		ORDER BY timestamp ASC
 	`
+	args := []interface{}{deploymentID, timeRangeSQL}
+
+	// Longer ranges outlive raw retention, so they read the rollup averages
+	end := time.Now()
+	resolution := s.orchestrator.GetMetricsRollup().ResolutionFor(ctx, deploymentID, end.Add(-getTimeRangeDuration(timeRange)), end)
+	if resolution != metrics.ResolutionRaw {
+		query = `
+			SELECT 
+				EXTRACT(EPOCH FROM bucket_start) * 1000 as time_ms,
+				COALESCE(cpu_avg, 0) as cpu_usage,
+				COALESCE(memory_avg, 0) as memory_usage,
+				COALESCE(network_rx, 0) as network_rx,
+				COALESCE(network_tx, 0) as network_tx
+			FROM container_metrics_rollups
+			WHERE deployment_id = $1 
+			  AND resolution = $3
+			  AND bucket_start >= NOW() - $2::interval
+			ORDER BY bucket_start ASC
+		`
+		args = append(args, string(resolution))
+	}
 
 	logger.Info("Querying time series data",
 		zap.String("deployment_id", deploymentID),
 		zap.String("time_range", timeRange),
-		zap.String("interval", timeRangeSQL))
+		zap.String("interval", timeRangeSQL),
+		zap.String("resolution", string(resolution)))
 
-	rows, err := s.orchestrator.GetDB().QueryContext(ctx, query, deploymentID, timeRangeSQL)
+	rows, err := s.orchestrator.GetDB().QueryContext(ctx, query, args...)
 	if err != nil {
 		logger.Error("Failed to query time series", zap.String("deployment_id", deploymentID), logger.Err(err))
 		return nil, fmt.Errorf("failed to query time series data: %w", err)
@@ -1388,6 +1420,9 @@ type ErrorTypeStat struct {
 func (s *Server) getHTTPMetrics(ctx context.Context, deploymentID string, timeRange string) (*HTTPMetricsData, error) {
 	interval := getTimeRangeInterval(timeRange)
 
+	// Minute rows are only kept for days, so long ranges read the hourly or daily rollups
+	httpSource := metrics.HTTPMetricsSource(metrics.HTTPResolutionFor(getTimeRangeDuration(timeRange)))
+
 	data := &HTTPMetricsData{}
 
 	// Get latency distribution
@@ -1402,7 +1437,7 @@ func (s *Server) getHTTPMetrics(ctx context.Context, deploymentID string, timeRa
 				ELSE '>1s'
 			END as bucket,
 			SUM(request_count) as count
-		FROM http_metrics_minute
+		FROM ` + httpSource + `
 		WHERE deployment_id = $1 
 		  AND timestamp_minute >= NOW() - $2::interval
 		GROUP BY 1
@@ -1478,7 +1513,7 @@ func (s *Server) getHTTPMetrics(ctx context.Context, deploymentID string, timeRa
 			EXTRACT(DOW FROM timestamp_minute)::int as day,
 			EXTRACT(HOUR FROM timestamp_minute)::int as hour,
 			SUM(request_count) as value
-		FROM http_metrics_minute
+		FROM ` + httpSource + `
 		WHERE deployment_id = $1 
 		  AND timestamp_minute >= NOW() - $2::interval
 		GROUP BY 1, 2
@@ -1502,7 +1537,7 @@ func (s *Server) getHTTPMetrics(ctx context.Context, deploymentID string, timeRa
 			COALESCE(requests_per_minute, 0) as rpm,
 			COALESCE(latency_avg, 0) as latency,
 			COALESCE(error_rate, 0) as error_rate
-		FROM http_metrics_minute
+		FROM ` + httpSource + `
 		WHERE deployment_id = $1 
 		  AND timestamp_minute >= NOW() - $2::interval
 		ORDER BY timestamp_minute ASC
@@ -1535,7 +1570,7 @@ func (s *Server) getHTTPMetrics(ctx context.Context, deploymentID string, timeRa
 				ELSE 'low'
 			END as severity,
 			SUM(request_count_4xx + request_count_5xx) as count
-		FROM http_metrics_minute
+		FROM ` + httpSource + `
 		WHERE deployment_id = $1 
 		  AND timestamp_minute >= NOW() - $2::interval
 		  AND (request_count_4xx > 0 OR request_count_5xx > 0)
@@ -1599,6 +1634,21 @@ func getTimeRangeInterval(timeRange string) string {
 	}
 }
 
+func getTimeRangeDuration(timeRange string) time.Duration {
+	switch timeRange {
+	case "1h":
+		return time.Hour
+	case "6h":
+		return 6 * time.Hour
+	case "7d":
+		return 7 * 24 * time.Hour
+	case "30d":
+		return 30 * 24 * time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
+
 func (s *Server) handleProjectMetricsSSE(c *gin.Context) {
 	projectID := c.Param("projectId")
 	timeRange := c.DefaultQuery("timeRange", "24h")
@@ -1776,7 +1826,11 @@ func (s *Server) handleGetProjectAlerts(c *gin.Context) {
 	defer cancel()
 
 	query := `
-		SELECT da.id, da.deployment_id, da.severity, da.title, da.status, da.triggered_at
+		SELECT da.id, da.deployment_id, da.severity, da.alert_message,
+		       CASE WHEN da.suppressed_by IS NOT NULL THEN 'silenced'
+		            WHEN da.acknowledged THEN 'acknowledged'
+		            ELSE 'active' END,
+		       da.created_at, da.occurrence_count
 		FROM deployment_alerts da
 		JOIN deployments d ON d.id = da.deployment_id
 		WHERE d.project_id = $1 
@@ -1788,7 +1842,7 @@ func (s *Server) handleGetProjectAlerts(c *gin.Context) {
 				WHEN 'medium' THEN 3 
 				ELSE 4 
 			END,
-			da.triggered_at DESC
+			da.created_at DESC
 		LIMIT 50
 	`
 
@@ -1804,7 +1858,7 @@ func (s *Server) handleGetProjectAlerts(c *gin.Context) {
 	for rows.Next() {
 		var alert ProjectAlert
 		var title string
-		if err := rows.Scan(&alert.ID, &alert.DeploymentID, &alert.Severity, &title, &alert.Status, &alert.Timestamp); err != nil {
+		if err := rows.Scan(&alert.ID, &alert.DeploymentID, &alert.Severity, &title, &alert.Status, &alert.Timestamp, &alert.Occurrences); err != nil {
 			continue
 		}
 		alert.Message = title
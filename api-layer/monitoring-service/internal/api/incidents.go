@@ -0,0 +1,240 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"monitoring-service/internal/monitoring"
+	"monitoring-service/pkg/logger"
+	"monitoring-service/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func incidentJSON(i *models.Incident) gin.H {
+	h := gin.H{
+		"id":              i.ID,
+		"project_id":      i.ProjectID,
+		"deployment_id":   i.DeploymentID,
+		"title":           i.Title,
+		"description":     i.Description,
+		"severity":        i.Severity,
+		"status":          i.Status,
+		"started_at":      i.StartedAt,
+		"detected_at":     i.DetectedAt,
+		"acknowledged_at": i.AcknowledgedAt,
+		"mitigated_at":    i.MitigatedAt,
+		"resolved_at":     i.ResolvedAt,
+		"last_alert_at":   i.LastAlertAt,
+		"alert_count":     i.AlertCount,
+		"root_cause":      i.RootCause,
+		"resolution":      i.Resolution,
+		"impact_summary":  i.ImpactSummary,
+	}
+	if d := i.TimeToAcknowledge(); d != nil {
+		h["time_to_acknowledge_seconds"] = int64(d.Seconds())
+	}
+	if d := i.TimeToResolve(); d != nil {
+		h["time_to_resolve_seconds"] = int64(d.Seconds())
+	}
+	if i.Timeline != nil {
+		timeline := make([]gin.H, 0, len(i.Timeline))
+		for _, ev := range i.Timeline {
+			timeline = append(timeline, gin.H{
+				"timestamp":   ev.Timestamp,
+				"event_type":  ev.EventType,
+				"description": ev.Description,
+				"user":        ev.User,
+				"source":      ev.Source,
+			})
+		}
+		h["timeline"] = timeline
+	}
+	return h
+}
+
+func (s *Server) respondIncidentError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, monitoring.ErrIncidentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Incident not found"})
+	case errors.Is(err, monitoring.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error(message, logger.String("incident_id", c.Param("incidentId")), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// Get incidents
+func (s *Server) handleGetIncidents(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	projectID := c.Query("projectId")
+	if projectID != "" && !isValidID(projectID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID format"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	incidents, err := s.orchestrator.GetIncidentManager().ListIncidents(ctx, projectID, c.Query("status"), limit)
+	if err != nil {
+		logger.Error("Failed to query incidents", logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve incidents"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(incidents))
+	for _, i := range incidents {
+		result = append(result, incidentJSON(i))
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Get single incident with its timeline
+func (s *Server) handleGetIncident(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	incident, err := s.orchestrator.GetIncidentManager().GetIncidentWithTimeline(ctx, c.Param("incidentId"))
+	if err != nil {
+		s.respondIncidentError(c, err, "Failed to retrieve incident")
+		return
+	}
+
+	c.JSON(http.StatusOK, incidentJSON(incident))
+}
+
+// MTTA/MTTR over the last N days
+func (s *Server) handleGetIncidentStats(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	projectID := c.Query("projectId")
+	if projectID != "" && !isValidID(projectID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID format"})
+		return
+	}
+
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if days <= 0 || days > 365 {
+		days = 30
+	}
+
+	stats, err := s.orchestrator.GetIncidentManager().GetIncidentStats(ctx, projectID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		logger.Error("Failed to compute incident stats", logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute incident stats"})
+		return
+	}
+
+	result := gin.H{
+		"period_days":  days,
+		"total":        stats.Total,
+		"open":         stats.Open,
+		"resolved":     stats.Resolved,
+		"mtta_seconds": nil,
+		"mttr_seconds": nil,
+	}
+	if stats.MeanTimeToAck != nil {
+		result["mtta_seconds"] = int64(stats.MeanTimeToAck.Seconds())
+	}
+	if stats.MeanTimeToResolve != nil {
+		result["mttr_seconds"] = int64(stats.MeanTimeToResolve.Seconds())
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (s *Server) handleTransitionIncident(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			RootCause  string `json:"rootCause"`
+			Resolution string `json:"resolution"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		incident, err := s.orchestrator.GetIncidentManager().Transition(ctx, c.Param("incidentId"), status, principal(c).UserID, req.RootCause, req.Resolution)
+		if err != nil {
+			s.respondIncidentError(c, err, "Failed to update incident")
+			return
+		}
+
+		c.JSON(http.StatusOK, incidentJSON(incident))
+	}
+}
+
+func (s *Server) handleAddIncidentNote(c *gin.Context) {
+	var req struct {
+		Message string `json:"message" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "message is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetIncidentManager().AddNote(ctx, c.Param("incidentId"), principal(c).UserID, req.Message); err != nil {
+		s.respondIncidentError(c, err, "Failed to add note")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Note added"})
+}
+
+// Postmortem draft for the AI agent or the incident owner to fill in
+func (s *Server) handleGetPostmortem(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	draft, err := s.orchestrator.GetIncidentManager().GetPostmortemDraft(ctx, c.Param("incidentId"))
+	if err != nil {
+		s.respondIncidentError(c, err, "Failed to build postmortem")
+		return
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
+
+func (s *Server) handleSavePostmortem(c *gin.Context) {
+	var req struct {
+		Sections monitoring.PostmortemSections `json:"sections"`
+		UserID   string                        `json:"userId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if req.UserID != "" && !isValidID(req.UserID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetIncidentManager().SavePostmortem(ctx, c.Param("incidentId"), req.UserID, req.Sections); err != nil {
+		s.respondIncidentError(c, err, "Failed to save postmortem")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Postmortem saved"})
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"monitoring-service/internal/monitoring"
+	"monitoring-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) respondIssueError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, monitoring.ErrIssueNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Issue not found"})
+	default:
+		logger.Error(message, logger.String("project_id", c.Param("projectId")), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func (s *Server) validateIssueID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isValidID(c.Param("issueId")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid issue ID format"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func isValidIssueStatus(status string) bool {
+	switch status {
+	case monitoring.IssueStatusUnresolved, monitoring.IssueStatusResolved, monitoring.IssueStatusIgnored:
+		return true
+	}
+	return false
+}
+
+func (s *Server) handleGetIssues(c *gin.Context) {
+	filter := monitoring.IssueFilter{
+		Status:       c.Query("status"),
+		Environment:  c.Query("environment"),
+		DeploymentID: c.Query("deployment_id"),
+	}
+	if filter.Status != "" && !isValidIssueStatus(filter.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be unresolved, resolved or ignored"})
+		return
+	}
+	if filter.DeploymentID != "" && !isValidID(filter.DeploymentID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deployment ID format"})
+		return
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	issues, err := s.orchestrator.GetErrorTracker().ListIssues(ctx, c.Param("projectId"), filter)
+	if err != nil {
+		s.respondIssueError(c, err, "Failed to retrieve issues")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"issues": issues})
+}
+
+func (s *Server) handleGetIssue(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	issue, err := s.orchestrator.GetErrorTracker().GetIssue(ctx, c.Param("projectId"), c.Param("issueId"))
+	if err != nil {
+		s.respondIssueError(c, err, "Failed to retrieve issue")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"issue": issue})
+}
+
+// Resolves, ignores or reopens an issue. A resolved issue that occurs on a deployment
+// created afterwards reopens as a regression.
+func (s *Server) handleUpdateIssueStatus(c *gin.Context) {
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if !isValidIssueStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be unresolved, resolved or ignored"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	issue, err := s.orchestrator.GetErrorTracker().UpdateIssueStatus(ctx, c.Param("projectId"), c.Param("issueId"), req.Status, principal(c).UserID)
+	if err != nil {
+		s.respondIssueError(c, err, "Failed to update issue")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"issue": issue})
+}
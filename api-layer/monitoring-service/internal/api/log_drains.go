@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"monitoring-service/internal/logdrain"
+	"monitoring-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) respondLogDrainError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, logdrain.ErrDrainNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Log drain not found"})
+	case errors.Is(err, logdrain.ErrDrainNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error(message, logger.String("project_id", c.Param("projectId")), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func (s *Server) validateDrainID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isValidID(c.Param("drainId")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid drain ID format"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func (s *Server) handleGetLogDrains(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	drains, err := s.orchestrator.GetLogDrains().ListDrains(ctx, c.Param("projectId"))
+	if err != nil {
+		s.respondLogDrainError(c, err, "Failed to retrieve log drains")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"drains": drains})
+}
+
+func (s *Server) handleCreateLogDrain(c *gin.Context) {
+	drain := logdrain.Drain{Enabled: true}
+	if err := c.ShouldBindJSON(&drain); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := drain.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	created, err := s.orchestrator.GetLogDrains().CreateDrain(ctx, c.Param("projectId"), &drain)
+	if err != nil {
+		s.respondLogDrainError(c, err, "Failed to create log drain")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"drain": created})
+}
+
+func (s *Server) handleUpdateLogDrain(c *gin.Context) {
+	drain := logdrain.Drain{Enabled: true}
+	if err := c.ShouldBindJSON(&drain); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := drain.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	updated, err := s.orchestrator.GetLogDrains().UpdateDrain(ctx, c.Param("projectId"), c.Param("drainId"), &drain)
+	if err != nil {
+		s.respondLogDrainError(c, err, "Failed to update log drain")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"drain": updated})
+}
+
+func (s *Server) handleDeleteLogDrain(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetLogDrains().DeleteDrain(ctx, c.Param("projectId"), c.Param("drainId")); err != nil {
+		s.respondLogDrainError(c, err, "Failed to delete log drain")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Log drain deleted"})
+}
+
+// Sends a test record to the drain. A sink that rejects it is reported in the body,
+// not as a failure of this request.
+func (s *Server) handleTestLogDrain(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	err := s.orchestrator.GetLogDrains().TestDrain(ctx, c.Param("projectId"), c.Param("drainId"))
+	if errors.Is(err, logdrain.ErrDrainNotFound) {
+		s.respondLogDrainError(c, err, "Failed to test log drain")
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"delivered": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"delivered": true})
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/AALXX/Obtura/shared/platformlog"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Platform log events are produced with the shared platformlog module, which stamps
+// them with its SchemaVersion. Events from producers built before the version existed
+// (version 0) are upgraded here; events from a newer module than this service was
+// built with are rejected, since their fields can mean something else.
+
+var errUnsupportedSchema = errors.New("unsupported schema_version")
+
+// decodeLogIngest returns the events of an ingest body: {"events": [...]}, or a bare
+// array as sent by version 0 Go clients
+func decodeLogIngest(body []byte) ([]json.RawMessage, error) {
+	body = bytes.TrimSpace(body)
+	var events []json.RawMessage
+	if len(body) > 0 && body[0] == '[' {
+		err := json.Unmarshal(body, &events)
+		return events, err
+	}
+
+	var req struct {
+		Events []json.RawMessage `json:"events"`
+	}
+	err := json.Unmarshal(body, &req)
+	return req.Events, err
+}
+
+// parseLogIngest upgrades the events of an ingest body, returning the rejected ones by
+// index with the reason
+func parseLogIngest(body []byte) ([]PlatformLogEvent, []gin.H, error) {
+	raw, err := decodeLogIngest(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events := make([]PlatformLogEvent, 0, len(raw))
+	var rejected []gin.H
+	for i, r := range raw {
+		event, err := upgradeLogEvent(r)
+		if err != nil {
+			rejected = append(rejected, gin.H{"index": i, "error": err.Error()})
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, rejected, nil
+}
+
+// upgradeLogEvent decodes an event of any supported schema version into the current one
+func upgradeLogEvent(raw json.RawMessage) (PlatformLogEvent, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return PlatformLogEvent{}, fmt.Errorf("invalid event: %w", err)
+	}
+
+	var version int
+	if v, ok := fields["schema_version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return PlatformLogEvent{}, fmt.Errorf("invalid schema_version: %w", err)
+		}
+	}
+	if version < 0 || version > platformlog.SchemaVersion {
+		return PlatformLogEvent{}, fmt.Errorf("%w %d, this service accepts up to %d", errUnsupportedSchema, version, platformlog.SchemaVersion)
+	}
+
+	if version == 0 {
+		// The Node SDK sent camelCase keys
+		fields = snakeCaseKeys(fields)
+		raw, _ = json.Marshal(fields)
+	}
+
+	var event PlatformLogEvent
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return PlatformLogEvent{}, fmt.Errorf("invalid event: %w", err)
+	}
+
+	if version == 0 {
+		event.upgradeFromV0()
+	}
+	event.SchemaVersion = platformlog.SchemaVersion
+
+	if uuid.Validate(event.ResourceID) != nil {
+		return PlatformLogEvent{}, fmt.Errorf("resource_id %q is not a UUID", event.ResourceID)
+	}
+	if event.EventType == "" || event.ResourceType == "" {
+		return PlatformLogEvent{}, errors.New("event_type and resource_type are required")
+	}
+	if event.EventTimestamp.IsZero() {
+		event.EventTimestamp = time.Now().UTC()
+	}
+	return event, nil
+}
+
+// upgradeFromV0 fills what version 0 producers left out: their IDs were timestamps
+// rather than UUIDs, and some only put the project and company in the metadata
+func (e *PlatformLogEvent) upgradeFromV0() {
+	if uuid.Validate(e.ID) != nil {
+		e.ID = uuid.NewString()
+	}
+	if e.Metadata != nil {
+		e.Metadata = snakeCaseMetadata(e.Metadata)
+		if id, ok := e.Metadata["project_id"].(string); ok && e.ProjectID == "" {
+			e.ProjectID = id
+		}
+		if id, ok := e.Metadata["company_id"].(string); ok && e.CompanyID == "" {
+			e.CompanyID = id
+		}
+	}
+	if e.Severity == "" {
+		e.Severity = "info"
+	}
+}
+
+func snakeCaseKeys(fields map[string]json.RawMessage) map[string]json.RawMessage {
+	out := make(map[string]json.RawMessage, len(fields))
+	for k, v := range fields {
+		out[snakeCase(k)] = v
+	}
+	return out
+}
+
+func snakeCaseMetadata(metadata map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		out[snakeCase(k)] = v
+	}
+	return out
+}
+
+// snakeCase converts camelCase keys, e.g. "eventTimestamp" to "event_timestamp"
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
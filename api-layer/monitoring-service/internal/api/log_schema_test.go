@@ -0,0 +1,314 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AALXX/Obtura/shared/platformlog"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	testResourceID = "6f1c2b7e-1d2a-4c3b-9e8f-0a1b2c3d4e5f"
+	testProjectID  = "0b9e5a34-7c21-4f0e-8d6a-2f3e4d5c6b7a"
+	testCompanyID  = "9d8c7b6a-5f4e-4d3c-8b2a-1f0e9d8c7b6a"
+)
+
+// Events as each producer generation sent them
+var (
+	// Go module before schema_version: snake_case, timestamp IDs, sent as a bare array
+	goV0Event = `{
+		"id": "1712345678901234567-42",
+		"event_type": "deployment",
+		"event_subtype": "deploy_start",
+		"resource_type": "deployment",
+		"resource_id": "` + testResourceID + `",
+		"severity": "info",
+		"message": "Deployment to production started",
+		"metadata": {"project_id": "` + testProjectID + `", "company_id": "` + testCompanyID + `"},
+		"source_service": "deploy-service",
+		"event_timestamp": "2026-01-02T03:04:05Z"
+	}`
+
+	// Node SDK before schema_version: camelCase keys and metadata
+	tsV0Event = `{
+		"id": "1712345678901-abc123def",
+		"eventType": "build",
+		"eventSubtype": "build_start",
+		"resourceType": "build",
+		"resourceId": "` + testResourceID + `",
+		"projectId": "` + testProjectID + `",
+		"severity": "info",
+		"message": "Build #3 started for branch main",
+		"metadata": {"buildNumber": 3, "companyId": "` + testCompanyID + `"},
+		"sourceService": "core-api",
+		"eventTimestamp": "2026-01-02T03:04:05.000Z"
+	}`
+
+	currentEvent = `{
+		"schema_version": 1,
+		"id": "3e1f0c9a-8b7d-4e6f-9a5b-4c3d2e1f0a9b",
+		"event_type": "container",
+		"event_subtype": "container_log",
+		"resource_type": "deployment",
+		"resource_id": "` + testResourceID + `",
+		"project_id": "` + testProjectID + `",
+		"company_id": "` + testCompanyID + `",
+		"container_id": "abc123",
+		"severity": "error",
+		"message": "panic: runtime error",
+		"source_service": "deploy-service",
+		"event_timestamp": "2026-01-02T03:04:05Z"
+	}`
+)
+
+func TestUpgradeLogEvent(t *testing.T) {
+	timestamp := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name string
+		raw  string
+		want PlatformLogEvent // ID is only checked when set
+	}{
+		{
+			name: "version 0 go client",
+			raw:  goV0Event,
+			want: PlatformLogEvent{
+				EventType: "deployment", EventSubtype: "deploy_start", ResourceType: "deployment",
+				ResourceID: testResourceID, ProjectID: testProjectID, CompanyID: testCompanyID,
+				Severity: "info", Message: "Deployment to production started",
+				SourceService: "deploy-service", EventTimestamp: timestamp,
+			},
+		},
+		{
+			name: "version 0 node sdk",
+			raw:  tsV0Event,
+			want: PlatformLogEvent{
+				EventType: "build", EventSubtype: "build_start", ResourceType: "build",
+				ResourceID: testResourceID, ProjectID: testProjectID, CompanyID: testCompanyID,
+				Severity: "info", Message: "Build #3 started for branch main",
+				SourceService: "core-api", EventTimestamp: timestamp,
+			},
+		},
+		{
+			name: "version 1",
+			raw:  currentEvent,
+			want: PlatformLogEvent{
+				ID: "3e1f0c9a-8b7d-4e6f-9a5b-4c3d2e1f0a9b", EventType: "container", EventSubtype: "container_log",
+				ResourceType: "deployment", ResourceID: testResourceID, ProjectID: testProjectID, CompanyID: testCompanyID,
+				ContainerID: "abc123", Severity: "error", Message: "panic: runtime error",
+				SourceService: "deploy-service", EventTimestamp: timestamp,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := upgradeLogEvent(json.RawMessage(tc.raw))
+			if err != nil {
+				t.Fatalf("rejected: %v", err)
+			}
+			checkUpgradedEvent(t, got, tc.want)
+
+			// The upgraded event is a current one: it round-trips unchanged
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := upgradeLogEvent(data)
+			if err != nil {
+				t.Fatalf("upgraded event rejected: %v", err)
+			}
+			tc.want.ID = got.ID
+			checkUpgradedEvent(t, again, tc.want)
+		})
+	}
+}
+
+func checkUpgradedEvent(t *testing.T, got, want PlatformLogEvent) {
+	t.Helper()
+
+	if got.SchemaVersion != platformlog.SchemaVersion {
+		t.Errorf("schema_version %d, want %d", got.SchemaVersion, platformlog.SchemaVersion)
+	}
+	if uuid.Validate(got.ID) != nil || (want.ID != "" && got.ID != want.ID) {
+		t.Errorf("id %q, want %q", got.ID, want.ID)
+	}
+	for _, f := range []struct{ name, got, want string }{
+		{"event_type", got.EventType, want.EventType},
+		{"event_subtype", got.EventSubtype, want.EventSubtype},
+		{"resource_type", got.ResourceType, want.ResourceType},
+		{"resource_id", got.ResourceID, want.ResourceID},
+		{"project_id", got.ProjectID, want.ProjectID},
+		{"company_id", got.CompanyID, want.CompanyID},
+		{"container_id", got.ContainerID, want.ContainerID},
+		{"severity", got.Severity, want.Severity},
+		{"message", got.Message, want.Message},
+		{"source_service", got.SourceService, want.SourceService},
+	} {
+		if f.got != f.want {
+			t.Errorf("%s %q, want %q", f.name, f.got, f.want)
+		}
+	}
+	if !got.EventTimestamp.Equal(want.EventTimestamp) {
+		t.Errorf("event_timestamp %v, want %v", got.EventTimestamp, want.EventTimestamp)
+	}
+}
+
+func TestUpgradeLogEventRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		raw     string
+		wantErr error
+	}{
+		{"newer version", strings.Replace(currentEvent, `"schema_version": 1`, `"schema_version": 2`, 1), errUnsupportedSchema},
+		{"negative version", strings.Replace(currentEvent, `"schema_version": 1`, `"schema_version": -1`, 1), errUnsupportedSchema},
+		{"version not a number", strings.Replace(currentEvent, `"schema_version": 1`, `"schema_version": "1"`, 1), nil},
+		{"resource id not a uuid", strings.Replace(currentEvent, testResourceID, "orchestrator", 1), nil},
+		{"version 0 resource id not a uuid", strings.Replace(goV0Event, testResourceID, "", 1), nil},
+		{"missing event type", strings.Replace(currentEvent, `"event_type": "container"`, `"event_type": ""`, 1), nil},
+		{"not an object", `["event"]`, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := upgradeLogEvent(json.RawMessage(tc.raw))
+			if err == nil {
+				t.Fatal("accepted")
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Fatalf("error %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// recordedIngest is what the shared module's HTTP transport posts
+func recordedIngest(t *testing.T, log func(*platformlog.Client) error) []byte {
+	t.Helper()
+
+	var mu sync.Mutex
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path != platformlog.IngestPath {
+			t.Errorf("posted to %s, want %s", r.URL.Path, platformlog.IngestPath)
+		}
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	client := platformlog.NewClient("test-service", platformlog.NewHTTPTransport(server.URL, "token"))
+	if err := log(client); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if body == nil {
+		t.Fatal("client posted nothing")
+	}
+	return body
+}
+
+func TestParseLogIngestAcceptsProducerVersions(t *testing.T) {
+	current := recordedIngest(t, func(c *platformlog.Client) error {
+		if err := c.DeployStart(context.Background(), testResourceID, testProjectID, testCompanyID, "production", "blue_green"); err != nil {
+			return err
+		}
+		return c.SystemEvent(context.Background(), "orchestrator", "startup", "Service started")
+	})
+
+	for _, tc := range []struct {
+		name         string
+		body         string
+		wantAccepted int
+		wantRejected []int
+	}{
+		{"current go client", string(current), 2, nil},
+		{"version 0 go client", "[" + goV0Event + "]", 1, nil},
+		{"version 0 node sdk", `{"events": [` + tsV0Event + `]}`, 1, nil},
+		{"mixed versions", `{"events": [` + goV0Event + `, ` + currentEvent + `, ` + tsV0Event + `]}`, 3, nil},
+		{
+			"newer version rejected per event",
+			`{"events": [` + currentEvent + `, ` + strings.Replace(currentEvent, `"schema_version": 1`, `"schema_version": 2`, 1) + `]}`,
+			1, []int{1},
+		},
+		{"empty", `{"events": []}`, 0, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			events, rejected, err := parseLogIngest([]byte(tc.body))
+			if err != nil {
+				t.Fatalf("body rejected: %v", err)
+			}
+			if len(events) != tc.wantAccepted {
+				t.Fatalf("accepted %d events, want %d (rejected %v)", len(events), tc.wantAccepted, rejected)
+			}
+			if len(rejected) != len(tc.wantRejected) {
+				t.Fatalf("rejected %v, want indexes %v", rejected, tc.wantRejected)
+			}
+			for i, r := range rejected {
+				if r["index"] != tc.wantRejected[i] {
+					t.Fatalf("rejected %v, want indexes %v", rejected, tc.wantRejected)
+				}
+			}
+		})
+	}
+
+	events, _, _ := parseLogIngest(current)
+	deploy := events[0]
+	if deploy.ResourceID != testResourceID || deploy.ProjectID != testProjectID || deploy.CompanyID != testCompanyID ||
+		deploy.SourceService != "test-service" || deploy.EventSubtype != string(platformlog.SubtypeDeployStart) {
+		t.Fatalf("current client event decoded as %+v", deploy)
+	}
+	if system := events[1]; system.ResourceID != platformlog.SystemResourceID || system.Metadata["component"] != "orchestrator" {
+		t.Fatalf("system event decoded as %+v", system)
+	}
+}
+
+func TestHandleIngestLogsWithoutStorableEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"not json", `{"events": [`, http.StatusBadRequest},
+		{"empty batch", `{"events": []}`, http.StatusOK},
+		{"empty version 0 batch", `[]`, http.StatusOK},
+		// 422 tells producers not to retry the batch
+		{"only newer versions", `{"events": [` + strings.Replace(currentEvent, `"schema_version": 1`, `"schema_version": 2`, 1) + `]}`, http.StatusUnprocessableEntity},
+		{"only invalid events", `[{"event_type": "build"}]`, http.StatusUnprocessableEntity},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, platformlog.IngestPath, strings.NewReader(tc.body))
+
+			// No event reaches storage, so the server needs no database
+			(&Server{}).handleIngestLogs(c)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tc.wantStatus, w.Body.String())
+			}
+			if tc.wantStatus == http.StatusUnprocessableEntity {
+				var resp struct {
+					SchemaVersion int `json:"schema_version"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.SchemaVersion != platformlog.SchemaVersion {
+					t.Fatalf("422 does not name the accepted schema version: %s", w.Body.String())
+				}
+			}
+		})
+	}
+}
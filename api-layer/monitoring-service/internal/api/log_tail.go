@@ -0,0 +1,404 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"monitoring-service/internal/monitoring"
+	"monitoring-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Lines per second sent to a tail client; it can ask for fewer with ?rate=
+	tailDefaultRate = 100
+	tailMaxRate     = 1000
+
+	// How often a tail looks for new deployments and containers of its project
+	tailRefreshInterval = 15 * time.Second
+	// How often lines dropped since the last line are reported when no line follows
+	tailDropReportInterval = time.Second
+
+	tailMaxQueryLength = 512
+
+	// Queued messages above which tail lines are dropped and counted rather than
+	// evicting the client as too slow
+	tailSendHighWater = wsSendBuffer * 3 / 4
+)
+
+// tailFilter is applied server side to every line before it counts against the rate
+type tailFilter struct {
+	Level        string   `json:"level,omitempty"` // Minimum level
+	Query        string   `json:"query,omitempty"` // Regular expression on the message
+	Environments []string `json:"environments,omitempty"`
+
+	minLevel     int
+	regex        *regexp.Regexp
+	environments map[string]bool
+}
+
+func newTailFilter(level, query string, environments []string) (tailFilter, error) {
+	f := tailFilter{Level: strings.ToLower(level), Query: query}
+	if f.Level != "" {
+		if !monitoring.IsLogLevel(f.Level) {
+			return f, errors.New("level must be one of debug, info, warning, error, fatal")
+		}
+		f.minLevel = monitoring.LogLevelRank(f.Level)
+	}
+	if query != "" {
+		if len(query) > tailMaxQueryLength {
+			return f, fmt.Errorf("query must be at most %d characters", tailMaxQueryLength)
+		}
+		regex, err := regexp.Compile(query)
+		if err != nil {
+			return f, fmt.Errorf("invalid query: %v", err)
+		}
+		f.regex = regex
+	}
+	for _, env := range environments {
+		if env = strings.TrimSpace(env); env != "" {
+			if f.environments == nil {
+				f.environments = make(map[string]bool)
+			}
+			f.environments[env] = true
+			f.Environments = append(f.Environments, env)
+		}
+	}
+	return f, nil
+}
+
+func (f *tailFilter) match(entry *monitoring.LogEntry, environment string) bool {
+	if f.environments != nil && !f.environments[environment] {
+		return false
+	}
+	if monitoring.LogLevelRank(entry.Level) < f.minLevel {
+		return false
+	}
+	return f.regex == nil || f.regex.MatchString(entry.Message)
+}
+
+// tailLine is a log line with its source, for the client to label and color
+type tailLine struct {
+	monitoring.LogEntry
+	Environment   string `json:"environment"`
+	ContainerName string `json:"container_name,omitempty"`
+	Color         string `json:"color"`
+}
+
+// logTail follows every container of a project for one WebSocket client. The hub
+// hands it the lines of the project's deployments; it filters them, holds them back
+// while paused and rate limits them, reporting how many it dropped.
+type logTail struct {
+	projectID string
+	client    *wsClient
+	rate      float64
+
+	refreshMu sync.Mutex // Serializes refresh
+
+	mu          sync.Mutex
+	filter      tailFilter
+	deployments map[string]monitoring.LogSource // Environment of each followed deployment
+	containers  map[string]monitoring.LogSource
+	paused      bool
+	skipped     int // Matching lines while paused
+	dropped     int // Rate limited or client behind, not reported yet
+	tokens      float64
+	refilled    time.Time
+}
+
+// offer takes a line the hub received on one of the tail's channels
+func (t *logTail) offer(payload string) {
+	var entry monitoring.LogEntry
+	if err := json.Unmarshal([]byte(payload), &entry); err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	line := tailLine{LogEntry: entry, Color: monitoring.SourceColor(entry.DeploymentID)}
+	if source, ok := t.containers[entry.ContainerID]; ok {
+		line.Environment, line.ContainerName, line.Color = source.Environment, source.ContainerName, source.Color
+	} else if source, ok := t.deployments[entry.DeploymentID]; ok {
+		line.Environment = source.Environment
+		if entry.ContainerID != "" {
+			line.Color = monitoring.SourceColor(entry.ContainerID)
+		}
+	}
+
+	if !t.filter.match(&entry, line.Environment) {
+		return
+	}
+	if t.paused {
+		t.skipped++
+		return
+	}
+	if !t.allow() || !t.reportDropped() || !t.deliver(wsMessage{Type: "log", DeploymentID: entry.DeploymentID, Data: line}) {
+		t.dropped++
+	}
+}
+
+// allow takes a token from the rate limiter, which holds up to a second of lines.
+// t.mu must be held.
+func (t *logTail) allow() bool {
+	now := time.Now()
+	t.tokens = min(t.tokens+now.Sub(t.refilled).Seconds()*t.rate, t.rate)
+	t.refilled = now
+	if t.tokens < 1 {
+		return false
+	}
+	t.tokens--
+	return true
+}
+
+// reportDropped sends the "N lines dropped" marker for lines dropped since the last
+// one, so it shows where the gap is. It reports false while the client cannot take it.
+// t.mu must be held.
+func (t *logTail) reportDropped() bool {
+	if t.dropped == 0 {
+		return true
+	}
+	if !t.deliver(wsMessage{Type: "dropped", Data: gin.H{"count": t.dropped}}) {
+		return false
+	}
+	t.dropped = 0
+	return true
+}
+
+// deliver queues a message unless the client is behind; unlike wsClient.enqueue it
+// never evicts the client, the caller counts the line as dropped instead
+func (t *logTail) deliver(msg wsMessage) bool {
+	if len(t.client.send) >= tailSendHighWater {
+		return false
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		logger.Error("Failed to encode log tail message", logger.Err(err))
+		return true
+	}
+	select {
+	case t.client.send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+// refresh follows the project's current deployments and tells the client when its
+// sources changed
+func (t *logTail) refresh(s *Server) error {
+	t.refreshMu.Lock()
+	defer t.refreshMu.Unlock()
+
+	t.mu.Lock()
+	environments := t.filter.Environments
+	t.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sources, err := s.orchestrator.GetLogAggregator().ProjectLogSources(ctx, t.projectID, environments)
+	if err != nil {
+		return err
+	}
+
+	deployments := make(map[string]monitoring.LogSource)
+	containers := make(map[string]monitoring.LogSource)
+	var deploymentIDs []string
+	for _, source := range sources {
+		if _, ok := deployments[source.DeploymentID]; !ok {
+			deploymentIDs = append(deploymentIDs, source.DeploymentID)
+		}
+		deployments[source.DeploymentID] = source
+		if source.ContainerID != "" {
+			containers[source.ContainerID] = source
+		}
+	}
+	if err := s.hub.follow(t.client, deploymentIDs); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	changed := !sameSources(t.containers, containers) || !sameSources(t.deployments, deployments)
+	t.deployments, t.containers = deployments, containers
+	t.mu.Unlock()
+
+	if changed {
+		if sources == nil {
+			sources = []monitoring.LogSource{}
+		}
+		t.client.sendJSON(wsMessage{Type: "sources", Data: sources})
+	}
+	return nil
+}
+
+func sameSources(a, b map[string]monitoring.LogSource) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, source := range a {
+		if other, ok := b[key]; !ok || other != source {
+			return false
+		}
+	}
+	return true
+}
+
+// run refreshes the sources and reports dropped lines no line followed until the
+// client disconnects
+func (t *logTail) run(s *Server) {
+	refresh := time.NewTicker(tailRefreshInterval)
+	defer refresh.Stop()
+	report := time.NewTicker(tailDropReportInterval)
+	defer report.Stop()
+
+	for {
+		select {
+		case <-t.client.done:
+			return
+		case <-refresh.C:
+			if err := t.refresh(s); err != nil && !errors.Is(err, errHubClosed) {
+				logger.Warn("Failed to refresh log tail sources", logger.String("project_id", t.projectID), logger.Err(err))
+			}
+		case <-report.C:
+			t.mu.Lock()
+			t.reportDropped()
+			t.mu.Unlock()
+		}
+	}
+}
+
+func (t *logTail) status(msgType string) wsMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return wsMessage{Type: msgType, Data: gin.H{
+		"project_id": t.projectID,
+		"filter":     t.filter,
+		"rate":       t.rate,
+		"paused":     t.paused,
+	}}
+}
+
+// handleProjectLogTail is `tail -f` across every running container of a project, or
+// of the environments in ?environments=. ?level= and ?q= (a regular expression) filter
+// lines server side and ?rate= lowers the lines per second. Over the socket the client
+// can send "filter" (replacing level, query and environments), "pause" and "resume".
+func (s *Server) handleProjectLogTail(c *gin.Context) {
+	var environments []string
+	if v := c.Query("environments"); v != "" {
+		environments = strings.Split(v, ",")
+	}
+	filter, err := newTailFilter(c.Query("level"), c.Query("q"), environments)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rate := tailDefaultRate
+	if v := c.Query("rate"); v != "" {
+		rate, err = strconv.Atoi(v)
+		if err != nil || rate < 1 || rate > tailMaxRate {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("rate must be between 1 and %d", tailMaxRate)})
+			return
+		}
+	}
+
+	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.Error("Failed to upgrade WebSocket connection", logger.String("stream", streamTail), logger.Err(err))
+		return
+	}
+
+	client := &wsClient{
+		conn:      conn,
+		principal: principal(c),
+		kind:      streamTail,
+		send:      make(chan []byte, wsSendBuffer),
+		done:      make(chan struct{}),
+		channels:  make(map[string]struct{}),
+	}
+	tail := &logTail{
+		projectID: c.Param("projectId"),
+		client:    client,
+		rate:      float64(rate),
+		filter:    filter,
+		tokens:    float64(rate),
+		refilled:  time.Now(),
+	}
+	client.tail = tail
+
+	if err := s.hub.register(client); err != nil {
+		conn.Close()
+		return
+	}
+	defer func() {
+		s.hub.unregister(client)
+		client.close(websocket.CloseNormalClosure, "")
+	}()
+
+	go client.writeLoop()
+
+	logger.Info("Log tail started", logger.String("project_id", tail.projectID))
+
+	if err := tail.refresh(s); err != nil {
+		logger.Error("Failed to start log tail", logger.String("project_id", tail.projectID), logger.Err(err))
+		client.sendError("", "Failed to subscribe")
+	}
+	client.sendJSON(tail.status("subscribed"))
+	go tail.run(s)
+
+	client.readLoop(func(req wsRequest) {
+		s.handleTailRequest(tail, req)
+	})
+}
+
+func (s *Server) handleTailRequest(tail *logTail, req wsRequest) {
+	client := tail.client
+	switch req.Action {
+	case "ping":
+		client.sendJSON(wsMessage{Type: "pong"})
+
+	case "filter":
+		filter, err := newTailFilter(req.Level, req.Query, req.Environments)
+		if err != nil {
+			client.sendError("", err.Error())
+			return
+		}
+		tail.mu.Lock()
+		tail.filter = filter
+		tail.mu.Unlock()
+		if err := tail.refresh(s); err != nil {
+			logger.Error("Failed to refresh log tail sources", logger.String("project_id", tail.projectID), logger.Err(err))
+			client.sendError("", "Failed to subscribe")
+		}
+		client.sendJSON(tail.status("filtered"))
+
+	case "pause":
+		tail.mu.Lock()
+		tail.paused = true
+		tail.mu.Unlock()
+		client.sendJSON(tail.status("paused"))
+
+	case "resume":
+		tail.mu.Lock()
+		skipped := tail.skipped
+		tail.paused, tail.skipped = false, 0
+		tail.mu.Unlock()
+		msg := tail.status("resumed")
+		msg.Data.(gin.H)["skipped"] = skipped
+		client.sendJSON(msg)
+
+	default:
+		client.sendError("", "Unknown action")
+	}
+}
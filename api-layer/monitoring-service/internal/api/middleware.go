@@ -1 +1,247 @@
-package api
\ No newline at end of file
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"monitoring-service/internal/auth"
+	"monitoring-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RBAC permissions, as "resource:action" from data-layer/RBAC
+const (
+	permDeploymentRead   = "deployment:read"   // Metrics, alerts, health, incidents
+	permDeploymentUpdate = "deployment:update" // Acknowledge and resolve alerts and incidents
+	permLogsRead         = "logs:read"
+	permProjectUpdate    = "project:update" // Alert rules, silences, probes, SLOs, tokens
+	permTeamRead         = "team:read"
+	permTeamUpdate       = "team:update"
+)
+
+const principalKey = "principal"
+
+const deploymentProjectQuery = `SELECT project_id FROM deployments WHERE id = $1`
+
+var errInvalidProjectID = errors.New("invalid project ID format")
+
+// principal returns the caller authenticated by authenticate()
+func principal(c *gin.Context) *auth.Principal {
+	p, _ := c.MustGet(principalKey).(*auth.Principal)
+	return p
+}
+
+// requestToken reads the caller's credential: a bearer token, an X-API-Key header, or
+// the session cookie for EventSource and WebSocket clients, which cannot set headers
+func (s *Server) requestToken(c *gin.Context) string {
+	if h := c.GetHeader("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if cookie, err := c.Cookie(s.config.SessionCookieName); err == nil {
+		return cookie
+	}
+	return ""
+}
+
+func (s *Server) authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := s.auth.Authenticate(c.Request.Context(), s.requestToken(c))
+		if err != nil {
+			s.respondAuthError(c, err)
+			return
+		}
+		c.Set(principalKey, p)
+		c.Next()
+	}
+}
+
+func (s *Server) respondAuthError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errInvalidProjectID):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID format"})
+	case errors.Is(err, auth.ErrUnauthenticated):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+	case errors.Is(err, auth.ErrForbidden):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+	case errors.Is(err, auth.ErrResourceNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+	default:
+		logger.Error("Failed to authorize request", logger.String("path", c.FullPath()), logger.Err(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authorize request"})
+	}
+}
+
+// requireService limits a route to internal services
+func (s *Server) requireService() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if principal(c).Kind != auth.KindService {
+			s.respondAuthError(c, auth.ErrForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireProjectPermission checks the permission on the project a route is about. The
+// project comes from the route's projectId, deploymentId, alertId or incidentId, and
+// for list endpoints from the projectId query parameter, which only internal services
+// may leave out.
+func (s *Server) requireProjectPermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectID, err := s.routeProject(c)
+		if err != nil {
+			s.respondAuthError(c, err)
+			return
+		}
+		if projectID == "" && principal(c).Kind != auth.KindService {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "projectId is required"})
+			return
+		}
+		if projectID != "" && !s.authorizeProject(c, projectID, permission) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorizeProject aborts the request unless the caller holds the permission on the
+// project
+func (s *Server) authorizeProject(c *gin.Context, projectID, permission string) bool {
+	permissions, err := s.auth.ProjectPermissions(c.Request.Context(), principal(c), projectID)
+	if err == nil && !permissions.Has(permission) {
+		err = auth.ErrForbidden
+	}
+	if err != nil {
+		s.respondAuthError(c, err)
+		return false
+	}
+	return true
+}
+
+func (s *Server) requireTeamPermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if uuid.Validate(c.Param("teamId")) != nil {
+			s.respondAuthError(c, auth.ErrResourceNotFound)
+			return
+		}
+		permissions, err := s.auth.TeamPermissions(c.Request.Context(), principal(c), c.Param("teamId"))
+		if err == nil && !permissions.Has(permission) {
+			err = auth.ErrForbidden
+		}
+		if err != nil {
+			s.respondAuthError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// routeProject resolves the project a route's parameters belong to
+func (s *Server) routeProject(c *gin.Context) (string, error) {
+	var query, id string
+	switch {
+	case c.Param("projectId") != "":
+		if uuid.Validate(c.Param("projectId")) != nil {
+			return "", auth.ErrResourceNotFound
+		}
+		return c.Param("projectId"), nil
+	case c.Param("deploymentId") != "":
+		query, id = deploymentProjectQuery, c.Param("deploymentId")
+	case c.Param("alertId") != "":
+		query, id = `
+			SELECT d.project_id FROM deployment_alerts a
+			JOIN deployments d ON d.id = a.deployment_id
+			WHERE a.id = $1`, c.Param("alertId")
+	case c.Param("incidentId") != "":
+		query, id = `SELECT project_id FROM incidents WHERE id = $1`, c.Param("incidentId")
+	default:
+		projectID := c.Query("projectId")
+		if projectID != "" && uuid.Validate(projectID) != nil {
+			return "", errInvalidProjectID
+		}
+		return projectID, nil
+	}
+
+	return s.lookupProject(c.Request.Context(), query, id)
+}
+
+// lookupProject runs a query selecting the project_id of the resource with the given ID
+func (s *Server) lookupProject(ctx context.Context, query, id string) (string, error) {
+	if uuid.Validate(id) != nil {
+		return "", auth.ErrResourceNotFound
+	}
+	var projectID sql.NullString
+	err := s.orchestrator.GetDB().QueryRowContext(ctx, query, id).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		return "", auth.ErrResourceNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if !projectID.Valid {
+		return "", auth.ErrResourceNotFound
+	}
+	return projectID.String, nil
+}
+
+// resourceProject resolves the project of a platform log resource. Company and system
+// logs belong to no project and are only readable by internal services.
+func (s *Server) resourceProject(c *gin.Context, resourceType, resourceID string) (string, error) {
+	var query string
+	switch resourceType {
+	case "project":
+		if uuid.Validate(resourceID) != nil {
+			return "", auth.ErrResourceNotFound
+		}
+		return resourceID, nil
+	case "deployment":
+		query = `SELECT project_id FROM deployments WHERE id::text = $1`
+	case "build":
+		query = `SELECT project_id FROM builds WHERE id::text = $1`
+	default:
+		return "", auth.ErrForbidden
+	}
+
+	var projectID sql.NullString
+	err := s.orchestrator.GetDB().QueryRowContext(c.Request.Context(), query, resourceID).Scan(&projectID)
+	if err == sql.ErrNoRows || (err == nil && !projectID.Valid) {
+		return "", auth.ErrResourceNotFound
+	}
+	return projectID.String, err
+}
+
+// authorizeLogResource aborts the request unless the caller may read the resource's
+// platform logs
+func (s *Server) authorizeLogResource(c *gin.Context, resourceType, resourceID string) bool {
+	if principal(c).Kind == auth.KindService {
+		return true
+	}
+	projectID, err := s.resourceProject(c, resourceType, resourceID)
+	if err != nil {
+		s.respondAuthError(c, err)
+		return false
+	}
+	return s.authorizeProject(c, projectID, permLogsRead)
+}
+
+// originAllowed reports whether a browser origin may call the API with credentials.
+// Requests without an Origin do not come from a browser page.
+func (s *Server) originAllowed(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range s.config.CORSAllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
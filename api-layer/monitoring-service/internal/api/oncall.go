@@ -0,0 +1,286 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"monitoring-service/internal/monitoring"
+	"monitoring-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) respondOnCallError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, monitoring.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "On-call schedule not found"})
+	case errors.Is(err, monitoring.ErrOverrideNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "On-call override not found"})
+	case errors.Is(err, monitoring.ErrEscalationPolicyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Escalation policy not found"})
+	case errors.Is(err, monitoring.ErrEscalationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert has no escalation"})
+	case errors.Is(err, monitoring.ErrScheduleNameTaken), errors.Is(err, monitoring.ErrEscalationPolicyNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, monitoring.ErrNotTeamMember), errors.Is(err, monitoring.ErrScheduleNotInTeam):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logger.Error(message, logger.String("team_id", c.Param("teamId")), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func (s *Server) validateTeamID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isValidID(c.Param("teamId")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID format"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func (s *Server) validateScheduleID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isValidID(c.Param("scheduleId")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID format"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func (s *Server) validatePolicyID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isValidID(c.Param("policyId")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid escalation policy ID format"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Who is on call right now for each of the team's schedules
+func (s *Server) handleGetOnCall(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	shifts, err := s.orchestrator.GetOnCallManager().WhoIsOnCall(ctx, c.Param("teamId"))
+	if err != nil {
+		s.respondOnCallError(c, err, "Failed to retrieve on-call")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"on_call": shifts})
+}
+
+func (s *Server) handleGetSchedules(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	schedules, err := s.orchestrator.GetOnCallManager().ListSchedules(ctx, c.Param("teamId"))
+	if err != nil {
+		s.respondOnCallError(c, err, "Failed to retrieve on-call schedules")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
+
+func (s *Server) handleGetSchedule(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	schedule, err := s.orchestrator.GetOnCallManager().GetSchedule(ctx, c.Param("teamId"), c.Param("scheduleId"))
+	if err != nil {
+		s.respondOnCallError(c, err, "Failed to retrieve on-call schedule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedule": schedule})
+}
+
+func (s *Server) handleCreateSchedule(c *gin.Context) {
+	var schedule monitoring.OnCallSchedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := schedule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetOnCallManager().CreateSchedule(ctx, c.Param("teamId"), &schedule); err != nil {
+		s.respondOnCallError(c, err, "Failed to create on-call schedule")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"schedule": schedule})
+}
+
+func (s *Server) handleUpdateSchedule(c *gin.Context) {
+	var schedule monitoring.OnCallSchedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := schedule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetOnCallManager().UpdateSchedule(ctx, c.Param("teamId"), c.Param("scheduleId"), &schedule); err != nil {
+		s.respondOnCallError(c, err, "Failed to update on-call schedule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedule": schedule})
+}
+
+func (s *Server) handleDeleteSchedule(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetOnCallManager().DeleteSchedule(ctx, c.Param("teamId"), c.Param("scheduleId")); err != nil {
+		s.respondOnCallError(c, err, "Failed to delete on-call schedule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "On-call schedule deleted"})
+}
+
+func (s *Server) handleCreateOverride(c *gin.Context) {
+	userID := principal(c).UserID
+
+	var override monitoring.OnCallOverride
+	if err := c.ShouldBindJSON(&override); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := override.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetOnCallManager().CreateOverride(ctx, c.Param("teamId"), c.Param("scheduleId"), userID, &override); err != nil {
+		s.respondOnCallError(c, err, "Failed to create on-call override")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"override": override})
+}
+
+func (s *Server) handleDeleteOverride(c *gin.Context) {
+	if !isValidID(c.Param("overrideId")) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid override ID format"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetOnCallManager().DeleteOverride(ctx, c.Param("teamId"), c.Param("scheduleId"), c.Param("overrideId")); err != nil {
+		s.respondOnCallError(c, err, "Failed to delete on-call override")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "On-call override deleted"})
+}
+
+func (s *Server) handleGetEscalationPolicies(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	policies, err := s.orchestrator.GetOnCallManager().ListEscalationPolicies(ctx, c.Param("teamId"))
+	if err != nil {
+		s.respondOnCallError(c, err, "Failed to retrieve escalation policies")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+func (s *Server) handleCreateEscalationPolicy(c *gin.Context) {
+	var policy monitoring.EscalationPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetOnCallManager().CreateEscalationPolicy(ctx, c.Param("teamId"), &policy); err != nil {
+		s.respondOnCallError(c, err, "Failed to create escalation policy")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"policy": policy})
+}
+
+func (s *Server) handleUpdateEscalationPolicy(c *gin.Context) {
+	var policy monitoring.EscalationPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetOnCallManager().UpdateEscalationPolicy(ctx, c.Param("teamId"), c.Param("policyId"), &policy); err != nil {
+		s.respondOnCallError(c, err, "Failed to update escalation policy")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policy": policy})
+}
+
+func (s *Server) handleDeleteEscalationPolicy(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetOnCallManager().DeleteEscalationPolicy(ctx, c.Param("teamId"), c.Param("policyId")); err != nil {
+		s.respondOnCallError(c, err, "Failed to delete escalation policy")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Escalation policy deleted"})
+}
+
+// Escalation progress of an alert and who was paged for it
+func (s *Server) handleGetAlertEscalation(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	escalation, err := s.orchestrator.GetOnCallManager().GetEscalation(ctx, c.Param("alertId"))
+	if err != nil {
+		s.respondOnCallError(c, err, "Failed to retrieve alert escalation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"escalation": escalation})
+}
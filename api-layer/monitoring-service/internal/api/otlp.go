@@ -0,0 +1,400 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"monitoring-service/internal/monitoring"
+	"monitoring-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Largest OTLP request accepted, after decompression
+const maxOTLPBodyBytes = 10 << 20
+
+func (s *Server) respondOTLPTokenError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, monitoring.ErrIngestTokenNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ingest token not found"})
+	case errors.Is(err, monitoring.ErrIngestTokenNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error(message, logger.String("project_id", c.Param("projectId")), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func (s *Server) handleGetOTLPTokens(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	tokens, err := s.orchestrator.GetOTLPReceiver().ListIngestTokens(ctx, c.Param("projectId"))
+	if err != nil {
+		s.respondOTLPTokenError(c, err, "Failed to retrieve ingest tokens")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+func (s *Server) handleCreateOTLPToken(c *gin.Context) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required and must be at most 100 characters"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	token, err := s.orchestrator.GetOTLPReceiver().CreateIngestToken(ctx, c.Param("projectId"), req.Name)
+	if err != nil {
+		s.respondOTLPTokenError(c, err, "Failed to create ingest token")
+		return
+	}
+
+	// The token is only returned here
+	c.JSON(http.StatusCreated, gin.H{"token": token})
+}
+
+func (s *Server) handleRevokeOTLPToken(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetOTLPReceiver().RevokeIngestToken(ctx, c.Param("projectId"), c.Param("tokenId")); err != nil {
+		s.respondOTLPTokenError(c, err, "Failed to revoke ingest token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ingest token revoked"})
+}
+
+// OTLP/HTTP receivers (/otlp/v1/traces, /otlp/v1/logs, /otlp/v1/metrics). Requests are
+// binary protobuf or JSON, optionally gzipped, authenticated with an ingest token as
+// bearer token. Responses use the request's encoding.
+func (s *Server) handleOTLPTraces(c *gin.Context) {
+	req := &coltracepb.ExportTraceServiceRequest{}
+	s.handleOTLP(c, req, func(ctx context.Context, projectID string) (proto.Message, error) {
+		return s.orchestrator.GetOTLPReceiver().ExportTraces(ctx, projectID, req)
+	})
+}
+
+func (s *Server) handleOTLPLogs(c *gin.Context) {
+	req := &collogspb.ExportLogsServiceRequest{}
+	s.handleOTLP(c, req, func(ctx context.Context, projectID string) (proto.Message, error) {
+		return s.orchestrator.GetOTLPReceiver().ExportLogs(ctx, projectID, req)
+	})
+}
+
+func (s *Server) handleOTLPMetrics(c *gin.Context) {
+	req := &colmetricspb.ExportMetricsServiceRequest{}
+	s.handleOTLP(c, req, func(ctx context.Context, projectID string) (proto.Message, error) {
+		return s.orchestrator.GetOTLPReceiver().ExportMetrics(ctx, projectID, req)
+	})
+}
+
+func (s *Server) handleOTLP(c *gin.Context, req proto.Message, export func(ctx context.Context, projectID string) (proto.Message, error)) {
+	isJSON := strings.HasPrefix(c.ContentType(), "application/json")
+	if !isJSON && c.ContentType() != "application/x-protobuf" {
+		respondOTLP(c, false, http.StatusUnsupportedMediaType, status.New(codes.InvalidArgument, "content type must be application/x-protobuf or application/json").Proto())
+		return
+	}
+
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || strings.TrimSpace(token) == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="otlp"`)
+		respondOTLP(c, isJSON, http.StatusUnauthorized, status.New(codes.Unauthenticated, "missing bearer token").Proto())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	projectID, err := s.orchestrator.GetOTLPReceiver().Authenticate(ctx, strings.TrimSpace(token))
+	if err != nil {
+		if errors.Is(err, monitoring.ErrInvalidIngestToken) {
+			c.Header("WWW-Authenticate", `Bearer realm="otlp", error="invalid_token"`)
+			respondOTLP(c, isJSON, http.StatusUnauthorized, status.New(codes.Unauthenticated, "invalid token").Proto())
+			return
+		}
+		logger.Error("Failed to authenticate OTLP export", logger.Err(err))
+		respondOTLP(c, isJSON, http.StatusServiceUnavailable, status.New(codes.Unavailable, "failed to authenticate").Proto())
+		return
+	}
+
+	body, err := readOTLPBody(c)
+	if err != nil {
+		respondOTLP(c, isJSON, http.StatusBadRequest, status.New(codes.InvalidArgument, err.Error()).Proto())
+		return
+	}
+	if isJSON {
+		if body, err = otlpJSONIDsToBase64(body); err == nil {
+			err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, req)
+		}
+	} else {
+		err = proto.Unmarshal(body, req)
+	}
+	if err != nil {
+		respondOTLP(c, isJSON, http.StatusBadRequest, status.New(codes.InvalidArgument, "invalid request: "+err.Error()).Proto())
+		return
+	}
+
+	resp, err := export(ctx, projectID)
+	if err != nil {
+		// 503 makes exporters retry
+		logger.Error("Failed to ingest OTLP export", logger.String("project_id", projectID), logger.Err(err))
+		respondOTLP(c, isJSON, http.StatusServiceUnavailable, status.New(codes.Unavailable, "failed to store telemetry").Proto())
+		return
+	}
+
+	respondOTLP(c, isJSON, http.StatusOK, resp)
+}
+
+func readOTLPBody(c *gin.Context) ([]byte, error) {
+	var r io.Reader = http.MaxBytesReader(c.Writer, c.Request.Body, maxOTLPBodyBytes)
+	switch c.GetHeader("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, errors.New("invalid gzip body")
+		}
+		defer gz.Close()
+		r = gz
+	default:
+		return nil, errors.New("unsupported content encoding")
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r, maxOTLPBodyBytes+1))
+	if err != nil {
+		return nil, errors.New("failed to read body")
+	}
+	if len(body) > maxOTLPBodyBytes {
+		return nil, errors.New("request body too large")
+	}
+	return body, nil
+}
+
+func respondOTLP(c *gin.Context, isJSON bool, code int, msg proto.Message) {
+	if isJSON {
+		data, _ := protojson.Marshal(msg)
+		c.Data(code, "application/json", data)
+		return
+	}
+	data, _ := proto.Marshal(msg)
+	c.Data(code, "application/x-protobuf", data)
+}
+
+// otlpJSONIDsToBase64 rewrites the hex trace and span IDs of OTLP/JSON to the base64
+// protojson expects for bytes fields
+func otlpJSONIDsToBase64(body []byte) ([]byte, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			for k, item := range val {
+				switch k {
+				case "traceId", "spanId", "parentSpanId", "trace_id", "span_id", "parent_span_id":
+					if id, ok := item.(string); ok {
+						if raw, err := hex.DecodeString(id); err == nil {
+							val[k] = base64.StdEncoding.EncodeToString(raw)
+						}
+					}
+				default:
+					walk(item)
+				}
+			}
+		case []interface{}:
+			for _, item := range val {
+				walk(item)
+			}
+		}
+	}
+	walk(doc)
+
+	return json.Marshal(doc)
+}
+
+// Traces of the project, newest first. duration_ms keeps traces at least that long.
+func (s *Server) handleGetTraces(c *gin.Context) {
+	filter := monitoring.TraceFilter{
+		DeploymentID: c.Query("deployment_id"),
+		Service:      c.Query("service"),
+		ErrorsOnly:   c.Query("errors") == "true",
+		End:          time.Now(),
+	}
+	filter.Start = filter.End.Add(-1 * time.Hour)
+	if filter.DeploymentID != "" && !isValidID(filter.DeploymentID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid deployment ID format"})
+		return
+	}
+	if !parseTraceWindow(c, &filter.Start, &filter.End) {
+		return
+	}
+	if v := c.Query("duration_ms"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "duration_ms must be a non-negative integer"})
+			return
+		}
+		filter.MinDuration = time.Duration(ms) * time.Millisecond
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	traces, err := s.orchestrator.GetTraceStore().ListTraces(ctx, c.Param("projectId"), filter)
+	if err != nil {
+		logger.Error("Failed to retrieve traces", logger.String("project_id", c.Param("projectId")), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve traces"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"traces": traces})
+}
+
+func (s *Server) handleGetTrace(c *gin.Context) {
+	traceID := strings.ToLower(c.Param("traceId"))
+	if raw, err := hex.DecodeString(traceID); err != nil || len(raw) != 16 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid trace ID format"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	spans, err := s.orchestrator.GetTraceStore().GetTrace(ctx, c.Param("projectId"), traceID)
+	if errors.Is(err, monitoring.ErrTraceNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trace not found"})
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to retrieve trace", logger.String("trace_id", traceID), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve trace"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"trace_id": traceID, "spans": spans})
+}
+
+// Sampled Traefik requests of the deployment. slow_ms keeps requests at least that
+// slow, slowest first; requests over a second are always sampled.
+func (s *Server) handleGetSampledRequests(c *gin.Context) {
+	end := time.Now()
+	start := end.Add(-1 * time.Hour)
+	if !parseTraceWindow(c, &start, &end) {
+		return
+	}
+
+	var minLatency time.Duration
+	if v := c.Query("slow_ms"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "slow_ms must be a non-negative integer"})
+			return
+		}
+		minLatency = time.Duration(ms) * time.Millisecond
+	}
+	limit := 0
+	if v := c.Query("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 || l > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+			return
+		}
+		limit = l
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	requests, err := s.orchestrator.GetTraceStore().ListRequests(ctx, c.Param("deploymentId"), minLatency, start, end, limit)
+	if err != nil {
+		logger.Error("Failed to retrieve sampled requests", logger.String("deployment_id", c.Param("deploymentId")), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// The trace that served a sampled request
+func (s *Server) handleGetRequestTrace(c *gin.Context) {
+	requestID, err := strconv.Atoi(c.Param("requestId"))
+	if err != nil || requestID < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID format"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	trace, err := s.orchestrator.GetTraceStore().TraceForRequest(ctx, c.Param("deploymentId"), requestID)
+	switch {
+	case errors.Is(err, monitoring.ErrRequestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Request not found"})
+	case errors.Is(err, monitoring.ErrTraceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No trace found for this request"})
+	case err != nil:
+		logger.Error("Failed to retrieve request trace", logger.String("deployment_id", c.Param("deploymentId")), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve request trace"})
+	default:
+		c.JSON(http.StatusOK, trace)
+	}
+}
+
+// parseTraceWindow reads the optional RFC3339 start and end query params
+func parseTraceWindow(c *gin.Context, start, end *time.Time) bool {
+	for param, dst := range map[string]*time.Time{"start": start, "end": end} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time format. Use RFC3339 format."})
+				return false
+			}
+			*dst = t
+		}
+	}
+	if !start.Before(*end) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start must be before end"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"monitoring-service/internal/monitoring"
+	"monitoring-service/pkg/logger"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // Exporters may compress requests
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// NewOTLPGRPCServer serves the OTLP trace, logs and metrics services. Exporters send
+// their ingest token in the authorization metadata ("Bearer <token>").
+func NewOTLPGRPCServer(o *monitoring.Orchestrator) *grpc.Server {
+	server := grpc.NewServer(grpc.MaxRecvMsgSize(maxOTLPBodyBytes))
+	receiver := &otlpGRPCReceiver{receiver: o.GetOTLPReceiver()}
+	coltracepb.RegisterTraceServiceServer(server, &otlpTraceService{otlpGRPCReceiver: receiver})
+	collogspb.RegisterLogsServiceServer(server, &otlpLogsService{otlpGRPCReceiver: receiver})
+	colmetricspb.RegisterMetricsServiceServer(server, &otlpMetricsService{otlpGRPCReceiver: receiver})
+	return server
+}
+
+type otlpGRPCReceiver struct {
+	receiver *monitoring.OTLPReceiver
+}
+
+func (r *otlpGRPCReceiver) authenticate(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	for _, v := range md.Get("authorization") {
+		if t, ok := strings.CutPrefix(v, "Bearer "); ok {
+			token = strings.TrimSpace(t)
+		}
+	}
+	if token == "" {
+		return "", status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	projectID, err := r.receiver.Authenticate(ctx, token)
+	if errors.Is(err, monitoring.ErrInvalidIngestToken) {
+		return "", status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if err != nil {
+		logger.Error("Failed to authenticate OTLP export", logger.Err(err))
+		return "", status.Error(codes.Unavailable, "failed to authenticate")
+	}
+	return projectID, nil
+}
+
+// exportError hides storage errors; Unavailable makes exporters retry
+func exportError(projectID string, err error) error {
+	logger.Error("Failed to ingest OTLP export", logger.String("project_id", projectID), logger.Err(err))
+	return status.Error(codes.Unavailable, "failed to store telemetry")
+}
+
+type otlpTraceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	*otlpGRPCReceiver
+}
+
+func (s *otlpTraceService) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	projectID, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.receiver.ExportTraces(ctx, projectID, req)
+	if err != nil {
+		return nil, exportError(projectID, err)
+	}
+	return resp, nil
+}
+
+type otlpLogsService struct {
+	collogspb.UnimplementedLogsServiceServer
+	*otlpGRPCReceiver
+}
+
+func (s *otlpLogsService) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	projectID, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.receiver.ExportLogs(ctx, projectID, req)
+	if err != nil {
+		return nil, exportError(projectID, err)
+	}
+	return resp, nil
+}
+
+type otlpMetricsService struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	*otlpGRPCReceiver
+}
+
+func (s *otlpMetricsService) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	projectID, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.receiver.ExportMetrics(ctx, projectID, req)
+	if err != nil {
+		return nil, exportError(projectID, err)
+	}
+	return resp, nil
+}
@@ -10,12 +10,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/AALXX/Obtura/shared/platformlog"
 	"github.com/gin-gonic/gin"
+	"monitoring-service/internal/auth"
+	"monitoring-service/internal/monitoring"
 	"monitoring-service/pkg/logger"
 )
 
 // PlatformLogEvent represents a unified log event from any service
 type PlatformLogEvent struct {
+	SchemaVersion  int                    `json:"schema_version,omitempty"`
 	ID             string                 `json:"id"`
 	EventType      string                 `json:"event_type"`
 	EventSubtype   string                 `json:"event_subtype"`
@@ -33,11 +37,6 @@ type PlatformLogEvent struct {
 	EventTimestamp time.Time              `json:"event_timestamp"`
 }
 
-// LogIngestRequest represents a batch log ingestion request
-type LogIngestRequest struct {
-	Events []PlatformLogEvent `json:"events"`
-}
-
 // LogQueryRequest represents a log query request
 type LogQueryRequest struct {
 	ResourceType string    `json:"resource_type"`
@@ -45,6 +44,7 @@ type LogQueryRequest struct {
 	ProjectID    string    `json:"project_id,omitempty"`
 	EventTypes   []string  `json:"event_types,omitempty"`
 	Severities   []string  `json:"severities,omitempty"`
+	Query        string    `json:"query,omitempty"` // Log query, see monitoring.ParseLogQuery
 	StartTime    time.Time `json:"start_time"`
 	EndTime      time.Time `json:"end_time"`
 	Limit        int       `json:"limit"`
@@ -59,21 +59,42 @@ type LogQueryResponse struct {
 	Offset int                `json:"offset"`
 }
 
-// handleIngestLogs handles batch log ingestion from any service
+// handleIngestLogs handles batch log ingestion from any service. Events of older
+// schema versions are upgraded; invalid ones are rejected without failing the batch.
 func (s *Server) handleIngestLogs(c *gin.Context) {
-	var req LogIngestRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	events, rejected, err := parseLogIngest(body)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
 
-	if len(req.Events) == 0 {
+	total := len(events) + len(rejected)
+	if total == 0 {
 		c.JSON(http.StatusOK, gin.H{"ingested": 0})
 		return
 	}
+	if len(events) == 0 {
+		// Nothing a retry could store; producers drop the batch
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":          "No valid events",
+			"rejected":       rejected,
+			"schema_version": platformlog.SchemaVersion,
+		})
+		return
+	}
+	if len(rejected) > 0 {
+		logger.Warn("Rejected platform log events",
+			logger.Int("rejected", len(rejected)),
+			logger.Int("total", total))
+	}
 
 	// Insert logs into database
-	inserted, err := s.insertLogEvents(c.Request.Context(), req.Events)
+	inserted, err := s.insertLogEvents(c.Request.Context(), events)
 	if err != nil {
 		logger.Error("Failed to insert log events", logger.Err(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store logs"})
@@ -81,13 +102,14 @@ func (s *Server) handleIngestLogs(c *gin.Context) {
 	}
 
 	// Also publish to Redis for real-time streaming
-	for _, event := range req.Events {
+	for _, event := range events {
 		s.publishToRedis(c.Request.Context(), event)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"ingested": inserted,
-		"total":    len(req.Events),
+		"total":    total,
+		"rejected": rejected,
 	})
 }
 
@@ -174,6 +196,7 @@ func (s *Server) handleQueryLogs(c *gin.Context) {
 		req.ResourceType = c.Query("resource_type")
 		req.ResourceID = c.Query("resource_id")
 		req.ProjectID = c.Query("project_id")
+		req.Query = c.Query("q")
 
 		if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
 			req.Limit = limit
@@ -191,8 +214,20 @@ func (s *Server) handleQueryLogs(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "resource_type and resource_id are required"})
 		return
 	}
+	if !s.authorizeLogResource(c, req.ResourceType, req.ResourceID) {
+		return
+	}
 
-	events, total, err := s.queryLogEvents(c.Request.Context(), req)
+	var query *monitoring.LogQuery
+	if req.Query != "" {
+		var err error
+		if query, err = monitoring.ParseLogQuery(req.Query, monitoring.PlatformLogColumns); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	events, total, err := s.queryLogEvents(c.Request.Context(), req, query)
 	if err != nil {
 		logger.Error("Failed to query log events", logger.Err(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query logs"})
@@ -207,7 +242,7 @@ func (s *Server) handleQueryLogs(c *gin.Context) {
 	})
 }
 
-func (s *Server) queryLogEvents(ctx context.Context, req LogQueryRequest) ([]PlatformLogEvent, int, error) {
+func (s *Server) queryLogEvents(ctx context.Context, req LogQueryRequest, logQuery *monitoring.LogQuery) ([]PlatformLogEvent, int, error) {
 	db := s.orchestrator.GetDB()
 
 	// Build dynamic query
@@ -260,6 +295,13 @@ func (s *Server) queryLogEvents(ctx context.Context, req LogQueryRequest) ([]Pla
 		args = append(args, req.EndTime)
 	}
 
+	if logQuery != nil {
+		cond, queryArgs := logQuery.SQL(argCount)
+		query += " AND " + cond
+		args = append(args, queryArgs...)
+		argCount += len(queryArgs)
+	}
+
 	// Get total count
 	countQuery := "SELECT COUNT(*) FROM (" + query + ") AS count_query"
 	var total int
@@ -351,6 +393,9 @@ func (s *Server) handleStreamPlatformLogs(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "resource type and ID are required"})
 		return
 	}
+	if !s.authorizeLogResource(c, resourceType, resourceID) {
+		return
+	}
 
 	// Check if client supports HTTP/3 - if so, we need special handling
 	// HTTP/3 has issues with SSE streaming, so we force HTTP/2 for this endpoint
@@ -367,9 +412,6 @@ func (s *Server) handleStreamPlatformLogs(c *gin.Context) {
 	c.Header("Connection", "keep-alive")
 	c.Header("X-Accel-Buffering", "no")
 	c.Header("X-Content-Type-Options", "nosniff")
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	c.Header("Access-Control-Expose-Headers", "Content-Type")
 
 	// Add these headers to help with buffering issues
 	c.Header("Pragma", "no-cache")
@@ -445,6 +487,21 @@ func (s *Server) handleGetLogStats(c *gin.Context) {
 	resourceID := c.Query("resource_id")
 	projectID := c.Query("project_id")
 
+	// Stats across resources are scoped to a project unless an internal service asks
+	switch {
+	case resourceType != "" && resourceID != "":
+		if !s.authorizeLogResource(c, resourceType, resourceID) {
+			return
+		}
+	case projectID != "":
+		if !s.authorizeLogResource(c, "project", projectID) {
+			return
+		}
+	case principal(c).Kind != auth.KindService:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "resource_type and resource_id, or project_id, are required"})
+		return
+	}
+
 	stats, err := s.getLogStats(c.Request.Context(), resourceType, resourceID, projectID)
 	if err != nil {
 		logger.Error("Failed to get log stats", logger.Err(err))
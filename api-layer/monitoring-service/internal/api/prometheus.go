@@ -0,0 +1,223 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"monitoring-service/internal/metrics"
+	"monitoring-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) respondPrometheusError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, metrics.ErrScrapeTokenNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scrape token not found"})
+	case errors.Is(err, metrics.ErrRemoteWriteNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Remote-write target not found"})
+	case errors.Is(err, metrics.ErrScrapeTokenNameTaken), errors.Is(err, metrics.ErrRemoteWriteNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error(message, logger.String("project_id", c.Param("projectId")), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func (s *Server) validateTokenID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isValidID(c.Param("tokenId")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID format"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func (s *Server) validateTargetID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isValidID(c.Param("targetId")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target ID format"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Prometheus scrape endpoint. The bearer token selects the project, so one Prometheus
+// job per project is enough.
+func (s *Server) handlePrometheusMetrics(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+		c.String(http.StatusUnauthorized, "missing bearer token\n")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	exporter := s.orchestrator.GetMetricsExporter()
+	projectID, err := exporter.AuthenticateScrape(ctx, strings.TrimSpace(token))
+	if err != nil {
+		if errors.Is(err, metrics.ErrInvalidScrapeToken) {
+			c.Header("WWW-Authenticate", `Bearer realm="metrics", error="invalid_token"`)
+			c.String(http.StatusUnauthorized, "invalid token\n")
+			return
+		}
+		logger.Error("Failed to authenticate scrape", logger.Err(err))
+		c.String(http.StatusInternalServerError, "failed to authenticate\n")
+		return
+	}
+
+	families, err := exporter.Collect(ctx, projectID)
+	if err != nil {
+		logger.Error("Failed to collect Prometheus metrics", logger.String("project_id", projectID), logger.Err(err))
+		c.String(http.StatusInternalServerError, "failed to collect metrics\n")
+		return
+	}
+
+	openMetrics := strings.Contains(c.GetHeader("Accept"), "application/openmetrics-text")
+	contentType := metrics.ContentTypeText
+	if openMetrics {
+		contentType = metrics.ContentTypeOpenMetrics
+	}
+
+	var body bytes.Buffer
+	if err := metrics.WriteText(&body, families, openMetrics); err != nil {
+		c.String(http.StatusInternalServerError, "failed to encode metrics\n")
+		return
+	}
+	c.Data(http.StatusOK, contentType, body.Bytes())
+}
+
+// Scrape tokens
+func (s *Server) handleGetScrapeTokens(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	tokens, err := s.orchestrator.GetMetricsExporter().ListScrapeTokens(ctx, c.Param("projectId"))
+	if err != nil {
+		s.respondPrometheusError(c, err, "Failed to retrieve scrape tokens")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+func (s *Server) handleCreateScrapeToken(c *gin.Context) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required and must be at most 100 characters"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	token, err := s.orchestrator.GetMetricsExporter().CreateScrapeToken(ctx, c.Param("projectId"), req.Name)
+	if err != nil {
+		s.respondPrometheusError(c, err, "Failed to create scrape token")
+		return
+	}
+
+	// The token is only returned here
+	c.JSON(http.StatusCreated, gin.H{"token": token})
+}
+
+func (s *Server) handleRevokeScrapeToken(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetMetricsExporter().RevokeScrapeToken(ctx, c.Param("projectId"), c.Param("tokenId")); err != nil {
+		s.respondPrometheusError(c, err, "Failed to revoke scrape token")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scrape token revoked"})
+}
+
+// Remote-write targets
+func (s *Server) handleGetRemoteWriteTargets(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	targets, err := s.orchestrator.GetRemoteWriter().ListTargets(ctx, c.Param("projectId"))
+	if err != nil {
+		s.respondPrometheusError(c, err, "Failed to retrieve remote-write targets")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"targets": targets})
+}
+
+func (s *Server) handleCreateRemoteWriteTarget(c *gin.Context) {
+	target := metrics.RemoteWriteTarget{Enabled: true, IntervalSeconds: metrics.DefaultRemoteWriteInterval}
+	if err := c.ShouldBindJSON(&target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := target.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	created, err := s.orchestrator.GetRemoteWriter().CreateTarget(ctx, c.Param("projectId"), &target)
+	if err != nil {
+		s.respondPrometheusError(c, err, "Failed to create remote-write target")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"target": created})
+}
+
+func (s *Server) handleUpdateRemoteWriteTarget(c *gin.Context) {
+	target := metrics.RemoteWriteTarget{Enabled: true, IntervalSeconds: metrics.DefaultRemoteWriteInterval}
+	if err := c.ShouldBindJSON(&target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := target.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	updated, err := s.orchestrator.GetRemoteWriter().UpdateTarget(ctx, c.Param("projectId"), c.Param("targetId"), &target)
+	if err != nil {
+		s.respondPrometheusError(c, err, "Failed to update remote-write target")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"target": updated})
+}
+
+func (s *Server) handleDeleteRemoteWriteTarget(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetRemoteWriter().DeleteTarget(ctx, c.Param("projectId"), c.Param("targetId")); err != nil {
+		s.respondPrometheusError(c, err, "Failed to delete remote-write target")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Remote-write target deleted"})
+}
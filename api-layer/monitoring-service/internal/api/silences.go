@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"monitoring-service/internal/monitoring"
+	"monitoring-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) respondSilenceError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, monitoring.ErrSilenceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Silence not found"})
+	case errors.Is(err, monitoring.ErrMaintenanceWindowNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Maintenance window not found"})
+	case errors.Is(err, monitoring.ErrDeploymentNotInProject):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logger.Error(message, logger.String("project_id", c.Param("projectId")), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func (s *Server) validateSilenceID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isValidID(c.Param("silenceId")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid silence ID format"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func (s *Server) validateWindowID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isValidID(c.Param("windowId")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid maintenance window ID format"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func (s *Server) handleGetSilences(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	silences, err := s.orchestrator.GetAlertManager().ListSilences(ctx, c.Param("projectId"), c.Query("include_expired") == "true")
+	if err != nil {
+		s.respondSilenceError(c, err, "Failed to retrieve silences")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"silences": silences})
+}
+
+func (s *Server) handleCreateSilence(c *gin.Context) {
+	userID := principal(c).UserID
+
+	var silence monitoring.Silence
+	if err := c.ShouldBindJSON(&silence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := silence.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetAlertManager().CreateSilence(ctx, c.Param("projectId"), userID, &silence); err != nil {
+		s.respondSilenceError(c, err, "Failed to create silence")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"silence": silence})
+}
+
+// Ends a silence early; alerts it muted are notified on the next cycle if still open
+func (s *Server) handleExpireSilence(c *gin.Context) {
+	userID := principal(c).UserID
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetAlertManager().ExpireSilence(ctx, c.Param("projectId"), c.Param("silenceId"), userID); err != nil {
+		s.respondSilenceError(c, err, "Failed to expire silence")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Silence expired"})
+}
+
+func (s *Server) handleGetMaintenanceWindows(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	windows, err := s.orchestrator.GetAlertManager().ListMaintenanceWindows(ctx, c.Param("projectId"), c.Query("include_past") == "true")
+	if err != nil {
+		s.respondSilenceError(c, err, "Failed to retrieve maintenance windows")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"maintenance_windows": windows})
+}
+
+func (s *Server) handleCreateMaintenanceWindow(c *gin.Context) {
+	userID := principal(c).UserID
+
+	var window monitoring.MaintenanceWindow
+	if err := c.ShouldBindJSON(&window); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := window.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetAlertManager().CreateMaintenanceWindow(ctx, c.Param("projectId"), userID, &window); err != nil {
+		s.respondSilenceError(c, err, "Failed to create maintenance window")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"maintenance_window": window})
+}
+
+func (s *Server) handleUpdateMaintenanceWindow(c *gin.Context) {
+	var window monitoring.MaintenanceWindow
+	if err := c.ShouldBindJSON(&window); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := window.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetAlertManager().UpdateMaintenanceWindow(ctx, c.Param("projectId"), c.Param("windowId"), &window); err != nil {
+		s.respondSilenceError(c, err, "Failed to update maintenance window")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"maintenance_window": window})
+}
+
+func (s *Server) handleDeleteMaintenanceWindow(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetAlertManager().DeleteMaintenanceWindow(ctx, c.Param("projectId"), c.Param("windowId")); err != nil {
+		s.respondSilenceError(c, err, "Failed to delete maintenance window")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Maintenance window deleted"})
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"monitoring-service/internal/monitoring"
+	"monitoring-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) respondProbeError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, monitoring.ErrProbeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Probe not found"})
+	case errors.Is(err, monitoring.ErrSLONotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "SLO not found"})
+	case errors.Is(err, monitoring.ErrProbeNameTaken), errors.Is(err, monitoring.ErrSLONameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, monitoring.ErrProbeHostDenied):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logger.Error(message, logger.String("project_id", c.Param("projectId")), logger.Err(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func (s *Server) validateProbeID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isValidID(c.Param("probeId")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid probe ID format"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func (s *Server) validateSLOID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isValidID(c.Param("sloId")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SLO ID format"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Synthetic probes
+func (s *Server) handleGetProbes(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	probes, err := s.orchestrator.GetSyntheticProber().ListProbes(ctx, c.Param("projectId"))
+	if err != nil {
+		s.respondProbeError(c, err, "Failed to retrieve probes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"probes": probes})
+}
+
+func (s *Server) handleCreateProbe(c *gin.Context) {
+	probe := monitoring.SyntheticProbe{Enabled: true, TLSExpiryWarnDays: monitoring.DefaultProbeTLSExpiryWarnDays}
+	if err := c.ShouldBindJSON(&probe); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := probe.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetSyntheticProber().CreateProbe(ctx, c.Param("projectId"), &probe); err != nil {
+		s.respondProbeError(c, err, "Failed to create probe")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"probe": probe})
+}
+
+func (s *Server) handleUpdateProbe(c *gin.Context) {
+	probe := monitoring.SyntheticProbe{Enabled: true, TLSExpiryWarnDays: monitoring.DefaultProbeTLSExpiryWarnDays}
+	if err := c.ShouldBindJSON(&probe); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := probe.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetSyntheticProber().UpdateProbe(ctx, c.Param("projectId"), c.Param("probeId"), &probe); err != nil {
+		s.respondProbeError(c, err, "Failed to update probe")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"probe": probe})
+}
+
+func (s *Server) handleDeleteProbe(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetSyntheticProber().DeleteProbe(ctx, c.Param("projectId"), c.Param("probeId")); err != nil {
+		s.respondProbeError(c, err, "Failed to delete probe")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Probe deleted"})
+}
+
+func (s *Server) handleGetProbeResults(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	results, err := s.orchestrator.GetSyntheticProber().ListProbeResults(ctx, c.Param("projectId"), c.Param("probeId"), limit)
+	if err != nil {
+		s.respondProbeError(c, err, "Failed to retrieve probe results")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// SLOs with their current error budget and burn rates
+func (s *Server) handleGetSLOs(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	statuses, err := s.orchestrator.GetSLOEngine().ListSLOStatuses(ctx, c.Param("projectId"))
+	if err != nil {
+		s.respondProbeError(c, err, "Failed to retrieve SLOs")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"slos": statuses})
+}
+
+func (s *Server) handleGetSLO(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	engine := s.orchestrator.GetSLOEngine()
+	slo, err := engine.GetSLO(ctx, c.Param("projectId"), c.Param("sloId"))
+	if err != nil {
+		s.respondProbeError(c, err, "Failed to retrieve SLO")
+		return
+	}
+
+	status, err := engine.Status(ctx, slo)
+	if err != nil {
+		s.respondProbeError(c, err, "Failed to compute error budget")
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (s *Server) handleCreateSLO(c *gin.Context) {
+	slo := monitoring.SLO{Enabled: true}
+	if err := c.ShouldBindJSON(&slo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := slo.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetSLOEngine().CreateSLO(ctx, c.Param("projectId"), &slo); err != nil {
+		s.respondProbeError(c, err, "Failed to create SLO")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"slo": slo})
+}
+
+func (s *Server) handleUpdateSLO(c *gin.Context) {
+	slo := monitoring.SLO{Enabled: true}
+	if err := c.ShouldBindJSON(&slo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if err := slo.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetSLOEngine().UpdateSLO(ctx, c.Param("projectId"), c.Param("sloId"), &slo); err != nil {
+		s.respondProbeError(c, err, "Failed to update SLO")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"slo": slo})
+}
+
+func (s *Server) handleDeleteSLO(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.orchestrator.GetSLOEngine().DeleteSLO(ctx, c.Param("projectId"), c.Param("sloId")); err != nil {
+		s.respondProbeError(c, err, "Failed to delete SLO")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "SLO deleted"})
+}
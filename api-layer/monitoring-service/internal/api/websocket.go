@@ -1 +1,638 @@
 package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"monitoring-service/internal/auth"
+	"monitoring-service/internal/metrics"
+	"monitoring-service/internal/monitoring"
+	"monitoring-service/pkg/db"
+	"monitoring-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = wsPongWait * 9 / 10
+	wsMaxMessageSize = 4096
+
+	// Messages queued for a client before it counts as too slow and is evicted
+	wsSendBuffer = 256
+)
+
+// Streams a WebSocket client can subscribe to
+const (
+	streamMetrics = "metrics"
+	streamLogs    = "logs"
+	streamTail    = "tail" // Logs of every container of a project, see log_tail.go
+)
+
+var (
+	errHubClosed       = errors.New("websocket hub is closed")
+	errSubscribeFailed = errors.New("failed to subscribe to the stream")
+)
+
+// wsRequest is a message from a client. "subscribe" switches the deployment or the
+// time range of the metrics history, "unsubscribe" pauses the stream and "ping" is
+// answered with a "pong" for browsers, which cannot send ping frames. Project tails
+// take "filter", "pause" and "resume" instead of "subscribe".
+type wsRequest struct {
+	Action       string   `json:"action"`
+	DeploymentID string   `json:"deployment_id"`
+	TimeRange    string   `json:"time_range"`
+	Level        string   `json:"level"`
+	Query        string   `json:"query"`
+	Environments []string `json:"environments"`
+}
+
+// wsMessage is the envelope of every message sent to a client
+type wsMessage struct {
+	Type         string      `json:"type"`
+	DeploymentID string      `json:"deployment_id,omitempty"`
+	TimeRange    string      `json:"time_range,omitempty"`
+	Data         interface{} `json:"data,omitempty"`
+	Error        string      `json:"error,omitempty"`
+}
+
+// hub fans Redis pub/sub messages out to WebSocket clients. All clients watching the
+// same deployment stream share one channel subscription, dropped with the last client.
+// Deployment streams are on one channel at a time; a project tail follows the log
+// channels of all the project's deployments.
+//
+// Joining and leaving only change the topics under mu; syncSubscriptions then brings
+// the Redis subscription in line without holding mu, so a slow Redis round trip never
+// holds up broadcast.
+type hub struct {
+	pubsub *redis.PubSub
+
+	mu      sync.Mutex
+	topics  map[string]*hubTopic
+	clients map[*wsClient]struct{}
+	closed  bool
+
+	// subMu orders the Redis calls; subscribed is what Redis has been asked for
+	subMu      sync.Mutex
+	subscribed map[string]bool
+}
+
+type hubTopic struct {
+	channel      string
+	kind         string
+	deploymentID string
+	clients      map[*wsClient]struct{}
+}
+
+func newHub(redisClient *db.RedisClient) *hub {
+	h := &hub{
+		pubsub:     redisClient.Subscribe(context.Background()),
+		topics:     make(map[string]*hubTopic),
+		clients:    make(map[*wsClient]struct{}),
+		subscribed: make(map[string]bool),
+	}
+	go h.run()
+	return h
+}
+
+func topicChannel(kind, deploymentID string) string {
+	if kind == streamLogs {
+		return monitoring.LogChannelPrefix + deploymentID
+	}
+	return metrics.MetricsChannelPrefix + deploymentID
+}
+
+func (h *hub) run() {
+	for msg := range h.pubsub.Channel() {
+		h.broadcast(msg.Channel, msg.Payload)
+	}
+}
+
+// broadcast encodes a message once and queues it for every client on the channel
+func (h *hub) broadcast(channel, payload string) {
+	h.mu.Lock()
+	topic := h.topics[channel]
+	if topic == nil {
+		h.mu.Unlock()
+		return
+	}
+	clients := make([]*wsClient, 0, len(topic.clients))
+	for c := range topic.clients {
+		clients = append(clients, c)
+	}
+	kind, deploymentID := topic.kind, topic.deploymentID
+	h.mu.Unlock()
+
+	// Tails filter and rate limit every line for their client
+	n := 0
+	for _, c := range clients {
+		if c.tail != nil {
+			c.tail.offer(payload)
+			continue
+		}
+		clients[n] = c
+		n++
+	}
+	clients = clients[:n]
+	if len(clients) == 0 {
+		return
+	}
+
+	msg, err := json.Marshal(wsMessage{Type: kind, DeploymentID: deploymentID, Data: json.RawMessage(payload)})
+	if err != nil {
+		logger.Warn("Dropping malformed stream message", logger.String("channel", channel), logger.Err(err))
+		return
+	}
+	for _, c := range clients {
+		c.enqueue(msg)
+	}
+}
+
+func (h *hub) register(c *wsClient) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return errHubClosed
+	}
+	h.clients[c] = struct{}{}
+	return nil
+}
+
+func (h *hub) unregister(c *wsClient) {
+	h.mu.Lock()
+	h.leave(c)
+	delete(h.clients, c)
+	h.mu.Unlock()
+
+	h.syncSubscriptions()
+}
+
+// subscribe moves the client to the deployment's stream, subscribing to the Redis
+// channel when it is the first client on it
+func (h *hub) subscribe(c *wsClient, deploymentID string) error {
+	channel := topicChannel(c.kind, deploymentID)
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return errHubClosed
+	}
+	if _, ok := c.channels[channel]; ok && len(c.channels) == 1 {
+		h.mu.Unlock()
+		return nil
+	}
+
+	h.join(c, c.kind, deploymentID)
+	for other := range c.channels {
+		if other != channel {
+			h.leaveChannel(c, other)
+		}
+	}
+	h.mu.Unlock()
+
+	h.syncSubscriptions()
+	return h.joined(c, []string{channel})
+}
+
+// follow keeps the client on the log channels of exactly these deployments. It joins
+// as many as it can and returns an error when any of them failed.
+func (h *hub) follow(c *wsClient, deploymentIDs []string) error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return errHubClosed
+	}
+
+	keep := make(map[string]struct{}, len(deploymentIDs))
+	channels := make([]string, 0, len(deploymentIDs))
+	for _, deploymentID := range deploymentIDs {
+		channel := topicChannel(streamLogs, deploymentID)
+		keep[channel] = struct{}{}
+		channels = append(channels, channel)
+		h.join(c, streamLogs, deploymentID)
+	}
+	for channel := range c.channels {
+		if _, ok := keep[channel]; !ok {
+			h.leaveChannel(c, channel)
+		}
+	}
+	h.mu.Unlock()
+
+	h.syncSubscriptions()
+	return h.joined(c, channels)
+}
+
+// join adds the client to a deployment's channel, creating the topic when it is the
+// first client on it. h.mu must be held.
+func (h *hub) join(c *wsClient, kind, deploymentID string) {
+	channel := topicChannel(kind, deploymentID)
+	topic := h.topics[channel]
+	if topic == nil {
+		topic = &hubTopic{
+			channel:      channel,
+			kind:         kind,
+			deploymentID: deploymentID,
+			clients:      make(map[*wsClient]struct{}),
+		}
+		h.topics[channel] = topic
+	}
+	topic.clients[c] = struct{}{}
+	c.channels[channel] = struct{}{}
+}
+
+// joined reports whether the client is still on the channels after the sync; topics
+// whose Redis subscription failed are dropped with their clients
+func (h *hub) joined(c *wsClient, channels []string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, channel := range channels {
+		if _, ok := c.channels[channel]; !ok {
+			return errSubscribeFailed
+		}
+	}
+	return nil
+}
+
+func (h *hub) unsubscribe(c *wsClient) {
+	h.mu.Lock()
+	h.leave(c)
+	h.mu.Unlock()
+
+	h.syncSubscriptions()
+}
+
+// leave takes the client off its channels. h.mu must be held.
+func (h *hub) leave(c *wsClient) {
+	for channel := range c.channels {
+		h.leaveChannel(c, channel)
+	}
+}
+
+// leaveChannel takes the client off a channel and drops the topic once nobody is left
+// on it. h.mu must be held.
+func (h *hub) leaveChannel(c *wsClient, channel string) {
+	delete(c.channels, channel)
+	topic := h.topics[channel]
+	if topic == nil {
+		return
+	}
+
+	delete(topic.clients, c)
+	if len(topic.clients) == 0 {
+		delete(h.topics, topic.channel)
+	}
+}
+
+// syncSubscriptions subscribes to the channels of new topics and unsubscribes from
+// those of dropped ones. Topics whose subscription fails are dropped, so their clients
+// see the error from joined.
+func (h *hub) syncSubscriptions() {
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+
+	h.mu.Lock()
+	var add, remove []string
+	for channel := range h.topics {
+		if !h.subscribed[channel] {
+			add = append(add, channel)
+		}
+	}
+	for channel := range h.subscribed {
+		if h.topics[channel] == nil {
+			remove = append(remove, channel)
+		}
+	}
+	h.mu.Unlock()
+
+	if len(add) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := h.pubsub.Subscribe(ctx, add...)
+		cancel()
+		if err != nil {
+			logger.Warn("Failed to subscribe to stream channels", logger.Int("channels", len(add)), logger.Err(err))
+			h.dropTopics(add)
+		} else {
+			for _, channel := range add {
+				h.subscribed[channel] = true
+			}
+		}
+	}
+
+	if len(remove) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := h.pubsub.Unsubscribe(ctx, remove...); err != nil {
+			logger.Warn("Failed to unsubscribe from stream channels", logger.Int("channels", len(remove)), logger.Err(err))
+		}
+		cancel()
+		for _, channel := range remove {
+			delete(h.subscribed, channel)
+		}
+	}
+}
+
+// dropTopics removes topics and takes their clients off them
+func (h *hub) dropTopics(channels []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, channel := range channels {
+		topic := h.topics[channel]
+		if topic == nil {
+			continue
+		}
+		for c := range topic.clients {
+			delete(c.channels, channel)
+		}
+		delete(h.topics, channel)
+	}
+}
+
+// Close disconnects every client and the Redis subscription
+func (h *hub) Close() error {
+	h.mu.Lock()
+	h.closed = true
+	clients := make([]*wsClient, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range clients {
+		c.close(websocket.CloseGoingAway, "server shutting down")
+	}
+	return h.pubsub.Close()
+}
+
+// wsClient is one WebSocket connection. Only writeLoop writes data frames to the
+// connection; everything else goes through the send buffer.
+type wsClient struct {
+	conn      *websocket.Conn
+	principal *auth.Principal
+	kind      string
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// Guarded by hub.mu
+	channels map[string]struct{}
+
+	// Set for project tails, which filter what the hub hands them
+	tail *logTail
+
+	// Owned by the read loop
+	deploymentID string
+	timeRange    string
+}
+
+// enqueue queues a message without blocking. A client whose buffer is full is not
+// keeping up and is disconnected rather than holding back the others.
+func (c *wsClient) enqueue(msg []byte) {
+	select {
+	case c.send <- msg:
+	case <-c.done:
+	default:
+		logger.Warn("Evicting slow WebSocket client", logger.String("stream", c.kind))
+		c.close(websocket.ClosePolicyViolation, "client too slow")
+	}
+}
+
+func (c *wsClient) sendJSON(msg wsMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		logger.Error("Failed to encode WebSocket message", logger.Err(err))
+		return
+	}
+	c.enqueue(data)
+}
+
+func (c *wsClient) sendError(deploymentID, message string) {
+	c.sendJSON(wsMessage{Type: "error", DeploymentID: deploymentID, Error: message})
+}
+
+// close sends a close frame and closes the connection, which ends both loops
+func (c *wsClient) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+		c.conn.Close()
+	})
+}
+
+// readLoop handles client requests until the connection drops or stops answering pings
+func (c *wsClient) readLoop(handle func(wsRequest)) {
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				logger.Warn("WebSocket connection lost", logger.String("stream", c.kind), logger.Err(err))
+			}
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var req wsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.sendError("", "Invalid message")
+			continue
+		}
+		handle(req)
+	}
+}
+
+func (c *wsClient) writeLoop() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.conn.Close()
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// serveStream streams the route deployment's metrics or logs from the hub. The client
+// can switch deployment or time range over the socket without reconnecting.
+func (s *Server) serveStream(c *gin.Context, kind string) {
+	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.Error("Failed to upgrade WebSocket connection", logger.String("stream", kind), logger.Err(err))
+		return
+	}
+
+	client := &wsClient{
+		conn:      conn,
+		principal: principal(c),
+		kind:      kind,
+		send:      make(chan []byte, wsSendBuffer),
+		done:      make(chan struct{}),
+		channels:  make(map[string]struct{}),
+	}
+	if err := s.hub.register(client); err != nil {
+		conn.Close()
+		return
+	}
+	defer func() {
+		s.hub.unregister(client)
+		client.close(websocket.CloseNormalClosure, "")
+	}()
+
+	go client.writeLoop()
+
+	logger.Info("WebSocket stream started",
+		logger.String("stream", kind),
+		logger.String("deployment_id", c.Param("deploymentId")))
+
+	s.handleStreamRequest(client, wsRequest{
+		Action:       "subscribe",
+		DeploymentID: c.Param("deploymentId"),
+		TimeRange:    c.DefaultQuery("timeRange", "1h"),
+	})
+	client.readLoop(func(req wsRequest) {
+		s.handleStreamRequest(client, req)
+	})
+}
+
+func (s *Server) handleStreamRequest(client *wsClient, req wsRequest) {
+	switch req.Action {
+	case "ping":
+		client.sendJSON(wsMessage{Type: "pong"})
+
+	case "subscribe":
+		deploymentID := req.DeploymentID
+		if deploymentID == "" {
+			deploymentID = client.deploymentID
+		}
+		timeRange := req.TimeRange
+		if timeRange == "" {
+			timeRange = client.timeRange
+		}
+		if deploymentID == "" {
+			client.sendError("", "deployment_id is required")
+			return
+		}
+		if !isValidTimeRange(timeRange) {
+			client.sendError(deploymentID, "time_range must be one of 1h, 6h, 24h, 7d, 30d")
+			return
+		}
+
+		if deploymentID != client.deploymentID {
+			if err := s.authorizeStream(client, deploymentID); err != nil {
+				client.sendError(deploymentID, streamErrorMessage(err, deploymentID))
+				return
+			}
+			if err := s.hub.subscribe(client, deploymentID); err != nil {
+				client.sendError(deploymentID, streamErrorMessage(err, deploymentID))
+				return
+			}
+			client.deploymentID = deploymentID
+		}
+		client.timeRange = timeRange
+
+		client.sendJSON(wsMessage{Type: "subscribed", DeploymentID: deploymentID, TimeRange: timeRange})
+		if client.kind == streamMetrics {
+			s.sendMetricsHistory(client)
+		}
+
+	case "unsubscribe":
+		s.hub.unsubscribe(client)
+		client.sendJSON(wsMessage{Type: "unsubscribed", DeploymentID: client.deploymentID})
+		client.deploymentID = ""
+
+	default:
+		client.sendError(client.deploymentID, "Unknown action")
+	}
+}
+
+// authorizeStream checks a subscription the same way the HTTP routes do, since a
+// client can ask for a deployment other than the one it connected for
+func (s *Server) authorizeStream(client *wsClient, deploymentID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	projectID, err := s.lookupProject(ctx, deploymentProjectQuery, deploymentID)
+	if err != nil {
+		return err
+	}
+	permissions, err := s.auth.ProjectPermissions(ctx, client.principal, projectID)
+	if err != nil {
+		return err
+	}
+
+	permission := permDeploymentRead
+	if client.kind == streamLogs {
+		permission = permLogsRead
+	}
+	if !permissions.Has(permission) {
+		return auth.ErrForbidden
+	}
+	return nil
+}
+
+// sendMetricsHistory sends the samples of the client's time range so a dashboard can
+// draw its chart before live updates arrive
+func (s *Server) sendMetricsHistory(client *wsClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	points, err := s.getDeploymentTimeSeriesData(ctx, client.deploymentID, client.timeRange)
+	if err != nil {
+		logger.Error("Failed to load metrics history",
+			logger.String("deployment_id", client.deploymentID),
+			logger.Err(err))
+		client.sendError(client.deploymentID, "Failed to load metrics history")
+		return
+	}
+
+	client.sendJSON(wsMessage{
+		Type:         "history",
+		DeploymentID: client.deploymentID,
+		TimeRange:    client.timeRange,
+		Data:         points,
+	})
+}
+
+func streamErrorMessage(err error, deploymentID string) string {
+	switch {
+	case errors.Is(err, auth.ErrResourceNotFound):
+		return "Deployment not found"
+	case errors.Is(err, auth.ErrForbidden):
+		return "Insufficient permissions"
+	default:
+		logger.Error("Failed to subscribe to stream", logger.String("deployment_id", deploymentID), logger.Err(err))
+		return "Failed to subscribe"
+	}
+}
+
+func isValidTimeRange(timeRange string) bool {
+	switch timeRange {
+	case "1h", "6h", "24h", "7d", "30d":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrAPITokenNotFound  = errors.New("API token not found")
+	ErrAPITokenNameTaken = errors.New("an API token with this name already exists")
+)
+
+// APIToken gives machine access to one project. Token is only set in the response that
+// created it.
+type APIToken struct {
+	ID              string     `json:"id"`
+	ProjectID       string     `json:"project_id"`
+	Name            string     `json:"name"`
+	Token           string     `json:"token,omitempty"`
+	TokenPrefix     string     `json:"token_prefix"`
+	Scopes          []string   `json:"scopes"`
+	CreatedByUserID string     `json:"created_by_user_id,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func (t *APIToken) Validate() error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" || len(t.Name) > 100 {
+		return fmt.Errorf("name is required and must be at most 100 characters")
+	}
+	if len(t.Scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range t.Scopes {
+		if resource, action, ok := strings.Cut(scope, ":"); !ok || resource == "" || action == "" {
+			return fmt.Errorf("invalid scope %q, expected resource:action", scope)
+		}
+	}
+	if t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	return nil
+}
+
+// CreateAPIToken issues a token for the project. Its scopes must be permissions the
+// creator holds on the project, so a token never outranks whoever made it.
+func (a *Authenticator) CreateAPIToken(ctx context.Context, creator *Principal, t *APIToken) error {
+	held, err := a.ProjectPermissions(ctx, creator, t.ProjectID)
+	if err != nil {
+		return err
+	}
+	scopes := map[string]bool{}
+	for _, scope := range t.Scopes {
+		if !held.Has(scope) {
+			return fmt.Errorf("%w: scope %s", ErrForbidden, scope)
+		}
+		scopes[scope] = true
+	}
+	t.Scopes = t.Scopes[:0]
+	for scope := range scopes {
+		t.Scopes = append(t.Scopes, scope)
+	}
+	sort.Strings(t.Scopes)
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	t.Token = apiTokenPrefix + hex.EncodeToString(raw)
+	t.TokenPrefix = t.Token[:len(apiTokenPrefix)+6]
+	t.CreatedByUserID = creator.UserID
+
+	scopesJSON, _ := json.Marshal(t.Scopes)
+	err = a.db.QueryRowContext(ctx, `
+		INSERT INTO api_tokens (project_id, name, token_hash, token_prefix, scopes, created_by_user_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		t.ProjectID, t.Name, hashToken(t.Token), t.TokenPrefix, scopesJSON, nullIfEmpty(t.CreatedByUserID), t.ExpiresAt,
+	).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrAPITokenNameTaken
+		}
+		return err
+	}
+	return nil
+}
+
+// ListAPITokens returns the project's active tokens without their secret
+func (a *Authenticator) ListAPITokens(ctx context.Context, projectID string) ([]APIToken, error) {
+	rows, err := a.db.QueryContext(ctx, `
+		SELECT id, project_id, name, token_prefix, scopes, COALESCE(created_by_user_id::text, ''),
+		       expires_at, last_used_at, created_at
+		FROM api_tokens
+		WHERE project_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		var t APIToken
+		var scopes []byte
+		var expires, lastUsed sql.NullTime
+		if err := rows.Scan(&t.ID, &t.ProjectID, &t.Name, &t.TokenPrefix, &scopes, &t.CreatedByUserID,
+			&expires, &lastUsed, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(scopes, &t.Scopes)
+		if expires.Valid {
+			t.ExpiresAt = &expires.Time
+		}
+		if lastUsed.Valid {
+			t.LastUsedAt = &lastUsed.Time
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeAPIToken stops the token from authenticating and frees its name
+func (a *Authenticator) RevokeAPIToken(ctx context.Context, projectID, tokenID string) error {
+	res, err := a.db.ExecContext(ctx, `
+		UPDATE api_tokens SET revoked_at = NOW()
+		WHERE id = $1 AND project_id = $2 AND revoked_at IS NULL`, tokenID, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAPITokenNotFound
+	}
+	return nil
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,317 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apiTokenPrefix = "obt_at_"
+
+	// Permissions are cached briefly so that streams and dashboards polling every few
+	// seconds do not query RBAC on every request
+	permissionCacheTTL = time.Minute
+
+	// Executives (ceo, cto, cfo) reach every project of their company, including the
+	// projects of teams they are not part of
+	executiveHierarchyLevel = 2
+)
+
+var (
+	ErrUnauthenticated  = errors.New("authentication required")
+	ErrForbidden        = errors.New("insufficient permissions")
+	ErrResourceNotFound = errors.New("resource not found")
+)
+
+type Kind string
+
+const (
+	KindUser     Kind = "user"
+	KindAPIToken Kind = "api_token"
+	KindService  Kind = "service" // Internal services, e.g. platform log ingestion
+)
+
+// Principal is the caller of a request
+type Principal struct {
+	Kind      Kind
+	UserID    string // Users; the creator for API tokens
+	TokenID   string // API tokens
+	ProjectID string // API tokens are scoped to one project
+	Scopes    Permissions
+}
+
+// Permissions is a set of RBAC permissions, keyed "resource:action"
+type Permissions map[string]bool
+
+func (p Permissions) Has(permission string) bool {
+	return p[permission]
+}
+
+// Authenticator resolves session tokens, API tokens and service tokens to principals,
+// and principals to their permissions on a project or team
+type Authenticator struct {
+	db            *sql.DB
+	serviceTokens [][]byte
+
+	mu    sync.Mutex
+	cache map[string]cachedPermissions
+}
+
+type cachedPermissions struct {
+	permissions Permissions
+	err         error
+	expires     time.Time
+}
+
+func NewAuthenticator(db *sql.DB, serviceTokens []string) *Authenticator {
+	a := &Authenticator{db: db, cache: map[string]cachedPermissions{}}
+	for _, t := range serviceTokens {
+		if t = strings.TrimSpace(t); t != "" {
+			a.serviceTokens = append(a.serviceTokens, []byte(t))
+		}
+	}
+	return a
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate resolves a bearer credential: a service token, an API token or a user's
+// session
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	for _, st := range a.serviceTokens {
+		if subtle.ConstantTimeCompare(st, []byte(token)) == 1 {
+			return &Principal{Kind: KindService}, nil
+		}
+	}
+
+	if strings.HasPrefix(token, apiTokenPrefix) {
+		return a.authenticateAPIToken(ctx, token)
+	}
+
+	var userID string
+	err := a.db.QueryRowContext(ctx, `
+		SELECT s.user_id
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.access_token = $1 AND s.expires_at > NOW() AND u.status = 'active'`, token).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrUnauthenticated
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{Kind: KindUser, UserID: userID}, nil
+}
+
+func (a *Authenticator) authenticateAPIToken(ctx context.Context, token string) (*Principal, error) {
+	p := Principal{Kind: KindAPIToken}
+	var createdBy sql.NullString
+	var scopes []byte
+	err := a.db.QueryRowContext(ctx, `
+		UPDATE api_tokens SET last_used_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		RETURNING id, project_id, created_by_user_id, scopes`, hashToken(token)).Scan(&p.TokenID, &p.ProjectID, &createdBy, &scopes)
+	if err == sql.ErrNoRows {
+		return nil, ErrUnauthenticated
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p.UserID = createdBy.String
+	var list []string
+	json.Unmarshal(scopes, &list)
+	p.Scopes = Permissions{}
+	for _, s := range list {
+		p.Scopes[s] = true
+	}
+	return &p, nil
+}
+
+// ProjectPermissions returns what the principal may do on a project. Users need to
+// work for the project's company and, for team projects, be on the team; their
+// company role grants the permissions and the team can grant or revoke single ones.
+func (a *Authenticator) ProjectPermissions(ctx context.Context, p *Principal, projectID string) (Permissions, error) {
+	switch p.Kind {
+	case KindService:
+		return a.allPermissions(ctx)
+	case KindAPIToken:
+		if p.ProjectID != projectID {
+			return nil, ErrForbidden
+		}
+		return p.Scopes, nil
+	}
+
+	return a.cached("project:"+projectID+":"+p.UserID, func() (Permissions, error) {
+		var companyID, teamID sql.NullString
+		err := a.db.QueryRowContext(ctx, `
+			SELECT company_id, team_id FROM projects WHERE id = $1 AND deleted_at IS NULL`, projectID).Scan(&companyID, &teamID)
+		if err == sql.ErrNoRows {
+			return nil, ErrResourceNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return a.userPermissions(ctx, p.UserID, companyID.String, teamID.String)
+	})
+}
+
+// TeamPermissions returns what the principal may do on a team's resources, e.g. its
+// on-call schedules. API tokens are scoped to projects and have no team access.
+func (a *Authenticator) TeamPermissions(ctx context.Context, p *Principal, teamID string) (Permissions, error) {
+	switch p.Kind {
+	case KindService:
+		return a.allPermissions(ctx)
+	case KindAPIToken:
+		return nil, ErrForbidden
+	}
+
+	return a.cached("team:"+teamID+":"+p.UserID, func() (Permissions, error) {
+		var companyID string
+		err := a.db.QueryRowContext(ctx, `
+			SELECT company_id FROM teams WHERE id = $1 AND is_active IS NOT FALSE`, teamID).Scan(&companyID)
+		if err == sql.ErrNoRows {
+			return nil, ErrResourceNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return a.userPermissions(ctx, p.UserID, companyID, teamID)
+	})
+}
+
+// userPermissions resolves a user's permissions within a company, narrowed to a team
+// when teamID is set
+func (a *Authenticator) userPermissions(ctx context.Context, userID, companyID, teamID string) (Permissions, error) {
+	var isOwner, onTeam bool
+	var roleName sql.NullString
+	var hierarchy sql.NullInt64
+	var teamMemberID sql.NullString
+	err := a.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(c.owner_user_id = $2, false),
+			r.name::text,
+			r.hierarchy_level,
+			tm.id,
+			COALESCE($3 = '' OR t.owner_user_id = $2 OR tm.id IS NOT NULL, false)
+		FROM companies c
+		LEFT JOIN company_users cu ON cu.company_id = c.id AND cu.user_id = $2
+		LEFT JOIN roles r ON r.id = cu.role
+		LEFT JOIN teams t ON $3 <> '' AND t.id::text = $3
+		LEFT JOIN team_members tm ON tm.team_id = t.id AND tm.user_id = $2
+		WHERE c.id = $1`, companyID, userID, teamID).Scan(&isOwner, &roleName, &hierarchy, &teamMemberID, &onTeam)
+	if err == sql.ErrNoRows {
+		return nil, ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if isOwner {
+		return a.allPermissions(ctx)
+	}
+	if !roleName.Valid {
+		return nil, ErrForbidden
+	}
+	if !onTeam && hierarchy.Int64 > executiveHierarchyLevel {
+		return nil, ErrForbidden
+	}
+
+	permissions := Permissions{}
+	rows, err := a.db.QueryContext(ctx, `
+		SELECT permission, granted FROM (
+			SELECT p.resource::text || ':' || p.action::text AS permission, true AS granted, 0 AS source
+			FROM role_permissions rp
+			JOIN permissions p ON p.id = rp.permission_id
+			WHERE rp.role_name::text = $1
+			UNION ALL
+			SELECT p.resource::text || ':' || p.action::text, tmp.is_granted, 1
+			FROM team_member_permissions tmp
+			JOIN permissions p ON p.id = tmp.permission_id
+			WHERE tmp.team_member_id::text = $2
+		) perms
+		ORDER BY source`, roleName.String, teamMemberID.String)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Team overrides come after the role's permissions and win
+	for rows.Next() {
+		var permission string
+		var granted bool
+		if err := rows.Scan(&permission, &granted); err != nil {
+			return nil, err
+		}
+		if granted {
+			permissions[permission] = true
+		} else {
+			delete(permissions, permission)
+		}
+	}
+	return permissions, rows.Err()
+}
+
+func (a *Authenticator) allPermissions(ctx context.Context) (Permissions, error) {
+	return a.cached("all", func() (Permissions, error) {
+		rows, err := a.db.QueryContext(ctx, `SELECT resource::text || ':' || action::text FROM permissions`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		permissions := Permissions{}
+		for rows.Next() {
+			var permission string
+			if err := rows.Scan(&permission); err != nil {
+				return nil, err
+			}
+			permissions[permission] = true
+		}
+		return permissions, rows.Err()
+	})
+}
+
+// cached memoizes a permission lookup, including denials. Database errors are not
+// cached.
+func (a *Authenticator) cached(key string, load func() (Permissions, error)) (Permissions, error) {
+	now := time.Now()
+	a.mu.Lock()
+	entry, ok := a.cache[key]
+	a.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.permissions, entry.err
+	}
+
+	permissions, err := load()
+	if err != nil && !errors.Is(err, ErrForbidden) && !errors.Is(err, ErrResourceNotFound) {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	if len(a.cache) > 10000 {
+		for k, e := range a.cache {
+			if now.After(e.expires) {
+				delete(a.cache, k)
+			}
+		}
+	}
+	a.cache[key] = cachedPermissions{permissions: permissions, err: err, expires: now.Add(permissionCacheTTL)}
+	a.mu.Unlock()
+	return permissions, err
+}
@@ -0,0 +1,62 @@
+package httpmetrics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// DurationBuckets are the upper bounds (seconds) of the request duration histogram kept
+// in the per-deployment totals
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// TotalsKey is the Redis hash of monotonic HTTP counters for a deployment. Fields:
+// requests_<class> per status class (e.g. requests_2xx), duration_us_sum, and
+// bucket_<i> holding the requests that fell in DurationBuckets[i] (not cumulative).
+func TotalsKey(deploymentID string) string {
+	return fmt.Sprintf("metrics:%s:http_totals", deploymentID)
+}
+
+// StatusClass returns the status class label of a response code, e.g. "2xx"
+func StatusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// recordTotals adds the entries to the deployment counters. The counters only ever grow,
+// so Prometheus can rate() them; a Redis flush shows up as a counter reset.
+func (c *TraefikCollector) recordTotals(ctx context.Context, entries []LogEntry) error {
+	type totals map[string]int64
+	byDeployment := make(map[string]totals)
+
+	for _, e := range entries {
+		t, ok := byDeployment[e.DeploymentID]
+		if !ok {
+			t = make(totals)
+			byDeployment[e.DeploymentID] = t
+		}
+
+		t["requests_"+StatusClass(e.StatusCode)]++
+		t["duration_us_sum"] += e.ResponseTime / 1000
+
+		seconds := float64(e.ResponseTime) / 1e9
+		for i, le := range DurationBuckets {
+			if seconds <= le {
+				t["bucket_"+strconv.Itoa(i)]++
+				break
+			}
+		}
+	}
+
+	pipe := c.redis.Pipeline()
+	for deploymentID, t := range byDeployment {
+		key := TotalsKey(deploymentID)
+		for field, n := range t {
+			pipe.HIncrBy(ctx, key, field, n)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -19,6 +19,9 @@ import (
 	"go.uber.org/zap"
 )
 
+// Requests at least this slow are always sampled
+const slowRequestThreshold = time.Second
+
 type TraefikCollector struct {
 	db            *sql.DB
 	redis         *db.RedisClient
@@ -127,6 +130,8 @@ type TraefikLogEntry struct {
 	DownstreamStatus int    `json:"DownstreamStatus"`
 	Duration         int64  `json:"Duration"`
 	StartLocal       string `json:"StartLocal"`
+	// Kept by the accessLog.fields.headers config
+	RequestTraceparent string `json:"request_Traceparent"`
 }
 
 func (c *TraefikCollector) processLogs(ctx context.Context) error {
@@ -189,6 +194,7 @@ func (c *TraefikCollector) processLogs(ctx context.Context) error {
 			ResponseTime: traefikEntry.Duration,
 			Timestamp:    ts,
 			ClientIP:     traefikEntry.ClientAddr,
+			TraceID:      traceIDFromParent(traefikEntry.RequestTraceparent),
 		}
 
 		// Match domain to deployment
@@ -229,6 +235,9 @@ func (c *TraefikCollector) processLogs(ctx context.Context) error {
 				logger.Error("Failed to store log entries", zap.Error(err))
 			}
 			logger.Info("Stored HTTP metrics entries", zap.Int("count", len(entries)))
+			if err := c.storeSampledRequests(ctx, entries); err != nil {
+				logger.Error("Failed to store sampled requests", zap.Error(err))
+			}
 			entries = entries[:0]
 		}
 	}
@@ -264,6 +273,22 @@ type LogEntry struct {
 	ResponseSize int64
 	ClientIP     string
 	DeploymentID string
+	TraceID      string
+}
+
+// traceIDFromParent returns the trace ID of a W3C traceparent header
+// (version-traceid-parentid-flags), or "" when it is missing or malformed
+func traceIDFromParent(header string) string {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || parts[1] == strings.Repeat("0", 32) {
+		return ""
+	}
+	for _, c := range parts[1] {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return ""
+		}
+	}
+	return parts[1]
 }
 
 func (c *TraefikCollector) storeEntries(ctx context.Context, entries []LogEntry) error {
@@ -271,6 +296,10 @@ func (c *TraefikCollector) storeEntries(ctx context.Context, entries []LogEntry)
 		return nil
 	}
 
+	if err := c.recordTotals(ctx, entries); err != nil {
+		logger.Error("Failed to record HTTP totals", zap.Error(err))
+	}
+
 	aggregates := make(map[string]*MinuteAggregate)
 
 	for _, e := range entries {
@@ -400,14 +429,15 @@ func (c *TraefikCollector) storeSampledRequests(ctx context.Context, entries []L
 	sampleQuery := `
 		INSERT INTO http_requests_sampled 
 			(deployment_id, timestamp, method, path, path_normalized, status_code, latency_ms, 
-			 request_size, response_size, client_ip, country_code, region, city, router_name, service_name)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+			 request_size, response_size, client_ip, country_code, region, city, router_name, service_name, trace_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NULLIF($16, ''))
 		ON CONFLICT DO NOTHING
 	`
 
 	for _, e := range entries {
-		// Sample 20% of requests for endpoint tracking (increased from 1% for better visibility)
-		if time.Now().UnixNano()%5 != 0 {
+		// Sample 20% of requests for endpoint tracking (increased from 1% for better visibility).
+		// Slow requests are always kept so they can be followed to their trace.
+		if e.ResponseTime < int64(slowRequestThreshold) && time.Now().UnixNano()%5 != 0 {
 			continue
 		}
 
@@ -442,6 +472,7 @@ func (c *TraefikCollector) storeSampledRequests(ctx context.Context, entries []L
 			"", // city
 			"", // router_name
 			"", // service_name
+			e.TraceID,
 		)
 		if err != nil {
 			logger.Error("Failed to store sampled request", zap.Error(err))
@@ -0,0 +1,201 @@
+package logdrain
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	TypeSyslog = "syslog"
+	TypeHTTP   = "http"
+	TypeLoki   = "loki"
+	TypeS3     = "s3"
+
+	lokiPushPath = "/loki/api/v1/push"
+)
+
+var (
+	ErrDrainNotFound  = errors.New("log drain not found")
+	ErrDrainNameTaken = errors.New("a log drain with this name already exists")
+)
+
+// Record is one deployment log line handed to the drains
+type Record struct {
+	ProjectID    string          `json:"project_id"`
+	DeploymentID string          `json:"deployment_id"`
+	ContainerID  string          `json:"container_id"`
+	Timestamp    time.Time       `json:"timestamp"`
+	Level        string          `json:"level"`
+	Message      string          `json:"message"`
+	Source       string          `json:"source"`
+	Stream       string          `json:"stream,omitempty"`
+	Metadata     json.RawMessage `json:"metadata,omitempty"`
+}
+
+// Drain forwards a project's deployment logs to an external sink: a syslog server
+// (RFC 5424 over TCP or TLS), an HTTP endpoint taking NDJSON, a Loki push API or an
+// S3-compatible bucket. Secrets are write-only.
+type Drain struct {
+	ID                   string            `json:"id"`
+	ProjectID            string            `json:"project_id"`
+	Name                 string            `json:"name"`
+	Type                 string            `json:"type"`
+	URL                  string            `json:"url"`
+	BasicAuthUsername    string            `json:"basic_auth_username"`
+	BasicAuthPassword    string            `json:"basic_auth_password,omitempty"`
+	BearerToken          string            `json:"bearer_token,omitempty"`
+	HasPassword          bool              `json:"has_password"`
+	HasBearerToken       bool              `json:"has_bearer_token"`
+	Headers              map[string]string `json:"headers"`
+	Labels               map[string]string `json:"labels"`
+	TLSCACert            string            `json:"tls_ca_cert"`
+	S3Bucket             string            `json:"s3_bucket"`
+	S3Region             string            `json:"s3_region"`
+	S3Prefix             string            `json:"s3_prefix"`
+	S3AccessKeyID        string            `json:"s3_access_key_id"`
+	S3SecretAccessKey    string            `json:"s3_secret_access_key,omitempty"`
+	HasS3SecretAccessKey bool              `json:"has_s3_secret_access_key"`
+	BatchSize            int               `json:"batch_size"`
+	FlushIntervalSeconds int               `json:"flush_interval_seconds"`
+	Enabled              bool              `json:"enabled"`
+	Stats                DeliveryStats     `json:"stats"`
+	CreatedAt            time.Time         `json:"created_at"`
+	UpdatedAt            time.Time         `json:"updated_at"`
+}
+
+// DeliveryStats counts the records a drain delivered, dropped (buffer full or rejected
+// by the sink) and still holds in its buffer, and the failed delivery attempts
+type DeliveryStats struct {
+	Delivered       int64      `json:"delivered"`
+	Dropped         int64      `json:"dropped"`
+	Retries         int64      `json:"retries"`
+	Buffered        int64      `json:"buffered"`
+	LastDeliveredAt *time.Time `json:"last_delivered_at"`
+	LastError       string     `json:"last_error"`
+	LastErrorAt     *time.Time `json:"last_error_at"`
+}
+
+// Headers the HTTP and Loki sinks set themselves
+var reservedDrainHeaders = map[string]bool{
+	"authorization": true, "content-type": true, "content-encoding": true,
+	"content-length": true, "host": true, "user-agent": true,
+}
+
+// Labels every Loki stream carries already
+var reservedLokiLabels = map[string]bool{
+	"project_id": true, "deployment_id": true, "level": true, "stream": true, "source": true,
+}
+
+var lokiLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Batch defaults per type: buckets get few large objects, the others near real-time
+// batches
+var drainDefaults = map[string]struct{ batchSize, flushInterval int }{
+	TypeSyslog: {200, 2},
+	TypeHTTP:   {500, 5},
+	TypeLoki:   {500, 5},
+	TypeS3:     {5000, 60},
+}
+
+func (d *Drain) Validate() error {
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" || len(d.Name) > 100 {
+		return fmt.Errorf("name is required and must be at most 100 characters")
+	}
+
+	defaults, ok := drainDefaults[d.Type]
+	if !ok {
+		return fmt.Errorf("type must be syslog, http, loki or s3")
+	}
+
+	if len(d.URL) > 2048 {
+		return fmt.Errorf("url must be at most 2048 characters")
+	}
+	u, err := url.Parse(d.URL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("url must be an absolute URL")
+	}
+
+	switch d.Type {
+	case TypeSyslog:
+		if u.Scheme != "tcp" && u.Scheme != "tls" {
+			return fmt.Errorf("syslog url must be tcp://host:port or tls://host:port")
+		}
+		if _, port, err := net.SplitHostPort(u.Host); err != nil || port == "" {
+			return fmt.Errorf("syslog url must include a port")
+		}
+	case TypeHTTP, TypeLoki:
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("url must be an absolute http(s) URL")
+		}
+		if d.Type == TypeLoki && (u.Path == "" || u.Path == "/") {
+			u.Path = lokiPushPath
+			d.URL = u.String()
+		}
+	case TypeS3:
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("s3 url must be the http(s) endpoint of the bucket's service")
+		}
+		if u.Path != "" && u.Path != "/" {
+			return fmt.Errorf("s3 url must not have a path; set s3_bucket and s3_prefix instead")
+		}
+		if len(d.S3Bucket) < 3 || len(d.S3Bucket) > 63 {
+			return fmt.Errorf("s3_bucket is required")
+		}
+		if d.S3AccessKeyID == "" {
+			return fmt.Errorf("s3_access_key_id is required")
+		}
+		d.S3Prefix = strings.TrimPrefix(d.S3Prefix, "/")
+		if d.S3Prefix != "" && !strings.HasSuffix(d.S3Prefix, "/") {
+			d.S3Prefix += "/"
+		}
+		if len(d.S3Prefix) > 255 {
+			return fmt.Errorf("s3_prefix must be at most 255 characters")
+		}
+	}
+
+	if d.BearerToken != "" && (d.BasicAuthUsername != "" || d.BasicAuthPassword != "") {
+		return fmt.Errorf("use either basic auth or a bearer token, not both")
+	}
+
+	for name := range d.Headers {
+		if reservedDrainHeaders[strings.ToLower(name)] {
+			return fmt.Errorf("header %s is set by the log drain", name)
+		}
+	}
+
+	for name := range d.Labels {
+		if !lokiLabelName.MatchString(name) {
+			return fmt.Errorf("label %s is not a valid Loki label name", name)
+		}
+		if reservedLokiLabels[name] {
+			return fmt.Errorf("label %s is set by the log drain", name)
+		}
+	}
+
+	if d.TLSCACert != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(d.TLSCACert)) {
+		return fmt.Errorf("tls_ca_cert must be a PEM encoded certificate")
+	}
+
+	if d.BatchSize == 0 {
+		d.BatchSize = defaults.batchSize
+	}
+	if d.BatchSize < 1 || d.BatchSize > 10000 {
+		return fmt.Errorf("batch_size must be between 1 and 10000")
+	}
+	if d.FlushIntervalSeconds == 0 {
+		d.FlushIntervalSeconds = defaults.flushInterval
+	}
+	if d.FlushIntervalSeconds < 1 || d.FlushIntervalSeconds > 3600 {
+		return fmt.Errorf("flush_interval_seconds must be between 1 and 3600")
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package logdrain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// httpSink posts every batch as NDJSON, one record per line
+type httpSink struct {
+	drain  *Drain
+	client *http.Client
+}
+
+func newHTTPSink(d *Drain, dialer *net.Dialer) (*httpSink, error) {
+	transport, err := newTransport(d, dialer)
+	if err != nil {
+		return nil, err
+	}
+	return &httpSink{drain: d, client: &http.Client{Timeout: sendTimeout, Transport: transport}}, nil
+}
+
+func (s *httpSink) Send(ctx context.Context, records []Record) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for i := range records {
+		if err := encoder.Encode(&records[i]); err != nil {
+			return err
+		}
+	}
+	return post(ctx, s.client, s.drain, "application/x-ndjson", body.Bytes())
+}
+
+func (s *httpSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// lokiSink pushes batches to the Loki push API, one stream per deployment, level and
+// output stream. Tenants go in an X-Scope-OrgID header.
+type lokiSink struct {
+	drain  *Drain
+	client *http.Client
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func newLokiSink(d *Drain, dialer *net.Dialer) (*lokiSink, error) {
+	transport, err := newTransport(d, dialer)
+	if err != nil {
+		return nil, err
+	}
+	return &lokiSink{drain: d, client: &http.Client{Timeout: sendTimeout, Transport: transport}}, nil
+}
+
+func (s *lokiSink) Send(ctx context.Context, records []Record) error {
+	sorted := make([]*Record, len(records))
+	for i := range records {
+		sorted[i] = &records[i]
+	}
+	// Loki rejects entries older than the newest of their stream on older versions
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	streams := make(map[string]*lokiStream)
+	var order []string
+	for _, r := range sorted {
+		key := r.DeploymentID + "\x00" + r.Level + "\x00" + r.Stream + "\x00" + r.Source
+		stream, ok := streams[key]
+		if !ok {
+			labels := map[string]string{
+				"project_id":    r.ProjectID,
+				"deployment_id": r.DeploymentID,
+				"level":         r.Level,
+				"source":        r.Source,
+			}
+			if r.Stream != "" {
+				labels["stream"] = r.Stream
+			}
+			for name, value := range s.drain.Labels {
+				labels[name] = value
+			}
+			stream = &lokiStream{Stream: labels}
+			streams[key] = stream
+			order = append(order, key)
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(r.Timestamp.UnixNano(), 10), r.Message})
+	}
+
+	payload := struct {
+		Streams []*lokiStream `json:"streams"`
+	}{}
+	for _, key := range order {
+		payload.Streams = append(payload.Streams, streams[key])
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.client, s.drain, "application/json", body)
+}
+
+func (s *lokiSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func post(ctx context.Context, client *http.Client, d *Drain, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range d.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "obtura-monitoring")
+	switch {
+	case d.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+d.BearerToken)
+	case d.BasicAuthUsername != "" || d.BasicAuthPassword != "":
+		req.SetBasicAuth(d.BasicAuthUsername, d.BasicAuthPassword)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return statusError(resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	return nil
+}
@@ -0,0 +1,509 @@
+package logdrain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"monitoring-service/pkg/logger"
+
+	"github.com/lib/pq"
+)
+
+const (
+	queueSize     = 10000 // Records held in memory per drain before new ones are dropped
+	maxRetryDelay = 5 * time.Minute
+	replayBatches = 20 // Buffered batches replayed per flush
+)
+
+// Manager runs one goroutine per enabled drain. Records are queued in memory, sent in
+// batches and, when the sink is unreachable, buffered on disk and replayed in order
+// with exponential backoff. Sync reloads the drains and records the delivery stats.
+type Manager struct {
+	db          *sql.DB
+	bufferDir   string
+	bufferBytes int64
+	dialer      *net.Dialer
+
+	mu          sync.RWMutex
+	workers     map[string]*drainWorker   // By drain ID
+	byProject   map[string][]*drainWorker // By project ID
+	deployments map[string]string         // Deployment ID -> project ID, for projects with drains
+}
+
+func NewManager(db *sql.DB, bufferDir string, bufferBytes int64, allowPrivate bool) *Manager {
+	return &Manager{
+		db:          db,
+		bufferDir:   bufferDir,
+		bufferBytes: bufferBytes,
+		dialer:      newDialer(allowPrivate),
+		workers:     make(map[string]*drainWorker),
+		byProject:   make(map[string][]*drainWorker),
+		deployments: make(map[string]string),
+	}
+}
+
+// Enqueue hands a deployment log line to the drains of its project. It never blocks;
+// a drain whose queue is full drops the record.
+func (m *Manager) Enqueue(r Record) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	projectID, ok := m.deployments[r.DeploymentID]
+	if !ok {
+		return
+	}
+	r.ProjectID = projectID
+
+	for _, w := range m.byProject[projectID] {
+		select {
+		case w.queue <- r:
+		default:
+			w.dropped.Add(1)
+		}
+	}
+}
+
+// Sync starts the drains that were created or enabled, reconfigures the changed ones
+// and stops the rest, then records the delivery stats. Buffers of deleted drains are
+// removed.
+func (m *Manager) Sync(ctx context.Context) error {
+	drains, err := m.loadDrains(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	current := m.workers
+	m.mu.RUnlock()
+
+	workers := make(map[string]*drainWorker)
+	byProject := make(map[string][]*drainWorker)
+	var projectIDs []string
+	var stopped []*drainWorker
+	exists := make(map[string]bool, len(drains))
+
+	for i := range drains {
+		d := &drains[i]
+		exists[d.ID] = true
+		if !d.Enabled {
+			continue
+		}
+
+		w, ok := current[d.ID]
+		if !ok {
+			if w, err = m.startWorker(d); err != nil {
+				logger.Error("Failed to start log drain", logger.String("drain_id", d.ID), logger.Err(err))
+				m.recordError(ctx, d.ID, err)
+				continue
+			}
+		} else if !w.version.Equal(d.UpdatedAt) {
+			// Reconfigured in place, keeping its queue and buffer
+			if err := m.updateWorker(ctx, w, d); err != nil {
+				logger.Error("Failed to update log drain", logger.String("drain_id", d.ID), logger.Err(err))
+				m.recordError(ctx, d.ID, err)
+			}
+		}
+
+		workers[d.ID] = w
+		if len(byProject[d.ProjectID]) == 0 {
+			projectIDs = append(projectIDs, d.ProjectID)
+		}
+		byProject[d.ProjectID] = append(byProject[d.ProjectID], w)
+	}
+
+	deployments, err := m.loadDeployments(ctx, projectIDs)
+	if err != nil {
+		for id, w := range workers {
+			if current[id] != w {
+				w.stop()
+			}
+		}
+		return err
+	}
+
+	m.mu.Lock()
+	for id, w := range m.workers {
+		if workers[id] != w {
+			stopped = append(stopped, w)
+		}
+	}
+	m.workers = workers
+	m.byProject = byProject
+	m.deployments = deployments
+	m.mu.Unlock()
+
+	// Swapped out first, so nothing is queued on a stopped drain
+	for _, w := range stopped {
+		w.stop()
+		m.recordStats(ctx, w)
+	}
+	for _, w := range workers {
+		m.recordStats(ctx, w)
+	}
+
+	if entries, err := os.ReadDir(m.bufferDir); err == nil {
+		for _, entry := range entries {
+			if !exists[entry.Name()] {
+				os.RemoveAll(filepath.Join(m.bufferDir, entry.Name()))
+			}
+		}
+	}
+
+	return nil
+}
+
+// Stop stops every drain, writing what it still holds in memory to its disk buffer
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	workers := m.workers
+	m.workers = make(map[string]*drainWorker)
+	m.byProject = make(map[string][]*drainWorker)
+	m.deployments = make(map[string]string)
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, w := range workers {
+		w.stop()
+		m.recordStats(ctx, w)
+	}
+}
+
+// buffered returns the records a drain holds in memory and on disk
+func (m *Manager) buffered(drainID string) (int64, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	w, ok := m.workers[drainID]
+	if !ok {
+		return 0, false
+	}
+	return w.buffered(), true
+}
+
+func (m *Manager) startWorker(d *Drain) (*drainWorker, error) {
+	sink, err := newSink(d, m.dialer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a disk buffer the drain still delivers, it just drops failed batches
+	buffer, err := openSpool(filepath.Join(m.bufferDir, d.ID), m.bufferBytes)
+	if err != nil {
+		logger.Warn("Log drain runs without a disk buffer", logger.String("drain_id", d.ID), logger.Err(err))
+		buffer = nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &drainWorker{
+		id:      d.ID,
+		version: d.UpdatedAt,
+		update:  make(chan drainConfig),
+		drain:   d,
+		sink:    sink,
+		spool:   buffer,
+		queue:   make(chan Record, queueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (m *Manager) updateWorker(ctx context.Context, w *drainWorker, d *Drain) error {
+	sink, err := newSink(d, m.dialer)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case w.update <- drainConfig{drain: d, sink: sink}:
+		w.version = d.UpdatedAt
+		return nil
+	case <-ctx.Done():
+		sink.Close()
+		return ctx.Err()
+	}
+}
+
+// loadDrains reads drains with their secrets: all of them, or only drainID
+func (m *Manager) loadDrains(ctx context.Context, drainID string) ([]Drain, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT id, project_id, name, drain_type, url, COALESCE(basic_auth_username, ''),
+		       COALESCE(basic_auth_password, ''), COALESCE(bearer_token, ''),
+		       COALESCE(headers, '{}'), COALESCE(labels, '{}'), COALESCE(tls_ca_cert, ''),
+		       COALESCE(s3_bucket, ''), COALESCE(s3_region, ''), COALESCE(s3_prefix, ''),
+		       COALESCE(s3_access_key_id, ''), COALESCE(s3_secret_access_key, ''),
+		       batch_size, flush_interval_seconds, enabled, updated_at
+		FROM log_drains
+		WHERE $1 = '' OR id::text = $1`, drainID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drains []Drain
+	for rows.Next() {
+		var d Drain
+		var headers, labels []byte
+		if err := rows.Scan(&d.ID, &d.ProjectID, &d.Name, &d.Type, &d.URL, &d.BasicAuthUsername,
+			&d.BasicAuthPassword, &d.BearerToken, &headers, &labels, &d.TLSCACert,
+			&d.S3Bucket, &d.S3Region, &d.S3Prefix, &d.S3AccessKeyID, &d.S3SecretAccessKey,
+			&d.BatchSize, &d.FlushIntervalSeconds, &d.Enabled, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(headers, &d.Headers)
+		json.Unmarshal(labels, &d.Labels)
+		drains = append(drains, d)
+	}
+	return drains, rows.Err()
+}
+
+// loadDeployments maps the live deployments of the given projects to their project,
+// using the same statuses the log shipper follows
+func (m *Manager) loadDeployments(ctx context.Context, projectIDs []string) (map[string]string, error) {
+	deployments := make(map[string]string)
+	if len(projectIDs) == 0 {
+		return deployments, nil
+	}
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT id, project_id FROM deployments
+		WHERE project_id = ANY($1) AND status IN ('active', 'running', 'starting', 'healthy')`,
+		pq.Array(projectIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var deploymentID, projectID string
+		if err := rows.Scan(&deploymentID, &projectID); err != nil {
+			return nil, err
+		}
+		deployments[deploymentID] = projectID
+	}
+	return deployments, rows.Err()
+}
+
+func (m *Manager) recordStats(ctx context.Context, w *drainWorker) {
+	delivered := w.delivered.Swap(0)
+	dropped := w.dropped.Swap(0)
+	retries := w.retries.Swap(0)
+
+	w.mu.Lock()
+	lastDelivered, lastError, lastErrorAt := w.lastDelivered, w.lastError, w.lastErrorAt
+	w.lastError = ""
+	w.mu.Unlock()
+
+	_, err := m.db.ExecContext(ctx, `
+		UPDATE log_drains SET
+			delivered_count = delivered_count + $2,
+			dropped_count = dropped_count + $3,
+			retry_count = retry_count + $4,
+			buffered_count = $5,
+			last_delivered_at = COALESCE($6, last_delivered_at),
+			last_error = COALESCE(NULLIF($7, ''), last_error),
+			last_error_at = CASE WHEN $7 != '' THEN $8 ELSE last_error_at END
+		WHERE id = $1`,
+		w.id, delivered, dropped, retries, w.buffered(),
+		nullTime(lastDelivered), lastError, nullTime(lastErrorAt))
+	if err != nil {
+		// Counted again next time
+		w.delivered.Add(delivered)
+		w.dropped.Add(dropped)
+		w.retries.Add(retries)
+		logger.Error("Failed to record log drain stats", logger.String("drain_id", w.id), logger.Err(err))
+	}
+}
+
+func (m *Manager) recordError(ctx context.Context, drainID string, drainErr error) {
+	_, err := m.db.ExecContext(ctx, `
+		UPDATE log_drains SET last_error = $2, last_error_at = NOW() WHERE id = $1`, drainID, drainErr.Error())
+	if err != nil {
+		logger.Error("Failed to record log drain error", logger.String("drain_id", drainID), logger.Err(err))
+	}
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+type drainWorker struct {
+	id      string
+	version time.Time // updated_at of the running config, owned by Sync
+	update  chan drainConfig
+
+	// Owned by run
+	drain      *Drain
+	sink       Sink
+	retryDelay time.Duration
+	retryAt    time.Time
+
+	spool *spool // nil when the buffer directory is unusable
+	queue chan Record
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	delivered atomic.Int64
+	dropped   atomic.Int64
+	retries   atomic.Int64
+
+	mu            sync.Mutex
+	lastDelivered time.Time
+	lastError     string
+	lastErrorAt   time.Time
+}
+
+type drainConfig struct {
+	drain *Drain
+	sink  Sink
+}
+
+func (w *drainWorker) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(time.Duration(w.drain.FlushIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, w.drain.BatchSize)
+	for {
+		select {
+		case <-w.ctx.Done():
+			// Keep what is still in memory for the next run
+			for len(w.queue) > 0 {
+				batch = append(batch, <-w.queue)
+			}
+			if len(batch) > 0 {
+				w.buffer(batch)
+			}
+			w.sink.Close()
+			return
+		case cfg := <-w.update:
+			w.sink.Close()
+			w.drain, w.sink = cfg.drain, cfg.sink
+			ticker.Reset(time.Duration(w.drain.FlushIntervalSeconds) * time.Second)
+			continue
+		case r := <-w.queue:
+			batch = append(batch, r)
+			if len(batch) < w.drain.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		w.flush(batch)
+		batch = make([]Record, 0, w.drain.BatchSize)
+	}
+}
+
+// flush sends a batch, unless earlier batches are still buffered: those go first, so
+// the sink receives records in order
+func (w *drainWorker) flush(batch []Record) {
+	if len(batch) > 0 {
+		if (w.spool != nil && !w.spool.empty()) || time.Now().Before(w.retryAt) {
+			w.buffer(batch)
+		} else if err := w.send(batch); err != nil && !isPermanent(err) {
+			w.buffer(batch)
+		}
+	}
+
+	if w.spool == nil {
+		return
+	}
+	for i := 0; i < replayBatches && !w.spool.empty() && !time.Now().Before(w.retryAt); i++ {
+		records, err := w.spool.peek()
+		if err != nil {
+			w.fail(err, int64(w.spool.segments[0].records))
+			w.spool.pop()
+			continue
+		}
+		if err := w.send(records); err != nil && !isPermanent(err) {
+			return
+		}
+		w.spool.pop()
+	}
+}
+
+// send delivers a batch. A transient failure schedules the next attempt with
+// exponential backoff; a permanent one drops the batch.
+func (w *drainWorker) send(records []Record) error {
+	ctx, cancel := context.WithTimeout(w.ctx, sendTimeout)
+	err := w.sink.Send(ctx, records)
+	cancel()
+
+	if err == nil {
+		w.delivered.Add(int64(len(records)))
+		w.retryDelay = 0
+		w.retryAt = time.Time{}
+		w.mu.Lock()
+		w.lastDelivered = time.Now()
+		w.mu.Unlock()
+		return nil
+	}
+	if w.ctx.Err() != nil {
+		// Stopping; the batch is buffered for the next run
+		return err
+	}
+
+	if isPermanent(err) {
+		w.fail(err, int64(len(records)))
+		return err
+	}
+
+	w.retries.Add(1)
+	w.retryDelay = min(max(2*w.retryDelay, time.Second), maxRetryDelay)
+	w.retryAt = time.Now().Add(w.retryDelay)
+	w.fail(err, 0)
+	return err
+}
+
+func (w *drainWorker) buffer(batch []Record) {
+	if w.spool == nil {
+		w.dropped.Add(int64(len(batch)))
+		return
+	}
+	dropped, err := w.spool.append(batch)
+	w.dropped.Add(int64(dropped))
+	if err != nil {
+		w.fail(err, 0)
+	}
+}
+
+// fail records the error and the records it cost
+func (w *drainWorker) fail(err error, dropped int64) {
+	w.dropped.Add(dropped)
+	logger.Warn("Log drain delivery failed",
+		logger.String("drain_id", w.id),
+		logger.String("project_id", w.drain.ProjectID),
+		logger.Err(err))
+
+	w.mu.Lock()
+	w.lastError = err.Error()
+	w.lastErrorAt = time.Now()
+	w.mu.Unlock()
+}
+
+func (w *drainWorker) buffered() int64 {
+	n := int64(len(w.queue))
+	if w.spool != nil {
+		n += w.spool.records.Load()
+	}
+	return n
+}
+
+func (w *drainWorker) stop() {
+	w.cancel()
+	<-w.done
+}
@@ -0,0 +1,92 @@
+package logdrain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Batches go to the bucket as gzipped NDJSON objects:
+//
+//	{s3_prefix}{project_id}/{yyyy}/{mm}/{dd}/{hhmmss}-{content_hash}.ndjson.gz
+//
+// The name comes from the content, so retrying a batch overwrites the same object.
+
+type s3Sink struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func newS3Sink(d *Drain, dialer *net.Dialer) (*s3Sink, error) {
+	u, err := url.Parse(d.URL)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := newTransport(d, dialer)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:     credentials.NewStaticV4(d.S3AccessKeyID, d.S3SecretAccessKey, ""),
+		Secure:    u.Scheme == "https",
+		Region:    d.S3Region,
+		Transport: transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	return &s3Sink{client: client, bucket: d.S3Bucket, prefix: d.S3Prefix}, nil
+}
+
+func (s *s3Sink) Send(ctx context.Context, records []Record) error {
+	var ndjson bytes.Buffer
+	encoder := json.NewEncoder(&ndjson)
+	for i := range records {
+		if err := encoder.Encode(&records[i]); err != nil {
+			return err
+		}
+	}
+	sum := sha256.Sum256(ndjson.Bytes())
+
+	var body bytes.Buffer
+	gzipWriter := gzip.NewWriter(&body)
+	if _, err := gzipWriter.Write(ndjson.Bytes()); err != nil {
+		return err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return err
+	}
+
+	first := records[0].Timestamp.UTC()
+	objectName := fmt.Sprintf("%s%s/%s/%s-%s.ndjson.gz",
+		s.prefix, records[0].ProjectID, first.Format("2006/01/02"), first.Format("150405"), hex.EncodeToString(sum[:8]))
+
+	_, err := s.client.PutObject(ctx, s.bucket, objectName, &body, int64(body.Len()),
+		minio.PutObjectOptions{
+			ContentType:     "application/x-ndjson",
+			ContentEncoding: "gzip",
+		})
+	if err != nil {
+		if resp := minio.ToErrorResponse(err); resp.StatusCode != 0 {
+			return statusError(resp.StatusCode, resp.Code+": "+resp.Message)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *s3Sink) Close() error {
+	return nil
+}
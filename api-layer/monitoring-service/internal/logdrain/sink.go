@@ -0,0 +1,102 @@
+package logdrain
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"monitoring-service/pkg/netguard"
+)
+
+const sendTimeout = 15 * time.Second
+
+// Sink delivers batches of records to one drain's destination. Send is only called by
+// the drain's own goroutine, so sinks may keep a connection between batches.
+type Sink interface {
+	Send(ctx context.Context, records []Record) error
+	Close() error
+}
+
+// permanentError is a rejection retrying will not fix, e.g. a 400 from the endpoint.
+// The batch is dropped instead of buffered.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func isPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// statusError turns a non-2xx response into an error. Client errors are permanent
+// except timeouts and rate limiting.
+func statusError(status int, body string) error {
+	err := fmt.Errorf("drain returned %d: %s", status, body)
+	if status/100 == 4 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests {
+		return &permanentError{err}
+	}
+	return err
+}
+
+// newDialer returns the dialer of all sinks. Drains are customer supplied, so unless
+// private targets are allowed (local development) they never reach the platform's own
+// network.
+func newDialer(allowPrivate bool) *net.Dialer {
+	if allowPrivate {
+		return &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	}
+	return netguard.Dialer("log drain")
+}
+
+// tlsConfig trusts the drain's CA certificate on top of the system roots
+func tlsConfig(d *Drain, serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+	if d.TLSCACert == "" {
+		return cfg, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM([]byte(d.TLSCACert)) {
+		return nil, fmt.Errorf("tls_ca_cert contains no PEM certificate")
+	}
+	cfg.RootCAs = pool
+	return cfg, nil
+}
+
+func newTransport(d *Drain, dialer *net.Dialer) (*http.Transport, error) {
+	tlsCfg, err := tlsConfig(d, "")
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	transport.TLSClientConfig = tlsCfg
+	return transport, nil
+}
+
+func newSink(d *Drain, dialer *net.Dialer) (Sink, error) {
+	switch d.Type {
+	case TypeSyslog:
+		return newSyslogSink(d, dialer)
+	case TypeHTTP:
+		return newHTTPSink(d, dialer)
+	case TypeLoki:
+		return newLokiSink(d, dialer)
+	case TypeS3:
+		return newS3Sink(d, dialer)
+	}
+	return nil, fmt.Errorf("unknown drain type %q", d.Type)
+}
@@ -0,0 +1,139 @@
+package logdrain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// spool is a drain's disk buffer: batches that could not be delivered, one NDJSON file
+// per batch named {sequence}-{records}.ndjson, replayed oldest first. When it outgrows
+// maxBytes the oldest batches are dropped. Only the drain's goroutine touches it; the
+// record count is read by others for the delivery stats.
+type spool struct {
+	dir      string
+	maxBytes int64
+	segments []spoolSegment // Oldest first
+	size     int64
+	seq      uint64
+	records  atomic.Int64
+}
+
+type spoolSegment struct {
+	name    string
+	size    int64
+	records int
+}
+
+// openSpool picks up the batches a previous run left in dir
+func openSpool(dir string, maxBytes int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create drain buffer: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read drain buffer: %w", err)
+	}
+
+	s := &spool{dir: dir, maxBytes: maxBytes}
+	for _, entry := range entries {
+		name := entry.Name()
+		seqStr, countStr, ok := strings.Cut(strings.TrimSuffix(name, ".ndjson"), "-")
+		seq, seqErr := strconv.ParseUint(seqStr, 10, 64)
+		count, countErr := strconv.Atoi(countStr)
+		info, infoErr := entry.Info()
+		if !ok || !strings.HasSuffix(name, ".ndjson") || seqErr != nil || countErr != nil || infoErr != nil {
+			// Leftover of an interrupted write
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+
+		s.segments = append(s.segments, spoolSegment{name: name, size: info.Size(), records: count})
+		s.size += info.Size()
+		s.records.Add(int64(count))
+		if seq > s.seq {
+			s.seq = seq
+		}
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].name < s.segments[j].name })
+
+	return s, nil
+}
+
+func (s *spool) empty() bool {
+	return len(s.segments) == 0
+}
+
+// append buffers a batch and returns how many records were dropped to make room
+func (s *spool) append(records []Record) (int, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range records {
+		if err := encoder.Encode(&records[i]); err != nil {
+			return len(records), err
+		}
+	}
+	size := int64(buf.Len())
+	if size > s.maxBytes {
+		return len(records), fmt.Errorf("batch of %d bytes exceeds the drain buffer", size)
+	}
+
+	dropped := 0
+	for s.size+size > s.maxBytes && len(s.segments) > 0 {
+		dropped += s.segments[0].records
+		s.pop()
+	}
+
+	s.seq++
+	name := fmt.Sprintf("%020d-%d.ndjson", s.seq, len(records))
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		os.Remove(tmp)
+		return dropped + len(records), fmt.Errorf("failed to write drain buffer: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmp)
+		return dropped + len(records), fmt.Errorf("failed to write drain buffer: %w", err)
+	}
+
+	s.segments = append(s.segments, spoolSegment{name: name, size: size, records: len(records)})
+	s.size += size
+	s.records.Add(int64(len(records)))
+	return dropped, nil
+}
+
+// peek reads the oldest batch
+func (s *spool) peek() ([]Record, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, s.segments[0].name))
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, 0, s.segments[0].records)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("corrupt drain buffer %s: %w", s.segments[0].name, err)
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// pop removes the oldest batch
+func (s *spool) pop() {
+	segment := s.segments[0]
+	os.Remove(filepath.Join(s.dir, segment.name))
+	s.segments = s.segments[1:]
+	s.size -= segment.size
+	s.records.Add(-int64(segment.records))
+}
@@ -0,0 +1,177 @@
+package logdrain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const drainColumns = `
+	id, project_id, name, drain_type, url, COALESCE(basic_auth_username, ''),
+	basic_auth_password IS NOT NULL AND basic_auth_password != '',
+	bearer_token IS NOT NULL AND bearer_token != '',
+	COALESCE(headers, '{}'), COALESCE(labels, '{}'), COALESCE(tls_ca_cert, ''),
+	COALESCE(s3_bucket, ''), COALESCE(s3_region, ''), COALESCE(s3_prefix, ''),
+	COALESCE(s3_access_key_id, ''),
+	s3_secret_access_key IS NOT NULL AND s3_secret_access_key != '',
+	batch_size, flush_interval_seconds, enabled,
+	delivered_count, dropped_count, retry_count, buffered_count,
+	last_delivered_at, COALESCE(last_error, ''), last_error_at,
+	created_at, updated_at`
+
+func scanDrain(row interface{ Scan(...interface{}) error }) (*Drain, error) {
+	var d Drain
+	var headers, labels []byte
+	var lastDelivered, lastErrorAt sql.NullTime
+	if err := row.Scan(&d.ID, &d.ProjectID, &d.Name, &d.Type, &d.URL, &d.BasicAuthUsername,
+		&d.HasPassword, &d.HasBearerToken, &headers, &labels, &d.TLSCACert,
+		&d.S3Bucket, &d.S3Region, &d.S3Prefix, &d.S3AccessKeyID, &d.HasS3SecretAccessKey,
+		&d.BatchSize, &d.FlushIntervalSeconds, &d.Enabled,
+		&d.Stats.Delivered, &d.Stats.Dropped, &d.Stats.Retries, &d.Stats.Buffered,
+		&lastDelivered, &d.Stats.LastError, &lastErrorAt,
+		&d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	json.Unmarshal(headers, &d.Headers)
+	json.Unmarshal(labels, &d.Labels)
+	if lastDelivered.Valid {
+		d.Stats.LastDeliveredAt = &lastDelivered.Time
+	}
+	if lastErrorAt.Valid {
+		d.Stats.LastErrorAt = &lastErrorAt.Time
+	}
+	return &d, nil
+}
+
+func (m *Manager) ListDrains(ctx context.Context, projectID string) ([]Drain, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT `+drainColumns+`
+		FROM log_drains
+		WHERE project_id = $1
+		ORDER BY name`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drains := []Drain{}
+	for rows.Next() {
+		d, err := scanDrain(rows)
+		if err != nil {
+			return nil, err
+		}
+		// The stored count lags by one sync
+		if buffered, ok := m.buffered(d.ID); ok {
+			d.Stats.Buffered = buffered
+		}
+		drains = append(drains, *d)
+	}
+	return drains, rows.Err()
+}
+
+func (m *Manager) CreateDrain(ctx context.Context, projectID string, d *Drain) (*Drain, error) {
+	headers, _ := json.Marshal(d.Headers)
+	labels, _ := json.Marshal(d.Labels)
+	row := m.db.QueryRowContext(ctx, `
+		INSERT INTO log_drains (
+			project_id, name, drain_type, url, basic_auth_username, basic_auth_password, bearer_token,
+			headers, labels, tls_ca_cert, s3_bucket, s3_region, s3_prefix, s3_access_key_id,
+			s3_secret_access_key, batch_size, flush_interval_seconds, enabled
+		) VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8, $9, NULLIF($10, ''),
+		          NULLIF($11, ''), NULLIF($12, ''), NULLIF($13, ''), NULLIF($14, ''), NULLIF($15, ''),
+		          $16, $17, $18)
+		RETURNING `+drainColumns,
+		projectID, d.Name, d.Type, d.URL, d.BasicAuthUsername, d.BasicAuthPassword, d.BearerToken,
+		headers, labels, d.TLSCACert, d.S3Bucket, d.S3Region, d.S3Prefix, d.S3AccessKeyID,
+		d.S3SecretAccessKey, d.BatchSize, d.FlushIntervalSeconds, d.Enabled)
+
+	created, err := scanDrain(row)
+	return created, drainError(err)
+}
+
+// UpdateDrain replaces the drain's settings. Empty secrets keep the stored ones, so
+// clients can update a drain without re-sending them. The type cannot change.
+func (m *Manager) UpdateDrain(ctx context.Context, projectID, drainID string, d *Drain) (*Drain, error) {
+	headers, _ := json.Marshal(d.Headers)
+	labels, _ := json.Marshal(d.Labels)
+	row := m.db.QueryRowContext(ctx, `
+		UPDATE log_drains SET
+			name = $3, url = $5, basic_auth_username = NULLIF($6, ''),
+			basic_auth_password = CASE WHEN $7 != '' THEN $7 WHEN $8 != '' THEN NULL ELSE basic_auth_password END,
+			bearer_token = CASE WHEN $8 != '' THEN $8 WHEN $6 != '' THEN NULL ELSE bearer_token END,
+			headers = $9, labels = $10, tls_ca_cert = NULLIF($11, ''),
+			s3_bucket = NULLIF($12, ''), s3_region = NULLIF($13, ''), s3_prefix = NULLIF($14, ''),
+			s3_access_key_id = NULLIF($15, ''),
+			s3_secret_access_key = CASE WHEN $16 != '' THEN $16 ELSE s3_secret_access_key END,
+			batch_size = $17, flush_interval_seconds = $18, enabled = $19, updated_at = NOW()
+		WHERE id = $1 AND project_id = $2 AND drain_type = $4
+		RETURNING `+drainColumns,
+		drainID, projectID, d.Name, d.Type, d.URL, d.BasicAuthUsername, d.BasicAuthPassword, d.BearerToken,
+		headers, labels, d.TLSCACert, d.S3Bucket, d.S3Region, d.S3Prefix, d.S3AccessKeyID,
+		d.S3SecretAccessKey, d.BatchSize, d.FlushIntervalSeconds, d.Enabled)
+
+	updated, err := scanDrain(row)
+	return updated, drainError(err)
+}
+
+func (m *Manager) DeleteDrain(ctx context.Context, projectID, drainID string) error {
+	res, err := m.db.ExecContext(ctx, `
+		DELETE FROM log_drains WHERE id = $1 AND project_id = $2`, drainID, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrDrainNotFound
+	}
+	return nil
+}
+
+// TestDrain sends one record to the drain right away, bypassing its queue and buffer,
+// and returns the sink's error
+func (m *Manager) TestDrain(ctx context.Context, projectID, drainID string) error {
+	drains, err := m.loadDrains(ctx, drainID)
+	if err != nil {
+		return err
+	}
+
+	for i := range drains {
+		d := &drains[i]
+		if d.ProjectID != projectID {
+			continue
+		}
+
+		sink, err := newSink(d, m.dialer)
+		if err != nil {
+			return err
+		}
+		defer sink.Close()
+
+		ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+		defer cancel()
+
+		return sink.Send(ctx, []Record{{
+			ProjectID: projectID,
+			Timestamp: time.Now().UTC(),
+			Level:     "info",
+			Message:   fmt.Sprintf("Test message from Obtura log drain %q", d.Name),
+			Source:    "obtura",
+		}})
+	}
+	return ErrDrainNotFound
+}
+
+func drainError(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrDrainNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDrainNameTaken
+	}
+	return err
+}
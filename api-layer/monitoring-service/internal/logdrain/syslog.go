@@ -0,0 +1,157 @@
+package logdrain
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Syslog messages are RFC 5424 with octet-counting framing (RFC 6587), which keeps
+// multi-line messages such as stack traces in one record:
+//
+//	123 <11>1 2026-10-18T12:00:00.000000Z obtura {deployment_id} {container} stdout [obtura@32473 project_id="..." level="error" source="container"] message
+//
+// 32473 is the private enterprise number reserved for documentation (RFC 5612).
+
+const (
+	syslogFacilityUser = 1
+	syslogSDID         = "obtura@32473"
+)
+
+type syslogSink struct {
+	address string
+	tls     *tls.Config // nil for plain TCP
+	dialer  *net.Dialer
+	conn    net.Conn
+}
+
+func newSyslogSink(d *Drain, dialer *net.Dialer) (*syslogSink, error) {
+	u, err := url.Parse(d.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &syslogSink{address: u.Host, dialer: dialer}
+	if u.Scheme == "tls" {
+		if s.tls, err = tlsConfig(d, u.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *syslogSink) connect(ctx context.Context) error {
+	if s.conn != nil {
+		return nil
+	}
+
+	var conn net.Conn
+	var err error
+	if s.tls != nil {
+		conn, err = (&tls.Dialer{NetDialer: s.dialer, Config: s.tls}).DialContext(ctx, "tcp", s.address)
+	} else {
+		conn, err = s.dialer.DialContext(ctx, "tcp", s.address)
+	}
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// Send writes the batch on the open connection, reconnecting first if the previous
+// batch failed. TCP syslog has no acknowledgements, so a batch counts as delivered
+// once it is written.
+func (s *syslogSink) Send(ctx context.Context, records []Record) error {
+	if err := s.connect(ctx); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for i := range records {
+		msg := formatRFC5424(&records[i])
+		fmt.Fprintf(&buf, "%d %s", len(msg), msg)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(sendTimeout)
+	}
+	s.conn.SetWriteDeadline(deadline)
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func syslogSeverity(level string) int {
+	switch strings.ToLower(level) {
+	case "fatal", "critical":
+		return 2
+	case "error":
+		return 3
+	case "warning", "warn":
+		return 4
+	case "debug", "trace":
+		return 7
+	}
+	return 6
+}
+
+func formatRFC5424(r *Record) string {
+	procID := r.ContainerID
+	if len(procID) > 12 {
+		procID = procID[:12]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s obtura %s %s %s [%s project_id=\"%s\" level=\"%s\" source=\"%s\"] ",
+		syslogFacilityUser*8+syslogSeverity(r.Level),
+		r.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogField(r.DeploymentID, 48),
+		syslogField(procID, 128),
+		syslogField(r.Stream, 32),
+		syslogSDID,
+		sdEscape(r.ProjectID), sdEscape(r.Level), sdEscape(r.Source))
+	b.WriteString(r.Message)
+	return b.String()
+}
+
+// syslogField is a header field: printable ASCII without spaces, or "-" when empty
+func syslogField(value string, maxLen int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+	if len(value) > maxLen {
+		value = value[:maxLen]
+	}
+	return value
+}
+
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+func sdEscape(value string) string {
+	return sdEscaper.Replace(value)
+}
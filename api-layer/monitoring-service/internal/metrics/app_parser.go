@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ScrapedSample is one sample of an application's Prometheus exposition
+type ScrapedSample struct {
+	Name   string
+	Type   string // Type of the sample's family
+	Help   string
+	Labels map[string]string
+	Value  float64
+}
+
+// parseExposition reads the Prometheus text format (and the OpenMetrics subset apps
+// emit): HELP/TYPE metadata, samples with optional labels and timestamps. Exemplars and
+// OpenMetrics _created samples are skipped. Malformed lines fail the whole scrape, like
+// Prometheus does.
+func parseExposition(r io.Reader) ([]ScrapedSample, error) {
+	types := map[string]string{}
+	helps := map[string]string{}
+	var samples []ScrapedSample
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) < 3 {
+				continue
+			}
+			switch fields[1] {
+			case "TYPE":
+				if len(fields) == 4 {
+					types[fields[2]] = strings.ToLower(strings.TrimSpace(fields[3]))
+				}
+			case "HELP":
+				if len(fields) == 4 {
+					helps[fields[2]] = fields[3]
+				}
+			}
+			continue
+		}
+
+		sample, err := parseSampleLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		family := sampleFamily(sample.Name, types)
+		if strings.HasSuffix(sample.Name, "_created") && family != sample.Name {
+			continue
+		}
+		sample.Type = types[family]
+		if sample.Type == "" || sample.Type == "unknown" {
+			sample.Type = "untyped"
+		}
+		sample.Help = helps[family]
+		samples = append(samples, sample)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// sampleFamily maps a sample name to its family (http_latency_bucket -> http_latency)
+func sampleFamily(name string, types map[string]string) string {
+	if _, ok := types[name]; ok {
+		return name
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count", "_total", "_created", "_gsum", "_gcount"} {
+		if base, ok := strings.CutSuffix(name, suffix); ok {
+			if _, known := types[base]; known {
+				return base
+			}
+		}
+	}
+	return name
+}
+
+func parseSampleLine(line string) (ScrapedSample, error) {
+	s := ScrapedSample{Labels: map[string]string{}}
+
+	// Exemplars follow the value after " # "
+	if i := strings.Index(line, " # "); i >= 0 {
+		line = line[:i]
+	}
+
+	i := 0
+	for i < len(line) && isMetricNameChar(line[i], i == 0) {
+		i++
+	}
+	if i == 0 {
+		return s, fmt.Errorf("invalid metric name")
+	}
+	s.Name = line[:i]
+
+	if i < len(line) && line[i] == '{' {
+		i++
+		for {
+			for i < len(line) && (line[i] == ' ' || line[i] == ',') {
+				i++
+			}
+			if i < len(line) && line[i] == '}' {
+				i++
+				break
+			}
+
+			start := i
+			for i < len(line) && isMetricNameChar(line[i], i == start) && line[i] != ':' {
+				i++
+			}
+			if i == start {
+				return s, fmt.Errorf("invalid label name in %s", s.Name)
+			}
+			name := line[start:i]
+
+			for i < len(line) && line[i] == ' ' {
+				i++
+			}
+			if i+1 >= len(line) || line[i] != '=' || line[i+1] != '"' {
+				return s, fmt.Errorf("expected =\" after label %s", name)
+			}
+			i += 2
+
+			var value strings.Builder
+			closed := false
+			for i < len(line) {
+				c := line[i]
+				if c == '\\' && i+1 < len(line) {
+					switch line[i+1] {
+					case 'n':
+						value.WriteByte('\n')
+					default:
+						value.WriteByte(line[i+1])
+					}
+					i += 2
+					continue
+				}
+				if c == '"' {
+					closed = true
+					i++
+					break
+				}
+				value.WriteByte(c)
+				i++
+			}
+			if !closed {
+				return s, fmt.Errorf("unterminated value of label %s", name)
+			}
+			s.Labels[name] = value.String()
+		}
+	}
+
+	rest := strings.Fields(line[i:])
+	if len(rest) == 0 || len(rest) > 2 {
+		return s, fmt.Errorf("expected value after %s", s.Name)
+	}
+	value, err := strconv.ParseFloat(rest[0], 64)
+	if err != nil {
+		return s, fmt.Errorf("invalid value %q for %s", rest[0], s.Name)
+	}
+	s.Value = value
+	return s, nil
+}
+
+func isMetricNameChar(c byte, first bool) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' {
+		return true
+	}
+	return !first && c >= '0' && c <= '9'
+}
+
+// canonicalLabels renders labels sorted by name, the identity of a series
+func canonicalLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(labels[name]))
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
@@ -0,0 +1,825 @@
+package metrics
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"monitoring-service/pkg/docker"
+	"monitoring-service/pkg/logger"
+
+	"github.com/lib/pq"
+)
+
+const (
+	DefaultAppMetricsPath     = "/metrics"
+	DefaultAppMetricsInterval = 30
+
+	// Used when the company has no active subscription
+	defaultAppMetricSeriesLimit = 500
+
+	// Cardinality limits applied to every scrape; series breaking them are dropped and
+	// counted in app_metrics_scrape_state.dropped_series
+	maxAppSeriesPerMetric    = 500
+	maxAppLabelsPerSeries    = 16
+	maxAppLabelValueLength   = 128
+	maxAppMetricNameLength   = 200
+	maxAppScrapeBodyBytes    = 10 << 20
+	appScrapeTimeout         = 10 * time.Second
+	appScrapeConcurrency     = 10
+	appSeriesCacheTTL        = 10 * time.Minute
+	appScrapeAcceptHeader    = "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1"
+	appMetricsPortLabel      = "obtura.app_port"
+	maxAppQuerySeries        = 50
+	maxAppQueryPointsPerLine = 1000
+)
+
+// AppMetricsConfig is the project's opt-in for scraping its app's Prometheus endpoint
+type AppMetricsConfig struct {
+	Enabled         bool   `json:"enabled"`
+	Path            string `json:"path"`
+	Port            *int   `json:"port"` // nil scrapes the app port
+	IntervalSeconds int    `json:"interval_seconds"`
+}
+
+func (c *AppMetricsConfig) Validate() error {
+	if c.Path == "" {
+		c.Path = DefaultAppMetricsPath
+	}
+	if !strings.HasPrefix(c.Path, "/") || len(c.Path) > 255 || strings.ContainsAny(c.Path, " \t\r\n") {
+		return fmt.Errorf("path must start with / and be at most 255 characters")
+	}
+	if c.Port != nil && (*c.Port < 1 || *c.Port > 65535) {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	if c.IntervalSeconds == 0 {
+		c.IntervalSeconds = DefaultAppMetricsInterval
+	}
+	if c.IntervalSeconds < 10 || c.IntervalSeconds > 3600 {
+		return fmt.Errorf("interval_seconds must be between 10 and 3600")
+	}
+	return nil
+}
+
+// AppScrapeStatus is the outcome of a deployment's latest scrape
+type AppScrapeStatus struct {
+	DeploymentID     string     `json:"deployment_id"`
+	LastScrapeAt     *time.Time `json:"last_scrape_at"`
+	LastSuccessAt    *time.Time `json:"last_success_at"`
+	LastError        string     `json:"last_error"`
+	ScrapeDurationMs int        `json:"scrape_duration_ms"`
+	SamplesScraped   int        `json:"samples_scraped"`
+	SeriesCount      int        `json:"series_count"`
+	DroppedSeries    int        `json:"dropped_series"`
+	SeriesLimit      int        `json:"series_limit"`
+}
+
+// AppMetric is a scraped metric name with its series count
+type AppMetric struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Help        string    `json:"help"`
+	SeriesCount int       `json:"series_count"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+type AppSeries struct {
+	Labels map[string]string `json:"labels"`
+	Points []AppPoint        `json:"points"`
+}
+
+type AppPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// AppMetricSummary condenses a metric to one number per deployment: the sum of the
+// latest values, plus the per-second increase for counters
+type AppMetricSummary struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	SeriesCount int      `json:"series_count"`
+	Latest      float64  `json:"latest"`
+	RatePerSec  *float64 `json:"rate_per_sec,omitempty"`
+}
+
+// AppDeploymentSummary is the application metrics of one active deployment
+type AppDeploymentSummary struct {
+	DeploymentID string             `json:"deployment_id"`
+	Environment  string             `json:"environment"`
+	Scrape       *AppScrapeStatus   `json:"scrape"`
+	Metrics      []AppMetricSummary `json:"metrics"`
+}
+
+type appScrapeTarget struct {
+	deploymentID   string
+	containerIDs   []string
+	containerNames []string
+	path           string
+	port           int
+	seriesLimit    int
+	lastScrapeAt   sql.NullTime
+}
+
+type appSeriesCache struct {
+	ids       map[string]int64 // metric name + labels hash -> series ID
+	perMetric map[string]int
+	loadedAt  time.Time
+}
+
+// AppScraper scrapes the Prometheus endpoint of app containers that opted in, on the
+// Docker network they share with the platform, and stores the samples per series
+type AppScraper struct {
+	db      *sql.DB
+	docker  *docker.Client
+	network string
+	client  *http.Client
+
+	mu       sync.Mutex
+	series   map[string]*appSeriesCache // deployment ID -> known series
+	storeMus map[string]*sync.Mutex     // Scrapes and OTLP pushes of a deployment share its cache
+}
+
+func NewAppScraper(db *sql.DB, dockerClient *docker.Client, network string) *AppScraper {
+	return &AppScraper{
+		db:      db,
+		docker:  dockerClient,
+		network: network,
+		client: &http.Client{
+			Timeout: appScrapeTimeout,
+			// Scrape targets are app containers; never follow them elsewhere
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		series:   make(map[string]*appSeriesCache),
+		storeMus: make(map[string]*sync.Mutex),
+	}
+}
+
+// ScrapeDue scrapes every opted-in deployment whose interval elapsed
+func (s *AppScraper) ScrapeDue(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d.id,
+			array_agg(dc.container_id ORDER BY dc.replica_index NULLS LAST, dc.container_name),
+			array_agg(dc.container_name ORDER BY dc.replica_index NULLS LAST, dc.container_name),
+			COALESCE(NULLIF(ps.app_metrics_path, ''), $1),
+			COALESCE(ps.app_metrics_port, 0),
+			COALESCE(MAX(sp.max_app_metric_series), $2),
+			st.last_scrape_at
+		FROM deployments d
+		JOIN deployment_containers dc ON dc.deployment_id = d.id AND dc.is_active = true
+		JOIN projects p ON p.id = d.project_id
+		JOIN project_settings ps ON ps.project_id = p.id AND ps.app_metrics_enabled = true
+		LEFT JOIN subscriptions s ON s.company_id = p.company_id AND s.status = 'active'
+		LEFT JOIN subscription_plans sp ON sp.id = s.plan_id
+		LEFT JOIN app_metrics_scrape_state st ON st.deployment_id = d.id
+		WHERE d.status IN ('active', 'running', 'healthy')
+		  AND (st.last_scrape_at IS NULL OR st.last_scrape_at <= NOW() - make_interval(
+			secs => GREATEST(COALESCE(ps.app_metrics_interval_seconds, $3), 10)))
+		GROUP BY d.id, ps.app_metrics_path, ps.app_metrics_port, st.last_scrape_at`,
+		DefaultAppMetricsPath, defaultAppMetricSeriesLimit, DefaultAppMetricsInterval)
+	if err != nil {
+		return fmt.Errorf("failed to query scrape targets: %w", err)
+	}
+
+	var targets []appScrapeTarget
+	for rows.Next() {
+		var t appScrapeTarget
+		if err := rows.Scan(&t.deploymentID, pq.Array(&t.containerIDs), pq.Array(&t.containerNames),
+			&t.path, &t.port, &t.seriesLimit, &t.lastScrapeAt); err != nil {
+			rows.Close()
+			return err
+		}
+		targets = append(targets, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	sem := make(chan struct{}, appScrapeConcurrency)
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		claimed, err := s.claim(ctx, t)
+		if err != nil {
+			logger.Error("Failed to claim app metrics scrape", logger.String("deployment_id", t.deploymentID), logger.Err(err))
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(t appScrapeTarget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.scrape(ctx, t)
+		}(t)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// claim bumps last_scrape_at unless another worker already did since we read it
+func (s *AppScraper) claim(ctx context.Context, t appScrapeTarget) (bool, error) {
+	var id string
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO app_metrics_scrape_state (deployment_id, last_scrape_at)
+		VALUES ($1, NOW())
+		ON CONFLICT (deployment_id) DO UPDATE SET last_scrape_at = NOW()
+		WHERE app_metrics_scrape_state.last_scrape_at IS NOT DISTINCT FROM $2
+		RETURNING deployment_id`, t.deploymentID, t.lastScrapeAt).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *AppScraper) scrape(ctx context.Context, t appScrapeTarget) {
+	started := time.Now()
+
+	var samples []ScrapedSample
+	var scrapeErr error
+	for i, containerID := range t.containerIDs {
+		scraped, err := s.scrapeContainer(ctx, containerID, t)
+		if err != nil {
+			scrapeErr = fmt.Errorf("%s: %w", t.containerNames[i], err)
+			break
+		}
+		// Replicas expose the same series; instance keeps them apart
+		for j := range scraped {
+			if v, ok := scraped[j].Labels["instance"]; ok {
+				scraped[j].Labels["exported_instance"] = v
+			}
+			scraped[j].Labels["instance"] = t.containerNames[i]
+		}
+		samples = append(samples, scraped...)
+	}
+
+	var stored, seriesCount, dropped int
+	if scrapeErr == nil {
+		stored, seriesCount, dropped, scrapeErr = s.store(ctx, t, samples, started)
+	}
+
+	duration := int(time.Since(started).Milliseconds())
+	var err error
+	if scrapeErr != nil {
+		logger.Warn("App metrics scrape failed", logger.String("deployment_id", t.deploymentID), logger.Err(scrapeErr))
+		_, err = s.db.ExecContext(ctx, `
+			UPDATE app_metrics_scrape_state SET last_error = $2, scrape_duration_ms = $3
+			WHERE deployment_id = $1`, t.deploymentID, scrapeErr.Error(), duration)
+	} else {
+		_, err = s.db.ExecContext(ctx, `
+			UPDATE app_metrics_scrape_state SET
+				last_success_at = NOW(), last_error = NULL, scrape_duration_ms = $2,
+				samples_scraped = $3, series_count = $4, dropped_series = $5
+			WHERE deployment_id = $1`, t.deploymentID, duration, stored, seriesCount, dropped)
+	}
+	if err != nil {
+		logger.Error("Failed to record app metrics scrape", logger.String("deployment_id", t.deploymentID), logger.Err(err))
+	}
+}
+
+func (s *AppScraper) scrapeContainer(ctx context.Context, containerID string, t appScrapeTarget) ([]ScrapedSample, error) {
+	info, err := s.docker.InspectContainer(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	var ip string
+	if info.NetworkSettings != nil {
+		if endpoint, ok := info.NetworkSettings.Networks[s.network]; ok && endpoint != nil {
+			ip = endpoint.IPAddress
+		}
+	}
+	if ip == "" {
+		return nil, fmt.Errorf("container is not attached to %s", s.network)
+	}
+
+	port := t.port
+	if port == 0 && info.Config != nil {
+		port, _ = strconv.Atoi(info.Config.Labels[appMetricsPortLabel])
+	}
+	if port == 0 {
+		return nil, fmt.Errorf("no metrics port configured and the container has no app port")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, appScrapeTimeout)
+	defer cancel()
+
+	url := "http://" + net.JoinHostPort(ip, strconv.Itoa(port)) + t.path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", appScrapeAcceptHeader)
+	req.Header.Set("User-Agent", "obtura-monitoring")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d", t.path, resp.StatusCode)
+	}
+
+	body := io.LimitReader(resp.Body, maxAppScrapeBodyBytes+1)
+	samples, err := parseExposition(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exposition: %w", err)
+	}
+	return samples, nil
+}
+
+// store keeps the samples of known series, registers new series within the
+// cardinality limits and drops the rest
+func (s *AppScraper) store(ctx context.Context, t appScrapeTarget, samples []ScrapedSample, ts time.Time) (int, int, int, error) {
+	s.mu.Lock()
+	storeMu, ok := s.storeMus[t.deploymentID]
+	if !ok {
+		storeMu = &sync.Mutex{}
+		s.storeMus[t.deploymentID] = storeMu
+	}
+	s.mu.Unlock()
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	cache, err := s.seriesCache(ctx, t.deploymentID)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	type row struct {
+		seriesID int64
+		value    float64
+	}
+	rows := make([]row, 0, len(samples))
+	seen := make([]int64, 0, len(samples))
+	dropped := 0
+
+	for _, sample := range samples {
+		if !appSeriesAllowed(sample) {
+			dropped++
+			continue
+		}
+
+		labels := canonicalLabels(sample.Labels)
+		sum := sha256.Sum256([]byte(labels))
+		hash := hex.EncodeToString(sum[:16])
+		key := sample.Name + "|" + hash
+
+		id, ok := cache.ids[key]
+		if !ok {
+			if len(cache.ids) >= t.seriesLimit || cache.perMetric[sample.Name] >= maxAppSeriesPerMetric {
+				dropped++
+				continue
+			}
+
+			labelsJSON, _ := json.Marshal(sample.Labels)
+			err := s.db.QueryRowContext(ctx, `
+				INSERT INTO app_metric_series (deployment_id, metric_name, metric_type, help, labels, labels_hash)
+				VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+				ON CONFLICT (deployment_id, metric_name, labels_hash) DO UPDATE SET
+					metric_type = EXCLUDED.metric_type, help = EXCLUDED.help, last_seen_at = NOW()
+				RETURNING id`, t.deploymentID, sample.Name, sample.Type, truncate(sample.Help, 1000), labelsJSON, hash).Scan(&id)
+			if err != nil {
+				return 0, 0, 0, fmt.Errorf("failed to register series: %w", err)
+			}
+			cache.ids[key] = id
+			cache.perMetric[sample.Name]++
+		}
+
+		rows = append(rows, row{id, sample.Value})
+		seen = append(seen, id)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("app_metric_samples", "series_id", "deployment_id", "timestamp", "value"))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	for _, r := range rows {
+		if _, err := stmt.ExecContext(ctx, r.seriesID, t.deploymentID, ts, r.value); err != nil {
+			stmt.Close()
+			return 0, 0, 0, err
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		s.forgetSeries(t.deploymentID, err)
+		return 0, 0, 0, fmt.Errorf("failed to store samples: %w", err)
+	}
+	stmt.Close()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE app_metric_series SET last_seen_at = NOW() WHERE id = ANY($1)`, pq.Array(seen)); err != nil {
+		return 0, 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, 0, err
+	}
+
+	return len(rows), len(cache.ids), dropped, nil
+}
+
+// IngestSamples stores samples an app pushed (over OTLP) under the same series and
+// cardinality limits as scraped ones. Returns the number of samples dropped.
+func (s *AppScraper) IngestSamples(ctx context.Context, deploymentID string, samples []ScrapedSample, ts time.Time) (int, error) {
+	t := appScrapeTarget{deploymentID: deploymentID}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(sp.max_app_metric_series), $2)
+		FROM deployments d
+		JOIN projects p ON p.id = d.project_id
+		LEFT JOIN subscriptions s ON s.company_id = p.company_id AND s.status = 'active'
+		LEFT JOIN subscription_plans sp ON sp.id = s.plan_id
+		WHERE d.id = $1`, deploymentID, defaultAppMetricSeriesLimit).Scan(&t.seriesLimit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up series limit: %w", err)
+	}
+
+	_, _, dropped, err := s.store(ctx, t, samples, ts)
+	return dropped, err
+}
+
+func appSeriesAllowed(sample ScrapedSample) bool {
+	if len(sample.Name) > maxAppMetricNameLength || len(sample.Labels) > maxAppLabelsPerSeries {
+		return false
+	}
+	for name, value := range sample.Labels {
+		if len(name) > maxAppMetricNameLength || len(value) > maxAppLabelValueLength {
+			return false
+		}
+	}
+	return true
+}
+
+// seriesCache returns the deployment's known series, reloading them periodically so
+// series removed by retention are registered again
+func (s *AppScraper) seriesCache(ctx context.Context, deploymentID string) (*appSeriesCache, error) {
+	s.mu.Lock()
+	cache, ok := s.series[deploymentID]
+	s.mu.Unlock()
+	if ok && time.Since(cache.loadedAt) < appSeriesCacheTTL {
+		return cache, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, metric_name, labels_hash FROM app_metric_series WHERE deployment_id = $1`, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cache = &appSeriesCache{ids: map[string]int64{}, perMetric: map[string]int{}, loadedAt: time.Now()}
+	for rows.Next() {
+		var id int64
+		var name, hash string
+		if err := rows.Scan(&id, &name, &hash); err != nil {
+			return nil, err
+		}
+		cache.ids[name+"|"+hash] = id
+		cache.perMetric[name]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.series[deploymentID] = cache
+	s.mu.Unlock()
+	return cache, nil
+}
+
+// forgetSeries drops the cache after a foreign key violation: a cached series was
+// deleted (retention or deployment removal) since it was loaded
+func (s *AppScraper) forgetSeries(deploymentID string, err error) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		s.mu.Lock()
+		delete(s.series, deploymentID)
+		s.mu.Unlock()
+	}
+}
+
+// GetConfig returns the project's scrape settings
+func (s *AppScraper) GetConfig(ctx context.Context, projectID string) (*AppMetricsConfig, error) {
+	c := &AppMetricsConfig{Path: DefaultAppMetricsPath, IntervalSeconds: DefaultAppMetricsInterval}
+	var port sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(app_metrics_enabled, false), COALESCE(NULLIF(app_metrics_path, ''), $2),
+		       app_metrics_port, COALESCE(app_metrics_interval_seconds, $3)
+		FROM project_settings WHERE project_id = $1`,
+		projectID, DefaultAppMetricsPath, DefaultAppMetricsInterval).Scan(&c.Enabled, &c.Path, &port, &c.IntervalSeconds)
+	if err == sql.ErrNoRows {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if port.Valid {
+		p := int(port.Int64)
+		c.Port = &p
+	}
+	return c, nil
+}
+
+// UpdateConfig stores the project's scrape settings
+func (s *AppScraper) UpdateConfig(ctx context.Context, projectID string, c *AppMetricsConfig) error {
+	var port interface{}
+	if c.Port != nil {
+		port = *c.Port
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO project_settings (project_id, app_metrics_enabled, app_metrics_path, app_metrics_port, app_metrics_interval_seconds)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (project_id) DO UPDATE SET
+			app_metrics_enabled = EXCLUDED.app_metrics_enabled,
+			app_metrics_path = EXCLUDED.app_metrics_path,
+			app_metrics_port = EXCLUDED.app_metrics_port,
+			app_metrics_interval_seconds = EXCLUDED.app_metrics_interval_seconds`,
+		projectID, c.Enabled, c.Path, port, c.IntervalSeconds)
+	return err
+}
+
+// GetScrapeStatus returns the latest scrape outcome of a deployment
+func (s *AppScraper) GetScrapeStatus(ctx context.Context, deploymentID string) (*AppScrapeStatus, error) {
+	st := &AppScrapeStatus{DeploymentID: deploymentID}
+	var lastScrape, lastSuccess sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT st.last_scrape_at, st.last_success_at, COALESCE(st.last_error, ''),
+		       COALESCE(st.scrape_duration_ms, 0), COALESCE(st.samples_scraped, 0),
+		       COALESCE(st.series_count, 0), COALESCE(st.dropped_series, 0),
+		       COALESCE((
+		           SELECT MAX(sp.max_app_metric_series)
+		           FROM deployments d
+		           JOIN projects p ON p.id = d.project_id
+		           JOIN subscriptions s ON s.company_id = p.company_id AND s.status = 'active'
+		           JOIN subscription_plans sp ON sp.id = s.plan_id
+		           WHERE d.id = $1
+		       ), $2)
+		FROM (SELECT $1::uuid AS deployment_id) q
+		LEFT JOIN app_metrics_scrape_state st ON st.deployment_id = q.deployment_id`,
+		deploymentID, defaultAppMetricSeriesLimit).Scan(&lastScrape, &lastSuccess, &st.LastError,
+		&st.ScrapeDurationMs, &st.SamplesScraped, &st.SeriesCount, &st.DroppedSeries, &st.SeriesLimit)
+	if err != nil {
+		return nil, err
+	}
+	if lastScrape.Valid {
+		st.LastScrapeAt = &lastScrape.Time
+	}
+	if lastSuccess.Valid {
+		st.LastSuccessAt = &lastSuccess.Time
+	}
+	return st, nil
+}
+
+// ListMetrics returns the metric names scraped from a deployment
+func (s *AppScraper) ListMetrics(ctx context.Context, deploymentID string) ([]AppMetric, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT metric_name, MAX(metric_type), COALESCE(MAX(help), ''), COUNT(*), MAX(last_seen_at)
+		FROM app_metric_series
+		WHERE deployment_id = $1
+		GROUP BY metric_name
+		ORDER BY metric_name`, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := []AppMetric{}
+	for rows.Next() {
+		var m AppMetric
+		if err := rows.Scan(&m.Name, &m.Type, &m.Help, &m.SeriesCount, &m.LastSeenAt); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
+}
+
+// QueryMetric returns the points of a metric's series in [start, end], optionally
+// restricted to series carrying all of the given labels
+func (s *AppScraper) QueryMetric(ctx context.Context, deploymentID, name string, match map[string]string, start, end time.Time) ([]AppSeries, error) {
+	matchJSON, _ := json.Marshal(match)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, labels FROM app_metric_series
+		WHERE deployment_id = $1 AND metric_name = $2 AND labels @> $3
+		ORDER BY labels_hash
+		LIMIT $4`, deploymentID, name, matchJSON, maxAppQuerySeries)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+	byID := map[int64]*AppSeries{}
+	series := []*AppSeries{}
+	for rows.Next() {
+		var id int64
+		var labels []byte
+		if err := rows.Scan(&id, &labels); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sr := &AppSeries{Points: []AppPoint{}}
+		json.Unmarshal(labels, &sr.Labels)
+		ids = append(ids, id)
+		byID[id] = sr
+		series = append(series, sr)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) > 0 {
+		points, err := s.db.QueryContext(ctx, `
+			SELECT series_id, timestamp, value FROM (
+				SELECT series_id, timestamp, value,
+				       ROW_NUMBER() OVER (PARTITION BY series_id ORDER BY timestamp DESC) AS rn
+				FROM app_metric_samples
+				WHERE series_id = ANY($1) AND timestamp >= $2 AND timestamp <= $3
+			) p
+			WHERE rn <= $4
+			ORDER BY series_id, timestamp`, pq.Array(ids), start, end, maxAppQueryPointsPerLine)
+		if err != nil {
+			return nil, err
+		}
+		defer points.Close()
+
+		for points.Next() {
+			var id int64
+			var p AppPoint
+			if err := points.Scan(&id, &p.Time, &p.Value); err != nil {
+				return nil, err
+			}
+			byID[id].Points = append(byID[id].Points, p)
+		}
+		if err := points.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]AppSeries, 0, len(series))
+	for _, sr := range series {
+		out = append(out, *sr)
+	}
+	return out, nil
+}
+
+// Summarize condenses the latest scrape of a deployment, for dashboards and the AI
+// agent's context. Histogram buckets are left out.
+func (s *AppScraper) Summarize(ctx context.Context, deploymentID string, limit int) ([]AppMetricSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		WITH latest AS (
+			SELECT DISTINCT ON (series_id) series_id, timestamp, value
+			FROM app_metric_samples
+			WHERE deployment_id = $1 AND timestamp > NOW() - INTERVAL '10 minutes'
+			ORDER BY series_id, timestamp DESC
+		), earlier AS (
+			SELECT DISTINCT ON (series_id) series_id, timestamp, value
+			FROM app_metric_samples
+			WHERE deployment_id = $1 AND timestamp > NOW() - INTERVAL '10 minutes'
+			ORDER BY series_id, timestamp ASC
+		)
+		SELECT ms.metric_name, MAX(ms.metric_type), COUNT(*), SUM(l.value),
+		       SUM(CASE WHEN l.timestamp > e.timestamp AND l.value >= e.value
+		                THEN (l.value - e.value) / EXTRACT(EPOCH FROM l.timestamp - e.timestamp) END)
+		FROM latest l
+		JOIN app_metric_series ms ON ms.id = l.series_id
+		LEFT JOIN earlier e ON e.series_id = l.series_id
+		WHERE ms.metric_name NOT LIKE '%\_bucket'
+		GROUP BY ms.metric_name
+		ORDER BY ms.metric_name
+		LIMIT $2`, deploymentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := []AppMetricSummary{}
+	for rows.Next() {
+		var m AppMetricSummary
+		var rate sql.NullFloat64
+		if err := rows.Scan(&m.Name, &m.Type, &m.SeriesCount, &m.Latest, &rate); err != nil {
+			return nil, err
+		}
+		isCounter := m.Type == "counter" || strings.HasSuffix(m.Name, "_total") ||
+			strings.HasSuffix(m.Name, "_count") || strings.HasSuffix(m.Name, "_sum")
+		if isCounter && rate.Valid {
+			m.RatePerSec = &rate.Float64
+		}
+		summaries = append(summaries, m)
+	}
+	return summaries, rows.Err()
+}
+
+// ProjectSummary summarizes every active deployment of a project
+func (s *AppScraper) ProjectSummary(ctx context.Context, projectID string, limit int) ([]AppDeploymentSummary, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d.id, COALESCE(d.environment, '')
+		FROM deployments d
+		WHERE d.project_id = $1 AND d.status IN ('active', 'running', 'healthy')
+		ORDER BY d.environment, d.created_at DESC`, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []AppDeploymentSummary
+	for rows.Next() {
+		var d AppDeploymentSummary
+		if err := rows.Scan(&d.DeploymentID, &d.Environment); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		summaries = append(summaries, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range summaries {
+		if summaries[i].Scrape, err = s.GetScrapeStatus(ctx, summaries[i].DeploymentID); err != nil {
+			return nil, err
+		}
+		if summaries[i].Metrics, err = s.Summarize(ctx, summaries[i].DeploymentID, limit); err != nil {
+			return nil, err
+		}
+	}
+	if summaries == nil {
+		summaries = []AppDeploymentSummary{}
+	}
+	return summaries, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// LatestValue sums the latest sample of every series of a metric carrying the given
+// labels. Nil means the metric was not scraped in the last 10 minutes.
+func (s *AppScraper) LatestValue(ctx context.Context, deploymentID, name string, match map[string]string) (*float64, error) {
+	matchJSON, _ := json.Marshal(match)
+	var value sql.NullFloat64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT SUM(value) FROM (
+			SELECT DISTINCT ON (s.series_id) s.value
+			FROM app_metric_samples s
+			JOIN app_metric_series ms ON ms.id = s.series_id
+			WHERE s.deployment_id = $1 AND ms.metric_name = $2 AND ms.labels @> $3
+			  AND s.timestamp > NOW() - INTERVAL '10 minutes'
+			ORDER BY s.series_id, s.timestamp DESC
+		) latest`, deploymentID, name, matchJSON).Scan(&value)
+	if err != nil || !value.Valid {
+		return nil, err
+	}
+	return &value.Float64, nil
+}
+
+// Rate is the per-second increase of a counter over the window, summed over its series.
+// Series that reset during the window count from their reset.
+func (s *AppScraper) Rate(ctx context.Context, deploymentID, name string, match map[string]string, window time.Duration) (*float64, error) {
+	matchJSON, _ := json.Marshal(match)
+	var value sql.NullFloat64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT SUM(CASE WHEN last_value >= first_value THEN last_value - first_value ELSE last_value END
+		           / GREATEST(EXTRACT(EPOCH FROM last_at - first_at), 1))
+		FROM (
+			SELECT s.series_id,
+			       (ARRAY_AGG(s.value ORDER BY s.timestamp))[1] AS first_value,
+			       (ARRAY_AGG(s.value ORDER BY s.timestamp DESC))[1] AS last_value,
+			       MIN(s.timestamp) AS first_at, MAX(s.timestamp) AS last_at
+			FROM app_metric_samples s
+			JOIN app_metric_series ms ON ms.id = s.series_id
+			WHERE s.deployment_id = $1 AND ms.metric_name = $2 AND ms.labels @> $3
+			  AND s.timestamp > NOW() - make_interval(secs => $4)
+			GROUP BY s.series_id
+			HAVING COUNT(*) > 1
+		) w`, deploymentID, name, matchJSON, window.Seconds()).Scan(&value)
+	if err != nil || !value.Valid {
+		return nil, err
+	}
+	return &value.Float64, nil
+}
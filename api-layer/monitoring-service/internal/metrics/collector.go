@@ -3,6 +3,7 @@ package metrics
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"math"
 	"time"
@@ -14,6 +15,20 @@ import (
 	"go.uber.org/zap"
 )
 
+// MetricsChannelPrefix is the Redis pub/sub channel each new sample of a deployment is
+// published on, followed by the deployment ID
+const MetricsChannelPrefix = "metrics:updates:"
+
+// MetricUpdate is the message published on a deployment's metrics channel
+type MetricUpdate struct {
+	DeploymentID string  `json:"deployment_id"`
+	CPUUsage     float64 `json:"cpu_usage"`
+	MemoryUsage  int64   `json:"memory_usage"`
+	NetworkRx    int64   `json:"network_rx"`
+	NetworkTx    int64   `json:"network_tx"`
+	Timestamp    int64   `json:"timestamp"`
+}
+
 type Collector struct {
 	dockerClient *docker.Client
 	db           *sql.DB
@@ -136,13 +151,30 @@ func (c *Collector) cacheMetric(ctx context.Context, metric *DeploymentMetric) e
 	key := fmt.Sprintf("metrics:%s:latest", metric.DeploymentID)
 
 	// Store as hash
-	return c.redis.HSet(ctx, key,
+	err := c.redis.HSet(ctx, key,
 		"cpu_usage", metric.CPUUsage,
 		"memory_usage", metric.MemoryUsage,
 		"network_rx", metric.NetworkRx,
 		"network_tx", metric.NetworkTx,
 		"timestamp", metric.Timestamp.Unix(),
 	).Err()
+	if err != nil {
+		return err
+	}
+
+	// Push the sample to live dashboards instead of having them poll the hash
+	update, err := json.Marshal(MetricUpdate{
+		DeploymentID: metric.DeploymentID,
+		CPUUsage:     metric.CPUUsage,
+		MemoryUsage:  metric.MemoryUsage,
+		NetworkRx:    metric.NetworkRx,
+		NetworkTx:    metric.NetworkTx,
+		Timestamp:    metric.Timestamp.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	return c.redis.Publish(ctx, MetricsChannelPrefix+metric.DeploymentID, update).Err()
 }
 
 func (c *Collector) calculateCPUPercentage(stats *docker.ContainerStats) float64 {
@@ -0,0 +1,269 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"monitoring-service/internal/httpmetrics"
+	"monitoring-service/pkg/db"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	ContentTypeText        = "text/plain; version=0.0.4; charset=utf-8"
+	ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Family is one Prometheus metric family. Counter samples carry the _total suffix in
+// Samples, histogram samples their _bucket/_sum/_count suffixes.
+type Family struct {
+	Name    string
+	Help    string
+	Type    string // gauge, counter or histogram
+	Samples []Sample
+}
+
+type Sample struct {
+	Name   string
+	Labels []Label
+	Value  float64
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Exporter exposes a project's deployment metrics in the Prometheus formats. Every
+// series carries the same project, project_id, environment and deployment_id labels.
+type Exporter struct {
+	db    *sql.DB
+	redis *db.RedisClient
+}
+
+func NewExporter(db *sql.DB, redisClient *db.RedisClient) *Exporter {
+	return &Exporter{db: db, redis: redisClient}
+}
+
+type exportedDeployment struct {
+	id     string
+	status string
+	labels []Label
+}
+
+// Collect reads the latest container sample and the HTTP counters of every running
+// deployment of the project
+func (e *Exporter) Collect(ctx context.Context, projectID string) ([]Family, error) {
+	rows, err := e.db.QueryContext(ctx, `
+		SELECT d.id, d.status, d.environment, p.slug
+		FROM deployments d
+		JOIN projects p ON p.id = d.project_id
+		WHERE d.project_id = $1
+		  AND d.status IN ('active', 'running', 'starting', 'healthy', 'deploying')
+		ORDER BY d.environment, d.created_at`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deployments: %w", err)
+	}
+
+	var deployments []exportedDeployment
+	for rows.Next() {
+		var d exportedDeployment
+		var environment, slug string
+		if err := rows.Scan(&d.id, &d.status, &environment, &slug); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.labels = []Label{
+			{"project", slug},
+			{"project_id", projectID},
+			{"environment", environment},
+			{"deployment_id", d.id},
+		}
+		deployments = append(deployments, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	up := Family{Name: "obtura_deployment_up", Help: "Whether the deployment is running (1) or starting (0).", Type: "gauge"}
+	cpu := Family{Name: "obtura_container_cpu_usage_percent", Help: "Container CPU usage in percent of one core.", Type: "gauge"}
+	memory := Family{Name: "obtura_container_memory_usage_bytes", Help: "Container memory usage.", Type: "gauge"}
+	rx := Family{Name: "obtura_container_network_receive_bytes", Help: "Bytes received by the container.", Type: "counter"}
+	tx := Family{Name: "obtura_container_network_transmit_bytes", Help: "Bytes sent by the container.", Type: "counter"}
+	sampled := Family{Name: "obtura_container_last_sample_timestamp_seconds", Help: "Unix time of the latest container sample.", Type: "gauge"}
+	requests := Family{Name: "obtura_http_requests", Help: "HTTP requests served through the router.", Type: "counter"}
+	duration := Family{Name: "obtura_http_request_duration_seconds", Help: "HTTP request duration seen by the router.", Type: "histogram"}
+
+	for _, d := range deployments {
+		running := 0.0
+		if d.status != "starting" && d.status != "deploying" {
+			running = 1
+		}
+		up.Samples = append(up.Samples, Sample{up.Name, d.labels, running})
+
+		latest, err := e.latestContainerSample(ctx, d.id)
+		if err != nil {
+			return nil, err
+		}
+		if latest != nil {
+			cpu.Samples = append(cpu.Samples, Sample{cpu.Name, d.labels, latest.CPUUsage})
+			memory.Samples = append(memory.Samples, Sample{memory.Name, d.labels, float64(latest.MemoryUsage)})
+			rx.Samples = append(rx.Samples, Sample{rx.Name + "_total", d.labels, float64(latest.NetworkRx)})
+			tx.Samples = append(tx.Samples, Sample{tx.Name + "_total", d.labels, float64(latest.NetworkTx)})
+			sampled.Samples = append(sampled.Samples, Sample{sampled.Name, d.labels, float64(latest.Timestamp.Unix())})
+		}
+
+		totals, err := e.redis.HGetAll(ctx, httpmetrics.TotalsKey(d.id)).Result()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("failed to read HTTP totals: %w", err)
+		}
+		if len(totals) == 0 {
+			continue
+		}
+
+		classes := make([]string, 0, 6)
+		for field := range totals {
+			if class, ok := strings.CutPrefix(field, "requests_"); ok {
+				classes = append(classes, class)
+			}
+		}
+		sort.Strings(classes)
+
+		var count float64
+		for _, class := range classes {
+			n := totalsValue(totals, "requests_"+class)
+			count += n
+			requests.Samples = append(requests.Samples, Sample{
+				requests.Name + "_total", withLabel(d.labels, "status_class", class), n,
+			})
+		}
+
+		var cumulative float64
+		for i, le := range httpmetrics.DurationBuckets {
+			cumulative += totalsValue(totals, "bucket_"+strconv.Itoa(i))
+			duration.Samples = append(duration.Samples, Sample{
+				duration.Name + "_bucket", withLabel(d.labels, "le", formatFloat(le)), cumulative,
+			})
+		}
+		duration.Samples = append(duration.Samples,
+			Sample{duration.Name + "_bucket", withLabel(d.labels, "le", "+Inf"), count},
+			Sample{duration.Name + "_sum", d.labels, totalsValue(totals, "duration_us_sum") / 1e6},
+			Sample{duration.Name + "_count", d.labels, count},
+		)
+	}
+
+	return []Family{up, cpu, memory, rx, tx, sampled, requests, duration}, nil
+}
+
+// latestContainerSample prefers the Redis copy the collector writes every cycle and
+// falls back to the newest stored row
+func (e *Exporter) latestContainerSample(ctx context.Context, deploymentID string) (*DeploymentMetric, error) {
+	cached, err := e.redis.HGetAll(ctx, fmt.Sprintf("metrics:%s:latest", deploymentID)).Result()
+	if err == nil && cached["timestamp"] != "" {
+		ts, _ := strconv.ParseInt(cached["timestamp"], 10, 64)
+		m := &DeploymentMetric{DeploymentID: deploymentID, Timestamp: time.Unix(ts, 0)}
+		m.CPUUsage, _ = strconv.ParseFloat(cached["cpu_usage"], 64)
+		m.MemoryUsage, _ = strconv.ParseInt(cached["memory_usage"], 10, 64)
+		m.NetworkRx, _ = strconv.ParseInt(cached["network_rx"], 10, 64)
+		m.NetworkTx, _ = strconv.ParseInt(cached["network_tx"], 10, 64)
+		return m, nil
+	}
+
+	m := &DeploymentMetric{DeploymentID: deploymentID}
+	var cpu sql.NullFloat64
+	var memory, netRx, netTx sql.NullInt64
+	err = e.db.QueryRowContext(ctx, `
+		SELECT timestamp, cpu_usage, memory_usage, network_rx, network_tx
+		FROM deployments_metrics
+		WHERE deployment_id = $1
+		ORDER BY timestamp DESC
+		LIMIT 1`, deploymentID).Scan(&m.Timestamp, &cpu, &memory, &netRx, &netTx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest metrics: %w", err)
+	}
+	m.CPUUsage, m.MemoryUsage, m.NetworkRx, m.NetworkTx = cpu.Float64, memory.Int64, netRx.Int64, netTx.Int64
+	return m, nil
+}
+
+// WriteText writes the families in the Prometheus text format, or OpenMetrics when
+// openMetrics is set
+func WriteText(w io.Writer, families []Family, openMetrics bool) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		if len(f.Samples) == 0 {
+			continue
+		}
+		// OpenMetrics names counter families without the _total suffix, the text
+		// format names them after the sample
+		name := f.Name
+		if f.Type == "counter" && !openMetrics {
+			name += "_total"
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, escapeHelp(f.Help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, f.Type)
+		for _, s := range f.Samples {
+			bw.WriteString(s.Name)
+			if len(s.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					fmt.Fprintf(bw, "%s=\"%s\"", l.Name, escapeLabelValue(l.Value))
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteByte(' ')
+			bw.WriteString(formatFloat(s.Value))
+			bw.WriteByte('\n')
+		}
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+func withLabel(labels []Label, name, value string) []Label {
+	out := make([]Label, len(labels), len(labels)+1)
+	copy(out, labels)
+	return append(out, Label{name, value})
+}
+
+func totalsValue(totals map[string]string, field string) float64 {
+	n, _ := strconv.ParseFloat(totals[field], 64)
+	return n
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string       { return helpEscaper.Replace(s) }
+func escapeLabelValue(s string) string { return labelEscaper.Replace(s) }
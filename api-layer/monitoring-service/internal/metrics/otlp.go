@@ -0,0 +1,210 @@
+package metrics
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// OTLPSamples converts the metrics an app pushed over OTLP to samples named the way
+// Prometheus' own OTLP endpoint names them: dots become underscores, monotonic sums
+// become _total counters and histograms and summaries are split into their
+// _bucket/_sum/_count series. job and instance come from the service resource
+// attributes. Samples are grouped by their timestamp in Unix seconds. Delta sums and
+// exponential histograms are not supported; the number of points rejected is returned.
+func OTLPSamples(rm *metricspb.ResourceMetrics) (map[int64][]ScrapedSample, int) {
+	resource := OTLPAttributes(rm.GetResource().GetAttributes())
+	base := map[string]string{}
+	if job := resource["service.name"]; job != "" {
+		if ns := resource["service.namespace"]; ns != "" {
+			job = ns + "/" + job
+		}
+		base["job"] = job
+	}
+	if instance := resource["service.instance.id"]; instance != "" {
+		base["instance"] = instance
+	} else if host := resource["host.name"]; host != "" {
+		base["instance"] = host
+	}
+
+	out := make(map[int64][]ScrapedSample)
+	rejected := 0
+	add := func(ts uint64, name, typ, help string, attrs []*commonpb.KeyValue, extra map[string]string, value float64) {
+		labels := make(map[string]string, len(base)+len(attrs)+len(extra))
+		for k, v := range base {
+			labels[k] = v
+		}
+		for k, v := range OTLPAttributes(attrs) {
+			labels[promName(k)] = v
+		}
+		for k, v := range extra {
+			labels[k] = v
+		}
+
+		sec := time.Now().Unix()
+		if ts > 0 {
+			sec = int64(ts / uint64(time.Second))
+		}
+		out[sec] = append(out[sec], ScrapedSample{Name: name, Type: typ, Help: help, Labels: labels, Value: value})
+	}
+
+	for _, sm := range rm.GetScopeMetrics() {
+		for _, m := range sm.GetMetrics() {
+			name := promName(m.GetName())
+			help := m.GetDescription()
+
+			switch data := m.GetData().(type) {
+			case *metricspb.Metric_Gauge:
+				for _, p := range data.Gauge.GetDataPoints() {
+					add(p.GetTimeUnixNano(), name, "gauge", help, p.GetAttributes(), nil, numberValue(p))
+				}
+
+			case *metricspb.Metric_Sum:
+				if data.Sum.GetAggregationTemporality() == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA {
+					rejected += len(data.Sum.GetDataPoints())
+					continue
+				}
+				typ, sumName := "gauge", name
+				if data.Sum.GetIsMonotonic() {
+					typ = "counter"
+					if !strings.HasSuffix(sumName, "_total") {
+						sumName += "_total"
+					}
+				}
+				for _, p := range data.Sum.GetDataPoints() {
+					add(p.GetTimeUnixNano(), sumName, typ, help, p.GetAttributes(), nil, numberValue(p))
+				}
+
+			case *metricspb.Metric_Histogram:
+				if data.Histogram.GetAggregationTemporality() == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA {
+					rejected += len(data.Histogram.GetDataPoints())
+					continue
+				}
+				for _, p := range data.Histogram.GetDataPoints() {
+					var cumulative uint64
+					for i, count := range p.GetBucketCounts() {
+						cumulative += count
+						le := "+Inf"
+						if i < len(p.GetExplicitBounds()) {
+							le = strconv.FormatFloat(p.GetExplicitBounds()[i], 'g', -1, 64)
+						}
+						add(p.GetTimeUnixNano(), name+"_bucket", "histogram", help, p.GetAttributes(),
+							map[string]string{"le": le}, float64(cumulative))
+					}
+					if p.Sum != nil {
+						add(p.GetTimeUnixNano(), name+"_sum", "histogram", help, p.GetAttributes(), nil, p.GetSum())
+					}
+					add(p.GetTimeUnixNano(), name+"_count", "histogram", help, p.GetAttributes(), nil, float64(p.GetCount()))
+				}
+
+			case *metricspb.Metric_Summary:
+				for _, p := range data.Summary.GetDataPoints() {
+					for _, q := range p.GetQuantileValues() {
+						add(p.GetTimeUnixNano(), name, "summary", help, p.GetAttributes(),
+							map[string]string{"quantile": strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)}, q.GetValue())
+					}
+					add(p.GetTimeUnixNano(), name+"_sum", "summary", help, p.GetAttributes(), nil, p.GetSum())
+					add(p.GetTimeUnixNano(), name+"_count", "summary", help, p.GetAttributes(), nil, float64(p.GetCount()))
+				}
+
+			case *metricspb.Metric_ExponentialHistogram:
+				rejected += len(data.ExponentialHistogram.GetDataPoints())
+			}
+		}
+	}
+
+	return out, rejected
+}
+
+func numberValue(p *metricspb.NumberDataPoint) float64 {
+	if v, ok := p.GetValue().(*metricspb.NumberDataPoint_AsInt); ok {
+		return float64(v.AsInt)
+	}
+	return p.GetAsDouble()
+}
+
+// promName replaces the characters Prometheus names do not allow
+func promName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if isMetricNameChar(c, i == 0) && c != ':' {
+			b.WriteByte(c)
+		} else if i == 0 && c >= '0' && c <= '9' {
+			b.WriteString("_")
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// OTLPAttributes flattens OTLP attributes to strings; arrays and maps become JSON
+func OTLPAttributes(attrs []*commonpb.KeyValue) map[string]string {
+	out := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		out[kv.GetKey()] = OTLPValueString(kv.GetValue())
+	}
+	return out
+}
+
+func OTLPValueString(v *commonpb.AnyValue) string {
+	switch val := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return val.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(val.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(val.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		if math.IsInf(val.DoubleValue, 0) || math.IsNaN(val.DoubleValue) {
+			return strconv.FormatFloat(val.DoubleValue, 'g', -1, 64)
+		}
+		return strconv.FormatFloat(val.DoubleValue, 'f', -1, 64)
+	case *commonpb.AnyValue_BytesValue:
+		return hex.EncodeToString(val.BytesValue)
+	case *commonpb.AnyValue_ArrayValue, *commonpb.AnyValue_KvlistValue:
+		data, _ := json.Marshal(OTLPValue(v))
+		return string(data)
+	}
+	return ""
+}
+
+// OTLPValue converts an OTLP value to its JSON equivalent
+func OTLPValue(v *commonpb.AnyValue) interface{} {
+	switch val := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return val.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return val.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return val.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		if math.IsInf(val.DoubleValue, 0) || math.IsNaN(val.DoubleValue) {
+			return strconv.FormatFloat(val.DoubleValue, 'g', -1, 64)
+		}
+		return val.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return hex.EncodeToString(val.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]interface{}, 0, len(val.ArrayValue.GetValues()))
+		for _, item := range val.ArrayValue.GetValues() {
+			values = append(values, OTLPValue(item))
+		}
+		return values
+	case *commonpb.AnyValue_KvlistValue:
+		fields := make(map[string]interface{}, len(val.KvlistValue.GetValues()))
+		for _, kv := range val.KvlistValue.GetValues() {
+			fields[kv.GetKey()] = OTLPValue(kv.GetValue())
+		}
+		return fields
+	}
+	return nil
+}
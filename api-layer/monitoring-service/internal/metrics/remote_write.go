@@ -0,0 +1,382 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"monitoring-service/pkg/logger"
+	"monitoring-service/pkg/netguard"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/lib/pq"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	DefaultRemoteWriteInterval = 30
+
+	remoteWriteTimeout     = 15 * time.Second
+	remoteWriteConcurrency = 5
+)
+
+var (
+	ErrRemoteWriteNotFound  = errors.New("remote-write target not found")
+	ErrRemoteWriteNameTaken = errors.New("a remote-write target with this name already exists")
+)
+
+// RemoteWriteTarget is a customer Prometheus remote-write endpoint (Prometheus, Mimir,
+// Grafana Cloud, ...) the project's metrics are pushed to. Secrets are write-only.
+type RemoteWriteTarget struct {
+	ID                string            `json:"id"`
+	ProjectID         string            `json:"project_id"`
+	Name              string            `json:"name"`
+	URL               string            `json:"url"`
+	BasicAuthUsername string            `json:"basic_auth_username"`
+	BasicAuthPassword string            `json:"basic_auth_password,omitempty"`
+	BearerToken       string            `json:"bearer_token,omitempty"`
+	HasPassword       bool              `json:"has_password"`
+	HasBearerToken    bool              `json:"has_bearer_token"`
+	Headers           map[string]string `json:"headers"`
+	IntervalSeconds   int               `json:"interval_seconds"`
+	Enabled           bool              `json:"enabled"`
+	LastPushAt        *time.Time        `json:"last_push_at"`
+	LastSuccessAt     *time.Time        `json:"last_success_at"`
+	LastError         string            `json:"last_error"`
+	CreatedAt         time.Time         `json:"created_at"`
+}
+
+// Headers the remote-write protocol sets itself
+var reservedRemoteWriteHeaders = map[string]bool{
+	"authorization": true, "content-type": true, "content-encoding": true,
+	"content-length": true, "host": true, "user-agent": true,
+	"x-prometheus-remote-write-version": true,
+}
+
+func (t *RemoteWriteTarget) Validate() error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" || len(t.Name) > 100 {
+		return fmt.Errorf("name is required and must be at most 100 characters")
+	}
+
+	u, err := url.Parse(t.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL")
+	}
+	if len(t.URL) > 2048 {
+		return fmt.Errorf("url must be at most 2048 characters")
+	}
+
+	if t.BearerToken != "" && (t.BasicAuthUsername != "" || t.BasicAuthPassword != "") {
+		return fmt.Errorf("use either basic auth or a bearer token, not both")
+	}
+
+	for name := range t.Headers {
+		if reservedRemoteWriteHeaders[strings.ToLower(name)] {
+			return fmt.Errorf("header %s is set by the remote-write protocol", name)
+		}
+	}
+
+	if t.IntervalSeconds == 0 {
+		t.IntervalSeconds = DefaultRemoteWriteInterval
+	}
+	if t.IntervalSeconds < 15 || t.IntervalSeconds > 3600 {
+		return fmt.Errorf("interval_seconds must be between 15 and 3600")
+	}
+
+	return nil
+}
+
+// RemoteWriter pushes each project's exported metrics to its remote-write targets
+type RemoteWriter struct {
+	db       *sql.DB
+	exporter *Exporter
+	client   *http.Client
+}
+
+func NewRemoteWriter(db *sql.DB, exporter *Exporter) *RemoteWriter {
+	// Targets are customer supplied, so never let them reach the platform's own network
+	dialer := netguard.Dialer("remote-write")
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &RemoteWriter{
+		db:       db,
+		exporter: exporter,
+		client:   &http.Client{Timeout: remoteWriteTimeout, Transport: transport},
+	}
+}
+
+type dueRemoteWrite struct {
+	target   RemoteWriteTarget
+	password string
+	token    string
+}
+
+// PushDue pushes to every target whose interval elapsed. Claiming a target bumps
+// last_push_at, so several workers never push the same target twice.
+func (w *RemoteWriter) PushDue(ctx context.Context) error {
+	rows, err := w.db.QueryContext(ctx, `
+		UPDATE metrics_remote_write_targets SET last_push_at = NOW()
+		WHERE enabled = true
+		  AND (last_push_at IS NULL OR last_push_at <= NOW() - make_interval(secs => interval_seconds))
+		RETURNING id, project_id, name, url, COALESCE(basic_auth_username, ''),
+		          COALESCE(basic_auth_password, ''), COALESCE(bearer_token, ''), COALESCE(headers, '{}')`)
+	if err != nil {
+		return fmt.Errorf("failed to claim remote-write targets: %w", err)
+	}
+
+	var due []dueRemoteWrite
+	for rows.Next() {
+		var d dueRemoteWrite
+		var headers []byte
+		if err := rows.Scan(&d.target.ID, &d.target.ProjectID, &d.target.Name, &d.target.URL,
+			&d.target.BasicAuthUsername, &d.password, &d.token, &headers); err != nil {
+			rows.Close()
+			return err
+		}
+		json.Unmarshal(headers, &d.target.Headers)
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	sem := make(chan struct{}, remoteWriteConcurrency)
+	var wg sync.WaitGroup
+	for _, d := range due {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(d dueRemoteWrite) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			pushErr := w.push(ctx, d)
+			if pushErr != nil {
+				logger.Warn("Remote-write push failed",
+					logger.String("target_id", d.target.ID),
+					logger.String("project_id", d.target.ProjectID),
+					logger.Err(pushErr))
+			}
+			w.recordPush(ctx, d.target.ID, pushErr)
+		}(d)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (w *RemoteWriter) push(ctx context.Context, d dueRemoteWrite) error {
+	families, err := w.exporter.Collect(ctx, d.target.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	body := s2.EncodeSnappy(nil, encodeWriteRequest(families, time.Now()))
+
+	ctx, cancel := context.WithTimeout(ctx, remoteWriteTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.target.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range d.target.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "obtura-monitoring")
+	switch {
+	case d.token != "":
+		req.Header.Set("Authorization", "Bearer "+d.token)
+	case d.target.BasicAuthUsername != "" || d.password != "":
+		req.SetBasicAuth(d.target.BasicAuthUsername, d.password)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote-write returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	return nil
+}
+
+func (w *RemoteWriter) recordPush(ctx context.Context, targetID string, pushErr error) {
+	var err error
+	if pushErr == nil {
+		_, err = w.db.ExecContext(ctx, `
+			UPDATE metrics_remote_write_targets SET last_success_at = NOW(), last_error = NULL WHERE id = $1`, targetID)
+	} else {
+		_, err = w.db.ExecContext(ctx, `
+			UPDATE metrics_remote_write_targets SET last_error = $2 WHERE id = $1`, targetID, pushErr.Error())
+	}
+	if err != nil {
+		logger.Error("Failed to record remote-write push", logger.String("target_id", targetID), logger.Err(err))
+	}
+}
+
+// encodeWriteRequest builds a prometheus.WriteRequest protobuf with one sample per
+// series, all stamped with ts
+func encodeWriteRequest(families []Family, ts time.Time) []byte {
+	millis := ts.UnixMilli()
+
+	var out []byte
+	for _, f := range families {
+		for _, s := range f.Samples {
+			labels := append([]Label{{"__name__", s.Name}}, s.Labels...)
+			sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+
+			var series []byte
+			for _, l := range labels {
+				var label []byte
+				label = protowire.AppendTag(label, 1, protowire.BytesType)
+				label = protowire.AppendString(label, l.Name)
+				label = protowire.AppendTag(label, 2, protowire.BytesType)
+				label = protowire.AppendString(label, l.Value)
+
+				series = protowire.AppendTag(series, 1, protowire.BytesType)
+				series = protowire.AppendBytes(series, label)
+			}
+
+			var sample []byte
+			sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(s.Value))
+			sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(millis))
+
+			series = protowire.AppendTag(series, 2, protowire.BytesType)
+			series = protowire.AppendBytes(series, sample)
+
+			out = protowire.AppendTag(out, 1, protowire.BytesType)
+			out = protowire.AppendBytes(out, series)
+		}
+	}
+	return out
+}
+
+const remoteWriteColumns = `
+	id, project_id, name, url, COALESCE(basic_auth_username, ''),
+	basic_auth_password IS NOT NULL AND basic_auth_password != '',
+	bearer_token IS NOT NULL AND bearer_token != '',
+	COALESCE(headers, '{}'), interval_seconds, enabled,
+	last_push_at, last_success_at, COALESCE(last_error, ''), created_at`
+
+func scanRemoteWriteTarget(row interface{ Scan(...interface{}) error }) (*RemoteWriteTarget, error) {
+	var t RemoteWriteTarget
+	var headers []byte
+	var lastPush, lastSuccess sql.NullTime
+	if err := row.Scan(&t.ID, &t.ProjectID, &t.Name, &t.URL, &t.BasicAuthUsername,
+		&t.HasPassword, &t.HasBearerToken, &headers, &t.IntervalSeconds, &t.Enabled,
+		&lastPush, &lastSuccess, &t.LastError, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	json.Unmarshal(headers, &t.Headers)
+	if lastPush.Valid {
+		t.LastPushAt = &lastPush.Time
+	}
+	if lastSuccess.Valid {
+		t.LastSuccessAt = &lastSuccess.Time
+	}
+	return &t, nil
+}
+
+func (w *RemoteWriter) ListTargets(ctx context.Context, projectID string) ([]RemoteWriteTarget, error) {
+	rows, err := w.db.QueryContext(ctx, `
+		SELECT `+remoteWriteColumns+`
+		FROM metrics_remote_write_targets
+		WHERE project_id = $1
+		ORDER BY name`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := []RemoteWriteTarget{}
+	for rows.Next() {
+		t, err := scanRemoteWriteTarget(rows)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, *t)
+	}
+	return targets, rows.Err()
+}
+
+func (w *RemoteWriter) CreateTarget(ctx context.Context, projectID string, t *RemoteWriteTarget) (*RemoteWriteTarget, error) {
+	headers, _ := json.Marshal(t.Headers)
+	row := w.db.QueryRowContext(ctx, `
+		INSERT INTO metrics_remote_write_targets (
+			project_id, name, url, basic_auth_username, basic_auth_password, bearer_token,
+			headers, interval_seconds, enabled
+		) VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9)
+		RETURNING `+remoteWriteColumns,
+		projectID, t.Name, t.URL, t.BasicAuthUsername, t.BasicAuthPassword, t.BearerToken,
+		headers, t.IntervalSeconds, t.Enabled)
+
+	created, err := scanRemoteWriteTarget(row)
+	return created, remoteWriteError(err)
+}
+
+// UpdateTarget replaces the target's settings. Empty secrets keep the stored ones, so
+// clients can update a target without re-sending them.
+func (w *RemoteWriter) UpdateTarget(ctx context.Context, projectID, targetID string, t *RemoteWriteTarget) (*RemoteWriteTarget, error) {
+	headers, _ := json.Marshal(t.Headers)
+	row := w.db.QueryRowContext(ctx, `
+		UPDATE metrics_remote_write_targets SET
+			name = $3, url = $4, basic_auth_username = NULLIF($5, ''),
+			basic_auth_password = CASE WHEN $6 != '' THEN $6 WHEN $7 != '' THEN NULL ELSE basic_auth_password END,
+			bearer_token = CASE WHEN $7 != '' THEN $7 WHEN $5 != '' THEN NULL ELSE bearer_token END,
+			headers = $8, interval_seconds = $9, enabled = $10, updated_at = NOW()
+		WHERE id = $1 AND project_id = $2
+		RETURNING `+remoteWriteColumns,
+		targetID, projectID, t.Name, t.URL, t.BasicAuthUsername, t.BasicAuthPassword, t.BearerToken,
+		headers, t.IntervalSeconds, t.Enabled)
+
+	updated, err := scanRemoteWriteTarget(row)
+	return updated, remoteWriteError(err)
+}
+
+func (w *RemoteWriter) DeleteTarget(ctx context.Context, projectID, targetID string) error {
+	res, err := w.db.ExecContext(ctx, `
+		DELETE FROM metrics_remote_write_targets WHERE id = $1 AND project_id = $2`, targetID, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRemoteWriteNotFound
+	}
+	return nil
+}
+
+func remoteWriteError(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrRemoteWriteNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrRemoteWriteNameTaken
+	}
+	return err
+}
@@ -0,0 +1,401 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"monitoring-service/pkg/logger"
+)
+
+// Resolution is a metrics tier
+type Resolution string
+
+const (
+	ResolutionRaw    Resolution = "raw"
+	ResolutionMinute Resolution = "1m"
+	ResolutionHour   Resolution = "1h"
+	ResolutionDay    Resolution = "1d"
+
+	// Sources may still receive rows for a bucket shortly after it ends (the Traefik
+	// collector flushes per minute), so buckets are only rolled up after this delay
+	rollupSettleDelay = 5 * time.Minute
+)
+
+// Retention per tier when a company has no active subscription
+var defaultRetentionDays = map[string]int{
+	"metrics_raw_retention_days":    1,
+	"metrics_minute_retention_days": 7,
+	"metrics_hourly_retention_days": 90,
+	"metrics_daily_retention_days":  365,
+}
+
+// rollupStep builds one tier from its source. Steps sourced from another tier only roll
+// up buckets that tier has fully covered.
+type rollupStep struct {
+	name    string
+	unit    string // date_trunc unit of the bucket
+	after   string // Step whose watermark bounds this one; "" bounds by NOW()
+	maxSpan time.Duration
+	// Source table and time column, to find the first bucket
+	sourceTable  string
+	sourceColumn string
+	query        string // $1/$2: source range, $3: resolution, $4: bucket unit
+}
+
+var rollupSteps = []rollupStep{
+	{
+		name: "container_1m", unit: "minute", maxSpan: 6 * time.Hour,
+		sourceTable: "deployments_metrics", sourceColumn: "timestamp",
+		query: containerFromRawQuery,
+	},
+	{
+		name: "container_1h", unit: "hour", maxSpan: 7 * 24 * time.Hour,
+		sourceTable: "deployments_metrics", sourceColumn: "timestamp",
+		query: containerFromRawQuery,
+	},
+	{
+		name: "container_1d", unit: "day", after: "container_1h", maxSpan: 90 * 24 * time.Hour,
+		sourceTable: "container_metrics_rollups", sourceColumn: "bucket_start",
+		query: containerFromHourlyQuery,
+	},
+	{
+		name: "http_1h", unit: "hour", maxSpan: 7 * 24 * time.Hour,
+		sourceTable: "http_metrics_minute", sourceColumn: "timestamp_minute",
+		query: httpFromMinuteQuery,
+	},
+	{
+		name: "http_1d", unit: "day", after: "http_1h", maxSpan: 90 * 24 * time.Hour,
+		sourceTable: "http_metrics_rollups", sourceColumn: "bucket_start",
+		query: httpFromHourlyQuery,
+	},
+}
+
+const containerRollupConflict = `
+	ON CONFLICT (deployment_id, resolution, bucket_start) DO UPDATE SET
+		sample_count = EXCLUDED.sample_count,
+		cpu_avg = EXCLUDED.cpu_avg, cpu_min = EXCLUDED.cpu_min, cpu_max = EXCLUDED.cpu_max, cpu_p95 = EXCLUDED.cpu_p95,
+		memory_avg = EXCLUDED.memory_avg, memory_min = EXCLUDED.memory_min,
+		memory_max = EXCLUDED.memory_max, memory_p95 = EXCLUDED.memory_p95,
+		network_rx = EXCLUDED.network_rx, network_tx = EXCLUDED.network_tx`
+
+const containerFromRawQuery = `
+	INSERT INTO container_metrics_rollups (
+		deployment_id, resolution, bucket_start, sample_count,
+		cpu_avg, cpu_min, cpu_max, cpu_p95,
+		memory_avg, memory_min, memory_max, memory_p95,
+		network_rx, network_tx
+	)
+	SELECT deployment_id, $3, date_trunc($4, timestamp), COUNT(*),
+		AVG(cpu_usage), MIN(cpu_usage), MAX(cpu_usage),
+		percentile_cont(0.95) WITHIN GROUP (ORDER BY cpu_usage::float8),
+		AVG(memory_usage)::BIGINT, MIN(memory_usage), MAX(memory_usage),
+		(percentile_cont(0.95) WITHIN GROUP (ORDER BY memory_usage::float8))::BIGINT,
+		MAX(network_rx), MAX(network_tx)
+	FROM deployments_metrics
+	WHERE timestamp >= $1 AND timestamp < $2
+	GROUP BY deployment_id, 3` + containerRollupConflict
+
+// The daily p95 is the 95th percentile of the hourly p95 values
+const containerFromHourlyQuery = `
+	INSERT INTO container_metrics_rollups (
+		deployment_id, resolution, bucket_start, sample_count,
+		cpu_avg, cpu_min, cpu_max, cpu_p95,
+		memory_avg, memory_min, memory_max, memory_p95,
+		network_rx, network_tx
+	)
+	SELECT deployment_id, $3, date_trunc($4, bucket_start), SUM(sample_count),
+		SUM(cpu_avg * sample_count) / NULLIF(SUM(sample_count), 0), MIN(cpu_min), MAX(cpu_max),
+		percentile_cont(0.95) WITHIN GROUP (ORDER BY cpu_p95::float8),
+		(SUM(memory_avg * sample_count) / NULLIF(SUM(sample_count), 0))::BIGINT, MIN(memory_min), MAX(memory_max),
+		(percentile_cont(0.95) WITHIN GROUP (ORDER BY memory_p95::float8))::BIGINT,
+		MAX(network_rx), MAX(network_tx)
+	FROM container_metrics_rollups
+	WHERE resolution = '1h' AND bucket_start >= $1 AND bucket_start < $2
+	GROUP BY deployment_id, 3` + containerRollupConflict
+
+const httpRollupConflict = `
+	ON CONFLICT (deployment_id, resolution, bucket_start) DO UPDATE SET
+		request_count = EXCLUDED.request_count,
+		request_count_2xx = EXCLUDED.request_count_2xx, request_count_3xx = EXCLUDED.request_count_3xx,
+		request_count_4xx = EXCLUDED.request_count_4xx, request_count_5xx = EXCLUDED.request_count_5xx,
+		latency_avg = EXCLUDED.latency_avg, latency_min = EXCLUDED.latency_min,
+		latency_max = EXCLUDED.latency_max, latency_p95 = EXCLUDED.latency_p95,
+		requests_per_minute = EXCLUDED.requests_per_minute, error_rate = EXCLUDED.error_rate,
+		bytes_in = EXCLUDED.bytes_in, bytes_out = EXCLUDED.bytes_out`
+
+// httpRollupSelect aggregates rows of http_metrics_minute or http_metrics_rollups, whose
+// columns share names; %s is the source time column
+const httpRollupSelect = `
+	SELECT deployment_id, $3, date_trunc($4, %[1]s),
+		SUM(request_count), SUM(request_count_2xx), SUM(request_count_3xx),
+		SUM(request_count_4xx), SUM(request_count_5xx),
+		(SUM(latency_avg::BIGINT * request_count) / NULLIF(SUM(request_count), 0))::INTEGER,
+		MIN(latency_min), MAX(latency_max),
+		(percentile_cont(0.95) WITHIN GROUP (ORDER BY latency_p95::float8))::INTEGER,
+		SUM(request_count)::DECIMAL / (EXTRACT(EPOCH FROM ('1 ' || $4)::interval) / 60),
+		SUM(request_count_5xx)::DECIMAL / NULLIF(SUM(request_count), 0),
+		SUM(bytes_in), SUM(bytes_out)`
+
+const httpRollupInsert = `
+	INSERT INTO http_metrics_rollups (
+		deployment_id, resolution, bucket_start,
+		request_count, request_count_2xx, request_count_3xx, request_count_4xx, request_count_5xx,
+		latency_avg, latency_min, latency_max, latency_p95,
+		requests_per_minute, error_rate, bytes_in, bytes_out
+	)`
+
+var httpFromMinuteQuery = httpRollupInsert + fmt.Sprintf(httpRollupSelect, "timestamp_minute") + `
+	FROM http_metrics_minute
+	WHERE timestamp_minute >= $1 AND timestamp_minute < $2
+	GROUP BY deployment_id, 3` + httpRollupConflict
+
+var httpFromHourlyQuery = httpRollupInsert + fmt.Sprintf(httpRollupSelect, "bucket_start") + `
+	FROM http_metrics_rollups
+	WHERE resolution = '1h' AND bucket_start >= $1 AND bucket_start < $2
+	GROUP BY deployment_id, 3` + httpRollupConflict
+
+// retentionRule deletes a tier's rows older than the plan's retention, but never rows a
+// dependent rollup has not covered yet
+type retentionRule struct {
+	table      string
+	timeColumn string
+	filter     string // Extra condition selecting the tier
+	planColumn string
+	coveredBy  []string // Rollup steps built from this tier
+}
+
+var retentionRules = []retentionRule{
+	{"deployments_metrics", "timestamp", "", "metrics_raw_retention_days", []string{"container_1m", "container_1h"}},
+	{"container_metrics_rollups", "bucket_start", "resolution = '1m'", "metrics_minute_retention_days", nil},
+	{"container_metrics_rollups", "bucket_start", "resolution = '1h'", "metrics_hourly_retention_days", []string{"container_1d"}},
+	{"container_metrics_rollups", "bucket_start", "resolution = '1d'", "metrics_daily_retention_days", nil},
+	{"http_metrics_minute", "timestamp_minute", "", "metrics_minute_retention_days", []string{"http_1h"}},
+	{"http_metrics_rollups", "bucket_start", "resolution = '1h'", "metrics_hourly_retention_days", []string{"http_1d"}},
+	{"http_metrics_rollups", "bucket_start", "resolution = '1d'", "metrics_daily_retention_days", nil},
+	{"app_metric_samples", "timestamp", "", "metrics_minute_retention_days", nil},
+	{"app_metric_series", "last_seen_at", "", "metrics_minute_retention_days", nil},
+}
+
+// Rollup downsamples container and HTTP metrics into 1m/1h/1d tiers and applies the
+// per-plan retention of every tier
+type Rollup struct {
+	db *sql.DB
+}
+
+func NewRollup(db *sql.DB) *Rollup {
+	return &Rollup{db: db}
+}
+
+// Run advances every rollup step to the latest settled bucket
+func (r *Rollup) Run(ctx context.Context) error {
+	for _, step := range rollupSteps {
+		if err := r.runStep(ctx, step); err != nil {
+			return fmt.Errorf("rollup %s: %w", step.name, err)
+		}
+	}
+	return nil
+}
+
+func (r *Rollup) runStep(ctx context.Context, step rollupStep) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The row lock keeps replicas from rolling up the same range concurrently
+	var watermark sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT watermark FROM metrics_rollup_state WHERE name = $1 FOR UPDATE SKIP LOCKED`, step.name).Scan(&watermark)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var upper sql.NullTime
+	if step.after == "" {
+		err = tx.QueryRowContext(ctx, `SELECT date_trunc($1, NOW()::timestamp - $2::interval)`,
+			step.unit, fmt.Sprintf("%d seconds", int(rollupSettleDelay.Seconds()))).Scan(&upper)
+	} else {
+		err = tx.QueryRowContext(ctx, `
+			SELECT date_trunc($1, watermark) FROM metrics_rollup_state WHERE name = $2`, step.unit, step.after).Scan(&upper)
+	}
+	if err != nil || !upper.Valid {
+		return err
+	}
+
+	lower := watermark
+	if !lower.Valid {
+		// First run: start at the oldest source row still retained
+		err = tx.QueryRowContext(ctx, fmt.Sprintf(`SELECT date_trunc($1, MIN(%s)) FROM %s`,
+			step.sourceColumn, step.sourceTable), step.unit).Scan(&lower)
+		if err != nil {
+			return err
+		}
+		if !lower.Valid {
+			return nil
+		}
+	}
+
+	to := upper.Time
+	if limit := lower.Time.Add(step.maxSpan); to.After(limit) {
+		to = truncateToUnit(limit, step.unit)
+	}
+	if !to.After(lower.Time) {
+		return nil
+	}
+
+	resolution := map[string]string{"minute": "1m", "hour": "1h", "day": "1d"}[step.unit]
+	if _, err := tx.ExecContext(ctx, step.query, lower.Time, to, resolution, step.unit); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE metrics_rollup_state SET watermark = $1, updated_at = NOW() WHERE name = $2`, to, step.name); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ApplyRetention deletes rows past the retention of the deployment's company plan
+func (r *Rollup) ApplyRetention(ctx context.Context) error {
+	watermarks := map[string]sql.NullTime{}
+	rows, err := r.db.QueryContext(ctx, `SELECT name, watermark FROM metrics_rollup_state`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		var wm sql.NullTime
+		if err := rows.Scan(&name, &wm); err == nil {
+			watermarks[name] = wm
+		}
+	}
+	rows.Close()
+
+	for _, rule := range retentionRules {
+		// Rows newer than the oldest dependent watermark are still needed for rollups
+		var covered interface{}
+		for _, step := range rule.coveredBy {
+			wm := watermarks[step]
+			if !wm.Valid {
+				covered = nil
+				break
+			}
+			if t, ok := covered.(time.Time); !ok || wm.Time.Before(t) {
+				covered = wm.Time
+			}
+		}
+		if len(rule.coveredBy) > 0 && covered == nil {
+			continue
+		}
+
+		filter := ""
+		if rule.filter != "" {
+			filter = " AND m." + rule.filter
+		}
+		query := fmt.Sprintf(`
+			WITH retention AS (
+				SELECT DISTINCT ON (d.id) d.id AS deployment_id, COALESCE(sp.%[1]s, $1) AS days
+				FROM deployments d
+				JOIN projects p ON p.id = d.project_id
+				LEFT JOIN subscriptions s ON s.company_id = p.company_id AND s.status = 'active'
+				LEFT JOIN subscription_plans sp ON sp.id = s.plan_id
+				ORDER BY d.id, sp.%[1]s DESC NULLS LAST
+			)
+			DELETE FROM %[2]s m
+			USING retention r
+			WHERE m.deployment_id = r.deployment_id
+			  AND m.%[3]s < NOW() - make_interval(days => r.days)
+			  AND ($2::timestamp IS NULL OR m.%[3]s < $2::timestamp)%[4]s`,
+			rule.planColumn, rule.table, rule.timeColumn, filter)
+
+		res, err := r.db.ExecContext(ctx, query, defaultRetentionDays[rule.planColumn], covered)
+		if err != nil {
+			return fmt.Errorf("retention %s: %w", rule.table, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			logger.Info("Applied metrics retention",
+				logger.String("table", rule.table), logger.String("tier", rule.filter), logger.Int("deleted", int(n)))
+		}
+	}
+
+	return nil
+}
+
+// ResolutionFor picks the tier for a query range so charts get a few hundred to a few
+// thousand points, then steps to a coarser tier while the finer one no longer reaches
+// back to the start of the range
+func (r *Rollup) ResolutionFor(ctx context.Context, deploymentID string, start, end time.Time) Resolution {
+	span := end.Sub(start)
+	var tiers []Resolution
+	switch {
+	case span <= 6*time.Hour:
+		tiers = []Resolution{ResolutionRaw, ResolutionMinute, ResolutionHour, ResolutionDay}
+	case span <= 3*24*time.Hour:
+		tiers = []Resolution{ResolutionMinute, ResolutionHour, ResolutionDay}
+	case span <= 90*24*time.Hour:
+		tiers = []Resolution{ResolutionHour, ResolutionDay}
+	default:
+		return ResolutionDay
+	}
+
+	for _, tier := range tiers[:len(tiers)-1] {
+		var oldest sql.NullTime
+		var err error
+		if tier == ResolutionRaw {
+			err = r.db.QueryRowContext(ctx, `
+				SELECT MIN(timestamp) FROM deployments_metrics WHERE deployment_id = $1`, deploymentID).Scan(&oldest)
+		} else {
+			err = r.db.QueryRowContext(ctx, `
+				SELECT MIN(bucket_start) FROM container_metrics_rollups
+				WHERE deployment_id = $1 AND resolution = $2`, deploymentID, string(tier)).Scan(&oldest)
+		}
+		if err == nil && oldest.Valid && !oldest.Time.After(start) {
+			return tier
+		}
+	}
+	return tiers[len(tiers)-1]
+}
+
+// HTTPResolutionFor picks the tier for HTTP metrics over the last span
+func HTTPResolutionFor(span time.Duration) Resolution {
+	switch {
+	case span <= 3*24*time.Hour:
+		return ResolutionMinute
+	case span <= 90*24*time.Hour:
+		return ResolutionHour
+	default:
+		return ResolutionDay
+	}
+}
+
+// HTTPMetricsSource returns a FROM clause exposing the tier with http_metrics_minute's
+// column names, so range queries work unchanged on every tier
+func HTTPMetricsSource(res Resolution) string {
+	if res == ResolutionMinute || res == ResolutionRaw {
+		return "http_metrics_minute"
+	}
+	return fmt.Sprintf(`(
+		SELECT deployment_id, bucket_start AS timestamp_minute, request_count,
+			request_count_2xx, request_count_3xx, request_count_4xx, request_count_5xx,
+			latency_avg, latency_min, latency_max, latency_p95, requests_per_minute, error_rate,
+			bytes_in, bytes_out
+		FROM http_metrics_rollups WHERE resolution = '%s'
+	) http_metrics`, res)
+}
+
+func truncateToUnit(t time.Time, unit string) time.Time {
+	switch unit {
+	case "minute":
+		return t.Truncate(time.Minute)
+	case "hour":
+		return t.Truncate(time.Hour)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
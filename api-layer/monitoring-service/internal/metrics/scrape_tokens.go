@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const scrapeTokenPrefix = "obt_mt_"
+
+var (
+	ErrScrapeTokenNotFound  = errors.New("scrape token not found")
+	ErrScrapeTokenNameTaken = errors.New("a scrape token with this name already exists")
+	ErrInvalidScrapeToken   = errors.New("invalid scrape token")
+)
+
+// ScrapeToken lets a Prometheus server read one project's /metrics. Token is only set
+// in the response that created it.
+type ScrapeToken struct {
+	ID          string     `json:"id"`
+	ProjectID   string     `json:"project_id"`
+	Name        string     `json:"name"`
+	Token       string     `json:"token,omitempty"`
+	TokenPrefix string     `json:"token_prefix"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func hashScrapeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateScrapeToken issues a new token for the project
+func (e *Exporter) CreateScrapeToken(ctx context.Context, projectID, name string) (*ScrapeToken, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := scrapeTokenPrefix + hex.EncodeToString(raw)
+
+	t := &ScrapeToken{ProjectID: projectID, Name: name, Token: token, TokenPrefix: token[:len(scrapeTokenPrefix)+6]}
+	err := e.db.QueryRowContext(ctx, `
+		INSERT INTO metrics_scrape_tokens (project_id, name, token_hash, token_prefix)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`, projectID, name, hashScrapeToken(token), t.TokenPrefix).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrScrapeTokenNameTaken
+		}
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// ListScrapeTokens returns the project's active tokens without their secret
+func (e *Exporter) ListScrapeTokens(ctx context.Context, projectID string) ([]ScrapeToken, error) {
+	rows, err := e.db.QueryContext(ctx, `
+		SELECT id, project_id, name, token_prefix, last_used_at, created_at
+		FROM metrics_scrape_tokens
+		WHERE project_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []ScrapeToken{}
+	for rows.Next() {
+		var t ScrapeToken
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&t.ID, &t.ProjectID, &t.Name, &t.TokenPrefix, &lastUsed, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			t.LastUsedAt = &lastUsed.Time
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeScrapeToken stops the token from authenticating and frees its name
+func (e *Exporter) RevokeScrapeToken(ctx context.Context, projectID, tokenID string) error {
+	res, err := e.db.ExecContext(ctx, `
+		UPDATE metrics_scrape_tokens
+		SET revoked_at = NOW()
+		WHERE id = $1 AND project_id = $2 AND revoked_at IS NULL`, tokenID, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrScrapeTokenNotFound
+	}
+	return nil
+}
+
+// AuthenticateScrape returns the project the token belongs to
+func (e *Exporter) AuthenticateScrape(ctx context.Context, token string) (string, error) {
+	if !strings.HasPrefix(token, scrapeTokenPrefix) {
+		return "", ErrInvalidScrapeToken
+	}
+
+	var projectID string
+	err := e.db.QueryRowContext(ctx, `
+		UPDATE metrics_scrape_tokens SET last_used_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL
+		RETURNING project_id`, hashScrapeToken(token)).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidScrapeToken
+	}
+	if err != nil {
+		return "", err
+	}
+	return projectID, nil
+}
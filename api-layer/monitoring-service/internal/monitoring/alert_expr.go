@@ -0,0 +1,770 @@
+package monitoring
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alert rule expressions are a small, side-effect free language over the metrics of one
+// deployment:
+//
+//	cpu_usage > 85 and avg(memory_percent, "10m") >= 90
+//	error_rate > 5 or max(p95_latency, "5m") > 1500
+//	health_status == "unhealthy"
+//	app_rate("jobs_failed_total", "5m", "queue=emails") > 0.5
+//	log_count("level:error AND message:/timeout|ECONNRESET/", "10m") > 20
+//	issue_rate("3f2a9c0d4e5b6a7f8091a2b3c4d5e6f7", "5m") > 1
+//
+// Comparisons (> >= < <= == !=), arithmetic (+ - * /), and/or/not (&& || !) and
+// parentheses are supported. Functions take a metric and a window: avg, min and max
+// aggregate a built-in metric, app and app_rate read metrics scraped from the app.
+// log_count counts the logs matching a log query (see ParseLogQuery), issue_count and
+// issue_rate the events of an error issue by its fingerprint.
+// A comparison involving a metric without data is false.
+
+const (
+	maxAlertExprLength = 1000
+	maxAlertExprDepth  = 32
+	maxAlertExprRefs   = 20
+	minAlertExprWindow = time.Minute
+	maxAlertExprWindow = 24 * time.Hour
+
+	// Window of a built-in metric used without avg/min/max
+	defaultAlertExprWindow = 5 * time.Minute
+)
+
+type exprType int
+
+const (
+	exprNumber exprType = iota
+	exprString
+	exprBool
+)
+
+func (t exprType) String() string {
+	switch t {
+	case exprNumber:
+		return "number"
+	case exprString:
+		return "string"
+	default:
+		return "boolean"
+	}
+}
+
+// exprValue is a value during evaluation. Absent marks a metric without data.
+type exprValue struct {
+	num    float64
+	str    string
+	b      bool
+	absent bool
+}
+
+// alertMetricRef is a metric an expression reads; key identifies it across rules so
+// every deployment's metrics are queried once per cycle
+type alertMetricRef struct {
+	key      string
+	metric   string // Built-in metric, the app metric name for app/app_rate, the log query or issue fingerprint
+	fn       string // "", avg, min, max, app, app_rate, log_count, issue_count, issue_rate
+	window   time.Duration
+	match    map[string]string // Label matchers of app metrics
+	logQuery *LogQuery
+}
+
+type exprNode interface {
+	typ() exprType
+	eval(values map[string]exprValue) exprValue
+}
+
+type numberLit float64
+
+func (n numberLit) typ() exprType                       { return exprNumber }
+func (n numberLit) eval(map[string]exprValue) exprValue { return exprValue{num: float64(n)} }
+
+type stringLit string
+
+func (s stringLit) typ() exprType                       { return exprString }
+func (s stringLit) eval(map[string]exprValue) exprValue { return exprValue{str: string(s)} }
+
+type boolLit bool
+
+func (b boolLit) typ() exprType                       { return exprBool }
+func (b boolLit) eval(map[string]exprValue) exprValue { return exprValue{b: bool(b)} }
+
+type metricNode struct {
+	ref *alertMetricRef
+	t   exprType
+}
+
+func (m *metricNode) typ() exprType { return m.t }
+func (m *metricNode) eval(values map[string]exprValue) exprValue {
+	v, ok := values[m.ref.key]
+	if !ok {
+		return exprValue{absent: true}
+	}
+	return v
+}
+
+type unaryNode struct {
+	op string
+	x  exprNode
+}
+
+func (u *unaryNode) typ() exprType {
+	if u.op == "!" {
+		return exprBool
+	}
+	return exprNumber
+}
+
+func (u *unaryNode) eval(values map[string]exprValue) exprValue {
+	v := u.x.eval(values)
+	if u.op == "!" {
+		return exprValue{b: !v.b}
+	}
+	if v.absent {
+		return v
+	}
+	return exprValue{num: -v.num}
+}
+
+type binaryNode struct {
+	op   string
+	l, r exprNode
+}
+
+func (b *binaryNode) typ() exprType {
+	switch b.op {
+	case "+", "-", "*", "/":
+		return exprNumber
+	default:
+		return exprBool
+	}
+}
+
+func (b *binaryNode) eval(values map[string]exprValue) exprValue {
+	switch b.op {
+	case "&&":
+		if !b.l.eval(values).b {
+			return exprValue{}
+		}
+		return exprValue{b: b.r.eval(values).b}
+	case "||":
+		if b.l.eval(values).b {
+			return exprValue{b: true}
+		}
+		return exprValue{b: b.r.eval(values).b}
+	}
+
+	l, r := b.l.eval(values), b.r.eval(values)
+	switch b.op {
+	case "+", "-", "*", "/":
+		if l.absent || r.absent {
+			return exprValue{absent: true}
+		}
+		switch b.op {
+		case "+":
+			return exprValue{num: l.num + r.num}
+		case "-":
+			return exprValue{num: l.num - r.num}
+		case "*":
+			return exprValue{num: l.num * r.num}
+		default:
+			if r.num == 0 {
+				return exprValue{absent: true}
+			}
+			return exprValue{num: l.num / r.num}
+		}
+	}
+
+	if l.absent || r.absent {
+		return exprValue{}
+	}
+	if b.l.typ() == exprString {
+		switch b.op {
+		case "==":
+			return exprValue{b: l.str == r.str}
+		default:
+			return exprValue{b: l.str != r.str}
+		}
+	}
+	if b.l.typ() == exprBool {
+		switch b.op {
+		case "==":
+			return exprValue{b: l.b == r.b}
+		default:
+			return exprValue{b: l.b != r.b}
+		}
+	}
+	switch b.op {
+	case ">":
+		return exprValue{b: l.num > r.num}
+	case ">=":
+		return exprValue{b: l.num >= r.num}
+	case "<":
+		return exprValue{b: l.num < r.num}
+	case "<=":
+		return exprValue{b: l.num <= r.num}
+	case "==":
+		return exprValue{b: l.num == r.num}
+	default:
+		return exprValue{b: l.num != r.num}
+	}
+}
+
+// AlertExpr is a parsed, type-checked alert rule expression
+type AlertExpr struct {
+	root exprNode
+	refs []*alertMetricRef
+}
+
+// ParseAlertExpr parses an expression and checks that it is a boolean condition over
+// known metrics
+func ParseAlertExpr(src string) (*AlertExpr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, fmt.Errorf("expression is required")
+	}
+	if len(src) > maxAlertExprLength {
+		return nil, fmt.Errorf("expression must be at most %d characters", maxAlertExprLength)
+	}
+
+	tokens, err := lexAlertExpr(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens, refs: map[string]*alertMetricRef{}}
+	root, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().pos)
+	}
+	if root.typ() != exprBool {
+		return nil, fmt.Errorf("expression must be a condition, e.g. cpu_usage > 85")
+	}
+
+	e := &AlertExpr{root: root}
+	for _, ref := range p.refs {
+		e.refs = append(e.refs, ref)
+	}
+	sort.Slice(e.refs, func(i, j int) bool { return e.refs[i].key < e.refs[j].key })
+	return e, nil
+}
+
+// Eval evaluates the expression with the metric values of one deployment
+func (e *AlertExpr) Eval(values map[string]exprValue) bool {
+	return e.root.eval(values).b
+}
+
+// Threshold is the literal the expression compares against when it is a single
+// comparison, e.g. 85 in cpu_usage > 85
+func (e *AlertExpr) Threshold() (float64, bool) {
+	b, ok := e.root.(*binaryNode)
+	if !ok {
+		return 0, false
+	}
+	if n, ok := b.r.(numberLit); ok {
+		return float64(n), true
+	}
+	if n, ok := b.l.(numberLit); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+func lexAlertExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.' || src[i] == 'e' || src[i] == 'E' ||
+				(src[i] == '-' || src[i] == '+') && (src[i-1] == 'e' || src[i-1] == 'E')) {
+				i++
+			}
+			n, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", src[start:i], start)
+			}
+			tokens = append(tokens, exprToken{kind: tokNumber, text: src[start:i], num: n, pos: start})
+
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(src) {
+				if src[i] == '\\' && i+1 < len(src) {
+					sb.WriteByte(src[i+1])
+					i += 2
+					continue
+				}
+				if src[i] == c {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			tokens = append(tokens, exprToken{kind: tokString, text: sb.String(), pos: start})
+
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_':
+			start := i
+			for i < len(src) && (src[i] >= 'a' && src[i] <= 'z' || src[i] >= 'A' && src[i] <= 'Z' ||
+				src[i] >= '0' && src[i] <= '9' || src[i] == '_') {
+				i++
+			}
+			word := src[start:i]
+			switch strings.ToLower(word) {
+			case "and":
+				tokens = append(tokens, exprToken{kind: tokOp, text: "&&", pos: start})
+			case "or":
+				tokens = append(tokens, exprToken{kind: tokOp, text: "||", pos: start})
+			case "not":
+				tokens = append(tokens, exprToken{kind: tokOp, text: "!", pos: start})
+			default:
+				tokens = append(tokens, exprToken{kind: tokIdent, text: word, pos: start})
+			}
+
+		default:
+			op := ""
+			for _, candidate := range []string{">=", "<=", "==", "!=", "&&", "||", ">", "<", "!", "+", "-", "*", "/", "(", ")", ","} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			tokens = append(tokens, exprToken{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, exprToken{kind: tokEOF, pos: len(src)}), nil
+}
+
+// Parser
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	refs   map[string]*alertMetricRef
+}
+
+func (p *exprParser) peek() exprToken { return p.tokens[p.pos] }
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		if t.kind == tokEOF {
+			return fmt.Errorf("expected %q at end of expression", op)
+		}
+		return fmt.Errorf("expected %q at position %d", op, t.pos)
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr(depth int) (exprNode, error) {
+	if depth > maxAlertExprDepth {
+		return nil, fmt.Errorf("expression is nested too deeply")
+	}
+	l, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		r, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		if l.typ() != exprBool || r.typ() != exprBool {
+			return nil, fmt.Errorf("or needs conditions on both sides")
+		}
+		l = &binaryNode{op: "||", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) parseAnd(depth int) (exprNode, error) {
+	l, err := p.parseNot(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		r, err := p.parseNot(depth)
+		if err != nil {
+			return nil, err
+		}
+		if l.typ() != exprBool || r.typ() != exprBool {
+			return nil, fmt.Errorf("and needs conditions on both sides")
+		}
+		l = &binaryNode{op: "&&", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) parseNot(depth int) (exprNode, error) {
+	if p.accept("!") {
+		if depth > maxAlertExprDepth {
+			return nil, fmt.Errorf("expression is nested too deeply")
+		}
+		x, err := p.parseNot(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if x.typ() != exprBool {
+			return nil, fmt.Errorf("not needs a condition")
+		}
+		return &unaryNode{op: "!", x: x}, nil
+	}
+	return p.parseComparison(depth)
+}
+
+func (p *exprParser) parseComparison(depth int) (exprNode, error) {
+	l, err := p.parseSum(depth)
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokOp {
+		return l, nil
+	}
+	switch t.text {
+	case ">", ">=", "<", "<=", "==", "!=":
+	default:
+		return l, nil
+	}
+	p.next()
+
+	r, err := p.parseSum(depth)
+	if err != nil {
+		return nil, err
+	}
+	if l.typ() != r.typ() {
+		return nil, fmt.Errorf("cannot compare %s with %s at position %d", l.typ(), r.typ(), t.pos)
+	}
+	if l.typ() != exprNumber && t.text != "==" && t.text != "!=" {
+		return nil, fmt.Errorf("%s values only support == and != (position %d)", l.typ(), t.pos)
+	}
+	return &binaryNode{op: t.text, l: l, r: r}, nil
+}
+
+func (p *exprParser) parseSum(depth int) (exprNode, error) {
+	l, err := p.parseProduct(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokOp || t.text != "+" && t.text != "-" {
+			return l, nil
+		}
+		p.next()
+		r, err := p.parseProduct(depth)
+		if err != nil {
+			return nil, err
+		}
+		if l.typ() != exprNumber || r.typ() != exprNumber {
+			return nil, fmt.Errorf("%s needs numbers (position %d)", t.text, t.pos)
+		}
+		l = &binaryNode{op: t.text, l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseProduct(depth int) (exprNode, error) {
+	l, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokOp || t.text != "*" && t.text != "/" {
+			return l, nil
+		}
+		p.next()
+		r, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		if l.typ() != exprNumber || r.typ() != exprNumber {
+			return nil, fmt.Errorf("%s needs numbers (position %d)", t.text, t.pos)
+		}
+		l = &binaryNode{op: t.text, l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseUnary(depth int) (exprNode, error) {
+	if t := p.peek(); p.accept("-") {
+		if depth > maxAlertExprDepth {
+			return nil, fmt.Errorf("expression is nested too deeply")
+		}
+		x, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if x.typ() != exprNumber {
+			return nil, fmt.Errorf("- needs a number (position %d)", t.pos)
+		}
+		return &unaryNode{op: "-", x: x}, nil
+	}
+	return p.parsePrimary(depth)
+}
+
+func (p *exprParser) parsePrimary(depth int) (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return numberLit(t.num), nil
+	case tokString:
+		return stringLit(t.text), nil
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	case tokOp:
+		if t.text != "(" {
+			return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+		}
+		x, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+
+	switch strings.ToLower(t.text) {
+	case "true":
+		return boolLit(true), nil
+	case "false":
+		return boolLit(false), nil
+	}
+
+	if p.accept("(") {
+		return p.parseCall(t)
+	}
+
+	metric, ok := alertMetrics[t.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown metric %q at position %d", t.text, t.pos)
+	}
+	window := time.Duration(0)
+	if metric.typ == exprNumber {
+		window = defaultAlertExprWindow
+	}
+	return p.metric(&alertMetricRef{metric: t.text, window: window}, metric.typ)
+}
+
+// parseCall parses avg/min/max(metric, "window") and app/app_rate("name", ...)
+func (p *exprParser) parseCall(fn exprToken) (exprNode, error) {
+	var args []exprToken
+	for !p.accept(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg := p.next()
+		if arg.kind != tokIdent && arg.kind != tokString {
+			return nil, fmt.Errorf("arguments of %s must be metric names or strings (position %d)", fn.text, arg.pos)
+		}
+		args = append(args, arg)
+	}
+
+	name := strings.ToLower(fn.text)
+	switch name {
+	case "avg", "min", "max":
+		if len(args) != 2 || args[0].kind != tokIdent || args[1].kind != tokString {
+			return nil, fmt.Errorf(`%s takes a metric and a window, e.g. %s(cpu_usage, "5m")`, name, name)
+		}
+		metric, ok := alertMetrics[args[0].text]
+		if !ok {
+			return nil, fmt.Errorf("unknown metric %q at position %d", args[0].text, args[0].pos)
+		}
+		if metric.typ != exprNumber {
+			return nil, fmt.Errorf("%s cannot aggregate %s", name, args[0].text)
+		}
+		window, err := parseExprWindow(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return p.metric(&alertMetricRef{metric: args[0].text, fn: name, window: window}, exprNumber)
+
+	case "app", "app_rate":
+		if len(args) == 0 || args[0].kind != tokString || args[0].text == "" {
+			return nil, fmt.Errorf(`%s takes the name of a scraped metric, e.g. %s("http_requests_total")`, name, name)
+		}
+		ref := &alertMetricRef{metric: args[0].text, fn: name, match: map[string]string{}}
+		rest := args[1:]
+		if name == "app_rate" {
+			if len(rest) == 0 {
+				return nil, fmt.Errorf(`app_rate takes a metric and a window, e.g. app_rate("http_requests_total", "5m")`)
+			}
+			window, err := parseExprWindow(rest[0])
+			if err != nil {
+				return nil, err
+			}
+			ref.window = window
+			rest = rest[1:]
+		}
+		for _, arg := range rest {
+			label, value, ok := strings.Cut(arg.text, "=")
+			if arg.kind != tokString || !ok || label == "" {
+				return nil, fmt.Errorf(`label matchers must look like "label=value" (position %d)`, arg.pos)
+			}
+			ref.match[label] = value
+		}
+		return p.metric(ref, exprNumber)
+
+	case "log_count":
+		if len(args) != 2 || args[0].kind != tokString || args[1].kind != tokString {
+			return nil, fmt.Errorf(`log_count takes a log query and a window, e.g. log_count("level:error", "5m")`)
+		}
+		q, err := ParseLogQuery(args[0].text, ContainerLogColumns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log query at position %d: %w", args[0].pos, err)
+		}
+		window, err := parseExprWindow(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return p.metric(&alertMetricRef{metric: args[0].text, fn: name, window: window, logQuery: q}, exprNumber)
+
+	case "issue_count", "issue_rate":
+		if len(args) != 2 || args[0].kind != tokString || args[1].kind != tokString {
+			return nil, fmt.Errorf(`%s takes an issue fingerprint and a window, e.g. %s("3f2a9c0d4e5b6a7f8091a2b3c4d5e6f7", "5m")`, name, name)
+		}
+		if !isIssueFingerprint(args[0].text) {
+			return nil, fmt.Errorf("invalid issue fingerprint %q at position %d", args[0].text, args[0].pos)
+		}
+		window, err := parseExprWindow(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return p.metric(&alertMetricRef{metric: args[0].text, fn: name, window: window}, exprNumber)
+	}
+
+	return nil, fmt.Errorf("unknown function %q at position %d", fn.text, fn.pos)
+}
+
+func (p *exprParser) metric(ref *alertMetricRef, t exprType) (exprNode, error) {
+	labels := make([]string, 0, len(ref.match))
+	for k, v := range ref.match {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+	ref.key = fmt.Sprintf("%s(%q,%s,%s)", ref.fn, ref.metric, ref.window, strings.Join(labels, ","))
+
+	if existing, ok := p.refs[ref.key]; ok {
+		return &metricNode{ref: existing, t: t}, nil
+	}
+	if len(p.refs) >= maxAlertExprRefs {
+		return nil, fmt.Errorf("expression may read at most %d metrics", maxAlertExprRefs)
+	}
+	p.refs[ref.key] = ref
+	return &metricNode{ref: ref, t: t}, nil
+}
+
+// isIssueFingerprint reports whether s looks like a fingerprint from IssueFingerprint
+func isIssueFingerprint(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func parseExprWindow(t exprToken) (time.Duration, error) {
+	d, err := time.ParseDuration(t.text)
+	if err != nil || t.kind != tokString {
+		return 0, fmt.Errorf(`invalid window %q at position %d, use e.g. "5m" or "1h"`, t.text, t.pos)
+	}
+	if d < minAlertExprWindow || d > maxAlertExprWindow {
+		return 0, fmt.Errorf("window must be between %s and %s", formatExprWindow(minAlertExprWindow), formatExprWindow(maxAlertExprWindow))
+	}
+	return d, nil
+}
+
+// String renders the reference the way it is written in expressions
+func (r *alertMetricRef) String() string {
+	var args []string
+	switch r.fn {
+	case "":
+		return r.metric
+	case "app", "app_rate", "log_count", "issue_count", "issue_rate":
+		args = append(args, strconv.Quote(r.metric))
+	default:
+		args = append(args, r.metric)
+	}
+	if r.fn != "app" {
+		args = append(args, strconv.Quote(formatExprWindow(r.window)))
+	}
+	labels := make([]string, 0, len(r.match))
+	for k, v := range r.match {
+		labels = append(labels, strconv.Quote(k+"="+v))
+	}
+	sort.Strings(labels)
+	return r.fn + "(" + strings.Join(append(args, labels...), ", ") + ")"
+}
+
+func formatExprWindow(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
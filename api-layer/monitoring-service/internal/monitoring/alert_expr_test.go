@@ -0,0 +1,225 @@
+package monitoring
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// exprValues keys plain metric values by the refs the expression reads
+func exprValues(expr *AlertExpr, metrics map[string]exprValue) map[string]exprValue {
+	values := map[string]exprValue{}
+	for _, ref := range expr.refs {
+		if v, ok := metrics[ref.metric]; ok && ref.fn == "" {
+			values[ref.key] = v
+		}
+	}
+	return values
+}
+
+func TestAlertExprEval(t *testing.T) {
+	metrics := map[string]exprValue{
+		"cpu_usage":      {num: 90},
+		"memory_percent": {num: 50},
+		"error_rate":     {num: 2},
+		"response_time":  {num: 0},
+		"health_status":  {str: "unhealthy"},
+		"p95_latency":    {absent: true},
+	}
+
+	for _, tc := range []struct {
+		expr string
+		want bool
+	}{
+		{"cpu_usage > 85", true},
+		{"cpu_usage <= 85", false},
+
+		// && binds tighter than ||, and ! tighter than both
+		{"cpu_usage > 85 || memory_percent > 80 && error_rate > 5", true},
+		{"(cpu_usage > 85 || memory_percent > 80) && error_rate > 5", false},
+		{"not cpu_usage > 85 or error_rate > 1", true},
+		{"!(cpu_usage > 85 || error_rate > 1)", false},
+		{"!!(cpu_usage > 85)", true},
+
+		// * and / bind tighter than + and -, which are left associative
+		{"memory_percent + error_rate * 10 == 70", true},
+		{"(memory_percent + error_rate) * 10 == 520", true},
+		{"memory_percent - error_rate - 8 == 40", true},
+		{"cpu_usage / error_rate / 3 == 15", true},
+		{"-error_rate + 3 == 1", true},
+
+		{`health_status == "unhealthy"`, true},
+		{`health_status != "unhealthy"`, false},
+		{"true == (cpu_usage > 85)", true},
+
+		// Missing data and division by zero never satisfy a comparison
+		{"p95_latency > 0", false},
+		{"p95_latency <= 0", false},
+		{"cpu_usage / response_time > 0", false},
+		{"cpu_usage / response_time <= 0", false},
+		{"p95_latency > 0 || cpu_usage > 85", true},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			expr, err := ParseAlertExpr(tc.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := expr.Eval(exprValues(expr, metrics)); got != tc.want {
+				t.Fatalf("evaluated to %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseAlertExprErrors(t *testing.T) {
+	manyMetrics := make([]string, maxAlertExprRefs+1)
+	for i := range manyMetrics {
+		manyMetrics[i] = `app("metric_` + string(rune('a'+i)) + `") > 0`
+	}
+
+	for _, tc := range []struct {
+		name    string
+		expr    string
+		wantErr string
+	}{
+		{"empty", "  ", "expression is required"},
+		{"not a condition", "cpu_usage + 1", "expression must be a condition"},
+		{"bare metric", "cpu_usage", "expression must be a condition"},
+		{"number with string", `cpu_usage > "high"`, "cannot compare number with string"},
+		{"string with number", "health_status == 1", "cannot compare string with number"},
+		{"ordered strings", `health_status > "a"`, "string values only support == and !="},
+		{"ordered booleans", "(cpu_usage > 1) > (error_rate > 1)", "boolean values only support == and !="},
+		{"arithmetic on strings", `health_status + 1 > 0`, "+ needs numbers"},
+		{"arithmetic on conditions", "(cpu_usage > 1) * 2 > 0", "* needs numbers"},
+		{"negated string", `-health_status == "a"`, "- needs a number"},
+		{"and on numbers", "cpu_usage && error_rate > 1", "and needs conditions on both sides"},
+		{"or on numbers", "cpu_usage > 1 or error_rate", "or needs conditions on both sides"},
+		{"not on a number", "not cpu_usage", "not needs a condition"},
+		{"unknown metric", "disk_usage > 90", `unknown metric "disk_usage"`},
+		{"unknown function", `median(cpu_usage, "5m") > 90`, `unknown function "median"`},
+		{"aggregated string", `avg(health_status, "5m") == "a"`, "avg cannot aggregate health_status"},
+		{"window too short", `avg(cpu_usage, "30s") > 90`, "window must be between"},
+		{"window too long", `max(cpu_usage, "48h") > 90`, "window must be between"},
+		{"unbalanced", "(cpu_usage > 90", `expected ")" at end of expression`},
+		{"trailing token", "cpu_usage > 90 90", `unexpected "90"`},
+		{"unterminated string", `health_status == "up`, "unterminated string"},
+		{"too long", "cpu_usage > " + strings.Repeat("1", maxAlertExprLength), "expression must be at most"},
+		{"nested parentheses", strings.Repeat("(", maxAlertExprDepth+1) + "cpu_usage > 1" + strings.Repeat(")", maxAlertExprDepth+1),
+			"expression is nested too deeply"},
+		{"stacked not", strings.Repeat("!", maxAlertExprDepth+2) + "(cpu_usage > 1)", "expression is nested too deeply"},
+		{"stacked minus", strings.Repeat("-", maxAlertExprDepth+2) + "1 > cpu_usage", "expression is nested too deeply"},
+		{"too many metrics", strings.Join(manyMetrics, " || "), "expression may read at most"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseAlertExpr(tc.expr)
+			if err == nil {
+				t.Fatalf("parsed, want an error containing %q", tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error %q, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseAlertExprLimitsAllowTheMaximum(t *testing.T) {
+	metrics := make([]string, maxAlertExprRefs)
+	for i := range metrics {
+		metrics[i] = `app("metric_` + string(rune('a'+i)) + `") > 0`
+	}
+	nested := strings.Repeat("(", maxAlertExprDepth) + "cpu_usage > 1" + strings.Repeat(")", maxAlertExprDepth)
+	long := "cpu_usage > 1" + strings.Repeat(" ", maxAlertExprLength-len("cpu_usage > 1"))
+
+	for name, src := range map[string]string{
+		"metrics": strings.Join(metrics, " || "),
+		"nesting": nested,
+		"length":  long,
+	} {
+		if _, err := ParseAlertExpr(src); err != nil {
+			t.Errorf("%s at the limit: %v", name, err)
+		}
+	}
+}
+
+func TestParseAlertExprSharesRepeatedMetrics(t *testing.T) {
+	expr, err := ParseAlertExpr(`cpu_usage > 80 && avg(cpu_usage, "5m") > 70 && avg(cpu_usage, "15m") < 95`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A plain built-in metric reads the default window, distinct from avg over it
+	if len(expr.refs) != 3 {
+		t.Fatalf("read %d metrics, want 3", len(expr.refs))
+	}
+	for _, ref := range expr.refs {
+		if ref.fn == "" && ref.window != defaultAlertExprWindow {
+			t.Fatalf("cpu_usage reads a %s window, want %s", ref.window, defaultAlertExprWindow)
+		}
+	}
+}
+
+func TestAlertExprThreshold(t *testing.T) {
+	for _, tc := range []struct {
+		expr   string
+		want   float64
+		wantOK bool
+	}{
+		{"cpu_usage > 85", 85, true},
+		{"90 <= memory_percent", 90, true},
+		{"cpu_usage > 85 && error_rate > 5", 0, false},
+		{`health_status == "unhealthy"`, 0, false},
+	} {
+		expr, err := ParseAlertExpr(tc.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := expr.Threshold(); got != tc.want || ok != tc.wantOK {
+			t.Errorf("%s: threshold %v, %v; want %v, %v", tc.expr, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestAdvanceRuleState(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	firedAt := start.Add(5 * time.Minute)
+
+	for _, tc := range []struct {
+		name            string
+		prev            *ruleState
+		now             time.Time
+		forDuration     time.Duration
+		wantState       string
+		wantPendingFrom time.Time
+		wantFiringSince *time.Time
+	}{
+		{"fires at once without a duration", nil, start, 0,
+			AlertRuleStateFiring, start, &start},
+		{"starts pending", nil, start, 5 * time.Minute,
+			AlertRuleStatePending, start, nil},
+		{"stays pending before the duration",
+			&ruleState{State: AlertRuleStatePending, PendingSince: start}, start.Add(5*time.Minute - time.Second), 5 * time.Minute,
+			AlertRuleStatePending, start, nil},
+		{"fires once the duration has passed",
+			&ruleState{State: AlertRuleStatePending, PendingSince: start}, firedAt, 5 * time.Minute,
+			AlertRuleStateFiring, start, &firedAt},
+		{"keeps the time it first fired",
+			&ruleState{State: AlertRuleStateFiring, PendingSince: start, FiringSince: &firedAt}, start.Add(time.Hour), 5 * time.Minute,
+			AlertRuleStateFiring, start, &firedAt},
+		{"a longer duration after an edit waits again",
+			&ruleState{State: AlertRuleStatePending, PendingSince: start}, start.Add(10 * time.Minute), 15 * time.Minute,
+			AlertRuleStatePending, start, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := advanceRuleState(tc.prev, tc.now, tc.forDuration)
+
+			if got.State != tc.wantState || !got.PendingSince.Equal(tc.wantPendingFrom) {
+				t.Fatalf("state %s pending since %s; want %s since %s", got.State, got.PendingSince, tc.wantState, tc.wantPendingFrom)
+			}
+			switch {
+			case tc.wantFiringSince == nil && got.FiringSince != nil:
+				t.Fatalf("firing since %s, want not firing", got.FiringSince)
+			case tc.wantFiringSince != nil && (got.FiringSince == nil || !got.FiringSince.Equal(*tc.wantFiringSince)):
+				t.Fatalf("firing since %v, want %s", got.FiringSince, tc.wantFiringSince)
+			}
+		})
+	}
+}
@@ -2,115 +2,78 @@ package monitoring
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
-	"time"
+	"strings"
 
 	"monitoring-service/pkg/models"
 )
 
 type AlertManager struct {
 	orchestrator *Orchestrator
-	alertRules   []*AlertRule
 }
 
-type AlertRule struct {
-	Name      string
-	Condition string
-	Duration  time.Duration
-	Severity  string
-	Channels  []string
+func NewAlertManager(o *Orchestrator) *AlertManager {
+	return &AlertManager{orchestrator: o}
 }
 
-func NewAlertManager(o *Orchestrator) *AlertManager {
-	am := &AlertManager{
-		orchestrator: o,
-		alertRules:   make([]*AlertRule, 0),
+// ProcessAlerts evaluates the alert rules and triggers notifications
+func (am *AlertManager) ProcessAlerts(ctx context.Context) error {
+	if err := am.evaluateRules(ctx); err != nil {
+		return fmt.Errorf("failed to evaluate alert rules: %w", err)
 	}
 
-	// Load alert rules from configuration
-	am.loadAlertRules()
+	// Process pending alerts for notifications
+	if err := am.processPendingAlerts(ctx); err != nil {
+		return fmt.Errorf("failed to process pending alerts: %w", err)
+	}
 
-	return am
+	return nil
 }
 
-func (am *AlertManager) loadAlertRules() {
-	// Default alert rules
-	am.alertRules = []*AlertRule{
-		{
-			Name:      "cpu_threshold",
-			Condition: "cpu_usage > 85",
-			Duration:  5 * time.Minute,
-			Severity:  "critical",
-			Channels:  []string{"email", "slack"},
-		},
-		{
-			Name:      "memory_limit",
-			Condition: "memory_usage > 90",
-			Duration:  5 * time.Minute,
-			Severity:  "critical",
-			Channels:  []string{"email", "slack"},
-		},
-		{
-			Name:      "high_error_rate",
-			Condition: "error_rate > 10",
-			Duration:  2 * time.Minute,
-			Severity:  "warning",
-			Channels:  []string{"slack"},
-		},
-		{
-			Name:      "high_response_time",
-			Condition: "response_time > 1000",
-			Duration:  2 * time.Minute,
-			Severity:  "warning",
-			Channels:  []string{"slack"},
-		},
-		{
-			Name:      "health_check_failed",
-			Condition: "health_status == failed",
-			Duration:  1 * time.Minute,
-			Severity:  "critical",
-			Channels:  []string{"email", "slack"},
-		},
-	}
+// fingerprintKeys are the metadata keys that tell apart alerts of the same type on one
+// deployment; every other field may change while the alert stays the same
+var fingerprintKeys = []string{"rule_id", "slo_id", "burn_window", "host", "probe_id", "dedup_key", "issue_id"}
+
+// defaultRenotifySeconds is how often an alert that stays unresolved and unacknowledged
+// is notified again, unless its rule says otherwise
+var defaultRenotifySeconds = map[string]int{
+	"critical": 3600,
+	"high":     7200,
+	"warning":  14400,
+	"medium":   14400,
 }
 
-// ProcessAlerts evaluates alert conditions and triggers notifications
-func (am *AlertManager) ProcessAlerts(ctx context.Context) error {
-	// Get all active deployments
-	deployments, err := am.getActiveDeployments(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get active deployments: %w", err)
-	}
-
-	for _, deployment := range deployments {
-		// Get latest metrics for deployment
-		metrics, err := am.getLatestMetrics(ctx, deployment.ID)
-		if err != nil {
-			log.Printf("Failed to get metrics for deployment %s: %v", deployment.ID, err)
-			continue
-		}
-
-		// Evaluate alert rules
-		for _, rule := range am.alertRules {
-			if am.evaluateRule(rule, metrics, deployment) {
-				if err := am.createAlert(ctx, deployment.ID, rule, metrics); err != nil {
-					log.Printf("Failed to create alert for deployment %s: %v", deployment.ID, err)
-				}
-			}
+// alertFingerprint identifies an alert across evaluations so that a condition that keeps
+// holding updates the open alert instead of raising a new one
+func alertFingerprint(alert *models.Alert) string {
+	parts := []string{alert.DeploymentID, alert.MetricType}
+	for _, key := range fingerprintKeys {
+		if v, ok := alert.Metadata[key]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%v", key, v))
 		}
 	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:])
+}
 
-	// Process pending alerts for notifications
-	if err := am.processPendingAlerts(ctx); err != nil {
-		return fmt.Errorf("failed to process pending alerts: %w", err)
+func renotifyInterval(alert *models.Alert) int {
+	switch v := alert.Metadata["renotify_seconds"].(type) {
+	case int:
+		return v
+	case *int:
+		if v != nil {
+			return *v
+		}
 	}
-
-	return nil
+	return defaultRenotifySeconds[alert.Severity]
 }
 
-// TriggerAlert manually triggers an alert
+// TriggerAlert raises an alert. While an alert with the same fingerprint is unresolved
+// it is updated and counted instead, and no new notification is sent.
 func (am *AlertManager) TriggerAlert(ctx context.Context, alert *models.Alert) error {
 	// First, get the project_id for this deployment
 	var projectID string
@@ -121,138 +84,106 @@ func (am *AlertManager) TriggerAlert(ctx context.Context, alert *models.Alert) e
 		// Continue without project_id - it will be NULL in the database
 	}
 
-	alertData, err := json.Marshal(map[string]interface{}{
+	data := map[string]interface{}{
 		"threshold_value": alert.ThresholdValue,
 		"current_value":   alert.CurrentValue,
 		"metric_type":     alert.MetricType,
-	})
+	}
+	for k, v := range alert.Metadata {
+		data[k] = v
+	}
+	alertData, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("failed to marshal alert data: %w", err)
 	}
 
 	query := `
 		INSERT INTO deployment_alerts (
-			deployment_id, project_id, alert_type, severity, alert_message, alert_data
-		) VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, created_at
+			deployment_id, project_id, alert_type, severity, alert_message, alert_data,
+			fingerprint, renotify_interval_seconds
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (fingerprint) WHERE resolved = false DO UPDATE
+		SET occurrence_count = deployment_alerts.occurrence_count + 1,
+		    last_seen_at = NOW(),
+		    alert_message = EXCLUDED.alert_message,
+		    alert_data = EXCLUDED.alert_data
+		RETURNING id, created_at, (xmax = 0) AS inserted
 	`
 
+	var inserted bool
 	err = am.orchestrator.db.QueryRowContext(
 		ctx,
 		query,
 		alert.DeploymentID,
-		projectID,
+		nullIfEmpty(projectID),
 		alert.MetricType,
 		alert.Severity,
 		alert.Description,
 		alertData,
-	).Scan(&alert.ID, &alert.TriggeredAt)
+		alertFingerprint(alert),
+		renotifyInterval(alert),
+	).Scan(&alert.ID, &alert.TriggeredAt, &inserted)
 
 	if err != nil {
 		return fmt.Errorf("failed to create alert: %w", err)
 	}
 
 	alert.Status = "active"
-
-	// Send notification
-	go am.sendNotification(alert)
-
-	return nil
-}
-
-func (am *AlertManager) evaluateRule(rule *AlertRule, metrics map[string]interface{}, deployment *models.Deployment) bool {
-	// Simple rule evaluation logic
-	// In production, use a proper expression evaluator
-	switch rule.Name {
-	case "cpu_threshold":
-		if cpu, ok := metrics["cpu_usage"].(float64); ok && cpu > 85 {
-			return true
-		}
-	case "memory_limit":
-		if mem, ok := metrics["memory_usage"].(float64); ok && mem > 90 {
-			return true
-		}
-	case "high_error_rate":
-		if errorRate, ok := metrics["error_rate"].(float64); ok && errorRate > 10 {
-			return true
-		}
-	case "high_response_time":
-		if responseTime, ok := metrics["response_time"].(float64); ok && responseTime > 1000 {
-			return true
-		}
-	case "health_check_failed":
-		if healthStatus, ok := metrics["health_status"].(string); ok && healthStatus == "failed" {
-			return true
-		}
+	if !inserted {
+		return nil
 	}
-	return false
-}
 
-func (am *AlertManager) createAlert(ctx context.Context, deploymentID string, rule *AlertRule, metrics map[string]interface{}) error {
-	// Check if alert already exists and is active
-	exists, err := am.alertExists(ctx, deploymentID, rule.Name)
-	if err != nil || exists {
-		return err
+	// Silenced alerts are notified once their silence ends, if they are still open
+	suppressedBy, err := am.suppress(ctx, alert.ID)
+	if err != nil {
+		log.Printf("Failed to check silences for alert %s: %v", alert.ID, err)
 	}
-
-	alert := &models.Alert{
-		DeploymentID: deploymentID,
-		Severity:     rule.Severity,
-		Title:        fmt.Sprintf("Alert: %s", rule.Name),
-		Description:  fmt.Sprintf("Alert condition met: %s", rule.Condition),
-		MetricType:   rule.Name,
-		Status:       "active",
+	if suppressedBy != "" {
+		alert.Status = "suppressed"
+		return nil
 	}
 
-	// Add current and threshold values based on rule type
-	switch rule.Name {
-	case "cpu_threshold":
-		alert.CurrentValue = metrics["cpu_usage"].(float64)
-		alert.ThresholdValue = 85.0
-	case "memory_limit":
-		alert.CurrentValue = metrics["memory_usage"].(float64)
-		alert.ThresholdValue = 90.0
-	case "high_error_rate":
-		alert.CurrentValue = metrics["error_rate"].(float64)
-		alert.ThresholdValue = 10.0
-	case "high_response_time":
-		if responseTime, ok := metrics["response_time"].(float64); ok {
-			alert.CurrentValue = responseTime
-			alert.ThresholdValue = 1000.0
-		}
-	}
+	go am.notifyPending(context.Background(), alert.ID)
 
-	return am.TriggerAlert(ctx, alert)
+	return nil
 }
 
-func (am *AlertManager) alertExists(ctx context.Context, deploymentID, alertType string) (bool, error) {
-	query := `
-		SELECT COUNT(*) FROM deployment_alerts
-		WHERE deployment_id = $1 AND alert_type = $2 AND resolved = false
-		AND created_at > NOW() - INTERVAL '1 hour'
-	`
-
-	var count int
-	err := am.orchestrator.db.QueryRowContext(ctx, query, deploymentID, alertType).Scan(&count)
-	if err != nil {
-		return false, err
-	}
-
-	return count > 0, nil
+// processPendingAlerts notifies the alerts that were never notified or are due for a
+// repeat, e.g. once the silence that muted them has ended
+func (am *AlertManager) processPendingAlerts(ctx context.Context) error {
+	return am.notifyPending(ctx, "")
 }
 
-func (am *AlertManager) processPendingAlerts(ctx context.Context) error {
+// notifyPending claims the pending alerts, or only alertID when set, and sends their
+// notifications. Claiming records the notification first so that concurrent workers and
+// a later tick do not notify the same alert twice.
+func (am *AlertManager) notifyPending(ctx context.Context, alertID string) error {
 	query := `
-		SELECT id, deployment_id, alert_type, severity, alert_message, alert_data
-		FROM deployment_alerts
-		WHERE resolved = false 
-		AND NOT (notified_users ? 'sent' OR notified_users @> '["sent"]'::jsonb)
-		ORDER BY created_at DESC
-		LIMIT 100
+		UPDATE deployment_alerts
+		SET last_notified_at = NOW(), notification_count = notification_count + 1, suppressed_by = NULL
+		WHERE id IN (
+			SELECT a.id FROM deployment_alerts a
+			JOIN deployments ad ON ad.id = a.deployment_id
+			WHERE a.resolved = false
+			  AND COALESCE(a.acknowledged, false) = false
+			  AND ($1 = '' OR a.id::text = $1)
+			  AND (a.last_notified_at IS NULL
+			       OR (a.renotify_interval_seconds > 0
+			           AND a.last_notified_at <= NOW() - make_interval(secs => a.renotify_interval_seconds)))
+			  AND NOT EXISTS (` + activeSilenceCondition + `)
+			  AND NOT EXISTS (` + activeMaintenanceCondition + `)
+			ORDER BY a.created_at DESC
+			LIMIT 100
+			FOR UPDATE OF a SKIP LOCKED
+		)
+		RETURNING id, deployment_id, alert_type, severity, alert_message, alert_data, notification_count
 	`
 
-	rows, err := am.orchestrator.db.QueryContext(ctx, query)
+	rows, err := am.orchestrator.db.QueryContext(ctx, query, alertID)
 	if err != nil {
+		if alertID != "" {
+			log.Printf("Failed to notify alert %s: %v", alertID, err)
+		}
 		return err
 	}
 	defer rows.Close()
@@ -260,7 +191,8 @@ func (am *AlertManager) processPendingAlerts(ctx context.Context) error {
 	for rows.Next() {
 		var alert models.Alert
 		var alertData []byte
-		if err := rows.Scan(&alert.ID, &alert.DeploymentID, &alert.MetricType, &alert.Severity, &alert.Description, &alertData); err != nil {
+		var notifications int
+		if err := rows.Scan(&alert.ID, &alert.DeploymentID, &alert.MetricType, &alert.Severity, &alert.Description, &alertData, &notifications); err != nil {
 			log.Printf("Failed to scan alert: %v", err)
 			continue
 		}
@@ -274,12 +206,20 @@ func (am *AlertManager) processPendingAlerts(ctx context.Context) error {
 			if thresholdVal, ok := data["threshold_value"].(float64); ok {
 				alert.ThresholdValue = thresholdVal
 			}
+			if channels, ok := data["channels"]; ok {
+				alert.Metadata = map[string]interface{}{"channels": channels}
+			}
+		}
+		if notifications > 1 {
+			alert.Description = "[still firing] " + alert.Description
+		} else {
+			go am.startEscalation(alert.ID)
 		}
 
 		go am.sendNotification(&alert)
 	}
 
-	return nil
+	return rows.Err()
 }
 
 func (am *AlertManager) sendNotification(alert *models.Alert) {
@@ -296,9 +236,13 @@ func (am *AlertManager) sendNotification(alert *models.Alert) {
 			am.sendWebhookNotification(alert)
 		}
 	}
+}
 
-	// Mark notification as sent
-	am.markNotificationSent(alert.ID)
+// startEscalation pages the on-call of the team owning the alert's project
+func (am *AlertManager) startEscalation(alertID string) {
+	if err := am.orchestrator.onCallMgr.StartEscalation(context.Background(), alertID); err != nil {
+		log.Printf("Failed to start escalation for alert %s: %v", alertID, err)
+	}
 }
 
 func (am *AlertManager) sendEmailNotification(alert *models.Alert) {
@@ -316,14 +260,21 @@ func (am *AlertManager) sendWebhookNotification(alert *models.Alert) {
 	log.Printf("Sending webhook notification for alert: %s", alert.Description)
 }
 
-func (am *AlertManager) markNotificationSent(alertID string) {
-	query := `UPDATE deployment_alerts SET notified_users = notified_users || '["sent"]'::jsonb WHERE id = $1`
-	if _, err := am.orchestrator.db.Exec(query, alertID); err != nil {
-		log.Printf("Failed to mark notification as sent: %v", err)
+func (am *AlertManager) getNotificationChannels(alert *models.Alert) []string {
+	// Alert rules route to their own channels
+	switch channels := alert.Metadata["channels"].(type) {
+	case []string:
+		return channels
+	case []interface{}:
+		routed := make([]string, 0, len(channels))
+		for _, ch := range channels {
+			if name, ok := ch.(string); ok {
+				routed = append(routed, name)
+			}
+		}
+		return routed
 	}
-}
 
-func (am *AlertManager) getNotificationChannels(alert *models.Alert) []string {
 	// Return channels based on severity
 	if alert.Severity == "critical" {
 		return []string{"email", "slack"}
@@ -386,55 +337,3 @@ func (am *AlertManager) GetActiveAlerts(ctx context.Context, deploymentID string
 
 	return alerts, nil
 }
-
-func (am *AlertManager) getActiveDeployments(ctx context.Context) ([]*models.Deployment, error) {
-	query := `
-		SELECT 
-			d.id, 
-			d.status, 
-			dc.status as container_status
-		FROM deployments d
-		JOIN deployment_containers dc ON dc.deployment_id = d.id AND dc.is_active = true
-		WHERE d.status NOT IN ('deleted', 'terminated', 'rolled_back')
-	`
-	rows, err := am.orchestrator.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var deployments []*models.Deployment
-	for rows.Next() {
-		var d models.Deployment
-		if err := rows.Scan(&d.ID, &d.Status, &d.ContainerStatus); err != nil {
-			return nil, err
-		}
-		deployments = append(deployments, &d)
-	}
-
-	return deployments, nil
-}
-
-func (am *AlertManager) getLatestMetrics(ctx context.Context, deploymentID string) (map[string]interface{}, error) {
-	query := `
-		SELECT cpu_usage, memory_usage, status
-		FROM deployments_metrics
-		WHERE deployment_id = $1
-		ORDER BY timestamp DESC
-		LIMIT 1
-	`
-
-	var cpu, mem float64
-	var status string
-
-	err := am.orchestrator.db.QueryRowContext(ctx, query, deploymentID).Scan(&cpu, &mem, &status)
-	if err != nil {
-		return nil, err
-	}
-
-	return map[string]interface{}{
-		"cpu_usage":    cpu,
-		"memory_usage": mem,
-		"status":       status,
-	}, nil
-}
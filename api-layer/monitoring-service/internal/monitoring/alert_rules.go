@@ -0,0 +1,802 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"monitoring-service/pkg/logger"
+	"monitoring-service/pkg/models"
+
+	"github.com/lib/pq"
+)
+
+const (
+	AlertRuleStatePending = "pending"
+	AlertRuleStateFiring  = "firing"
+
+	maxAlertRuleFor = 24 * time.Hour
+)
+
+var (
+	ErrAlertRuleNotFound  = errors.New("alert rule not found")
+	ErrAlertRuleNameTaken = errors.New("an alert rule with this name already exists")
+
+	alertChannels = map[string]bool{"email": true, "slack": true, "webhook": true}
+)
+
+// Deployment statuses whose metrics are evaluated
+const alertableDeploymentFilter = `d.status NOT IN ('deleted', 'terminated', 'rolled_back')
+	AND EXISTS (SELECT 1 FROM deployment_containers dc WHERE dc.deployment_id = d.id AND dc.is_active = true)`
+
+// defaultAlertRules are created for every project the first time it has an active
+// deployment; projects can edit or delete them like their own rules
+var defaultAlertRules = []AlertRule{
+	{Name: "cpu_threshold", Expression: "cpu_usage > 85", ForSeconds: 300, Severity: "critical", Channels: []string{"email", "slack"},
+		Description: "CPU usage above 85% for 5 minutes"},
+	{Name: "memory_limit", Expression: "memory_percent > 90", ForSeconds: 300, Severity: "critical", Channels: []string{"email", "slack"},
+		Description: "Memory above 90% of the container limit for 5 minutes"},
+	{Name: "high_error_rate", Expression: "error_rate > 10", ForSeconds: 120, Severity: "warning", Channels: []string{"slack"},
+		Description: "More than 10% of requests failing with 5xx for 2 minutes"},
+	{Name: "high_response_time", Expression: "response_time > 1000", ForSeconds: 120, Severity: "warning", Channels: []string{"slack"},
+		Description: "Average response time above 1s for 2 minutes"},
+	{Name: "health_check_failed", Expression: `health_status == "unhealthy"`, ForSeconds: 60, Severity: "critical", Channels: []string{"email", "slack"},
+		Description: "Health checks failing for 1 minute"},
+	{Name: "error_log_spike", Expression: `log_count("level:error OR level:fatal", "1h") > 10`, ForSeconds: 0, Severity: "warning", Channels: []string{"slack"},
+		Description: "More than 10 error lines logged in the last hour"},
+}
+
+// alertMetric is a built-in metric of the expression language. Container metrics are read
+// from deployments_metrics, HTTP metrics from http_metrics_minute: windowExpr aggregates
+// the window (plain use and avg), minuteExpr is the per-minute value min/max pick from.
+type alertMetric struct {
+	typ        exprType
+	source     string // container, http, health
+	expr       string
+	windowExpr string
+	minuteExpr string
+}
+
+var alertMetrics = map[string]alertMetric{
+	"cpu_usage":      {typ: exprNumber, source: "container", expr: "m.cpu_usage"},
+	"memory_mb":      {typ: exprNumber, source: "container", expr: "m.memory_usage / 1048576.0"},
+	"memory_percent": {typ: exprNumber, source: "container", expr: "m.memory_usage / 1048576.0 * 100 / NULLIF(lim.limit_mb, 0)"},
+	"request_rate": {typ: exprNumber, source: "http",
+		windowExpr: "COALESCE(SUM(h.request_count), 0)::float / $2", minuteExpr: "h.request_count / 60.0"},
+	"error_rate": {typ: exprNumber, source: "http",
+		windowExpr: "SUM(h.request_count_5xx) * 100.0 / NULLIF(SUM(h.request_count), 0)",
+		minuteExpr: "h.request_count_5xx * 100.0 / NULLIF(h.request_count, 0)"},
+	"response_time": {typ: exprNumber, source: "http",
+		windowExpr: "SUM(h.latency_sum)::float / NULLIF(SUM(h.request_count), 0)", minuteExpr: "h.latency_avg"},
+	"p95_latency":   {typ: exprNumber, source: "http", windowExpr: "AVG(h.latency_p95)", minuteExpr: "h.latency_p95"},
+	"health_status": {typ: exprString, source: "health"},
+}
+
+// AlertRule is a project's alert condition. It fires once its expression has held for
+// ForSeconds on a deployment and notifies the rule's channels.
+type AlertRule struct {
+	ID              string    `json:"id"`
+	ProjectID       string    `json:"project_id"`
+	Environment     string    `json:"environment,omitempty"` // Empty evaluates every environment
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	Expression      string    `json:"expression"`
+	ForSeconds      int       `json:"for_seconds"`
+	RenotifySeconds *int      `json:"renotify_seconds"` // Repeat interval while unacknowledged; nil uses the severity default, 0 never repeats
+	Severity        string    `json:"severity"`
+	Channels        []string  `json:"channels"`
+	Enabled         bool      `json:"enabled"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+
+	States []AlertRuleState `json:"states,omitempty"`
+}
+
+// AlertRuleState is a rule that is pending or firing on a deployment
+type AlertRuleState struct {
+	DeploymentID string     `json:"deployment_id"`
+	State        string     `json:"state"`
+	PendingSince time.Time  `json:"pending_since"`
+	FiringSince  *time.Time `json:"firing_since"`
+	LastValue    *float64   `json:"last_value"`
+	AlertID      string     `json:"alert_id,omitempty"`
+}
+
+// AlertRuleEvaluation is the result of an expression on one deployment, for trying
+// out rules before saving them
+type AlertRuleEvaluation struct {
+	DeploymentID string              `json:"deployment_id"`
+	Environment  string              `json:"environment"`
+	Result       bool                `json:"result"`
+	Values       map[string]*float64 `json:"values"`
+	Labels       map[string]string   `json:"labels,omitempty"` // String metrics, e.g. health_status
+}
+
+// Validate normalizes the rule, applies defaults and checks its expression
+func (r *AlertRule) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || len(r.Name) > 50 {
+		return fmt.Errorf("name is required and must be at most 50 characters")
+	}
+	if len(r.Description) > 500 {
+		return fmt.Errorf("description must be at most 500 characters")
+	}
+
+	if r.Environment != "" && !probeEnvironments[r.Environment] {
+		return fmt.Errorf("environment must be production, staging or preview")
+	}
+
+	if _, err := ParseAlertExpr(r.Expression); err != nil {
+		return fmt.Errorf("invalid expression: %w", err)
+	}
+
+	if r.ForSeconds < 0 || time.Duration(r.ForSeconds)*time.Second > maxAlertRuleFor {
+		return fmt.Errorf("for_seconds must be between 0 and %d", int(maxAlertRuleFor.Seconds()))
+	}
+
+	if r.RenotifySeconds != nil && *r.RenotifySeconds != 0 && *r.RenotifySeconds < 300 {
+		return fmt.Errorf("renotify_seconds must be 0 or at least 300")
+	}
+
+	if r.Severity == "" {
+		r.Severity = "warning"
+	}
+	if _, ok := incidentSeverityRank[r.Severity]; !ok {
+		return fmt.Errorf("severity must be info, low, warning, medium, high or critical")
+	}
+
+	seen := map[string]bool{}
+	channels := make([]string, 0, len(r.Channels))
+	for _, ch := range r.Channels {
+		if !alertChannels[ch] {
+			return fmt.Errorf("unknown channel %q, use email, slack or webhook", ch)
+		}
+		if !seen[ch] {
+			seen[ch] = true
+			channels = append(channels, ch)
+		}
+	}
+	r.Channels = channels
+
+	return nil
+}
+
+const alertRuleColumns = `r.id, r.project_id, COALESCE(r.environment, ''), r.name, COALESCE(r.description, ''),
+	r.expression, r.for_seconds, r.renotify_seconds, r.severity, r.channels, r.enabled, r.created_at, r.updated_at`
+
+func scanAlertRule(scan func(dest ...interface{}) error) (*AlertRule, error) {
+	var r AlertRule
+	var channels []byte
+	var renotify sql.NullInt64
+	err := scan(&r.ID, &r.ProjectID, &r.Environment, &r.Name, &r.Description, &r.Expression,
+		&r.ForSeconds, &renotify, &r.Severity, &channels, &r.Enabled, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if renotify.Valid {
+		seconds := int(renotify.Int64)
+		r.RenotifySeconds = &seconds
+	}
+	r.Channels = []string{}
+	json.Unmarshal(channels, &r.Channels)
+	return &r, nil
+}
+
+// evaluateRules evaluates every enabled rule on the deployments of its project and moves
+// each (rule, deployment) through pending and firing
+func (am *AlertManager) evaluateRules(ctx context.Context) error {
+	db := am.orchestrator.db
+
+	if err := am.seedDefaultRules(ctx); err != nil {
+		logger.Error("Failed to create default alert rules", logger.Err(err))
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+alertRuleColumns+`, d.id
+		FROM alert_rules r
+		JOIN deployments d ON d.project_id = r.project_id AND (r.environment IS NULL OR d.environment = r.environment)
+		WHERE r.enabled = true AND `+alertableDeploymentFilter+`
+		ORDER BY d.id`)
+	if err != nil {
+		return fmt.Errorf("failed to load alert rules: %w", err)
+	}
+
+	type target struct {
+		rule         *AlertRule
+		deploymentID string
+	}
+	var targets []target
+	for rows.Next() {
+		var deploymentID string
+		rule, err := scanAlertRule(func(dest ...interface{}) error {
+			return rows.Scan(append(dest, &deploymentID)...)
+		})
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		targets = append(targets, target{rule, deploymentID})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	exprs := map[string]*AlertExpr{}
+	values := map[string]map[string]exprValue{}
+	for _, t := range targets {
+		expr, ok := exprs[t.rule.ID]
+		if !ok {
+			if expr, err = ParseAlertExpr(t.rule.Expression); err != nil {
+				// Rules are validated when saved; only a language change can break one
+				logger.Warn("Skipping invalid alert rule", logger.String("rule_id", t.rule.ID), logger.Err(err))
+			}
+			exprs[t.rule.ID] = expr
+		}
+		if expr == nil {
+			continue
+		}
+
+		if values[t.deploymentID] == nil {
+			values[t.deploymentID] = map[string]exprValue{}
+		}
+		if err := am.resolveMetrics(ctx, t.deploymentID, expr.refs, values[t.deploymentID]); err != nil {
+			logger.Error("Failed to read metrics for alert rule", logger.String("rule_id", t.rule.ID),
+				logger.String("deployment_id", t.deploymentID), logger.Err(err))
+			continue
+		}
+
+		if err := am.syncRuleState(ctx, t.rule, expr, t.deploymentID, values[t.deploymentID]); err != nil {
+			logger.Error("Failed to update alert rule state", logger.String("rule_id", t.rule.ID),
+				logger.String("deployment_id", t.deploymentID), logger.Err(err))
+		}
+	}
+
+	// Rules that were disabled or whose deployment went away stop firing
+	_, err = db.ExecContext(ctx, `
+		WITH gone AS (
+			DELETE FROM alert_rule_states st
+			WHERE NOT EXISTS (
+				SELECT 1 FROM alert_rules r
+				JOIN deployments d ON d.project_id = r.project_id AND (r.environment IS NULL OR d.environment = r.environment)
+				WHERE r.id = st.rule_id AND d.id = st.deployment_id AND r.enabled = true AND `+alertableDeploymentFilter+`
+			)
+			RETURNING alert_id
+		)
+		UPDATE deployment_alerts SET resolved = true, resolved_at = NOW()
+		WHERE resolved = false AND id IN (SELECT alert_id FROM gone)`)
+	return err
+}
+
+// syncRuleState records the outcome of one evaluation. A true condition starts pending
+// (or fires right away without a for duration) and fires once it held long enough; a
+// false condition clears the pending state or resolves the alert.
+func (am *AlertManager) syncRuleState(ctx context.Context, rule *AlertRule, expr *AlertExpr, deploymentID string, values map[string]exprValue) error {
+	db := am.orchestrator.db
+
+	if !expr.Eval(values) {
+		_, err := db.ExecContext(ctx, `
+			WITH cleared AS (
+				DELETE FROM alert_rule_states WHERE rule_id = $1 AND deployment_id = $2
+				RETURNING alert_id
+			)
+			UPDATE deployment_alerts SET resolved = true, resolved_at = NOW()
+			WHERE resolved = false AND id IN (SELECT alert_id FROM cleared)`, rule.ID, deploymentID)
+		return err
+	}
+
+	current, hasCurrent := firstNumericValue(expr, values)
+	var lastValue interface{}
+	if hasCurrent {
+		lastValue = current
+	}
+
+	var prev *ruleState
+	var existing ruleState
+	var firingSince sql.NullTime
+	err := db.QueryRowContext(ctx, `
+		SELECT state, pending_since, firing_since FROM alert_rule_states
+		WHERE rule_id = $1 AND deployment_id = $2`, rule.ID, deploymentID).Scan(&existing.State, &existing.PendingSince, &firingSince)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	default:
+		existing.FiringSince = nullTimePtr(firingSince)
+		prev = &existing
+	}
+	next := advanceRuleState(prev, time.Now().UTC(), time.Duration(rule.ForSeconds)*time.Second)
+
+	// A concurrent evaluation may have fired the rule in between; never move it back
+	var state string
+	var alertID sql.NullString
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO alert_rule_states (rule_id, deployment_id, state, pending_since, firing_since, last_value, last_evaluated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (rule_id, deployment_id) DO UPDATE SET
+			state = CASE WHEN alert_rule_states.state = 'firing' THEN 'firing' ELSE EXCLUDED.state END,
+			firing_since = COALESCE(alert_rule_states.firing_since, EXCLUDED.firing_since),
+			last_value = EXCLUDED.last_value,
+			last_evaluated_at = NOW()
+		RETURNING state, alert_id`, rule.ID, deploymentID, next.State, next.PendingSince, next.FiringSince, lastValue).Scan(&state, &alertID)
+	if err != nil {
+		return err
+	}
+
+	// Firing without an alert: it just fired, or creating the alert failed last cycle
+	if state != AlertRuleStateFiring || alertID.Valid {
+		return nil
+	}
+
+	description := rule.Description
+	if description == "" {
+		description = rule.Expression
+	}
+	observed := make(map[string]interface{}, len(expr.refs))
+	var parts []string
+	for _, ref := range expr.refs {
+		if v, ok := values[ref.key]; ok && !v.absent {
+			if alertMetrics[ref.metric].typ == exprString && ref.fn == "" {
+				observed[ref.String()] = v.str
+				parts = append(parts, fmt.Sprintf("%s = %s", ref, v.str))
+			} else {
+				observed[ref.String()] = v.num
+				parts = append(parts, fmt.Sprintf("%s = %.4g", ref, v.num))
+			}
+		}
+	}
+	if len(parts) > 0 {
+		description += " (" + strings.Join(parts, ", ") + ")"
+	}
+
+	alert := &models.Alert{
+		DeploymentID: deploymentID,
+		Severity:     rule.Severity,
+		Title:        rule.Name,
+		Description:  description,
+		MetricType:   rule.Name,
+		CurrentValue: current,
+		Metadata: map[string]interface{}{
+			"rule_id":    rule.ID,
+			"expression": rule.Expression,
+			"channels":   rule.Channels,
+			"values":     observed,
+		},
+	}
+	if rule.RenotifySeconds != nil {
+		alert.Metadata["renotify_seconds"] = *rule.RenotifySeconds
+	}
+	if threshold, ok := expr.Threshold(); ok {
+		alert.ThresholdValue = threshold
+	}
+	if err := am.TriggerAlert(ctx, alert); err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE alert_rule_states SET alert_id = $3
+		WHERE rule_id = $1 AND deployment_id = $2`, rule.ID, deploymentID, alert.ID)
+	return err
+}
+
+// ruleState is where a rule stands on a deployment while its condition holds
+type ruleState struct {
+	State        string
+	PendingSince time.Time
+	FiringSince  *time.Time
+}
+
+// advanceRuleState applies one true evaluation at now. prev is nil when the condition
+// was false last cycle: the rule starts pending, or fires right away without a for
+// duration, and fires once it has been pending for the whole duration.
+func advanceRuleState(prev *ruleState, now time.Time, forDuration time.Duration) ruleState {
+	next := ruleState{State: AlertRuleStatePending, PendingSince: now}
+	if prev != nil {
+		next = *prev
+	}
+	if next.FiringSince == nil && !now.Before(next.PendingSince.Add(forDuration)) {
+		firing := now
+		next.State, next.FiringSince = AlertRuleStateFiring, &firing
+	}
+	return next
+}
+
+// firstNumericValue is the value of the expression's first numeric metric, recorded as
+// the rule's current value
+func firstNumericValue(expr *AlertExpr, values map[string]exprValue) (float64, bool) {
+	for _, ref := range expr.refs {
+		if alertMetrics[ref.metric].typ == exprString && ref.fn == "" {
+			continue
+		}
+		if v, ok := values[ref.key]; ok && !v.absent {
+			return v.num, true
+		}
+	}
+	return 0, false
+}
+
+// resolveMetrics reads the metrics not already in values
+func (am *AlertManager) resolveMetrics(ctx context.Context, deploymentID string, refs []*alertMetricRef, values map[string]exprValue) error {
+	for _, ref := range refs {
+		if _, ok := values[ref.key]; ok {
+			continue
+		}
+		v, err := am.resolveMetric(ctx, deploymentID, ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ref, err)
+		}
+		values[ref.key] = v
+	}
+	return nil
+}
+
+func (am *AlertManager) resolveMetric(ctx context.Context, deploymentID string, ref *alertMetricRef) (exprValue, error) {
+	db := am.orchestrator.db
+
+	var value *float64
+	var err error
+	switch ref.fn {
+	case "app":
+		value, err = am.orchestrator.appScraper.LatestValue(ctx, deploymentID, ref.metric, ref.match)
+		return numericValue(value), err
+	case "app_rate":
+		value, err = am.orchestrator.appScraper.Rate(ctx, deploymentID, ref.metric, ref.match, ref.window)
+		return numericValue(value), err
+	case "log_count":
+		value, err = am.orchestrator.logAggregator.CountLogs(ctx, deploymentID, ref.logQuery, ref.window)
+		return numericValue(value), err
+	case "issue_count", "issue_rate":
+		value, err = am.orchestrator.errorTracker.CountEvents(ctx, deploymentID, ref.metric, ref.window)
+		if value != nil && ref.fn == "issue_rate" {
+			rate := *value / ref.window.Seconds()
+			value = &rate
+		}
+		return numericValue(value), err
+	}
+
+	metric := alertMetrics[ref.metric]
+	var result sql.NullFloat64
+	switch metric.source {
+	case "health":
+		var status sql.NullString
+		err := db.QueryRowContext(ctx, `
+			SELECT CASE
+				WHEN bool_or(health_status = 'unhealthy') THEN 'unhealthy'
+				WHEN bool_or(health_status = 'starting') THEN 'starting'
+				WHEN bool_and(health_status = 'healthy') THEN 'healthy'
+				ELSE 'unknown'
+			END
+			FROM deployment_containers
+			WHERE deployment_id = $1 AND is_active = true`, deploymentID).Scan(&status)
+		if err != nil || !status.Valid {
+			return exprValue{absent: true}, err
+		}
+		return exprValue{str: status.String}, nil
+
+	case "container":
+		from := `
+			FROM deployments_metrics m
+			CROSS JOIN (
+				SELECT SUM(memory_limit_mb) AS limit_mb FROM deployment_containers
+				WHERE deployment_id = $1 AND is_active = true
+			) lim
+			WHERE m.deployment_id = $1 AND m.timestamp > NOW() - make_interval(secs => $2)`
+		query := `SELECT ` + metric.expr + from + ` ORDER BY m.timestamp DESC LIMIT 1`
+		if ref.fn != "" {
+			query = `SELECT ` + strings.ToUpper(ref.fn) + `(` + metric.expr + `)` + from
+		}
+		err = db.QueryRowContext(ctx, query, deploymentID, ref.window.Seconds()).Scan(&result)
+
+	case "http":
+		from := `
+			FROM http_metrics_minute h
+			WHERE h.deployment_id = $1 AND h.timestamp_minute > NOW() - make_interval(secs => $2)`
+		query := `SELECT ` + metric.windowExpr + from
+		if ref.fn == "min" || ref.fn == "max" {
+			query = `SELECT ` + strings.ToUpper(ref.fn) + `(` + metric.minuteExpr + `)` + from
+		}
+		err = db.QueryRowContext(ctx, query, deploymentID, ref.window.Seconds()).Scan(&result)
+	}
+
+	if err == sql.ErrNoRows || err == nil && !result.Valid {
+		return exprValue{absent: true}, nil
+	}
+	if err != nil {
+		return exprValue{}, err
+	}
+	return exprValue{num: result.Float64}, nil
+}
+
+func numericValue(v *float64) exprValue {
+	if v == nil {
+		return exprValue{absent: true}
+	}
+	return exprValue{num: *v}
+}
+
+// seedDefaultRules gives projects their default rules once, when they first have an
+// active deployment
+func (am *AlertManager) seedDefaultRules(ctx context.Context) error {
+	var names, descriptions, expressions, severities, channels []string
+	var fors []int64
+	for _, r := range defaultAlertRules {
+		ch, _ := json.Marshal(r.Channels)
+		names = append(names, r.Name)
+		descriptions = append(descriptions, r.Description)
+		expressions = append(expressions, r.Expression)
+		fors = append(fors, int64(r.ForSeconds))
+		severities = append(severities, r.Severity)
+		channels = append(channels, string(ch))
+	}
+
+	_, err := am.orchestrator.db.ExecContext(ctx, `
+		WITH seeded AS (
+			INSERT INTO alert_rule_seeds (project_id)
+			SELECT DISTINCT d.project_id FROM deployments d
+			WHERE `+alertableDeploymentFilter+`
+			  AND NOT EXISTS (SELECT 1 FROM alert_rule_seeds s WHERE s.project_id = d.project_id)
+			ON CONFLICT (project_id) DO NOTHING
+			RETURNING project_id
+		)
+		INSERT INTO alert_rules (project_id, name, description, expression, for_seconds, severity, channels)
+		SELECT s.project_id, r.name, r.description, r.expression, r.for_seconds, r.severity, r.channels::jsonb
+		FROM seeded s
+		CROSS JOIN unnest($1::text[], $2::text[], $3::text[], $4::int[], $5::text[], $6::text[])
+			AS r(name, description, expression, for_seconds, severity, channels)
+		ON CONFLICT (project_id, name) DO NOTHING`,
+		pq.Array(names), pq.Array(descriptions), pq.Array(expressions), pq.Array(fors), pq.Array(severities), pq.Array(channels))
+	return err
+}
+
+// ListRules returns a project's alert rules with where they are pending or firing
+func (am *AlertManager) ListRules(ctx context.Context, projectID string) ([]*AlertRule, error) {
+	rows, err := am.orchestrator.db.QueryContext(ctx, `
+		SELECT `+alertRuleColumns+` FROM alert_rules r WHERE r.project_id = $1 ORDER BY r.name`, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []*AlertRule{}
+	byID := map[string]*AlertRule{}
+	for rows.Next() {
+		r, err := scanAlertRule(rows.Scan)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rules = append(rules, r)
+		byID[r.ID] = r
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	states, err := am.orchestrator.db.QueryContext(ctx, `
+		SELECT st.rule_id, st.deployment_id, st.state, st.pending_since, st.firing_since, st.last_value,
+		       COALESCE(st.alert_id::text, '')
+		FROM alert_rule_states st
+		JOIN alert_rules r ON r.id = st.rule_id
+		WHERE r.project_id = $1
+		ORDER BY st.pending_since`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer states.Close()
+
+	for states.Next() {
+		var ruleID string
+		s, err := scanAlertRuleState(states.Scan, &ruleID)
+		if err != nil {
+			return nil, err
+		}
+		if r := byID[ruleID]; r != nil {
+			r.States = append(r.States, *s)
+		}
+	}
+	return rules, states.Err()
+}
+
+func scanAlertRuleState(scan func(dest ...interface{}) error, ruleID *string) (*AlertRuleState, error) {
+	var s AlertRuleState
+	var firingSince sql.NullTime
+	var lastValue sql.NullFloat64
+	if err := scan(ruleID, &s.DeploymentID, &s.State, &s.PendingSince, &firingSince, &lastValue, &s.AlertID); err != nil {
+		return nil, err
+	}
+	if firingSince.Valid {
+		s.FiringSince = &firingSince.Time
+	}
+	if lastValue.Valid {
+		s.LastValue = &lastValue.Float64
+	}
+	return &s, nil
+}
+
+// GetRule returns one of a project's alert rules
+func (am *AlertManager) GetRule(ctx context.Context, projectID, ruleID string) (*AlertRule, error) {
+	r, err := scanAlertRule(am.orchestrator.db.QueryRowContext(ctx, `
+		SELECT `+alertRuleColumns+` FROM alert_rules r WHERE r.id = $1 AND r.project_id = $2`, ruleID, projectID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrAlertRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := am.orchestrator.db.QueryContext(ctx, `
+		SELECT rule_id, deployment_id, state, pending_since, firing_since, last_value, COALESCE(alert_id::text, '')
+		FROM alert_rule_states WHERE rule_id = $1
+		ORDER BY pending_since`, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		s, err := scanAlertRuleState(rows.Scan, &id)
+		if err != nil {
+			return nil, err
+		}
+		r.States = append(r.States, *s)
+	}
+	return r, rows.Err()
+}
+
+// CreateRule stores a validated alert rule for a project
+func (am *AlertManager) CreateRule(ctx context.Context, projectID string, r *AlertRule) error {
+	channels, _ := json.Marshal(r.Channels)
+	r.ProjectID = projectID
+	err := am.orchestrator.db.QueryRowContext(ctx, `
+		INSERT INTO alert_rules (project_id, environment, name, description, expression, for_seconds, renotify_seconds,
+			severity, channels, enabled)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at`,
+		projectID, nullIfEmpty(r.Environment), r.Name, r.Description, r.Expression, r.ForSeconds, r.RenotifySeconds,
+		r.Severity, channels, r.Enabled,
+	).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+	return alertRuleWriteError(err)
+}
+
+// UpdateRule replaces a rule's definition. Its pending and firing states are dropped
+// and their alerts resolved, so the new condition starts over on the next cycle.
+func (am *AlertManager) UpdateRule(ctx context.Context, projectID, ruleID string, r *AlertRule) error {
+	tx, err := am.orchestrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	channels, _ := json.Marshal(r.Channels)
+	r.ID = ruleID
+	r.ProjectID = projectID
+	err = tx.QueryRowContext(ctx, `
+		UPDATE alert_rules
+		SET environment = $3, name = $4, description = NULLIF($5, ''), expression = $6, for_seconds = $7,
+			renotify_seconds = $8, severity = $9, channels = $10, enabled = $11, updated_at = NOW()
+		WHERE id = $1 AND project_id = $2
+		RETURNING created_at, updated_at`,
+		ruleID, projectID, nullIfEmpty(r.Environment), r.Name, r.Description, r.Expression, r.ForSeconds,
+		r.RenotifySeconds, r.Severity, channels, r.Enabled,
+	).Scan(&r.CreatedAt, &r.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrAlertRuleNotFound
+	}
+	if err != nil {
+		return alertRuleWriteError(err)
+	}
+
+	if err := resetRuleStates(ctx, tx, ruleID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteRule removes a rule and resolves its firing alerts
+func (am *AlertManager) DeleteRule(ctx context.Context, projectID, ruleID string) error {
+	tx, err := am.orchestrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id string
+	err = tx.QueryRowContext(ctx, `
+		SELECT id FROM alert_rules WHERE id = $1 AND project_id = $2 FOR UPDATE`, ruleID, projectID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrAlertRuleNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := resetRuleStates(ctx, tx, ruleID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM alert_rules WHERE id = $1`, ruleID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func resetRuleStates(ctx context.Context, tx *sql.Tx, ruleID string) error {
+	_, err := tx.ExecContext(ctx, `
+		WITH cleared AS (
+			DELETE FROM alert_rule_states WHERE rule_id = $1 RETURNING alert_id
+		)
+		UPDATE deployment_alerts SET resolved = true, resolved_at = NOW()
+		WHERE resolved = false AND id IN (SELECT alert_id FROM cleared)`, ruleID)
+	return err
+}
+
+// EvaluateExpression evaluates an expression on the project's deployments now, without
+// changing any rule state
+func (am *AlertManager) EvaluateExpression(ctx context.Context, projectID, environment, expression string) ([]AlertRuleEvaluation, error) {
+	expr, err := ParseAlertExpr(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := am.orchestrator.db.QueryContext(ctx, `
+		SELECT d.id, d.environment FROM deployments d
+		WHERE d.project_id = $1 AND ($2 = '' OR d.environment = $2) AND `+alertableDeploymentFilter+`
+		ORDER BY d.environment, d.created_at DESC`, projectID, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []AlertRuleEvaluation{}
+	for rows.Next() {
+		var e AlertRuleEvaluation
+		if err := rows.Scan(&e.DeploymentID, &e.Environment); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		results = append(results, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		values := map[string]exprValue{}
+		if err := am.resolveMetrics(ctx, results[i].DeploymentID, expr.refs, values); err != nil {
+			return nil, err
+		}
+		results[i].Result = expr.Eval(values)
+		results[i].Values = map[string]*float64{}
+		for _, ref := range expr.refs {
+			v := values[ref.key]
+			if alertMetrics[ref.metric].typ == exprString && ref.fn == "" {
+				if !v.absent {
+					if results[i].Labels == nil {
+						results[i].Labels = map[string]string{}
+					}
+					results[i].Labels[ref.String()] = v.str
+				}
+				continue
+			}
+			if v.absent {
+				results[i].Values[ref.String()] = nil
+			} else {
+				num := v.num
+				results[i].Values[ref.String()] = &num
+			}
+		}
+	}
+	return results, nil
+}
+
+func alertRuleWriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrAlertRuleNameTaken
+	}
+	return err
+}
@@ -0,0 +1,664 @@
+package monitoring
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"monitoring-service/pkg/logger"
+	"monitoring-service/pkg/models"
+)
+
+// Error lines are grouped into issues by a fingerprint of their normalized message and
+// stack trace, so the same error logged 500 times is one issue with a count. An issue
+// resolved and then seen on a deployment created after the resolution has regressed.
+
+const (
+	IssueStatusUnresolved = "unresolved"
+	IssueStatusResolved   = "resolved"
+	IssueStatusIgnored    = "ignored"
+
+	// How long an error line waits for the stack trace lines that follow it
+	stackTraceWait = 2 * time.Second
+	maxStackLines  = 50
+	// Frames that make up the fingerprint; deeper frames are mostly framework code
+	maxFingerprintFrames = 10
+	maxIssueSampleLength = 16 * 1024
+
+	// Per-minute counts back issue_count and issue_rate in alert rules
+	issueCountRetention = 25 * time.Hour
+
+	tracebackHeader = "Traceback (most recent call last):"
+)
+
+var ErrIssueNotFound = errors.New("issue not found")
+
+var issueStatuses = map[string]bool{IssueStatusUnresolved: true, IssueStatusResolved: true, IssueStatusIgnored: true}
+
+// Replacements applied, in order, to turn a message into its issue title
+var issueNormalizers = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://\S+`), "<url>"},
+	{regexp.MustCompile(`[\w.+-]+@[\w-]+\.[\w.-]+`), "<email>"},
+	{regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`), "<uuid>"},
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`), "<time>"},
+	{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<ip>"},
+	{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b|\b[0-9a-fA-F]{8,}\b`), "<hex>"},
+	{regexp.MustCompile(`(?:[A-Za-z]:)?(?:[/\\][\w.@~-]+){2,}[/\\]?`), "<path>"},
+	{regexp.MustCompile(`"[^"]*"|'[^']*'|` + "`[^`]*`"), "<str>"},
+	{regexp.MustCompile(`\d+(\.\d+)*`), "<n>"},
+	{regexp.MustCompile(`\s+`), " "},
+}
+
+var (
+	// Line and column numbers, and Go's frame offsets, change with every build
+	frameLocation = regexp.MustCompile(`:\d+(:\d+)?\)?$|:\d+ \+0x[0-9a-f]+$|, line \d+`)
+	// The part of a message before ": " when it names the error, e.g. TypeError
+	errorTypePrefix = regexp.MustCompile(`^([\w.$]+(?:Error|Exception|Panic|Fault)|panic|fatal error): `)
+)
+
+// normalizeIssueText strips the parts of a message that vary between occurrences of the
+// same error: IDs, numbers, paths, quoted values
+func normalizeIssueText(s string) string {
+	for _, n := range issueNormalizers {
+		s = n.re.ReplaceAllString(s, n.repl)
+	}
+	return strings.TrimSpace(s)
+}
+
+// normalizeFrame keeps a stack frame's function and file but not its line numbers
+func normalizeFrame(frame string) string {
+	frame = strings.TrimSpace(frame)
+	frame = frameLocation.ReplaceAllString(frame, "")
+	// Paths stay, they tell frames apart; only the variable parts go
+	for _, n := range issueNormalizers {
+		if n.repl == "<path>" || n.repl == "<str>" {
+			continue
+		}
+		frame = n.re.ReplaceAllString(frame, n.repl)
+	}
+	return strings.TrimSpace(frame)
+}
+
+// isStackFrame reports whether a line looks like a stack frame rather than a message
+func isStackFrame(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "at ") || strings.HasPrefix(trimmed, "File \"") ||
+		strings.HasSuffix(trimmed, ")") || strings.Contains(trimmed, ".go:")
+}
+
+// isStackContinuation reports whether a line continues the error before it: indented
+// frames and the headers between chained exceptions and goroutines
+func isStackContinuation(line string) bool {
+	if line == "" {
+		return false
+	}
+	if line[0] == ' ' || line[0] == '\t' {
+		return true
+	}
+	for _, prefix := range []string{"Caused by:", "goroutine ", "During handling of", "The above exception", tracebackHeader} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IssueFingerprint groups an error by its type and stack frames, or by its normalized
+// message when it has no stack trace. It returns the fingerprint, the issue title and
+// the culprit, the topmost frame.
+func IssueFingerprint(message string, stack []string) (fingerprint, title, culprit string) {
+	firstLine, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	// Cut on a rune boundary: Postgres rejects a split UTF-8 character
+	title = truncateString(normalizeIssueText(firstLine), 255)
+
+	var frames []string
+	for _, line := range stack {
+		if !isStackFrame(line) {
+			continue
+		}
+		frame := normalizeFrame(line)
+		if frame == "" {
+			continue
+		}
+		if culprit == "" {
+			culprit = strings.TrimPrefix(frame, "at ")
+		}
+		if len(frames) < maxFingerprintFrames {
+			frames = append(frames, frame)
+		}
+	}
+
+	key := title
+	if len(frames) > 0 {
+		// The same crash site with a different message is the same issue
+		key = "stack"
+		if m := errorTypePrefix.FindStringSubmatch(firstLine); m != nil {
+			key = m[1]
+		}
+		key += "\n" + strings.Join(frames, "\n")
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	culprit = truncateString(culprit, 255)
+	return hex.EncodeToString(sum[:16]), title, culprit
+}
+
+// Issue is a group of error events sharing a fingerprint within a project
+type Issue struct {
+	ID                string            `json:"id"`
+	ProjectID         string            `json:"project_id"`
+	Fingerprint       string            `json:"fingerprint"`
+	Title             string            `json:"title"`
+	Culprit           string            `json:"culprit,omitempty"`
+	Level             string            `json:"level"`
+	Status            string            `json:"status"`
+	SampleMessage     string            `json:"sample_message"`
+	SampleStack       string            `json:"sample_stack,omitempty"`
+	EventCount        int64             `json:"event_count"`
+	FirstSeen         time.Time         `json:"first_seen"`
+	LastSeen          time.Time         `json:"last_seen"`
+	FirstDeploymentID string            `json:"first_deployment_id,omitempty"`
+	LastDeploymentID  string            `json:"last_deployment_id,omitempty"`
+	ResolvedAt        *time.Time        `json:"resolved_at"`
+	RegressedAt       *time.Time        `json:"regressed_at"`
+	RegressionCount   int               `json:"regression_count"`
+	Deployments       []IssueDeployment `json:"deployments,omitempty"`
+}
+
+// IssueDeployment is an issue's occurrences on one deployment
+type IssueDeployment struct {
+	DeploymentID string    `json:"deployment_id"`
+	Environment  string    `json:"environment"`
+	CommitHash   string    `json:"commit_hash"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	EventCount   int64     `json:"event_count"`
+}
+
+// IssueFilter selects issues of a project
+type IssueFilter struct {
+	Status       string
+	Environment  string
+	DeploymentID string
+	Limit        int
+}
+
+// pendingError is an error line waiting for the stack trace lines after it. A Python
+// traceback comes before its error line, so it waits for that line instead.
+type pendingError struct {
+	entry     *LogEntry
+	stack     []string
+	traceback bool
+	timer     *time.Timer
+}
+
+// issueAggregate is what a flush writes for one fingerprint on one deployment
+type issueAggregate struct {
+	deploymentID string
+	fingerprint  string
+	title        string
+	culprit      string
+	level        string
+	message      string
+	stack        string
+	count        int64
+	firstSeen    time.Time
+	lastSeen     time.Time
+	minutes      map[time.Time]int64
+}
+
+// ErrorTracker fingerprints the error lines of deployment logs and keeps issues in
+// error_issues. Events are counted in memory and written on every Flush.
+type ErrorTracker struct {
+	orchestrator *Orchestrator
+
+	mu          sync.Mutex
+	pending     map[string]*pendingError   // By container ID
+	aggregates  map[string]*issueAggregate // By deployment ID and fingerprint
+	lastCleanup time.Time
+}
+
+func NewErrorTracker(o *Orchestrator) *ErrorTracker {
+	return &ErrorTracker{
+		orchestrator: o,
+		pending:      make(map[string]*pendingError),
+		aggregates:   make(map[string]*issueAggregate),
+	}
+}
+
+// Observe sees every log line of a container in order. Error lines are held briefly so
+// the stack trace lines following them are fingerprinted with them.
+func (et *ErrorTracker) Observe(entry *LogEntry, isError bool) {
+	et.mu.Lock()
+	defer et.mu.Unlock()
+
+	if p := et.pending[entry.ContainerID]; p != nil {
+		if isStackContinuation(entry.Message) {
+			if len(p.stack) < maxStackLines {
+				p.stack = append(p.stack, entry.Message)
+			}
+			return
+		}
+		et.finish(entry.ContainerID, p)
+		if p.traceback {
+			// The line after a traceback is the error it belongs to
+			p.entry = entry
+			et.record(p)
+			return
+		}
+		et.record(p)
+	}
+
+	if strings.HasPrefix(entry.Message, tracebackHeader) {
+		et.wait(entry.ContainerID, &pendingError{entry: entry, traceback: true})
+		return
+	}
+	// Go panics are not logged with a level
+	if !isError && !strings.HasPrefix(entry.Message, "panic: ") {
+		return
+	}
+
+	if stack := metadataStack(entry.Metadata); len(stack) > 0 {
+		et.record(&pendingError{entry: entry, stack: stack})
+		return
+	}
+	et.wait(entry.ContainerID, &pendingError{entry: entry})
+}
+
+func (et *ErrorTracker) wait(containerID string, p *pendingError) {
+	et.pending[containerID] = p
+	p.timer = time.AfterFunc(stackTraceWait, func() {
+		et.mu.Lock()
+		defer et.mu.Unlock()
+		if et.pending[containerID] == p {
+			delete(et.pending, containerID)
+			et.record(p)
+		}
+	})
+}
+
+func (et *ErrorTracker) finish(containerID string, p *pendingError) {
+	p.timer.Stop()
+	delete(et.pending, containerID)
+}
+
+// metadataStack returns the stack trace of a structured log line, from the fields
+// common loggers use
+func metadataStack(metadata string) []string {
+	if metadata == "" || metadata == "{}" {
+		return nil
+	}
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(metadata), &fields) != nil {
+		return nil
+	}
+
+	candidates := []interface{}{fields["stack"], fields["stack_trace"], fields["stacktrace"], fields["exception"]}
+	if errField, ok := fields["error"].(map[string]interface{}); ok {
+		candidates = append(candidates, errField["stack"])
+	}
+	if errField, ok := fields["err"].(map[string]interface{}); ok {
+		candidates = append(candidates, errField["stack"])
+	}
+	for _, c := range candidates {
+		if s, ok := c.(string); ok && strings.Contains(s, "\n") {
+			lines := strings.Split(s, "\n")
+			if len(lines) > maxStackLines {
+				lines = lines[:maxStackLines]
+			}
+			return lines
+		}
+	}
+	return nil
+}
+
+// record adds one error event to the in-memory aggregates; et.mu must be held
+func (et *ErrorTracker) record(p *pendingError) {
+	entry := p.entry
+	message := entry.Message
+	if p.traceback && strings.HasPrefix(message, tracebackHeader) && len(p.stack) > 0 {
+		// A traceback that never got its error line is titled by its last frame
+		message = strings.TrimSpace(p.stack[len(p.stack)-1])
+	}
+
+	fingerprint, title, culprit := IssueFingerprint(message, p.stack)
+	if title == "" {
+		return
+	}
+
+	key := entry.DeploymentID + "|" + fingerprint
+	agg, ok := et.aggregates[key]
+	if !ok {
+		agg = &issueAggregate{
+			deploymentID: entry.DeploymentID,
+			fingerprint:  fingerprint,
+			title:        title,
+			culprit:      culprit,
+			firstSeen:    entry.Timestamp,
+			minutes:      make(map[time.Time]int64),
+		}
+		et.aggregates[key] = agg
+	}
+
+	agg.level = entry.Level
+	if agg.level == "" || agg.level == "info" {
+		agg.level = "error"
+	}
+	agg.message = truncateSample(message)
+	if len(p.stack) > 0 {
+		agg.stack = truncateSample(strings.Join(p.stack, "\n"))
+	}
+	agg.count++
+	if entry.Timestamp.Before(agg.firstSeen) {
+		agg.firstSeen = entry.Timestamp
+	}
+	if entry.Timestamp.After(agg.lastSeen) {
+		agg.lastSeen = entry.Timestamp
+	}
+	agg.minutes[entry.Timestamp.UTC().Truncate(time.Minute)]++
+}
+
+func truncateSample(s string) string {
+	return truncateString(s, maxIssueSampleLength)
+}
+
+// Flush writes the events counted since the last flush to their issues and raises an
+// alert for every issue that regressed
+func (et *ErrorTracker) Flush(ctx context.Context) error {
+	et.mu.Lock()
+	aggregates := et.aggregates
+	et.aggregates = make(map[string]*issueAggregate)
+	cleanup := time.Since(et.lastCleanup) > 10*time.Minute
+	if cleanup {
+		et.lastCleanup = time.Now()
+	}
+	et.mu.Unlock()
+
+	var firstErr error
+	for _, agg := range aggregates {
+		if err := et.writeAggregate(ctx, agg); err != nil {
+			logger.Error("Failed to record error issue",
+				logger.String("deployment_id", agg.deploymentID),
+				logger.String("fingerprint", agg.fingerprint),
+				logger.Err(err))
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if cleanup {
+		if _, err := et.orchestrator.db.ExecContext(ctx,
+			`DELETE FROM error_issue_counts WHERE minute < NOW() - make_interval(secs => $1)`,
+			issueCountRetention.Seconds()); err != nil {
+			logger.Error("Failed to clean up issue counts", logger.Err(err))
+		}
+	}
+
+	return firstErr
+}
+
+func (et *ErrorTracker) writeAggregate(ctx context.Context, agg *issueAggregate) error {
+	tx, err := et.orchestrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// An event from a deployment created after the issue was resolved is a regression;
+	// events from deployments that were already running count without reopening it
+	var issueID, title, status string
+	var regressed bool
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO error_issues (
+			project_id, fingerprint, title, culprit, level, sample_message, sample_stack,
+			first_seen, last_seen, event_count, first_deployment_id, last_deployment_id
+		)
+		SELECT d.project_id, $2, $3, NULLIF($4, ''), $5, $6, NULLIF($7, ''), $8, $9, $10, d.id, d.id
+		FROM deployments d WHERE d.id = $1
+		ON CONFLICT (project_id, fingerprint) DO UPDATE SET
+			level = EXCLUDED.level,
+			sample_message = EXCLUDED.sample_message,
+			sample_stack = COALESCE(EXCLUDED.sample_stack, error_issues.sample_stack),
+			culprit = COALESCE(error_issues.culprit, EXCLUDED.culprit),
+			first_seen = LEAST(error_issues.first_seen, EXCLUDED.first_seen),
+			last_seen = GREATEST(error_issues.last_seen, EXCLUDED.last_seen),
+			event_count = error_issues.event_count + EXCLUDED.event_count,
+			last_deployment_id = EXCLUDED.last_deployment_id,
+			status = CASE WHEN error_issues.status = 'resolved'
+			               AND (SELECT created_at FROM deployments WHERE id = EXCLUDED.last_deployment_id) > error_issues.resolved_at
+			              THEN 'unresolved' ELSE error_issues.status END,
+			regressed_at = CASE WHEN error_issues.status = 'resolved'
+			                     AND (SELECT created_at FROM deployments WHERE id = EXCLUDED.last_deployment_id) > error_issues.resolved_at
+			                    THEN NOW() ELSE error_issues.regressed_at END,
+			regression_count = error_issues.regression_count + CASE WHEN error_issues.status = 'resolved'
+			                     AND (SELECT created_at FROM deployments WHERE id = EXCLUDED.last_deployment_id) > error_issues.resolved_at
+			                    THEN 1 ELSE 0 END,
+			updated_at = NOW()
+		RETURNING id, title, status, regressed_at IS NOT NULL AND regressed_at = NOW()`,
+		agg.deploymentID, agg.fingerprint, agg.title, agg.culprit, agg.level, agg.message, agg.stack,
+		agg.firstSeen, agg.lastSeen, agg.count,
+	).Scan(&issueID, &title, &status, &regressed)
+	if err == sql.ErrNoRows {
+		// The deployment was deleted while its logs were still arriving
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO error_issue_deployments (issue_id, deployment_id, first_seen, last_seen, event_count)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (issue_id, deployment_id) DO UPDATE SET
+			first_seen = LEAST(error_issue_deployments.first_seen, EXCLUDED.first_seen),
+			last_seen = GREATEST(error_issue_deployments.last_seen, EXCLUDED.last_seen),
+			event_count = error_issue_deployments.event_count + EXCLUDED.event_count`,
+		issueID, agg.deploymentID, agg.firstSeen, agg.lastSeen, agg.count)
+	if err != nil {
+		return err
+	}
+
+	minutes := make([]time.Time, 0, len(agg.minutes))
+	for minute := range agg.minutes {
+		minutes = append(minutes, minute)
+	}
+	sort.Slice(minutes, func(i, j int) bool { return minutes[i].Before(minutes[j]) })
+	for _, minute := range minutes {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO error_issue_counts (issue_id, deployment_id, minute, event_count)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (issue_id, deployment_id, minute) DO UPDATE
+			SET event_count = error_issue_counts.event_count + EXCLUDED.event_count`,
+			issueID, agg.deploymentID, minute, agg.minutes[minute])
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if regressed {
+		logger.Warn("Error issue regressed",
+			logger.String("issue_id", issueID),
+			logger.String("deployment_id", agg.deploymentID))
+
+		alert := &models.Alert{
+			DeploymentID: agg.deploymentID,
+			Severity:     "warning",
+			Title:        "Resolved error is back",
+			Description:  fmt.Sprintf("Issue resolved earlier occurred again in a newer deployment: %s", title),
+			MetricType:   "error_regression",
+			CurrentValue: float64(agg.count),
+			Metadata: map[string]interface{}{
+				"issue_id":    issueID,
+				"fingerprint": agg.fingerprint,
+			},
+		}
+		if err := et.orchestrator.alertManager.TriggerAlert(ctx, alert); err != nil {
+			return fmt.Errorf("failed to raise regression alert: %w", err)
+		}
+	}
+	return nil
+}
+
+// CountEvents returns how many events of an issue a deployment logged within the window
+func (et *ErrorTracker) CountEvents(ctx context.Context, deploymentID, fingerprint string, window time.Duration) (*float64, error) {
+	var count float64
+	err := et.orchestrator.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(c.event_count), 0)
+		FROM error_issue_counts c
+		JOIN error_issues i ON i.id = c.issue_id
+		WHERE c.deployment_id = $1 AND i.fingerprint = $2
+		  AND c.minute > NOW() - make_interval(secs => $3)`,
+		deploymentID, fingerprint, window.Seconds()).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+const issueColumns = `i.id, i.project_id, i.fingerprint, i.title, COALESCE(i.culprit, ''), i.level, i.status,
+	i.sample_message, COALESCE(i.sample_stack, ''), i.event_count, i.first_seen, i.last_seen,
+	COALESCE(i.first_deployment_id::text, ''), COALESCE(i.last_deployment_id::text, ''),
+	i.resolved_at, i.regressed_at, i.regression_count`
+
+func scanIssue(scan func(dest ...interface{}) error) (*Issue, error) {
+	var i Issue
+	var resolvedAt, regressedAt sql.NullTime
+	err := scan(&i.ID, &i.ProjectID, &i.Fingerprint, &i.Title, &i.Culprit, &i.Level, &i.Status,
+		&i.SampleMessage, &i.SampleStack, &i.EventCount, &i.FirstSeen, &i.LastSeen,
+		&i.FirstDeploymentID, &i.LastDeploymentID, &resolvedAt, &regressedAt, &i.RegressionCount)
+	if err != nil {
+		return nil, err
+	}
+	if resolvedAt.Valid {
+		i.ResolvedAt = &resolvedAt.Time
+	}
+	if regressedAt.Valid {
+		i.RegressedAt = &regressedAt.Time
+	}
+	return &i, nil
+}
+
+// ListIssues returns a project's issues, most recently seen first
+func (et *ErrorTracker) ListIssues(ctx context.Context, projectID string, filter IssueFilter) ([]*Issue, error) {
+	if filter.Status != "" && !issueStatuses[filter.Status] {
+		return nil, fmt.Errorf("status must be unresolved, resolved or ignored")
+	}
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+
+	rows, err := et.orchestrator.db.QueryContext(ctx, `
+		SELECT `+issueColumns+`
+		FROM error_issues i
+		WHERE i.project_id = $1
+		  AND ($2 = '' OR i.status = $2)
+		  AND ($3 = '' OR EXISTS (
+		      SELECT 1 FROM error_issue_deployments id JOIN deployments d ON d.id = id.deployment_id
+		      WHERE id.issue_id = i.id AND d.environment = $3))
+		  AND ($4 = '' OR EXISTS (
+		      SELECT 1 FROM error_issue_deployments id
+		      WHERE id.issue_id = i.id AND id.deployment_id::text = $4))
+		ORDER BY i.last_seen DESC
+		LIMIT $5`,
+		projectID, filter.Status, filter.Environment, filter.DeploymentID, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	issues := []*Issue{}
+	for rows.Next() {
+		i, err := scanIssue(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, i)
+	}
+	return issues, rows.Err()
+}
+
+// GetIssue returns one of a project's issues with its occurrences per deployment
+func (et *ErrorTracker) GetIssue(ctx context.Context, projectID, issueID string) (*Issue, error) {
+	i, err := scanIssue(et.orchestrator.db.QueryRowContext(ctx, `
+		SELECT `+issueColumns+` FROM error_issues i WHERE i.id = $1 AND i.project_id = $2`,
+		issueID, projectID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrIssueNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := et.orchestrator.db.QueryContext(ctx, `
+		SELECT id.deployment_id, d.environment, d.commit_hash, id.first_seen, id.last_seen, id.event_count
+		FROM error_issue_deployments id
+		JOIN deployments d ON d.id = id.deployment_id
+		WHERE id.issue_id = $1
+		ORDER BY d.created_at DESC`, issueID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	i.Deployments = []IssueDeployment{}
+	for rows.Next() {
+		var d IssueDeployment
+		if err := rows.Scan(&d.DeploymentID, &d.Environment, &d.CommitHash, &d.FirstSeen, &d.LastSeen, &d.EventCount); err != nil {
+			return nil, err
+		}
+		i.Deployments = append(i.Deployments, d)
+	}
+	return i, rows.Err()
+}
+
+// UpdateIssueStatus resolves, ignores or reopens an issue. A resolved issue regresses
+// when it occurs on a deployment created after this call.
+func (et *ErrorTracker) UpdateIssueStatus(ctx context.Context, projectID, issueID, status, userID string) (*Issue, error) {
+	if !issueStatuses[status] {
+		return nil, fmt.Errorf("status must be unresolved, resolved or ignored")
+	}
+
+	res, err := et.orchestrator.db.ExecContext(ctx, `
+		UPDATE error_issues
+		SET status = $3,
+		    resolved_at = CASE WHEN $3 = 'resolved' THEN NOW() END,
+		    resolved_by = CASE WHEN $3 = 'resolved' THEN $4::uuid END,
+		    updated_at = NOW()
+		WHERE id = $1 AND project_id = $2`,
+		issueID, projectID, status, nullIfEmpty(userID))
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrIssueNotFound
+	}
+
+	if status == IssueStatusResolved {
+		// Its regression alerts are settled along with it
+		if _, err := et.orchestrator.db.ExecContext(ctx, `
+			UPDATE deployment_alerts SET resolved = true, resolved_at = NOW()
+			WHERE resolved = false AND alert_type = 'error_regression' AND alert_data->>'issue_id' = $1`,
+			issueID); err != nil {
+			logger.Error("Failed to resolve regression alerts", logger.String("issue_id", issueID), logger.Err(err))
+		}
+	}
+
+	return et.GetIssue(ctx, projectID, issueID)
+}
@@ -0,0 +1,23 @@
+package monitoring
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestIssueFingerprintTruncatesOnRuneBoundary(t *testing.T) {
+	// The 255 byte limit falls inside "é" in the title and "€" in the culprit
+	message := strings.Repeat("x", 254) + "é and more"
+	frame := "at " + strings.Repeat("z", 253) + "€ (main.go:1)"
+
+	_, title, culprit := IssueFingerprint(message, []string{frame})
+	for name, got := range map[string]string{"title": title, "culprit": culprit} {
+		if !utf8.ValidString(got) {
+			t.Errorf("%s is not valid UTF-8: %q", name, got[len(got)-4:])
+		}
+		if len(got) > 255 {
+			t.Errorf("%s is %d bytes, want at most 255", name, len(got))
+		}
+	}
+}
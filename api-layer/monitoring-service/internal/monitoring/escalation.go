@@ -0,0 +1,340 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"monitoring-service/pkg/logger"
+)
+
+var ErrEscalationNotFound = errors.New("alert has no escalation")
+
+// AlertEscalation is the progress of an alert through its escalation policy
+type AlertEscalation struct {
+	AlertID          string      `json:"alert_id"`
+	PolicyID         string      `json:"policy_id,omitempty"`
+	Level            int         `json:"level"` // Zero-based index of the level paged last
+	Cycle            int         `json:"cycle"`
+	NextEscalationAt *time.Time  `json:"next_escalation_at"`
+	StoppedAt        *time.Time  `json:"stopped_at"`
+	StopReason       string      `json:"stop_reason,omitempty"`
+	StartedAt        time.Time   `json:"started_at"`
+	Pages            []AlertPage `json:"pages"`
+}
+
+// AlertPage records that a user was paged for an alert
+type AlertPage struct {
+	UserID     string    `json:"user_id"`
+	Email      string    `json:"email,omitempty"`
+	Level      int       `json:"level"`
+	Cycle      int       `json:"cycle"`
+	ScheduleID string    `json:"schedule_id,omitempty"`
+	PagedAt    time.Time `json:"paged_at"`
+}
+
+// escalationStep is a level that is due to be paged
+type escalationStep struct {
+	alertID string
+	policy  *EscalationPolicy
+	level   int
+	cycle   int
+}
+
+// StartEscalation pages the first level of the policy covering an alert's project, if
+// its team has a default policy and the alert is severe enough. Alerts already under
+// escalation are left alone.
+func (om *OnCallManager) StartEscalation(ctx context.Context, alertID string) error {
+	db := om.orchestrator.db
+
+	var severity, policyID string
+	err := db.QueryRowContext(ctx, `
+		SELECT a.severity, ep.id
+		FROM deployment_alerts a
+		JOIN deployments d ON d.id = a.deployment_id
+		JOIN projects pr ON pr.id = d.project_id
+		JOIN escalation_policies ep ON ep.team_id = pr.team_id AND ep.is_default
+		WHERE a.id = $1`, alertID).Scan(&severity, &policyID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	p, err := getEscalationPolicy(ctx, db, policyID)
+	if err != nil {
+		return err
+	}
+	if incidentSeverityRank[severity] < incidentSeverityRank[p.MinSeverity] || len(p.Levels) == 0 {
+		return nil
+	}
+
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO alert_escalations (alert_id, policy_id, next_escalation_at)
+		VALUES ($1, $2, NOW() + make_interval(mins => $3))
+		ON CONFLICT (alert_id) DO NOTHING`, alertID, p.ID, p.Levels[0].TimeoutMinutes)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	return om.page(ctx, escalationStep{alertID: alertID, policy: p, level: 0})
+}
+
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func getEscalationPolicy(ctx context.Context, q rowQuerier, policyID string) (*EscalationPolicy, error) {
+	return scanEscalationPolicy(q.QueryRowContext(ctx, `
+		SELECT `+escalationPolicyColumns+` FROM escalation_policies WHERE id = $1`, policyID).Scan)
+}
+
+// Escalate stops the escalations of alerts that were acknowledged or resolved, then
+// pages the next level of every escalation whose level timed out
+func (om *OnCallManager) Escalate(ctx context.Context) error {
+	db := om.orchestrator.db
+
+	if _, err := db.ExecContext(ctx, `
+		UPDATE alert_escalations e
+		SET stopped_at = NOW(), next_escalation_at = NULL,
+		    stop_reason = CASE WHEN a.resolved THEN 'resolved' ELSE 'acknowledged' END
+		FROM deployment_alerts a
+		WHERE a.id = e.alert_id AND e.stopped_at IS NULL
+		  AND (a.resolved OR COALESCE(a.acknowledged, false))`); err != nil {
+		return fmt.Errorf("failed to stop escalations: %w", err)
+	}
+
+	steps, err := om.advanceDue(ctx)
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		if err := om.page(ctx, step); err != nil {
+			logger.Error("Failed to page escalation level",
+				logger.String("alert_id", step.alertID), logger.Int("level", step.level), logger.Err(err))
+		}
+	}
+	return nil
+}
+
+// advanceDue moves every due escalation to its next level, or back to the first one
+// while the policy has repeats left, and returns the levels to page
+func (om *OnCallManager) advanceDue(ctx context.Context) ([]escalationStep, error) {
+	tx, err := om.orchestrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT e.alert_id, e.level, e.cycle, COALESCE(e.policy_id::text, '')
+		FROM alert_escalations e
+		WHERE e.stopped_at IS NULL AND e.next_escalation_at <= NOW()
+		ORDER BY e.next_escalation_at
+		LIMIT 100
+		FOR UPDATE SKIP LOCKED`)
+	if err != nil {
+		return nil, err
+	}
+
+	type due struct {
+		alertID, policyID string
+		level, cycle      int
+	}
+	var dues []due
+	for rows.Next() {
+		var d due
+		if err := rows.Scan(&d.alertID, &d.level, &d.cycle, &d.policyID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		dues = append(dues, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	policies := map[string]*EscalationPolicy{}
+	for _, d := range dues {
+		if _, ok := policies[d.policyID]; ok || d.policyID == "" {
+			continue
+		}
+		p, err := getEscalationPolicy(ctx, tx, d.policyID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		policies[d.policyID] = p
+	}
+
+	var steps []escalationStep
+	for _, d := range dues {
+		policy := policies[d.policyID]
+		if policy == nil || len(policy.Levels) == 0 {
+			if err := stopEscalation(ctx, tx, d.alertID, "policy_removed"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		level, cycle := d.level+1, d.cycle
+		if level >= len(policy.Levels) {
+			if cycle >= policy.RepeatCount {
+				if err := stopEscalation(ctx, tx, d.alertID, "exhausted"); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			level, cycle = 0, cycle+1
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE alert_escalations
+			SET level = $2, cycle = $3, next_escalation_at = NOW() + make_interval(mins => $4)
+			WHERE alert_id = $1`, d.alertID, level, cycle, policy.Levels[level].TimeoutMinutes); err != nil {
+			return nil, err
+		}
+		steps = append(steps, escalationStep{alertID: d.alertID, policy: policy, level: level, cycle: cycle})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// StopEscalation ends an alert's escalation right away, e.g. when it is acknowledged
+func (om *OnCallManager) StopEscalation(ctx context.Context, alertID, reason string) error {
+	_, err := om.orchestrator.db.ExecContext(ctx, `
+		UPDATE alert_escalations SET stopped_at = NOW(), next_escalation_at = NULL, stop_reason = $2
+		WHERE alert_id = $1 AND stopped_at IS NULL`, alertID, reason)
+	return err
+}
+
+func stopEscalation(ctx context.Context, tx *sql.Tx, alertID, reason string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE alert_escalations SET stopped_at = NOW(), next_escalation_at = NULL, stop_reason = $2
+		WHERE alert_id = $1`, alertID, reason)
+	return err
+}
+
+// page notifies everyone a level targets: users directly, schedules through whoever is
+// on call. A user targeted twice is paged once.
+func (om *OnCallManager) page(ctx context.Context, step escalationStep) error {
+	db := om.orchestrator.db
+	now := time.Now()
+
+	var severity, message string
+	err := db.QueryRowContext(ctx, `
+		SELECT severity, alert_message FROM deployment_alerts WHERE id = $1`, step.alertID).Scan(&severity, &message)
+	if err != nil {
+		return err
+	}
+
+	paged := map[string]bool{}
+	for _, target := range step.policy.Levels[step.level].Targets {
+		userID, scheduleID := target.ID, ""
+		if target.Type == "schedule" {
+			s, err := scanSchedule(db.QueryRowContext(ctx, `
+				SELECT `+scheduleColumns+` FROM oncall_schedules s WHERE s.id = $1`, target.ID).Scan)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			shift, err := om.onCallAt(ctx, s, now)
+			if err != nil {
+				return err
+			}
+			if shift == nil {
+				continue
+			}
+			userID, scheduleID = shift.UserID, s.ID
+		}
+		if paged[userID] {
+			continue
+		}
+		paged[userID] = true
+
+		var email string
+		err = db.QueryRowContext(ctx, `
+			WITH page AS (
+				INSERT INTO alert_pages (alert_id, user_id, level, cycle, schedule_id)
+				VALUES ($1, $2, $3, $4, $5)
+			)
+			SELECT COALESCE((SELECT email FROM users WHERE id = $2), '')`,
+			step.alertID, userID, step.level, step.cycle, nullIfEmpty(scheduleID)).Scan(&email)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("Paging on-call user",
+			logger.String("alert_id", step.alertID),
+			logger.String("user_id", userID),
+			logger.String("email", email),
+			logger.String("severity", severity),
+			logger.Int("level", step.level+1),
+			logger.String("message", message))
+	}
+
+	if len(paged) == 0 {
+		logger.Warn("Escalation level has nobody to page",
+			logger.String("alert_id", step.alertID), logger.String("policy_id", step.policy.ID), logger.Int("level", step.level+1))
+	}
+	return nil
+}
+
+// GetEscalation returns an alert's escalation and who was paged
+func (om *OnCallManager) GetEscalation(ctx context.Context, alertID string) (*AlertEscalation, error) {
+	db := om.orchestrator.db
+
+	e := AlertEscalation{AlertID: alertID}
+	var policyID, stopReason sql.NullString
+	var next, stopped sql.NullTime
+	err := db.QueryRowContext(ctx, `
+		SELECT policy_id, level, cycle, next_escalation_at, stopped_at, stop_reason, started_at
+		FROM alert_escalations WHERE alert_id = $1`, alertID).Scan(
+		&policyID, &e.Level, &e.Cycle, &next, &stopped, &stopReason, &e.StartedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrEscalationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.PolicyID = policyID.String
+	e.StopReason = stopReason.String
+	if next.Valid {
+		e.NextEscalationAt = &next.Time
+	}
+	if stopped.Valid {
+		e.StoppedAt = &stopped.Time
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT COALESCE(p.user_id::text, ''), COALESCE(u.email, ''), p.level, p.cycle,
+		       COALESCE(p.schedule_id::text, ''), p.paged_at
+		FROM alert_pages p
+		LEFT JOIN users u ON u.id = p.user_id
+		WHERE p.alert_id = $1
+		ORDER BY p.paged_at`, alertID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	e.Pages = []AlertPage{}
+	for rows.Next() {
+		var p AlertPage
+		if err := rows.Scan(&p.UserID, &p.Email, &p.Level, &p.Cycle, &p.ScheduleID, &p.PagedAt); err != nil {
+			return nil, err
+		}
+		e.Pages = append(e.Pages, p)
+	}
+	return &e, rows.Err()
+}
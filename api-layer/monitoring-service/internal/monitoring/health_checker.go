@@ -2,25 +2,45 @@ package monitoring
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"monitoring-service/pkg/models"
+
+	"github.com/AALXX/Obtura/shared/healthcheck"
+)
+
+const (
+	defaultHealthCheckPath    = healthcheck.DefaultPath
+	defaultHealthCheckTimeout = healthcheck.DefaultTimeout
+
+	// deploymentHost is where published container ports are reachable, same as the Traefik services
+	deploymentHost = "docker"
 )
 
 type HealthChecker struct {
 	orchestrator *Orchestrator
 	httpClient   *http.Client
+
+	mu        sync.Mutex
+	lastCheck map[string]time.Time // container ID -> last run
+	failures  map[string]int       // container ID + check type -> consecutive failures
 }
 
 func NewHealthChecker(o *Orchestrator) *HealthChecker {
 	return &HealthChecker{
 		orchestrator: o,
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			// Per-request timeouts come from the deployment's health check spec
+			Timeout: 60 * time.Second,
 		},
+		lastCheck: make(map[string]time.Time),
+		failures:  make(map[string]int),
 	}
 }
 
@@ -31,7 +51,12 @@ func (hc *HealthChecker) CheckAll(ctx context.Context) error {
 		return fmt.Errorf("failed to get active deployments: %w", err)
 	}
 
+	hc.prune(deployments)
+
 	for _, deployment := range deployments {
+		if !hc.due(deployment) {
+			continue
+		}
 		if err := hc.checkDeployment(ctx, deployment); err != nil {
 			log.Printf("Health check failed for deployment %s: %v", deployment.ID, err)
 		}
@@ -40,6 +65,63 @@ func (hc *HealthChecker) CheckAll(ctx context.Context) error {
 	return nil
 }
 
+// checkKey identifies a checked container; getActiveDeployments returns one row per
+// container, so replicas of a deployment are throttled and alerted on separately
+func checkKey(deployment *models.Deployment) string {
+	return deployment.ContainerID
+}
+
+// due reports whether the deployment's own check interval has elapsed for the container
+func (hc *HealthChecker) due(deployment *models.Deployment) bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	key := checkKey(deployment)
+	now := time.Now()
+	if last, ok := hc.lastCheck[key]; ok && now.Sub(last) < deployment.HealthCheck.Interval() {
+		return false
+	}
+	hc.lastCheck[key] = now
+	return true
+}
+
+// prune forgets containers that are no longer active
+func (hc *HealthChecker) prune(active []*models.Deployment) {
+	keys := make(map[string]bool, len(active))
+	for _, deployment := range active {
+		keys[checkKey(deployment)] = true
+	}
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	for key := range hc.lastCheck {
+		if !keys[key] {
+			delete(hc.lastCheck, key)
+		}
+	}
+	for key := range hc.failures {
+		if containerID, _, _ := strings.Cut(key, ":"); !keys[containerID] {
+			delete(hc.failures, key)
+		}
+	}
+}
+
+// reachedRetries counts a consecutive failure and reports whether it crossed the retry threshold.
+// A success resets the counter.
+func (hc *HealthChecker) reachedRetries(deployment *models.Deployment, checkType string, failed bool) bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	key := checkKey(deployment) + ":" + checkType
+	if !failed {
+		delete(hc.failures, key)
+		return false
+	}
+	hc.failures[key]++
+	return hc.failures[key] == deployment.HealthCheck.RetryCount()
+}
+
 func (hc *HealthChecker) checkDeployment(ctx context.Context, deployment *models.Deployment) error {
 	// Skip health checks if not enabled for this project
 	if !deployment.HealthCheckEnabled {
@@ -67,33 +149,40 @@ func (hc *HealthChecker) checkDeployment(ctx context.Context, deployment *models
 }
 
 func (hc *HealthChecker) performHTTPCheck(ctx context.Context, deployment *models.Deployment) error {
+	spec := deployment.HealthCheck
+
+	ctx, cancel := context.WithTimeout(ctx, spec.Timeout())
+	defer cancel()
+
 	startTime := time.Now()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", deployment.HealthCheckEndpoint, nil)
 	if err != nil {
-		return hc.recordHealthCheck(deployment.ID, "http", "failed", 0, 0, err.Error())
+		return hc.recordHealthCheck(deployment, "http", "failed", 0, 0, err.Error())
 	}
 
 	resp, err := hc.httpClient.Do(req)
 	responseTime := time.Since(startTime).Milliseconds()
 
 	if err != nil {
-		return hc.recordHealthCheck(deployment.ID, "http", "failed", int(responseTime), 0, err.Error())
+		return hc.recordHealthCheck(deployment, "http", "failed", int(responseTime), 0, err.Error())
 	}
 	defer resp.Body.Close()
 
 	status := "healthy"
-	if resp.StatusCode >= 400 {
+	errorMsg := ""
+	if !spec.Accepts(resp.StatusCode) {
 		status = "unhealthy"
+		errorMsg = fmt.Sprintf("%s returned %d, expected %s", spec.Path, resp.StatusCode, expectedStatusLabel(spec.ExpectedStatus))
 	}
 
-	return hc.recordHealthCheck(deployment.ID, "http", status, int(responseTime), resp.StatusCode, "")
+	return hc.recordHealthCheck(deployment, "http", status, int(responseTime), resp.StatusCode, errorMsg)
 }
 
 func (hc *HealthChecker) performContainerCheck(ctx context.Context, deployment *models.Deployment) error {
 	containerStats, err := hc.orchestrator.dockerClient.GetContainerStats(ctx, deployment.ContainerID)
 	if err != nil {
-		hc.recordHealthCheck(deployment.ID, "container", "failed", 0, 0, err.Error())
+		hc.recordHealthCheck(deployment, "container", "failed", 0, 0, err.Error())
 		hc.updateContainerHealthStatus(ctx, deployment.ContainerID, "unhealthy")
 		return err
 	}
@@ -103,7 +192,7 @@ func (hc *HealthChecker) performContainerCheck(ctx context.Context, deployment *
 		status = "unhealthy"
 	}
 
-	hc.recordHealthCheck(deployment.ID, "container", status, 0, 0, "")
+	hc.recordHealthCheck(deployment, "container", status, 0, 0, "")
 	hc.updateContainerHealthStatus(ctx, deployment.ContainerID, status)
 	return nil
 }
@@ -148,7 +237,7 @@ func (hc *HealthChecker) checkCacheConnection(ctx context.Context, deployment *m
 	return nil
 }
 
-func (hc *HealthChecker) recordHealthCheck(deploymentID, checkType, status string, responseTime, statusCode int, errorMsg string) error {
+func (hc *HealthChecker) recordHealthCheck(deployment *models.Deployment, checkType, status string, responseTime, statusCode int, errorMsg string) error {
 	query := `
 		INSERT INTO health_checks (
 			deployment_id, check_type, status, response_time_ms, status_code, error_message, checked_at
@@ -157,7 +246,7 @@ func (hc *HealthChecker) recordHealthCheck(deploymentID, checkType, status strin
 
 	_, err := hc.orchestrator.db.Exec(
 		query,
-		deploymentID,
+		deployment.ID,
 		checkType,
 		status,
 		responseTime,
@@ -170,13 +259,14 @@ func (hc *HealthChecker) recordHealthCheck(deploymentID, checkType, status strin
 		return fmt.Errorf("failed to record health check: %w", err)
 	}
 
-	// Trigger alert if status is unhealthy
-	if status == "unhealthy" || status == "failed" {
+	// Alert once the failure has persisted for the configured number of consecutive checks
+	failed := status == "unhealthy" || status == "failed"
+	if hc.reachedRetries(deployment, checkType, failed) {
 		hc.orchestrator.alertManager.TriggerAlert(context.Background(), &models.Alert{
-			DeploymentID: deploymentID,
+			DeploymentID: deployment.ID,
 			Severity:     "warning",
 			Title:        fmt.Sprintf("Health check failed: %s", checkType),
-			Description:  errorMsg,
+			Description:  fmt.Sprintf("%d consecutive failures: %s", deployment.HealthCheck.RetryCount(), errorMsg),
 			MetricType:   "health_check",
 		})
 	}
@@ -191,6 +281,12 @@ func (hc *HealthChecker) getActiveDeployments(ctx context.Context) ([]*models.De
 			dc.container_id, 
 			ps.health_check_url,
 			ps.perform_health_checks,
+			d.health_check_path,
+			COALESCE(d.health_check_expected_status, 0),
+			COALESCE(d.health_check_interval_seconds, 0),
+			COALESCE(d.health_check_timeout_seconds, 0),
+			COALESCE(d.health_check_retries, 0),
+			COALESCE(dc.port, 0),
 			CASE 
 				WHEN d.database_connections IS NOT NULL 
 					AND jsonb_array_length(d.database_connections) > 0 
@@ -221,11 +317,58 @@ func (hc *HealthChecker) getActiveDeployments(ctx context.Context) ([]*models.De
 	var deployments []*models.Deployment
 	for rows.Next() {
 		var d models.Deployment
-		if err := rows.Scan(&d.ID, &d.ContainerID, &d.HealthCheckEndpoint, &d.HealthCheckEnabled, &d.HasDatabase, &d.HasCache); err != nil {
+		var settingsURL, path sql.NullString
+		var expectedStatus, intervalSeconds, timeoutSeconds, retries int
+		if err := rows.Scan(&d.ID, &d.ContainerID, &settingsURL, &d.HealthCheckEnabled,
+			&path, &expectedStatus, &intervalSeconds, &timeoutSeconds, &retries, &d.Port,
+			&d.HasDatabase, &d.HasCache); err != nil {
 			return nil, err
 		}
+
+		d.HealthCheck = models.HealthCheckSpec{
+			Path:            path.String,
+			ExpectedStatus:  expectedStatus,
+			IntervalSeconds: intervalSeconds,
+			TimeoutSeconds:  timeoutSeconds,
+			Retries:         retries,
+		}
+		d.HealthCheckEndpoint = healthCheckEndpoint(settingsURL.String, &d)
+
 		deployments = append(deployments, &d)
 	}
 
 	return deployments, nil
 }
+
+// healthCheckEndpoint uses an absolute URL from project settings as-is, otherwise probes the
+// deployment's resolved path on its published port
+func healthCheckEndpoint(settingsURL string, d *models.Deployment) string {
+	settingsURL = strings.TrimSpace(settingsURL)
+	if strings.HasPrefix(settingsURL, "http://") || strings.HasPrefix(settingsURL, "https://") {
+		return settingsURL
+	}
+	if d.Port == 0 {
+		return ""
+	}
+
+	path := d.HealthCheck.Path
+	if path == "" {
+		path = settingsURL
+	}
+	if path == "" {
+		path = defaultHealthCheckPath
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	d.HealthCheck.Path = path
+
+	return fmt.Sprintf("http://%s:%d%s", deploymentHost, d.Port, path)
+}
+
+func expectedStatusLabel(status int) string {
+	if status == 0 {
+		return "2xx/3xx"
+	}
+	return fmt.Sprintf("%d", status)
+}
@@ -0,0 +1,594 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"monitoring-service/pkg/logger"
+	"monitoring-service/pkg/models"
+)
+
+const (
+	IncidentStatusOpen         = "open"
+	IncidentStatusAcknowledged = "acknowledged"
+	IncidentStatusMitigated    = "mitigated"
+	IncidentStatusResolved     = "resolved"
+
+	// Alerts for the same project within this window of the incident's last alert are grouped
+	incidentCorrelationWindow = 15 * time.Minute
+	// Mitigated incidents with no new alerts for this long are resolved automatically
+	incidentAutoResolveAfter = 30 * time.Minute
+	// Deploys this long before the first alert are part of the incident timeline
+	incidentTimelineLookback = 1 * time.Hour
+)
+
+var (
+	ErrIncidentNotFound  = errors.New("incident not found")
+	ErrInvalidTransition = errors.New("invalid incident status transition")
+	incidentTransitions  = map[string][]string{
+		IncidentStatusOpen:         {IncidentStatusAcknowledged, IncidentStatusMitigated, IncidentStatusResolved},
+		IncidentStatusAcknowledged: {IncidentStatusMitigated, IncidentStatusResolved},
+		IncidentStatusMitigated:    {IncidentStatusResolved},
+	}
+	incidentSeverityRank = map[string]int{
+		"info": 0, "low": 1, "warning": 2, "medium": 2, "high": 3, "critical": 4,
+	}
+)
+
+// IncidentManager groups related alerts into incidents and drives their lifecycle
+type IncidentManager struct {
+	orchestrator *Orchestrator
+}
+
+// IncidentStats are the MTTA/MTTR figures over a period
+type IncidentStats struct {
+	Total             int
+	Open              int
+	Resolved          int
+	MeanTimeToAck     *time.Duration
+	MeanTimeToResolve *time.Duration
+}
+
+func NewIncidentManager(o *Orchestrator) *IncidentManager {
+	return &IncidentManager{orchestrator: o}
+}
+
+// Process attaches new alerts to incidents and advances incidents whose alerts cleared
+func (im *IncidentManager) Process(ctx context.Context) error {
+	if err := im.correlateAlerts(ctx); err != nil {
+		return fmt.Errorf("failed to correlate alerts: %w", err)
+	}
+	if err := im.advanceIncidents(ctx); err != nil {
+		return fmt.Errorf("failed to advance incidents: %w", err)
+	}
+	return nil
+}
+
+type pendingAlert struct {
+	id, deploymentID, projectID  string
+	alertType, severity, message string
+	createdAt                    time.Time
+}
+
+// correlateAlerts attaches every unresolved alert that is not part of an incident yet. An
+// alert joins the project's live incident if that incident saw an alert within the
+// correlation window, preferring one on the same deployment; otherwise it opens a new
+// incident. Low-severity alerts only join existing incidents, and silenced alerts wait
+// until their silence ends.
+func (im *IncidentManager) correlateAlerts(ctx context.Context) error {
+	rows, err := im.orchestrator.db.QueryContext(ctx, `
+		SELECT a.id, a.deployment_id, COALESCE(a.project_id, d.project_id), a.alert_type,
+		       a.severity, a.alert_message, a.created_at
+		FROM deployment_alerts a
+		JOIN deployments d ON d.id = a.deployment_id
+		LEFT JOIN incident_alerts ia ON ia.alert_id = a.id
+		WHERE ia.alert_id IS NULL
+		  AND a.resolved = false
+		  AND a.suppressed_by IS NULL
+		  AND a.created_at > NOW() - INTERVAL '24 hours'
+		ORDER BY a.created_at
+		LIMIT 500
+	`)
+	if err != nil {
+		return err
+	}
+
+	var alerts []pendingAlert
+	for rows.Next() {
+		var a pendingAlert
+		if err := rows.Scan(&a.id, &a.deploymentID, &a.projectID, &a.alertType, &a.severity, &a.message, &a.createdAt); err != nil {
+			logger.Error("Failed to scan alert for correlation", logger.Err(err))
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+	rows.Close()
+
+	for _, a := range alerts {
+		if err := im.attachAlert(ctx, a); err != nil {
+			logger.Error("Failed to attach alert to incident",
+				logger.String("alert_id", a.id), logger.Err(err))
+		}
+	}
+	return nil
+}
+
+func (im *IncidentManager) attachAlert(ctx context.Context, a pendingAlert) error {
+	tx, err := im.orchestrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Serialize correlation per project so concurrent passes don't open twin incidents
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "incident:"+a.projectID); err != nil {
+		return err
+	}
+
+	var incidentID, severity, status string
+	var acknowledged bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, severity, status, acknowledged_at IS NOT NULL
+		FROM incidents
+		WHERE project_id = $1 AND status <> $2 AND last_alert_at >= $3
+		ORDER BY (deployment_id = $4) IS TRUE DESC, last_alert_at DESC
+		LIMIT 1`,
+		a.projectID, IncidentStatusResolved, a.createdAt.Add(-incidentCorrelationWindow), a.deploymentID,
+	).Scan(&incidentID, &severity, &status, &acknowledged)
+
+	switch {
+	case err == sql.ErrNoRows:
+		if incidentSeverityRank[a.severity] < incidentSeverityRank["medium"] {
+			return nil
+		}
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO incidents (project_id, deployment_id, title, description, severity, status,
+			                       started_at, last_alert_at, alert_count)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $7, 0)
+			RETURNING id`,
+			a.projectID, a.deploymentID, incidentTitle(a.alertType), a.message, a.severity,
+			IncidentStatusOpen, a.createdAt,
+		).Scan(&incidentID)
+		if err != nil {
+			return err
+		}
+		severity, status = a.severity, IncidentStatusOpen
+		logger.Info("Incident opened",
+			logger.String("incident_id", incidentID), logger.String("project_id", a.projectID))
+	case err != nil:
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO incident_alerts (incident_id, alert_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, incidentID, a.id); err != nil {
+		return err
+	}
+
+	if incidentSeverityRank[a.severity] > incidentSeverityRank[severity] {
+		severity = a.severity
+	}
+
+	// A new alert on a mitigated incident means the mitigation did not hold
+	newStatus := status
+	if status == IncidentStatusMitigated {
+		newStatus = IncidentStatusOpen
+		if acknowledged {
+			newStatus = IncidentStatusAcknowledged
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE incidents
+		SET alert_count = alert_count + 1,
+		    last_alert_at = GREATEST(last_alert_at, $1),
+		    severity = $2,
+		    status = $3,
+		    mitigated_at = CASE WHEN $3 = 'mitigated' THEN mitigated_at ELSE NULL END,
+		    updated_at = NOW()
+		WHERE id = $4`, a.createdAt, severity, newStatus, incidentID); err != nil {
+		return err
+	}
+
+	if err := insertIncidentEvent(ctx, tx, incidentID, "alert",
+		fmt.Sprintf("[%s] %s: %s", a.severity, a.alertType, a.message), "", a.id, a.createdAt); err != nil {
+		return err
+	}
+	if newStatus != status {
+		if err := insertIncidentEvent(ctx, tx, incidentID, "status_change",
+			fmt.Sprintf("Reopened as %s after a new alert", newStatus), "", "", time.Now()); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// advanceIncidents records resolved alerts on the timeline, marks incidents mitigated once
+// all their alerts cleared and resolves them after a quiet period
+func (im *IncidentManager) advanceIncidents(ctx context.Context) error {
+	db := im.orchestrator.db
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO incident_events (incident_id, event_type, description, source_id, occurred_at)
+		SELECT ia.incident_id, 'alert_resolved', 'Alert cleared: ' || a.alert_type, a.id, COALESCE(a.resolved_at, NOW())
+		FROM incident_alerts ia
+		JOIN deployment_alerts a ON a.id = ia.alert_id
+		JOIN incidents i ON i.id = ia.incident_id
+		WHERE a.resolved = true AND i.status <> 'resolved'
+		  AND NOT EXISTS (
+			SELECT 1 FROM incident_events e
+			WHERE e.incident_id = ia.incident_id AND e.source_id = a.id AND e.event_type = 'alert_resolved'
+		  )`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		UPDATE incidents i
+		SET status = 'mitigated', mitigated_at = NOW(), updated_at = NOW()
+		WHERE i.status IN ('open', 'acknowledged')
+		  AND NOT EXISTS (
+			SELECT 1 FROM incident_alerts ia
+			JOIN deployment_alerts a ON a.id = ia.alert_id
+			WHERE ia.incident_id = i.id AND a.resolved = false
+		  )
+		RETURNING i.id`)
+	if err != nil {
+		return err
+	}
+	mitigated := scanIDs(rows)
+	for _, id := range mitigated {
+		if err := insertIncidentEvent(ctx, db, id, "status_change", "Mitigated: all alerts cleared", "", "", time.Now()); err != nil {
+			logger.Error("Failed to record incident event", logger.Err(err))
+		}
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		UPDATE incidents
+		SET status = 'resolved', resolved_at = NOW(),
+		    acknowledged_at = COALESCE(acknowledged_at, NOW()), updated_at = NOW()
+		WHERE status = 'mitigated' AND mitigated_at < $1 AND last_alert_at < $1
+		RETURNING id`, time.Now().Add(-incidentAutoResolveAfter))
+	if err != nil {
+		return err
+	}
+	resolved := scanIDs(rows)
+	for _, id := range resolved {
+		if err := insertIncidentEvent(ctx, db, id, "status_change",
+			fmt.Sprintf("Resolved automatically after %s without new alerts", incidentAutoResolveAfter), "", "", time.Now()); err != nil {
+			logger.Error("Failed to record incident event", logger.Err(err))
+		}
+	}
+
+	if len(mitigated) > 0 || len(resolved) > 0 {
+		logger.Info("Incidents advanced", logger.Int("mitigated", len(mitigated)), logger.Int("resolved", len(resolved)))
+	}
+	return nil
+}
+
+// Transition moves an incident to a new status on behalf of a user. Acknowledging also
+// acknowledges the grouped alerts. Skipping acknowledgement counts the transition time
+// as the acknowledgement for MTTA.
+func (im *IncidentManager) Transition(ctx context.Context, incidentID, status, userID, rootCause, resolution string) (*models.Incident, error) {
+	incident, err := im.GetIncident(ctx, incidentID)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := false
+	for _, next := range incidentTransitions[incident.Status] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, incident.Status, status)
+	}
+
+	tx, err := im.orchestrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE incidents
+		SET status = $1,
+		    acknowledged_at = COALESCE(acknowledged_at, NOW()),
+		    acknowledged_by_user_id = COALESCE(acknowledged_by_user_id, $2::uuid),
+		    mitigated_at = CASE WHEN $1 IN ('mitigated', 'resolved') THEN COALESCE(mitigated_at, NOW()) ELSE mitigated_at END,
+		    resolved_at = CASE WHEN $1 = 'resolved' THEN NOW() ELSE resolved_at END,
+		    resolved_by_user_id = CASE WHEN $1 = 'resolved' THEN $2::uuid ELSE resolved_by_user_id END,
+		    root_cause = COALESCE(NULLIF($3, ''), root_cause),
+		    resolution = COALESCE(NULLIF($4, ''), resolution),
+		    updated_at = NOW()
+		WHERE id = $5 AND status = $6`,
+		status, nullIfEmpty(userID), rootCause, resolution, incidentID, incident.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == IncidentStatusAcknowledged {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE deployment_alerts
+			SET acknowledged = true, acknowledged_at = NOW(), acknowledged_by_user_id = $1::uuid
+			WHERE acknowledged = false AND id IN (SELECT alert_id FROM incident_alerts WHERE incident_id = $2)`,
+			nullIfEmpty(userID), incidentID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	description := fmt.Sprintf("Status changed from %s to %s", incident.Status, status)
+	if resolution != "" {
+		description += ": " + resolution
+	}
+	if err := insertIncidentEvent(ctx, tx, incidentID, "status_change", description, userID, "", time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Incident status changed",
+		logger.String("incident_id", incidentID), logger.String("status", status))
+	return im.GetIncident(ctx, incidentID)
+}
+
+// AddNote appends a user note to the incident timeline
+func (im *IncidentManager) AddNote(ctx context.Context, incidentID, userID, note string) error {
+	if _, err := im.GetIncident(ctx, incidentID); err != nil {
+		return err
+	}
+	return insertIncidentEvent(ctx, im.orchestrator.db, incidentID, "note", note, userID, "", time.Now())
+}
+
+const incidentColumns = `id, project_id, COALESCE(deployment_id::text, ''), title, COALESCE(description, ''),
+	severity, status, started_at, detected_at, acknowledged_at, mitigated_at, resolved_at, last_alert_at,
+	alert_count, assigned_to::text, COALESCE(root_cause, ''), COALESCE(resolution, ''), COALESCE(impact_summary, '')`
+
+func scanIncident(scan func(dest ...interface{}) error) (*models.Incident, error) {
+	var i models.Incident
+	var detectedAt, acknowledgedAt, mitigatedAt, resolvedAt sql.NullTime
+	var assignedTo sql.NullString
+	err := scan(&i.ID, &i.ProjectID, &i.DeploymentID, &i.Title, &i.Description,
+		&i.Severity, &i.Status, &i.StartedAt, &detectedAt, &acknowledgedAt, &mitigatedAt, &resolvedAt, &i.LastAlertAt,
+		&i.AlertCount, &assignedTo, &i.RootCause, &i.Resolution, &i.ImpactSummary)
+	if err != nil {
+		return nil, err
+	}
+	i.DetectedAt = nullTimePtr(detectedAt)
+	i.AcknowledgedAt = nullTimePtr(acknowledgedAt)
+	i.MitigatedAt = nullTimePtr(mitigatedAt)
+	i.ResolvedAt = nullTimePtr(resolvedAt)
+	if assignedTo.Valid {
+		i.AssignedTo = &assignedTo.String
+	}
+	return &i, nil
+}
+
+// ListIncidents returns recent incidents, optionally filtered by project and status
+func (im *IncidentManager) ListIncidents(ctx context.Context, projectID, status string, limit int) ([]*models.Incident, error) {
+	rows, err := im.orchestrator.db.QueryContext(ctx, `
+		SELECT `+incidentColumns+` FROM incidents
+		WHERE ($1 = '' OR project_id::text = $1) AND ($2 = '' OR status = $2)
+		ORDER BY started_at DESC
+		LIMIT $3`, projectID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var incidents []*models.Incident
+	for rows.Next() {
+		i, err := scanIncident(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, i)
+	}
+	return incidents, rows.Err()
+}
+
+// GetIncident returns an incident without its timeline
+func (im *IncidentManager) GetIncident(ctx context.Context, incidentID string) (*models.Incident, error) {
+	i, err := scanIncident(im.orchestrator.db.QueryRowContext(ctx, `
+		SELECT `+incidentColumns+` FROM incidents WHERE id = $1`, incidentID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrIncidentNotFound
+	}
+	return i, err
+}
+
+// GetIncidentWithTimeline returns an incident with its timeline: alerts, status changes and
+// notes, plus the project's deploys and rollbacks from shortly before the first alert
+// until resolution
+func (im *IncidentManager) GetIncidentWithTimeline(ctx context.Context, incidentID string) (*models.Incident, error) {
+	incident, err := im.GetIncident(ctx, incidentID)
+	if err != nil {
+		return nil, err
+	}
+
+	db := im.orchestrator.db
+	rows, err := db.QueryContext(ctx, `
+		SELECT e.occurred_at, e.event_type, e.description, COALESCE(u.email, '')
+		FROM incident_events e
+		LEFT JOIN users u ON u.id = e.user_id
+		WHERE e.incident_id = $1`, incidentID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		ev := models.IncidentEvent{Source: "incident"}
+		if err := rows.Scan(&ev.Timestamp, &ev.EventType, &ev.Description, &ev.User); err == nil {
+			incident.Timeline = append(incident.Timeline, ev)
+		}
+	}
+	rows.Close()
+
+	from := incident.StartedAt.Add(-incidentTimelineLookback)
+	to := time.Now()
+	if incident.ResolvedAt != nil {
+		to = *incident.ResolvedAt
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT d.created_at, d.environment, d.commit_hash, COALESCE(d.commit_message, ''), d.status,
+		       COALESCE(d.is_rollback, false)
+		FROM deployments d
+		WHERE d.project_id = $1 AND d.created_at BETWEEN $2 AND $3`, incident.ProjectID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var at time.Time
+		var environment, commit, message, status string
+		var isRollback bool
+		if err := rows.Scan(&at, &environment, &commit, &message, &status, &isRollback); err != nil {
+			continue
+		}
+		ev := models.IncidentEvent{
+			Timestamp:   at,
+			EventType:   "deploy",
+			Description: fmt.Sprintf("Deploy to %s of %s (%s): %s", environment, shortCommit(commit), status, message),
+			Source:      "deployment",
+		}
+		if isRollback {
+			ev.EventType = "rollback"
+			ev.Source = "rollback"
+		}
+		incident.Timeline = append(incident.Timeline, ev)
+	}
+	rows.Close()
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT r.created_at, r.reason, r.automatic, COALESCE(u.email, '')
+		FROM deployment_rollbacks r
+		JOIN deployments d ON d.id = r.from_deployment_id
+		LEFT JOIN users u ON u.id = r.initiated_by_user_id
+		WHERE d.project_id = $1 AND r.created_at BETWEEN $2 AND $3`, incident.ProjectID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var at time.Time
+		var reason, user string
+		var automatic bool
+		if err := rows.Scan(&at, &reason, &automatic, &user); err != nil {
+			continue
+		}
+		description := "Rollback: " + reason
+		if automatic {
+			description = "Automatic rollback: " + reason
+		}
+		incident.Timeline = append(incident.Timeline, models.IncidentEvent{
+			Timestamp: at, EventType: "rollback", Description: description, User: user, Source: "rollback",
+		})
+	}
+	rows.Close()
+
+	sort.SliceStable(incident.Timeline, func(a, b int) bool {
+		return incident.Timeline[a].Timestamp.Before(incident.Timeline[b].Timestamp)
+	})
+	return incident, nil
+}
+
+// GetIncidentStats returns MTTA and MTTR over incidents started since the given time
+func (im *IncidentManager) GetIncidentStats(ctx context.Context, projectID string, since time.Time) (*IncidentStats, error) {
+	var stats IncidentStats
+	var mtta, mttr sql.NullFloat64
+	err := im.orchestrator.db.QueryRowContext(ctx, `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE status <> 'resolved'),
+		       COUNT(*) FILTER (WHERE status = 'resolved'),
+		       AVG(EXTRACT(EPOCH FROM (acknowledged_at - started_at))) FILTER (WHERE acknowledged_at IS NOT NULL),
+		       AVG(EXTRACT(EPOCH FROM (resolved_at - started_at))) FILTER (WHERE resolved_at IS NOT NULL)
+		FROM incidents
+		WHERE ($1 = '' OR project_id::text = $1) AND started_at >= $2`, projectID, since,
+	).Scan(&stats.Total, &stats.Open, &stats.Resolved, &mtta, &mttr)
+	if err != nil {
+		return nil, err
+	}
+	if mtta.Valid {
+		d := time.Duration(mtta.Float64 * float64(time.Second))
+		stats.MeanTimeToAck = &d
+	}
+	if mttr.Valid {
+		d := time.Duration(mttr.Float64 * float64(time.Second))
+		stats.MeanTimeToResolve = &d
+	}
+	return &stats, nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertIncidentEvent(ctx context.Context, db execer, incidentID, eventType, description, userID, sourceID string, at time.Time) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO incident_events (incident_id, event_type, description, user_id, source_id, occurred_at)
+		VALUES ($1, $2, $3, $4::uuid, $5::uuid, $6)`,
+		incidentID, eventType, description, nullIfEmpty(userID), nullIfEmpty(sourceID), at)
+	return err
+}
+
+func scanIDs(rows *sql.Rows) []string {
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func incidentTitle(alertType string) string {
+	switch alertType {
+	case "health_check_failed":
+		return "Health checks failing"
+	case "high_error_rate":
+		return "Elevated error rate"
+	case "high_response_time":
+		return "Elevated response times"
+	case "cpu_threshold":
+		return "CPU saturation"
+	case "memory_limit":
+		return "Memory pressure"
+	case "slo_burn":
+		return "Error budget burning"
+	case "ssl_expiry":
+		return "TLS certificate expiring"
+	default:
+		return "Alert: " + alertType
+	}
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func shortCommit(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -4,19 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"monitoring-service/internal/logdrain"
 	"monitoring-service/pkg/db"
 	"monitoring-service/pkg/logger"
 	"monitoring-service/pkg/models"
 )
 
 type LogAggregator struct {
-	orchestrator     *Orchestrator
-	logStorage       *db.LogStorage
-	lastLogTimestamp map[string]time.Time // Track last seen log timestamp per deployment
+	orchestrator *Orchestrator
+	logStorage   *db.LogStorage
 }
 
 type LogEntry struct {
@@ -26,6 +27,7 @@ type LogEntry struct {
 	Level        string    `json:"level"`
 	Message      string    `json:"message"`
 	Source       string    `json:"source"`
+	Stream       string    `json:"stream"` // stdout or stderr
 	Metadata     string    `json:"metadata"`
 }
 
@@ -37,82 +39,42 @@ const (
 
 func NewLogAggregator(o *Orchestrator, logStorage *db.LogStorage) *LogAggregator {
 	return &LogAggregator{
-		orchestrator:     o,
-		logStorage:       logStorage,
-		lastLogTimestamp: make(map[string]time.Time),
+		orchestrator: o,
+		logStorage:   logStorage,
 	}
 }
 
-// AggregateAll collects logs from all active deployments
-func (la *LogAggregator) AggregateAll(ctx context.Context) error {
-	deployments, err := la.getActiveDeployments(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get active deployments: %w", err)
-	}
-
-	for _, deployment := range deployments {
-		if err := la.aggregateDeploymentLogs(ctx, deployment); err != nil {
-			log.Printf("Failed to aggregate logs for deployment %s: %v", deployment.ID, err)
+// ingest stores, publishes and checks one line read by the log shipper. Store and
+// publish: recent logs go to the database for fast queries and to Redis for live
+// streaming; the archival worker moves them to MinIO later. Every line goes through the
+// error tracker, which needs the lines after an error for its stack trace, and to the
+// project's log drains.
+func (la *LogAggregator) ingest(ctx context.Context, entry *LogEntry) {
+	if time.Since(entry.Timestamp) < db.LogRetentionDB {
+		if err := la.storeLogEntry(ctx, entry); err != nil {
+			logger.Error("Failed to store log entry in DB", logger.Err(err))
 		}
 	}
 
-	return nil
-}
-
-func (la *LogAggregator) aggregateDeploymentLogs(ctx context.Context, deployment *Deployment) error {
-	// Get last seen timestamp for this deployment
-	var since *time.Time
-	if lastTime, exists := la.lastLogTimestamp[deployment.ID]; exists {
-		since = &lastTime
+	if err := la.publishLogToRedis(ctx, entry); err != nil {
+		logger.Error("Failed to publish log to Redis", logger.Err(err))
 	}
 
-	// Get container logs (only new logs since last fetch)
-	logs, err := la.orchestrator.dockerClient.GetContainerLogs(ctx, deployment.ContainerID, 100, since)
-	if err != nil {
-		return fmt.Errorf("failed to get container logs: %w", err)
-	}
+	la.orchestrator.errorTracker.Observe(entry, la.isErrorLog(entry))
 
-	// Parse logs
-	var entries []*LogEntry
-	var newestTimestamp time.Time
-	for _, logLine := range logs {
-		entry := la.parseLogLine(deployment, logLine)
-		entries = append(entries, entry)
-		if entry.Timestamp.After(newestTimestamp) {
-			newestTimestamp = entry.Timestamp
-		}
+	record := logdrain.Record{
+		DeploymentID: entry.DeploymentID,
+		ContainerID:  entry.ContainerID,
+		Timestamp:    entry.Timestamp,
+		Level:        entry.Level,
+		Message:      entry.Message,
+		Source:       entry.Source,
+		Stream:       entry.Stream,
 	}
-
-	// Update last seen timestamp for this deployment
-	if !newestTimestamp.IsZero() {
-		la.lastLogTimestamp[deployment.ID] = newestTimestamp.Add(time.Second) // Add buffer to avoid edge cases
+	if entry.Metadata != "" && entry.Metadata != "{}" {
+		record.Metadata = json.RawMessage(entry.Metadata)
 	}
-
-	// Store logs using hybrid approach:
-	// 1. Recent logs -> Database (fast queries)
-	// 2. Real-time -> Redis pub/sub
-	// 3. Archive -> MinIO (runs separately via worker)
-
-	for _, entry := range entries {
-		// Store in database for recent queries (last 24h)
-		if time.Since(entry.Timestamp) < db.LogRetentionDB {
-			if err := la.storeLogEntry(ctx, entry); err != nil {
-				logger.Error("Failed to store log entry in DB", logger.Err(err))
-			}
-		}
-
-		// Publish to Redis for real-time streaming
-		if err := la.publishLogToRedis(ctx, entry); err != nil {
-			logger.Error("Failed to publish log to Redis", logger.Err(err))
-		}
-
-		// Check for error patterns
-		if la.isErrorLog(entry) {
-			la.handleErrorLog(ctx, entry)
-		}
-	}
-
-	return nil
+	la.orchestrator.logDrains.Enqueue(record)
 }
 
 func (la *LogAggregator) parseLogLine(deployment *Deployment, logLine string) *LogEntry {
@@ -188,31 +150,11 @@ func (la *LogAggregator) isErrorLog(entry *LogEntry) bool {
 	return entry.Level == "error" || entry.Level == "fatal"
 }
 
-func (la *LogAggregator) handleErrorLog(ctx context.Context, entry *LogEntry) {
-	// Increment error count in Redis
-	key := fmt.Sprintf("errors:%s:%s", entry.DeploymentID, time.Now().Format("2006-01-02-15"))
-	la.orchestrator.redis.Incr(ctx, key)
-	la.orchestrator.redis.Expire(ctx, key, 24*time.Hour)
-
-	// Check if error threshold exceeded
-	errorCount, _ := la.orchestrator.redis.Get(ctx, key).Int()
-	if errorCount > 10 {
-		// Trigger alert
-		la.orchestrator.alertManager.TriggerAlert(ctx, &models.Alert{
-			DeploymentID: entry.DeploymentID,
-			Severity:     "warning",
-			Title:        "High error rate detected",
-			Description:  fmt.Sprintf("Deployment has logged %d errors in the last hour", errorCount),
-			MetricType:   "error_rate",
-		})
-	}
-}
-
 func (la *LogAggregator) storeLogEntry(ctx context.Context, entry *LogEntry) error {
 	query := `
 		INSERT INTO logs_archive (
-			deployment_id, container_id, timestamp, level, message, source, metadata
-		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+			deployment_id, container_id, timestamp, level, message, source, stream, metadata
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		ON CONFLICT (container_id, timestamp, message) DO NOTHING
 	`
 
@@ -225,6 +167,7 @@ func (la *LogAggregator) storeLogEntry(ctx context.Context, entry *LogEntry) err
 		entry.Level,
 		entry.Message,
 		entry.Source,
+		entry.Stream,
 		entry.Metadata,
 	)
 
@@ -250,7 +193,7 @@ func (la *LogAggregator) GetRecentLogs(ctx context.Context, deploymentID string,
 	}
 
 	query := `
-		SELECT deployment_id, container_id, timestamp, level, message, source, metadata
+		SELECT deployment_id, container_id, timestamp, level, message, source, stream, metadata
 		FROM logs_archive
 		WHERE deployment_id = $1 AND timestamp > NOW() - INTERVAL '24 hours'
 		ORDER BY timestamp DESC
@@ -273,6 +216,7 @@ func (la *LogAggregator) GetRecentLogs(ctx context.Context, deploymentID string,
 			&entry.Level,
 			&entry.Message,
 			&entry.Source,
+			&entry.Stream,
 			&entry.Metadata,
 		); err != nil {
 			logger.Error("Failed to scan log entry", logger.Err(err))
@@ -301,38 +245,180 @@ func (la *LogAggregator) GetArchivedLogs(ctx context.Context, deploymentID strin
 			Level:        logs[i].Level,
 			Message:      logs[i].Message,
 			Source:       logs[i].Source,
+			Stream:       logs[i].Stream,
 		})
 	}
 
 	return result, nil
 }
 
-// ArchiveOldLogs moves logs older than 24h from DB to MinIO
-func (la *LogAggregator) ArchiveOldLogs(ctx context.Context) error {
-	cutoffTime := time.Now().Add(-db.LogRetentionDB)
+// SearchLogs returns a deployment's logs between start and end matching the query,
+// newest first. The query may be nil. Days past the deployment's hot retention are read
+// from the MinIO archive and matched in memory.
+func (la *LogAggregator) SearchLogs(ctx context.Context, deploymentID string, q *LogQuery, start, end time.Time, limit int) ([]*LogEntry, error) {
+	if limit <= 0 || limit > MaxDBLogsPerQuery {
+		limit = MaxDBLogsPerQuery
+	}
 
-	// Get logs to archive (older than 24h but not yet archived)
 	query := `
-		SELECT id, deployment_id, container_id, timestamp, level, message, source, metadata
+		SELECT deployment_id, container_id, timestamp, level, message, source, stream, COALESCE(metadata::text, '{}')
 		FROM logs_archive
-		WHERE timestamp < $1 AND archived_to_minio = false
-		ORDER BY deployment_id, timestamp
-		LIMIT $2
+		WHERE deployment_id = $1 AND timestamp >= $2 AND timestamp <= $3
+	`
+	args := []interface{}{deploymentID, start, end}
+	if q != nil {
+		cond, queryArgs := q.SQL(len(args))
+		query += " AND " + cond
+		args = append(args, queryArgs...)
+	}
+	query += fmt.Sprintf(" ORDER BY timestamp DESC LIMIT $%d", len(args)+1)
+	args = append(args, limit)
+
+	rows, err := la.orchestrator.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*LogEntry
+	for rows.Next() {
+		var entry LogEntry
+		if err := rows.Scan(
+			&entry.DeploymentID,
+			&entry.ContainerID,
+			&entry.Timestamp,
+			&entry.Level,
+			&entry.Message,
+			&entry.Source,
+			&entry.Stream,
+			&entry.Metadata,
+		); err != nil {
+			logger.Error("Failed to scan log entry", logger.Err(err))
+			continue
+		}
+		logs = append(logs, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search logs: %w", err)
+	}
+
+	// Only logs past the hot retention have been moved to the archive
+	cutoff := time.Now().Add(-la.orchestrator.retention.HotRetention(ctx, deploymentID))
+	if len(logs) >= limit || !start.Before(cutoff) {
+		return logs, nil
+	}
+	archiveEnd := end
+	if archiveEnd.After(cutoff) {
+		archiveEnd = cutoff
+	}
+
+	// Archives are daily files, read newest first until the limit is reached
+	startUTC := start.UTC()
+	firstDay := time.Date(startUTC.Year(), startUTC.Month(), startUTC.Day(), 0, 0, 0, 0, time.UTC)
+	for day := archiveEnd.UTC(); !day.Before(firstDay) && len(logs) < limit; day = day.AddDate(0, 0, -1) {
+		archived, err := la.logStorage.GetLogs(ctx, deploymentID, day, day)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get archived logs: %w", err)
+		}
+
+		var matched []*LogEntry
+		for i := range archived {
+			a := &archived[i]
+			if a.Timestamp.Before(start) || a.Timestamp.After(end) {
+				continue
+			}
+			row := map[string]string{
+				"container_id": a.ContainerID,
+				"level":        a.Level,
+				"message":      a.Message,
+				"source":       a.Source,
+				"stream":       a.Stream,
+			}
+			if q != nil && !q.Match(row, a.Metadata) {
+				continue
+			}
+
+			metadata := "{}"
+			if len(a.Metadata) > 0 {
+				if b, err := json.Marshal(a.Metadata); err == nil {
+					metadata = string(b)
+				}
+			}
+			matched = append(matched, &LogEntry{
+				DeploymentID: deploymentID,
+				ContainerID:  a.ContainerID,
+				Timestamp:    a.Timestamp,
+				Level:        a.Level,
+				Message:      a.Message,
+				Source:       a.Source,
+				Stream:       a.Stream,
+				Metadata:     metadata,
+			})
+		}
+
+		sort.Slice(matched, func(i, j int) bool { return matched[i].Timestamp.After(matched[j].Timestamp) })
+		logs = append(logs, matched...)
+	}
+
+	if len(logs) > limit {
+		logs = logs[:limit]
+	}
+	return logs, nil
+}
+
+// CountLogs returns how many of a deployment's logs within the window match the query.
+// Only logs still in the database are counted.
+func (la *LogAggregator) CountLogs(ctx context.Context, deploymentID string, q *LogQuery, window time.Duration) (*float64, error) {
+	query := `
+		SELECT COUNT(*) FROM logs_archive
+		WHERE deployment_id = $1 AND timestamp > NOW() - make_interval(secs => $2)
 	`
+	args := []interface{}{deploymentID, window.Seconds()}
+	if q != nil {
+		cond, queryArgs := q.SQL(len(args))
+		query += " AND " + cond
+		args = append(args, queryArgs...)
+	}
+
+	var count float64
+	if err := la.orchestrator.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count logs: %w", err)
+	}
+	return &count, nil
+}
 
-	rows, err := la.orchestrator.db.QueryContext(ctx, query, cutoffTime, db.MaxLogsPerBatch)
+// ArchiveOldLogs moves one batch of logs past their deployment's hot retention from the
+// DB to MinIO and returns the batch size. Deployments whose policy disables archiving
+// lose those logs, unless they are under a legal hold.
+func (la *LogAggregator) ArchiveOldLogs(ctx context.Context, job *ArchivalJob) (int, error) {
+	query := retentionCTE + `
+		SELECT l.id, l.deployment_id, l.container_id, l.timestamp, l.level, l.message, l.source, l.stream, l.metadata,
+		       r.archive_enabled OR r.held
+		FROM logs_archive l
+		JOIN deployment_retention r ON r.deployment_id = l.deployment_id
+		WHERE l.archived_to_minio = false
+		  AND l.timestamp < NOW() - make_interval(hours => r.hot_hours)
+		ORDER BY l.deployment_id, l.timestamp, l.id
+		LIMIT $4
+	`
+
+	rows, err := la.orchestrator.db.QueryContext(ctx, query,
+		job.EventType, defaultHotRetentionHours, defaultWarmRetentionDays, db.MaxLogsPerBatch)
 	if err != nil {
-		return fmt.Errorf("failed to query logs for archival: %w", err)
+		return 0, fmt.Errorf("failed to query logs for archival: %w", err)
 	}
 	defer rows.Close()
 
-	// Group logs by deployment and date
+	// Group logs by deployment and date. The order above keeps a re-run after a failed
+	// delete picking the same rows, which StoreLogs recognizes by their IDs.
 	logsByDeployment := make(map[string]map[string][]models.LogEntry)
-	var logIDs []int
+	var dropIDs []int
+	selected := 0
 
 	for rows.Next() {
 		var entry LogEntry
 		var id int
+		var archive bool
 		if err := rows.Scan(
 			&id,
 			&entry.DeploymentID,
@@ -341,13 +427,20 @@ func (la *LogAggregator) ArchiveOldLogs(ctx context.Context) error {
 			&entry.Level,
 			&entry.Message,
 			&entry.Source,
+			&entry.Stream,
 			&entry.Metadata,
+			&archive,
 		); err != nil {
 			logger.Error("Failed to scan log for archival", logger.Err(err))
 			continue
 		}
+		selected++
+
+		if !archive {
+			dropIDs = append(dropIDs, id)
+			continue
+		}
 
-		logIDs = append(logIDs, id)
 		date := entry.Timestamp.Format("2006-01-02")
 
 		if _, ok := logsByDeployment[entry.DeploymentID]; !ok {
@@ -363,66 +456,98 @@ func (la *LogAggregator) ArchiveOldLogs(ctx context.Context) error {
 		logsByDeployment[entry.DeploymentID][date] = append(
 			logsByDeployment[entry.DeploymentID][date],
 			models.LogEntry{
+				ID:           strconv.Itoa(id),
 				DeploymentID: entry.DeploymentID,
 				ContainerID:  entry.ContainerID,
 				Timestamp:    entry.Timestamp,
 				Level:        entry.Level,
 				Message:      entry.Message,
 				Source:       entry.Source,
+				Stream:       entry.Stream,
 				Metadata:     metadata,
 			},
 		)
 	}
+	if err := rows.Err(); err != nil {
+		return selected, fmt.Errorf("failed to query logs for archival: %w", err)
+	}
+	job.RecordsProcessed += selected
 
-	// Store logs to MinIO
+	// Store logs to MinIO; only rows that made it into a chunk are deleted
+	var logIDs []int
 	for deploymentID, dates := range logsByDeployment {
 		for date, logs := range dates {
 			d, _ := time.Parse("2006-01-02", date)
 			if err := la.logStorage.StoreLogs(ctx, deploymentID, d, logs); err != nil {
-				logger.Error("Failed to store logs to MinIO",
-					logger.String("deployment_id", deploymentID),
-					logger.String("date", date),
-					logger.Err(err),
-				)
+				job.fail(fmt.Sprintf("Failed to store logs of deployment %s for %s to MinIO", deploymentID, date), err)
 				continue
 			}
+			job.MinioObjectsCreated++
+			for _, entry := range logs {
+				id, _ := strconv.Atoi(entry.ID)
+				logIDs = append(logIDs, id)
+			}
 		}
 	}
 
 	// Delete archived logs from DB
 	if len(logIDs) > 0 {
-		// Use batch delete for performance
-		for i := 0; i < len(logIDs); i += 1000 {
-			end := i + 1000
-			if end > len(logIDs) {
-				end = len(logIDs)
-			}
-			batch := logIDs[i:end]
-
-			// Build delete query with IN clause
-			placeholders := make([]string, len(batch))
-			args := make([]interface{}, len(batch))
-			for j, id := range batch {
-				placeholders[j] = fmt.Sprintf("$%d", j+1)
-				args[j] = id
-			}
+		n, err := la.deleteLogRows(ctx, logIDs)
+		job.RecordsArchived += n
+		if err != nil {
+			job.fail("Failed to delete archived logs from DB", err)
+		}
+
+		logger.Info("Archived logs to MinIO",
+			logger.Int("count", n),
+		)
+	}
 
-			deleteQuery := fmt.Sprintf(
-				"DELETE FROM logs_archive WHERE id IN (%s)",
-				strings.Join(placeholders, ","),
-			)
+	if len(dropIDs) > 0 {
+		n, err := la.deleteLogRows(ctx, dropIDs)
+		job.RecordsDeleted += n
+		if err != nil {
+			job.fail("Failed to delete expired logs from DB", err)
+		}
+	}
 
-			if _, err := la.orchestrator.db.ExecContext(ctx, deleteQuery, args...); err != nil {
-				logger.Error("Failed to delete archived logs from DB", logger.Err(err))
-			}
+	return selected, nil
+}
+
+// deleteLogRows deletes logs_archive rows by ID and returns how many were deleted
+func (la *LogAggregator) deleteLogRows(ctx context.Context, ids []int) (int, error) {
+	deleted := 0
+
+	// Use batch delete for performance
+	for i := 0; i < len(ids); i += 1000 {
+		end := i + 1000
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[i:end]
+
+		// Build delete query with IN clause
+		placeholders := make([]string, len(batch))
+		args := make([]interface{}, len(batch))
+		for j, id := range batch {
+			placeholders[j] = fmt.Sprintf("$%d", j+1)
+			args[j] = id
 		}
 
-		logger.Info("Archived logs to MinIO",
-			logger.Int("count", len(logIDs)),
+		deleteQuery := fmt.Sprintf(
+			"DELETE FROM logs_archive WHERE id IN (%s)",
+			strings.Join(placeholders, ","),
 		)
+
+		res, err := la.orchestrator.db.ExecContext(ctx, deleteQuery, args...)
+		if err != nil {
+			return deleted, err
+		}
+		n, _ := res.RowsAffected()
+		deleted += int(n)
 	}
 
-	return nil
+	return deleted, nil
 }
 
 // StreamLogs streams logs in real-time for a deployment using Redis pub/sub
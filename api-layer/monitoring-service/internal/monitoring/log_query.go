@@ -0,0 +1,641 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+// Log queries search container and platform logs:
+//
+//	level:error AND message:"timeout" AND metadata.userId=42
+//	connection refused NOT source:build
+//	(level:error OR level:fatal) message:/dial tcp .*:5432/
+//	metadata.duration_ms>500 metadata.route:"/api/*"
+//
+// A bare word matches whole words of the message through the full-text index, a
+// quoted phrase matches anywhere in it through the trigram index. field:value compares
+// case-insensitively with * as wildcard, field:/re/ matches a regular expression and
+// field:* checks the field is set. = and != compare exactly; > >= < <= compare
+// metadata numbers. Terms are joined with AND, OR, NOT and parentheses, and adjacent
+// terms are ANDed. Operators must be upper case so that "not" and "or" stay searchable.
+
+const (
+	maxLogQueryLength = 1000
+	maxLogQueryDepth  = 32
+	maxLogQueryTerms  = 20
+)
+
+// LogQueryColumns maps the fields a query may use onto a log table
+type LogQueryColumns struct {
+	Fields   map[string]string // Field name to column
+	Message  string            // Column searched by bare words and phrases
+	Metadata string            // JSONB column addressed as metadata.<path>
+}
+
+// ContainerLogColumns are the fields of logs_archive and of archived container logs
+var ContainerLogColumns = LogQueryColumns{
+	Fields: map[string]string{
+		"level":     "level",
+		"severity":  "level",
+		"message":   "message",
+		"source":    "source",
+		"stream":    "stream",
+		"container": "container_id",
+	},
+	Message:  "message",
+	Metadata: "metadata",
+}
+
+// PlatformLogColumns are the fields of platform_log_events
+var PlatformLogColumns = LogQueryColumns{
+	Fields: map[string]string{
+		"level":     "severity",
+		"severity":  "severity",
+		"message":   "message",
+		"type":      "event_type",
+		"subtype":   "event_subtype",
+		"service":   "source_service",
+		"source":    "source_service",
+		"host":      "source_host",
+		"container": "container_name",
+	},
+	Message:  "message",
+	Metadata: "metadata",
+}
+
+type logTermKind int
+
+const (
+	logTermWords    logTermKind = iota // Full-text match of whole words
+	logTermContains                    // Case-insensitive substring with * wildcards
+	logTermLike                        // Case-insensitive whole value with * wildcards
+	logTermRegex
+	logTermExists
+	logTermCompare
+)
+
+type logQueryNode interface {
+	sql(b *logQuerySQL) string
+	match(row map[string]string, metadata map[string]interface{}) bool
+}
+
+type logAndNode struct{ l, r logQueryNode }
+type logOrNode struct{ l, r logQueryNode }
+type logNotNode struct{ x logQueryNode }
+
+type logTermNode struct {
+	kind   logTermKind
+	column string   // Set for table columns
+	path   []string // Set for metadata fields
+	op     string   // For logTermCompare
+	value  string
+	num    float64
+	re     *regexp.Regexp // Matches the value in memory for every kind but compare
+}
+
+// LogQuery is a parsed log query bound to the columns of one log table
+type LogQuery struct {
+	root logQueryNode
+	cols LogQueryColumns
+}
+
+// ParseLogQuery parses a query and checks its fields against the table's columns
+func ParseLogQuery(src string, cols LogQueryColumns) (*LogQuery, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	if len(src) > maxLogQueryLength {
+		return nil, fmt.Errorf("query must be at most %d characters", maxLogQueryLength)
+	}
+
+	tokens, err := lexLogQuery(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &logQueryParser{tokens: tokens, cols: cols}
+	root, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != logTokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+	}
+	return &LogQuery{root: root, cols: cols}, nil
+}
+
+// SQL returns the query as a WHERE condition whose placeholders are numbered after
+// argOffset, with their arguments
+func (q *LogQuery) SQL(argOffset int) (string, []interface{}) {
+	b := &logQuerySQL{cols: q.cols, argOffset: argOffset}
+	return q.root.sql(b), b.args
+}
+
+// Match evaluates the query in memory, for logs that are no longer in the database.
+// row holds the entry's values by column name.
+func (q *LogQuery) Match(row map[string]string, metadata map[string]interface{}) bool {
+	return q.root.match(row, metadata)
+}
+
+// SQL compilation
+
+type logQuerySQL struct {
+	cols      LogQueryColumns
+	argOffset int
+	args      []interface{}
+}
+
+func (b *logQuerySQL) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", b.argOffset+len(b.args))
+}
+
+func (n *logAndNode) sql(b *logQuerySQL) string {
+	return "(" + n.l.sql(b) + " AND " + n.r.sql(b) + ")"
+}
+
+func (n *logOrNode) sql(b *logQuerySQL) string {
+	return "(" + n.l.sql(b) + " OR " + n.r.sql(b) + ")"
+}
+
+func (n *logNotNode) sql(b *logQuerySQL) string {
+	// A term on a missing value is NULL, which NOT would keep out of the results
+	return "(" + n.x.sql(b) + ") IS NOT TRUE"
+}
+
+func (n *logTermNode) sql(b *logQuerySQL) string {
+	expr := n.column
+	var path string
+	if n.path != nil {
+		path = b.arg(pq.Array(n.path))
+		expr = fmt.Sprintf("(%s #>> %s::text[])", b.cols.Metadata, path)
+	}
+
+	switch n.kind {
+	case logTermWords:
+		return fmt.Sprintf("to_tsvector('simple', %s) @@ plainto_tsquery('simple', %s)", expr, b.arg(n.value))
+	case logTermContains:
+		return fmt.Sprintf("%s ILIKE %s", expr, b.arg("%"+likePattern(n.value)+"%"))
+	case logTermLike:
+		return fmt.Sprintf("%s ILIKE %s", expr, b.arg(likePattern(n.value)))
+	case logTermRegex:
+		return fmt.Sprintf("%s ~* %s", expr, b.arg(n.value))
+	case logTermExists:
+		return fmt.Sprintf("COALESCE(%s, '') <> ''", expr)
+	}
+
+	switch n.op {
+	case "=":
+		return fmt.Sprintf("%s = %s", expr, b.arg(n.value))
+	case "!=":
+		return fmt.Sprintf("%s IS DISTINCT FROM %s", expr, b.arg(n.value))
+	default:
+		// Non-numeric values are NULL rather than a failed cast
+		return fmt.Sprintf("(CASE WHEN jsonb_typeof(%s #> %s::text[]) = 'number' THEN %s::numeric END) %s %s",
+			b.cols.Metadata, path, expr, n.op, b.arg(n.num))
+	}
+}
+
+// likePattern escapes LIKE wildcards in a value and turns its * into %
+func likePattern(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	return r.Replace(value)
+}
+
+// In-memory evaluation
+
+func (n *logAndNode) match(row map[string]string, metadata map[string]interface{}) bool {
+	return n.l.match(row, metadata) && n.r.match(row, metadata)
+}
+
+func (n *logOrNode) match(row map[string]string, metadata map[string]interface{}) bool {
+	return n.l.match(row, metadata) || n.r.match(row, metadata)
+}
+
+func (n *logNotNode) match(row map[string]string, metadata map[string]interface{}) bool {
+	return !n.x.match(row, metadata)
+}
+
+func (n *logTermNode) match(row map[string]string, metadata map[string]interface{}) bool {
+	var value string
+	var found bool
+	var raw interface{}
+	if n.path != nil {
+		raw, found = metadataPath(metadata, n.path)
+		if found {
+			value, found = metadataText(raw)
+		}
+	} else {
+		value, found = row[n.column]
+	}
+
+	switch n.kind {
+	case logTermExists:
+		return found && value != ""
+	case logTermWords:
+		if !found {
+			return false
+		}
+		words := map[string]bool{}
+		for _, w := range logQueryWords(value) {
+			words[w] = true
+		}
+		for _, w := range logQueryWords(n.value) {
+			if !words[w] {
+				return false
+			}
+		}
+		return true
+	case logTermContains, logTermLike, logTermRegex:
+		return found && n.re.MatchString(value)
+	}
+
+	switch n.op {
+	case "=":
+		return found && value == n.value
+	case "!=":
+		return !found || value != n.value
+	}
+	num, ok := raw.(float64)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case ">":
+		return num > n.num
+	case ">=":
+		return num >= n.num
+	case "<":
+		return num < n.num
+	default:
+		return num <= n.num
+	}
+}
+
+// logQueryWords splits text into lower-case words the way the 'simple' text search
+// configuration does, closely enough for archived logs
+func logQueryWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func metadataPath(metadata map[string]interface{}, path []string) (interface{}, bool) {
+	var v interface{} = metadata
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
+// metadataText renders a metadata value as Postgres' #>> operator does
+func metadataText(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case nil:
+		return "", false
+	default:
+		b, err := json.Marshal(v)
+		return string(b), err == nil
+	}
+}
+
+// Lexer
+
+type logTokenKind int
+
+const (
+	logTokEOF logTokenKind = iota
+	logTokWord
+	logTokString
+	logTokRegex
+	logTokOp
+)
+
+type logToken struct {
+	kind logTokenKind
+	text string
+	pos  int
+}
+
+func isLogQuerySpecial(c byte) bool {
+	return strings.IndexByte(" \t\r\n()\"':=!<>", c) >= 0
+}
+
+func lexLogQuery(src string) ([]logToken, error) {
+	var tokens []logToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '(' || c == ')':
+			tokens = append(tokens, logToken{kind: logTokOp, text: string(c), pos: i})
+			i++
+
+		case c == '"' || c == '\'':
+			text, end, err := lexLogQuoted(src, i, c)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, logToken{kind: logTokString, text: text, pos: i})
+			i = end
+
+		case c == '/' && len(tokens) > 0 && tokens[len(tokens)-1].kind == logTokOp && tokens[len(tokens)-1].text == ":":
+			// Regular expressions are only values, so paths stay searchable as words
+			text, end, err := lexLogQuoted(src, i, '/')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, logToken{kind: logTokRegex, text: text, pos: i})
+			i = end
+
+		case c == ':' || c == '=' || c == '!' || c == '<' || c == '>':
+			op := string(c)
+			if i+1 < len(src) && src[i+1] == '=' && c != ':' && c != '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected character '!' at position %d, use NOT or !=", i)
+			}
+			tokens = append(tokens, logToken{kind: logTokOp, text: op, pos: i})
+			i += len(op)
+
+		default:
+			start := i
+			for i < len(src) && !isLogQuerySpecial(src[i]) {
+				i++
+			}
+			word := src[start:i]
+			switch word {
+			case "AND", "OR", "NOT":
+				tokens = append(tokens, logToken{kind: logTokOp, text: word, pos: start})
+			default:
+				tokens = append(tokens, logToken{kind: logTokWord, text: word, pos: start})
+			}
+		}
+	}
+	return append(tokens, logToken{kind: logTokEOF, pos: len(src)}), nil
+}
+
+// lexLogQuoted reads a string delimited by quote starting at src[start]. A backslash
+// escapes the delimiter; in regular expressions other escapes are kept as written.
+func lexLogQuoted(src string, start int, quote byte) (string, int, error) {
+	var sb strings.Builder
+	i := start + 1
+	for i < len(src) {
+		if src[i] == '\\' && i+1 < len(src) {
+			if quote == '/' && src[i+1] != '/' {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(src[i+1])
+			i += 2
+			continue
+		}
+		if src[i] == quote {
+			return sb.String(), i + 1, nil
+		}
+		sb.WriteByte(src[i])
+		i++
+	}
+	if quote == '/' {
+		return "", 0, fmt.Errorf("unterminated regular expression at position %d", start)
+	}
+	return "", 0, fmt.Errorf("unterminated string at position %d", start)
+}
+
+// Parser
+
+type logQueryParser struct {
+	tokens []logToken
+	pos    int
+	cols   LogQueryColumns
+	terms  int
+}
+
+func (p *logQueryParser) peek() logToken { return p.tokens[p.pos] }
+
+func (p *logQueryParser) next() logToken {
+	t := p.tokens[p.pos]
+	if t.kind != logTokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *logQueryParser) accept(op string) bool {
+	if t := p.peek(); t.kind == logTokOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *logQueryParser) parseOr(depth int) (logQueryNode, error) {
+	if depth > maxLogQueryDepth {
+		return nil, fmt.Errorf("query is nested too deeply")
+	}
+	l, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		r, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		l = &logOrNode{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *logQueryParser) parseAnd(depth int) (logQueryNode, error) {
+	l, err := p.parseNot(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		explicit := p.accept("AND")
+		t := p.peek()
+		startsTerm := t.kind == logTokWord || t.kind == logTokString ||
+			t.kind == logTokOp && (t.text == "(" || t.text == "NOT")
+		if !explicit && !startsTerm {
+			return l, nil
+		}
+		r, err := p.parseNot(depth)
+		if err != nil {
+			return nil, err
+		}
+		l = &logAndNode{l: l, r: r}
+	}
+}
+
+func (p *logQueryParser) parseNot(depth int) (logQueryNode, error) {
+	if p.accept("NOT") {
+		if depth > maxLogQueryDepth {
+			return nil, fmt.Errorf("query is nested too deeply")
+		}
+		x, err := p.parseNot(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &logNotNode{x: x}, nil
+	}
+	return p.parsePrimary(depth)
+}
+
+func (p *logQueryParser) parsePrimary(depth int) (logQueryNode, error) {
+	t := p.next()
+	switch {
+	case t.kind == logTokOp && t.text == "(":
+		x, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("expected \")\" at position %d", p.peek().pos)
+		}
+		return x, nil
+
+	case t.kind == logTokString:
+		return p.term(p.cols.Message, nil, logTermContains, t.text, "")
+
+	case t.kind == logTokWord:
+		if op := p.peek(); op.kind == logTokOp && isLogQueryFieldOp(op.text) {
+			p.next()
+			return p.parseFieldTerm(t, op)
+		}
+		return p.wordTerm(p.cols.Message, t.text)
+
+	case t.kind == logTokEOF:
+		return nil, fmt.Errorf("unexpected end of query")
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+	}
+}
+
+func isLogQueryFieldOp(op string) bool {
+	switch op {
+	case ":", "=", "!=", ">", ">=", "<", "<=":
+		return true
+	}
+	return false
+}
+
+// parseFieldTerm parses the value of field<op>value
+func (p *logQueryParser) parseFieldTerm(field, op logToken) (logQueryNode, error) {
+	column, path, err := p.resolveField(field)
+	if err != nil {
+		return nil, err
+	}
+
+	v := p.next()
+	if v.kind != logTokWord && v.kind != logTokString && v.kind != logTokRegex {
+		return nil, fmt.Errorf("expected a value after %s%s at position %d", field.text, op.text, v.pos)
+	}
+
+	switch op.text {
+	case ":":
+		switch {
+		case v.kind == logTokRegex:
+			return p.term(column, path, logTermRegex, v.text, "")
+		case v.kind == logTokWord && v.text == "*":
+			return p.term(column, path, logTermExists, "", "")
+		case column == p.cols.Message && v.kind == logTokString:
+			return p.term(column, path, logTermContains, v.text, "")
+		case column == p.cols.Message:
+			return p.wordTerm(column, v.text)
+		default:
+			return p.term(column, path, logTermLike, v.text, "")
+		}
+	case "=", "!=":
+		return p.term(column, path, logTermCompare, v.text, op.text)
+	default:
+		if path == nil {
+			return nil, fmt.Errorf("%s: only metadata fields can be compared with %s", field.text, op.text)
+		}
+		if _, err := strconv.ParseFloat(v.text, 64); err != nil || v.kind != logTokWord {
+			return nil, fmt.Errorf("%s%s needs a number, got %q", field.text, op.text, v.text)
+		}
+		return p.term(column, path, logTermCompare, v.text, op.text)
+	}
+}
+
+// wordTerm searches the message for a word. Words with wildcards cannot use the
+// full-text index and fall back to a substring match.
+func (p *logQueryParser) wordTerm(column, word string) (logQueryNode, error) {
+	if strings.Contains(word, "*") || len(logQueryWords(word)) == 0 {
+		return p.term(column, nil, logTermContains, word, "")
+	}
+	return p.term(column, nil, logTermWords, word, "")
+}
+
+func (p *logQueryParser) resolveField(t logToken) (string, []string, error) {
+	name := strings.ToLower(t.text)
+	if strings.HasPrefix(name, "metadata.") && p.cols.Metadata != "" {
+		path := strings.Split(t.text[len("metadata."):], ".")
+		for _, key := range path {
+			if key == "" {
+				return "", nil, fmt.Errorf("invalid metadata field %q at position %d", t.text, t.pos)
+			}
+		}
+		return "", path, nil
+	}
+	if column, ok := p.cols.Fields[name]; ok {
+		return column, nil, nil
+	}
+	return "", nil, fmt.Errorf("unknown field %q at position %d", t.text, t.pos)
+}
+
+func (p *logQueryParser) term(column string, path []string, kind logTermKind, value, op string) (logQueryNode, error) {
+	p.terms++
+	if p.terms > maxLogQueryTerms {
+		return nil, fmt.Errorf("query can have at most %d terms", maxLogQueryTerms)
+	}
+
+	n := &logTermNode{kind: kind, column: column, path: path, op: op, value: value}
+	var err error
+	switch kind {
+	case logTermContains:
+		n.re, err = regexp.Compile("(?is)" + wildcardRegexp(value))
+	case logTermLike:
+		n.re, err = regexp.Compile("(?is)^" + wildcardRegexp(value) + "$")
+	case logTermRegex:
+		n.re, err = regexp.Compile("(?i)" + value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", value, err)
+		}
+	case logTermCompare:
+		if op != "=" && op != "!=" {
+			n.num, err = strconv.ParseFloat(value, 64)
+		}
+	}
+	return n, err
+}
+
+func wildcardRegexp(value string) string {
+	parts := strings.Split(value, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return strings.Join(parts, ".*")
+}
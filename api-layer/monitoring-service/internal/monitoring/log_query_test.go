@@ -0,0 +1,273 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestParseLogQuerySQL(t *testing.T) {
+	for _, tc := range []struct {
+		query    string
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{"level:error", "level ILIKE $1", []interface{}{"error"}},
+		{"severity:ERR*", "level ILIKE $1", []interface{}{"ERR%"}},
+		{"connection refused",
+			"(to_tsvector('simple', message) @@ plainto_tsquery('simple', $1) AND to_tsvector('simple', message) @@ plainto_tsquery('simple', $2))",
+			[]interface{}{"connection", "refused"}},
+		// Lower-case operators are words to search for
+		{"not found",
+			"(to_tsvector('simple', message) @@ plainto_tsquery('simple', $1) AND to_tsvector('simple', message) @@ plainto_tsquery('simple', $2))",
+			[]interface{}{"not", "found"}},
+		{`"time out"`, "message ILIKE $1", []interface{}{"%time out%"}},
+		{`message:"100%_done"`, "message ILIKE $1", []interface{}{`%100\%\_done%`}},
+		{"refus*", "message ILIKE $1", []interface{}{"%refus%%"}},
+		{"NOT source:build", "(source ILIKE $1) IS NOT TRUE", []interface{}{"build"}},
+		{"(level:error OR level:fatal) message:/dial tcp .*:5432/",
+			"((level ILIKE $1 OR level ILIKE $2) AND message ~* $3)",
+			[]interface{}{"error", "fatal", "dial tcp .*:5432"}},
+		// AND binds tighter than OR
+		{"level:error OR level:fatal AND source:app",
+			"(level ILIKE $1 OR (level ILIKE $2 AND source ILIKE $3))",
+			[]interface{}{"error", "fatal", "app"}},
+		{`message:/a\/b\d/`, "message ~* $1", []interface{}{`a/b\d`}},
+		{"stream!=stderr", "stream IS DISTINCT FROM $1", []interface{}{"stderr"}},
+		{"container=abc", "container_id = $1", []interface{}{"abc"}},
+		{"metadata.userId=42", "(metadata #>> $1::text[]) = $2",
+			[]interface{}{pq.Array([]string{"userId"}), "42"}},
+		{"metadata.duration_ms>500",
+			"(CASE WHEN jsonb_typeof(metadata #> $1::text[]) = 'number' THEN (metadata #>> $1::text[])::numeric END) > $2",
+			[]interface{}{pq.Array([]string{"duration_ms"}), 500.0}},
+		{`metadata.route:"/api/*"`, "(metadata #>> $1::text[]) ILIKE $2",
+			[]interface{}{pq.Array([]string{"route"}), "/api/%"}},
+		{"metadata.nested.ok:*", "COALESCE((metadata #>> $1::text[]), '') <> ''",
+			[]interface{}{pq.Array([]string{"nested", "ok"})}},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			q, err := ParseLogQuery(tc.query, ContainerLogColumns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotSQL, gotArgs := q.SQL(0)
+			if gotSQL != tc.wantSQL {
+				t.Errorf("SQL\n  %s\nwant\n  %s", gotSQL, tc.wantSQL)
+			}
+			if !reflect.DeepEqual(gotArgs, tc.wantArgs) {
+				t.Errorf("args %#v, want %#v", gotArgs, tc.wantArgs)
+			}
+		})
+	}
+}
+
+func TestLogQuerySQLArgOffset(t *testing.T) {
+	q, err := ParseLogQuery("type:deployment AND service:deploy*", PlatformLogColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotSQL, gotArgs := q.SQL(3)
+	if want := "(event_type ILIKE $4 AND source_service ILIKE $5)"; gotSQL != want {
+		t.Fatalf("SQL %s, want %s", gotSQL, want)
+	}
+	if len(gotArgs) != 2 {
+		t.Fatalf("%d args, want 2", len(gotArgs))
+	}
+}
+
+func TestParseLogQueryErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{"empty", " ", "query is required"},
+		{"too long", strings.Repeat("a", maxLogQueryLength+1), "query must be at most"},
+		{"unknown field", "user:bob", `unknown field "user"`},
+		{"empty metadata key", "metadata.a..b:1", "invalid metadata field"},
+		{"compared column", "level>3", "only metadata fields can be compared"},
+		{"compared text", "metadata.duration_ms>fast", "needs a number"},
+		{"compared string", `metadata.duration_ms>"5"`, "needs a number"},
+		{"missing value", "level:", "expected a value after level:"},
+		{"bare bang", "a ! b", "use NOT or !="},
+		{"unterminated string", `"timeout`, "unterminated string"},
+		{"unterminated regex", "message:/dial", "unterminated regular expression"},
+		{"invalid regex", "message:/(/", "invalid regular expression"},
+		{"unbalanced", "(level:error", `expected ")"`},
+		{"dangling operator", "level:error OR", "unexpected end of query"},
+		{"stray parenthesis", "level:error)", `unexpected ")"`},
+		{"too many terms", strings.Repeat("word ", maxLogQueryTerms+1), "at most 20 terms"},
+		{"nested parentheses", strings.Repeat("(", maxLogQueryDepth+1) + "a" + strings.Repeat(")", maxLogQueryDepth+1),
+			"query is nested too deeply"},
+		{"stacked NOT", strings.Repeat("NOT ", maxLogQueryDepth+2) + "a", "query is nested too deeply"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseLogQuery(tc.query, ContainerLogColumns)
+			if err == nil {
+				t.Fatalf("parsed, want an error containing %q", tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error %q, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// logQueryRows are container log entries; a missing column is NULL
+var logQueryRows = []struct {
+	row      map[string]string
+	metadata string
+}{
+	{map[string]string{"level": "error", "message": "connection refused by db", "source": "app"},
+		`{"userId": 42, "duration_ms": 750, "route": "/api/users", "nested": {"ok": true}}`},
+	{map[string]string{"level": "info", "message": "request completed in 20ms", "source": "build"},
+		`{"userId": "42", "duration_ms": "slow", "route": "/health"}`},
+	{map[string]string{"level": "fatal", "message": "dial tcp 10.0.0.5:5432: timeout", "source": "app"},
+		`{}`},
+	{map[string]string{"level": "warn", "message": "Not found: 100%_done", "source": "app", "stream": "stderr"},
+		`{"duration_ms": 500, "nested": {"ok": null}}`},
+}
+
+// logQueryMatches lists the rows each query selects in Postgres
+var logQueryMatches = []struct {
+	query string
+	want  []int
+}{
+	{"level:error", []int{0}},
+	{"level:ERR*", []int{0}},
+	{"connection refused", []int{0}},
+	{"refused connection", []int{0}},
+	{"not found", []int{3}},
+	{`"timeout"`, []int{2}},
+	{`"TCP 10.0"`, []int{2}},
+	{`message:"100%_done"`, []int{3}},
+	{`message:"100%?done"`, nil},
+	{"refus*", []int{0}},
+	{"NOT source:build", []int{0, 2, 3}},
+	{"(level:error OR level:fatal) message:/dial tcp .*:5432/", []int{2}},
+	{"level:error OR level:fatal AND source:build", []int{0}},
+	{"stream:stderr", []int{3}},
+	{"NOT stream:stderr", []int{0, 1, 2}},
+	{"stream!=stderr", []int{0, 1, 2}},
+	{"stream:*", []int{3}},
+	{"metadata.userId=42", []int{0, 1}},
+	{"metadata.userId!=42", []int{2, 3}},
+	{"metadata.duration_ms>500", []int{0}},
+	{"metadata.duration_ms>=500", []int{0, 3}},
+	{"NOT metadata.duration_ms<600", []int{0, 1, 2}},
+	{`metadata.route:"/api/*"`, []int{0}},
+	{"metadata.nested.ok:*", []int{0}},
+	{"metadata.nested.ok=true", []int{0}},
+	{"metadata.nested:*", []int{0, 3}},
+}
+
+func matchingRows(t *testing.T, q *LogQuery) []int {
+	t.Helper()
+	var matched []int
+	for i, r := range logQueryRows {
+		var metadata map[string]interface{}
+		if err := json.Unmarshal([]byte(r.metadata), &metadata); err != nil {
+			t.Fatal(err)
+		}
+		if q.Match(r.row, metadata) {
+			matched = append(matched, i)
+		}
+	}
+	return matched
+}
+
+func TestLogQueryMatch(t *testing.T) {
+	for _, tc := range logQueryMatches {
+		t.Run(tc.query, func(t *testing.T) {
+			q, err := ParseLogQuery(tc.query, ContainerLogColumns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := matchingRows(t, q); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("matched rows %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestLogQueryMatchAgreesWithSQL runs the same queries against Postgres, so archived
+// logs are filtered like the live ones. It needs LOG_QUERY_TEST_DATABASE_URL.
+func TestLogQueryMatchAgreesWithSQL(t *testing.T) {
+	dsn := os.Getenv("LOG_QUERY_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("LOG_QUERY_TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Temporary tables live in one session
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TEMP TABLE log_query_test (
+			id INT, level TEXT, message TEXT, source TEXT, stream TEXT, container_id TEXT, metadata JSONB
+		)`); err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range logQueryRows {
+		if _, err := conn.ExecContext(ctx, `
+			INSERT INTO log_query_test (id, level, message, source, stream, container_id, metadata)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			i, nullIfMissing(r.row, "level"), nullIfMissing(r.row, "message"), nullIfMissing(r.row, "source"),
+			nullIfMissing(r.row, "stream"), nullIfMissing(r.row, "container_id"), r.metadata); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range logQueryMatches {
+		t.Run(tc.query, func(t *testing.T) {
+			q, err := ParseLogQuery(tc.query, ContainerLogColumns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			where, args := q.SQL(0)
+			rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT id FROM log_query_test WHERE %s ORDER BY id", where), args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+
+			var selected []int
+			for rows.Next() {
+				var id int
+				if err := rows.Scan(&id); err != nil {
+					t.Fatal(err)
+				}
+				selected = append(selected, id)
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if matched := matchingRows(t, q); !reflect.DeepEqual(selected, matched) {
+				t.Fatalf("SQL selected %v, Match matched %v", selected, matched)
+			}
+		})
+	}
+}
+
+func nullIfMissing(row map[string]string, column string) sql.NullString {
+	v, ok := row[column]
+	return sql.NullString{String: v, Valid: ok}
+}
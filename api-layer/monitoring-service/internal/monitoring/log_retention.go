@@ -0,0 +1,475 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"monitoring-service/pkg/db"
+	"monitoring-service/pkg/logger"
+
+	"github.com/lib/pq"
+)
+
+// DeploymentLogEventType is the policy event type of deployment container logs, which
+// live in logs_archive rather than platform_log_events
+const DeploymentLogEventType = "container"
+
+// Each event type is archived in batches of db.MaxLogsPerBatch, up to this many per run
+const retentionMaxBatches = 20
+
+// Policy values used when log_retention_policies has no row for an event type
+var (
+	defaultHotRetentionHours = int(db.LogRetentionDB / time.Hour)
+	defaultWarmRetentionDays = int(db.LogRetentionMinIO / (24 * time.Hour))
+)
+
+// activeLegalHold selects the holds h currently in force
+const activeLegalHold = `h.released_at IS NULL AND (h.expires_at IS NULL OR h.expires_at > NOW())`
+
+// retentionCTE resolves the policy of event type $1 for every project and deployment.
+// A project's own policy wins over its plan's, which wins over the default row; $2 and
+// $3 are the hot hours and warm days when the event type has no policy at all.
+const retentionCTE = `
+	WITH project_retention AS (
+		SELECT DISTINCT ON (p.id)
+		       p.id AS project_id,
+		       COALESCE(rp.hot_retention_hours, $2) AS hot_hours,
+		       COALESCE(rp.warm_retention_days, $3) AS warm_days,
+		       COALESCE(rp.archive_enabled, true) AS archive_enabled,
+		       EXISTS (
+		           SELECT 1 FROM log_legal_holds h
+		           WHERE h.project_id = p.id AND h.deployment_id IS NULL AND ` + activeLegalHold + `
+		       ) AS held
+		FROM projects p
+		LEFT JOIN subscriptions s ON s.company_id = p.company_id AND s.status = 'active'
+		LEFT JOIN log_retention_policies rp ON rp.event_type = $1
+		 AND (rp.project_id = p.id OR rp.plan_id = s.plan_id OR (rp.project_id IS NULL AND rp.plan_id IS NULL))
+		ORDER BY p.id, rp.project_id IS NULL, rp.plan_id IS NULL
+	),
+	deployment_retention AS (
+		SELECT d.id AS deployment_id, r.hot_hours, r.warm_days, r.archive_enabled,
+		       r.held OR EXISTS (
+		           SELECT 1 FROM log_legal_holds h
+		           WHERE h.deployment_id = d.id AND ` + activeLegalHold + `
+		       ) AS held
+		FROM deployments d
+		JOIN project_retention r ON r.project_id = d.project_id
+	)`
+
+// ArchivalJob is one run of the retention engine for an event type, recorded in
+// log_archival_jobs
+type ArchivalJob struct {
+	ID                  string
+	EventType           string
+	RecordsProcessed    int // Rows past their hot retention
+	RecordsArchived     int // Rows moved to MinIO
+	RecordsDeleted      int // Rows dropped without an archive copy
+	MinioObjectsCreated int
+	MinioObjectsDeleted int
+	Errors              []string
+}
+
+func (j *ArchivalJob) fail(msg string, err error) {
+	logger.Error(msg, logger.String("event_type", j.EventType), logger.Err(err))
+	j.Errors = append(j.Errors, fmt.Sprintf("%s: %v", msg, err))
+}
+
+// RetentionEngine applies log_retention_policies. Rows past their hot retention leave
+// the database for MinIO, or are dropped when the policy disables archiving; archives
+// past their warm retention are deleted. Anything under an active legal hold is never
+// dropped or deleted, only archived.
+type RetentionEngine struct {
+	orchestrator *Orchestrator
+	aggregator   *LogAggregator
+	logStorage   *db.LogStorage
+}
+
+func NewRetentionEngine(o *Orchestrator, aggregator *LogAggregator, logStorage *db.LogStorage) *RetentionEngine {
+	return &RetentionEngine{
+		orchestrator: o,
+		aggregator:   aggregator,
+		logStorage:   logStorage,
+	}
+}
+
+// Run applies the policy of every event type and records one archival job for each
+func (re *RetentionEngine) Run(ctx context.Context) error {
+	eventTypes, err := re.eventTypes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list log event types: %w", err)
+	}
+
+	failed := 0
+	for _, eventType := range eventTypes {
+		job, err := re.startJob(ctx, eventType)
+		if err != nil {
+			return fmt.Errorf("failed to record archival job: %w", err)
+		}
+
+		if eventType == DeploymentLogEventType {
+			re.applyDeploymentLogs(ctx, job)
+		}
+		re.applyPlatformEvents(ctx, job)
+
+		re.finishJob(job)
+		if len(job.Errors) > 0 {
+			failed++
+		}
+
+		logger.Info("Applied log retention",
+			logger.String("event_type", eventType),
+			logger.Int("processed", job.RecordsProcessed),
+			logger.Int("archived", job.RecordsArchived),
+			logger.Int("deleted", job.RecordsDeleted),
+			logger.Int("objects_created", job.MinioObjectsCreated),
+			logger.Int("objects_deleted", job.MinioObjectsDeleted))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("log retention failed for %d of %d event types", failed, len(eventTypes))
+	}
+	return nil
+}
+
+// HotRetention returns how long a deployment's logs stay in the database
+func (re *RetentionEngine) HotRetention(ctx context.Context, deploymentID string) time.Duration {
+	var hours int
+	err := re.orchestrator.db.QueryRowContext(ctx, `
+		SELECT COALESCE(rp.hot_retention_hours, $2)
+		FROM deployments d
+		JOIN projects p ON p.id = d.project_id
+		LEFT JOIN subscriptions s ON s.company_id = p.company_id AND s.status = 'active'
+		LEFT JOIN log_retention_policies rp ON rp.event_type = $1
+		 AND (rp.project_id = p.id OR rp.plan_id = s.plan_id OR (rp.project_id IS NULL AND rp.plan_id IS NULL))
+		WHERE d.id = $3
+		ORDER BY rp.project_id IS NULL, rp.plan_id IS NULL
+		LIMIT 1`,
+		DeploymentLogEventType, defaultHotRetentionHours, deploymentID,
+	).Scan(&hours)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logger.Error("Failed to resolve log retention", logger.String("deployment_id", deploymentID), logger.Err(err))
+		}
+		return db.LogRetentionDB
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func (re *RetentionEngine) eventTypes(ctx context.Context) ([]string, error) {
+	rows, err := re.orchestrator.db.QueryContext(ctx, `
+		SELECT $1::varchar
+		UNION SELECT event_type FROM log_retention_policies
+		UNION SELECT DISTINCT event_type FROM platform_log_events
+		ORDER BY 1`, DeploymentLogEventType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var eventTypes []string
+	for rows.Next() {
+		var eventType string
+		if err := rows.Scan(&eventType); err != nil {
+			return nil, err
+		}
+		eventTypes = append(eventTypes, eventType)
+	}
+	return eventTypes, rows.Err()
+}
+
+func (re *RetentionEngine) startJob(ctx context.Context, eventType string) (*ArchivalJob, error) {
+	job := &ArchivalJob{EventType: eventType}
+	err := re.orchestrator.db.QueryRowContext(ctx, `
+		INSERT INTO log_archival_jobs (event_type, status)
+		VALUES ($1, 'running')
+		RETURNING id`, eventType,
+	).Scan(&job.ID)
+	return job, err
+}
+
+func (re *RetentionEngine) finishJob(job *ArchivalJob) {
+	status := "completed"
+	var errorMessage interface{}
+	if len(job.Errors) > 0 {
+		status = "failed"
+		errorMessage = strings.Join(job.Errors, "; ")
+	}
+
+	// The run's context may be done by now; the job must still be closed
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := re.orchestrator.db.ExecContext(ctx, `
+		UPDATE log_archival_jobs
+		SET completed_at = NOW(),
+		    records_processed = $2,
+		    records_archived = $3,
+		    records_deleted = $4,
+		    minio_objects_created = $5,
+		    minio_objects_deleted = $6,
+		    status = $7,
+		    error_message = $8
+		WHERE id = $1`,
+		job.ID, job.RecordsProcessed, job.RecordsArchived, job.RecordsDeleted,
+		job.MinioObjectsCreated, job.MinioObjectsDeleted, status, errorMessage)
+	if err != nil {
+		logger.Error("Failed to record archival job", logger.String("job_id", job.ID), logger.Err(err))
+	}
+}
+
+// applyDeploymentLogs archives logs_archive rows past their hot retention and deletes
+// archived days past their warm retention
+func (re *RetentionEngine) applyDeploymentLogs(ctx context.Context, job *ArchivalJob) {
+	for i := 0; i < retentionMaxBatches; i++ {
+		errorsBefore := len(job.Errors)
+		n, err := re.aggregator.ArchiveOldLogs(ctx, job)
+		if err != nil {
+			job.fail("Failed to archive deployment logs", err)
+			break
+		}
+		// Rows that failed to archive are selected again; retry them next run
+		if n < db.MaxLogsPerBatch || len(job.Errors) > errorsBefore {
+			break
+		}
+	}
+
+	rows, err := re.orchestrator.db.QueryContext(ctx, retentionCTE+`
+		SELECT deployment_id, warm_days FROM deployment_retention WHERE NOT held`,
+		job.EventType, defaultHotRetentionHours, defaultWarmRetentionDays)
+	if err != nil {
+		job.fail("Failed to resolve deployment log retention", err)
+		return
+	}
+
+	warmDays := make(map[string]int)
+	for rows.Next() {
+		var deploymentID string
+		var days int
+		if err := rows.Scan(&deploymentID, &days); err != nil {
+			rows.Close()
+			job.fail("Failed to resolve deployment log retention", err)
+			return
+		}
+		warmDays[deploymentID] = days
+	}
+	rows.Close()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for deploymentID, days := range warmDays {
+		// Whole days only: a day is deleted once all of it is past the retention
+		n, err := re.logStorage.DeleteLogs(ctx, deploymentID, today.AddDate(0, 0, -days))
+		job.MinioObjectsDeleted += n
+		if err != nil {
+			job.fail(fmt.Sprintf("Failed to delete archived logs of deployment %s", deploymentID), err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// archivedPlatformEvent is the archived form of a platform_log_events row, in the
+// shape the platform logs API returns
+type archivedPlatformEvent struct {
+	ID             string          `json:"id"`
+	EventType      string          `json:"event_type"`
+	EventSubtype   string          `json:"event_subtype"`
+	ResourceType   string          `json:"resource_type"`
+	ResourceID     string          `json:"resource_id"`
+	ProjectID      string          `json:"project_id,omitempty"`
+	CompanyID      string          `json:"company_id,omitempty"`
+	ContainerID    string          `json:"container_id,omitempty"`
+	ContainerName  string          `json:"container_name,omitempty"`
+	Severity       string          `json:"severity"`
+	Message        string          `json:"message"`
+	Metadata       json.RawMessage `json:"metadata,omitempty"`
+	SourceService  string          `json:"source_service"`
+	SourceHost     string          `json:"source_host,omitempty"`
+	EventTimestamp time.Time       `json:"event_timestamp"`
+}
+
+// applyPlatformEvents moves platform events past their hot retention to MinIO and
+// deletes archived days past their warm retention
+func (re *RetentionEngine) applyPlatformEvents(ctx context.Context, job *ArchivalJob) {
+	for i := 0; i < retentionMaxBatches; i++ {
+		errorsBefore := len(job.Errors)
+		n, err := re.archivePlatformEvents(ctx, job)
+		if err != nil {
+			job.fail("Failed to archive platform events", err)
+			break
+		}
+		if n < db.MaxLogsPerBatch || len(job.Errors) > errorsBefore {
+			break
+		}
+	}
+
+	rows, err := re.orchestrator.db.QueryContext(ctx, retentionCTE+`
+		SELECT project_id, warm_days, held FROM project_retention`,
+		job.EventType, defaultHotRetentionHours, defaultWarmRetentionDays)
+	if err != nil {
+		job.fail("Failed to resolve platform event retention", err)
+		return
+	}
+
+	type projectPolicy struct {
+		warmDays int
+		held     bool
+	}
+	policies := make(map[string]projectPolicy)
+	for rows.Next() {
+		var projectID string
+		var p projectPolicy
+		if err := rows.Scan(&projectID, &p.warmDays, &p.held); err != nil {
+			rows.Close()
+			job.fail("Failed to resolve platform event retention", err)
+			return
+		}
+		policies[projectID] = p
+	}
+	rows.Close()
+
+	// Events without a project follow the default policy
+	systemWarmDays := defaultWarmRetentionDays
+	err = re.orchestrator.db.QueryRowContext(ctx, `
+		SELECT warm_retention_days FROM log_retention_policies
+		WHERE event_type = $1 AND project_id IS NULL AND plan_id IS NULL AND warm_retention_days IS NOT NULL`,
+		job.EventType,
+	).Scan(&systemWarmDays)
+	if err != nil && err != sql.ErrNoRows {
+		job.fail("Failed to resolve platform event retention", err)
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	n, err := re.logStorage.DeletePlatformLogs(ctx, job.EventType, func(projectID string, day time.Time) bool {
+		warmDays := systemWarmDays
+		if projectID != db.PlatformLogsNoProject {
+			p, ok := policies[projectID]
+			// Archives of deleted projects have no hold to honor
+			if ok && p.held {
+				return false
+			}
+			if ok {
+				warmDays = p.warmDays
+			}
+		}
+		return day.Before(today.AddDate(0, 0, -warmDays))
+	})
+	job.MinioObjectsDeleted += n
+	if err != nil {
+		job.fail("Failed to delete archived platform events", err)
+	}
+}
+
+// archivePlatformEvents handles one batch of events past their hot retention and
+// returns its size
+func (re *RetentionEngine) archivePlatformEvents(ctx context.Context, job *ArchivalJob) (int, error) {
+	rows, err := re.orchestrator.db.QueryContext(ctx, retentionCTE+`
+		SELECT e.id, e.event_subtype, e.resource_type, e.resource_id,
+		       COALESCE(e.project_id::text, ''), COALESCE(e.company_id::text, ''),
+		       COALESCE(e.container_id, ''), COALESCE(e.container_name, ''),
+		       e.severity, e.message, COALESCE(e.metadata::text, '{}'),
+		       e.source_service, COALESCE(e.source_host, ''), e.event_timestamp,
+		       COALESCE(r.archive_enabled, dp.archive_enabled, true), COALESCE(r.held, false)
+		FROM platform_log_events e
+		LEFT JOIN project_retention r ON r.project_id = e.project_id
+		LEFT JOIN log_retention_policies dp
+		       ON dp.event_type = e.event_type AND dp.project_id IS NULL AND dp.plan_id IS NULL
+		WHERE e.event_type = $1
+		  AND e.event_timestamp < NOW() - make_interval(hours => COALESCE(r.hot_hours, dp.hot_retention_hours, $2))
+		ORDER BY e.event_timestamp, e.id
+		LIMIT $4`,
+		job.EventType, defaultHotRetentionHours, defaultWarmRetentionDays, db.MaxLogsPerBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	type archiveBatch struct {
+		projectID string
+		day       time.Time
+		ids       []string
+		events    []json.RawMessage
+	}
+	batches := make(map[string]*archiveBatch) // By project and day
+	var dropIDs []string
+	n := 0
+
+	for rows.Next() {
+		var event archivedPlatformEvent
+		var metadata string
+		var archive, held bool
+		if err := rows.Scan(
+			&event.ID, &event.EventSubtype, &event.ResourceType, &event.ResourceID,
+			&event.ProjectID, &event.CompanyID, &event.ContainerID, &event.ContainerName,
+			&event.Severity, &event.Message, &metadata,
+			&event.SourceService, &event.SourceHost, &event.EventTimestamp,
+			&archive, &held,
+		); err != nil {
+			rows.Close()
+			return n, err
+		}
+		n++
+		event.EventType = job.EventType
+		event.Metadata = json.RawMessage(metadata)
+
+		// Held events are kept even when the policy disables archiving
+		if !archive && !held {
+			dropIDs = append(dropIDs, event.ID)
+			continue
+		}
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			job.fail("Failed to encode platform event", err)
+			continue
+		}
+		day := event.EventTimestamp.UTC().Truncate(24 * time.Hour)
+		key := event.ProjectID + "|" + day.Format("2006-01-02")
+		batch, ok := batches[key]
+		if !ok {
+			batch = &archiveBatch{projectID: event.ProjectID, day: day}
+			batches[key] = batch
+		}
+		batch.ids = append(batch.ids, event.ID)
+		batch.events = append(batch.events, data)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	job.RecordsProcessed += n
+
+	// Only events that made it into an object leave the database
+	var archivedIDs []string
+	for _, batch := range batches {
+		if _, err := re.logStorage.StorePlatformLogs(ctx, job.EventType, batch.projectID, batch.day, batch.ids, batch.events); err != nil {
+			job.fail("Failed to archive platform events", err)
+			continue
+		}
+		job.MinioObjectsCreated++
+		archivedIDs = append(archivedIDs, batch.ids...)
+	}
+
+	if len(archivedIDs) > 0 {
+		if _, err := re.orchestrator.db.ExecContext(ctx,
+			`DELETE FROM platform_log_events WHERE id = ANY($1::uuid[])`, pq.Array(archivedIDs)); err != nil {
+			job.fail("Failed to delete archived platform events", err)
+		} else {
+			job.RecordsArchived += len(archivedIDs)
+		}
+	}
+	if len(dropIDs) > 0 {
+		if _, err := re.orchestrator.db.ExecContext(ctx,
+			`DELETE FROM platform_log_events WHERE id = ANY($1::uuid[])`, pq.Array(dropIDs)); err != nil {
+			job.fail("Failed to delete expired platform events", err)
+		} else {
+			job.RecordsDeleted += len(dropIDs)
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,196 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"monitoring-service/pkg/db"
+	"monitoring-service/pkg/logger"
+)
+
+// How often a followed stream persists its cursor. A crash replays at most this much,
+// which the logs_archive unique key absorbs.
+const logCursorSaveInterval = 5 * time.Second
+
+// LogShipper follows the Docker log stream of every active container and hands each
+// line to the LogAggregator. Its position in every stream is kept in log_cursors, so
+// a restart resumes where it stopped.
+type LogShipper struct {
+	orchestrator *Orchestrator
+	aggregator   *LogAggregator
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	streams map[string]*containerLogStream // By container ID
+}
+
+type containerLogStream struct {
+	deployment *Deployment
+	cancel     context.CancelFunc
+}
+
+func NewLogShipper(o *Orchestrator, aggregator *LogAggregator) *LogShipper {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &LogShipper{
+		orchestrator: o,
+		aggregator:   aggregator,
+		ctx:          ctx,
+		cancel:       cancel,
+		streams:      make(map[string]*containerLogStream),
+	}
+}
+
+// Sync attaches to containers that became active in deployment_containers and
+// detaches from those that are no longer. Streams that ended while their container is
+// still active are attached again.
+func (ls *LogShipper) Sync(ctx context.Context) error {
+	deployments, err := ls.aggregator.getActiveDeployments(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get active deployments: %w", err)
+	}
+
+	active := make(map[string]*Deployment, len(deployments))
+	for _, d := range deployments {
+		active[d.ContainerID] = d
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.ctx.Err() != nil {
+		return nil
+	}
+
+	for containerID, stream := range ls.streams {
+		if _, ok := active[containerID]; !ok {
+			logger.Info("Detaching from container logs", logger.String("container_id", containerID))
+			stream.cancel()
+			delete(ls.streams, containerID)
+		}
+	}
+
+	for containerID, d := range active {
+		if _, ok := ls.streams[containerID]; ok {
+			continue
+		}
+		streamCtx, cancel := context.WithCancel(ls.ctx)
+		stream := &containerLogStream{deployment: d, cancel: cancel}
+		ls.streams[containerID] = stream
+
+		ls.wg.Add(1)
+		go ls.follow(streamCtx, stream)
+	}
+
+	return nil
+}
+
+// Stop detaches from every container and saves the cursors
+func (ls *LogShipper) Stop() {
+	ls.mu.Lock()
+	ls.cancel()
+	ls.mu.Unlock()
+
+	ls.wg.Wait()
+}
+
+func (ls *LogShipper) follow(ctx context.Context, stream *containerLogStream) {
+	defer ls.wg.Done()
+	defer ls.detached(stream)
+
+	d := stream.deployment
+	cursor, err := ls.loadCursor(ctx, d.ContainerID)
+	if err != nil {
+		logger.Error("Failed to load log cursor", logger.String("container_id", d.ContainerID), logger.Err(err))
+		return
+	}
+
+	// A new container is read from the start of the database retention
+	since := cursor
+	if since.IsZero() {
+		since = time.Now().Add(-db.LogRetentionDB)
+	}
+
+	logger.Info("Following container logs",
+		logger.String("deployment_id", d.ID),
+		logger.String("container_id", d.ContainerID),
+		logger.String("since", since.Format(time.RFC3339Nano)))
+
+	saved := cursor
+	lastSave := time.Now()
+	err = ls.orchestrator.dockerClient.FollowContainerLogs(ctx, d.ContainerID, since, func(streamName, line string) {
+		entry := ls.aggregator.parseLogLine(d, line)
+		entry.Stream = streamName
+
+		// Docker's since is inclusive and resuming may repeat lines already shipped
+		if !entry.Timestamp.After(cursor) {
+			return
+		}
+		ls.aggregator.ingest(ctx, entry)
+		cursor = entry.Timestamp
+
+		if time.Since(lastSave) >= logCursorSaveInterval {
+			if err := ls.saveCursor(ctx, d, cursor); err != nil {
+				logger.Error("Failed to save log cursor", logger.String("container_id", d.ContainerID), logger.Err(err))
+			} else {
+				saved = cursor
+			}
+			lastSave = time.Now()
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		logger.Warn("Container log stream ended",
+			logger.String("container_id", d.ContainerID),
+			logger.Err(err))
+	}
+
+	if cursor.After(saved) {
+		// The stream's context is done on detach; the final save must still happen
+		saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := ls.saveCursor(saveCtx, d, cursor); err != nil {
+			logger.Error("Failed to save log cursor", logger.String("container_id", d.ContainerID), logger.Err(err))
+		}
+	}
+}
+
+// detached forgets a stream that ended on its own, so the next Sync can attach again
+func (ls *LogShipper) detached(stream *containerLogStream) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.streams[stream.deployment.ContainerID] == stream {
+		delete(ls.streams, stream.deployment.ContainerID)
+	}
+	stream.cancel()
+}
+
+func (ls *LogShipper) loadCursor(ctx context.Context, containerID string) (time.Time, error) {
+	var ns int64
+	err := ls.orchestrator.db.QueryRowContext(ctx,
+		`SELECT last_timestamp_ns FROM log_cursors WHERE container_id = $1`, containerID,
+	).Scan(&ns)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, ns), nil
+}
+
+func (ls *LogShipper) saveCursor(ctx context.Context, d *Deployment, cursor time.Time) error {
+	_, err := ls.orchestrator.db.ExecContext(ctx, `
+		INSERT INTO log_cursors (container_id, deployment_id, last_timestamp_ns, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (container_id) DO UPDATE
+		SET last_timestamp_ns = GREATEST(log_cursors.last_timestamp_ns, EXCLUDED.last_timestamp_ns),
+		    updated_at = NOW()`,
+		d.ContainerID, d.ID, cursor.UnixNano())
+	return err
+}
@@ -0,0 +1,100 @@
+package monitoring
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// LogSource is a container whose logs a project tail follows
+type LogSource struct {
+	DeploymentID  string `json:"deployment_id"`
+	Environment   string `json:"environment"`
+	ContainerID   string `json:"container_id,omitempty"`
+	ContainerName string `json:"container_name,omitempty"`
+	Group         string `json:"deployment_group,omitempty"` // blue, green, canary...
+	Color         string `json:"color"`
+}
+
+// sourceColors is the palette tail clients color sources with; picked for contrast on
+// dark and light terminals
+var sourceColors = []string{
+	"#4e9af1", "#f1a24e", "#5ec46a", "#e05d6f", "#b07ce8", "#3cc8c8",
+	"#d9c84a", "#e87cc3", "#8fa0b3", "#7fd47f", "#f07b3f", "#6f8cf2",
+}
+
+// SourceColor picks a stable color for a source, so a container keeps its color across
+// reconnects and clients
+func SourceColor(key string) string {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return sourceColors[h.Sum32()%uint32(len(sourceColors))]
+}
+
+// ProjectLogSources lists the pending, deploying and active deployments of a project
+// and their active containers, optionally only those of the given environments. A deployment without
+// containers yet is listed with an empty container, so its logs are followed as soon
+// as they start.
+func (la *LogAggregator) ProjectLogSources(ctx context.Context, projectID string, environments []string) ([]LogSource, error) {
+	query := `
+		SELECT d.id, d.environment, COALESCE(dc.container_id, ''), COALESCE(dc.container_name, ''),
+			COALESCE(dc.deployment_group, '')
+		FROM deployments d
+		LEFT JOIN deployment_containers dc ON dc.deployment_id = d.id AND dc.is_active = true
+		WHERE d.project_id = $1
+		  AND d.status IN ('pending', 'deploying', 'active')
+		  AND (cardinality($2::text[]) = 0 OR d.environment = ANY($2))
+		ORDER BY d.environment, d.id, dc.container_name
+	`
+	if environments == nil {
+		environments = []string{}
+	}
+
+	rows, err := la.orchestrator.db.QueryContext(ctx, query, projectID, pq.Array(environments))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []LogSource
+	for rows.Next() {
+		var s LogSource
+		if err := rows.Scan(&s.DeploymentID, &s.Environment, &s.ContainerID, &s.ContainerName, &s.Group); err != nil {
+			return nil, err
+		}
+		key := s.ContainerID
+		if key == "" {
+			key = s.DeploymentID
+		}
+		s.Color = SourceColor(key)
+		sources = append(sources, s)
+	}
+	return sources, rows.Err()
+}
+
+// LogLevelRank orders log levels for minimum level filters; unknown levels rank as info
+func LogLevelRank(level string) int {
+	switch strings.ToLower(level) {
+	case "trace", "debug":
+		return 0
+	case "warn", "warning":
+		return 2
+	case "error":
+		return 3
+	case "fatal", "critical", "panic":
+		return 4
+	default:
+		return 1
+	}
+}
+
+// IsLogLevel reports whether a minimum level filter names a known level
+func IsLogLevel(level string) bool {
+	switch strings.ToLower(level) {
+	case "debug", "info", "warn", "warning", "error", "fatal":
+		return true
+	}
+	return false
+}
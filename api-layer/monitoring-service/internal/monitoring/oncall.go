@@ -0,0 +1,690 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	maxScheduleMembers  = 50
+	maxEscalationLevels = 10
+	maxLevelTargets     = 10
+	maxOverrideDuration = 90 * 24 * time.Hour
+)
+
+var (
+	ErrScheduleNotFound          = errors.New("on-call schedule not found")
+	ErrScheduleNameTaken         = errors.New("an on-call schedule with this name already exists")
+	ErrOverrideNotFound          = errors.New("on-call override not found")
+	ErrEscalationPolicyNotFound  = errors.New("escalation policy not found")
+	ErrEscalationPolicyNameTaken = errors.New("an escalation policy with this name already exists")
+	ErrNotTeamMember             = errors.New("on-call users must be members of the team")
+	ErrScheduleNotInTeam         = errors.New("escalation targets must be schedules of the team")
+)
+
+// OnCallManager owns the teams' on-call rotations and pages their escalation policies
+// for alerts on the team's projects
+type OnCallManager struct {
+	orchestrator *Orchestrator
+}
+
+func NewOnCallManager(o *Orchestrator) *OnCallManager {
+	return &OnCallManager{orchestrator: o}
+}
+
+// OnCallSchedule rotates duty through its members, one shift of RotationHours each,
+// starting with the first member at RotationStart
+type OnCallSchedule struct {
+	ID            string    `json:"id"`
+	TeamID        string    `json:"team_id"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	RotationHours int       `json:"rotation_hours"`
+	RotationStart time.Time `json:"rotation_start"`
+	Members       []string  `json:"members"` // User IDs in rotation order
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	OnCall    *OnCallShift      `json:"on_call,omitempty"`
+	Upcoming  []OnCallShift     `json:"upcoming,omitempty"`
+	Overrides []*OnCallOverride `json:"overrides,omitempty"`
+}
+
+// OnCallShift is a period during which one user is on call for a schedule
+type OnCallShift struct {
+	ScheduleID string    `json:"schedule_id"`
+	UserID     string    `json:"user_id"`
+	StartsAt   time.Time `json:"starts_at"`
+	EndsAt     time.Time `json:"ends_at"`
+	Override   bool      `json:"override"`
+}
+
+// OnCallOverride hands a schedule to another user for a period
+type OnCallOverride struct {
+	ID         string    `json:"id"`
+	ScheduleID string    `json:"schedule_id"`
+	UserID     string    `json:"user_id"`
+	StartsAt   time.Time `json:"starts_at"`
+	EndsAt     time.Time `json:"ends_at"`
+	Reason     string    `json:"reason"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// EscalationPolicy pages its levels in order until the alert is acknowledged. Each
+// level waits TimeoutMinutes before handing over to the next one.
+type EscalationPolicy struct {
+	ID          string            `json:"id"`
+	TeamID      string            `json:"team_id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	IsDefault   bool              `json:"is_default"`   // Pages for the alerts of the team's projects
+	MinSeverity string            `json:"min_severity"` // Less severe alerts are only sent to their channels
+	RepeatCount int               `json:"repeat_count"` // Restarts from the first level after the last one timed out
+	Levels      []EscalationLevel `json:"levels"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+type EscalationLevel struct {
+	TimeoutMinutes int                `json:"timeout_minutes"`
+	Targets        []EscalationTarget `json:"targets"`
+}
+
+// EscalationTarget pages a user directly, or whoever is on call for a schedule
+type EscalationTarget struct {
+	Type string `json:"type"` // 'schedule' or 'user'
+	ID   string `json:"id"`
+}
+
+// Validate normalizes the schedule; a schedule without rotation start starts now
+func (s *OnCallSchedule) Validate() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" || len(s.Name) > 100 {
+		return fmt.Errorf("name is required and must be at most 100 characters")
+	}
+	if s.RotationHours == 0 {
+		s.RotationHours = 168
+	}
+	if s.RotationHours < 1 || s.RotationHours > 2016 {
+		return fmt.Errorf("rotation_hours must be between 1 and 2016")
+	}
+	if s.RotationStart.IsZero() {
+		s.RotationStart = time.Now().Truncate(time.Hour)
+	}
+	if len(s.Members) == 0 || len(s.Members) > maxScheduleMembers {
+		return fmt.Errorf("a schedule needs between 1 and %d members", maxScheduleMembers)
+	}
+	seen := map[string]bool{}
+	for _, id := range s.Members {
+		if uuid.Validate(id) != nil {
+			return fmt.Errorf("members must be user IDs")
+		}
+		if seen[id] {
+			return fmt.Errorf("user %s is in the rotation twice", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// Validate checks the override's period
+func (o *OnCallOverride) Validate() error {
+	if uuid.Validate(o.UserID) != nil {
+		return fmt.Errorf("user_id must be a user ID")
+	}
+	if o.StartsAt.IsZero() {
+		o.StartsAt = time.Now()
+	}
+	if !o.EndsAt.After(o.StartsAt) || !o.EndsAt.After(time.Now()) {
+		return fmt.Errorf("ends_at must be in the future and after starts_at")
+	}
+	if o.EndsAt.Sub(o.StartsAt) > maxOverrideDuration {
+		return fmt.Errorf("overrides can last at most 90 days")
+	}
+	if len(o.Reason) > 500 {
+		return fmt.Errorf("reason must be at most 500 characters")
+	}
+	return nil
+}
+
+// Validate normalizes the policy and checks its levels
+func (p *EscalationPolicy) Validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" || len(p.Name) > 100 {
+		return fmt.Errorf("name is required and must be at most 100 characters")
+	}
+	if p.MinSeverity == "" {
+		p.MinSeverity = "warning"
+	}
+	if _, ok := incidentSeverityRank[p.MinSeverity]; !ok {
+		return fmt.Errorf("min_severity must be info, low, warning, medium, high or critical")
+	}
+	if p.RepeatCount < 0 || p.RepeatCount > 5 {
+		return fmt.Errorf("repeat_count must be between 0 and 5")
+	}
+	if len(p.Levels) == 0 || len(p.Levels) > maxEscalationLevels {
+		return fmt.Errorf("a policy needs between 1 and %d levels", maxEscalationLevels)
+	}
+	for i, level := range p.Levels {
+		if level.TimeoutMinutes < 1 || level.TimeoutMinutes > 1440 {
+			return fmt.Errorf("level %d: timeout_minutes must be between 1 and 1440", i+1)
+		}
+		if len(level.Targets) == 0 || len(level.Targets) > maxLevelTargets {
+			return fmt.Errorf("level %d: needs between 1 and %d targets", i+1, maxLevelTargets)
+		}
+		for _, t := range level.Targets {
+			if t.Type != "schedule" && t.Type != "user" {
+				return fmt.Errorf("level %d: target type must be schedule or user", i+1)
+			}
+			if uuid.Validate(t.ID) != nil {
+				return fmt.Errorf("level %d: target id must be a UUID", i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// shiftAt is the rotation shift covering at, ignoring overrides
+func (s *OnCallSchedule) shiftAt(at time.Time) (OnCallShift, bool) {
+	if len(s.Members) == 0 || s.RotationHours <= 0 {
+		return OnCallShift{}, false
+	}
+	length := time.Duration(s.RotationHours) * time.Hour
+	elapsed := at.Sub(s.RotationStart)
+	k := int64(elapsed / length)
+	if elapsed < 0 && elapsed%length != 0 {
+		k--
+	}
+	n := int64(len(s.Members))
+	start := s.RotationStart.Add(time.Duration(k) * length)
+	return OnCallShift{
+		ScheduleID: s.ID,
+		UserID:     s.Members[((k%n)+n)%n],
+		StartsAt:   start,
+		EndsAt:     start.Add(length),
+	}, true
+}
+
+// upcomingShifts are the next count rotation shifts after the current one
+func (s *OnCallSchedule) upcomingShifts(from time.Time, count int) []OnCallShift {
+	current, ok := s.shiftAt(from)
+	if !ok {
+		return nil
+	}
+	shifts := make([]OnCallShift, 0, count)
+	for next := current.EndsAt; len(shifts) < count; {
+		shift, _ := s.shiftAt(next)
+		shifts = append(shifts, shift)
+		next = shift.EndsAt
+	}
+	return shifts
+}
+
+// onCallAt is who is on call for the schedule at a time: the latest override covering
+// it, otherwise the rotation
+func (om *OnCallManager) onCallAt(ctx context.Context, s *OnCallSchedule, at time.Time) (*OnCallShift, error) {
+	shift := OnCallShift{ScheduleID: s.ID, Override: true}
+	err := om.orchestrator.db.QueryRowContext(ctx, `
+		SELECT user_id, starts_at, ends_at FROM oncall_overrides
+		WHERE schedule_id = $1 AND starts_at <= $2 AND ends_at > $2
+		ORDER BY created_at DESC
+		LIMIT 1`, s.ID, at).Scan(&shift.UserID, &shift.StartsAt, &shift.EndsAt)
+	if err == nil {
+		return &shift, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if shift, ok := s.shiftAt(at); ok {
+		return &shift, nil
+	}
+	return nil, nil
+}
+
+const scheduleColumns = `s.id, s.team_id, s.name, COALESCE(s.description, ''), s.rotation_hours, s.rotation_start,
+	COALESCE((SELECT json_agg(m.user_id ORDER BY m.position) FROM oncall_schedule_members m WHERE m.schedule_id = s.id), '[]'),
+	s.created_at, s.updated_at`
+
+func scanSchedule(scan func(dest ...interface{}) error) (*OnCallSchedule, error) {
+	var s OnCallSchedule
+	var members []byte
+	err := scan(&s.ID, &s.TeamID, &s.Name, &s.Description, &s.RotationHours, &s.RotationStart,
+		&members, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	s.Members = []string{}
+	json.Unmarshal(members, &s.Members)
+	return &s, nil
+}
+
+// ListSchedules returns a team's schedules with who is on call now
+func (om *OnCallManager) ListSchedules(ctx context.Context, teamID string) ([]*OnCallSchedule, error) {
+	rows, err := om.orchestrator.db.QueryContext(ctx, `
+		SELECT `+scheduleColumns+` FROM oncall_schedules s
+		WHERE s.team_id = $1
+		ORDER BY s.name`, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := []*OnCallSchedule{}
+	for rows.Next() {
+		s, err := scanSchedule(rows.Scan)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, s := range schedules {
+		if s.OnCall, err = om.onCallAt(ctx, s, now); err != nil {
+			return nil, err
+		}
+	}
+	return schedules, nil
+}
+
+// GetSchedule returns a schedule with who is on call, the next shifts of the rotation
+// and the overrides that have not ended
+func (om *OnCallManager) GetSchedule(ctx context.Context, teamID, scheduleID string) (*OnCallSchedule, error) {
+	s, err := scanSchedule(om.orchestrator.db.QueryRowContext(ctx, `
+		SELECT `+scheduleColumns+` FROM oncall_schedules s
+		WHERE s.id = $1 AND s.team_id = $2`, scheduleID, teamID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if s.OnCall, err = om.onCallAt(ctx, s, now); err != nil {
+		return nil, err
+	}
+	s.Upcoming = s.upcomingShifts(now, len(s.Members))
+
+	rows, err := om.orchestrator.db.QueryContext(ctx, `
+		SELECT id, schedule_id, user_id, starts_at, ends_at, COALESCE(reason, ''),
+		       COALESCE(created_by_user_id::text, ''), created_at
+		FROM oncall_overrides
+		WHERE schedule_id = $1 AND ends_at > NOW()
+		ORDER BY starts_at`, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	s.Overrides = []*OnCallOverride{}
+	for rows.Next() {
+		var o OnCallOverride
+		if err := rows.Scan(&o.ID, &o.ScheduleID, &o.UserID, &o.StartsAt, &o.EndsAt, &o.Reason, &o.CreatedBy, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		s.Overrides = append(s.Overrides, &o)
+	}
+	return s, rows.Err()
+}
+
+// CreateSchedule stores a validated schedule. Its members must belong to the team.
+func (om *OnCallManager) CreateSchedule(ctx context.Context, teamID string, s *OnCallSchedule) error {
+	tx, err := om.orchestrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkTeamUsers(ctx, tx, teamID, s.Members); err != nil {
+		return err
+	}
+
+	s.TeamID = teamID
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO oncall_schedules (team_id, name, description, rotation_hours, rotation_start)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		RETURNING id, created_at, updated_at`,
+		teamID, s.Name, s.Description, s.RotationHours, s.RotationStart,
+	).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return onCallWriteError(err, ErrScheduleNameTaken)
+	}
+
+	if err := insertScheduleMembers(ctx, tx, s); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateSchedule replaces a schedule's rotation. Overrides are kept.
+func (om *OnCallManager) UpdateSchedule(ctx context.Context, teamID, scheduleID string, s *OnCallSchedule) error {
+	tx, err := om.orchestrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkTeamUsers(ctx, tx, teamID, s.Members); err != nil {
+		return err
+	}
+
+	s.ID = scheduleID
+	s.TeamID = teamID
+	err = tx.QueryRowContext(ctx, `
+		UPDATE oncall_schedules
+		SET name = $3, description = NULLIF($4, ''), rotation_hours = $5, rotation_start = $6, updated_at = NOW()
+		WHERE id = $1 AND team_id = $2
+		RETURNING created_at, updated_at`,
+		scheduleID, teamID, s.Name, s.Description, s.RotationHours, s.RotationStart,
+	).Scan(&s.CreatedAt, &s.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrScheduleNotFound
+	}
+	if err != nil {
+		return onCallWriteError(err, ErrScheduleNameTaken)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM oncall_schedule_members WHERE schedule_id = $1`, scheduleID); err != nil {
+		return err
+	}
+	if err := insertScheduleMembers(ctx, tx, s); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteSchedule removes a schedule. Policy levels that page it skip it from then on.
+func (om *OnCallManager) DeleteSchedule(ctx context.Context, teamID, scheduleID string) error {
+	res, err := om.orchestrator.db.ExecContext(ctx, `
+		DELETE FROM oncall_schedules WHERE id = $1 AND team_id = $2`, scheduleID, teamID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// CreateOverride puts a team member on call for a schedule for a period
+func (om *OnCallManager) CreateOverride(ctx context.Context, teamID, scheduleID, userID string, o *OnCallOverride) error {
+	tx, err := om.orchestrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id string
+	err = tx.QueryRowContext(ctx, `
+		SELECT id FROM oncall_schedules WHERE id = $1 AND team_id = $2`, scheduleID, teamID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrScheduleNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkTeamUsers(ctx, tx, teamID, []string{o.UserID}); err != nil {
+		return err
+	}
+
+	o.ScheduleID = scheduleID
+	o.CreatedBy = userID
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO oncall_overrides (schedule_id, user_id, starts_at, ends_at, reason, created_by_user_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		RETURNING id, created_at`,
+		scheduleID, o.UserID, o.StartsAt, o.EndsAt, o.Reason, nullIfEmpty(userID),
+	).Scan(&o.ID, &o.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteOverride removes an override, handing the schedule back to the rotation
+func (om *OnCallManager) DeleteOverride(ctx context.Context, teamID, scheduleID, overrideID string) error {
+	res, err := om.orchestrator.db.ExecContext(ctx, `
+		DELETE FROM oncall_overrides o
+		USING oncall_schedules s
+		WHERE o.id = $1 AND o.schedule_id = $2 AND s.id = o.schedule_id AND s.team_id = $3`,
+		overrideID, scheduleID, teamID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrOverrideNotFound
+	}
+	return nil
+}
+
+// WhoIsOnCall returns who is on call now for each of the team's schedules
+func (om *OnCallManager) WhoIsOnCall(ctx context.Context, teamID string) ([]OnCallShift, error) {
+	schedules, err := om.ListSchedules(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	shifts := make([]OnCallShift, 0, len(schedules))
+	for _, s := range schedules {
+		if s.OnCall != nil {
+			shifts = append(shifts, *s.OnCall)
+		}
+	}
+	return shifts, nil
+}
+
+const escalationPolicyColumns = `id, team_id, name, COALESCE(description, ''), is_default, min_severity,
+	repeat_count, levels, created_at, updated_at`
+
+func scanEscalationPolicy(scan func(dest ...interface{}) error) (*EscalationPolicy, error) {
+	var p EscalationPolicy
+	var levels []byte
+	err := scan(&p.ID, &p.TeamID, &p.Name, &p.Description, &p.IsDefault, &p.MinSeverity,
+		&p.RepeatCount, &levels, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	p.Levels = []EscalationLevel{}
+	json.Unmarshal(levels, &p.Levels)
+	return &p, nil
+}
+
+// ListEscalationPolicies returns a team's escalation policies, the default one first
+func (om *OnCallManager) ListEscalationPolicies(ctx context.Context, teamID string) ([]*EscalationPolicy, error) {
+	rows, err := om.orchestrator.db.QueryContext(ctx, `
+		SELECT `+escalationPolicyColumns+` FROM escalation_policies
+		WHERE team_id = $1
+		ORDER BY is_default DESC, name`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []*EscalationPolicy{}
+	for rows.Next() {
+		p, err := scanEscalationPolicy(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// CreateEscalationPolicy stores a validated policy. Making it the default takes the
+// default over from the team's previous one.
+func (om *OnCallManager) CreateEscalationPolicy(ctx context.Context, teamID string, p *EscalationPolicy) error {
+	tx, err := om.orchestrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkPolicyTargets(ctx, tx, teamID, p); err != nil {
+		return err
+	}
+	if p.IsDefault {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE escalation_policies SET is_default = false WHERE team_id = $1 AND is_default`, teamID); err != nil {
+			return err
+		}
+	}
+
+	levels, _ := json.Marshal(p.Levels)
+	p.TeamID = teamID
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO escalation_policies (team_id, name, description, is_default, min_severity, repeat_count, levels)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at`,
+		teamID, p.Name, p.Description, p.IsDefault, p.MinSeverity, p.RepeatCount, levels,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return onCallWriteError(err, ErrEscalationPolicyNameTaken)
+	}
+	return tx.Commit()
+}
+
+// UpdateEscalationPolicy replaces a policy. Running escalations continue at their
+// level with the new definition.
+func (om *OnCallManager) UpdateEscalationPolicy(ctx context.Context, teamID, policyID string, p *EscalationPolicy) error {
+	tx, err := om.orchestrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkPolicyTargets(ctx, tx, teamID, p); err != nil {
+		return err
+	}
+	if p.IsDefault {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE escalation_policies SET is_default = false
+			WHERE team_id = $1 AND is_default AND id <> $2`, teamID, policyID); err != nil {
+			return err
+		}
+	}
+
+	levels, _ := json.Marshal(p.Levels)
+	p.ID = policyID
+	p.TeamID = teamID
+	err = tx.QueryRowContext(ctx, `
+		UPDATE escalation_policies
+		SET name = $3, description = NULLIF($4, ''), is_default = $5, min_severity = $6, repeat_count = $7,
+		    levels = $8, updated_at = NOW()
+		WHERE id = $1 AND team_id = $2
+		RETURNING created_at, updated_at`,
+		policyID, teamID, p.Name, p.Description, p.IsDefault, p.MinSeverity, p.RepeatCount, levels,
+	).Scan(&p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrEscalationPolicyNotFound
+	}
+	if err != nil {
+		return onCallWriteError(err, ErrEscalationPolicyNameTaken)
+	}
+	return tx.Commit()
+}
+
+// DeleteEscalationPolicy removes a policy; its running escalations stop on the next cycle
+func (om *OnCallManager) DeleteEscalationPolicy(ctx context.Context, teamID, policyID string) error {
+	res, err := om.orchestrator.db.ExecContext(ctx, `
+		DELETE FROM escalation_policies WHERE id = $1 AND team_id = $2`, policyID, teamID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEscalationPolicyNotFound
+	}
+	return nil
+}
+
+func insertScheduleMembers(ctx context.Context, tx *sql.Tx, s *OnCallSchedule) error {
+	for i, userID := range s.Members {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO oncall_schedule_members (schedule_id, user_id, position) VALUES ($1, $2, $3)`,
+			s.ID, userID, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTeamUsers verifies that every user is the team's owner or one of its members
+func checkTeamUsers(ctx context.Context, tx *sql.Tx, teamID string, userIDs []string) error {
+	var found int
+	err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT u.id) FROM unnest($2::uuid[]) AS u(id)
+		WHERE EXISTS (SELECT 1 FROM team_members tm WHERE tm.team_id = $1 AND tm.user_id = u.id)
+		   OR EXISTS (SELECT 1 FROM teams t WHERE t.id = $1 AND t.owner_user_id = u.id)`,
+		teamID, pq.Array(userIDs)).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if found != len(userIDs) {
+		return ErrNotTeamMember
+	}
+	return nil
+}
+
+// checkPolicyTargets verifies that the policy only pages the team's schedules and members
+func checkPolicyTargets(ctx context.Context, tx *sql.Tx, teamID string, p *EscalationPolicy) error {
+	users := map[string]bool{}
+	schedules := map[string]bool{}
+	for _, level := range p.Levels {
+		for _, t := range level.Targets {
+			if t.Type == "user" {
+				users[t.ID] = true
+			} else {
+				schedules[t.ID] = true
+			}
+		}
+	}
+
+	if len(users) > 0 {
+		if err := checkTeamUsers(ctx, tx, teamID, mapKeys(users)); err != nil {
+			return err
+		}
+	}
+	if len(schedules) > 0 {
+		var found int
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM oncall_schedules WHERE team_id = $1 AND id = ANY($2::uuid[])`,
+			teamID, pq.Array(mapKeys(schedules))).Scan(&found)
+		if err != nil {
+			return err
+		}
+		if found != len(schedules) {
+			return ErrScheduleNotInTeam
+		}
+	}
+	return nil
+}
+
+func mapKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func onCallWriteError(err error, nameTaken error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nameTaken
+	}
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 
 	"monitoring-service/internal/httpmetrics"
+	"monitoring-service/internal/logdrain"
 	"monitoring-service/internal/metrics"
 	"monitoring-service/pkg/config"
 	"monitoring-service/pkg/db"
@@ -24,10 +25,24 @@ type Orchestrator struct {
 
 	healthChecker *HealthChecker
 	logAggregator *LogAggregator
+	logShipper    *LogShipper
+	retention     *RetentionEngine
+	logDrains     *logdrain.Manager
+	errorTracker  *ErrorTracker
 	alertManager  *AlertManager
+	incidentMgr   *IncidentManager
+	onCallMgr     *OnCallManager
 	uptimeTracker *UptimeTracker
+	prober        *SyntheticProber
+	sloEngine     *SLOEngine
 	metricsCol    *metrics.Collector
+	rollup        *metrics.Rollup
+	exporter      *metrics.Exporter
+	remoteWriter  *metrics.RemoteWriter
+	appScraper    *metrics.AppScraper
 	traefikCol    *httpmetrics.TraefikCollector
+	otlp          *OTLPReceiver
+	traces        *TraceStore
 }
 
 func NewOrchestrator(cfg *config.Config, dbConn *sql.DB, redisClient *db.RedisClient, minioClient *minio.Client) *Orchestrator {
@@ -51,14 +66,29 @@ func NewOrchestrator(cfg *config.Config, dbConn *sql.DB, redisClient *db.RedisCl
 	// Initialize components
 	o.healthChecker = NewHealthChecker(o)
 	o.logAggregator = NewLogAggregator(o, logStorage)
+	o.logShipper = NewLogShipper(o, o.logAggregator)
+	o.retention = NewRetentionEngine(o, o.logAggregator, logStorage)
+	o.logDrains = logdrain.NewManager(dbConn, cfg.LogDrainBufferDir, cfg.LogDrainBufferBytes, cfg.LogDrainAllowPrivate)
+	o.errorTracker = NewErrorTracker(o)
 	o.alertManager = NewAlertManager(o)
+	o.incidentMgr = NewIncidentManager(o)
+	o.onCallMgr = NewOnCallManager(o)
 	o.uptimeTracker = NewUptimeTracker(o)
+	o.prober = NewSyntheticProber(o)
+	o.sloEngine = NewSLOEngine(o)
 	o.metricsCol = metrics.NewCollector(o.dockerClient, dbConn, redisClient)
+	o.rollup = metrics.NewRollup(dbConn)
+	o.exporter = metrics.NewExporter(dbConn, redisClient)
+	o.remoteWriter = metrics.NewRemoteWriter(dbConn, o.exporter)
+	o.appScraper = metrics.NewAppScraper(dbConn, o.dockerClient, cfg.AppMetricsNetwork)
 
 	// Initialize Traefik HTTP metrics collector
 	traefikLogPath := "/var/log/traefik"
 	o.traefikCol = httpmetrics.NewTraefikCollector(dbConn, redisClient, traefikLogPath)
 
+	o.otlp = NewOTLPReceiver(o)
+	o.traces = NewTraceStore(o)
+
 	return o
 }
 
@@ -92,6 +122,21 @@ func (o *Orchestrator) GetLogAggregator() *LogAggregator {
 	return o.logAggregator
 }
 
+// GetLogShipper returns the container log shipper
+func (o *Orchestrator) GetLogShipper() *LogShipper {
+	return o.logShipper
+}
+
+// GetLogDrains returns the log drain manager
+func (o *Orchestrator) GetLogDrains() *logdrain.Manager {
+	return o.logDrains
+}
+
+// GetErrorTracker returns the error issue tracker
+func (o *Orchestrator) GetErrorTracker() *ErrorTracker {
+	return o.errorTracker
+}
+
 // GetLogStorage returns log storage
 func (o *Orchestrator) GetLogStorage() *db.LogStorage {
 	return o.logStorage
@@ -102,21 +147,71 @@ func (o *Orchestrator) GetAlertManager() *AlertManager {
 	return o.alertManager
 }
 
+// GetIncidentManager returns incident manager
+func (o *Orchestrator) GetIncidentManager() *IncidentManager {
+	return o.incidentMgr
+}
+
+// GetOnCallManager returns the on-call schedules and escalation manager
+func (o *Orchestrator) GetOnCallManager() *OnCallManager {
+	return o.onCallMgr
+}
+
 // GetUptimeTracker returns uptime tracker
 func (o *Orchestrator) GetUptimeTracker() *UptimeTracker {
 	return o.uptimeTracker
 }
 
+// GetSyntheticProber returns synthetic prober
+func (o *Orchestrator) GetSyntheticProber() *SyntheticProber {
+	return o.prober
+}
+
+// GetSLOEngine returns SLO engine
+func (o *Orchestrator) GetSLOEngine() *SLOEngine {
+	return o.sloEngine
+}
+
 // GetMetricsCollector returns metrics collector
 func (o *Orchestrator) GetMetricsCollector() *metrics.Collector {
 	return o.metricsCol
 }
 
+// GetMetricsRollup returns metrics rollup
+func (o *Orchestrator) GetMetricsRollup() *metrics.Rollup {
+	return o.rollup
+}
+
+// GetMetricsExporter returns Prometheus metrics exporter
+func (o *Orchestrator) GetMetricsExporter() *metrics.Exporter {
+	return o.exporter
+}
+
+// GetRemoteWriter returns Prometheus remote-write pusher
+func (o *Orchestrator) GetRemoteWriter() *metrics.RemoteWriter {
+	return o.remoteWriter
+}
+
+// GetAppScraper returns application metrics scraper
+func (o *Orchestrator) GetAppScraper() *metrics.AppScraper {
+	return o.appScraper
+}
+
 // GetTraefikCollector returns Traefik collector
 func (o *Orchestrator) GetTraefikCollector() *httpmetrics.TraefikCollector {
 	return o.traefikCol
 }
 
+// GetOTLPReceiver returns the OTLP receiver
+func (o *Orchestrator) GetOTLPReceiver() *OTLPReceiver {
+	return o.otlp
+}
+
+// GetTraceStore returns the trace store
+func (o *Orchestrator) GetTraceStore() *TraceStore {
+	return o.traces
+}
+
 // GetConfig returns config
 func (o *Orchestrator) GetConfig() *config.Config {
 	return o.config
@@ -140,15 +235,45 @@ func (o *Orchestrator) RunHealthChecks(ctx context.Context) error {
 	return nil
 }
 
-// RunLogAggregation performs log aggregation
+// RunLogAggregation attaches the log shipper to new containers and detaches it from
+// removed ones
 func (o *Orchestrator) RunLogAggregation(ctx context.Context) error {
-	if err := o.logAggregator.AggregateAll(ctx); err != nil {
+	if err := o.logShipper.Sync(ctx); err != nil {
 		logger.Error("Error aggregating logs", logger.Err(err))
 		return err
 	}
 	return nil
 }
 
+// RunLogDrainSync starts, reconfigures and stops log drains and records their delivery
+// stats
+func (o *Orchestrator) RunLogDrainSync(ctx context.Context) error {
+	if err := o.logDrains.Sync(ctx); err != nil {
+		logger.Error("Error syncing log drains", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
+// RunLogRetention applies the log retention policies to database logs, archives and
+// platform events
+func (o *Orchestrator) RunLogRetention(ctx context.Context) error {
+	if err := o.retention.Run(ctx); err != nil {
+		logger.Error("Error applying log retention", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
+// RunErrorTracking writes the error events counted since the last run to their issues
+func (o *Orchestrator) RunErrorTracking(ctx context.Context) error {
+	if err := o.errorTracker.Flush(ctx); err != nil {
+		logger.Error("Error recording error issues", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
 // RunUptimeTracking performs uptime tracking
 func (o *Orchestrator) RunUptimeTracking(ctx context.Context) error {
 	if err := o.uptimeTracker.Track(ctx); err != nil {
@@ -158,6 +283,60 @@ func (o *Orchestrator) RunUptimeTracking(ctx context.Context) error {
 	return nil
 }
 
+// RunSyntheticProbes runs the probes whose interval elapsed
+func (o *Orchestrator) RunSyntheticProbes(ctx context.Context) error {
+	if err := o.prober.RunDue(ctx); err != nil {
+		logger.Error("Error running synthetic probes", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
+// RunSLOEvaluation updates burn-rate alerts for all SLOs
+func (o *Orchestrator) RunSLOEvaluation(ctx context.Context) error {
+	if err := o.sloEngine.Evaluate(ctx); err != nil {
+		logger.Error("Error evaluating SLOs", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
+// RunMetricsRollup downsamples metrics into the 1m/1h/1d tiers
+func (o *Orchestrator) RunMetricsRollup(ctx context.Context) error {
+	if err := o.rollup.Run(ctx); err != nil {
+		logger.Error("Error rolling up metrics", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
+// RunMetricsRetention deletes metrics past the retention of each plan tier
+func (o *Orchestrator) RunMetricsRetention(ctx context.Context) error {
+	if err := o.rollup.ApplyRetention(ctx); err != nil {
+		logger.Error("Error applying metrics retention", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
+// RunRemoteWrite pushes metrics to the remote-write targets that are due
+func (o *Orchestrator) RunRemoteWrite(ctx context.Context) error {
+	if err := o.remoteWriter.PushDue(ctx); err != nil {
+		logger.Error("Error pushing remote-write metrics", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
+// RunAppMetricsScrape scrapes the application metrics endpoints that are due
+func (o *Orchestrator) RunAppMetricsScrape(ctx context.Context) error {
+	if err := o.appScraper.ScrapeDue(ctx); err != nil {
+		logger.Error("Error scraping application metrics", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
 // RunAlertProcessing processes alerts
 func (o *Orchestrator) RunAlertProcessing(ctx context.Context) error {
 	if err := o.alertManager.ProcessAlerts(ctx); err != nil {
@@ -167,6 +346,24 @@ func (o *Orchestrator) RunAlertProcessing(ctx context.Context) error {
 	return nil
 }
 
+// RunIncidentProcessing groups new alerts into incidents and advances their lifecycle
+func (o *Orchestrator) RunIncidentProcessing(ctx context.Context) error {
+	if err := o.incidentMgr.Process(ctx); err != nil {
+		logger.Error("Error processing incidents", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
+// RunEscalations pages the next escalation level of alerts nobody acknowledged in time
+func (o *Orchestrator) RunEscalations(ctx context.Context) error {
+	if err := o.onCallMgr.Escalate(ctx); err != nil {
+		logger.Error("Error escalating alerts", logger.Err(err))
+		return err
+	}
+	return nil
+}
+
 // RunHTTPMetricsCollection collects HTTP metrics from Traefik
 func (o *Orchestrator) RunHTTPMetricsCollection(ctx context.Context) error {
 	if o.traefikCol == nil {
@@ -0,0 +1,574 @@
+package monitoring
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"monitoring-service/internal/metrics"
+
+	"github.com/lib/pq"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	ingestTokenPrefix = "obt_ot_"
+
+	// Resource attributes that tie telemetry to a deployment, besides the standard
+	// container.id and deployment.environment
+	otlpProjectAttr    = "obtura.project.id"
+	otlpDeploymentAttr = "obtura.deployment.id"
+
+	otlpResolveTTL = time.Minute
+)
+
+var (
+	ErrIngestTokenNotFound  = errors.New("ingest token not found")
+	ErrIngestTokenNameTaken = errors.New("an ingest token with this name already exists")
+	ErrInvalidIngestToken   = errors.New("invalid ingest token")
+
+	errOtherProject = errors.New("resource belongs to another project")
+)
+
+// IngestToken lets an app's OTLP exporter push telemetry into one project. Token is
+// only set in the response that created it.
+type IngestToken struct {
+	ID          string     `json:"id"`
+	ProjectID   string     `json:"project_id"`
+	Name        string     `json:"name"`
+	Token       string     `json:"token,omitempty"`
+	TokenPrefix string     `json:"token_prefix"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type resolvedDeployment struct {
+	deploymentID string
+	resolvedAt   time.Time
+}
+
+// OTLPReceiver takes the traces, logs and metrics apps export over OTLP. Each resource
+// is matched to a deployment of the token's project: by obtura.deployment.id, by the
+// container.id (or a container hostname) of one of its containers, by the newest
+// active deployment of its deployment.environment, or by the project's only active
+// deployment. Logs and metrics of resources matching none are rejected; spans are kept
+// without a deployment.
+type OTLPReceiver struct {
+	orchestrator *Orchestrator
+
+	mu       sync.Mutex
+	resolved map[string]resolvedDeployment
+}
+
+func NewOTLPReceiver(o *Orchestrator) *OTLPReceiver {
+	return &OTLPReceiver{
+		orchestrator: o,
+		resolved:     make(map[string]resolvedDeployment),
+	}
+}
+
+func hashIngestToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateIngestToken issues a new token for the project
+func (r *OTLPReceiver) CreateIngestToken(ctx context.Context, projectID, name string) (*IngestToken, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := ingestTokenPrefix + hex.EncodeToString(raw)
+
+	t := &IngestToken{ProjectID: projectID, Name: name, Token: token, TokenPrefix: token[:len(ingestTokenPrefix)+6]}
+	err := r.orchestrator.db.QueryRowContext(ctx, `
+		INSERT INTO otlp_ingest_tokens (project_id, name, token_hash, token_prefix)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`, projectID, name, hashIngestToken(token), t.TokenPrefix).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrIngestTokenNameTaken
+		}
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// ListIngestTokens returns the project's active tokens without their secret
+func (r *OTLPReceiver) ListIngestTokens(ctx context.Context, projectID string) ([]IngestToken, error) {
+	rows, err := r.orchestrator.db.QueryContext(ctx, `
+		SELECT id, project_id, name, token_prefix, last_used_at, created_at
+		FROM otlp_ingest_tokens
+		WHERE project_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []IngestToken{}
+	for rows.Next() {
+		var t IngestToken
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&t.ID, &t.ProjectID, &t.Name, &t.TokenPrefix, &lastUsed, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			t.LastUsedAt = &lastUsed.Time
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeIngestToken stops the token from authenticating and frees its name
+func (r *OTLPReceiver) RevokeIngestToken(ctx context.Context, projectID, tokenID string) error {
+	res, err := r.orchestrator.db.ExecContext(ctx, `
+		UPDATE otlp_ingest_tokens
+		SET revoked_at = NOW()
+		WHERE id = $1 AND project_id = $2 AND revoked_at IS NULL`, tokenID, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrIngestTokenNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the project the token belongs to
+func (r *OTLPReceiver) Authenticate(ctx context.Context, token string) (string, error) {
+	if !strings.HasPrefix(token, ingestTokenPrefix) {
+		return "", ErrInvalidIngestToken
+	}
+
+	var projectID string
+	err := r.orchestrator.db.QueryRowContext(ctx, `
+		UPDATE otlp_ingest_tokens SET last_used_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL
+		RETURNING project_id`, hashIngestToken(token)).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidIngestToken
+	}
+	if err != nil {
+		return "", err
+	}
+	return projectID, nil
+}
+
+// resolveDeployment returns the deployment the resource belongs to, "" when it
+// matches none, or errOtherProject when it names another project
+func (r *OTLPReceiver) resolveDeployment(ctx context.Context, projectID string, resource map[string]string) (string, error) {
+	if p := resource[otlpProjectAttr]; p != "" && p != projectID {
+		return "", errOtherProject
+	}
+
+	containerID := resource["container.id"]
+	if containerID == "" && isContainerIDPrefix(resource["host.name"]) {
+		// Docker sets a container's hostname to its short ID
+		containerID = resource["host.name"]
+	}
+	environment := resource["deployment.environment.name"]
+	if environment == "" {
+		environment = resource["deployment.environment"]
+	}
+
+	key := strings.Join([]string{projectID, resource[otlpDeploymentAttr], containerID, environment}, "\x00")
+	r.mu.Lock()
+	cached, ok := r.resolved[key]
+	r.mu.Unlock()
+	if ok && time.Since(cached.resolvedAt) < otlpResolveTTL {
+		return cached.deploymentID, nil
+	}
+
+	deploymentID, err := r.lookupDeployment(ctx, projectID, resource[otlpDeploymentAttr], containerID, environment)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	if len(r.resolved) > 10000 {
+		r.resolved = make(map[string]resolvedDeployment)
+	}
+	r.resolved[key] = resolvedDeployment{deploymentID: deploymentID, resolvedAt: time.Now()}
+	r.mu.Unlock()
+	return deploymentID, nil
+}
+
+func (r *OTLPReceiver) lookupDeployment(ctx context.Context, projectID, deploymentID, containerID, environment string) (string, error) {
+	db := r.orchestrator.db
+	var id string
+
+	if deploymentID != "" {
+		err := db.QueryRowContext(ctx, `
+			SELECT id FROM deployments WHERE id::text = $1 AND project_id = $2`, deploymentID, projectID).Scan(&id)
+		if err != sql.ErrNoRows {
+			return id, err
+		}
+	}
+
+	if isContainerIDPrefix(containerID) {
+		err := db.QueryRowContext(ctx, `
+			SELECT dc.deployment_id FROM deployment_containers dc
+			JOIN deployments d ON d.id = dc.deployment_id
+			WHERE d.project_id = $1 AND dc.container_id LIKE $2 || '%'
+			ORDER BY dc.is_active DESC, d.created_at DESC
+			LIMIT 1`, projectID, strings.ToLower(containerID)).Scan(&id)
+		if err != sql.ErrNoRows {
+			return id, err
+		}
+	}
+
+	if environment != "" {
+		err := db.QueryRowContext(ctx, `
+			SELECT d.id FROM deployments d
+			WHERE d.project_id = $1 AND d.environment = $2 AND `+alertableDeploymentFilter+`
+			ORDER BY d.created_at DESC
+			LIMIT 1`, projectID, environment).Scan(&id)
+		if err != sql.ErrNoRows {
+			return id, err
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT d.id FROM deployments d
+		WHERE d.project_id = $1 AND `+alertableDeploymentFilter+`
+		LIMIT 2`, projectID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var active []string
+	for rows.Next() {
+		if err := rows.Scan(&id); err != nil {
+			return "", err
+		}
+		active = append(active, id)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if len(active) == 1 {
+		return active[0], nil
+	}
+	return "", nil
+}
+
+func isContainerIDPrefix(s string) bool {
+	if len(s) < 12 || len(s) > 64 {
+		return false
+	}
+	for _, c := range strings.ToLower(s) {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ExportTraces stores the spans of the request. Spans of resources from another
+// project or with invalid IDs are rejected and counted in the partial success.
+func (r *OTLPReceiver) ExportTraces(ctx context.Context, projectID string, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	var spans []Span
+	var rejected int64
+	var reason string
+
+	for _, rs := range req.GetResourceSpans() {
+		resource := metrics.OTLPAttributes(rs.GetResource().GetAttributes())
+		count := 0
+		for _, ss := range rs.GetScopeSpans() {
+			count += len(ss.GetSpans())
+		}
+
+		deploymentID, err := r.resolveDeployment(ctx, projectID, resource)
+		if err == errOtherProject {
+			rejected += int64(count)
+			reason = err.Error()
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve deployment: %w", err)
+		}
+
+		resourceJSON := otlpAttributeValues(rs.GetResource().GetAttributes())
+		for _, ss := range rs.GetScopeSpans() {
+			for _, s := range ss.GetSpans() {
+				span, ok := convertSpan(s)
+				if !ok {
+					rejected++
+					reason = "invalid trace or span ID"
+					continue
+				}
+				span.ProjectID = projectID
+				span.DeploymentID = deploymentID
+				span.ServiceName = resource["service.name"]
+				span.Resource = resourceJSON
+				spans = append(spans, span)
+			}
+		}
+	}
+
+	if err := r.orchestrator.traces.StoreSpans(ctx, spans); err != nil {
+		return nil, err
+	}
+
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &coltracepb.ExportTracePartialSuccess{RejectedSpans: rejected, ErrorMessage: reason}
+	}
+	return resp, nil
+}
+
+func convertSpan(s *tracepb.Span) (Span, bool) {
+	if len(s.GetTraceId()) != 16 || len(s.GetSpanId()) != 8 {
+		return Span{}, false
+	}
+
+	span := Span{
+		TraceID:    hex.EncodeToString(s.GetTraceId()),
+		SpanID:     hex.EncodeToString(s.GetSpanId()),
+		Name:       s.GetName(),
+		Kind:       "internal",
+		StartTime:  time.Unix(0, int64(s.GetStartTimeUnixNano())).UTC(),
+		StatusCode: "unset",
+		Attributes: otlpAttributeValues(s.GetAttributes()),
+		Events:     []SpanEvent{},
+	}
+	if len(s.GetParentSpanId()) == 8 {
+		span.ParentSpanID = hex.EncodeToString(s.GetParentSpanId())
+	}
+	if s.GetEndTimeUnixNano() > s.GetStartTimeUnixNano() {
+		span.DurationUs = int64((s.GetEndTimeUnixNano() - s.GetStartTimeUnixNano()) / 1000)
+	}
+
+	switch s.GetKind() {
+	case tracepb.Span_SPAN_KIND_SERVER:
+		span.Kind = "server"
+	case tracepb.Span_SPAN_KIND_CLIENT:
+		span.Kind = "client"
+	case tracepb.Span_SPAN_KIND_PRODUCER:
+		span.Kind = "producer"
+	case tracepb.Span_SPAN_KIND_CONSUMER:
+		span.Kind = "consumer"
+	}
+	switch s.GetStatus().GetCode() {
+	case tracepb.Status_STATUS_CODE_OK:
+		span.StatusCode = "ok"
+	case tracepb.Status_STATUS_CODE_ERROR:
+		span.StatusCode = "error"
+		span.StatusMessage = s.GetStatus().GetMessage()
+	}
+
+	// Both the current and the pre-1.21 HTTP semantic conventions
+	attrs := metrics.OTLPAttributes(s.GetAttributes())
+	span.HTTPMethod = firstAttr(attrs, "http.request.method", "http.method")
+	span.HTTPRoute = attrs["http.route"]
+	span.HTTPPath = requestPath(firstAttr(attrs, "url.path", "http.target"))
+	fmt.Sscanf(firstAttr(attrs, "http.response.status_code", "http.status_code"), "%d", &span.HTTPStatusCode)
+
+	for _, e := range s.GetEvents() {
+		span.Events = append(span.Events, SpanEvent{
+			Name:       e.GetName(),
+			Timestamp:  time.Unix(0, int64(e.GetTimeUnixNano())).UTC(),
+			Attributes: otlpAttributeValues(e.GetAttributes()),
+		})
+	}
+	return span, true
+}
+
+// ExportLogs ingests the log records like container logs, so they are stored,
+// streamed, forwarded to log drains and tracked as error issues
+func (r *OTLPReceiver) ExportLogs(ctx context.Context, projectID string, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	var rejected int64
+	var reason string
+
+	for _, rl := range req.GetResourceLogs() {
+		resource := metrics.OTLPAttributes(rl.GetResource().GetAttributes())
+		count := 0
+		for _, sl := range rl.GetScopeLogs() {
+			count += len(sl.GetLogRecords())
+		}
+
+		deploymentID, err := r.resolveDeployment(ctx, projectID, resource)
+		if err != nil && err != errOtherProject {
+			return nil, fmt.Errorf("failed to resolve deployment: %w", err)
+		}
+		if err == errOtherProject || deploymentID == "" {
+			rejected += int64(count)
+			reason = "resource matches no deployment of the project; set the " + otlpDeploymentAttr + " resource attribute"
+			if err != nil {
+				reason = err.Error()
+			}
+			continue
+		}
+
+		containerID := resource["container.id"]
+		if containerID == "" {
+			containerID = truncateString("otlp:"+resource["service.name"], 255)
+		}
+		for _, sl := range rl.GetScopeLogs() {
+			for _, lr := range sl.GetLogRecords() {
+				r.orchestrator.logAggregator.ingest(ctx, convertLogRecord(lr, deploymentID, containerID, resource["service.name"]))
+			}
+		}
+	}
+
+	resp := &collogspb.ExportLogsServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &collogspb.ExportLogsPartialSuccess{RejectedLogRecords: rejected, ErrorMessage: reason}
+	}
+	return resp, nil
+}
+
+func convertLogRecord(lr *logspb.LogRecord, deploymentID, containerID, service string) *LogEntry {
+	ts := lr.GetTimeUnixNano()
+	if ts == 0 {
+		ts = lr.GetObservedTimeUnixNano()
+	}
+	timestamp := time.Now()
+	if ts > 0 {
+		timestamp = time.Unix(0, int64(ts))
+	}
+
+	fields := otlpAttributeValues(lr.GetAttributes())
+	attrs := metrics.OTLPAttributes(lr.GetAttributes())
+	message := metrics.OTLPValueString(lr.GetBody())
+	if message == "" && attrs["exception.message"] != "" {
+		message = strings.TrimPrefix(attrs["exception.type"]+": "+attrs["exception.message"], ": ")
+	}
+	// The error tracker reads stacks from these keys
+	if stack := attrs["exception.stacktrace"]; stack != "" {
+		fields["stacktrace"] = stack
+	}
+	if len(lr.GetTraceId()) == 16 {
+		fields["trace_id"] = hex.EncodeToString(lr.GetTraceId())
+	}
+	if len(lr.GetSpanId()) == 8 {
+		fields["span_id"] = hex.EncodeToString(lr.GetSpanId())
+	}
+	if service != "" {
+		fields["service.name"] = service
+	}
+	metadata, _ := json.Marshal(fields)
+
+	return &LogEntry{
+		DeploymentID: deploymentID,
+		ContainerID:  containerID,
+		Timestamp:    timestamp,
+		Level:        otlpSeverity(lr),
+		Message:      message,
+		Source:       "otlp",
+		Metadata:     string(metadata),
+	}
+}
+
+// otlpSeverity maps the record's severity to the levels container logs use
+func otlpSeverity(lr *logspb.LogRecord) string {
+	switch strings.ToLower(lr.GetSeverityText()) {
+	case "trace", "debug":
+		return "debug"
+	case "info", "information", "notice":
+		return "info"
+	case "warn", "warning":
+		return "warning"
+	case "error", "err":
+		return "error"
+	case "fatal", "critical", "crit", "panic", "emergency", "alert":
+		return "fatal"
+	}
+
+	switch n := lr.GetSeverityNumber(); {
+	case n == logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED:
+		return "info"
+	case n < logspb.SeverityNumber_SEVERITY_NUMBER_INFO:
+		return "debug"
+	case n < logspb.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return "info"
+	case n < logspb.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return "warning"
+	case n < logspb.SeverityNumber_SEVERITY_NUMBER_FATAL:
+		return "error"
+	}
+	return "fatal"
+}
+
+// ExportMetrics stores the metrics as app metrics of the resource's deployment, under
+// the same series limits as scraped ones
+func (r *OTLPReceiver) ExportMetrics(ctx context.Context, projectID string, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	var rejected int64
+	var reason string
+
+	for _, rm := range req.GetResourceMetrics() {
+		resource := metrics.OTLPAttributes(rm.GetResource().GetAttributes())
+		samples, unsupported := metrics.OTLPSamples(rm)
+		if unsupported > 0 {
+			rejected += int64(unsupported)
+			reason = "delta temporality and exponential histograms are not supported"
+		}
+
+		deploymentID, err := r.resolveDeployment(ctx, projectID, resource)
+		if err != nil && err != errOtherProject {
+			return nil, fmt.Errorf("failed to resolve deployment: %w", err)
+		}
+		if err == errOtherProject || deploymentID == "" {
+			for _, batch := range samples {
+				rejected += int64(len(batch))
+			}
+			reason = "resource matches no deployment of the project; set the " + otlpDeploymentAttr + " resource attribute"
+			if err != nil {
+				reason = err.Error()
+			}
+			continue
+		}
+
+		for sec, batch := range samples {
+			dropped, err := r.orchestrator.appScraper.IngestSamples(ctx, deploymentID, batch, time.Unix(sec, 0))
+			if err != nil {
+				return nil, err
+			}
+			if dropped > 0 {
+				rejected += int64(dropped)
+				reason = "series limit reached or series too large"
+			}
+		}
+	}
+
+	resp := &colmetricspb.ExportMetricsServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &colmetricspb.ExportMetricsPartialSuccess{RejectedDataPoints: rejected, ErrorMessage: reason}
+	}
+	return resp, nil
+}
+
+func otlpAttributeValues(attrs []*commonpb.KeyValue) map[string]interface{} {
+	out := make(map[string]interface{}, len(attrs))
+	for _, kv := range attrs {
+		out[kv.GetKey()] = metrics.OTLPValue(kv.GetValue())
+	}
+	return out
+}
+
+func firstAttr(attrs map[string]string, keys ...string) string {
+	for _, k := range keys {
+		if v := attrs[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
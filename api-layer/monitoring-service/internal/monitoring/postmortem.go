@@ -0,0 +1,209 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const postmortemInstructions = `Fill in the sections object only. Base the summary, root cause and contributing factors on the timeline, alerts and deployments; do not invent events that are not listed. Each action item needs a concrete description and a priority of high, medium or low. Leave owner empty unless the timeline names one.`
+
+// PostmortemDraft is an incident exported for review. The facts (timeline, alerts,
+// deployments, timings) are generated; Sections holds the narrative the AI agent or a
+// user fills in and saves back.
+type PostmortemDraft struct {
+	IncidentID        string                 `json:"incident_id"`
+	ProjectID         string                 `json:"project_id"`
+	Title             string                 `json:"title"`
+	Severity          string                 `json:"severity"`
+	Status            string                 `json:"status"`
+	StartedAt         time.Time              `json:"started_at"`
+	AcknowledgedAt    *time.Time             `json:"acknowledged_at,omitempty"`
+	MitigatedAt       *time.Time             `json:"mitigated_at,omitempty"`
+	ResolvedAt        *time.Time             `json:"resolved_at,omitempty"`
+	TimeToAckSeconds  *int64                 `json:"time_to_acknowledge_seconds,omitempty"`
+	TimeToResolveSecs *int64                 `json:"time_to_resolve_seconds,omitempty"`
+	Alerts            []PostmortemAlert      `json:"alerts"`
+	Deployments       []PostmortemDeployment `json:"deployments"`
+	Timeline          []PostmortemEvent      `json:"timeline"`
+	Sections          PostmortemSections     `json:"sections"`
+	Completed         bool                   `json:"completed"`
+	Instructions      string                 `json:"instructions"`
+}
+
+type PostmortemAlert struct {
+	Type       string     `json:"type"`
+	Severity   string     `json:"severity"`
+	Message    string     `json:"message"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+type PostmortemDeployment struct {
+	ID            string    `json:"id"`
+	Environment   string    `json:"environment"`
+	CommitHash    string    `json:"commit_hash"`
+	CommitMessage string    `json:"commit_message,omitempty"`
+	Status        string    `json:"status"`
+	IsRollback    bool      `json:"is_rollback"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type PostmortemEvent struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	User        string    `json:"user,omitempty"`
+}
+
+type PostmortemSections struct {
+	Summary             string                 `json:"summary"`
+	Impact              string                 `json:"impact"`
+	RootCause           string                 `json:"root_cause"`
+	ContributingFactors []string               `json:"contributing_factors"`
+	Resolution          string                 `json:"resolution"`
+	ActionItems         []PostmortemActionItem `json:"action_items"`
+	LessonsLearned      string                 `json:"lessons_learned"`
+}
+
+type PostmortemActionItem struct {
+	Description string `json:"description"`
+	Owner       string `json:"owner,omitempty"`
+	Priority    string `json:"priority"`
+}
+
+// GetPostmortemDraft builds the postmortem for an incident. Sections saved earlier are
+// returned as-is; otherwise they are seeded from the incident's root cause, resolution
+// and impact summary.
+func (im *IncidentManager) GetPostmortemDraft(ctx context.Context, incidentID string) (*PostmortemDraft, error) {
+	incident, err := im.GetIncidentWithTimeline(ctx, incidentID)
+	if err != nil {
+		return nil, err
+	}
+
+	draft := &PostmortemDraft{
+		IncidentID:     incident.ID,
+		ProjectID:      incident.ProjectID,
+		Title:          incident.Title,
+		Severity:       incident.Severity,
+		Status:         incident.Status,
+		StartedAt:      incident.StartedAt,
+		AcknowledgedAt: incident.AcknowledgedAt,
+		MitigatedAt:    incident.MitigatedAt,
+		ResolvedAt:     incident.ResolvedAt,
+		Alerts:         []PostmortemAlert{},
+		Deployments:    []PostmortemDeployment{},
+		Timeline:       []PostmortemEvent{},
+		Sections: PostmortemSections{
+			Impact:              incident.ImpactSummary,
+			RootCause:           incident.RootCause,
+			Resolution:          incident.Resolution,
+			ContributingFactors: []string{},
+			ActionItems:         []PostmortemActionItem{},
+		},
+		Instructions: postmortemInstructions,
+	}
+	if d := incident.TimeToAcknowledge(); d != nil {
+		secs := int64(d.Seconds())
+		draft.TimeToAckSeconds = &secs
+	}
+	if d := incident.TimeToResolve(); d != nil {
+		secs := int64(d.Seconds())
+		draft.TimeToResolveSecs = &secs
+	}
+	for _, ev := range incident.Timeline {
+		draft.Timeline = append(draft.Timeline, PostmortemEvent{
+			Timestamp: ev.Timestamp, Type: ev.EventType, Description: ev.Description, User: ev.User,
+		})
+	}
+
+	db := im.orchestrator.db
+	rows, err := db.QueryContext(ctx, `
+		SELECT a.alert_type, a.severity, a.alert_message, a.created_at, a.resolved_at
+		FROM incident_alerts ia
+		JOIN deployment_alerts a ON a.id = ia.alert_id
+		WHERE ia.incident_id = $1
+		ORDER BY a.created_at`, incidentID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var a PostmortemAlert
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&a.Type, &a.Severity, &a.Message, &a.CreatedAt, &resolvedAt); err == nil {
+			a.ResolvedAt = nullTimePtr(resolvedAt)
+			draft.Alerts = append(draft.Alerts, a)
+		}
+	}
+	rows.Close()
+
+	to := time.Now()
+	if incident.ResolvedAt != nil {
+		to = *incident.ResolvedAt
+	}
+	rows, err = db.QueryContext(ctx, `
+		SELECT id, environment, commit_hash, COALESCE(commit_message, ''), status,
+		       COALESCE(is_rollback, false), created_at
+		FROM deployments
+		WHERE project_id = $1 AND created_at BETWEEN $2 AND $3
+		ORDER BY created_at`, incident.ProjectID, incident.StartedAt.Add(-incidentTimelineLookback), to)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var d PostmortemDeployment
+		if err := rows.Scan(&d.ID, &d.Environment, &d.CommitHash, &d.CommitMessage, &d.Status, &d.IsRollback, &d.CreatedAt); err == nil {
+			draft.Deployments = append(draft.Deployments, d)
+		}
+	}
+	rows.Close()
+
+	var saved sql.NullString
+	if err := db.QueryRowContext(ctx, `SELECT postmortem FROM incidents WHERE id = $1`, incidentID).Scan(&saved); err != nil {
+		return nil, err
+	}
+	if saved.Valid && saved.String != "" {
+		var sections PostmortemSections
+		if err := json.Unmarshal([]byte(saved.String), &sections); err == nil {
+			draft.Sections = sections
+			draft.Completed = true
+		}
+	}
+
+	return draft, nil
+}
+
+// SavePostmortem stores the filled-in sections and copies the root cause, resolution and
+// impact onto the incident
+func (im *IncidentManager) SavePostmortem(ctx context.Context, incidentID, userID string, sections PostmortemSections) error {
+	if _, err := im.GetIncident(ctx, incidentID); err != nil {
+		return err
+	}
+
+	if sections.ContributingFactors == nil {
+		sections.ContributingFactors = []string{}
+	}
+	if sections.ActionItems == nil {
+		sections.ActionItems = []PostmortemActionItem{}
+	}
+	data, err := json.Marshal(sections)
+	if err != nil {
+		return err
+	}
+
+	_, err = im.orchestrator.db.ExecContext(ctx, `
+		UPDATE incidents
+		SET postmortem = $1,
+		    root_cause = COALESCE(NULLIF($2, ''), root_cause),
+		    resolution = COALESCE(NULLIF($3, ''), resolution),
+		    impact_summary = COALESCE(NULLIF($4, ''), impact_summary),
+		    updated_at = NOW()
+		WHERE id = $5`,
+		string(data), sections.RootCause, sections.Resolution, sections.Impact, incidentID)
+	if err != nil {
+		return err
+	}
+
+	return insertIncidentEvent(ctx, im.orchestrator.db, incidentID, "postmortem", "Postmortem updated", userID, "", time.Now())
+}
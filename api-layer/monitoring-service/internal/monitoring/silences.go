@@ -0,0 +1,294 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const maxSilenceDuration = 30 * 24 * time.Hour
+
+var (
+	ErrSilenceNotFound           = errors.New("silence not found")
+	ErrMaintenanceWindowNotFound = errors.New("maintenance window not found")
+	ErrDeploymentNotInProject    = errors.New("deployment does not belong to this project")
+)
+
+// SQL conditions matching an active silence or maintenance window for the alert a,
+// whose deployment is joined as ad
+const (
+	activeSilenceCondition = `
+		SELECT 'silence:' || s.id FROM alert_silences s
+		WHERE s.project_id = ad.project_id AND s.starts_at <= NOW() AND s.ends_at > NOW()
+		  AND (s.deployment_id IS NULL OR s.deployment_id = a.deployment_id)
+		  AND (s.alert_type IS NULL OR s.alert_type = a.alert_type)`
+	activeMaintenanceCondition = `
+		SELECT 'maintenance:' || mw.id FROM maintenance_windows mw
+		WHERE mw.project_id = ad.project_id AND mw.starts_at <= NOW() AND mw.ends_at > NOW()
+		  AND (mw.environment IS NULL OR mw.environment = ad.environment)`
+)
+
+// Silence mutes notifications of the alerts it matches until it ends
+type Silence struct {
+	ID           string    `json:"id"`
+	ProjectID    string    `json:"project_id"`
+	DeploymentID string    `json:"deployment_id,omitempty"` // Empty matches every deployment
+	AlertType    string    `json:"alert_type,omitempty"`    // Rule name or built-in alert type; empty matches every alert
+	Comment      string    `json:"comment"`
+	StartsAt     time.Time `json:"starts_at"`
+	EndsAt       time.Time `json:"ends_at"`
+	CreatedBy    string    `json:"created_by,omitempty"`
+	ExpiredBy    string    `json:"expired_by,omitempty"`
+	Active       bool      `json:"active"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// MaintenanceWindow mutes a project's alerts and keeps downtime out of uptime and SLOs
+type MaintenanceWindow struct {
+	ID          string    `json:"id"`
+	ProjectID   string    `json:"project_id"`
+	Environment string    `json:"environment,omitempty"` // Empty covers every environment
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Validate normalizes the silence; a silence without start starts now
+func (s *Silence) Validate() error {
+	s.Comment = strings.TrimSpace(s.Comment)
+	if s.Comment == "" || len(s.Comment) > 500 {
+		return fmt.Errorf("comment is required and must be at most 500 characters")
+	}
+	if s.DeploymentID != "" && uuid.Validate(s.DeploymentID) != nil {
+		return fmt.Errorf("deployment_id must be a UUID")
+	}
+	if len(s.AlertType) > 50 {
+		return fmt.Errorf("alert_type must be at most 50 characters")
+	}
+	if s.StartsAt.IsZero() {
+		s.StartsAt = time.Now()
+	}
+	if !s.EndsAt.After(s.StartsAt) || !s.EndsAt.After(time.Now()) {
+		return fmt.Errorf("ends_at must be in the future and after starts_at")
+	}
+	if s.EndsAt.Sub(s.StartsAt) > maxSilenceDuration {
+		return fmt.Errorf("silences can last at most 30 days")
+	}
+	return nil
+}
+
+// Validate normalizes the maintenance window
+func (w *MaintenanceWindow) Validate() error {
+	w.Name = strings.TrimSpace(w.Name)
+	if w.Name == "" || len(w.Name) > 100 {
+		return fmt.Errorf("name is required and must be at most 100 characters")
+	}
+	if w.Environment != "" && !probeEnvironments[w.Environment] {
+		return fmt.Errorf("environment must be production, staging or preview")
+	}
+	if w.StartsAt.IsZero() || w.EndsAt.IsZero() {
+		return fmt.Errorf("starts_at and ends_at are required")
+	}
+	if !w.EndsAt.After(w.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	if w.EndsAt.Sub(w.StartsAt) > maxSilenceDuration {
+		return fmt.Errorf("maintenance windows can last at most 30 days")
+	}
+	return nil
+}
+
+// suppress records the silence or maintenance window muting a new alert. Suppressed
+// alerts are kept out of incidents; their notification goes out if they are still
+// unresolved when the silence ends.
+func (am *AlertManager) suppress(ctx context.Context, alertID string) (string, error) {
+	var suppressedBy sql.NullString
+	err := am.orchestrator.db.QueryRowContext(ctx, `
+		UPDATE deployment_alerts a
+		SET suppressed_by = COALESCE((`+activeSilenceCondition+` LIMIT 1), (`+activeMaintenanceCondition+` LIMIT 1))
+		FROM deployments ad
+		WHERE a.id = $1 AND ad.id = a.deployment_id
+		RETURNING a.suppressed_by`, alertID).Scan(&suppressedBy)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return suppressedBy.String, err
+}
+
+// InMaintenance reports whether a deployment is inside one of its project's maintenance
+// windows
+func (am *AlertManager) InMaintenance(ctx context.Context, deploymentID string) (bool, error) {
+	var active bool
+	err := am.orchestrator.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM deployments d
+			JOIN maintenance_windows mw ON mw.project_id = d.project_id
+			WHERE d.id = $1 AND mw.starts_at <= NOW() AND mw.ends_at > NOW()
+			  AND (mw.environment IS NULL OR mw.environment = d.environment)
+		)`, deploymentID).Scan(&active)
+	return active, err
+}
+
+const silenceColumns = `id, project_id, COALESCE(deployment_id::text, ''), COALESCE(alert_type, ''), comment,
+	starts_at, ends_at, COALESCE(created_by_user_id::text, ''), COALESCE(expired_by_user_id::text, ''),
+	starts_at <= NOW() AND ends_at > NOW(), created_at`
+
+func scanSilence(scan func(dest ...interface{}) error) (*Silence, error) {
+	var s Silence
+	err := scan(&s.ID, &s.ProjectID, &s.DeploymentID, &s.AlertType, &s.Comment,
+		&s.StartsAt, &s.EndsAt, &s.CreatedBy, &s.ExpiredBy, &s.Active, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListSilences returns a project's current and upcoming silences, and the ones that
+// ended in the last week when includeExpired is set
+func (am *AlertManager) ListSilences(ctx context.Context, projectID string, includeExpired bool) ([]*Silence, error) {
+	rows, err := am.orchestrator.db.QueryContext(ctx, `
+		SELECT `+silenceColumns+` FROM alert_silences
+		WHERE project_id = $1 AND (ends_at > NOW() OR ($2 AND ends_at > NOW() - INTERVAL '7 days'))
+		ORDER BY starts_at DESC`, projectID, includeExpired)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	silences := []*Silence{}
+	for rows.Next() {
+		s, err := scanSilence(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		silences = append(silences, s)
+	}
+	return silences, rows.Err()
+}
+
+// CreateSilence stores a validated silence. Its deployment must belong to the project.
+func (am *AlertManager) CreateSilence(ctx context.Context, projectID, userID string, s *Silence) error {
+	if s.DeploymentID != "" {
+		var exists bool
+		err := am.orchestrator.db.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM deployments WHERE id = $1 AND project_id = $2)`, s.DeploymentID, projectID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrDeploymentNotInProject
+		}
+	}
+
+	s.ProjectID = projectID
+	s.CreatedBy = userID
+	return am.orchestrator.db.QueryRowContext(ctx, `
+		INSERT INTO alert_silences (project_id, deployment_id, alert_type, comment, starts_at, ends_at, created_by_user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, starts_at <= NOW() AND ends_at > NOW(), created_at`,
+		projectID, nullIfEmpty(s.DeploymentID), nullIfEmpty(s.AlertType), s.Comment, s.StartsAt, s.EndsAt, nullIfEmpty(userID),
+	).Scan(&s.ID, &s.Active, &s.CreatedAt)
+}
+
+// ExpireSilence ends a silence now
+func (am *AlertManager) ExpireSilence(ctx context.Context, projectID, silenceID, userID string) error {
+	res, err := am.orchestrator.db.ExecContext(ctx, `
+		UPDATE alert_silences
+		SET ends_at = GREATEST(NOW(), starts_at + INTERVAL '1 second'), expired_by_user_id = $3
+		WHERE id = $1 AND project_id = $2 AND ends_at > NOW()`, silenceID, projectID, nullIfEmpty(userID))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSilenceNotFound
+	}
+	return nil
+}
+
+const maintenanceWindowColumns = `id, project_id, COALESCE(environment, ''), name, COALESCE(description, ''),
+	starts_at, ends_at, COALESCE(created_by_user_id::text, ''), starts_at <= NOW() AND ends_at > NOW(), created_at`
+
+func scanMaintenanceWindow(scan func(dest ...interface{}) error) (*MaintenanceWindow, error) {
+	var w MaintenanceWindow
+	err := scan(&w.ID, &w.ProjectID, &w.Environment, &w.Name, &w.Description,
+		&w.StartsAt, &w.EndsAt, &w.CreatedBy, &w.Active, &w.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// ListMaintenanceWindows returns a project's current and upcoming windows, and the
+// ones of the last 30 days when includePast is set
+func (am *AlertManager) ListMaintenanceWindows(ctx context.Context, projectID string, includePast bool) ([]*MaintenanceWindow, error) {
+	rows, err := am.orchestrator.db.QueryContext(ctx, `
+		SELECT `+maintenanceWindowColumns+` FROM maintenance_windows
+		WHERE project_id = $1 AND (ends_at > NOW() OR ($2 AND ends_at > NOW() - INTERVAL '30 days'))
+		ORDER BY starts_at`, projectID, includePast)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []*MaintenanceWindow{}
+	for rows.Next() {
+		w, err := scanMaintenanceWindow(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, rows.Err()
+}
+
+// CreateMaintenanceWindow schedules a validated maintenance window
+func (am *AlertManager) CreateMaintenanceWindow(ctx context.Context, projectID, userID string, w *MaintenanceWindow) error {
+	w.ProjectID = projectID
+	w.CreatedBy = userID
+	return am.orchestrator.db.QueryRowContext(ctx, `
+		INSERT INTO maintenance_windows (project_id, environment, name, description, starts_at, ends_at, created_by_user_id)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7)
+		RETURNING id, starts_at <= NOW() AND ends_at > NOW(), created_at`,
+		projectID, nullIfEmpty(w.Environment), w.Name, w.Description, w.StartsAt, w.EndsAt, nullIfEmpty(userID),
+	).Scan(&w.ID, &w.Active, &w.CreatedAt)
+}
+
+// UpdateMaintenanceWindow reschedules a window, e.g. to extend a running migration
+func (am *AlertManager) UpdateMaintenanceWindow(ctx context.Context, projectID, windowID string, w *MaintenanceWindow) error {
+	w.ID = windowID
+	w.ProjectID = projectID
+	err := am.orchestrator.db.QueryRowContext(ctx, `
+		UPDATE maintenance_windows
+		SET environment = $3, name = $4, description = NULLIF($5, ''), starts_at = $6, ends_at = $7, updated_at = NOW()
+		WHERE id = $1 AND project_id = $2
+		RETURNING COALESCE(created_by_user_id::text, ''), starts_at <= NOW() AND ends_at > NOW(), created_at`,
+		windowID, projectID, nullIfEmpty(w.Environment), w.Name, w.Description, w.StartsAt, w.EndsAt,
+	).Scan(&w.CreatedBy, &w.Active, &w.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrMaintenanceWindowNotFound
+	}
+	return err
+}
+
+// DeleteMaintenanceWindow removes a window. Records taken during it keep their
+// maintenance flag.
+func (am *AlertManager) DeleteMaintenanceWindow(ctx context.Context, projectID, windowID string) error {
+	res, err := am.orchestrator.db.ExecContext(ctx, `
+		DELETE FROM maintenance_windows WHERE id = $1 AND project_id = $2`, windowID, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMaintenanceWindowNotFound
+	}
+	return nil
+}
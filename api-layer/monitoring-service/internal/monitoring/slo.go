@@ -0,0 +1,458 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"monitoring-service/pkg/logger"
+	"monitoring-service/pkg/models"
+
+	"github.com/lib/pq"
+)
+
+const (
+	SLOTypeAvailability = "availability"
+	SLOTypeLatency      = "latency"
+)
+
+var (
+	ErrSLONotFound  = errors.New("SLO not found")
+	ErrSLONameTaken = errors.New("an SLO with this name already exists")
+)
+
+// sloBurnWindows are the multi-window burn-rate alerts: each fires when both the long and
+// the short window burn faster than the rate that would spend budgetSpent of the error
+// budget over the long window. The short window makes the alert clear quickly once the
+// burn stops.
+var sloBurnWindows = []struct {
+	name        string
+	long, short time.Duration
+	budgetSpent float64
+	severity    string
+}{
+	{"1h/5m", time.Hour, 5 * time.Minute, 0.02, "critical"},
+	{"6h/30m", 6 * time.Hour, 30 * time.Minute, 0.05, "critical"},
+	{"1d/2h", 24 * time.Hour, 2 * time.Hour, 0.10, "warning"},
+	{"3d/6h", 72 * time.Hour, 6 * time.Hour, 0.10, "warning"},
+}
+
+// SLO is an availability or latency objective over a 28 or 30 day rolling window,
+// measured on synthetic probe results
+type SLO struct {
+	ID                 string    `json:"id"`
+	ProjectID          string    `json:"project_id"`
+	Environment        string    `json:"environment"`
+	Name               string    `json:"name"`
+	Type               string    `json:"type"`
+	Target             float64   `json:"target"` // Percentage of good checks, e.g. 99.9
+	LatencyThresholdMs int       `json:"latency_threshold_ms,omitempty"`
+	WindowDays         int       `json:"window_days"`
+	ProbeID            string    `json:"probe_id,omitempty"` // Empty measures every probe of the environment
+	Enabled            bool      `json:"enabled"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// SLOStatus is an SLO's current SLI, remaining error budget and burn rates
+type SLOStatus struct {
+	SLO             *SLO       `json:"slo"`
+	TotalChecks     int64      `json:"total_checks"`
+	BadChecks       int64      `json:"bad_checks"`
+	SLI             *float64   `json:"sli"`              // Percentage of good checks over the window; nil without data
+	ErrorBudget     float64    `json:"error_budget"`     // Allowed fraction of bad checks
+	BudgetRemaining float64    `json:"budget_remaining"` // Fraction of the budget left; negative once exhausted
+	BurnRates       []BurnRate `json:"burn_rates"`
+	Alerting        bool       `json:"alerting"`
+}
+
+// BurnRate is how fast the error budget is spent relative to spending it exactly over the
+// SLO window (1.0)
+type BurnRate struct {
+	Window    string  `json:"window"`
+	LongRate  float64 `json:"long_rate"`
+	ShortRate float64 `json:"short_rate"`
+	Threshold float64 `json:"threshold"`
+	Severity  string  `json:"severity"`
+	Firing    bool    `json:"firing"`
+}
+
+// Validate normalizes the SLO and applies defaults
+func (s *SLO) Validate() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" || len(s.Name) > 100 {
+		return fmt.Errorf("name is required and must be at most 100 characters")
+	}
+
+	if s.Environment == "" {
+		s.Environment = "production"
+	}
+	if !probeEnvironments[s.Environment] {
+		return fmt.Errorf("environment must be production, staging or preview")
+	}
+
+	switch s.Type {
+	case SLOTypeAvailability:
+		s.LatencyThresholdMs = 0
+	case SLOTypeLatency:
+		if s.LatencyThresholdMs <= 0 {
+			return fmt.Errorf("latency_threshold_ms is required for latency SLOs")
+		}
+	default:
+		return fmt.Errorf("type must be availability or latency")
+	}
+
+	if s.Target <= 0 || s.Target >= 100 {
+		return fmt.Errorf("target must be a percentage between 0 and 100, e.g. 99.9")
+	}
+
+	if s.WindowDays == 0 {
+		s.WindowDays = 30
+	}
+	if s.WindowDays != 28 && s.WindowDays != 30 {
+		return fmt.Errorf("window_days must be 28 or 30")
+	}
+
+	return nil
+}
+
+// SLOEngine computes error budgets and raises burn-rate alerts
+type SLOEngine struct {
+	orchestrator *Orchestrator
+}
+
+func NewSLOEngine(o *Orchestrator) *SLOEngine {
+	return &SLOEngine{orchestrator: o}
+}
+
+const sloColumns = `id, project_id, environment, name, slo_type, target, COALESCE(latency_threshold_ms, 0),
+	window_days, COALESCE(probe_id::text, ''), COALESCE(enabled, true), created_at`
+
+func scanSLO(scan func(dest ...interface{}) error) (*SLO, error) {
+	var s SLO
+	err := scan(&s.ID, &s.ProjectID, &s.Environment, &s.Name, &s.Type, &s.Target, &s.LatencyThresholdMs,
+		&s.WindowDays, &s.ProbeID, &s.Enabled, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Status computes the SLI over the SLO window and the burn rate of every alert window
+func (se *SLOEngine) Status(ctx context.Context, slo *SLO) (*SLOStatus, error) {
+	// Latency SLOs only count successful checks; failures burn the availability budget
+	args := []interface{}{slo.ProjectID, slo.Environment, slo.ProbeID, slo.WindowDays}
+	bad := "NOT r.success"
+	scope := ""
+	if slo.Type == SLOTypeLatency {
+		bad = "r.latency_ms > $5"
+		scope = "AND r.success"
+		args = append(args, slo.LatencyThresholdMs)
+	}
+
+	window := time.Duration(slo.WindowDays) * 24 * time.Hour
+	durations := []time.Duration{window}
+	for _, w := range sloBurnWindows {
+		durations = append(durations, w.long, w.short)
+	}
+
+	var cols []string
+	for _, d := range durations {
+		since := fmt.Sprintf("r.checked_at > NOW() - make_interval(secs => %d)", int64(d.Seconds()))
+		cols = append(cols,
+			fmt.Sprintf("COUNT(*) FILTER (WHERE %s)", since),
+			fmt.Sprintf("COUNT(*) FILTER (WHERE %s AND %s)", since, bad))
+	}
+
+	counts := make([]int64, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+
+	err := se.orchestrator.db.QueryRowContext(ctx, `
+		SELECT `+strings.Join(cols, ", ")+`
+		FROM synthetic_probe_results r
+		JOIN synthetic_probes p ON p.id = r.probe_id
+		WHERE p.project_id = $1 AND p.environment = $2 AND ($3 = '' OR p.id::text = $3)
+		  AND NOT r.in_maintenance
+		  AND r.checked_at > NOW() - make_interval(days => $4) `+scope, args...).Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+
+	budget := 1 - slo.Target/100
+	badRatio := func(i int) float64 {
+		if counts[2*i] == 0 {
+			return 0
+		}
+		return float64(counts[2*i+1]) / float64(counts[2*i])
+	}
+
+	status := &SLOStatus{
+		SLO:             slo,
+		TotalChecks:     counts[0],
+		BadChecks:       counts[1],
+		ErrorBudget:     budget,
+		BudgetRemaining: 1 - badRatio(0)/budget,
+		BurnRates:       make([]BurnRate, 0, len(sloBurnWindows)),
+	}
+	if counts[0] > 0 {
+		sli := (1 - badRatio(0)) * 100
+		status.SLI = &sli
+	}
+
+	for i, w := range sloBurnWindows {
+		rate := BurnRate{
+			Window:    w.name,
+			LongRate:  badRatio(1+2*i) / budget,
+			ShortRate: badRatio(2+2*i) / budget,
+			Threshold: w.budgetSpent * window.Hours() / w.long.Hours(),
+			Severity:  w.severity,
+		}
+		rate.Firing = rate.LongRate >= rate.Threshold && rate.ShortRate >= rate.Threshold
+		status.Alerting = status.Alerting || rate.Firing
+		status.BurnRates = append(status.BurnRates, rate)
+	}
+
+	return status, nil
+}
+
+// Evaluate raises a deployment alert for every burn window that starts firing, so the
+// incident correlator groups it with the alerts that explain it, and resolves the alert
+// once the window stops firing
+func (se *SLOEngine) Evaluate(ctx context.Context) error {
+	rows, err := se.orchestrator.db.QueryContext(ctx, `SELECT `+sloColumns+` FROM slo_definitions WHERE enabled = true`)
+	if err != nil {
+		return err
+	}
+	var slos []*SLO
+	for rows.Next() {
+		s, err := scanSLO(rows.Scan)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		slos = append(slos, s)
+	}
+	rows.Close()
+
+	for _, slo := range slos {
+		status, err := se.Status(ctx, slo)
+		if err != nil {
+			logger.Error("Failed to compute SLO status", logger.String("slo_id", slo.ID), logger.Err(err))
+			continue
+		}
+		for _, rate := range status.BurnRates {
+			if err := se.syncBurnAlert(ctx, status, rate); err != nil {
+				logger.Error("Failed to update SLO burn alert", logger.String("slo_id", slo.ID),
+					logger.String("window", rate.Window), logger.Err(err))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (se *SLOEngine) syncBurnAlert(ctx context.Context, status *SLOStatus, rate BurnRate) error {
+	db := se.orchestrator.db
+	slo := status.SLO
+
+	var alertID string
+	err := db.QueryRowContext(ctx, `
+		SELECT alert_id FROM slo_burn_alerts WHERE slo_id = $1 AND burn_window = $2`, slo.ID, rate.Window).Scan(&alertID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	firing := alertID != ""
+
+	if !rate.Firing {
+		if !firing {
+			return nil
+		}
+		if _, err := db.ExecContext(ctx, `
+			UPDATE deployment_alerts SET resolved = true, resolved_at = NOW()
+			WHERE id = $1 AND resolved = false`, alertID); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, `DELETE FROM slo_burn_alerts WHERE slo_id = $1 AND burn_window = $2`, slo.ID, rate.Window)
+		return err
+	}
+	if firing {
+		return nil
+	}
+
+	// Alerts are attached to the deployment serving the environment
+	var deploymentID string
+	err = db.QueryRowContext(ctx, `
+		SELECT id FROM deployments
+		WHERE project_id = $1 AND environment = $2 AND status IN ('active', 'running', 'healthy')
+		ORDER BY created_at DESC
+		LIMIT 1`, slo.ProjectID, slo.Environment).Scan(&deploymentID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	alert := &models.Alert{
+		DeploymentID: deploymentID,
+		Severity:     rate.Severity,
+		Title:        fmt.Sprintf("SLO %s burning error budget", slo.Name),
+		Description: fmt.Sprintf("SLO %q (%s %.3g%% over %d days) is burning its error budget %.1fx over %s (threshold %.1fx); %.0f%% of the budget left",
+			slo.Name, slo.Type, slo.Target, slo.WindowDays, rate.LongRate, rate.Window, rate.Threshold, status.BudgetRemaining*100),
+		MetricType:     "slo_burn",
+		ThresholdValue: rate.Threshold,
+		CurrentValue:   rate.LongRate,
+		Metadata: map[string]interface{}{
+			"slo_id":           slo.ID,
+			"burn_window":      rate.Window,
+			"budget_remaining": status.BudgetRemaining,
+		},
+	}
+	if err := se.orchestrator.alertManager.TriggerAlert(ctx, alert); err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO slo_burn_alerts (slo_id, burn_window, alert_id, burn_rate)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (slo_id, burn_window) DO NOTHING`, slo.ID, rate.Window, alert.ID, rate.LongRate)
+	return err
+}
+
+// checkSLOProbe makes sure an SLO's probe belongs to the project and measures its
+// environment
+func (se *SLOEngine) checkSLOProbe(ctx context.Context, projectID string, s *SLO) error {
+	if s.ProbeID == "" {
+		return nil
+	}
+	var environment string
+	err := se.orchestrator.db.QueryRowContext(ctx, `
+		SELECT environment FROM synthetic_probes WHERE id = $1 AND project_id = $2`, s.ProbeID, projectID).Scan(&environment)
+	if err == sql.ErrNoRows {
+		return ErrProbeNotFound
+	}
+	if err != nil {
+		return err
+	}
+	s.Environment = environment
+	return nil
+}
+
+// CreateSLO stores a validated SLO for a project
+func (se *SLOEngine) CreateSLO(ctx context.Context, projectID string, s *SLO) error {
+	if err := se.checkSLOProbe(ctx, projectID, s); err != nil {
+		return err
+	}
+
+	s.ProjectID = projectID
+	err := se.orchestrator.db.QueryRowContext(ctx, `
+		INSERT INTO slo_definitions (
+			project_id, environment, name, slo_type, target, latency_threshold_ms, window_days, probe_id, enabled
+		) VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), $7, $8, $9)
+		RETURNING id, created_at`,
+		projectID, s.Environment, s.Name, s.Type, s.Target, s.LatencyThresholdMs, s.WindowDays, nullIfEmpty(s.ProbeID), s.Enabled,
+	).Scan(&s.ID, &s.CreatedAt)
+	return sloWriteError(err)
+}
+
+// UpdateSLO replaces an SLO's definition. Firing burn alerts are re-evaluated on the
+// next cycle against the new target.
+func (se *SLOEngine) UpdateSLO(ctx context.Context, projectID, sloID string, s *SLO) error {
+	if err := se.checkSLOProbe(ctx, projectID, s); err != nil {
+		return err
+	}
+
+	s.ID = sloID
+	s.ProjectID = projectID
+	err := se.orchestrator.db.QueryRowContext(ctx, `
+		UPDATE slo_definitions
+		SET environment = $3, name = $4, slo_type = $5, target = $6, latency_threshold_ms = NULLIF($7, 0),
+			window_days = $8, probe_id = $9, enabled = $10, updated_at = NOW()
+		WHERE id = $1 AND project_id = $2
+		RETURNING created_at`,
+		sloID, projectID, s.Environment, s.Name, s.Type, s.Target, s.LatencyThresholdMs,
+		s.WindowDays, nullIfEmpty(s.ProbeID), s.Enabled,
+	).Scan(&s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrSLONotFound
+	}
+	return sloWriteError(err)
+}
+
+// DeleteSLO removes an SLO and resolves its firing burn alerts
+func (se *SLOEngine) DeleteSLO(ctx context.Context, projectID, sloID string) error {
+	db := se.orchestrator.db
+	_, err := db.ExecContext(ctx, `
+		UPDATE deployment_alerts SET resolved = true, resolved_at = NOW()
+		WHERE resolved = false AND id IN (
+			SELECT b.alert_id FROM slo_burn_alerts b
+			JOIN slo_definitions s ON s.id = b.slo_id
+			WHERE s.id = $1 AND s.project_id = $2
+		)`, sloID, projectID)
+	if err != nil {
+		return err
+	}
+
+	res, err := db.ExecContext(ctx, `DELETE FROM slo_definitions WHERE id = $1 AND project_id = $2`, sloID, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSLONotFound
+	}
+	return nil
+}
+
+// GetSLO returns one of a project's SLOs
+func (se *SLOEngine) GetSLO(ctx context.Context, projectID, sloID string) (*SLO, error) {
+	s, err := scanSLO(se.orchestrator.db.QueryRowContext(ctx, `
+		SELECT `+sloColumns+` FROM slo_definitions WHERE id = $1 AND project_id = $2`, sloID, projectID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrSLONotFound
+	}
+	return s, err
+}
+
+// ListSLOStatuses returns every SLO of a project with its current error budget
+func (se *SLOEngine) ListSLOStatuses(ctx context.Context, projectID string) ([]*SLOStatus, error) {
+	rows, err := se.orchestrator.db.QueryContext(ctx, `
+		SELECT `+sloColumns+` FROM slo_definitions
+		WHERE project_id = $1
+		ORDER BY environment, name`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	var slos []*SLO
+	for rows.Next() {
+		s, err := scanSLO(rows.Scan)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		slos = append(slos, s)
+	}
+	rows.Close()
+
+	statuses := make([]*SLOStatus, 0, len(slos))
+	for _, s := range slos {
+		status, err := se.Status(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func sloWriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrSLONameTaken
+	}
+	return err
+}
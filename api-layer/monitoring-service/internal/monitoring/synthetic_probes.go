@@ -0,0 +1,681 @@
+package monitoring
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"monitoring-service/pkg/logger"
+	"monitoring-service/pkg/models"
+
+	"github.com/lib/pq"
+)
+
+const (
+	DefaultProbeTLSExpiryWarnDays = 14
+
+	defaultProbeTimeout  = 10 * time.Second
+	defaultProbeInterval = 60
+	// Bodies are read up to this size for body matching
+	probeBodyLimit = 1 << 20
+	// Probes running at once per cycle
+	probeConcurrency = 10
+	// Default uptime probes reuse a recent configured probe result instead of probing again
+	probeResultFreshness = 5 * time.Minute
+
+	// probeHostOwnedSQL holds when host ($2 or p.host) is served for the project ($1 or
+	// p.project_id): a deployment domain or a verified custom domain, wildcards included
+	probeHostOwnedSQL = `(EXISTS (
+			SELECT 1 FROM deployments WHERE project_id = %[1]s AND lower(domain) = %[2]s
+		) OR EXISTS (
+			SELECT 1 FROM project_domains
+			WHERE project_id = %[1]s AND verification_status = 'verified'
+			  AND (domain = %[2]s OR (is_wildcard AND %[2]s LIKE '%%.' || domain))
+		))`
+)
+
+var (
+	ErrProbeNotFound   = errors.New("probe not found")
+	ErrProbeNameTaken  = errors.New("a probe with this name already exists")
+	ErrProbeHostDenied = errors.New("host must be one of the project's deployment domains or verified custom domains")
+
+	probeMethods      = map[string]bool{"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true}
+	probeEnvironments = map[string]bool{"production": true, "staging": true, "preview": true}
+	probeHostPattern  = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+)
+
+// SyntheticProbe is an HTTP check sent through Traefik to a project's public route, so
+// routing, TLS and the app itself are all measured the way users reach them
+type SyntheticProbe struct {
+	ID                string            `json:"id"`
+	ProjectID         string            `json:"project_id"`
+	Environment       string            `json:"environment"`
+	Name              string            `json:"name"`
+	Method            string            `json:"method"`
+	Scheme            string            `json:"scheme"`
+	Host              string            `json:"host,omitempty"` // Empty probes the active deployment's domain
+	Path              string            `json:"path"`
+	Headers           map[string]string `json:"headers"`
+	Body              string            `json:"body,omitempty"`
+	ExpectedStatus    int               `json:"expected_status,omitempty"` // 0 accepts any 2xx/3xx
+	BodyMatch         string            `json:"body_match,omitempty"`
+	BodyMatchRegex    bool              `json:"body_match_regex"`
+	TLSExpiryWarnDays int               `json:"tls_expiry_warn_days"`
+	TimeoutMs         int               `json:"timeout_ms"`
+	IntervalSeconds   int               `json:"interval_seconds"`
+	Enabled           bool              `json:"enabled"`
+	LastRunAt         *time.Time        `json:"last_run_at,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+
+	bodyPattern *regexp.Regexp
+}
+
+// ProbeResult is the outcome of one probe run
+type ProbeResult struct {
+	ProbeID      string     `json:"probe_id"`
+	DeploymentID string     `json:"deployment_id,omitempty"`
+	Success      bool       `json:"success"`
+	StatusCode   int        `json:"status_code,omitempty"`
+	LatencyMs    int        `json:"latency_ms"`
+	Error        string     `json:"error,omitempty"`
+	TLSExpiresAt *time.Time `json:"tls_expires_at,omitempty"`
+	CheckedAt    time.Time  `json:"checked_at"`
+}
+
+// Validate normalizes the probe and applies defaults
+func (p *SyntheticProbe) Validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" || len(p.Name) > 100 {
+		return fmt.Errorf("name is required and must be at most 100 characters")
+	}
+
+	if p.Environment == "" {
+		p.Environment = "production"
+	}
+	if !probeEnvironments[p.Environment] {
+		return fmt.Errorf("environment must be production, staging or preview")
+	}
+
+	p.Method = strings.ToUpper(strings.TrimSpace(p.Method))
+	if p.Method == "" {
+		p.Method = "GET"
+	}
+	if !probeMethods[p.Method] {
+		return fmt.Errorf("unsupported method %q", p.Method)
+	}
+
+	if p.Scheme == "" {
+		p.Scheme = "https"
+	}
+	if p.Scheme != "http" && p.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https")
+	}
+
+	p.Host = strings.ToLower(strings.TrimSpace(p.Host))
+	if p.Host != "" && !probeHostPattern.MatchString(p.Host) {
+		return fmt.Errorf("host must be a bare domain name")
+	}
+
+	if p.Path == "" {
+		p.Path = "/"
+	}
+	if !strings.HasPrefix(p.Path, "/") {
+		p.Path = "/" + p.Path
+	}
+	if len(p.Path) > 1024 {
+		return fmt.Errorf("path must be at most 1024 characters")
+	}
+
+	for name := range p.Headers {
+		if strings.EqualFold(name, "Host") {
+			return fmt.Errorf("set the host field instead of a Host header")
+		}
+	}
+
+	if p.ExpectedStatus != 0 && (p.ExpectedStatus < 100 || p.ExpectedStatus > 599) {
+		return fmt.Errorf("expected_status must be a valid HTTP status code")
+	}
+
+	if p.BodyMatchRegex && p.BodyMatch != "" {
+		re, err := regexp.Compile(p.BodyMatch)
+		if err != nil {
+			return fmt.Errorf("invalid body_match expression: %v", err)
+		}
+		p.bodyPattern = re
+	}
+
+	if p.TimeoutMs == 0 {
+		p.TimeoutMs = int(defaultProbeTimeout / time.Millisecond)
+	}
+	if p.IntervalSeconds == 0 {
+		p.IntervalSeconds = defaultProbeInterval
+	}
+	if p.TimeoutMs < 100 || p.TimeoutMs > 60000 {
+		return fmt.Errorf("timeout_ms must be between 100 and 60000")
+	}
+	if p.IntervalSeconds < 10 || p.IntervalSeconds > 86400 {
+		return fmt.Errorf("interval_seconds must be between 10 and 86400")
+	}
+	if p.TimeoutMs >= p.IntervalSeconds*1000 {
+		return fmt.Errorf("timeout_ms must be shorter than the interval")
+	}
+
+	if p.TLSExpiryWarnDays < 0 || p.TLSExpiryWarnDays > 365 {
+		return fmt.Errorf("tls_expiry_warn_days must be between 0 and 365")
+	}
+
+	return nil
+}
+
+// SyntheticProber runs due probes and records their results
+type SyntheticProber struct {
+	orchestrator *Orchestrator
+	roots        *x509.CertPool
+}
+
+func NewSyntheticProber(o *Orchestrator) *SyntheticProber {
+	return &SyntheticProber{
+		orchestrator: o,
+		roots:        probeRootPool(o.config.ProbeCARoots),
+	}
+}
+
+// probeRootPool returns the system roots plus an extra CA bundle (e.g. Pebble's roots)
+func probeRootPool(path string) *x509.CertPool {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			logger.Warn("Failed to read probe CA roots", logger.String("path", path), logger.Err(err))
+		} else if !pool.AppendCertsFromPEM(pem) {
+			logger.Warn("No certificates found in probe CA roots", logger.String("path", path))
+		}
+	}
+	return pool
+}
+
+const syntheticProbeColumns = `p.id, p.project_id, p.environment, p.name, p.method, p.scheme, COALESCE(p.host, ''), p.path,
+	COALESCE(p.request_headers, '{}'), COALESCE(p.request_body, ''), COALESCE(p.expected_status, 0),
+	COALESCE(p.body_match, ''), COALESCE(p.body_match_regex, false), COALESCE(p.tls_expiry_warn_days, 0),
+	p.timeout_ms, p.interval_seconds, COALESCE(p.enabled, true), p.last_run_at, p.created_at`
+
+func scanSyntheticProbe(scan func(dest ...interface{}) error, extra ...interface{}) (*SyntheticProbe, error) {
+	var p SyntheticProbe
+	var headers []byte
+	var lastRunAt sql.NullTime
+	dest := []interface{}{&p.ID, &p.ProjectID, &p.Environment, &p.Name, &p.Method, &p.Scheme, &p.Host, &p.Path,
+		&headers, &p.Body, &p.ExpectedStatus, &p.BodyMatch, &p.BodyMatchRegex, &p.TLSExpiryWarnDays,
+		&p.TimeoutMs, &p.IntervalSeconds, &p.Enabled, &lastRunAt, &p.CreatedAt}
+	if err := scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	p.LastRunAt = nullTimePtr(lastRunAt)
+	p.Headers = map[string]string{}
+	if len(headers) > 0 {
+		json.Unmarshal(headers, &p.Headers)
+	}
+	if p.BodyMatchRegex && p.BodyMatch != "" {
+		p.bodyPattern, _ = regexp.Compile(p.BodyMatch)
+	}
+	return &p, nil
+}
+
+// RunDue runs every enabled probe whose interval elapsed against the environment's
+// active deployment. Environments without a serving deployment are skipped so an
+// undeployed project does not burn its error budget, and so are probes whose host no
+// longer belongs to the project.
+func (sp *SyntheticProber) RunDue(ctx context.Context) error {
+	rows, err := sp.orchestrator.db.QueryContext(ctx, `
+		SELECT `+syntheticProbeColumns+`, d.id, COALESCE(d.domain, '')
+		FROM synthetic_probes p
+		JOIN LATERAL (
+			SELECT id, domain FROM deployments
+			WHERE project_id = p.project_id AND environment = p.environment
+			  AND status IN ('active', 'running', 'healthy')
+			ORDER BY created_at DESC
+			LIMIT 1
+		) d ON true
+		WHERE p.enabled = true
+		  AND (p.host IS NULL OR `+fmt.Sprintf(probeHostOwnedSQL, "p.project_id", "p.host")+`)
+		  AND (p.last_run_at IS NULL OR p.last_run_at <= NOW() - make_interval(secs => p.interval_seconds))`)
+	if err != nil {
+		return err
+	}
+
+	type dueProbe struct {
+		probe        *SyntheticProbe
+		deploymentID string
+		domain       string
+	}
+	var due []dueProbe
+	for rows.Next() {
+		var d dueProbe
+		probe, err := scanSyntheticProbe(rows.Scan, &d.deploymentID, &d.domain)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		d.probe = probe
+		due = append(due, d)
+	}
+	rows.Close()
+
+	sem := make(chan struct{}, probeConcurrency)
+	var wg sync.WaitGroup
+	for _, d := range due {
+		// Claim the run so concurrent monitoring replicas do not probe twice
+		res, err := sp.orchestrator.db.ExecContext(ctx, `
+			UPDATE synthetic_probes SET last_run_at = NOW()
+			WHERE id = $1 AND last_run_at IS NOT DISTINCT FROM $2`, d.probe.ID, d.probe.LastRunAt)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+
+		host := d.probe.Host
+		if host == "" {
+			host = d.domain
+		}
+		if host == "" {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(d dueProbe, host string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := sp.execute(ctx, d.probe, host)
+			result.DeploymentID = d.deploymentID
+			if err := sp.storeResult(ctx, result); err != nil {
+				logger.Error("Failed to store probe result", logger.String("probe_id", d.probe.ID), logger.Err(err))
+			}
+			sp.checkCertificateExpiry(ctx, d.probe, host, result)
+		}(d, host)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// execute sends the probe to Traefik with the route's host as Host header and SNI.
+// Redirects are not followed so expected_status can assert them.
+func (sp *SyntheticProber) execute(ctx context.Context, probe *SyntheticProbe, host string) *ProbeResult {
+	result := &ProbeResult{ProbeID: probe.ID, CheckedAt: time.Now()}
+
+	addr := sp.orchestrator.config.TraefikHTTPAddress
+	port := "80"
+	if probe.Scheme == "https" {
+		addr = sp.orchestrator.config.TraefikTLSAddress
+		port = "443"
+	}
+	if addr == "" {
+		addr = net.JoinHostPort(host, port)
+	}
+
+	timeout := time.Duration(probe.TimeoutMs) * time.Millisecond
+	dialer := &net.Dialer{Timeout: timeout}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+			TLSClientConfig:   &tls.Config{ServerName: host, RootCAs: sp.roots},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var body io.Reader
+	if probe.Body != "" {
+		body = strings.NewReader(probe.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, probe.Method, fmt.Sprintf("%s://%s%s", probe.Scheme, host, probe.Path), body)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("User-Agent", "Obtura-Synthetic-Probe/1.0")
+	for name, value := range probe.Headers {
+		req.Header.Set(name, value)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.LatencyMs = int(time.Since(start).Milliseconds())
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	content, readErr := io.ReadAll(io.LimitReader(resp.Body, probeBodyLimit))
+	result.LatencyMs = int(time.Since(start).Milliseconds())
+	result.StatusCode = resp.StatusCode
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		expires := resp.TLS.PeerCertificates[0].NotAfter
+		result.TLSExpiresAt = &expires
+	}
+
+	switch {
+	case probe.ExpectedStatus > 0 && resp.StatusCode != probe.ExpectedStatus:
+		result.Error = fmt.Sprintf("%s returned %d, expected %d", probe.Path, resp.StatusCode, probe.ExpectedStatus)
+	case probe.ExpectedStatus == 0 && (resp.StatusCode < 200 || resp.StatusCode >= 400):
+		result.Error = fmt.Sprintf("%s returned %d, expected 2xx/3xx", probe.Path, resp.StatusCode)
+	case probe.BodyMatch != "" && readErr != nil:
+		result.Error = fmt.Sprintf("failed to read body: %v", readErr)
+	case probe.bodyPattern != nil && !probe.bodyPattern.Match(content):
+		result.Error = fmt.Sprintf("body does not match %q", probe.BodyMatch)
+	case probe.BodyMatch != "" && !probe.BodyMatchRegex && !strings.Contains(string(content), probe.BodyMatch):
+		result.Error = fmt.Sprintf("body does not contain %q", probe.BodyMatch)
+	default:
+		result.Success = true
+	}
+
+	return result
+}
+
+func (sp *SyntheticProber) storeResult(ctx context.Context, r *ProbeResult) error {
+	var tlsExpiresAt interface{}
+	if r.TLSExpiresAt != nil {
+		tlsExpiresAt = *r.TLSExpiresAt
+	}
+	// Results inside a maintenance window of the probe's environment are flagged so SLOs
+	// skip them
+	_, err := sp.orchestrator.db.ExecContext(ctx, `
+		INSERT INTO synthetic_probe_results (
+			probe_id, deployment_id, success, status_code, latency_ms, error_message, tls_expires_at, checked_at,
+			in_maintenance
+		) VALUES ($1, $2, $3, NULLIF($4, 0), $5, NULLIF($6, ''), $7, $8, EXISTS (
+			SELECT 1 FROM synthetic_probes p
+			JOIN maintenance_windows mw ON mw.project_id = p.project_id
+			WHERE p.id = $1 AND mw.starts_at <= $8 AND mw.ends_at > $8
+			  AND (mw.environment IS NULL OR mw.environment = p.environment)
+		))`,
+		r.ProbeID, nullIfEmpty(r.DeploymentID), r.Success, r.StatusCode, r.LatencyMs, r.Error, tlsExpiresAt, r.CheckedAt)
+	return err
+}
+
+// checkCertificateExpiry raises an ssl_expiry alert while the served certificate is
+// inside the probe's warning window and resolves it once a renewed certificate is served
+func (sp *SyntheticProber) checkCertificateExpiry(ctx context.Context, probe *SyntheticProbe, host string, r *ProbeResult) {
+	if probe.TLSExpiryWarnDays == 0 || r.TLSExpiresAt == nil || r.DeploymentID == "" {
+		return
+	}
+
+	db := sp.orchestrator.db
+	remaining := time.Until(*r.TLSExpiresAt)
+	if remaining > time.Duration(probe.TLSExpiryWarnDays)*24*time.Hour {
+		_, err := db.ExecContext(ctx, `
+			UPDATE deployment_alerts SET resolved = true, resolved_at = NOW()
+			WHERE project_id = $1 AND alert_type = 'ssl_expiry' AND resolved = false
+			  AND alert_data->>'host' = $2`, probe.ProjectID, host)
+		if err != nil {
+			logger.Error("Failed to resolve certificate expiry alert", logger.Err(err))
+		}
+		return
+	}
+
+	var exists bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM deployment_alerts
+			WHERE project_id = $1 AND alert_type = 'ssl_expiry' AND resolved = false AND alert_data->>'host' = $2
+		)`, probe.ProjectID, host).Scan(&exists)
+	if err != nil || exists {
+		return
+	}
+
+	days := remaining.Hours() / 24
+	severity := "warning"
+	if days < 3 {
+		severity = "critical"
+	}
+	alert := &models.Alert{
+		DeploymentID:   r.DeploymentID,
+		Severity:       severity,
+		Title:          "TLS certificate expiring",
+		Description:    fmt.Sprintf("Certificate for %s expires on %s (%.0f days left)", host, r.TLSExpiresAt.Format("2006-01-02"), days),
+		MetricType:     "ssl_expiry",
+		ThresholdValue: float64(probe.TLSExpiryWarnDays),
+		CurrentValue:   days,
+		Metadata:       map[string]interface{}{"host": host},
+	}
+	if err := sp.orchestrator.alertManager.TriggerAlert(ctx, alert); err != nil {
+		logger.Error("Failed to raise certificate expiry alert", logger.String("host", host), logger.Err(err))
+	}
+}
+
+// ProbeDeployment decides whether a deployment is up for uptime tracking. Recent results
+// of the deployment's configured probes are used when there are any; otherwise a default
+// probe requests its health check path through Traefik.
+func (sp *SyntheticProber) ProbeDeployment(ctx context.Context, d *models.Deployment) (*ProbeResult, error) {
+	var configured int
+	var failed int
+	var latency sql.NullFloat64
+	err := sp.orchestrator.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE NOT r.success), AVG(r.latency_ms)
+		FROM synthetic_probes p
+		JOIN LATERAL (
+			SELECT success, latency_ms FROM synthetic_probe_results
+			WHERE probe_id = p.id AND deployment_id = $1 AND checked_at > $2
+			ORDER BY checked_at DESC
+			LIMIT 1
+		) r ON true
+		WHERE p.enabled = true`, d.ID, time.Now().Add(-probeResultFreshness)).Scan(&configured, &failed, &latency)
+	if err != nil {
+		return nil, err
+	}
+	if configured > 0 {
+		return &ProbeResult{
+			DeploymentID: d.ID,
+			Success:      failed == 0,
+			LatencyMs:    int(latency.Float64),
+			CheckedAt:    time.Now(),
+		}, nil
+	}
+
+	if d.Domain == "" {
+		return nil, fmt.Errorf("deployment %s has no public domain", d.ID)
+	}
+
+	scheme := "http"
+	if sp.orchestrator.config.TraefikTLSEnabled {
+		scheme = "https"
+	}
+	probe := &SyntheticProbe{
+		Method:         "GET",
+		Scheme:         scheme,
+		Path:           d.HealthCheck.Path,
+		ExpectedStatus: d.HealthCheck.ExpectedStatus,
+		TimeoutMs:      int(defaultHealthCheckTimeout / time.Millisecond),
+	}
+	if probe.Path == "" {
+		probe.Path = defaultHealthCheckPath
+	}
+	result := sp.execute(ctx, probe, d.Domain)
+	result.DeploymentID = d.ID
+	return result, nil
+}
+
+// CreateProbe stores a validated probe for a project
+func (sp *SyntheticProber) CreateProbe(ctx context.Context, projectID string, p *SyntheticProbe) error {
+	if err := sp.checkProbeHost(ctx, projectID, p.Host); err != nil {
+		return err
+	}
+
+	headers, err := json.Marshal(p.Headers)
+	if err != nil {
+		return err
+	}
+
+	p.ProjectID = projectID
+	err = sp.orchestrator.db.QueryRowContext(ctx, `
+		INSERT INTO synthetic_probes (
+			project_id, environment, name, method, scheme, host, path, request_headers, request_body,
+			expected_status, body_match, body_match_regex, tls_expiry_warn_days, timeout_ms, interval_seconds, enabled
+		) VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, NULLIF($9, ''),
+			NULLIF($10, 0), NULLIF($11, ''), $12, $13, $14, $15, $16)
+		RETURNING id, created_at`,
+		projectID, p.Environment, p.Name, p.Method, p.Scheme, p.Host, p.Path, headers, p.Body,
+		p.ExpectedStatus, p.BodyMatch, p.BodyMatchRegex, p.TLSExpiryWarnDays, p.TimeoutMs, p.IntervalSeconds, p.Enabled,
+	).Scan(&p.ID, &p.CreatedAt)
+	return probeWriteError(err)
+}
+
+// checkProbeHost refuses hosts the project does not serve: the probe's method, headers
+// and body would otherwise reach another project's app through Traefik
+func (sp *SyntheticProber) checkProbeHost(ctx context.Context, projectID, host string) error {
+	if host == "" {
+		return nil
+	}
+
+	var owned bool
+	err := sp.orchestrator.db.QueryRowContext(ctx,
+		`SELECT `+fmt.Sprintf(probeHostOwnedSQL, "$1", "$2"), projectID, host).Scan(&owned)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return ErrProbeHostDenied
+	}
+	return nil
+}
+
+// UpdateProbe replaces a probe's definition
+func (sp *SyntheticProber) UpdateProbe(ctx context.Context, projectID, probeID string, p *SyntheticProbe) error {
+	if err := sp.checkProbeHost(ctx, projectID, p.Host); err != nil {
+		return err
+	}
+
+	headers, err := json.Marshal(p.Headers)
+	if err != nil {
+		return err
+	}
+
+	p.ID = probeID
+	p.ProjectID = projectID
+	err = sp.orchestrator.db.QueryRowContext(ctx, `
+		UPDATE synthetic_probes
+		SET environment = $3, name = $4, method = $5, scheme = $6, host = NULLIF($7, ''), path = $8,
+			request_headers = $9, request_body = NULLIF($10, ''), expected_status = NULLIF($11, 0),
+			body_match = NULLIF($12, ''), body_match_regex = $13, tls_expiry_warn_days = $14,
+			timeout_ms = $15, interval_seconds = $16, enabled = $17, updated_at = NOW()
+		WHERE id = $1 AND project_id = $2
+		RETURNING created_at`,
+		probeID, projectID, p.Environment, p.Name, p.Method, p.Scheme, p.Host, p.Path, headers, p.Body,
+		p.ExpectedStatus, p.BodyMatch, p.BodyMatchRegex, p.TLSExpiryWarnDays, p.TimeoutMs, p.IntervalSeconds, p.Enabled,
+	).Scan(&p.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrProbeNotFound
+	}
+	return probeWriteError(err)
+}
+
+// DeleteProbe removes a probe, its results and the SLOs measured on it alone
+func (sp *SyntheticProber) DeleteProbe(ctx context.Context, projectID, probeID string) error {
+	res, err := sp.orchestrator.db.ExecContext(ctx, `DELETE FROM synthetic_probes WHERE id = $1 AND project_id = $2`, probeID, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrProbeNotFound
+	}
+	return nil
+}
+
+// ListProbes returns a project's probes
+func (sp *SyntheticProber) ListProbes(ctx context.Context, projectID string) ([]*SyntheticProbe, error) {
+	rows, err := sp.orchestrator.db.QueryContext(ctx, `
+		SELECT `+syntheticProbeColumns+` FROM synthetic_probes p
+		WHERE p.project_id = $1
+		ORDER BY p.environment, p.name`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	probes := []*SyntheticProbe{}
+	for rows.Next() {
+		p, err := scanSyntheticProbe(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		probes = append(probes, p)
+	}
+	return probes, rows.Err()
+}
+
+// GetProbe returns one of a project's probes
+func (sp *SyntheticProber) GetProbe(ctx context.Context, projectID, probeID string) (*SyntheticProbe, error) {
+	p, err := scanSyntheticProbe(sp.orchestrator.db.QueryRowContext(ctx, `
+		SELECT `+syntheticProbeColumns+` FROM synthetic_probes p
+		WHERE p.id = $1 AND p.project_id = $2`, probeID, projectID).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrProbeNotFound
+	}
+	return p, err
+}
+
+// ListProbeResults returns a probe's most recent results
+func (sp *SyntheticProber) ListProbeResults(ctx context.Context, projectID, probeID string, limit int) ([]*ProbeResult, error) {
+	if _, err := sp.GetProbe(ctx, projectID, probeID); err != nil {
+		return nil, err
+	}
+
+	rows, err := sp.orchestrator.db.QueryContext(ctx, `
+		SELECT probe_id, COALESCE(deployment_id::text, ''), success, COALESCE(status_code, 0),
+		       COALESCE(latency_ms, 0), COALESCE(error_message, ''), tls_expires_at, checked_at
+		FROM synthetic_probe_results
+		WHERE probe_id = $1
+		ORDER BY checked_at DESC
+		LIMIT $2`, probeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*ProbeResult{}
+	for rows.Next() {
+		var r ProbeResult
+		var tlsExpiresAt sql.NullTime
+		if err := rows.Scan(&r.ProbeID, &r.DeploymentID, &r.Success, &r.StatusCode,
+			&r.LatencyMs, &r.Error, &tlsExpiresAt, &r.CheckedAt); err != nil {
+			return nil, err
+		}
+		r.TLSExpiresAt = nullTimePtr(tlsExpiresAt)
+		results = append(results, &r)
+	}
+	return results, rows.Err()
+}
+
+func probeWriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrProbeNameTaken
+	}
+	return err
+}
@@ -0,0 +1,351 @@
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultTraceListLimit = 50
+	maxTraceListLimit     = 500
+	// Spans returned for one trace
+	maxTraceSpans = 5000
+	// Window around a Traefik request that its server span is searched in, when the
+	// request carried no traceparent
+	traceMatchBefore = 2 * time.Second
+	traceMatchAfter  = 2 * time.Second
+)
+
+var (
+	ErrTraceNotFound   = errors.New("trace not found")
+	ErrRequestNotFound = errors.New("request not found")
+)
+
+// Span is one operation of a trace, as exported over OTLP
+type Span struct {
+	TraceID        string                 `json:"trace_id"`
+	SpanID         string                 `json:"span_id"`
+	ParentSpanID   string                 `json:"parent_span_id"`
+	ProjectID      string                 `json:"project_id"`
+	DeploymentID   string                 `json:"deployment_id,omitempty"`
+	ServiceName    string                 `json:"service_name"`
+	Name           string                 `json:"name"`
+	Kind           string                 `json:"kind"`
+	StartTime      time.Time              `json:"start_time"`
+	DurationUs     int64                  `json:"duration_us"`
+	StatusCode     string                 `json:"status_code"`
+	StatusMessage  string                 `json:"status_message,omitempty"`
+	HTTPMethod     string                 `json:"http_method,omitempty"`
+	HTTPRoute      string                 `json:"http_route,omitempty"`
+	HTTPPath       string                 `json:"http_path,omitempty"`
+	HTTPStatusCode int                    `json:"http_status_code,omitempty"`
+	Attributes     map[string]interface{} `json:"attributes"`
+	Resource       map[string]interface{} `json:"resource"`
+	Events         []SpanEvent            `json:"events"`
+}
+
+type SpanEvent struct {
+	Name       string                 `json:"name"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// TraceSummary describes a trace by its root span
+type TraceSummary struct {
+	TraceID        string    `json:"trace_id"`
+	DeploymentID   string    `json:"deployment_id,omitempty"`
+	ServiceName    string    `json:"service_name"`
+	Name           string    `json:"name"`
+	StartTime      time.Time `json:"start_time"`
+	DurationUs     int64     `json:"duration_us"`
+	SpanCount      int       `json:"span_count"`
+	ErrorCount     int       `json:"error_count"`
+	HTTPMethod     string    `json:"http_method,omitempty"`
+	HTTPPath       string    `json:"http_path,omitempty"`
+	HTTPStatusCode int       `json:"http_status_code,omitempty"`
+}
+
+type TraceFilter struct {
+	DeploymentID string
+	Service      string
+	MinDuration  time.Duration
+	ErrorsOnly   bool
+	Start        time.Time
+	End          time.Time
+	Limit        int
+}
+
+// SampledRequest is a Traefik request kept in http_requests_sampled
+type SampledRequest struct {
+	ID         int       `json:"id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	StatusCode int       `json:"status_code"`
+	LatencyMs  int       `json:"latency_ms"`
+	TraceID    string    `json:"trace_id,omitempty"`
+}
+
+// RequestTrace is the trace a sampled request was served by. Match is "traceparent"
+// when the request carried the trace ID, "heuristic" when its server span was picked
+// by time, path, status and duration.
+type RequestTrace struct {
+	Request SampledRequest `json:"request"`
+	TraceID string         `json:"trace_id"`
+	Match   string         `json:"match"`
+	Spans   []Span         `json:"spans"`
+}
+
+// TraceStore keeps the spans apps export and links them to Traefik requests
+type TraceStore struct {
+	orchestrator *Orchestrator
+}
+
+func NewTraceStore(o *Orchestrator) *TraceStore {
+	return &TraceStore{orchestrator: o}
+}
+
+// StoreSpans inserts the spans in one transaction; spans already stored (exporter
+// retries) are skipped
+func (ts *TraceStore) StoreSpans(ctx context.Context, spans []Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	tx, err := ts.orchestrator.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO trace_spans (
+			project_id, deployment_id, trace_id, span_id, parent_span_id, service_name, name, kind,
+			start_time, duration_us, status_code, status_message, http_method, http_route, http_path,
+			http_status_code, attributes, resource, events
+		) VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''),
+		          NULLIF($13, ''), NULLIF($14, ''), NULLIF($15, ''), NULLIF($16, 0), $17, $18, $19)
+		ON CONFLICT (project_id, trace_id, span_id) DO NOTHING`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, s := range spans {
+		attributes, _ := json.Marshal(s.Attributes)
+		resource, _ := json.Marshal(s.Resource)
+		events, _ := json.Marshal(s.Events)
+		if _, err := stmt.ExecContext(ctx, s.ProjectID, s.DeploymentID, s.TraceID, s.SpanID, s.ParentSpanID,
+			truncateString(s.ServiceName, 200), truncateString(s.Name, 500), s.Kind, s.StartTime.UTC(), s.DurationUs,
+			s.StatusCode, s.StatusMessage, truncateString(s.HTTPMethod, 10), truncateString(s.HTTPRoute, 500),
+			truncateString(s.HTTPPath, 500), s.HTTPStatusCode, attributes, resource, events); err != nil {
+			return fmt.Errorf("failed to store span: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListTraces returns the project's traces started in the window, newest first. A
+// trace's root is its span whose parent was not exported.
+func (ts *TraceStore) ListTraces(ctx context.Context, projectID string, f TraceFilter) ([]TraceSummary, error) {
+	if f.Limit <= 0 {
+		f.Limit = defaultTraceListLimit
+	}
+	if f.Limit > maxTraceListLimit {
+		f.Limit = maxTraceListLimit
+	}
+
+	rows, err := ts.orchestrator.db.QueryContext(ctx, `
+		WITH roots AS (
+			SELECT DISTINCT ON (s.trace_id)
+				s.trace_id, COALESCE(s.deployment_id::text, '') AS deployment_id, s.service_name, s.name,
+				s.start_time, COALESCE(s.http_method, '') AS http_method, COALESCE(s.http_path, '') AS http_path,
+				COALESCE(s.http_status_code, 0) AS http_status_code
+			FROM trace_spans s
+			WHERE s.project_id = $1 AND s.start_time BETWEEN $2 AND $3
+			  AND ($4 = '' OR s.deployment_id::text = $4)
+			  AND ($5 = '' OR s.service_name = $5)
+			  AND NOT EXISTS (
+				SELECT 1 FROM trace_spans p
+				WHERE p.project_id = s.project_id AND p.trace_id = s.trace_id AND p.span_id = s.parent_span_id)
+			ORDER BY s.trace_id, s.start_time
+		)
+		SELECT r.trace_id, r.deployment_id, r.service_name, r.name, r.start_time,
+			a.duration_us, a.span_count, a.error_count, r.http_method, r.http_path, r.http_status_code
+		FROM roots r
+		JOIN LATERAL (
+			SELECT COUNT(*) AS span_count,
+				COUNT(*) FILTER (WHERE t.status_code = 'error') AS error_count,
+				(EXTRACT(EPOCH FROM MAX(t.start_time + t.duration_us * INTERVAL '1 microsecond') - MIN(t.start_time)) * 1000000)::bigint AS duration_us
+			FROM trace_spans t
+			WHERE t.project_id = $1 AND t.trace_id = r.trace_id
+		) a ON true
+		WHERE a.duration_us >= $6 AND (NOT $7 OR a.error_count > 0)
+		ORDER BY r.start_time DESC
+		LIMIT $8`,
+		projectID, f.Start.UTC(), f.End.UTC(), f.DeploymentID, f.Service, f.MinDuration.Microseconds(), f.ErrorsOnly, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	traces := []TraceSummary{}
+	for rows.Next() {
+		var t TraceSummary
+		if err := rows.Scan(&t.TraceID, &t.DeploymentID, &t.ServiceName, &t.Name, &t.StartTime, &t.DurationUs,
+			&t.SpanCount, &t.ErrorCount, &t.HTTPMethod, &t.HTTPPath, &t.HTTPStatusCode); err != nil {
+			return nil, err
+		}
+		traces = append(traces, t)
+	}
+	return traces, rows.Err()
+}
+
+// GetTrace returns the trace's spans ordered by start time
+func (ts *TraceStore) GetTrace(ctx context.Context, projectID, traceID string) ([]Span, error) {
+	rows, err := ts.orchestrator.db.QueryContext(ctx, `
+		SELECT trace_id, span_id, parent_span_id, project_id, COALESCE(deployment_id::text, ''), service_name,
+			name, kind, start_time, duration_us, status_code, COALESCE(status_message, ''),
+			COALESCE(http_method, ''), COALESCE(http_route, ''), COALESCE(http_path, ''),
+			COALESCE(http_status_code, 0), COALESCE(attributes, '{}'), COALESCE(resource, '{}'),
+			COALESCE(events, '[]')
+		FROM trace_spans
+		WHERE project_id = $1 AND trace_id = $2
+		ORDER BY start_time, span_id
+		LIMIT $3`, projectID, traceID, maxTraceSpans)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spans := []Span{}
+	for rows.Next() {
+		var s Span
+		var attributes, resource, events []byte
+		if err := rows.Scan(&s.TraceID, &s.SpanID, &s.ParentSpanID, &s.ProjectID, &s.DeploymentID, &s.ServiceName,
+			&s.Name, &s.Kind, &s.StartTime, &s.DurationUs, &s.StatusCode, &s.StatusMessage,
+			&s.HTTPMethod, &s.HTTPRoute, &s.HTTPPath, &s.HTTPStatusCode, &attributes, &resource, &events); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(attributes, &s.Attributes)
+		json.Unmarshal(resource, &s.Resource)
+		json.Unmarshal(events, &s.Events)
+		spans = append(spans, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(spans) == 0 {
+		return nil, ErrTraceNotFound
+	}
+	return spans, nil
+}
+
+// ListRequests returns the deployment's sampled Traefik requests in the window,
+// slowest first when minLatency is set and newest first otherwise
+func (ts *TraceStore) ListRequests(ctx context.Context, deploymentID string, minLatency time.Duration, start, end time.Time, limit int) ([]SampledRequest, error) {
+	if limit <= 0 {
+		limit = defaultTraceListLimit
+	}
+	if limit > maxTraceListLimit {
+		limit = maxTraceListLimit
+	}
+
+	order := "timestamp DESC"
+	if minLatency > 0 {
+		order = "latency_ms DESC, timestamp DESC"
+	}
+	rows, err := ts.orchestrator.db.QueryContext(ctx, `
+		SELECT id, timestamp, method, path, status_code, latency_ms, COALESCE(trace_id, '')
+		FROM http_requests_sampled
+		WHERE deployment_id = $1 AND timestamp BETWEEN $2 AND $3 AND latency_ms >= $4
+		ORDER BY `+order+`
+		LIMIT $5`, deploymentID, start, end, minLatency.Milliseconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []SampledRequest{}
+	for rows.Next() {
+		var r SampledRequest
+		if err := rows.Scan(&r.ID, &r.Timestamp, &r.Method, &r.Path, &r.StatusCode, &r.LatencyMs, &r.TraceID); err != nil {
+			return nil, err
+		}
+		requests = append(requests, r)
+	}
+	return requests, rows.Err()
+}
+
+// TraceForRequest returns the trace that served a sampled request: the one named by
+// its traceparent header or, without it, the deployment's server span closest to it
+func (ts *TraceStore) TraceForRequest(ctx context.Context, deploymentID string, requestID int) (*RequestTrace, error) {
+	var projectID string
+	rt := &RequestTrace{}
+	r := &rt.Request
+	err := ts.orchestrator.db.QueryRowContext(ctx, `
+		SELECT d.project_id, r.id, r.timestamp, r.method, r.path, r.status_code, r.latency_ms,
+			COALESCE(r.trace_id, '')
+		FROM http_requests_sampled r
+		JOIN deployments d ON d.id = r.deployment_id
+		WHERE r.id = $1 AND r.deployment_id = $2`, requestID, deploymentID).Scan(
+		&projectID, &r.ID, &r.Timestamp, &r.Method, &r.Path, &r.StatusCode, &r.LatencyMs, &r.TraceID)
+	if err == sql.ErrNoRows {
+		return nil, ErrRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rt.TraceID, rt.Match = r.TraceID, "traceparent"
+	if rt.TraceID == "" {
+		// Traefik logs the request's start; prefer the same path, then the same
+		// status, then the duration closest to the measured latency
+		rt.Match = "heuristic"
+		err := ts.orchestrator.db.QueryRowContext(ctx, `
+			SELECT trace_id FROM trace_spans
+			WHERE deployment_id = $1 AND kind = 'server'
+			  AND start_time BETWEEN $2 AND $3
+			  AND (http_method IS NULL OR http_method = $4)
+			ORDER BY (http_path = $5) IS TRUE DESC, (http_status_code = $6) IS TRUE DESC,
+				ABS(duration_us - $7), ABS(EXTRACT(EPOCH FROM start_time - $8))
+			LIMIT 1`,
+			deploymentID, r.Timestamp.UTC().Add(-traceMatchBefore), r.Timestamp.UTC().Add(traceMatchAfter),
+			r.Method, requestPath(r.Path), r.StatusCode, int64(r.LatencyMs)*1000, r.Timestamp.UTC()).Scan(&rt.TraceID)
+		if err == sql.ErrNoRows {
+			return nil, ErrTraceNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rt.Spans, err = ts.GetTrace(ctx, projectID, rt.TraceID)
+	if err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// requestPath strips the query string; span http_path attributes carry none
+func requestPath(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	return path
+}
+
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"time"
+
+	"monitoring-service/pkg/logger"
+	"monitoring-service/pkg/models"
 )
 
 type UptimeTracker struct {
@@ -11,11 +14,12 @@ type UptimeTracker struct {
 }
 
 type UptimeRecord struct {
-	DeploymentID string
-	Timestamp    time.Time
-	IsUp         bool
-	ResponseTime int
-	Uptime       float64 // percentage
+	DeploymentID  string
+	Timestamp     time.Time
+	IsUp          bool
+	InMaintenance bool // Downtime during a maintenance window is not counted
+	ResponseTime  int
+	Uptime        float64 // percentage
 }
 
 func NewUptimeTracker(o *Orchestrator) *UptimeTracker {
@@ -41,38 +45,57 @@ func (ut *UptimeTracker) Track(ctx context.Context) error {
 	return nil
 }
 
-func (ut *UptimeTracker) trackDeployment(ctx context.Context, deployment *Deployment) error {
+func (ut *UptimeTracker) trackDeployment(ctx context.Context, deployment *models.Deployment) error {
 	// Check if deployment is up
-	isUp := ut.checkDeploymentStatus(ctx, deployment)
+	isUp, responseTime := ut.checkDeploymentStatus(ctx, deployment)
+
+	inMaintenance, err := ut.orchestrator.alertManager.InMaintenance(ctx, deployment.ID)
+	if err != nil {
+		logger.Warn("Failed to check maintenance windows",
+			logger.String("deployment_id", deployment.ID), logger.Err(err))
+	}
 
 	// Calculate uptime percentage
-	uptime := ut.calculateUptime(ctx, deployment.ID, isUp)
+	uptime := ut.calculateUptime(ctx, deployment.ID, isUp || inMaintenance)
 
 	// Store uptime record
 	record := &UptimeRecord{
-		DeploymentID: deployment.ID,
-		Timestamp:    time.Now(),
-		IsUp:         isUp,
-		Uptime:       uptime,
+		DeploymentID:  deployment.ID,
+		Timestamp:     time.Now(),
+		IsUp:          isUp,
+		InMaintenance: inMaintenance,
+		ResponseTime:  responseTime,
+		Uptime:        uptime,
 	}
 
 	return ut.storeUptimeRecord(ctx, record)
 }
 
-func (ut *UptimeTracker) checkDeploymentStatus(ctx context.Context, deployment *Deployment) bool {
-	// Check container status
+// checkDeploymentStatus measures the deployment from outside through its public route. A
+// running container serving errors is down. The container state is only used for
+// deployments without a public domain.
+func (ut *UptimeTracker) checkDeploymentStatus(ctx context.Context, deployment *models.Deployment) (bool, int) {
+	if deployment.Domain != "" {
+		result, err := ut.orchestrator.prober.ProbeDeployment(ctx, deployment)
+		if err == nil {
+			return result.Success, result.LatencyMs
+		}
+		logger.Warn("Uptime probe failed, falling back to container state",
+			logger.String("deployment_id", deployment.ID), logger.Err(err))
+	}
+
 	stats, err := ut.orchestrator.dockerClient.GetContainerStats(ctx, deployment.ContainerID)
 	if err != nil {
-		return false
+		return false, 0
 	}
 
-	return stats.State == "running"
+	return stats.State == "running", 0
 }
 
 func (ut *UptimeTracker) calculateUptime(ctx context.Context, deploymentID string, currentStatus bool) float64 {
-	// Get uptime records for last 24 hours
+	// Get uptime records for last 24 hours; downtime inside maintenance windows is left out
 	query := `
-		SELECT COUNT(*) as total, COUNT(*) FILTER (WHERE is_up = true) as up_count
+		SELECT COUNT(*) FILTER (WHERE is_up OR NOT in_maintenance) as total, COUNT(*) FILTER (WHERE is_up = true) as up_count
 		FROM uptime_records
 		WHERE deployment_id = $1 AND timestamp > NOW() - INTERVAL '24 hours'
 	`
@@ -92,8 +115,8 @@ func (ut *UptimeTracker) calculateUptime(ctx context.Context, deploymentID strin
 func (ut *UptimeTracker) storeUptimeRecord(ctx context.Context, record *UptimeRecord) error {
 	query := `
 		INSERT INTO uptime_records (
-			deployment_id, timestamp, is_up, uptime_percentage
-		) VALUES ($1, $2, $3, $4)
+			deployment_id, timestamp, is_up, in_maintenance, uptime_percentage, response_time_ms
+		) VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0))
 	`
 
 	_, err := ut.orchestrator.db.ExecContext(
@@ -102,7 +125,9 @@ func (ut *UptimeTracker) storeUptimeRecord(ctx context.Context, record *UptimeRe
 		record.DeploymentID,
 		record.Timestamp,
 		record.IsUp,
+		record.InMaintenance,
 		record.Uptime,
+		record.ResponseTime,
 	)
 
 	// Store in Redis for quick access
@@ -137,9 +162,10 @@ func (ut *UptimeTracker) GetUptime(ctx context.Context, deploymentID string) (fl
 	return uptime, err
 }
 
-func (ut *UptimeTracker) getActiveDeployments(ctx context.Context) ([]*Deployment, error) {
+func (ut *UptimeTracker) getActiveDeployments(ctx context.Context) ([]*models.Deployment, error) {
 	query := `
-		SELECT d.id, dc.container_id 
+		SELECT d.id, d.project_id, d.environment, COALESCE(d.domain, ''), dc.container_id,
+		       COALESCE(d.health_check_path, ''), COALESCE(d.health_check_expected_status, 0)
 		FROM deployments d
 		JOIN deployment_containers dc ON dc.deployment_id = d.id AND dc.is_active = true
 		WHERE d.status NOT IN ('deleted', 'terminated', 'rolled_back')
@@ -150,10 +176,11 @@ func (ut *UptimeTracker) getActiveDeployments(ctx context.Context) ([]*Deploymen
 	}
 	defer rows.Close()
 
-	var deployments []*Deployment
+	var deployments []*models.Deployment
 	for rows.Next() {
-		var d Deployment
-		if err := rows.Scan(&d.ID, &d.ContainerID); err != nil {
+		var d models.Deployment
+		if err := rows.Scan(&d.ID, &d.ProjectID, &d.Environment, &d.Domain, &d.ContainerID,
+			&d.HealthCheck.Path, &d.HealthCheck.ExpectedStatus); err != nil {
 			return nil, err
 		}
 		deployments = append(deployments, &d)
@@ -50,12 +50,33 @@ func (wp *WorkerPool) Start() {
 	wp.wg.Add(1)
 	go wp.logAggregator()
 
+	wp.wg.Add(1)
+	go wp.errorTracker()
+
 	wp.wg.Add(1)
 	go wp.uptimeTracker()
 
+	wp.wg.Add(1)
+	go wp.syntheticProber()
+
+	wp.wg.Add(1)
+	go wp.sloEvaluator()
+
+	wp.wg.Add(1)
+	go wp.remoteWriter()
+
+	wp.wg.Add(1)
+	go wp.appMetricsScraper()
+
 	wp.wg.Add(1)
 	go wp.alertProcessor()
 
+	wp.wg.Add(1)
+	go wp.incidentProcessor()
+
+	wp.wg.Add(1)
+	go wp.escalationProcessor()
+
 	// Start log archival worker
 	wp.wg.Add(1)
 	go wp.logArchivalWorker()
@@ -141,17 +162,59 @@ func (wp *WorkerPool) logAggregator() {
 	ticker := time.NewTicker(wp.config.LogAggregationInterval)
 	defer ticker.Stop()
 
+	// Log streams are followed continuously; the ticker only attaches and detaches
+	// containers. Attach right away so a restart resumes without waiting a tick. Drains
+	// sync first, so they know the deployments of the containers attached next.
+	attach := func() {
+		ctx, cancel := context.WithTimeout(wp.ctx, 60*time.Second)
+		if err := wp.orchestrator.RunLogDrainSync(ctx); err != nil {
+			logger.Error("Log drain sync failed", logger.Err(err))
+		}
+		if err := wp.orchestrator.RunLogAggregation(ctx); err != nil {
+			logger.Error("Log aggregation failed", logger.Err(err))
+		}
+		cancel()
+	}
+	attach()
+
 	for {
 		select {
 		case <-wp.ctx.Done():
+			wp.orchestrator.GetLogShipper().Stop()
+			wp.orchestrator.GetLogDrains().Stop()
 			logger.Info("Log aggregator stopped")
 			return
 		case <-ticker.C:
-			ctx, cancel := context.WithTimeout(wp.ctx, 60*time.Second)
-			if err := wp.orchestrator.RunLogAggregation(ctx); err != nil {
-				logger.Error("Log aggregation failed", logger.Err(err))
-			}
-			cancel()
+			attach()
+		}
+	}
+}
+
+func (wp *WorkerPool) errorTracker() {
+	defer wp.wg.Done()
+
+	logger.Info("Error tracker started")
+
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	flush := func(parent context.Context) {
+		ctx, cancel := context.WithTimeout(parent, 30*time.Second)
+		if err := wp.orchestrator.RunErrorTracking(ctx); err != nil {
+			logger.Error("Error tracking failed", logger.Err(err))
+		}
+		cancel()
+	}
+
+	for {
+		select {
+		case <-wp.ctx.Done():
+			// Events counted since the last tick would be lost otherwise
+			flush(context.Background())
+			logger.Info("Error tracker stopped")
+			return
+		case <-ticker.C:
+			flush(wp.ctx)
 		}
 	}
 }
@@ -179,6 +242,53 @@ func (wp *WorkerPool) uptimeTracker() {
 	}
 }
 
+func (wp *WorkerPool) syntheticProber() {
+	defer wp.wg.Done()
+
+	logger.Info("Synthetic prober started")
+
+	// Each probe runs on its own interval_seconds
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wp.ctx.Done():
+			logger.Info("Synthetic prober stopped")
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(wp.ctx, 90*time.Second)
+			if err := wp.orchestrator.RunSyntheticProbes(ctx); err != nil {
+				logger.Error("Synthetic probes failed", logger.Err(err))
+			}
+			cancel()
+		}
+	}
+}
+
+func (wp *WorkerPool) sloEvaluator() {
+	defer wp.wg.Done()
+
+	logger.Info("SLO evaluator started")
+
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wp.ctx.Done():
+			logger.Info("SLO evaluator stopped")
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(wp.ctx, 60*time.Second)
+			if err := wp.orchestrator.RunSLOEvaluation(ctx); err != nil {
+				logger.Error("SLO evaluation failed", logger.Err(err))
+			}
+			cancel()
+		}
+	}
+}
+
 func (wp *WorkerPool) alertProcessor() {
 	defer wp.wg.Done()
 
@@ -202,6 +312,100 @@ func (wp *WorkerPool) alertProcessor() {
 	}
 }
 
+func (wp *WorkerPool) remoteWriter() {
+	defer wp.wg.Done()
+
+	logger.Info("Remote-write pusher started")
+
+	// Targets have their own interval (>= 15s); this only checks which are due
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wp.ctx.Done():
+			logger.Info("Remote-write pusher stopped")
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(wp.ctx, 60*time.Second)
+			if err := wp.orchestrator.RunRemoteWrite(ctx); err != nil {
+				logger.Error("Remote-write push failed", logger.Err(err))
+			}
+			cancel()
+		}
+	}
+}
+
+func (wp *WorkerPool) appMetricsScraper() {
+	defer wp.wg.Done()
+
+	logger.Info("App metrics scraper started")
+
+	// Projects have their own interval (>= 10s); this only checks which are due
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wp.ctx.Done():
+			logger.Info("App metrics scraper stopped")
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(wp.ctx, 60*time.Second)
+			if err := wp.orchestrator.RunAppMetricsScrape(ctx); err != nil {
+				logger.Error("App metrics scrape failed", logger.Err(err))
+			}
+			cancel()
+		}
+	}
+}
+
+func (wp *WorkerPool) incidentProcessor() {
+	defer wp.wg.Done()
+
+	logger.Info("Incident processor started")
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wp.ctx.Done():
+			logger.Info("Incident processor stopped")
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(wp.ctx, 30*time.Second)
+			if err := wp.orchestrator.RunIncidentProcessing(ctx); err != nil {
+				logger.Error("Incident processing failed", logger.Err(err))
+			}
+			cancel()
+		}
+	}
+}
+
+func (wp *WorkerPool) escalationProcessor() {
+	defer wp.wg.Done()
+
+	logger.Info("Escalation processor started")
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wp.ctx.Done():
+			logger.Info("Escalation processor stopped")
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(wp.ctx, 30*time.Second)
+			if err := wp.orchestrator.RunEscalations(ctx); err != nil {
+				logger.Error("Escalation processing failed", logger.Err(err))
+			}
+			cancel()
+		}
+	}
+}
+
 func (wp *WorkerPool) processBackgroundTasks() {
 	// Clean up old metrics
 	wp.cleanupOldMetrics()
@@ -217,11 +421,21 @@ func (wp *WorkerPool) cleanupOldMetrics() {
 	ctx, cancel := context.WithTimeout(wp.ctx, 30*time.Second)
 	defer cancel()
 
-	query := `DELETE FROM deployments_metrics WHERE timestamp < NOW() - INTERVAL '30 days'`
-	_, err := wp.orchestrator.GetDB().ExecContext(ctx, query)
-	if err != nil {
+	// Each tier is kept for its plan's retention once it has been rolled up
+	if err := wp.orchestrator.RunMetricsRetention(ctx); err != nil {
 		logger.Error("Failed to cleanup old metrics", logger.Err(err))
 	}
+
+	// Probe results back SLO windows of up to 30 days
+	query := `DELETE FROM synthetic_probe_results WHERE checked_at < NOW() - INTERVAL '31 days'`
+	if _, err := wp.orchestrator.GetDB().ExecContext(ctx, query); err != nil {
+		logger.Error("Failed to cleanup old probe results", logger.Err(err))
+	}
+
+	query = `DELETE FROM trace_spans WHERE created_at < NOW() - INTERVAL '7 days'`
+	if _, err := wp.orchestrator.GetDB().ExecContext(ctx, query); err != nil {
+		logger.Error("Failed to cleanup old trace spans", logger.Err(err))
+	}
 }
 
 func (wp *WorkerPool) logArchivalWorker() {
@@ -248,14 +462,13 @@ func (wp *WorkerPool) logArchivalWorker() {
 }
 
 func (wp *WorkerPool) archiveOldLogs() {
-	ctx, cancel := context.WithTimeout(wp.ctx, 5*time.Minute)
+	ctx, cancel := context.WithTimeout(wp.ctx, 15*time.Minute)
 	defer cancel()
 
 	logger.Info("Starting log archival to MinIO")
 
-	// Use the log aggregator to archive old logs
-	if err := wp.orchestrator.GetLogAggregator().ArchiveOldLogs(ctx); err != nil {
-		logger.Error("Failed to archive old logs", logger.Err(err))
+	// Archive and expire logs per retention policy; each run is recorded in log_archival_jobs
+	if err := wp.orchestrator.RunLogRetention(ctx); err != nil {
 		return
 	}
 
@@ -263,6 +476,11 @@ func (wp *WorkerPool) archiveOldLogs() {
 }
 
 func (wp *WorkerPool) updateAggregateStats() {
-	// Update daily/hourly aggregate statistics
-	// This could include average uptime, error rates, etc.
+	ctx, cancel := context.WithTimeout(wp.ctx, 2*time.Minute)
+	defer cancel()
+
+	// Roll up container and HTTP metrics into the 1m/1h/1d tiers
+	if err := wp.orchestrator.RunMetricsRollup(ctx); err != nil {
+		logger.Error("Failed to update aggregate stats", logger.Err(err))
+	}
 }
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -32,6 +34,18 @@ type Config struct {
 	HealthCheckInterval    time.Duration
 	LogAggregationInterval time.Duration
 	CoreAPIURL             string
+	TraefikHTTPAddress     string // Synthetic probes dial Traefik directly with the route's Host/SNI
+	TraefikTLSAddress      string
+	TraefikTLSEnabled      bool     // Default probe scheme; routes only redirect to HTTPS when enabled
+	ProbeCARoots           string   // Extra CA bundle trusted by probes (e.g. Pebble in development)
+	AppMetricsNetwork      string   // Docker network app containers are scraped on
+	ServiceAPITokens       []string // Shared secrets of internal callers (log ingestion, AI agent)
+	CORSAllowedOrigins     []string // Browser origins allowed to send credentials and open WebSockets
+	SessionCookieName      string   // Carries the session for EventSource and WebSocket clients
+	LogDrainBufferDir      string   // Disk buffer of log drains whose sink is unreachable
+	LogDrainBufferBytes    int64    // Per drain; the oldest batches are dropped beyond it
+	LogDrainAllowPrivate   bool     // Let drains reach private and loopback addresses (local listeners)
+	OTLPGRPCPort           string   // OTLP/gRPC receiver; OTLP/HTTP is served on Port. Empty disables it
 }
 
 func Load() (*Config, error) {
@@ -53,9 +67,21 @@ func Load() (*Config, error) {
 		DockerTLSVerify:        getEnv("DOCKER_TLS_VERIFY", "1"),
 		DockerCertPath:         getEnv("DOCKER_CERT_PATH", "/certs/client/client"),
 		MetricsInterval:        30 * time.Second,
-		HealthCheckInterval:    60 * time.Second,
+		HealthCheckInterval:    5 * time.Second, // scheduler tick; each deployment runs on its own health_check_interval_seconds
 		LogAggregationInterval: 10 * time.Second,
 		CoreAPIURL:             getEnv("CORE_API_URL", "http://core-api:7070"),
+		TraefikHTTPAddress:     getEnv("TRAEFIK_HTTP_ADDRESS", "traefik:80"),
+		TraefikTLSAddress:      getEnv("TRAEFIK_TLS_ADDRESS", "traefik:443"),
+		TraefikTLSEnabled:      getEnv("TRAEFIK_TLS_ENABLED", "true") == "true",
+		ProbeCARoots:           getEnv("ACME_CA_ROOTS", ""),
+		AppMetricsNetwork:      getEnv("APP_METRICS_NETWORK", "obtura_dev"),
+		ServiceAPITokens:       getEnvList("SERVICE_API_TOKENS", ""),
+		CORSAllowedOrigins:     getEnvList("CORS_ALLOWED_ORIGINS", "http://localhost:3000"),
+		SessionCookieName:      getEnv("SESSION_COOKIE_NAME", "obtura_session"),
+		LogDrainBufferDir:      getEnv("LOG_DRAIN_BUFFER_DIR", "/var/lib/obtura/log-drains"),
+		LogDrainBufferBytes:    getEnvInt64("LOG_DRAIN_BUFFER_MB", 256) << 20,
+		LogDrainAllowPrivate:   getEnv("LOG_DRAIN_ALLOW_PRIVATE_TARGETS", "false") == "true",
+		OTLPGRPCPort:           getEnv("OTLP_GRPC_PORT", "4317"),
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -149,6 +175,24 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvList reads a comma-separated variable
+func getEnvList(key, defaultValue string) []string {
+	var values []string
+	for _, v := range strings.Split(getEnv(key, defaultValue), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
 func (c *Config) GetPostgresConnString() string {
 	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
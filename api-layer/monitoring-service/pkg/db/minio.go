@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"time"
 
@@ -19,8 +22,8 @@ import (
 
 const (
 	LogsBucketName    = "monitoring-logs"
-	LogRetentionDB    = 24 * time.Hour      // Keep recent logs in DB
-	LogRetentionMinIO = 90 * 24 * time.Hour // Keep logs in MinIO for 90 days
+	LogRetentionDB    = 24 * time.Hour      // Keep recent logs in DB, unless a retention policy says otherwise
+	LogRetentionMinIO = 90 * 24 * time.Hour // Keep logs in MinIO for 90 days, unless a retention policy says otherwise
 	MaxLogsPerBatch   = 1000
 	CompressionLevel  = gzip.BestSpeed
 )
@@ -76,17 +79,66 @@ func NewLogStorage(client *minio.Client) *LogStorage {
 	return &LogStorage{client: client}
 }
 
-// StoreLogs stores compressed logs to MinIO
+// Archived logs are stored per deployment and day as numbered chunks with a manifest:
+//
+//	deployment-logs/{deployment_id}/{yyyy}/{mm}/{dd}/000001.json.gz
+//	deployment-logs/{deployment_id}/{yyyy}/{mm}/{dd}/manifest.json
+//
+// Days archived before chunking are a single deployment-logs/{id}/{yyyy}/{mm}/{dd}.json.gz,
+// which is still read.
+
+const logManifestName = "manifest.json"
+
+// LogChunk is one archived object of a deployment-day
+type LogChunk struct {
+	Object         string    `json:"object"`
+	Sequence       int       `json:"sequence"`
+	FirstTimestamp time.Time `json:"first_timestamp"`
+	LastTimestamp  time.Time `json:"last_timestamp"`
+	Count          int       `json:"count"`
+	SizeBytes      int64     `json:"size_bytes"`
+	BatchHash      string    `json:"batch_hash"` // Identifies the archived rows, so archiving them again is a no-op
+}
+
+// LogManifest indexes the chunks of one deployment-day
+type LogManifest struct {
+	DeploymentID   string     `json:"deployment_id"`
+	Date           string     `json:"date"`
+	Chunks         []LogChunk `json:"chunks"`
+	Count          int        `json:"count"`
+	FirstTimestamp time.Time  `json:"first_timestamp"`
+	LastTimestamp  time.Time  `json:"last_timestamp"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func logDayPrefix(deploymentID string, date time.Time) string {
+	return fmt.Sprintf("deployment-logs/%s/%s/", deploymentID, date.Format("2006/01/02"))
+}
+
+// StoreLogs appends logs to a deployment-day as a new chunk. The chunk is written
+// before the manifest that lists it, and its name comes from the manifest, so a run
+// that fails in between rewrites the same object when retried. Logs already in a chunk,
+// recognized by their IDs, are not stored twice.
 func (ls *LogStorage) StoreLogs(ctx context.Context, deploymentID string, date time.Time, logs []models.LogEntry) error {
 	if len(logs) == 0 {
 		return nil
 	}
 
-	// Format: deployment-logs/{deployment_id}/{year}/{month}/{day}.json.gz
-	objectName := fmt.Sprintf("deployment-logs/%s/%s.json.gz",
-		deploymentID,
-		date.Format("2006/01/02"),
-	)
+	manifest, err := ls.GetManifest(ctx, deploymentID, date)
+	if err != nil {
+		return err
+	}
+
+	hash := logBatchHash(logs)
+	for _, chunk := range manifest.Chunks {
+		if chunk.BatchHash == hash {
+			return nil
+		}
+	}
+
+	sorted := make([]models.LogEntry, len(logs))
+	copy(sorted, logs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
 
 	// Compress logs
 	var buf bytes.Buffer
@@ -96,14 +148,15 @@ func (ls *LogStorage) StoreLogs(ctx context.Context, deploymentID string, date t
 	}
 
 	// Write logs as JSON lines
-	for _, log := range logs {
+	for _, log := range sorted {
 		// Build log entry with metadata
 		logEntry := map[string]interface{}{
-			"timestamp":    log.Timestamp.Format(time.RFC3339),
+			"timestamp":    log.Timestamp.Format(time.RFC3339Nano),
 			"level":        log.Level,
 			"message":      log.Message,
 			"source":       log.Source,
 			"container_id": log.ContainerID,
+			"stream":       log.Stream,
 		}
 
 		// Add metadata if present
@@ -128,36 +181,127 @@ func (ls *LogStorage) StoreLogs(ctx context.Context, deploymentID string, date t
 		return fmt.Errorf("failed to close gzip writer: %w", err)
 	}
 
+	sequence := 1
+	if n := len(manifest.Chunks); n > 0 {
+		sequence = manifest.Chunks[n-1].Sequence + 1
+	}
+	chunk := LogChunk{
+		Object:         fmt.Sprintf("%s%06d.json.gz", logDayPrefix(deploymentID, date), sequence),
+		Sequence:       sequence,
+		FirstTimestamp: sorted[0].Timestamp,
+		LastTimestamp:  sorted[len(sorted)-1].Timestamp,
+		Count:          len(sorted),
+		SizeBytes:      int64(buf.Len()),
+		BatchHash:      hash,
+	}
+
 	// Upload to MinIO
-	_, err = ls.client.PutObject(ctx, LogsBucketName, objectName, &buf, int64(buf.Len()),
+	_, err = ls.client.PutObject(ctx, LogsBucketName, chunk.Object, &buf, int64(buf.Len()),
 		minio.PutObjectOptions{
 			ContentType:     "application/gzip",
 			ContentEncoding: "gzip",
 		})
-
 	if err != nil {
 		return fmt.Errorf("failed to upload logs to MinIO: %w", err)
 	}
 
+	manifest.Chunks = append(manifest.Chunks, chunk)
+	manifest.Count += chunk.Count
+	if manifest.FirstTimestamp.IsZero() || chunk.FirstTimestamp.Before(manifest.FirstTimestamp) {
+		manifest.FirstTimestamp = chunk.FirstTimestamp
+	}
+	if chunk.LastTimestamp.After(manifest.LastTimestamp) {
+		manifest.LastTimestamp = chunk.LastTimestamp
+	}
+	manifest.UpdatedAt = time.Now()
+
+	return ls.putManifest(ctx, manifest, date)
+}
+
+// GetManifest returns the manifest of a deployment-day, empty if nothing was archived
+// in chunks that day
+func (ls *LogStorage) GetManifest(ctx context.Context, deploymentID string, date time.Time) (*LogManifest, error) {
+	manifest := &LogManifest{DeploymentID: deploymentID, Date: date.Format("2006-01-02")}
+
+	obj, err := ls.client.GetObject(ctx, LogsBucketName, logDayPrefix(deploymentID, date)+logManifestName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get log manifest: %w", err)
+	}
+	defer obj.Close()
+
+	if err := json.NewDecoder(obj).Decode(manifest); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return manifest, nil
+		}
+		return nil, fmt.Errorf("failed to read log manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func (ls *LogStorage) putManifest(ctx context.Context, manifest *LogManifest, date time.Time) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	_, err = ls.client.PutObject(ctx, LogsBucketName, logDayPrefix(manifest.DeploymentID, date)+logManifestName,
+		bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: "application/json"})
+	if err != nil {
+		return fmt.Errorf("failed to upload log manifest: %w", err)
+	}
 	return nil
 }
 
-// GetLogs retrieves logs from MinIO for a date range
+// logBatchHash identifies a batch by the database IDs of its logs, or by their content
+// for logs without one
+func logBatchHash(logs []models.LogEntry) string {
+	keys := make([]string, len(logs))
+	for i, log := range logs {
+		if log.ID != "" {
+			keys[i] = log.ID
+		} else {
+			keys[i] = fmt.Sprintf("%s|%d|%s", log.ContainerID, log.Timestamp.UnixNano(), log.Message)
+		}
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, key := range keys {
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GetLogs retrieves logs from MinIO for a date range, in time order within each day
 func (ls *LogStorage) GetLogs(ctx context.Context, deploymentID string, startDate, endDate time.Time) ([]models.LogEntry, error) {
 	var allLogs []models.LogEntry
 
-	prefix := fmt.Sprintf("deployment-logs/%s/", deploymentID)
-
 	for current := startDate; !current.After(endDate); current = current.AddDate(0, 0, 1) {
-		objectName := fmt.Sprintf("%s%s.json.gz", prefix, current.Format("2006/01/02"))
-
-		logs, err := ls.getLogsFromObject(ctx, objectName)
+		manifest, err := ls.GetManifest(ctx, deploymentID, current)
 		if err != nil {
-			// Object might not exist, continue
-			continue
+			return nil, err
+		}
+
+		var dayLogs []models.LogEntry
+
+		// Days archived before chunking; missing for most days
+		legacy := fmt.Sprintf("deployment-logs/%s/%s.json.gz", deploymentID, current.Format("2006/01/02"))
+		if logs, err := ls.getLogsFromObject(ctx, legacy); err == nil {
+			dayLogs = append(dayLogs, logs...)
 		}
 
-		allLogs = append(allLogs, logs...)
+		for _, chunk := range manifest.Chunks {
+			logs, err := ls.getLogsFromObject(ctx, chunk.Object)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read log chunk %s: %w", chunk.Object, err)
+			}
+			dayLogs = append(dayLogs, logs...)
+		}
+
+		// Chunks are sorted internally but a late batch can overlap earlier ones
+		sort.SliceStable(dayLogs, func(i, j int) bool { return dayLogs[i].Timestamp.Before(dayLogs[j].Timestamp) })
+		allLogs = append(allLogs, dayLogs...)
 	}
 
 	return allLogs, nil
@@ -192,51 +336,82 @@ func (ls *LogStorage) getLogsFromObject(ctx context.Context, objectName string)
 	return logs, nil
 }
 
-// DeleteLogs deletes logs from MinIO
-func (ls *LogStorage) DeleteLogs(ctx context.Context, deploymentID string, before time.Time) error {
+// DeleteLogs deletes a deployment's archived days before the given day and returns the
+// number of objects removed
+func (ls *LogStorage) DeleteLogs(ctx context.Context, deploymentID string, before time.Time) (int, error) {
 	prefix := fmt.Sprintf("deployment-logs/%s/", deploymentID)
 
+	var expired []minio.ObjectInfo
+	for object := range ls.client.ListObjects(ctx, LogsBucketName, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if object.Err != nil {
+			continue
+		}
+		// Parse date from object key
+		if isLogOlderThan(object.Key, before) {
+			expired = append(expired, object)
+		}
+	}
+
+	return ls.removeObjects(ctx, expired)
+}
+
+// removeObjects deletes the given objects and returns how many were removed
+func (ls *LogStorage) removeObjects(ctx context.Context, objects []minio.ObjectInfo) (int, error) {
+	if len(objects) == 0 {
+		return 0, nil
+	}
+
 	objectsCh := make(chan minio.ObjectInfo)
 	go func() {
 		defer close(objectsCh)
-		for object := range ls.client.ListObjects(ctx, LogsBucketName, minio.ListObjectsOptions{
-			Prefix:    prefix,
-			Recursive: true,
-		}) {
-			if object.Err != nil {
-				continue
-			}
-			// Parse date from object key
-			if isLogOlderThan(object.Key, before) {
-				objectsCh <- object
+		for _, object := range objects {
+			select {
+			case objectsCh <- object:
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
 
-	errorsCh := ls.client.RemoveObjects(ctx, LogsBucketName, objectsCh, minio.RemoveObjectsOptions{})
-	for err := range errorsCh {
+	failed := 0
+	var firstErr error
+	for err := range ls.client.RemoveObjects(ctx, LogsBucketName, objectsCh, minio.RemoveObjectsOptions{}) {
 		if err.Err != nil {
-			return fmt.Errorf("failed to delete log object %s: %w", err.ObjectName, err.Err)
+			failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to delete log object %s: %w", err.ObjectName, err.Err)
+			}
 		}
 	}
+	if firstErr == nil && ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
 
-	return nil
+	return len(objects) - failed, firstErr
 }
 
-func isLogOlderThan(objectKey string, before time.Time) bool {
-	// Extract date from path like: deployment-logs/{id}/2024/01/15.json.gz
+// logObjectDate returns the day of an archived object, from keys like
+// deployment-logs/{id}/2024/01/15/000001.json.gz or deployment-logs/{id}/2024/01/15.json.gz
+func logObjectDate(objectKey string) (time.Time, bool) {
 	parts := strings.Split(objectKey, "/")
 	if len(parts) < 5 {
-		return false
+		return time.Time{}, false
 	}
 
-	dateStr := parts[len(parts)-3] + "-" + parts[len(parts)-2] + "-" + strings.TrimSuffix(parts[len(parts)-1], ".json.gz")
+	dateStr := parts[2] + "-" + parts[3] + "-" + strings.TrimSuffix(parts[4], ".json.gz")
 	date, err := time.Parse("2006-01-02", dateStr)
 	if err != nil {
-		return false
+		return time.Time{}, false
 	}
+	return date, true
+}
 
-	return date.Before(before)
+func isLogOlderThan(objectKey string, before time.Time) bool {
+	date, ok := logObjectDate(objectKey)
+	return ok && date.Before(before)
 }
 
 // GetLogStats returns statistics about stored logs
@@ -257,19 +432,18 @@ func (ls *LogStorage) GetLogStats(ctx context.Context, deploymentID string) (map
 		}
 
 		totalSize += object.Size
+		if strings.HasSuffix(object.Key, "/"+logManifestName) {
+			continue
+		}
 		fileCount++
 
 		// Parse date from object key
-		parts := strings.Split(object.Key, "/")
-		if len(parts) >= 5 {
-			dateStr := parts[len(parts)-3] + "-" + parts[len(parts)-2] + "-" + strings.TrimSuffix(parts[len(parts)-1], ".json.gz")
-			if date, err := time.Parse("2006-01-02", dateStr); err == nil {
-				if date.Before(oldestDate) {
-					oldestDate = date
-				}
-				if date.After(newestDate) {
-					newestDate = date
-				}
+		if date, ok := logObjectDate(object.Key); ok {
+			if date.Before(oldestDate) {
+				oldestDate = date
+			}
+			if date.After(newestDate) {
+				newestDate = date
 			}
 		}
 	}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Platform events leaving the database are archived per event type, project and day:
+//
+//	platform-logs/{event_type}/{project_id}/{yyyy}/{mm}/{dd}/{batch_hash}.json.gz
+//
+// Events without a project are stored under PlatformLogsNoProject. The object name comes
+// from the IDs of the events, so archiving the same batch again overwrites it.
+
+const PlatformLogsNoProject = "_system"
+
+func platformLogPrefix(eventType string) string {
+	return fmt.Sprintf("platform-logs/%s/", eventType)
+}
+
+// StorePlatformLogs archives a batch of events, one JSON document per line, and returns
+// the object name
+func (ls *LogStorage) StorePlatformLogs(ctx context.Context, eventType, projectID string, date time.Time, ids []string, events []json.RawMessage) (string, error) {
+	if projectID == "" {
+		projectID = PlatformLogsNoProject
+	}
+
+	var buf bytes.Buffer
+	gzipWriter, err := gzip.NewWriterLevel(&buf, CompressionLevel)
+	if err != nil {
+		return "", fmt.Errorf("failed to create gzip writer: %w", err)
+	}
+	for _, event := range events {
+		if _, err := gzipWriter.Write(append(event, '\n')); err != nil {
+			gzipWriter.Close()
+			return "", fmt.Errorf("failed to write platform event: %w", err)
+		}
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return "", fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+
+	sorted := make([]string, len(ids))
+	copy(sorted, ids)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\x00")))
+
+	objectName := fmt.Sprintf("%s%s/%s/%s.json.gz",
+		platformLogPrefix(eventType), projectID, date.Format("2006/01/02"), hex.EncodeToString(sum[:16]))
+
+	_, err = ls.client.PutObject(ctx, LogsBucketName, objectName, &buf, int64(buf.Len()),
+		minio.PutObjectOptions{
+			ContentType:     "application/gzip",
+			ContentEncoding: "gzip",
+		})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload platform events to MinIO: %w", err)
+	}
+
+	return objectName, nil
+}
+
+// DeletePlatformLogs deletes the archived days of an event type for which expired
+// returns true, given the project (PlatformLogsNoProject for none) and the day, and
+// returns the number of objects removed
+func (ls *LogStorage) DeletePlatformLogs(ctx context.Context, eventType string, expired func(projectID string, day time.Time) bool) (int, error) {
+	var objects []minio.ObjectInfo
+	for object := range ls.client.ListObjects(ctx, LogsBucketName, minio.ListObjectsOptions{
+		Prefix:    platformLogPrefix(eventType),
+		Recursive: true,
+	}) {
+		if object.Err != nil {
+			continue
+		}
+
+		// platform-logs/{event_type}/{project_id}/{yyyy}/{mm}/{dd}/{hash}.json.gz
+		parts := strings.Split(object.Key, "/")
+		if len(parts) != 7 {
+			continue
+		}
+		day, err := time.Parse("2006-01-02", parts[3]+"-"+parts[4]+"-"+parts[5])
+		if err != nil {
+			continue
+		}
+		if expired(parts[2], day) {
+			objects = append(objects, object)
+		}
+	}
+
+	return ls.removeObjects(ctx, objects)
+}
@@ -1,6 +1,7 @@
 package docker
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -12,8 +13,18 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
+// Streams of a container log line
+const (
+	LogStreamStdout = "stdout"
+	LogStreamStderr = "stderr"
+)
+
+// Longest log line passed on whole; longer lines are split
+const maxLogLineBytes = 256 * 1024
+
 type Client struct {
 	cli *client.Client
 }
@@ -156,6 +167,79 @@ func (c *Client) GetContainerLogs(ctx context.Context, containerID string, lines
 	return logLines, nil
 }
 
+// FollowContainerLogs streams a container's logs from since until the container stops
+// or ctx is cancelled, calling handle for every line. Lines keep Docker's RFC3339Nano
+// timestamp prefix.
+func (c *Client) FollowContainerLogs(ctx context.Context, containerID string, since time.Time, handle func(stream, line string)) error {
+	info, err := c.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	options := container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Timestamps: true,
+	}
+	if !since.IsZero() {
+		options.Since = fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond())
+	}
+
+	logs, err := c.cli.ContainerLogs(ctx, containerID, options)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	stdout := &logLineWriter{stream: LogStreamStdout, handle: handle}
+	stderr := &logLineWriter{stream: LogStreamStderr, handle: handle}
+
+	// Without a TTY Docker multiplexes stdout and stderr in framed chunks; a TTY has a
+	// single stream
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(stdout, logs)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, logs)
+	}
+	stdout.flush()
+	stderr.flush()
+	return err
+}
+
+// logLineWriter splits one stream of a container's output into lines
+type logLineWriter struct {
+	stream string
+	handle func(stream, line string)
+	buf    []byte
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	rest := w.buf
+	for {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			break
+		}
+		w.handle(w.stream, string(bytes.TrimRight(rest[:i], "\r")))
+		rest = rest[i+1:]
+	}
+	if len(rest) > maxLogLineBytes {
+		w.handle(w.stream, string(rest))
+		rest = nil
+	}
+	w.buf = append(w.buf[:0], rest...)
+	return len(p), nil
+}
+
+func (w *logLineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.handle(w.stream, string(w.buf))
+		w.buf = w.buf[:0]
+	}
+}
+
 // ListContainers lists all containers
 func (c *Client) ListContainers(ctx context.Context) ([]types.Container, error) {
 	return c.cli.ContainerList(ctx, container.ListOptions{All: true})
@@ -1,23 +1,35 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/AALXX/Obtura/shared/healthcheck"
+)
 
 // Deployment represents a deployed application
 type Deployment struct {
 	ID                  string
 	ProjectID           string
+	Environment         string
+	Domain              string // Public host Traefik routes to this deployment
 	ContainerID         string // Docker/K8s container ID from deployment_containers table
 	ContainerUUID       string // UUID of the container record in deployment_containers table
 	Status              string
 	ContainerStatus     string
 	HealthCheckEndpoint string
 	HealthCheckEnabled  bool
+	HealthCheck         HealthCheckSpec
+	Port                int
 	HasDatabase         bool
 	HasCache            bool
 	CreatedAt           time.Time
 	UpdatedAt           time.Time
 }
 
+// HealthCheckSpec is read back from the health_check_* columns the deploy-service
+// resolves per deployment
+type HealthCheckSpec = healthcheck.Spec
+
 // Alert represents a monitoring alert
 type Alert struct {
 	ID             string
@@ -52,34 +64,58 @@ type Metric struct {
 	Status       string
 }
 
-// LogEntry represents a log entry
+// LogEntry represents a log entry. The JSON names are those of archived log lines.
 type LogEntry struct {
-	ID           string
-	DeploymentID string
-	ContainerID  string
-	Timestamp    time.Time
-	Level        string
-	Message      string
-	Source       string
-	Metadata     map[string]interface{}
+	ID           string                 `json:"id,omitempty"`
+	DeploymentID string                 `json:"deployment_id,omitempty"`
+	ContainerID  string                 `json:"container_id"`
+	Timestamp    time.Time              `json:"timestamp"`
+	Level        string                 `json:"level"`
+	Message      string                 `json:"message"`
+	Source       string                 `json:"source"`
+	Stream       string                 `json:"stream,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
 }
 
 // Incident represents a deployment incident
 type Incident struct {
-	ID            string
-	DeploymentID  string
-	Title         string
-	Description   string
-	Severity      string
-	Status        string
-	StartedAt     time.Time
-	DetectedAt    *time.Time
-	ResolvedAt    *time.Time
-	AssignedTo    *string
-	RootCause     string
-	Resolution    string
-	ImpactSummary string
-	Timeline      []IncidentEvent
+	ID             string
+	ProjectID      string
+	DeploymentID   string
+	Title          string
+	Description    string
+	Severity       string
+	Status         string
+	StartedAt      time.Time
+	DetectedAt     *time.Time
+	AcknowledgedAt *time.Time
+	MitigatedAt    *time.Time
+	ResolvedAt     *time.Time
+	LastAlertAt    time.Time
+	AlertCount     int
+	AssignedTo     *string
+	RootCause      string
+	Resolution     string
+	ImpactSummary  string
+	Timeline       []IncidentEvent
+}
+
+// TimeToAcknowledge is the incident's contribution to MTTA, nil until acknowledged
+func (i *Incident) TimeToAcknowledge() *time.Duration {
+	if i.AcknowledgedAt == nil {
+		return nil
+	}
+	d := i.AcknowledgedAt.Sub(i.StartedAt)
+	return &d
+}
+
+// TimeToResolve is the incident's contribution to MTTR, nil until resolved
+func (i *Incident) TimeToResolve() *time.Duration {
+	if i.ResolvedAt == nil {
+		return nil
+	}
+	d := i.ResolvedAt.Sub(i.StartedAt)
+	return &d
 }
 
 // IncidentEvent represents an event in an incident timeline
@@ -88,6 +124,7 @@ type IncidentEvent struct {
 	EventType   string
 	Description string
 	User        string
+	Source      string // 'incident', 'deployment', 'rollback'
 }
 
 // HealthCheck represents a health check result
@@ -0,0 +1,82 @@
+// Package netguard keeps connections to customer supplied endpoints (log drains,
+// remote-write targets) off the platform's own network
+package netguard
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // "This" network
+	"100.64.0.0/10", // Carrier-grade NAT, used by some cloud and VPN internal networks
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // Benchmarking
+)
+
+// embeddedIPv4Prefixes carry an IPv4 address in their last four bytes, which is what
+// the connection reaches: IPv4-compatible (::/96) and NAT64 (64:ff9b::/96) addresses.
+// IPv4-mapped addresses (::ffff:0:0/96) are unwrapped by To4.
+var embeddedIPv4Prefixes = mustParseCIDRs("::/96", "64:ff9b::/96")
+
+// IsBlocked reports whether ip is loopback, private, link-local (including the cloud
+// metadata address 169.254.169.254), CGNAT or unspecified, also when written as an
+// IPv4 address inside an IPv6 one
+func IsBlocked(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	} else if len(ip) == net.IPv6len {
+		for _, prefix := range embeddedIPv4Prefixes {
+			if prefix.Contains(ip) {
+				ip = ip[12:]
+				break
+			}
+		}
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dialer returns a dialer that refuses blocked addresses once they are resolved, so a
+// hostname resolving to a private address is refused too. what names the connection
+// in the error, e.g. "log drain".
+func Dialer(what string) *net.Dialer {
+	return &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || IsBlocked(ip) {
+				return fmt.Errorf("%s to %s is not allowed", what, host)
+			}
+			return nil
+		},
+	}
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -19,6 +19,7 @@ import { useDeploymentUpdates } from '@/hooks/useDeployuseDeploymentUpdates'
 import CreateServiceDialog from './components/CreateServiceDialog'
 import { AIAgentWrapper } from '@/features/ai-agent/components/AIAgentWrapper'
 import Link from 'next/link'
+import { monitoringAuth, openMonitoringSession } from '@/lib/monitoring'
 
 const ProjectDetails: React.FC<{ projectData: ProjectData; accessToken: string; services: { service_name: string; env_vars: Record<string, string> }[] }> = ({ projectData, accessToken, services }) => {
     const [activeTab, setActiveTab] = useState<'overview' | 'deployments' | 'deploymentHistory' | 'environment' | 'settings' | 'monitoring' | 'builds'>('overview')
@@ -101,6 +102,11 @@ const ProjectDetails: React.FC<{ projectData: ProjectData; accessToken: string;
     )
     const liveDeployments = useDeploymentUpdates(projectData.id, deployments)
 
+    // Log and metrics streams authenticate with the monitoring session cookie
+    useEffect(() => {
+        openMonitoringSession(accessToken)
+    }, [accessToken])
+
     useEffect(() => {
         const productionDeployment = liveDeployments.find(d => d.environment === 'production')
         const stagingDeployment = liveDeployments.find(d => d.environment === 'staging')
@@ -338,7 +344,7 @@ const ProjectDetails: React.FC<{ projectData: ProjectData; accessToken: string;
         setResolvingAlerts(prev => new Set(prev).add(alertId))
 
         try {
-            await axios.post(`${process.env.NEXT_PUBLIC_MONITORING_SERVICE_URL}/api/alerts/${alertId}/resolve/${accessToken}`)
+            await axios.post(`${process.env.NEXT_PUBLIC_MONITORING_SERVICE_URL}/api/alerts/${alertId}/resolve`, null, monitoringAuth(accessToken))
 
             setAlerts(prev => prev.filter(alert => alert.id !== alertId))
 
@@ -370,7 +376,7 @@ const ProjectDetails: React.FC<{ projectData: ProjectData; accessToken: string;
         setDeletingAlerts(prev => new Set(prev).add(alertId))
 
         try {
-            await axios.delete(`${process.env.NEXT_PUBLIC_MONITORING_SERVICE_URL}/api/alerts/${alertId}?accessToken=${accessToken}`)
+            await axios.delete(`${process.env.NEXT_PUBLIC_MONITORING_SERVICE_URL}/api/alerts/${alertId}`, monitoringAuth(accessToken))
 
             setAlerts(prev => prev.filter(alert => alert.id !== alertId))
 
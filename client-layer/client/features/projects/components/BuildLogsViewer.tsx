@@ -65,7 +65,8 @@ const BuildLogsViewer: React.FC<BuildLogsViewerProps> = ({ build, onClose }) =>
             setIsLoading(true)
             try {
                 const resp = await axios.get<{ events: PlatformLogEvent[]; total: number }>(
-                    `${MONITORING_SERVICE_URL}/api/platform-logs/query?resource_type=build&resource_id=${build.id}&limit=1000`
+                    `${MONITORING_SERVICE_URL}/api/platform-logs/query?resource_type=build&resource_id=${build.id}&limit=1000`,
+                    { withCredentials: true }
                 )
                 if (!cancelled && resp.status === 200 && resp.data.events?.length > 0) {
                     setLogs(resp.data.events.map((event: PlatformLogEvent) => ({
@@ -143,7 +144,7 @@ const BuildLogsViewer: React.FC<BuildLogsViewerProps> = ({ build, onClose }) =>
         eventSource.onerror = () => {
             // Connection lost or build-service unreachable — fall back to platform-logs SSE
             eventSource.close()
-            const fallback = new EventSource(`${MONITORING_SERVICE_URL}/api/platform-logs/stream/build/${build.id}`)
+            const fallback = new EventSource(`${MONITORING_SERVICE_URL}/api/platform-logs/stream/build/${build.id}`, { withCredentials: true })
             eventSourceRef.current = fallback
 
             fallback.addEventListener('log', e => {
@@ -174,7 +174,7 @@ const DeploymentLogsViewer: React.FC<DeploymentLogsViewerProps> = ({ deploymentI
         // Fetch build logs from unified API if we have a buildId
         if (buildId) {
             try {
-                const buildResp = await axios.get<{ events: PlatformLogEvent[]; total: number }>(`${MONITORING_SERVICE_URL}/api/platform-logs/query?resource_type=build&resource_id=${buildId}&limit=1000`)
+                const buildResp = await axios.get<{ events: PlatformLogEvent[]; total: number }>(`${MONITORING_SERVICE_URL}/api/platform-logs/query?resource_type=build&resource_id=${buildId}&limit=1000`, { withCredentials: true })
                 if (buildResp.status === 200 && buildResp.data.events && buildResp.data.events.length > 0) {
                     const buildLogs = buildResp.data.events.map((event: PlatformLogEvent) => ({
                         time: formatLogTime(event.event_timestamp),
@@ -206,7 +206,7 @@ const DeploymentLogsViewer: React.FC<DeploymentLogsViewerProps> = ({ deploymentI
 
         // Fetch deployment logs from unified API
         try {
-            const deployResp = await axios.get<{ events: PlatformLogEvent[]; total: number }>(`${MONITORING_SERVICE_URL}/api/platform-logs/query?resource_type=deployment&resource_id=${deploymentId}&limit=1000`)
+            const deployResp = await axios.get<{ events: PlatformLogEvent[]; total: number }>(`${MONITORING_SERVICE_URL}/api/platform-logs/query?resource_type=deployment&resource_id=${deploymentId}&limit=1000`, { withCredentials: true })
             if (deployResp.status === 200 && deployResp.data.events && deployResp.data.events.length > 0) {
                 const deployLogs = deployResp.data.events.map((event: PlatformLogEvent) => ({
                     time: formatLogTime(event.event_timestamp),
@@ -341,7 +341,7 @@ const DeploymentLogsViewer: React.FC<DeploymentLogsViewerProps> = ({ deploymentI
 
         const connectToBuildSSE = () => {
             // Try unified API first, fallback to old API on error
-            const unifiedEventSource = new EventSource(`${MONITORING_SERVICE_URL}/api/platform-logs/stream/build/${buildId}`)
+            const unifiedEventSource = new EventSource(`${MONITORING_SERVICE_URL}/api/platform-logs/stream/build/${buildId}`, { withCredentials: true })
             buildEventSourceRef.current = unifiedEventSource
 
             unifiedEventSource.onopen = () => {
@@ -426,7 +426,7 @@ const DeploymentLogsViewer: React.FC<DeploymentLogsViewerProps> = ({ deploymentI
             // Track connection state to suppress initial HTTP/3 errors
             let isConnectionOpen = false
 
-            const unifiedEventSource = new EventSource(`${MONITORING_SERVICE_URL}/api/platform-logs/stream/deployment/${deploymentId}`)
+            const unifiedEventSource = new EventSource(`${MONITORING_SERVICE_URL}/api/platform-logs/stream/deployment/${deploymentId}`, { withCredentials: true })
             deployEventSourceRef.current = unifiedEventSource
 
             unifiedEventSource.onopen = () => {
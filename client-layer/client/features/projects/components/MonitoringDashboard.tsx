@@ -5,6 +5,7 @@ import { Activity, TrendingUp, Zap, AlertCircle, Clock, Globe, Cpu, Database, Se
 import { ProjectData, Alert as ProjectAlert } from '../Types/ProjectTypes'
 import axios from 'axios'
 import { useProjectMetricsUpdates } from '@/hooks/useProjectMetrics'
+import { monitoringAuth } from '@/lib/monitoring'
 
 // Custom Checkbox Component with dark theme styling
 interface CustomCheckboxProps {
@@ -1101,7 +1102,8 @@ const MonitoringDashboard: React.FC<MonitoringProps> = ({ projectData, alerts: p
         try {
             const monitoringUrl = process.env.NEXT_PUBLIC_MONITORING_SERVICE_URL || 'http://localhost:5110'
             const response = await axios.get<{ data: { metrics: any } }>(
-                `${monitoringUrl}/api/projects/${projectId}/metrics?timeRange=${range}`
+                `${monitoringUrl}/api/projects/${projectId}/metrics?timeRange=${range}`,
+                monitoringAuth(accessToken)
             )
             if (response.data?.data?.metrics) {
                 setMetricData(response.data.data.metrics)
@@ -1112,7 +1114,7 @@ const MonitoringDashboard: React.FC<MonitoringProps> = ({ projectData, alerts: p
         } finally {
             setIsLoading(false)
         }
-    }, [projectId])
+    }, [projectId, accessToken])
 
     useEffect(() => {
         fetchInitialMetrics(timeRange)
@@ -1138,7 +1140,7 @@ const MonitoringDashboard: React.FC<MonitoringProps> = ({ projectData, alerts: p
     const handleResolveAlert = async (alertId: string) => {
         try {
             const monitoringUrl = process.env.NEXT_PUBLIC_MONITORING_SERVICE_URL || 'http://localhost:5110'
-            await axios.post(`${monitoringUrl}/api/alerts/${alertId}/resolve/${accessToken}`)
+            await axios.post(`${monitoringUrl}/api/alerts/${alertId}/resolve`, null, monitoringAuth(accessToken))
             setAlerts(prev => prev.filter(a => a.id !== alertId))
             onResolveAlert(alertId)
         } catch (error) {
@@ -1155,8 +1157,7 @@ const MonitoringDashboard: React.FC<MonitoringProps> = ({ projectData, alerts: p
         try {
             setDeletingAlerts(prev => new Set(prev).add(alertId))
             const monitoringUrl = process.env.NEXT_PUBLIC_MONITORING_SERVICE_URL || 'http://localhost:5110'
-            // Use query parameter for access token (consistent with other endpoints)
-            await axios.delete(`${monitoringUrl}/api/alerts/${alertId}?accessToken=${accessToken}`)
+            await axios.delete(`${monitoringUrl}/api/alerts/${alertId}`, monitoringAuth(accessToken))
             setAlerts(prev => prev.filter(a => a.id !== alertId))
             setSelectedAlerts(prev => {
                 const next = new Set(prev)
@@ -1,4 +1,5 @@
 import { useEffect, useRef, useCallback, useState } from 'react'
+import { monitoringSessionReady } from '@/lib/monitoring'
 
 export interface ProjectMetrics {
   projectId: string
@@ -68,7 +69,7 @@ export function useProjectMetricsUpdates(projectId: string, timeRange: string =
     const url = `${MONITORING_SERVICE_URL}/api/projects/${currentProjectId}/metrics/sse?timeRange=${currentTimeRange}`
     console.log('[metrics] SSE connecting to:', url)
     
-    const eventSource = new EventSource(url)
+    const eventSource = new EventSource(url, { withCredentials: true })
     eventSourceRef.current = eventSource
 
     eventSource.onopen = () => {
@@ -132,9 +133,14 @@ export function useProjectMetricsUpdates(projectId: string, timeRange: string =
 
   useEffect(() => {
     reconnectAttemptsRef.current = 0
-    connectSSE(timeRange)
+    let cancelled = false
+    // The stream authenticates with the session cookie
+    monitoringSessionReady().then(() => {
+      if (!cancelled) connectSSE(timeRange)
+    })
 
     return () => {
+      cancelled = true
       console.log('[metrics] Cleanup: closing SSE connection')
       if (reconnectTimeoutRef.current) {
         clearTimeout(reconnectTimeoutRef.current)
@@ -0,0 +1,26 @@
+import axios from 'axios'
+
+const MONITORING_SERVICE_URL = process.env.NEXT_PUBLIC_MONITORING_SERVICE_URL || 'http://localhost:5110'
+
+// Authorization header for monitoring-service requests
+export const monitoringAuth = (accessToken: string) => ({
+    headers: { Authorization: `Bearer ${accessToken}` }
+})
+
+let session: { accessToken: string; ready: Promise<void> } | null = null
+
+// EventSource and WebSocket cannot send headers, so the session is also stored in an
+// HttpOnly cookie on the monitoring service; streams are opened with withCredentials
+export const openMonitoringSession = (accessToken: string): Promise<void> => {
+    if (session?.accessToken !== accessToken) {
+        const ready = axios
+            .post(`${MONITORING_SERVICE_URL}/api/auth/session`, null, { ...monitoringAuth(accessToken), withCredentials: true })
+            .then(() => undefined)
+            .catch(error => console.error('Failed to open monitoring session:', error))
+        session = { accessToken, ready }
+    }
+    return session.ready
+}
+
+// Resolves once the session cookie is set, if a session is being opened
+export const monitoringSessionReady = (): Promise<void> => session?.ready ?? Promise.resolve()
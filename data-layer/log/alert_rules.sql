@@ -0,0 +1,55 @@
+-- ============================================================================
+-- ALERT RULES
+-- Per-project alert conditions written in the alert expression language, e.g.
+-- cpu_usage > 85 or avg(error_rate, "10m") > 5. A rule goes pending on a deployment
+-- when its expression holds and fires once it held for for_seconds.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    environment VARCHAR(50), -- NULL evaluates every environment
+    name VARCHAR(50) NOT NULL, -- Also the alert_type of the alerts it raises
+    description TEXT,
+    expression TEXT NOT NULL,
+    for_seconds INTEGER NOT NULL DEFAULT 0, -- How long the expression must hold before firing
+    severity VARCHAR(20) NOT NULL DEFAULT 'warning', -- 'info', 'low', 'warning', 'medium', 'high', 'critical'
+    channels JSONB NOT NULL DEFAULT '[]', -- Notification channels: 'email', 'slack', 'webhook'
+    renotify_seconds INTEGER, -- Repeat notifications while firing; NULL uses the severity default, 0 never repeats
+    enabled BOOLEAN DEFAULT true,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    UNIQUE (project_id, name),
+    CHECK (for_seconds BETWEEN 0 AND 86400),
+    CHECK (renotify_seconds IS NULL OR renotify_seconds = 0 OR renotify_seconds >= 300),
+    CHECK (severity IN ('info', 'low', 'warning', 'medium', 'high', 'critical'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_project ON alert_rules(project_id);
+
+-- Rules currently pending or firing on a deployment. Rows are removed when the
+-- expression stops holding, which resolves the alert.
+CREATE TABLE IF NOT EXISTS alert_rule_states (
+    rule_id UUID NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    state VARCHAR(20) NOT NULL, -- 'pending', 'firing'
+    pending_since TIMESTAMP NOT NULL DEFAULT NOW(),
+    firing_since TIMESTAMP,
+    last_value DOUBLE PRECISION, -- Value of the expression's first metric at the last evaluation
+    last_evaluated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    alert_id UUID REFERENCES deployment_alerts(id) ON DELETE SET NULL,
+
+    PRIMARY KEY (rule_id, deployment_id),
+    CHECK (state IN ('pending', 'firing'))
+);
+
+-- Projects that received the default rules, so deleting them sticks
+CREATE TABLE IF NOT EXISTS alert_rule_seeds (
+    project_id UUID PRIMARY KEY REFERENCES projects(id) ON DELETE CASCADE,
+    seeded_at TIMESTAMP DEFAULT NOW()
+);
+
+COMMENT ON TABLE alert_rules IS 'User-defined alert rules evaluated on every deployment of a project';
+COMMENT ON TABLE alert_rule_states IS 'Pending and firing alert rules per deployment';
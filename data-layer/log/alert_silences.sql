@@ -0,0 +1,46 @@
+-- ============================================================================
+-- ALERT SILENCES AND MAINTENANCE WINDOWS
+-- Silences mute notifications of matching alerts until they expire. Maintenance
+-- windows mute every alert of a project (or one environment) and keep downtime out
+-- of uptime and SLOs while they are active.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS alert_silences (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+
+    -- Matchers; NULL matches anything
+    deployment_id UUID REFERENCES deployments(id) ON DELETE CASCADE,
+    alert_type VARCHAR(50), -- Alert rule name or built-in alert type, e.g. 'health_check'
+
+    comment TEXT NOT NULL,
+    starts_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    ends_at TIMESTAMP NOT NULL,
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    expired_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL, -- Set when ended early
+    created_at TIMESTAMP DEFAULT NOW(),
+
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_silences_active ON alert_silences(project_id, ends_at);
+
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    environment VARCHAR(50), -- NULL covers every environment
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_windows_active ON maintenance_windows(project_id, ends_at);
+
+COMMENT ON TABLE alert_silences IS 'Time-boxed muting of matching alert notifications';
+COMMENT ON TABLE maintenance_windows IS 'Scheduled maintenance: alerts are muted and downtime is not counted';
@@ -0,0 +1,24 @@
+-- ============================================================================
+-- MONITORING API TOKENS
+-- Machine access to one project's monitoring APIs (CI pipelines, scripts, external
+-- dashboards). A token carries a subset of its creator's RBAC permissions as scopes;
+-- only its sha256 hash is stored.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    token_prefix VARCHAR(16) NOT NULL, -- First characters, to tell tokens apart in the UI
+    scopes JSONB NOT NULL DEFAULT '[]', -- Permissions as "resource:action", e.g. ["deployment:read", "logs:read"]
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP, -- NULL never expires
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    revoked_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_tokens_name ON api_tokens(project_id, name) WHERE revoked_at IS NULL;
+
+COMMENT ON TABLE api_tokens IS 'Project-scoped tokens for machine access to the monitoring APIs';
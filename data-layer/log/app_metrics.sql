@@ -0,0 +1,49 @@
+-- ============================================================================
+-- APPLICATION METRICS
+-- Prometheus metrics scraped from the app's own /metrics endpoint (opt-in per project,
+-- see project_settings.app_metrics_*). Series per deployment are capped by the plan's
+-- max_app_metric_series; samples follow metrics_minute_retention_days.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS app_metric_series (
+    id BIGSERIAL PRIMARY KEY,
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    metric_name VARCHAR(200) NOT NULL, -- Sample name, e.g. http_requests_total or latency_seconds_bucket
+    metric_type VARCHAR(20) NOT NULL DEFAULT 'untyped', -- Family type: counter, gauge, histogram, summary, untyped
+    help TEXT,
+    labels JSONB NOT NULL DEFAULT '{}',
+    labels_hash CHAR(32) NOT NULL, -- Hash of the sorted labels, for the unique constraint
+    first_seen_at TIMESTAMP DEFAULT NOW(),
+    last_seen_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT unique_app_metric_series UNIQUE (deployment_id, metric_name, labels_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_metric_series_labels ON app_metric_series USING GIN (labels);
+CREATE INDEX IF NOT EXISTS idx_app_metric_series_last_seen ON app_metric_series(last_seen_at);
+
+CREATE TABLE IF NOT EXISTS app_metric_samples (
+    series_id BIGINT NOT NULL REFERENCES app_metric_series(id) ON DELETE CASCADE,
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    timestamp TIMESTAMP NOT NULL,
+    value DOUBLE PRECISION NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_metric_samples_series_time ON app_metric_samples(series_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_app_metric_samples_time ON app_metric_samples(deployment_id, timestamp);
+
+-- Outcome of the latest scrape of each deployment. Claiming a scrape bumps
+-- last_scrape_at, so replicas never scrape the same deployment twice.
+CREATE TABLE IF NOT EXISTS app_metrics_scrape_state (
+    deployment_id UUID PRIMARY KEY REFERENCES deployments(id) ON DELETE CASCADE,
+    last_scrape_at TIMESTAMP,
+    last_success_at TIMESTAMP,
+    last_error TEXT,
+    scrape_duration_ms INTEGER,
+    samples_scraped INTEGER DEFAULT 0,
+    series_count INTEGER DEFAULT 0,
+    dropped_series INTEGER DEFAULT 0 -- Series rejected by the cardinality limits in the latest scrape
+);
+
+COMMENT ON TABLE app_metric_series IS 'Series scraped from application /metrics endpoints';
+COMMENT ON TABLE app_metric_samples IS 'Samples of application metric series';
@@ -0,0 +1,69 @@
+-- ============================================================================
+-- ERROR ISSUES
+-- Error lines of deployment logs grouped by fingerprint: the error type and stack
+-- frames, or the message with IDs, numbers and paths stripped. A resolved issue seen
+-- again on a deployment created after it was resolved reopens as a regression.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS error_issues (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    fingerprint VARCHAR(32) NOT NULL,
+    title VARCHAR(255) NOT NULL, -- Normalized first line of the message
+    culprit VARCHAR(255), -- Topmost stack frame
+    level VARCHAR(20) NOT NULL DEFAULT 'error',
+    status VARCHAR(20) NOT NULL DEFAULT 'unresolved', -- 'unresolved', 'resolved', 'ignored'
+    sample_message TEXT NOT NULL, -- Latest occurrence
+    sample_stack TEXT,
+
+    event_count BIGINT NOT NULL DEFAULT 0,
+    first_seen TIMESTAMP NOT NULL,
+    last_seen TIMESTAMP NOT NULL,
+    first_deployment_id UUID REFERENCES deployments(id) ON DELETE SET NULL,
+    last_deployment_id UUID REFERENCES deployments(id) ON DELETE SET NULL,
+
+    resolved_at TIMESTAMP,
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    regressed_at TIMESTAMP,
+    regression_count INTEGER NOT NULL DEFAULT 0,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    UNIQUE (project_id, fingerprint),
+    CHECK (status IN ('unresolved', 'resolved', 'ignored'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_error_issues_project_seen ON error_issues(project_id, last_seen DESC);
+CREATE INDEX IF NOT EXISTS idx_error_issues_fingerprint ON error_issues(fingerprint);
+
+-- Occurrences of an issue per deployment, i.e. per release
+CREATE TABLE IF NOT EXISTS error_issue_deployments (
+    issue_id UUID NOT NULL REFERENCES error_issues(id) ON DELETE CASCADE,
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    first_seen TIMESTAMP NOT NULL,
+    last_seen TIMESTAMP NOT NULL,
+    event_count BIGINT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (issue_id, deployment_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_error_issue_deployments_deployment ON error_issue_deployments(deployment_id);
+
+-- Per-minute event counts behind issue_count() and issue_rate() in alert rules, kept
+-- for the longest alert window
+CREATE TABLE IF NOT EXISTS error_issue_counts (
+    issue_id UUID NOT NULL REFERENCES error_issues(id) ON DELETE CASCADE,
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    minute TIMESTAMP NOT NULL,
+    event_count BIGINT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (issue_id, deployment_id, minute)
+);
+
+CREATE INDEX IF NOT EXISTS idx_error_issue_counts_deployment ON error_issue_counts(deployment_id, minute);
+CREATE INDEX IF NOT EXISTS idx_error_issue_counts_minute ON error_issue_counts(minute);
+
+COMMENT ON TABLE error_issues IS 'Deployment error logs grouped by fingerprint, with first/last seen, count and regressions';
+COMMENT ON TABLE error_issue_deployments IS 'Occurrences of every error issue per deployment';
+COMMENT ON TABLE error_issue_counts IS 'Per-minute event counts of error issues for log-based alert rules';
@@ -51,7 +51,7 @@ CREATE INDEX IF NOT EXISTS idx_http_metrics_time_range
     ON http_metrics_minute(timestamp_minute) 
     WHERE timestamp_minute > NOW() - INTERVAL '30 days';
 
-COMMENT ON TABLE http_metrics_minute IS 'Minute-level HTTP metrics aggregates for dashboard visualizations. Retention: 30 days';
+COMMENT ON TABLE http_metrics_minute IS 'Minute-level HTTP metrics aggregates for dashboard visualizations. Retention: metrics_minute_retention_days of the plan, then rolled up (see metrics_rollups.sql)';
 COMMENT ON COLUMN http_metrics_minute.deployment_id IS 'Foreign key to deployments table';
 COMMENT ON COLUMN http_metrics_minute.timestamp_minute IS 'Start of the minute bucket (e.g., 10:30:00 for 10:30:00-10:30:59)';
 COMMENT ON COLUMN http_metrics_minute.request_count IS 'Total number of requests in this minute';
@@ -91,6 +91,9 @@ CREATE TABLE IF NOT EXISTS http_requests_sampled (
     -- Traefik metadata
     router_name VARCHAR(200),
     service_name VARCHAR(200),
+
+    -- W3C trace ID from the request's traceparent header, links to trace_spans
+    trace_id CHAR(32),
     
     created_at TIMESTAMP DEFAULT NOW()
 );
@@ -103,6 +106,10 @@ CREATE INDEX IF NOT EXISTS idx_http_requests_sampled_status
     ON http_requests_sampled(deployment_id, status_code) 
     WHERE status_code >= 400;
 
+CREATE INDEX IF NOT EXISTS idx_http_requests_sampled_trace 
+    ON http_requests_sampled(trace_id) 
+    WHERE trace_id IS NOT NULL;
+
 CREATE INDEX IF NOT EXISTS idx_http_requests_sampled_created 
     ON http_requests_sampled(created_at);
 
@@ -0,0 +1,60 @@
+CREATE TABLE IF NOT EXISTS incidents (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    deployment_id UUID REFERENCES deployments(id) ON DELETE SET NULL, -- Deployment of the first alert
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    severity VARCHAR(20) NOT NULL, -- Highest severity among the grouped alerts
+    status VARCHAR(20) NOT NULL DEFAULT 'open', -- 'open', 'acknowledged', 'mitigated', 'resolved'
+
+    started_at TIMESTAMP NOT NULL, -- First alert
+    detected_at TIMESTAMP DEFAULT NOW(), -- Incident opened by the correlator
+    acknowledged_at TIMESTAMP,
+    acknowledged_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    mitigated_at TIMESTAMP,
+    resolved_at TIMESTAMP,
+    resolved_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    last_alert_at TIMESTAMP NOT NULL,
+    alert_count INTEGER NOT NULL DEFAULT 0,
+
+    assigned_to UUID REFERENCES users(id) ON DELETE SET NULL,
+    root_cause TEXT,
+    resolution TEXT,
+    impact_summary TEXT,
+    postmortem JSONB, -- Completed postmortem (draft filled in by the AI agent or a user)
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    CHECK (status IN ('open', 'acknowledged', 'mitigated', 'resolved'))
+);
+
+CREATE INDEX idx_incidents_project ON incidents(project_id, started_at DESC);
+CREATE INDEX idx_incidents_active ON incidents(project_id, last_alert_at DESC) WHERE status <> 'resolved';
+CREATE INDEX idx_incidents_started_at ON incidents(started_at DESC);
+
+-- Alerts grouped into an incident
+CREATE TABLE IF NOT EXISTS incident_alerts (
+    incident_id UUID NOT NULL REFERENCES incidents(id) ON DELETE CASCADE,
+    alert_id UUID NOT NULL REFERENCES deployment_alerts(id) ON DELETE CASCADE,
+    attached_at TIMESTAMP DEFAULT NOW(),
+
+    PRIMARY KEY (incident_id, alert_id),
+    UNIQUE (alert_id)
+);
+
+-- Incident timeline; deploys and rollbacks in the incident window are merged in when read
+CREATE TABLE IF NOT EXISTS incident_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    incident_id UUID NOT NULL REFERENCES incidents(id) ON DELETE CASCADE,
+    event_type VARCHAR(30) NOT NULL, -- 'alert', 'alert_resolved', 'status_change', 'note', 'postmortem'
+    description TEXT NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    source_id UUID, -- e.g., the alert ID
+    occurred_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_incident_events_incident ON incident_events(incident_id, occurred_at);
+
+COMMENT ON TABLE incidents IS 'Related alerts grouped by the monitoring-service incident correlator';
+COMMENT ON COLUMN incidents.started_at IS 'MTTA and MTTR are measured from this timestamp';
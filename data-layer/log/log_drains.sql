@@ -0,0 +1,52 @@
+-- ============================================================================
+-- LOG DRAINS
+-- External sinks a project's deployment logs are forwarded to: syslog (RFC 5424 over
+-- TCP/TLS), HTTP(S) NDJSON, the Loki push API or an S3-compatible bucket. Batches the
+-- sink cannot take are buffered on the monitoring service's disk and replayed in order.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS log_drains (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    drain_type VARCHAR(20) NOT NULL, -- 'syslog', 'http', 'loki', 's3'
+    url VARCHAR(2048) NOT NULL, -- tcp:// or tls://host:port for syslog, the S3 endpoint for s3
+
+    -- HTTP and Loki: either basic auth or a bearer token; both optional
+    basic_auth_username VARCHAR(255),
+    basic_auth_password TEXT,
+    bearer_token TEXT,
+    headers JSONB DEFAULT '{}', -- e.g. X-Scope-OrgID for Loki tenants
+    labels JSONB DEFAULT '{}', -- Extra Loki stream labels
+    tls_ca_cert TEXT, -- PEM, trusted on top of the system roots
+
+    -- S3
+    s3_bucket VARCHAR(63),
+    s3_region VARCHAR(64),
+    s3_prefix VARCHAR(255),
+    s3_access_key_id VARCHAR(255),
+    s3_secret_access_key TEXT,
+
+    batch_size INTEGER NOT NULL DEFAULT 500,
+    flush_interval_seconds INTEGER NOT NULL DEFAULT 5,
+    enabled BOOLEAN DEFAULT true,
+
+    -- Delivery metrics, recorded by the monitoring service on every sync
+    delivered_count BIGINT NOT NULL DEFAULT 0,
+    dropped_count BIGINT NOT NULL DEFAULT 0, -- Buffer full or rejected by the sink
+    retry_count BIGINT NOT NULL DEFAULT 0, -- Failed delivery attempts
+    buffered_count INTEGER NOT NULL DEFAULT 0,
+    last_delivered_at TIMESTAMP,
+    last_error TEXT,
+    last_error_at TIMESTAMP,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    UNIQUE (project_id, name),
+    CHECK (drain_type IN ('syslog', 'http', 'loki', 's3')),
+    CHECK (batch_size BETWEEN 1 AND 10000),
+    CHECK (flush_interval_seconds BETWEEN 1 AND 3600)
+);
+
+COMMENT ON TABLE log_drains IS 'External sinks deployment logs are forwarded to, with their delivery metrics';
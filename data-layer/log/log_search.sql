@@ -0,0 +1,21 @@
+-- ============================================================================
+-- LOG SEARCH
+-- Indexes behind the log query language of the monitoring service (level:error AND
+-- message:"timeout" AND metadata.userId=42). Bare words use the 'simple' full-text
+-- index of the message; quoted phrases, wildcards and /regex/ use the trigram index.
+-- The expressions must match the ones the query compiler emits.
+-- ============================================================================
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_logs_archive_message_fts
+ON logs_archive USING GIN (to_tsvector('simple', message));
+
+CREATE INDEX IF NOT EXISTS idx_logs_archive_message_trgm
+ON logs_archive USING GIN (message gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_platform_logs_message_fts
+ON platform_log_events USING GIN (to_tsvector('simple', message));
+
+CREATE INDEX IF NOT EXISTS idx_platform_logs_message_trgm
+ON platform_log_events USING GIN (message gin_trgm_ops);
@@ -0,0 +1,82 @@
+-- ============================================================================
+-- METRICS ROLLUPS
+-- Downsampled container and HTTP metrics. Each tier is kept for the
+-- metrics_*_retention_days of the company's plan (see subscription_plans).
+--   container: deployments_metrics (raw samples) -> 1m, 1h (from raw) -> 1d (from 1h)
+--   http:      http_metrics_minute (1m)          -> 1h -> 1d
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS container_metrics_rollups (
+    id BIGSERIAL PRIMARY KEY,
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    resolution VARCHAR(3) NOT NULL, -- '1m', '1h', '1d'
+    bucket_start TIMESTAMP NOT NULL,
+    sample_count INTEGER NOT NULL,
+
+    cpu_avg DECIMAL(6, 2),
+    cpu_min DECIMAL(6, 2),
+    cpu_max DECIMAL(6, 2),
+    cpu_p95 DECIMAL(6, 2),
+
+    memory_avg BIGINT,
+    memory_min BIGINT,
+    memory_max BIGINT,
+    memory_p95 BIGINT,
+
+    -- Cumulative container counters at the end of the bucket
+    network_rx BIGINT,
+    network_tx BIGINT,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT unique_container_rollup UNIQUE (deployment_id, resolution, bucket_start),
+    CHECK (resolution IN ('1m', '1h', '1d'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_container_rollups_time ON container_metrics_rollups(resolution, bucket_start);
+
+CREATE TABLE IF NOT EXISTS http_metrics_rollups (
+    id BIGSERIAL PRIMARY KEY,
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    resolution VARCHAR(3) NOT NULL, -- '1h', '1d'; the 1m tier is http_metrics_minute
+    bucket_start TIMESTAMP NOT NULL,
+
+    request_count BIGINT NOT NULL DEFAULT 0,
+    request_count_2xx BIGINT DEFAULT 0,
+    request_count_3xx BIGINT DEFAULT 0,
+    request_count_4xx BIGINT DEFAULT 0,
+    request_count_5xx BIGINT DEFAULT 0,
+
+    latency_avg INTEGER, -- Weighted by request count
+    latency_min INTEGER,
+    latency_max INTEGER,
+    latency_p95 INTEGER, -- 95th percentile of the finer tier's p95 values (approximation)
+
+    requests_per_minute DECIMAL(10, 2),
+    error_rate DECIMAL(5, 4), -- Ratio of 5xx responses (0.0 to 1.0)
+
+    bytes_in BIGINT DEFAULT 0,
+    bytes_out BIGINT DEFAULT 0,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+
+    CONSTRAINT unique_http_rollup UNIQUE (deployment_id, resolution, bucket_start),
+    CHECK (resolution IN ('1h', '1d'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_http_rollups_time ON http_metrics_rollups(resolution, bucket_start);
+
+-- Everything before the watermark has been rolled up; retention never deletes source
+-- rows past the watermark of a tier built from them
+CREATE TABLE IF NOT EXISTS metrics_rollup_state (
+    name VARCHAR(50) PRIMARY KEY, -- e.g. 'container_1h'
+    watermark TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO metrics_rollup_state (name) VALUES
+    ('container_1m'), ('container_1h'), ('container_1d'), ('http_1h'), ('http_1d')
+ON CONFLICT (name) DO NOTHING;
+
+COMMENT ON TABLE container_metrics_rollups IS 'Downsampled container metrics (avg/min/max/p95) at 1m, 1h and 1d';
+COMMENT ON TABLE http_metrics_rollups IS 'Downsampled HTTP metrics at 1h and 1d';
@@ -6,6 +6,7 @@ CREATE TABLE IF NOT EXISTS logs_archive (
     level VARCHAR(50) NOT NULL DEFAULT 'info', -- 'info', 'warning', 'error', 'fatal', 'debug'
     message TEXT NOT NULL,
     source VARCHAR(100) NOT NULL DEFAULT 'container', -- 'container', 'build', 'system'
+    stream VARCHAR(10) NOT NULL DEFAULT 'stdout', -- 'stdout', 'stderr'
     metadata JSONB DEFAULT '{}',
     created_at TIMESTAMP DEFAULT NOW(),
     archived_to_minio BOOLEAN DEFAULT false, -- Track if log has been archived
@@ -29,6 +30,20 @@ COMMENT ON COLUMN logs_archive.message IS 'Log message content';
 COMMENT ON COLUMN logs_archive.source IS 'Source of the log: container, build, system';
 COMMENT ON COLUMN logs_archive.metadata IS 'Additional JSON metadata about the log entry';
 COMMENT ON COLUMN logs_archive.archived_to_minio IS 'Whether this log entry has been archived to MinIO';
+COMMENT ON COLUMN logs_archive.stream IS 'Container output stream the line was written to: stdout or stderr';
+
+-- Position of the log shipper in each container's log stream, so a restart of the
+-- monitoring service resumes where it stopped instead of re-reading or skipping lines
+CREATE TABLE IF NOT EXISTS log_cursors (
+    container_id VARCHAR(255) PRIMARY KEY,
+    deployment_id UUID NOT NULL REFERENCES deployments(id) ON DELETE CASCADE,
+    last_timestamp_ns BIGINT NOT NULL, -- Docker timestamp of the last shipped line, in Unix nanoseconds
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_log_cursors_deployment_id ON log_cursors(deployment_id);
+
+COMMENT ON TABLE log_cursors IS 'Last shipped position of every followed container log stream';
 
 -- ============================================================================
 -- MINIO BUCKET LIFECYCLE POLICY (run via MinIO CLI or API)
@@ -42,6 +57,10 @@ Or via MinIO Client SDK in the application.
 
 This ensures logs older than 90 days are automatically deleted from MinIO
 to control storage costs.
+
+The monitoring service now expires archives itself, per log_retention_policies and
+honoring log_legal_holds. A bucket-wide expiry rule would delete held logs, so it
+should only be set as a backstop longer than every warm retention.
 */
 
 CREATE TABLE IF NOT EXISTS uptime_records (
@@ -51,6 +70,7 @@ CREATE TABLE IF NOT EXISTS uptime_records (
     is_up BOOLEAN NOT NULL DEFAULT true,
     uptime_percentage DECIMAL(5, 2) NOT NULL DEFAULT 100.0,
     response_time_ms INTEGER,
+    in_maintenance BOOLEAN NOT NULL DEFAULT false, -- Downtime inside a maintenance window does not count against uptime
     created_at TIMESTAMP DEFAULT NOW()
 );
 
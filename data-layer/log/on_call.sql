@@ -0,0 +1,98 @@
+-- ============================================================================
+-- ON-CALL SCHEDULES AND ESCALATION POLICIES
+-- Teams rotate on-call duty through schedules. An alert on a project owned by a team
+-- pages the team's escalation policy: each level pages its targets and hands over to
+-- the next level when the alert stays unacknowledged past the level's timeout.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS oncall_schedules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    rotation_hours INTEGER NOT NULL DEFAULT 168, -- Shift length; 168 rotates weekly
+    rotation_start TIMESTAMP NOT NULL, -- Handoff anchor: the first member's first shift starts here
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    UNIQUE (team_id, name),
+    CHECK (rotation_hours BETWEEN 1 AND 2016)
+);
+
+-- Rotation order of a schedule
+CREATE TABLE IF NOT EXISTS oncall_schedule_members (
+    schedule_id UUID NOT NULL REFERENCES oncall_schedules(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+
+    PRIMARY KEY (schedule_id, position),
+    UNIQUE (schedule_id, user_id)
+);
+
+-- Takes over a schedule for a period, e.g. to swap shifts or cover a holiday
+CREATE TABLE IF NOT EXISTS oncall_overrides (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    schedule_id UUID NOT NULL REFERENCES oncall_schedules(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    reason TEXT,
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_oncall_overrides_schedule ON oncall_overrides(schedule_id, ends_at);
+
+CREATE TABLE IF NOT EXISTS escalation_policies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    is_default BOOLEAN NOT NULL DEFAULT false, -- Pages for the alerts of the team's projects
+    min_severity VARCHAR(20) NOT NULL DEFAULT 'warning', -- Less severe alerts only go to their channels
+    repeat_count INTEGER NOT NULL DEFAULT 0, -- Times to restart from the first level once the last one timed out
+
+    -- Ordered levels: [{"timeout_minutes": 15, "targets": [{"type": "schedule", "id": "..."}, {"type": "user", "id": "..."}]}]
+    levels JSONB NOT NULL DEFAULT '[]',
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    UNIQUE (team_id, name),
+    CHECK (repeat_count BETWEEN 0 AND 5)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_escalation_policies_default ON escalation_policies(team_id) WHERE is_default;
+
+-- Escalation of one alert through its policy
+CREATE TABLE IF NOT EXISTS alert_escalations (
+    alert_id UUID PRIMARY KEY REFERENCES deployment_alerts(id) ON DELETE CASCADE,
+    policy_id UUID REFERENCES escalation_policies(id) ON DELETE SET NULL,
+    level INTEGER NOT NULL DEFAULT 0, -- Index of the level paged last
+    cycle INTEGER NOT NULL DEFAULT 0, -- Repeats of the policy so far
+    next_escalation_at TIMESTAMP, -- NULL once stopped
+    stopped_at TIMESTAMP,
+    stop_reason VARCHAR(20), -- 'acknowledged', 'resolved', 'exhausted', 'policy_removed'
+    started_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_escalations_due ON alert_escalations(next_escalation_at) WHERE stopped_at IS NULL;
+
+-- Who was paged for an alert, and when
+CREATE TABLE IF NOT EXISTS alert_pages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    alert_id UUID NOT NULL REFERENCES deployment_alerts(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    level INTEGER NOT NULL,
+    cycle INTEGER NOT NULL DEFAULT 0,
+    schedule_id UUID REFERENCES oncall_schedules(id) ON DELETE SET NULL, -- Set when paged as the schedule's on-call
+    paged_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_pages_alert ON alert_pages(alert_id, paged_at);
+
+COMMENT ON TABLE oncall_schedules IS 'Team on-call rotations; the member on duty is derived from rotation_start and rotation_hours';
+COMMENT ON TABLE escalation_policies IS 'Who gets paged for a team''s alerts, and when to escalate unacknowledged ones';
+COMMENT ON TABLE alert_escalations IS 'Escalation progress of an alert; acknowledging or resolving the alert stops it';
@@ -100,19 +100,31 @@ FROM platform_log_events
 WHERE event_timestamp > NOW() - INTERVAL '30 days'
 GROUP BY project_id, event_type, severity, DATE(event_timestamp);
 
+-- A policy applies to every project, to the projects of a subscription plan, or to
+-- one project; the most specific one wins. The 'container' policy also covers
+-- deployment logs in logs_archive.
 CREATE TABLE IF NOT EXISTS log_retention_policies (
     id SERIAL PRIMARY KEY,
     event_type VARCHAR(50) NOT NULL,
+    plan_id VARCHAR(50) REFERENCES subscription_plans(id) ON DELETE CASCADE, -- Plan override
+    project_id UUID REFERENCES projects(id) ON DELETE CASCADE, -- Project override
     hot_retention_hours INTEGER DEFAULT 24, -- Keep in DB
     warm_retention_days INTEGER DEFAULT 90, -- Keep in MinIO
     cold_retention_days INTEGER DEFAULT 365, -- Keep in cold storage (or delete)
-    archive_enabled BOOLEAN DEFAULT true,
+    archive_enabled BOOLEAN DEFAULT true, -- When false, logs past hot retention are dropped
     created_at TIMESTAMP DEFAULT NOW(),
     updated_at TIMESTAMP DEFAULT NOW(),
-    
-    UNIQUE (event_type)
+
+    CHECK (plan_id IS NULL OR project_id IS NULL)
 );
 
+CREATE UNIQUE INDEX idx_log_retention_default ON log_retention_policies(event_type)
+WHERE plan_id IS NULL AND project_id IS NULL;
+CREATE UNIQUE INDEX idx_log_retention_plan ON log_retention_policies(event_type, plan_id)
+WHERE plan_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_log_retention_project ON log_retention_policies(event_type, project_id)
+WHERE project_id IS NOT NULL;
+
 -- Insert default policies
 INSERT INTO log_retention_policies (event_type, hot_retention_hours, warm_retention_days, cold_retention_days) VALUES
     ('build', 24, 90, 365),
@@ -120,7 +132,23 @@ INSERT INTO log_retention_policies (event_type, hot_retention_hours, warm_retent
     ('container', 6, 30, 90), -- Container logs expire faster
     ('system', 6, 30, 90),
     ('security', 168, 365, 2555) -- Security logs kept longer (7 years)
-ON CONFLICT (event_type) DO NOTHING;
+ON CONFLICT (event_type) WHERE plan_id IS NULL AND project_id IS NULL DO NOTHING;
+
+-- Legal holds exempt a project's logs, or one deployment's, from deletion. Held logs
+-- still move from the DB to MinIO but are never dropped or expired there.
+CREATE TABLE IF NOT EXISTS log_legal_holds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    deployment_id UUID REFERENCES deployments(id) ON DELETE CASCADE, -- NULL holds the whole project
+    reason TEXT NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    expires_at TIMESTAMP, -- NULL holds until released
+    released_at TIMESTAMP
+);
+
+CREATE INDEX idx_log_legal_holds_project ON log_legal_holds(project_id) WHERE released_at IS NULL;
+CREATE INDEX idx_log_legal_holds_deployment ON log_legal_holds(deployment_id) WHERE released_at IS NULL;
 
 -- ============================================================================
 -- REAL-TIME LOG STREAMING (Redis channel tracking)
@@ -148,8 +176,9 @@ CREATE TABLE IF NOT EXISTS log_archival_jobs (
     event_type VARCHAR(50),
     records_processed INTEGER DEFAULT 0,
     records_archived INTEGER DEFAULT 0,
-    records_deleted INTEGER DEFAULT 0,
+    records_deleted INTEGER DEFAULT 0, -- Dropped without an archive copy
     minio_objects_created INTEGER DEFAULT 0,
+    minio_objects_deleted INTEGER DEFAULT 0, -- Archives past their warm retention
     status VARCHAR(50) DEFAULT 'running', -- 'running', 'completed', 'failed'
     error_message TEXT
 );
@@ -170,7 +199,7 @@ BEGIN
     -- Get retention policy
     SELECT hot_retention_hours INTO v_hot_hours
     FROM log_retention_policies
-    WHERE event_type = p_event_type;
+    WHERE event_type = p_event_type AND plan_id IS NULL AND project_id IS NULL;
     
     IF v_hot_hours IS NULL THEN
         v_hot_hours := 24;
@@ -0,0 +1,43 @@
+-- Tokens a Prometheus server presents (Authorization: Bearer) to scrape a project's /metrics.
+-- Only the SHA-256 of the token is stored; the token itself is shown once on creation.
+CREATE TABLE IF NOT EXISTS metrics_scrape_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    token_prefix VARCHAR(16) NOT NULL, -- First characters, to tell tokens apart in the UI
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    revoked_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_metrics_scrape_tokens_name ON metrics_scrape_tokens(project_id, name) WHERE revoked_at IS NULL;
+
+-- Prometheus remote-write endpoints the project's metrics are pushed to
+CREATE TABLE IF NOT EXISTS metrics_remote_write_targets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+
+    -- Either basic auth or a bearer token; both optional
+    basic_auth_username VARCHAR(255),
+    basic_auth_password TEXT,
+    bearer_token TEXT,
+    headers JSONB DEFAULT '{}', -- e.g. X-Scope-OrgID for Mimir/Cortex tenants
+
+    interval_seconds INTEGER NOT NULL DEFAULT 30,
+    enabled BOOLEAN DEFAULT true,
+
+    last_push_at TIMESTAMP,
+    last_success_at TIMESTAMP,
+    last_error TEXT,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    UNIQUE (project_id, name),
+    CHECK (interval_seconds >= 15)
+);
+
+CREATE INDEX idx_metrics_remote_write_due ON metrics_remote_write_targets(last_push_at) WHERE enabled = true;
@@ -0,0 +1,91 @@
+-- Synthetic HTTP probes sent through Traefik against a project's public route
+CREATE TABLE IF NOT EXISTS synthetic_probes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    environment VARCHAR(50) NOT NULL DEFAULT 'production', -- 'production', 'staging', 'preview'
+    name VARCHAR(100) NOT NULL,
+
+    -- Request
+    method VARCHAR(10) NOT NULL DEFAULT 'GET',
+    scheme VARCHAR(5) NOT NULL DEFAULT 'https',
+    host VARCHAR(255), -- Defaults to the active deployment's domain; set to probe a custom domain
+    path VARCHAR(1024) NOT NULL DEFAULT '/',
+    request_headers JSONB DEFAULT '{}',
+    request_body TEXT,
+
+    -- Assertions
+    expected_status INTEGER, -- NULL accepts any 2xx/3xx
+    body_match TEXT, -- Substring (or regular expression when body_match_regex) the body must contain
+    body_match_regex BOOLEAN DEFAULT false,
+    tls_expiry_warn_days INTEGER DEFAULT 14, -- Alert when the served certificate expires sooner; 0 disables
+
+    timeout_ms INTEGER NOT NULL DEFAULT 10000,
+    interval_seconds INTEGER NOT NULL DEFAULT 60,
+    enabled BOOLEAN DEFAULT true,
+    last_run_at TIMESTAMP,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    UNIQUE (project_id, name),
+    CHECK (scheme IN ('http', 'https')),
+    CHECK (interval_seconds >= 10),
+    CHECK (timeout_ms BETWEEN 100 AND 60000)
+);
+
+CREATE INDEX idx_synthetic_probes_due ON synthetic_probes(last_run_at) WHERE enabled = true;
+
+CREATE TABLE IF NOT EXISTS synthetic_probe_results (
+    id BIGSERIAL PRIMARY KEY,
+    probe_id UUID NOT NULL REFERENCES synthetic_probes(id) ON DELETE CASCADE,
+    deployment_id UUID REFERENCES deployments(id) ON DELETE SET NULL, -- Deployment serving the route at check time
+    success BOOLEAN NOT NULL,
+    status_code INTEGER,
+    latency_ms INTEGER,
+    error_message TEXT,
+    tls_expires_at TIMESTAMP,
+    in_maintenance BOOLEAN NOT NULL DEFAULT false, -- Checked inside a maintenance window; ignored by SLOs
+    checked_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_synthetic_probe_results_probe ON synthetic_probe_results(probe_id, checked_at DESC);
+CREATE INDEX idx_synthetic_probe_results_deployment ON synthetic_probe_results(deployment_id, checked_at DESC);
+
+-- Service level objectives measured on probe results
+CREATE TABLE IF NOT EXISTS slo_definitions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    environment VARCHAR(50) NOT NULL DEFAULT 'production',
+    name VARCHAR(100) NOT NULL,
+    slo_type VARCHAR(20) NOT NULL, -- 'availability', 'latency'
+    target DECIMAL(6, 3) NOT NULL, -- Percentage of good checks, e.g. 99.9
+    latency_threshold_ms INTEGER, -- Latency SLOs: checks slower than this are bad
+    window_days INTEGER NOT NULL DEFAULT 30,
+    probe_id UUID REFERENCES synthetic_probes(id) ON DELETE CASCADE, -- NULL measures every probe of the environment
+    enabled BOOLEAN DEFAULT true,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    UNIQUE (project_id, name),
+    CHECK (slo_type IN ('availability', 'latency')),
+    CHECK (target > 0 AND target < 100),
+    CHECK (window_days IN (28, 30)),
+    CHECK (slo_type <> 'latency' OR latency_threshold_ms > 0)
+);
+
+CREATE INDEX idx_slo_definitions_project ON slo_definitions(project_id);
+
+-- Multi-window burn-rate alerts currently firing for an SLO
+CREATE TABLE IF NOT EXISTS slo_burn_alerts (
+    slo_id UUID NOT NULL REFERENCES slo_definitions(id) ON DELETE CASCADE,
+    burn_window VARCHAR(20) NOT NULL, -- e.g. '1h/5m'
+    alert_id UUID NOT NULL REFERENCES deployment_alerts(id) ON DELETE CASCADE,
+    burn_rate DECIMAL(10, 3),
+    fired_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (slo_id, burn_window)
+);
+
+COMMENT ON TABLE synthetic_probes IS 'Uptime is measured from these probes; the container state is only a fallback';
+COMMENT ON COLUMN slo_definitions.target IS 'The error budget is 100 - target percent of checks over window_days';
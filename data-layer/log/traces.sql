@@ -0,0 +1,61 @@
+-- ============================================================================
+-- OTLP INGESTION AND TRACES
+-- Apps push traces, logs and metrics over OTLP (HTTP or gRPC) with a project ingest
+-- token. Logs land in logs_archive and metrics in app_metric_samples; spans are kept
+-- here for 7 days. Sampled Traefik requests carry the trace ID of their traceparent
+-- header (http_requests_sampled.trace_id).
+-- ============================================================================
+
+-- Tokens an app's OTLP exporter authenticates with (Authorization: Bearer). Only the
+-- SHA-256 of the token is stored; token_prefix identifies it in the dashboard.
+CREATE TABLE IF NOT EXISTS otlp_ingest_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    token_prefix VARCHAR(16) NOT NULL,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    revoked_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_otlp_ingest_tokens_name
+    ON otlp_ingest_tokens(project_id, name)
+    WHERE revoked_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS trace_spans (
+    id BIGSERIAL PRIMARY KEY,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    deployment_id UUID REFERENCES deployments(id) ON DELETE CASCADE, -- NULL when the resource matched no deployment
+    trace_id CHAR(32) NOT NULL, -- Lowercase hex
+    span_id CHAR(16) NOT NULL,
+    parent_span_id VARCHAR(16) NOT NULL DEFAULT '', -- '' for root spans
+    service_name VARCHAR(200) NOT NULL DEFAULT '',
+    name VARCHAR(500) NOT NULL,
+    kind VARCHAR(10) NOT NULL DEFAULT 'internal', -- 'internal', 'server', 'client', 'producer', 'consumer'
+    start_time TIMESTAMP NOT NULL, -- UTC
+    duration_us BIGINT NOT NULL,
+    status_code VARCHAR(10) NOT NULL DEFAULT 'unset', -- 'unset', 'ok', 'error'
+    status_message TEXT,
+
+    -- From the HTTP semantic convention attributes, for matching Traefik requests
+    http_method VARCHAR(10),
+    http_route VARCHAR(500),
+    http_path VARCHAR(500),
+    http_status_code SMALLINT,
+
+    attributes JSONB DEFAULT '{}',
+    resource JSONB DEFAULT '{}',
+    events JSONB DEFAULT '[]',
+    created_at TIMESTAMP DEFAULT NOW(),
+
+    UNIQUE (project_id, trace_id, span_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trace_spans_project_time ON trace_spans(project_id, start_time DESC);
+CREATE INDEX IF NOT EXISTS idx_trace_spans_deployment_time ON trace_spans(deployment_id, start_time DESC)
+    WHERE kind = 'server';
+CREATE INDEX IF NOT EXISTS idx_trace_spans_created ON trace_spans(created_at);
+
+COMMENT ON TABLE otlp_ingest_tokens IS 'Tokens apps authenticate OTLP exports with';
+COMMENT ON TABLE trace_spans IS 'Spans apps exported over OTLP. Retention: 7 days';
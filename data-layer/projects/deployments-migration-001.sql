@@ -0,0 +1,30 @@
+-- Migration 001: Health check columns fall back to the shared/healthcheck defaults
+-- Apply this against the live obtura_db database.
+
+-- ─────────────────────────────────────────────────────────────────────────────
+-- 1. deployments: drop the '/health' and 30s column defaults
+-- ─────────────────────────────────────────────────────────────────────────────
+ALTER TABLE deployments
+    ALTER COLUMN health_check_path DROP DEFAULT,
+    ALTER COLUMN health_check_interval_seconds DROP DEFAULT,
+    ALTER COLUMN health_check_timeout_seconds DROP DEFAULT,
+    ALTER COLUMN health_check_start_period_seconds DROP DEFAULT,
+    ALTER COLUMN health_check_retries DROP DEFAULT;
+
+-- ─────────────────────────────────────────────────────────────────────────────
+-- 2. deployments: clear values the deploy-service never wrote
+-- ─────────────────────────────────────────────────────────────────────────────
+-- Rows still holding exactly the old column defaults were never resolved by the
+-- deploy-service (it writes a 10s interval unless configured otherwise)
+UPDATE deployments
+SET health_check_path = NULL,
+    health_check_interval_seconds = NULL,
+    health_check_timeout_seconds = NULL,
+    health_check_start_period_seconds = NULL,
+    health_check_retries = NULL
+WHERE health_check_path = '/health'
+  AND health_check_interval_seconds = 30
+  AND health_check_timeout_seconds = 5
+  AND health_check_start_period_seconds = 40
+  AND health_check_retries = 3
+  AND COALESCE(health_check_expected_status, 0) = 0;
@@ -28,10 +28,14 @@ CREATE TABLE deployments (
     cpu_limit VARCHAR(20), -- e.g., '1000m' (1 CPU core)
     memory_limit VARCHAR(20), -- e.g., '512Mi', '2Gi'
     
-    -- Health check configuration
-    health_check_path VARCHAR(255) DEFAULT '/health',
-    health_check_interval_seconds INTEGER DEFAULT 30,
-    health_check_timeout_seconds INTEGER DEFAULT 5,
+    -- Health check configuration, written by the deploy-service when it resolves the spec.
+    -- NULL until then: readers fall back to the shared/healthcheck defaults.
+    health_check_path VARCHAR(255),
+    health_check_interval_seconds INTEGER,
+    health_check_timeout_seconds INTEGER,
+    health_check_expected_status INTEGER DEFAULT 0, -- 0 accepts any 2xx/3xx response
+    health_check_start_period_seconds INTEGER,
+    health_check_retries INTEGER, -- Consecutive failures before the deployment is reported unhealthy
     
     -- Environment variables and secrets
     env_vars JSONB DEFAULT '{}', -- Non-sensitive environment variables
@@ -51,7 +55,7 @@ CREATE TABLE deployments (
     
     -- Deployment metadata
     deployed_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
-    deployment_trigger VARCHAR(50), -- 'manual', 'auto_push', 'auto_merge', 'scheduled', 'rollback'
+    deployment_trigger VARCHAR(50), -- 'manual', 'auto_push', 'auto_merge', 'scheduled', 'rollback', 'pull_request'
     error_message TEXT,
     approval_required BOOLEAN DEFAULT false,
     approved_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
@@ -238,6 +242,19 @@ CREATE TABLE deployment_alerts (
     acknowledged_at TIMESTAMP,
     acknowledged_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
     notified_users JSONB DEFAULT '[]', -- Array of user IDs notified
+
+    -- Deduplication: a condition that fires again while its alert is unresolved bumps the
+    -- existing alert instead of creating a new one
+    fingerprint CHAR(64), -- sha256 of deployment, alert type and the source's identity (rule, SLO window, host)
+    occurrence_count INTEGER NOT NULL DEFAULT 1,
+    last_seen_at TIMESTAMP DEFAULT NOW(),
+
+    -- Notifications
+    last_notified_at TIMESTAMP,
+    notification_count INTEGER NOT NULL DEFAULT 0,
+    renotify_interval_seconds INTEGER NOT NULL DEFAULT 0, -- Repeat while unresolved and unacknowledged; 0 never repeats
+    suppressed_by VARCHAR(100), -- 'silence:<id>' or 'maintenance:<id>' matching when the alert fired
+
     created_at TIMESTAMP DEFAULT NOW()
 );
 
@@ -248,6 +265,7 @@ CREATE INDEX idx_deployment_alerts_project_unresolved ON deployment_alerts(proje
 CREATE INDEX idx_deployment_alerts_severity ON deployment_alerts(severity);
 CREATE INDEX idx_deployment_alerts_type ON deployment_alerts(alert_type);
 CREATE INDEX idx_deployment_alerts_created_at ON deployment_alerts(created_at DESC);
+CREATE UNIQUE INDEX idx_deployment_alerts_fingerprint ON deployment_alerts(fingerprint) WHERE resolved = false;
 
 
 
@@ -0,0 +1,59 @@
+-- Custom domains attached to a project environment
+CREATE TABLE project_domains (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    environment VARCHAR(50) NOT NULL DEFAULT 'production', -- 'production', 'staging', 'preview'
+
+    -- Domain identification
+    domain VARCHAR(255) NOT NULL, -- e.g., 'app.example.com' or 'example.com' for wildcards
+    is_wildcard BOOLEAN DEFAULT false, -- Also serves '*.<domain>' (requires DNS verification and a DNS-01 resolver)
+
+    -- Ownership verification
+    verification_method VARCHAR(20) NOT NULL DEFAULT 'dns_txt', -- 'dns_txt', 'http'
+    verification_token VARCHAR(128) NOT NULL,
+    verification_status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'verified', 'failed'
+    verification_error TEXT,
+    verification_attempts INTEGER DEFAULT 0,
+    last_verification_at TIMESTAMP,
+    verified_at TIMESTAMP,
+    verification_expires_at TIMESTAMP DEFAULT NOW() + INTERVAL '7 days', -- Unverified claims are deleted after this
+
+    -- TLS certificate tracking
+    certificate_status VARCHAR(20) NOT NULL DEFAULT 'none', -- 'none', 'pending', 'issued', 'expiring', 'failed'
+    certificate_resolver VARCHAR(100), -- Traefik cert resolver used to request the certificate
+    certificate_issuer VARCHAR(255),
+    certificate_not_before TIMESTAMP,
+    certificate_expires_at TIMESTAMP,
+    certificate_error TEXT,
+    certificate_checked_at TIMESTAMP,
+
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    UNIQUE (project_id, domain),
+    CHECK (verification_method IN ('dns_txt', 'http')),
+    CHECK (verification_status IN ('pending', 'verified', 'failed')),
+    CHECK (certificate_status IN ('none', 'pending', 'issued', 'expiring', 'failed')),
+    CHECK (NOT is_wildcard OR verification_method = 'dns_txt')
+);
+
+-- Any project may claim a domain; only one can verify it
+CREATE UNIQUE INDEX idx_project_domains_verified_domain ON project_domains(domain) WHERE verification_status = 'verified';
+CREATE INDEX idx_project_domains_project ON project_domains(project_id, environment);
+CREATE INDEX idx_project_domains_token ON project_domains(verification_token);
+CREATE INDEX idx_project_domains_verified ON project_domains(project_id, environment) WHERE verification_status = 'verified';
+CREATE INDEX idx_project_domains_expiry ON project_domains(verification_expires_at) WHERE verification_status <> 'verified';
+CREATE INDEX idx_project_domains_cert_check ON project_domains(certificate_checked_at) WHERE verification_status = 'verified';
+
+-- Traefik middleware chain per project environment (rateLimit, basicAuth, ipAllowList, headers, compress, redirectRegex)
+CREATE TABLE project_route_middlewares (
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    environment VARCHAR(50) NOT NULL DEFAULT 'production',
+    config JSONB NOT NULL DEFAULT '{}', -- basicAuth users are stored as htpasswd hashes only
+    updated_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    PRIMARY KEY (project_id, environment)
+);
@@ -0,0 +1,64 @@
+-- One-off and scheduled (cron) jobs that run a project's deployed image with a custom command
+CREATE TABLE project_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    environment VARCHAR(50) NOT NULL DEFAULT 'production', -- Image and env vars come from this environment's active deployment
+    name VARCHAR(100) NOT NULL,
+    job_type VARCHAR(10) NOT NULL DEFAULT 'job', -- 'job' (run on demand), 'cron' (scheduled)
+
+    command JSONB NOT NULL DEFAULT '[]', -- e.g., ["node", "scripts/nightly-report.js"]; empty uses the image CMD
+    schedule VARCHAR(100), -- Cron expression, required for 'cron'
+    timezone VARCHAR(64) DEFAULT 'UTC',
+    concurrency_policy VARCHAR(10) NOT NULL DEFAULT 'forbid', -- 'allow', 'forbid' (skip while running), 'replace' (stop the running one)
+    timeout_seconds INTEGER NOT NULL DEFAULT 1800,
+    enabled BOOLEAN DEFAULT true,
+
+    next_run_at TIMESTAMP,
+    last_run_at TIMESTAMP,
+
+    created_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    UNIQUE (project_id, environment, name),
+    CHECK (job_type IN ('job', 'cron')),
+    CHECK (job_type = 'job' OR schedule IS NOT NULL),
+    CHECK (concurrency_policy IN ('allow', 'forbid', 'replace')),
+    CHECK (timeout_seconds > 0)
+);
+
+CREATE INDEX idx_project_jobs_project ON project_jobs(project_id);
+CREATE INDEX idx_project_jobs_due ON project_jobs(next_run_at) WHERE job_type = 'cron' AND enabled = true;
+
+-- Run history per job
+CREATE TABLE project_job_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_id UUID NOT NULL REFERENCES project_jobs(id) ON DELETE CASCADE,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    deployment_id UUID REFERENCES deployments(id) ON DELETE SET NULL, -- Deployment whose image was run
+
+    trigger VARCHAR(20) NOT NULL, -- 'manual', 'schedule'
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'running', 'succeeded', 'failed', 'timed_out', 'cancelled', 'skipped'
+
+    image VARCHAR(500),
+    command JSONB,
+    container_id VARCHAR(255),
+    container_name VARCHAR(255),
+
+    exit_code INTEGER,
+    error_message TEXT,
+    logs TEXT, -- Tail of stdout/stderr, captured when the run finishes
+    logs_truncated BOOLEAN DEFAULT false,
+
+    scheduled_for TIMESTAMP,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    duration_ms BIGINT,
+    created_at TIMESTAMP DEFAULT NOW(),
+
+    CHECK (trigger IN ('manual', 'schedule')),
+    CHECK (status IN ('pending', 'running', 'succeeded', 'failed', 'timed_out', 'cancelled', 'skipped'))
+);
+
+CREATE INDEX idx_project_job_runs_job ON project_job_runs(job_id, created_at DESC);
+CREATE INDEX idx_project_job_runs_active ON project_job_runs(project_id) WHERE status IN ('pending', 'running');
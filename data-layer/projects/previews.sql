@@ -0,0 +1,50 @@
+-- Preview environments: one per open pull request (redeployed on every push) or per manually previewed branch
+CREATE TABLE preview_environments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+
+    -- Pull request
+    pull_request_number INTEGER, -- NULL for manual branch previews
+    pull_request_url TEXT,
+    pull_request_title TEXT,
+    head_branch VARCHAR(255) NOT NULL,
+    head_commit VARCHAR(40),
+
+    -- Routing
+    subdomain VARCHAR(63) NOT NULL, -- e.g., 'pr-42', also used to scope containers
+    domain VARCHAR(255), -- e.g., 'pr-42-myapp-1a2b3c.s3rbvn.org', assigned by the deploy-service
+
+    deployment_id UUID REFERENCES deployments(id) ON DELETE SET NULL, -- Latest deployment for this PR
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'deploying', 'active', 'failed', 'terminating', 'terminated'
+
+    -- Teardown
+    expires_at TIMESTAMP, -- TTL, pushed forward on every deploy
+    closed_at TIMESTAMP, -- PR closed or merged
+    terminated_at TIMESTAMP,
+    termination_reason VARCHAR(20), -- 'pr_closed', 'ttl_expired', 'manual'
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    UNIQUE (project_id, pull_request_number),
+    UNIQUE (project_id, subdomain),
+    UNIQUE (domain),
+    CHECK (status IN ('pending', 'deploying', 'active', 'failed', 'terminating', 'terminated')),
+    CHECK (termination_reason IS NULL OR termination_reason IN ('pr_closed', 'ttl_expired', 'manual'))
+);
+
+CREATE INDEX idx_preview_environments_project ON preview_environments(project_id, status);
+CREATE INDEX idx_preview_environments_expiry ON preview_environments(expires_at) WHERE status NOT IN ('terminating', 'terminated');
+
+-- Per-project preview settings
+CREATE TABLE project_preview_settings (
+    project_id UUID PRIMARY KEY REFERENCES projects(id) ON DELETE CASCADE,
+    enabled BOOLEAN DEFAULT true,
+    ttl_hours INTEGER DEFAULT 168, -- Preview is torn down this long after its last deploy
+    env_overrides JSONB DEFAULT '{}', -- Applied on top of the variables cloned from staging; null values remove a variable
+    updated_by_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+
+    CHECK (ttl_hours > 0)
+);
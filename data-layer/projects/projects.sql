@@ -139,6 +139,10 @@ CREATE TABLE IF NOT EXISTS project_settings (
     rate_limiting_burst_period_seconds INTEGER DEFAULT 0,
     perform_health_checks BOOLEAN DEFAULT false,
     health_check_url VARCHAR(255),
+    app_metrics_enabled BOOLEAN DEFAULT false, -- Scrape the app's Prometheus endpoint
+    app_metrics_path VARCHAR(255) DEFAULT '/metrics',
+    app_metrics_port INTEGER, -- NULL scrapes the app port
+    app_metrics_interval_seconds INTEGER DEFAULT 30,
     build_cache_enabled BOOLEAN DEFAULT false,
     parallel_builds BOOLEAN DEFAULT false,
     build_optimization_enabled BOOLEAN DEFAULT false,
@@ -30,6 +30,8 @@ CREATE TABLE subscription_plans (
     max_environments_per_project INTEGER NOT NULL DEFAULT 3, -- prod/staging/preview
     max_preview_environments INTEGER, -- NULL = unlimited
     rollback_retention_count INTEGER NOT NULL DEFAULT 10,
+    max_concurrent_job_runs INTEGER, -- NULL = unlimited; one-off and cron job containers
+    max_scheduled_jobs_per_project INTEGER, -- NULL = unlimited
     
     -- Runtime Resources
     cpu_cores_per_deployment DECIMAL(3,1) NOT NULL,
@@ -41,6 +43,11 @@ CREATE TABLE subscription_plans (
     max_database_storage_gb INTEGER, -- NULL = unlimited
     max_logs_retention_days INTEGER NOT NULL,
     max_backup_retention_days INTEGER NOT NULL,
+    metrics_raw_retention_days INTEGER NOT NULL DEFAULT 1, -- Raw container samples
+    metrics_minute_retention_days INTEGER NOT NULL DEFAULT 7, -- 1m rollups and http_metrics_minute
+    metrics_hourly_retention_days INTEGER NOT NULL DEFAULT 90,
+    metrics_daily_retention_days INTEGER NOT NULL DEFAULT 365,
+    max_app_metric_series INTEGER NOT NULL DEFAULT 500, -- Scraped application series per deployment
     
     -- Traffic & Bandwidth
     bandwidth_gb_per_month INTEGER, -- NULL = unlimited
@@ -291,9 +298,10 @@ CREATE TRIGGER log_subscription_changes_trigger
         max_builds_per_hour, max_builds_per_day, max_builds_per_month, max_concurrent_builds,
         max_build_duration_minutes, max_build_size_mb,
         cpu_cores_per_build, memory_gb_per_build,
-        max_deployments_per_month, max_concurrent_deployments, max_environments_per_project, max_preview_environments, rollback_retention_count,
+        max_deployments_per_month, max_concurrent_deployments, max_environments_per_project, max_preview_environments, rollback_retention_count, max_concurrent_job_runs, max_scheduled_jobs_per_project,
         cpu_cores_per_deployment, memory_gb_per_deployment,
         storage_gb, max_build_artifacts_gb, max_database_storage_gb, max_logs_retention_days, max_backup_retention_days,
+        metrics_raw_retention_days, metrics_minute_retention_days, metrics_hourly_retention_days, metrics_daily_retention_days, max_app_metric_series,
         bandwidth_gb_per_month, requests_per_minute, ddos_protection_enabled,
         max_webhooks_per_project, max_api_keys_per_project, max_custom_domains,
         ssl_certificates_included, advanced_analytics_enabled, audit_logs_enabled, audit_logs_retention_days,
@@ -307,9 +315,10 @@ CREATE TRIGGER log_subscription_changes_trigger
         5, 20, 100, 1,
         10, 100,
         1.0, 2,
-        100, 1, 3, 5, 10,
+        100, 1, 3, 5, 10, 2, 3,
         0.5, 1,
         10, 5, 5, 7, 30,
+        1, 7, 90, 365, 500,
         50, 100, false,
         3, 2, 0,
         true, false, false, null,
@@ -323,9 +332,10 @@ CREATE TRIGGER log_subscription_changes_trigger
         15, 20, 100, 3,
         30, 500,
         4.0, 8,
-        500, 3, 5, 20, 30,
+        500, 3, 5, 20, 30, 5, 10,
         1.0, 2,
         50, 30, 20, 30, 60,
+        3, 14, 180, 730, 2000,
         500, 500, true,
         10, 5, 5,
         true, true, true, 30,
@@ -339,9 +349,10 @@ CREATE TRIGGER log_subscription_changes_trigger
         30, 100, 1000, 10,
         60, 2000,
         8.0, 16,
-        1000, 10, 50, 60, 60,
+        1000, 10, 50, 60, 60, 20, 50,
         2.0, 4,
         3072, 100, 100, 90, 90,
+        7, 30, 395, 1095, 10000,
         1000, 1000, true,
         50, 20, null,
         true, true, true, 90,
@@ -355,9 +366,10 @@ CREATE TRIGGER log_subscription_changes_trigger
         20, 120, 5000, 16,
         null, 5000,
         16.0, 32,
-        null, 10, 4, 100, 100,
+        null, 10, 4, 100, 100, null, null,
         4.0, 8,
         5120, 500, 500, 365, 365,
+        7, 30, 730, 1825, 50000,
         null, 2000, true,
         30, null, null,
         true, true, true, 365,
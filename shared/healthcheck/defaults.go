@@ -0,0 +1,16 @@
+// Package healthcheck holds the health check spec and defaults shared by the services. The
+// build-service writes them into generated compose files, the deploy-service into the
+// Docker HEALTHCHECK and Traefik, and the monitoring-service uses them for deployments
+// that predate the health_check_* columns, so a service is checked the same way
+// everywhere when neither the repository nor the project sets a value.
+package healthcheck
+
+import "time"
+
+const (
+	DefaultPath        = "/"
+	DefaultInterval    = 10 * time.Second
+	DefaultTimeout     = 5 * time.Second
+	DefaultStartPeriod = 40 * time.Second
+	DefaultRetries     = 3
+)
@@ -0,0 +1,3 @@
+module github.com/AALXX/Obtura/shared/healthcheck
+
+go 1.24.1
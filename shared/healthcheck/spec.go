@@ -0,0 +1,125 @@
+package healthcheck
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Spec is the single health check definition for a service. The build-service stores it
+// in the build metadata, the deploy-service resolves it into the Docker HEALTHCHECK,
+// Traefik and the health_check_* columns on deployments, and the monitoring-service
+// reads those columns back into a Spec.
+//
+// ExpectedStatus 0 accepts any 2xx/3xx response. Zero durations and retries fall back to
+// the defaults.
+type Spec struct {
+	Path               string `json:"path"`
+	ExpectedStatus     int    `json:"expectedStatus,omitempty"`
+	IntervalSeconds    int    `json:"intervalSeconds,omitempty"`
+	TimeoutSeconds     int    `json:"timeoutSeconds,omitempty"`
+	StartPeriodSeconds int    `json:"startPeriodSeconds,omitempty"`
+	Retries            int    `json:"retries,omitempty"`
+
+	// Source records where the path came from, for deployment logs
+	Source string `json:"source,omitempty"`
+}
+
+func (s Spec) Interval() time.Duration {
+	return secondsOr(s.IntervalSeconds, DefaultInterval)
+}
+
+func (s Spec) Timeout() time.Duration {
+	return secondsOr(s.TimeoutSeconds, DefaultTimeout)
+}
+
+func (s Spec) StartPeriod() time.Duration {
+	return secondsOr(s.StartPeriodSeconds, DefaultStartPeriod)
+}
+
+func (s Spec) RetryCount() int {
+	if s.Retries > 0 {
+		return s.Retries
+	}
+	return DefaultRetries
+}
+
+// Accepts reports whether an HTTP status code counts as healthy
+func (s Spec) Accepts(status int) bool {
+	if s.ExpectedStatus > 0 {
+		return status == s.ExpectedStatus
+	}
+	return status >= 200 && status < 400
+}
+
+// UnhealthyGrace is how long an "unhealthy" Docker status is tolerated after start
+func (s Spec) UnhealthyGrace() time.Duration {
+	return s.StartPeriod() + time.Duration(s.RetryCount())*s.Interval()
+}
+
+// ShellCommand returns the check run inside the container against the app's port
+func (s Spec) ShellCommand(appPort int) string {
+	target := fmt.Sprintf("http://127.0.0.1:%d%s", appPort, NormalizePath(s.Path))
+	timeout := int(s.Timeout().Seconds())
+
+	if s.ExpectedStatus > 0 {
+		// wget exits non-zero on 4xx/5xx, so match the status line instead of the exit code
+		return fmt.Sprintf("wget --server-response --spider --tries=1 -T %d %s 2>&1 | grep -q 'HTTP/[0-9.]* %d' || exit 1",
+			timeout, target, s.ExpectedStatus)
+	}
+	return fmt.Sprintf("wget --no-verbose --tries=1 -T %d --spider %s || exit 1", timeout, target)
+}
+
+// Normalize makes the path safe and fills in the defaults, so the stored spec says
+// exactly what is checked
+func (s *Spec) Normalize() {
+	s.Path = NormalizePath(s.Path)
+	if s.IntervalSeconds <= 0 {
+		s.IntervalSeconds = int(DefaultInterval.Seconds())
+	}
+	if s.TimeoutSeconds <= 0 {
+		s.TimeoutSeconds = int(DefaultTimeout.Seconds())
+	}
+	if s.TimeoutSeconds >= s.IntervalSeconds && s.IntervalSeconds > 1 {
+		s.TimeoutSeconds = s.IntervalSeconds - 1
+	}
+	if s.StartPeriodSeconds <= 0 {
+		s.StartPeriodSeconds = int(DefaultStartPeriod.Seconds())
+	}
+	if s.Retries <= 0 {
+		s.Retries = DefaultRetries
+	}
+	if s.ExpectedStatus != 0 && (s.ExpectedStatus < 100 || s.ExpectedStatus > 599) {
+		s.ExpectedStatus = 0
+	}
+}
+
+// NormalizePath accepts a path or a full URL and returns a safe absolute path. Paths
+// with spaces or shell and YAML metacharacters fall back to DefaultPath, since the path
+// ends up in a shell command and a YAML file.
+func NormalizePath(path string) string {
+	path = strings.TrimSpace(path)
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		if u, err := url.Parse(path); err == nil {
+			path = u.RequestURI()
+		}
+	}
+	if path == "" {
+		return DefaultPath
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if strings.ContainsAny(path, " \t'\"`$;|&<>\\\n\r") {
+		return DefaultPath
+	}
+	return path
+}
+
+func secondsOr(seconds int, fallback time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return fallback
+}
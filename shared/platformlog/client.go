@@ -4,9 +4,38 @@ package platformlog
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/google/uuid"
+)
+
+// Version of the module; services pin it in go.mod
+const Version = "1.0.0"
+
+// SchemaVersion is the LogEvent schema this module produces. Adding optional fields
+// keeps the version; renaming, removing or changing the meaning of a field bumps it,
+// and the monitoring service must accept the new version before producers ship it.
+// Events without a schema_version predate it and are treated as version 0.
+const SchemaVersion = 1
+
+// Events per batch handed to the transports
+const batchSize = 100
+
+// SystemResourceID is the resource ID of system events, which are about a component
+// rather than a build or deployment; the component is in the metadata
+const SystemResourceID = "00000000-0000-0000-0000-000000000000"
+
+var (
+	ErrClientClosed = errors.New("platform log client is closed")
+	// ErrInvalidResourceID is returned by Log for a resource ID that is not a UUID; the
+	// event is dropped and counted in Stats.Invalid
+	ErrInvalidResourceID = errors.New("resource ID is not a UUID")
 )
 
 // LogEventType represents the high-level category of log events
@@ -85,6 +114,7 @@ const (
 
 // LogEvent represents a unified platform log event
 type LogEvent struct {
+	SchemaVersion  int             `json:"schema_version"`
 	ID             string          `json:"id"`
 	EventType      LogEventType    `json:"event_type"`
 	EventSubtype   LogEventSubtype `json:"event_subtype"`
@@ -153,12 +183,24 @@ type Metadata struct {
 	Extra map[string]interface{} `json:"extra,omitempty"`
 }
 
-// Client represents a platform logging client
+// Client represents a platform logging client. Events are batched and delivered to
+// every transport independently: a transport that fails gets its batches spooled and
+// retried with backoff, in order, without holding up the others.
 type Client struct {
 	serviceName string
 	hostname    string
+	opts        Options
 	transports  []Transport
+	deliverers  []*deliverer
 	buffer      chan LogEvent
+
+	logged    atomic.Uint64
+	dropped   atomic.Uint64
+	invalid   atomic.Uint64
+	done      chan struct{}
+	closeOnce sync.Once
+	senderWG  sync.WaitGroup
+	deliverWG sync.WaitGroup
 }
 
 // Transport interface for different log delivery methods
@@ -167,26 +209,49 @@ type Transport interface {
 	Close() error
 }
 
-// generateID creates a simple unique ID
+// generateID creates a UUID-based unique ID
 func generateID() string {
-	return fmt.Sprintf("%d-%d", time.Now().UnixNano(), os.Getpid())
+	id, err := uuid.NewRandom()
+	if err != nil {
+		// Fallback to timestamp-based ID if UUID generation fails
+		return fmt.Sprintf("%d-%d", time.Now().UnixNano(), os.Getpid())
+	}
+	return id.String()
 }
 
-// NewClient creates a new logging client
+// NewClient creates a new logging client with the default options: failed batches
+// are kept in memory and the oldest events are dropped when it falls behind
 func NewClient(serviceName string, transports ...Transport) *Client {
+	return NewClientWithOptions(serviceName, Options{}, transports...)
+}
+
+// NewClientWithOptions creates a logging client. Batches spooled to opts.SpoolDir by a
+// previous run are replayed before new events.
+func NewClientWithOptions(serviceName string, opts Options, transports ...Transport) *Client {
 	hostname, _ := os.Hostname()
 	if hostname == "" {
 		hostname = "unknown"
 	}
+	opts.applyDefaults()
 
 	c := &Client{
 		serviceName: serviceName,
 		hostname:    hostname,
+		opts:        opts,
 		transports:  transports,
-		buffer:      make(chan LogEvent, 1000),
+		buffer:      make(chan LogEvent, opts.BufferSize),
+		done:        make(chan struct{}),
+	}
+
+	for i, transport := range transports {
+		d := newDeliverer(i, transport, &c.opts, c.done)
+		c.deliverers = append(c.deliverers, d)
+		c.deliverWG.Add(1)
+		go d.run(&c.deliverWG)
 	}
 
 	// Start background sender
+	c.senderWG.Add(1)
 	go c.backgroundSender()
 
 	return c
@@ -196,12 +261,20 @@ func NewClient(serviceName string, transports ...Transport) *Client {
 func (c *Client) Log(ctx context.Context, eventType LogEventType, subtype LogEventSubtype,
 	resourceType ResourceType, resourceID string, severity Severity, message string, meta Metadata) error {
 
+	// resource_id is a UUID column the monitoring service rejects anything else for
+	id, err := uuid.Parse(strings.TrimSpace(resourceID))
+	if err != nil {
+		c.invalid.Add(1)
+		return fmt.Errorf("%w: %q for %s event", ErrInvalidResourceID, resourceID, eventType)
+	}
+
 	event := LogEvent{
+		SchemaVersion:  SchemaVersion,
 		ID:             generateID(),
 		EventType:      eventType,
 		EventSubtype:   subtype,
 		ResourceType:   resourceType,
-		ResourceID:     resourceID,
+		ResourceID:     id.String(),
 		ProjectID:      meta.ProjectID,
 		CompanyID:      meta.CompanyID,
 		ContainerID:    meta.ContainerID,
@@ -214,12 +287,58 @@ func (c *Client) Log(ctx context.Context, eventType LogEventType, subtype LogEve
 		EventTimestamp: time.Now().UTC(),
 	}
 
+	return c.enqueue(ctx, event)
+}
+
+// enqueue buffers the event for the sender, applying the overflow policy when the
+// buffer is full
+func (c *Client) enqueue(ctx context.Context, event LogEvent) error {
 	select {
-	case c.buffer <- event:
-		return nil
+	case <-c.done:
+		return ErrClientClosed
 	default:
-		return fmt.Errorf("log buffer full, dropping event")
 	}
+
+	if c.opts.Policy == PolicyBlock {
+		select {
+		case c.buffer <- event:
+			c.logged.Add(1)
+			return nil
+		case <-ctx.Done():
+			c.dropped.Add(1)
+			return ctx.Err()
+		case <-c.done:
+			return ErrClientClosed
+		}
+	}
+
+	for {
+		select {
+		case c.buffer <- event:
+			c.logged.Add(1)
+			return nil
+		default:
+		}
+		select {
+		case <-c.buffer:
+			c.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// Stats returns the delivery counters
+func (c *Client) Stats() Stats {
+	stats := Stats{
+		Logged:     c.logged.Load(),
+		Dropped:    c.dropped.Load(),
+		Invalid:    c.invalid.Load(),
+		Transports: make([]TransportStats, 0, len(c.deliverers)),
+	}
+	for _, d := range c.deliverers {
+		stats.Transports = append(stats.Transports, d.stats())
+	}
+	return stats
 }
 
 // Build logging helpers
@@ -284,6 +403,8 @@ func (c *Client) DeployStart(ctx context.Context, deploymentID, projectID, compa
 	return c.Log(ctx, EventTypeDeployment, SubtypeDeployStart, ResourceTypeDeployment, deploymentID,
 		SeverityInfo, fmt.Sprintf("Deployment to %s started using %s strategy", environment, strategy),
 		Metadata{
+			ProjectID:    projectID,
+			CompanyID:    companyID,
 			DeploymentID: deploymentID,
 			Environment:  environment,
 			Strategy:     strategy,
@@ -298,7 +419,7 @@ func (c *Client) DeployStep(ctx context.Context, deploymentID, stepName string,
 		})
 }
 
-func (c *Client) DeployComplete(ctx context.Context, deploymentID string, success bool, durationMs int64) error {
+func (c *Client) DeployComplete(ctx context.Context, deploymentID, projectID, companyID string, success bool, durationMs int64) error {
 	severity := SeverityInfo
 	message := fmt.Sprintf("Deployment completed successfully in %v", time.Duration(durationMs)*time.Millisecond)
 
@@ -310,6 +431,8 @@ func (c *Client) DeployComplete(ctx context.Context, deploymentID string, succes
 	return c.Log(ctx, EventTypeDeployment, SubtypeDeployComplete, ResourceTypeDeployment, deploymentID,
 		severity, message,
 		Metadata{
+			ProjectID:    projectID,
+			CompanyID:    companyID,
 			DeploymentID: deploymentID,
 			DurationMs:   durationMs,
 		})
@@ -357,7 +480,7 @@ func (c *Client) ContainerRestart(ctx context.Context, deploymentID, containerID
 
 // System logging helpers
 func (c *Client) SystemEvent(ctx context.Context, component, operation string, message string) error {
-	return c.Log(ctx, EventTypeSystem, SubtypeSystemStartup, ResourceTypeSystem, component,
+	return c.Log(ctx, EventTypeSystem, SubtypeSystemStartup, ResourceTypeSystem, SystemResourceID,
 		SeverityInfo, message,
 		Metadata{
 			Component: component,
@@ -365,60 +488,68 @@ func (c *Client) SystemEvent(ctx context.Context, component, operation string, m
 		})
 }
 
-// backgroundSender processes the log buffer asynchronously
+// backgroundSender batches the buffered events and hands them to the deliverers
 func (c *Client) backgroundSender() {
+	defer c.senderWG.Done()
+
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
-	batch := make([]LogEvent, 0, 100)
+	batch := make([]LogEvent, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		for _, d := range c.deliverers {
+			d.submit(batch)
+		}
+		// Deliverers keep the slice; start a new one
+		batch = make([]LogEvent, 0, batchSize)
+	}
 
 	for {
 		select {
-		case event, ok := <-c.buffer:
-			if !ok {
-				// Channel closed, send remaining batch
-				if len(batch) > 0 {
-					c.sendBatch(batch)
-				}
-				return
-			}
+		case event := <-c.buffer:
 			batch = append(batch, event)
-
-			if len(batch) >= 100 {
-				c.sendBatch(batch)
-				batch = make([]LogEvent, 0, 100)
+			if len(batch) >= batchSize {
+				flush()
 			}
 
 		case <-ticker.C:
-			if len(batch) > 0 {
-				c.sendBatch(batch)
-				batch = make([]LogEvent, 0, 100)
+			flush()
+
+		case <-c.done:
+			// Log accepts nothing after done; hand over what it already buffered
+			for len(c.buffer) > 0 {
+				batch = append(batch, <-c.buffer)
+				if len(batch) >= batchSize {
+					flush()
+				}
 			}
+			flush()
+			for _, d := range c.deliverers {
+				close(d.in)
+			}
+			return
 		}
 	}
 }
 
-func (c *Client) sendBatch(batch []LogEvent) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	for _, transport := range c.transports {
-		if err := transport.Send(ctx, batch); err != nil {
-			// Log to stderr as fallback
-			fmt.Fprintf(os.Stderr, "Failed to send logs via %T: %v\n", transport, err)
-		}
-	}
-}
-
-// Close gracefully shuts down the client
+// Close stops accepting events, delivers what is pending within the close timeout
+// and closes the transports. Undelivered spooled batches are replayed by the next
+// client using the same spool directory.
 func (c *Client) Close() error {
-	close(c.buffer)
-
-	for _, transport := range c.transports {
-		if err := transport.Close(); err != nil {
-			return err
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.senderWG.Wait()
+		c.deliverWG.Wait()
+
+		for _, transport := range c.transports {
+			if closeErr := transport.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
 		}
-	}
-
-	return nil
+	})
+	return err
 }
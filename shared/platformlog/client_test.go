@@ -0,0 +1,73 @@
+package platformlog
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLogResourceID(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		resourceID string
+		want       string // Empty when the event is rejected
+	}{
+		{"uuid", "6f1c2b7e-1d2a-4c3b-9e8f-0a1b2c3d4e5f", "6f1c2b7e-1d2a-4c3b-9e8f-0a1b2c3d4e5f"},
+		{"uppercase and padded", " 6F1C2B7E-1D2A-4C3B-9E8F-0A1B2C3D4E5F ", "6f1c2b7e-1d2a-4c3b-9e8f-0a1b2c3d4e5f"},
+		{"empty", "", ""},
+		{"name", "orchestrator", ""},
+		{"timestamp id", "1712345678901234567-42", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &failingTransport{}
+			client := NewClient("test", transport)
+
+			err := client.Log(context.Background(), EventTypeDeployment, SubtypeDeployStep, ResourceTypeDeployment,
+				tc.resourceID, SeverityInfo, "message", Metadata{})
+			if closeErr := client.Close(); closeErr != nil {
+				t.Fatal(closeErr)
+			}
+
+			stats := client.Stats()
+			if tc.want == "" {
+				if !errors.Is(err, ErrInvalidResourceID) {
+					t.Fatalf("error %v, want ErrInvalidResourceID", err)
+				}
+				if stats.Invalid != 1 || stats.Logged != 0 || len(transport.received) != 0 {
+					t.Fatalf("invalid %d, logged %d, sent %d; want the event dropped and counted",
+						stats.Invalid, stats.Logged, len(transport.received))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(transport.received) != 1 || transport.received[0].ResourceID != tc.want {
+				t.Fatalf("sent %+v, want one event for %s", transport.received, tc.want)
+			}
+			if stats.Invalid != 0 {
+				t.Fatalf("invalid %d, want 0", stats.Invalid)
+			}
+		})
+	}
+}
+
+func TestSystemEventResourceID(t *testing.T) {
+	transport := &failingTransport{}
+	client := NewClient("test", transport)
+
+	if err := client.SystemEvent(context.Background(), "orchestrator", "startup", "Service started"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transport.received) != 1 {
+		t.Fatalf("sent %d events, want 1", len(transport.received))
+	}
+	if e := transport.received[0]; e.ResourceID != SystemResourceID || e.Metadata.Component != "orchestrator" {
+		t.Fatalf("system event has resource %q and component %q", e.ResourceID, e.Metadata.Component)
+	}
+}
@@ -0,0 +1,399 @@
+package platformlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy decides what happens when events arrive faster than they can be
+// delivered or spooled
+type OverflowPolicy string
+
+const (
+	// PolicyDropOldest discards the oldest undelivered events to make room; Log never
+	// blocks. This is the default: logging must not stall a build or deployment.
+	PolicyDropOldest OverflowPolicy = "drop_oldest"
+	// PolicyBlock makes Log wait until there is room (or its context is done)
+	PolicyBlock OverflowPolicy = "block"
+)
+
+// Options tunes delivery. Zero values use the defaults.
+type Options struct {
+	// Events Log queues in memory before the sender batches them (default 1000)
+	BufferSize int
+	// Directory batches are spooled to while a transport is failing, one
+	// subdirectory per transport. Empty keeps them in memory, lost on exit.
+	SpoolDir string
+	// Spool size per transport (default 64 MiB)
+	MaxSpoolBytes int64
+	// Events kept in memory per transport without a spool directory (default 10000)
+	MaxPendingEvents int
+	Policy           OverflowPolicy
+	// Per delivery attempt (default 10s)
+	SendTimeout time.Duration
+	// Retry delay cap; delays double from a second (default 1m)
+	MaxBackoff time.Duration
+	// How long Close keeps trying to deliver what is pending (default 10s)
+	CloseTimeout time.Duration
+}
+
+func (o *Options) applyDefaults() {
+	if o.BufferSize <= 0 {
+		o.BufferSize = 1000
+	}
+	if o.MaxSpoolBytes <= 0 {
+		o.MaxSpoolBytes = 64 << 20
+	}
+	if o.MaxPendingEvents <= 0 {
+		o.MaxPendingEvents = 10000
+	}
+	if o.Policy != PolicyBlock {
+		o.Policy = PolicyDropOldest
+	}
+	if o.SendTimeout <= 0 {
+		o.SendTimeout = 10 * time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = time.Minute
+	}
+	if o.CloseTimeout <= 0 {
+		o.CloseTimeout = 10 * time.Second
+	}
+}
+
+// Stats are the client's delivery counters since it was created
+type Stats struct {
+	Logged     uint64           `json:"logged"`
+	Dropped    uint64           `json:"dropped"` // Buffer overflow, before reaching any transport
+	Invalid    uint64           `json:"invalid"` // Rejected by Log, e.g. a resource ID that is not a UUID
+	Transports []TransportStats `json:"transports"`
+}
+
+type TransportStats struct {
+	Name      string `json:"name"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"` // Spool overflow, rejected by the transport or lost on close
+	Retries   uint64 `json:"retries"` // Failed delivery attempts
+	Pending   int64  `json:"pending"` // Events waiting for a retry
+	LastError string `json:"last_error,omitempty"`
+}
+
+// permanentError marks a batch the transport will never accept; it is dropped
+// instead of retried
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps an error a transport returns for a batch that retrying cannot fix
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+// submission is how many batches the sender can hand a transport before it waits
+// (PolicyBlock) or drops the oldest one (PolicyDropOldest)
+const submissionQueue = 16
+
+// deliverer sends batches to one transport. A batch is sent directly while the
+// transport is healthy; once an attempt fails it and every later batch go to the
+// queue, which is replayed oldest first with exponential backoff, so the transport
+// sees events in order.
+type deliverer struct {
+	name      string
+	transport Transport
+	opts      *Options
+	in        chan []LogEvent
+	queue     queue
+	done      <-chan struct{} // Closed when the client closes
+
+	retryDelay time.Duration
+	retryAt    time.Time
+
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+	retries   atomic.Uint64
+	pending   atomic.Int64 // Events in the queue
+	submitted atomic.Int64 // Events in the submissions, not taken by run yet
+	mu        sync.Mutex
+	lastError string
+}
+
+func newDeliverer(index int, transport Transport, opts *Options, done <-chan struct{}) *deliverer {
+	d := &deliverer{
+		name:      transportName(transport),
+		transport: transport,
+		opts:      opts,
+		in:        make(chan []LogEvent, submissionQueue),
+		done:      done,
+	}
+
+	if opts.SpoolDir != "" {
+		dir := filepath.Join(opts.SpoolDir, fmt.Sprintf("%d-%s", index, d.name))
+		spool, err := openDiskSpool(dir, opts.MaxSpoolBytes)
+		if err == nil {
+			d.queue = spool
+			if !spool.empty() {
+				// Left by a previous run; replay before anything new
+				d.retryAt = time.Now()
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "platformlog: %v, spooling %s in memory\n", err, d.name)
+		}
+	}
+	if d.queue == nil {
+		d.queue = &memoryQueue{maxEvents: opts.MaxPendingEvents}
+	}
+	d.pending.Store(int64(d.queue.pending()))
+
+	return d
+}
+
+// transportName is the transport's type without the package and Transport suffix,
+// e.g. "http" for *HTTPTransport
+func transportName(t Transport) string {
+	typ := reflect.TypeOf(t)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	name := strings.ToLower(strings.TrimSuffix(typ.Name(), "Transport"))
+	if name == "" {
+		return "transport"
+	}
+	return name
+}
+
+// submit hands a batch to the deliverer, applying the overflow policy when it is
+// behind. Only the client's sender calls it.
+func (d *deliverer) submit(batch []LogEvent) {
+	d.submitted.Add(int64(len(batch)))
+	if d.opts.Policy == PolicyBlock {
+		d.in <- batch
+		return
+	}
+	for {
+		select {
+		case d.in <- batch:
+			return
+		default:
+		}
+		select {
+		case old := <-d.in:
+			d.submitted.Add(-int64(len(old)))
+			d.drop(len(old))
+		default:
+		}
+	}
+}
+
+func (d *deliverer) drop(n int) {
+	if n > 0 {
+		d.dropped.Add(uint64(n))
+	}
+}
+
+func (d *deliverer) run(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		if !d.queue.empty() {
+			d.replay()
+		}
+
+		// While batches are queued, new ones join the queue behind them. With
+		// PolicyBlock a full queue stops taking batches, which holds up the sender
+		// and in turn Log, until the client closes.
+		in := d.in
+		if !d.queue.empty() && d.opts.Policy == PolicyBlock && d.queue.full(batchSize) && !d.closing() {
+			in = nil
+		}
+
+		var retry <-chan time.Time
+		if !d.queue.empty() {
+			timer.Reset(time.Until(d.retryAt))
+			retry = timer.C
+		}
+
+		select {
+		case batch, ok := <-in:
+			if !ok {
+				d.flush()
+				return
+			}
+			d.submitted.Add(-int64(len(batch)))
+			if d.queue.empty() {
+				d.send(batch)
+			} else {
+				d.enqueue(batch)
+			}
+		case <-retry:
+		case <-d.doneOrNever(in):
+		}
+		if retry != nil && !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
+
+func (d *deliverer) closing() bool {
+	select {
+	case <-d.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// doneOrNever wakes a deliverer that stopped taking batches when the client closes,
+// so it drains the submissions before the sender can close them
+func (d *deliverer) doneOrNever(in chan []LogEvent) <-chan struct{} {
+	if in == nil && !d.closing() {
+		return d.done
+	}
+	return nil
+}
+
+// send tries a batch the queue holds nothing ahead of; a failure queues it
+func (d *deliverer) send(batch []LogEvent) {
+	err := d.attempt(batch)
+	if err == nil {
+		return
+	}
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return
+	}
+	d.enqueue(batch)
+	d.backoff()
+}
+
+// replay sends queued batches, oldest first, while the transport accepts them
+func (d *deliverer) replay() {
+	for !d.queue.empty() && !time.Now().Before(d.retryAt) {
+		batch, err := d.queue.peek()
+		if err != nil {
+			// Unreadable spool file: nothing to retry
+			fmt.Fprintf(os.Stderr, "platformlog: %v\n", err)
+			d.pop(true)
+			continue
+		}
+
+		err = d.attempt(batch)
+		var permanent *permanentError
+		if err != nil && !errors.As(err, &permanent) {
+			d.backoff()
+			return
+		}
+		d.pop(false)
+		if d.queue.empty() {
+			fmt.Fprintf(os.Stderr, "platformlog: %s transport recovered, spooled events delivered\n", d.name)
+		}
+	}
+}
+
+// attempt sends the batch once. Permanent errors drop the batch.
+func (d *deliverer) attempt(batch []LogEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.SendTimeout)
+	defer cancel()
+
+	err := d.transport.Send(ctx, batch)
+	if err == nil {
+		d.delivered.Add(uint64(len(batch)))
+		d.retryDelay = 0
+		return nil
+	}
+
+	d.mu.Lock()
+	d.lastError = err.Error()
+	d.mu.Unlock()
+
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		fmt.Fprintf(os.Stderr, "platformlog: %s transport rejected %d events: %v\n", d.name, len(batch), err)
+		d.drop(len(batch))
+		return err
+	}
+
+	d.retries.Add(1)
+	if d.retryDelay == 0 {
+		// First failure of a streak; later ones are only counted
+		fmt.Fprintf(os.Stderr, "platformlog: %s transport failed, spooling events: %v\n", d.name, err)
+	}
+	return err
+}
+
+func (d *deliverer) backoff() {
+	d.retryDelay = min(max(2*d.retryDelay, time.Second), d.opts.MaxBackoff)
+	d.retryAt = time.Now().Add(d.retryDelay)
+}
+
+func (d *deliverer) enqueue(batch []LogEvent) {
+	dropped, err := d.queue.append(batch)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "platformlog: %v\n", err)
+	}
+	d.drop(dropped)
+	d.pending.Store(int64(d.queue.pending()))
+}
+
+func (d *deliverer) pop(dropped bool) {
+	n := d.queue.pop()
+	if dropped {
+		d.drop(n)
+	}
+	d.pending.Store(int64(d.queue.pending()))
+}
+
+// flush runs after the last submission: it keeps replaying until the queue is empty
+// or the close timeout passes. Spooled batches left over are replayed by the next
+// run; batches held in memory are lost.
+func (d *deliverer) flush() {
+	deadline := time.Now().Add(d.opts.CloseTimeout)
+	for !d.queue.empty() && time.Now().Before(deadline) {
+		d.retryAt = time.Time{}
+		before := d.queue.pending()
+		d.replay()
+		if d.queue.pending() == before {
+			time.Sleep(min(time.Until(deadline), time.Second))
+		}
+	}
+
+	if memory, ok := d.queue.(*memoryQueue); ok && !memory.empty() {
+		fmt.Fprintf(os.Stderr, "platformlog: %s transport unavailable, dropping %d events on close\n", d.name, memory.pending())
+		d.drop(memory.pending())
+		for !memory.empty() {
+			memory.pop()
+		}
+		d.pending.Store(0)
+	}
+}
+
+func (d *deliverer) stats() TransportStats {
+	d.mu.Lock()
+	lastError := d.lastError
+	d.mu.Unlock()
+
+	return TransportStats{
+		Name:      d.name,
+		Delivered: d.delivered.Load(),
+		Dropped:   d.dropped.Load(),
+		Retries:   d.retries.Load(),
+		Pending:   d.pending.Load() + d.submitted.Load(),
+		LastError: lastError,
+	}
+}
@@ -0,0 +1,337 @@
+package platformlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failingTransport rejects every batch with a retryable error until it recovers
+type failingTransport struct {
+	mu       sync.Mutex
+	failing  bool
+	attempts int
+	received []LogEvent
+}
+
+func (t *failingTransport) Send(ctx context.Context, events []LogEvent) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.attempts++
+	if t.failing {
+		return errors.New("connection refused")
+	}
+	t.received = append(t.received, events...)
+	return nil
+}
+
+func (t *failingTransport) Close() error { return nil }
+
+func (t *failingTransport) setFailing(failing bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failing = failing
+}
+
+func (t *failingTransport) messages() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	messages := make([]string, len(t.received))
+	for i, e := range t.received {
+		messages[i] = e.Message
+	}
+	return messages
+}
+
+func testBatch(first, n int) []LogEvent {
+	batch := make([]LogEvent, n)
+	for i := range batch {
+		batch[i] = LogEvent{ID: generateID(), Message: fmt.Sprintf("event %d", first+i)}
+	}
+	return batch
+}
+
+func eventMessages(first, n int) []string {
+	messages := make([]string, n)
+	for i := range messages {
+		messages[i] = fmt.Sprintf("event %d", first+i)
+	}
+	return messages
+}
+
+func equalMessages(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func newTestDeliverer(t *testing.T, transport Transport, opts Options) *deliverer {
+	t.Helper()
+	opts.applyDefaults()
+	return newDeliverer(0, transport, &opts, make(chan struct{}))
+}
+
+func TestDelivererSpoolsOnFailureAndReplaysInOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		spoolDir bool
+	}{
+		{"memory", false},
+		{"disk", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &failingTransport{failing: true}
+			var opts Options
+			if tc.spoolDir {
+				opts.SpoolDir = t.TempDir()
+			}
+			d := newTestDeliverer(t, transport, opts)
+
+			// The failed batch is queued and later ones join it, as run does
+			d.send(testBatch(0, 3))
+			d.enqueue(testBatch(3, 2))
+			d.enqueue(testBatch(5, 4))
+
+			if got := d.stats(); got.Pending != 9 || got.Retries != 1 || got.Delivered != 0 {
+				t.Fatalf("after failure: pending %d, retries %d, delivered %d; want 9, 1, 0", got.Pending, got.Retries, got.Delivered)
+			}
+			if d.retryAt.IsZero() {
+				t.Fatal("failure did not schedule a retry")
+			}
+
+			// Not due yet: nothing is retried
+			d.replay()
+			if transport.attempts != 1 {
+				t.Fatalf("replayed before the backoff elapsed: %d attempts", transport.attempts)
+			}
+
+			transport.setFailing(false)
+			d.retryAt = time.Time{}
+			d.replay()
+
+			if got := transport.messages(); !equalMessages(got, eventMessages(0, 9)) {
+				t.Fatalf("replayed %v, want events 0-8 in order", got)
+			}
+			if got := d.stats(); got.Pending != 0 || got.Delivered != 9 || got.Dropped != 0 {
+				t.Fatalf("after recovery: pending %d, delivered %d, dropped %d; want 0, 9, 0", got.Pending, got.Delivered, got.Dropped)
+			}
+			if tc.spoolDir {
+				if entries, _ := os.ReadDir(d.queue.(*diskSpool).dir); len(entries) != 0 {
+					t.Fatalf("%d spool files left after replay", len(entries))
+				}
+			}
+		})
+	}
+}
+
+func TestDelivererReplayStopsAtFirstFailure(t *testing.T) {
+	transport := &failingTransport{failing: true}
+	d := newTestDeliverer(t, transport, Options{SpoolDir: t.TempDir()})
+
+	d.send(testBatch(0, 2))
+	d.enqueue(testBatch(2, 2))
+
+	// Still failing: the oldest batch is tried once and stays first
+	d.retryAt = time.Time{}
+	d.replay()
+	if transport.attempts != 2 || d.stats().Pending != 4 {
+		t.Fatalf("attempts %d, pending %d; want 2, 4", transport.attempts, d.stats().Pending)
+	}
+	if d.retryDelay != 2*time.Second {
+		t.Fatalf("retry delay %v, want it doubled to 2s", d.retryDelay)
+	}
+
+	transport.setFailing(false)
+	d.retryAt = time.Time{}
+	d.replay()
+	if got := transport.messages(); !equalMessages(got, eventMessages(0, 4)) {
+		t.Fatalf("replayed %v, want events 0-3 in order", got)
+	}
+	if d.retryDelay != 0 {
+		t.Fatalf("retry delay %v not reset by a success", d.retryDelay)
+	}
+}
+
+func TestDelivererDropsCorruptSpoolSegment(t *testing.T) {
+	transport := &failingTransport{failing: true}
+	d := newTestDeliverer(t, transport, Options{SpoolDir: t.TempDir()})
+
+	d.send(testBatch(0, 3))
+	d.enqueue(testBatch(3, 2))
+
+	// The oldest segment is damaged on disk
+	spool := d.queue.(*diskSpool)
+	if err := os.WriteFile(filepath.Join(spool.dir, spool.segments[0].name), []byte("{not json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	transport.setFailing(false)
+	d.retryAt = time.Time{}
+	d.replay()
+
+	if got := transport.messages(); !equalMessages(got, eventMessages(3, 2)) {
+		t.Fatalf("replayed %v, want events 3-4", got)
+	}
+	if got := d.stats(); got.Dropped != 3 || got.Delivered != 2 || got.Pending != 0 {
+		t.Fatalf("dropped %d, delivered %d, pending %d; want the corrupt segment's 3 events dropped, 2, 0",
+			got.Dropped, got.Delivered, got.Pending)
+	}
+}
+
+func TestDelivererDropsPermanentRejections(t *testing.T) {
+	transport := &rejectingTransport{}
+	d := newTestDeliverer(t, transport, Options{})
+
+	d.send(testBatch(0, 5))
+
+	if got := d.stats(); got.Dropped != 5 || got.Pending != 0 || got.Retries != 0 {
+		t.Fatalf("dropped %d, pending %d, retries %d; want 5, 0, 0", got.Dropped, got.Pending, got.Retries)
+	}
+}
+
+type rejectingTransport struct{}
+
+func (rejectingTransport) Send(context.Context, []LogEvent) error {
+	return Permanent(errors.New("400 bad request"))
+}
+
+func (rejectingTransport) Close() error { return nil }
+
+func TestDelivererDropOldestAccounting(t *testing.T) {
+	t.Run("memory queue", func(t *testing.T) {
+		transport := &failingTransport{failing: true}
+		d := newTestDeliverer(t, transport, Options{MaxPendingEvents: 10})
+
+		d.send(testBatch(0, 4))
+		d.enqueue(testBatch(4, 4))
+		d.enqueue(testBatch(8, 4)) // Pushes out events 0-3
+
+		if got := d.stats(); got.Dropped != 4 || got.Pending != 8 {
+			t.Fatalf("dropped %d, pending %d; want 4, 8", got.Dropped, got.Pending)
+		}
+
+		transport.setFailing(false)
+		d.retryAt = time.Time{}
+		d.replay()
+		if got := transport.messages(); !equalMessages(got, eventMessages(4, 8)) {
+			t.Fatalf("replayed %v, want events 4-11", got)
+		}
+	})
+
+	t.Run("submissions", func(t *testing.T) {
+		// No run loop: submissions pile up until the oldest are dropped
+		d := newTestDeliverer(t, &failingTransport{}, Options{})
+		for i := 0; i < submissionQueue+3; i++ {
+			d.submit(testBatch(i*2, 2))
+		}
+
+		if got := d.stats(); got.Dropped != 6 || got.Pending != 2*submissionQueue {
+			t.Fatalf("dropped %d, pending %d; want 6, %d", got.Dropped, got.Pending, 2*submissionQueue)
+		}
+		if oldest := <-d.in; oldest[0].Message != "event 6" {
+			t.Fatalf("oldest submission is %q, want event 6", oldest[0].Message)
+		}
+	})
+}
+
+func TestClientRecoversSpooledEvents(t *testing.T) {
+	transport := &failingTransport{failing: true}
+	client := NewClientWithOptions("test", Options{SpoolDir: t.TempDir()}, transport)
+	defer client.Close()
+
+	logEvents(t, client, 0, 150)
+	waitFor(t, "events to be spooled", func() bool {
+		return client.Stats().Transports[0].Pending == 150
+	})
+
+	transport.setFailing(false)
+	logEvents(t, client, 150, 50)
+	waitFor(t, "spooled events to be delivered", func() bool {
+		return client.Stats().Transports[0].Delivered == 200
+	})
+
+	if got := transport.messages(); !equalMessages(got, eventMessages(0, 200)) {
+		t.Fatalf("delivered %d events out of order", len(got))
+	}
+}
+
+func TestClientReplaysSpoolAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	down := &failingTransport{failing: true}
+	client := NewClientWithOptions("test", Options{SpoolDir: dir, CloseTimeout: 10 * time.Millisecond}, down)
+	logEvents(t, client, 0, 30)
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := client.Stats().Transports[0]; got.Pending != 30 || got.Dropped != 0 {
+		t.Fatalf("after close: pending %d, dropped %d; want 30 spooled, 0 dropped", got.Pending, got.Dropped)
+	}
+
+	up := &failingTransport{}
+	client = NewClientWithOptions("test", Options{SpoolDir: dir}, up)
+	logEvents(t, client, 30, 10)
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := up.messages(); !equalMessages(got, eventMessages(0, 40)) {
+		t.Fatalf("delivered %v, want the spooled events 0-29 before 30-39", got)
+	}
+}
+
+func TestClientAccountsForEveryEvent(t *testing.T) {
+	transport := &failingTransport{failing: true}
+	client := NewClientWithOptions("test", Options{MaxPendingEvents: 50, CloseTimeout: 10 * time.Millisecond}, transport)
+
+	logEvents(t, client, 0, 120)
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a spool directory, what is still pending on close is dropped
+	stats := client.Stats()
+	got := stats.Transports[0]
+	if got.Delivered != 0 || got.Pending != 0 {
+		t.Fatalf("delivered %d, pending %d; want 0, 0", got.Delivered, got.Pending)
+	}
+	if stats.Logged-stats.Dropped != got.Dropped {
+		t.Fatalf("logged %d, dropped %d by the client and %d by the transport; every event must be counted once",
+			stats.Logged, stats.Dropped, got.Dropped)
+	}
+}
+
+func logEvents(t *testing.T, client *Client, first, n int) {
+	t.Helper()
+	for i := first; i < first+n; i++ {
+		err := client.Log(context.Background(), EventTypeSystem, SubtypeSystemStartup, ResourceTypeSystem,
+			generateID(), SeverityInfo, fmt.Sprintf("event %d", i), Metadata{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
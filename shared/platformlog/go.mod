@@ -0,0 +1,8 @@
+module github.com/AALXX/Obtura/shared/platformlog
+
+go 1.24.1
+
+require (
+	github.com/google/uuid v1.6.0
+	github.com/streadway/amqp v1.1.0
+)
@@ -77,6 +77,44 @@ export interface LogEvent {
   eventTimestamp: Date;
 }
 
+// LogEvent schema this SDK produces; must match SchemaVersion in the Go module
+export const SCHEMA_VERSION = 1;
+
+// Resource ID of system events, which are about a component rather than a build or
+// deployment; the component is in the metadata
+export const SYSTEM_RESOURCE_ID = '00000000-0000-0000-0000-000000000000';
+
+const toSnakeCase = (key: string): string => key.replace(/[A-Z]/g, (c) => `_${c.toLowerCase()}`);
+
+// The ingest endpoint takes the Go module's snake_case wire format
+function toWire(event: LogEvent): Record<string, any> {
+  const metadata: Record<string, any> = {};
+  for (const [key, value] of Object.entries(event.metadata || {})) {
+    if (value !== undefined) {
+      metadata[toSnakeCase(key)] = value;
+    }
+  }
+
+  return {
+    schema_version: SCHEMA_VERSION,
+    id: event.id,
+    event_type: event.eventType,
+    event_subtype: event.eventSubtype,
+    resource_type: event.resourceType,
+    resource_id: event.resourceId,
+    project_id: event.projectId,
+    company_id: event.companyId,
+    container_id: event.containerId,
+    container_name: event.containerName,
+    severity: event.severity,
+    message: event.message,
+    metadata,
+    source_service: event.sourceService,
+    source_host: event.sourceHost,
+    event_timestamp: event.eventTimestamp.toISOString(),
+  };
+}
+
 export interface PlatformLoggerConfig {
   serviceName: string;
   monitoringServiceUrl: string;
@@ -102,7 +140,7 @@ export class PlatformLogger {
   }
 
   private generateId(): string {
-    return `${Date.now()}-${Math.random().toString(36).substr(2, 9)}`;
+    return require('crypto').randomUUID();
   }
 
   private startFlushTimer(): void {
@@ -127,7 +165,7 @@ export class PlatformLogger {
           'Content-Type': 'application/json',
           ...(this.config.apiKey && { 'X-API-Key': this.config.apiKey }),
         },
-        body: JSON.stringify({ events }),
+        body: JSON.stringify({ events: events.map(toWire) }),
       });
 
       if (!response.ok) {
@@ -361,7 +399,7 @@ export class PlatformLogger {
       'system',
       'system_startup',
       'system',
-      component,
+      SYSTEM_RESOURCE_ID,
       'info',
       message,
       { component, operation }
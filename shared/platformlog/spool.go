@@ -0,0 +1,205 @@
+package platformlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// queue holds the batches a transport has not accepted yet, oldest first
+type queue interface {
+	empty() bool
+	// full reports whether a batch of n events would push out older ones
+	full(n int) bool
+	// append adds a batch and returns how many events were dropped to make room
+	append(events []LogEvent) (int, error)
+	peek() ([]LogEvent, error)
+	// pop removes the oldest batch and returns its event count, known even when the
+	// batch can no longer be read
+	pop() int
+	pending() int
+}
+
+// memoryQueue is used when the client has no spool directory; it is lost on exit
+type memoryQueue struct {
+	batches   [][]LogEvent
+	events    int
+	maxEvents int
+}
+
+func (q *memoryQueue) empty() bool {
+	return len(q.batches) == 0
+}
+
+func (q *memoryQueue) full(n int) bool {
+	return q.events+n > q.maxEvents && len(q.batches) > 0
+}
+
+func (q *memoryQueue) append(events []LogEvent) (int, error) {
+	dropped := 0
+	for q.full(len(events)) {
+		dropped += len(q.batches[0])
+		q.pop()
+	}
+	q.batches = append(q.batches, events)
+	q.events += len(events)
+	return dropped, nil
+}
+
+func (q *memoryQueue) peek() ([]LogEvent, error) {
+	return q.batches[0], nil
+}
+
+func (q *memoryQueue) pop() int {
+	n := len(q.batches[0])
+	q.events -= n
+	q.batches[0] = nil
+	q.batches = q.batches[1:]
+	return n
+}
+
+func (q *memoryQueue) pending() int {
+	return q.events
+}
+
+// diskSpool is a write-ahead spool: one NDJSON file per batch named
+// {sequence}-{events}.ndjson, written before the batch is retried and removed once a
+// transport accepted it, so events survive restarts of the service. Batches left by a
+// previous run are replayed first.
+type diskSpool struct {
+	dir      string
+	maxBytes int64
+	segments []spoolSegment // Oldest first
+	size     int64
+	events   int
+	seq      uint64
+}
+
+type spoolSegment struct {
+	name   string
+	size   int64
+	events int
+}
+
+func openDiskSpool(dir string, maxBytes int64) (*diskSpool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create log spool: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log spool: %w", err)
+	}
+
+	s := &diskSpool{dir: dir, maxBytes: maxBytes}
+	for _, entry := range entries {
+		name := entry.Name()
+		seqStr, countStr, ok := strings.Cut(strings.TrimSuffix(name, ".ndjson"), "-")
+		seq, seqErr := strconv.ParseUint(seqStr, 10, 64)
+		count, countErr := strconv.Atoi(countStr)
+		info, infoErr := entry.Info()
+		if !ok || !strings.HasSuffix(name, ".ndjson") || seqErr != nil || countErr != nil || infoErr != nil {
+			// Leftover of an interrupted write
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+
+		s.segments = append(s.segments, spoolSegment{name: name, size: info.Size(), events: count})
+		s.size += info.Size()
+		s.events += count
+		if seq > s.seq {
+			s.seq = seq
+		}
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].name < s.segments[j].name })
+
+	return s, nil
+}
+
+func (s *diskSpool) empty() bool {
+	return len(s.segments) == 0
+}
+
+// full is approximate: it assumes the batch is as large per event as the spool average
+func (s *diskSpool) full(n int) bool {
+	if len(s.segments) == 0 || s.events == 0 {
+		return false
+	}
+	return s.size+int64(n)*(s.size/int64(s.events)) > s.maxBytes
+}
+
+func (s *diskSpool) append(events []LogEvent) (int, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range events {
+		if err := encoder.Encode(&events[i]); err != nil {
+			return len(events), err
+		}
+	}
+	size := int64(buf.Len())
+	if size > s.maxBytes {
+		return len(events), fmt.Errorf("batch of %d bytes exceeds the log spool", size)
+	}
+
+	dropped := 0
+	for s.size+size > s.maxBytes && len(s.segments) > 0 {
+		dropped += s.segments[0].events
+		s.pop()
+	}
+
+	s.seq++
+	name := fmt.Sprintf("%020d-%d.ndjson", s.seq, len(events))
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		os.Remove(tmp)
+		return dropped + len(events), fmt.Errorf("failed to write log spool: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmp)
+		return dropped + len(events), fmt.Errorf("failed to write log spool: %w", err)
+	}
+
+	s.segments = append(s.segments, spoolSegment{name: name, size: size, events: len(events)})
+	s.size += size
+	s.events += len(events)
+	return dropped, nil
+}
+
+func (s *diskSpool) peek() ([]LogEvent, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, s.segments[0].name))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]LogEvent, 0, s.segments[0].events)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		var e LogEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("corrupt log spool %s: %w", s.segments[0].name, err)
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+// pop takes the event count from the segment's name, so an unreadable segment is
+// still counted
+func (s *diskSpool) pop() int {
+	segment := s.segments[0]
+	os.Remove(filepath.Join(s.dir, segment.name))
+	s.segments = s.segments[1:]
+	s.size -= segment.size
+	s.events -= segment.events
+	return segment.events
+}
+
+func (s *diskSpool) pending() int {
+	return s.events
+}
@@ -0,0 +1,162 @@
+package platformlog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryQueueDropsOldest(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		maxEvents   int
+		batches     []int
+		wantDropped int
+		wantPending int
+		wantFirst   string
+	}{
+		{"within limit", 10, []int{4, 4}, 0, 8, "event 0"},
+		{"exactly full", 8, []int{4, 4}, 0, 8, "event 0"},
+		{"drops oldest batch", 10, []int{4, 4, 4}, 4, 8, "event 4"},
+		{"drops several batches", 10, []int{2, 2, 2, 9}, 6, 9, "event 6"},
+		// A batch larger than the limit is still kept rather than dropping everything
+		{"oversized batch", 5, []int{2, 8}, 2, 8, "event 2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			q := &memoryQueue{maxEvents: tc.maxEvents}
+			dropped, first := 0, 0
+			for _, n := range tc.batches {
+				d, err := q.append(testBatch(first, n))
+				if err != nil {
+					t.Fatal(err)
+				}
+				dropped += d
+				first += n
+			}
+
+			if dropped != tc.wantDropped || q.pending() != tc.wantPending {
+				t.Fatalf("dropped %d, pending %d; want %d, %d", dropped, q.pending(), tc.wantDropped, tc.wantPending)
+			}
+			batch, _ := q.peek()
+			if batch[0].Message != tc.wantFirst {
+				t.Fatalf("oldest event is %q, want %q", batch[0].Message, tc.wantFirst)
+			}
+		})
+	}
+}
+
+func TestDiskSpoolSizeCap(t *testing.T) {
+	dir := t.TempDir()
+
+	// Measure one encoded batch to size the spool in batches
+	probe, err := openDiskSpool(filepath.Join(t.TempDir(), "probe"), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := probe.append(testBatch(0, 5)); err != nil {
+		t.Fatal(err)
+	}
+	batchBytes := probe.size
+
+	// Room for three batches and a bit
+	s, err := openDiskSpool(dir, 3*batchBytes+batchBytes/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dropped := 0
+	for i := 0; i < 5; i++ {
+		n, err := s.append(testBatch(i*5, 5))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dropped += n
+	}
+
+	if dropped != 10 {
+		t.Fatalf("dropped %d events, want the two oldest batches (10)", dropped)
+	}
+	if s.size > s.maxBytes {
+		t.Fatalf("spool holds %d bytes, over its %d byte cap", s.size, s.maxBytes)
+	}
+	if s.pending() != 15 || len(s.segments) != 3 {
+		t.Fatalf("pending %d events in %d segments; want 15 in 3", s.pending(), len(s.segments))
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Fatalf("%d spool files on disk, want 3", len(entries))
+	}
+
+	batch, err := s.peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch[0].Message != "event 10" {
+		t.Fatalf("oldest spooled event is %q, want event 10", batch[0].Message)
+	}
+}
+
+func TestDiskSpoolRejectsOversizedBatch(t *testing.T) {
+	s, err := openDiskSpool(t.TempDir(), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dropped, err := s.append(testBatch(0, 3))
+	if err == nil {
+		t.Fatal("batch larger than the spool was accepted")
+	}
+	if dropped != 3 || !s.empty() {
+		t.Fatalf("dropped %d, empty %v; want the batch counted as dropped and nothing spooled", dropped, s.empty())
+	}
+}
+
+func TestDiskSpoolReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := openDiskSpool(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.append(testBatch(i*2, 2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.pop()
+
+	// An interrupted write leaves a temporary file behind
+	if err := os.WriteFile(filepath.Join(dir, "00000000000000000009-2.ndjson.tmp"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := openDiskSpool(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.pending() != 4 || len(reopened.segments) != 2 || reopened.size != s.size {
+		t.Fatalf("reopened with %d events in %d segments (%d bytes); want 4 in 2 (%d bytes)",
+			reopened.pending(), len(reopened.segments), reopened.size, s.size)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "00000000000000000009-2.ndjson.tmp")); !os.IsNotExist(err) {
+		t.Fatal("leftover temporary file was not removed")
+	}
+
+	// New batches continue the sequence after the replayed ones
+	if _, err := reopened.append(testBatch(6, 2)); err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for !reopened.empty() {
+		batch, err := reopened.peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range batch {
+			messages = append(messages, e.Message)
+		}
+		reopened.pop()
+	}
+	if !equalMessages(messages, eventMessages(2, 6)) {
+		t.Fatalf("replayed %v, want events 2-7 in order", messages)
+	}
+}
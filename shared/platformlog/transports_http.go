@@ -5,10 +5,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 )
 
+// IngestPath is the monitoring service endpoint HTTPTransport posts to
+const IngestPath = "/api/platform-logs/ingest"
+
+// IngestRequest is the body of an ingest request
+type IngestRequest struct {
+	Events []LogEvent `json:"events"`
+}
+
 // HTTPTransport sends logs via HTTP POST to logging service
 type HTTPTransport struct {
 	endpoint   string
@@ -33,12 +42,12 @@ func (h *HTTPTransport) Send(ctx context.Context, events []LogEvent) error {
 		return nil
 	}
 
-	body, err := json.Marshal(events)
+	body, err := json.Marshal(IngestRequest{Events: events})
 	if err != nil {
 		return fmt.Errorf("failed to marshal log events: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", h.endpoint+"/api/platform-logs/ingest", bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", h.endpoint+IngestPath, bytes.NewBuffer(body))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -55,7 +64,15 @@ func (h *HTTPTransport) Send(ctx context.Context, events []LogEvent) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("logging service returned status %d", resp.StatusCode)
+		// Read response body for more detailed error information
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		err := fmt.Errorf("logging service returned status %d: %s", resp.StatusCode, string(respBody))
+		// Other client errors mean the batch itself is refused; retrying cannot help
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests &&
+			resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden {
+			return Permanent(err)
+		}
+		return err
 	}
 
 	return nil